@@ -31,7 +31,6 @@ import (
 var (
 	enableProcessor, enableRouter, enableReplay                bool
 	objectStorageDestinations                                  []string
-	asyncDestinations                                          []string
 	routerLoaded                                               utilsync.First
 	processorLoaded                                            utilsync.First
 	pkgLogger                                                  logger.Logger
@@ -74,7 +73,6 @@ func loadConfig() {
 	config.RegisterBoolConfigVariable(types.DEFAULT_REPLAY_ENABLED, &enableReplay, false, "Replay.enabled")
 	config.RegisterBoolConfigVariable(true, &enableRouter, false, "enableRouter")
 	objectStorageDestinations = []string{"S3", "GCS", "AZURE_BLOB", "MINIO", "DIGITAL_OCEAN_SPACES"}
-	asyncDestinations = []string{"MARKETO_BULK_UPLOAD"}
 }
 
 func rudderCoreDBValidator() {
@@ -188,7 +186,7 @@ func monitorDestRouters(ctx context.Context, routerFactory *router.Factory, batc
 					// For batch router destinations
 					if misc.Contains(objectStorageDestinations, destination.DestinationDefinition.Name) ||
 						misc.Contains(warehouseutils.WarehouseDestinations, destination.DestinationDefinition.Name) ||
-						misc.Contains(asyncDestinations, destination.DestinationDefinition.Name) {
+						batchrouter.IsAsyncDestination(destination.DestinationDefinition.Name) {
 						_, ok := dstToBatchRouter[destination.DestinationDefinition.Name]
 						if !ok {
 							pkgLogger.Info("Starting a new Batch Destination Router ", destination.DestinationDefinition.Name)
@@ -31,6 +31,7 @@ import (
 	"github.com/rudderlabs/rudder-server/config"
 	backendconfig "github.com/rudderlabs/rudder-server/config/backend-config"
 	event_schema "github.com/rudderlabs/rudder-server/event-schema"
+	"github.com/rudderlabs/rudder-server/gateway/kafkasource"
 	"github.com/rudderlabs/rudder-server/gateway/response"
 	"github.com/rudderlabs/rudder-server/gateway/webhook"
 	"github.com/rudderlabs/rudder-server/jobsdb"
@@ -175,6 +176,7 @@ type HandleT struct {
 	webRequestBatchCount                                       uint64
 	userWebRequestWorkers                                      []*userWebRequestWorkerT
 	webhookHandler                                             *webhook.HandleT
+	kafkaSourceHandler                                         *kafkasource.HandleT
 	suppressUserHandler                                        types.UserSuppression
 	eventSchemaHandler                                         types.EventSchemasI
 	versionHandler                                             func(w http.ResponseWriter, r *http.Request)
@@ -1524,6 +1526,7 @@ func (gateway *HandleT) backendConfigSubscriber() {
 			newEnabledWriteKeyWebhookMap   = map[string]string{}
 			newEnabledWriteKeyWorkspaceMap = map[string]string{}
 			newSourceIDToNameMap           = map[string]string{}
+			kafkaSources                   []backendconfig.SourceT
 		)
 		config := data.Data.(map[string]backendconfig.ConfigT)
 		for workspaceID, wsConfig := range config {
@@ -1537,6 +1540,9 @@ func (gateway *HandleT) backendConfigSubscriber() {
 						newEnabledWriteKeyWebhookMap[source.WriteKey] = source.SourceDefinition.Name
 						gateway.webhookHandler.Register(source.SourceDefinition.Name)
 					}
+					if source.SourceDefinition.Name == kafkasource.SourceType {
+						kafkaSources = append(kafkaSources, source)
+					}
 				}
 			}
 		}
@@ -1546,6 +1552,8 @@ func (gateway *HandleT) backendConfigSubscriber() {
 		enabledWriteKeyWorkspaceMap = newEnabledWriteKeyWorkspaceMap
 		sourceIDToNameMap = newSourceIDToNameMap
 		configSubscriberLock.Unlock()
+		// write keys need to be enabled before Kafka sources start storing events
+		gateway.kafkaSourceHandler.Sync(kafkaSources)
 	}
 }
 
@@ -1655,6 +1663,7 @@ func (gateway *HandleT) Setup(
 	gateway.rrh = &RegularRequestHandler{}
 
 	gateway.webhookHandler = webhook.Setup(gateway)
+	gateway.kafkaSourceHandler = kafkasource.Setup(gateway)
 
 	whURL, err := url.ParseRequestURI(misc.GetWarehouseURL())
 	if err != nil {
@@ -1720,6 +1729,10 @@ func (gateway *HandleT) Shutdown() error {
 	if err := gateway.webhookHandler.Shutdown(); err != nil {
 		return err
 	}
+	// Kafka source consumers store their last batches before the worker queues are closed
+	if err := gateway.kafkaSourceHandler.Shutdown(); err != nil {
+		return err
+	}
 
 	// UserWebRequestWorkers
 	for _, worker := range gateway.userWebRequestWorkers {
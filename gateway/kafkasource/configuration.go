@@ -0,0 +1,212 @@
+package kafkasource
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+
+	"github.com/rudderlabs/rudder-server/config"
+	backendconfig "github.com/rudderlabs/rudder-server/config/backend-config"
+	"github.com/rudderlabs/rudder-server/services/streammanager/kafka/client"
+)
+
+const (
+	// SourceType is the name of the source definition of Kafka sources
+	SourceType = "KAFKA"
+
+	jsonFormat           = "JSON"
+	schemaRegistryFormat = "SCHEMA_REGISTRY"
+)
+
+var rudderEventTypes = map[string]struct{}{
+	"track": {}, "identify": {}, "page": {}, "screen": {}, "group": {}, "alias": {},
+}
+
+func loadConfig() {
+	// Number of Kafka messages that are stored in the gateway with a single request
+	config.RegisterIntConfigVariable(100, &maxBatchSize, true, 1, "Gateway.kafkaSource.maxBatchSize")
+	// Maximum size of the messages that are stored in the gateway with a single request
+	config.RegisterIntConfigVariable(1000, &maxBatchSizeInBytes, true, 1024, "Gateway.kafkaSource.maxBatchSizeInKB")
+	// Timeout after which a batch is stored anyway with whatever messages are available
+	config.RegisterDurationConfigVariable(1, &maxBatchWait, true, time.Second, "Gateway.kafkaSource.maxBatchWait")
+	config.RegisterDurationConfigVariable(10, &dialTimeout, false, time.Second, "Gateway.kafkaSource.dialTimeout")
+	// Max time between retries of storing a batch or connecting to Kafka
+	config.RegisterDurationConfigVariable(30, &maxRetryInterval, false, time.Second, "Gateway.kafkaSource.maxRetryInterval")
+	// Time left to the batch being stored to be committed once its source is stopped
+	config.RegisterDurationConfigVariable(10, &shutdownTimeout, false, time.Second, "Gateway.kafkaSource.shutdownTimeout")
+}
+
+// sourceConfig is the config of a Kafka source
+type sourceConfig struct {
+	HostName string
+	Port     string
+	// Topics is a comma separated list of topics
+	Topics string
+	// ConsumerGroup defaults to "rudder-<sourceID>"
+	ConsumerGroup string
+	// StartFromBeginning makes a new consumer group start from the oldest message instead of the newest one
+	StartFromBeginning bool
+	SslEnabled         bool
+	CACertificate      string
+	UseSASL            bool
+	SaslType           string
+	Username           string
+	Password           string
+	// Format is either JSON (default) or SCHEMA_REGISTRY
+	Format                 string
+	SchemaRegistryURL      string
+	SchemaRegistryUsername string
+	SchemaRegistryPassword string
+	// EventName is the name of the track events generated from messages that are not Rudder events,
+	// it defaults to the topic
+	EventName string
+}
+
+func parseSourceConfig(source *backendconfig.SourceT) (*sourceConfig, error) {
+	conf := sourceConfig{}
+	jsonConfig, err := json.Marshal(source.Config)
+	if err != nil {
+		return nil, fmt.Errorf("error while marshalling source configuration: %w", err)
+	}
+	if err = json.Unmarshal(jsonConfig, &conf); err != nil {
+		return nil, fmt.Errorf("error while unmarshalling source configuration: %w", err)
+	}
+	if conf.ConsumerGroup == "" {
+		conf.ConsumerGroup = "rudder-" + source.ID
+	}
+	if conf.Format == "" {
+		conf.Format = jsonFormat
+	}
+	if err = conf.validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	return &conf, nil
+}
+
+func (c *sourceConfig) validate() error {
+	if len(c.topics()) == 0 {
+		return fmt.Errorf("topics cannot be empty")
+	}
+	if c.HostName == "" {
+		return fmt.Errorf("hostname cannot be empty")
+	}
+	port, err := strconv.Atoi(c.Port)
+	if err != nil {
+		return fmt.Errorf("invalid port: %w", err)
+	}
+	if port < 1 {
+		return fmt.Errorf("invalid port: %d", port)
+	}
+	switch c.Format {
+	case jsonFormat:
+	case schemaRegistryFormat:
+		if c.SchemaRegistryURL == "" {
+			return fmt.Errorf("schema registry url cannot be empty")
+		}
+	default:
+		return fmt.Errorf("unknown format: %q", c.Format)
+	}
+	return nil
+}
+
+func (c *sourceConfig) topics() []string {
+	var topics []string
+	for _, topic := range strings.Split(c.Topics, ",") {
+		if topic = strings.TrimSpace(topic); topic != "" {
+			topics = append(topics, topic)
+		}
+	}
+	return topics
+}
+
+func (c *sourceConfig) addresses() []string {
+	hostNames := strings.Split(c.HostName, ",")
+	addresses := make([]string, len(hostNames))
+	for i, hostName := range hostNames {
+		addresses[i] = strings.TrimSpace(hostName) + ":" + c.Port
+	}
+	return addresses
+}
+
+func (c *sourceConfig) clientConfig() (client.Config, error) {
+	clientConf := client.Config{DialTimeout: dialTimeout}
+	if !c.SslEnabled {
+		return clientConf, nil
+	}
+	if c.CACertificate != "" {
+		clientConf.TLS = &client.TLS{CACertificate: []byte(c.CACertificate)}
+	} else {
+		clientConf.TLS = &client.TLS{WithSystemCertPool: true}
+	}
+	if c.UseSASL { // SASL is enabled only with SSL
+		scramHashGen, err := client.ScramHashGeneratorFromString(c.SaslType)
+		if err != nil {
+			return clientConf, fmt.Errorf("invalid SASL type: %w", err)
+		}
+		clientConf.SASL = &client.SASL{ScramHashGen: scramHashGen, Username: c.Username, Password: c.Password}
+	}
+	return clientConf, nil
+}
+
+type fieldDefault struct {
+	path  string
+	value interface{}
+}
+
+// toRudderEvent converts the decoded payload of a message into a Rudder event.
+// Payloads that already are Rudder events are kept as they are, any other payload becomes the properties of a track
+// event. The message id is derived from the position of the message, so that messages consumed more than once
+// produce events with the same message id.
+func (c *sourceConfig) toRudderEvent(msg *client.Message, payload []byte) ([]byte, error) {
+	parsed := gjson.ParseBytes(payload)
+	if !parsed.IsObject() {
+		return nil, fmt.Errorf("message is not a JSON object")
+	}
+
+	event := payload
+	var err error
+	if _, ok := rudderEventTypes[parsed.Get("type").String()]; !ok {
+		eventName := c.EventName
+		if eventName == "" {
+			eventName = msg.Topic
+		}
+		event = []byte(`{"type":"track"}`)
+		if event, err = sjson.SetBytes(event, "event", eventName); err != nil {
+			return nil, err
+		}
+		if event, err = sjson.SetRawBytes(event, "properties", payload); err != nil {
+			return nil, err
+		}
+	}
+
+	messageID := msg.Topic + "-" + strconv.Itoa(int(msg.Partition)) + "-" + strconv.FormatInt(msg.Offset, 10)
+	defaults := []fieldDefault{
+		{"messageId", messageID},
+		{"originalTimestamp", msg.Timestamp.UTC().Format(time.RFC3339Nano)},
+	}
+	if !parsed.Get("userId").Exists() && !parsed.Get("anonymousId").Exists() {
+		anonymousID := messageID
+		if len(msg.Key) > 0 {
+			anonymousID = string(msg.Key)
+		}
+		defaults = append(defaults, fieldDefault{"anonymousId", anonymousID})
+	}
+	for _, d := range defaults {
+		if gjson.GetBytes(event, d.path).Exists() {
+			continue
+		}
+		if event, err = sjson.SetBytes(event, d.path, d.value); err != nil {
+			return nil, err
+		}
+	}
+	return sjson.SetBytes(event, "context.kafka", map[string]interface{}{
+		"topic":     msg.Topic,
+		"partition": msg.Partition,
+		"offset":    msg.Offset,
+	})
+}
@@ -0,0 +1,491 @@
+package kafkasource
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+
+	backendconfig "github.com/rudderlabs/rudder-server/config/backend-config"
+	"github.com/rudderlabs/rudder-server/gateway/response"
+	"github.com/rudderlabs/rudder-server/services/stats"
+	"github.com/rudderlabs/rudder-server/services/streammanager/kafka/client"
+	"github.com/rudderlabs/rudder-server/services/streammanager/kafka/schemaregistry"
+	"github.com/rudderlabs/rudder-server/utils/logger"
+	"github.com/rudderlabs/rudder-server/utils/misc"
+)
+
+var (
+	maxBatchSize        int
+	maxBatchSizeInBytes int
+	maxBatchWait        time.Duration
+	dialTimeout         time.Duration
+	maxRetryInterval    time.Duration
+	shutdownTimeout     time.Duration
+	pkgLogger           logger.Logger
+)
+
+func Init() {
+	loadConfig()
+	pkgLogger = logger.NewLogger().Child("gateway").Child("kafkasource")
+}
+
+// GatewayI is the subset of the gateway used to store the events consumed from Kafka
+type GatewayI interface {
+	IncrementRecvCount(count uint64)
+	IncrementAckCount(count uint64)
+	TrackRequestMetrics(errorMessage string)
+	ProcessWebRequest(writer *http.ResponseWriter, req *http.Request, reqType string, requestPayload []byte, writeKey string) string
+	MaxReqSize() int
+}
+
+// permanentStoreErrors are the errors of the gateway rejecting a request whatever the number of times it is retried.
+// Invalid write keys and disabled sources are not permanent: the write keys of the gateway are updated before the
+// consumers of the sources, thus a batch being stored while the configuration changes is retried until either the
+// gateway accepts it or its source is stopped, leaving its offsets uncommitted.
+var permanentStoreErrors = []string{response.RequestBodyTooLarge}
+
+// rejectedError is returned when the gateway rejects the events permanently, in which case they are skipped
+type rejectedError struct {
+	message string
+}
+
+func (e *rejectedError) Error() string {
+	return e.message
+}
+
+type consumer interface {
+	Fetch(ctx context.Context) (client.Message, error)
+	Commit(ctx context.Context, msgs ...client.Message) error
+	Close(ctx context.Context) error
+}
+
+type decoder func(ctx context.Context, value []byte) ([]byte, error)
+
+// HandleT runs the consumers of the enabled Kafka sources. The consumed messages are stored in the gateway in
+// batches and their offsets are committed only once the batch has been stored, so that no message is lost.
+type HandleT struct {
+	gwHandle    GatewayI
+	logger      logger.Logger
+	newConsumer func(conf *sourceConfig, topic string) (consumer, error)
+
+	mu      sync.Mutex
+	ctx     context.Context
+	cancel  context.CancelFunc
+	sources map[string]*sourceConsumers // keyed by source id
+}
+
+// sourceConsumers are the consumers of a source, one per topic
+type sourceConsumers struct {
+	source backendconfig.SourceT
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// Setup returns a handle consuming the Kafka sources provided through Sync
+func Setup(gwHandle GatewayI) *HandleT {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &HandleT{
+		gwHandle:    gwHandle,
+		logger:      pkgLogger,
+		newConsumer: newKafkaConsumer,
+		ctx:         ctx,
+		cancel:      cancel,
+		sources:     make(map[string]*sourceConsumers),
+	}
+}
+
+// Shutdown stops all consumers waiting for the batches being stored to be committed
+func (h *HandleT) Shutdown() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.cancel()
+	for sourceID := range h.sources {
+		h.stopSource(sourceID)
+	}
+	return nil
+}
+
+// Sync starts the consumers of new sources, stops the ones of removed sources and restarts the ones of
+// sources whose configuration has changed. Sources are expected to be the enabled sources of type SourceType.
+func (h *HandleT) Sync(sources []backendconfig.SourceT) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.ctx.Err() != nil {
+		return // shut down
+	}
+	sourcesByID := make(map[string]backendconfig.SourceT, len(sources))
+	for i := range sources {
+		sourcesByID[sources[i].ID] = sources[i]
+	}
+	for sourceID, running := range h.sources {
+		source, ok := sourcesByID[sourceID]
+		if ok && source.WriteKey == running.source.WriteKey && reflect.DeepEqual(source.Config, running.source.Config) {
+			continue
+		}
+		h.logger.Infof("Stopping Kafka source %s", sourceID)
+		h.stopSource(sourceID)
+	}
+	for sourceID, source := range sourcesByID {
+		if _, ok := h.sources[sourceID]; ok {
+			continue
+		}
+		if err := h.startSource(h.ctx, source); err != nil {
+			h.logger.Errorf("Could not start Kafka source %s: %v", sourceID, err)
+		}
+	}
+}
+
+func (h *HandleT) startSource(ctx context.Context, source backendconfig.SourceT) error { // skipcq: CRT-P0003
+	conf, err := parseSourceConfig(&source)
+	if err != nil {
+		return err
+	}
+	decode, err := newDecoder(conf)
+	if err != nil {
+		return err
+	}
+
+	h.logger.Infof("Starting Kafka source %s consuming topics %v", source.ID, conf.topics())
+	ctx, cancel := context.WithCancel(ctx)
+	sc := &sourceConsumers{source: source, cancel: cancel}
+	for _, topic := range conf.topics() {
+		w := &worker{
+			handle:   h,
+			source:   source,
+			conf:     conf,
+			topic:    topic,
+			decode:   decode,
+			stats:    newWorkerStats(source.ID, topic),
+			sleepFor: misc.SleepCtx,
+		}
+		sc.wg.Add(1)
+		go misc.WithBugsnag(func() error {
+			defer sc.wg.Done()
+			w.run(ctx)
+			return nil
+		})()
+	}
+	h.sources[source.ID] = sc
+	return nil
+}
+
+func (h *HandleT) stopSource(sourceID string) {
+	sc := h.sources[sourceID]
+	sc.cancel()
+	sc.wg.Wait()
+	delete(h.sources, sourceID)
+}
+
+func newKafkaConsumer(conf *sourceConfig, topic string) (consumer, error) {
+	clientConf, err := conf.clientConfig()
+	if err != nil {
+		return nil, err
+	}
+	c, err := client.New("tcp", conf.addresses(), clientConf)
+	if err != nil {
+		return nil, fmt.Errorf("could not create client: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+	defer cancel()
+	if err = c.Ping(ctx); err != nil {
+		return nil, fmt.Errorf("could not ping: %w", err)
+	}
+	startOffset := client.LastOffset
+	if conf.StartFromBeginning {
+		startOffset = client.FirstOffset
+	}
+	return c.NewConsumer(topic, client.ConsumerConfig{
+		GroupID:     conf.ConsumerGroup,
+		StartOffset: startOffset,
+	}), nil
+}
+
+func newDecoder(conf *sourceConfig) (decoder, error) {
+	if conf.Format != schemaRegistryFormat {
+		return func(_ context.Context, value []byte) ([]byte, error) { return value, nil }, nil
+	}
+	registryClient, err := schemaregistry.NewClient(schemaregistry.Config{
+		URL:      conf.SchemaRegistryURL,
+		Username: conf.SchemaRegistryUsername,
+		Password: conf.SchemaRegistryPassword,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema registry configuration: %w", err)
+	}
+	deserializer, err := schemaregistry.NewDeserializer(registryClient)
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context, value []byte) ([]byte, error) {
+		return deserializer.Deserialize(ctx, value)
+	}, nil
+}
+
+type workerStats struct {
+	received       stats.Measurement
+	decodeErrors   stats.Measurement
+	stored         stats.Measurement
+	storeErrors    stats.Measurement
+	dropped        stats.Measurement
+	commitErrors   stats.Measurement
+	batchStoreTime stats.Measurement
+}
+
+func newWorkerStats(sourceID, topic string) *workerStats {
+	tags := stats.Tags{"sourceID": sourceID, "topic": topic}
+	return &workerStats{
+		received:       stats.Default.NewTaggedStat("gateway.kafka_source.received", stats.CountType, tags),
+		decodeErrors:   stats.Default.NewTaggedStat("gateway.kafka_source.decode_errors", stats.CountType, tags),
+		stored:         stats.Default.NewTaggedStat("gateway.kafka_source.stored", stats.CountType, tags),
+		storeErrors:    stats.Default.NewTaggedStat("gateway.kafka_source.store_errors", stats.CountType, tags),
+		dropped:        stats.Default.NewTaggedStat("gateway.kafka_source.dropped", stats.CountType, tags),
+		commitErrors:   stats.Default.NewTaggedStat("gateway.kafka_source.commit_errors", stats.CountType, tags),
+		batchStoreTime: stats.Default.NewTaggedStat("gateway.kafka_source.batch_store_time", stats.TimerType, tags),
+	}
+}
+
+// worker consumes a single topic of a source
+type worker struct {
+	handle   *HandleT
+	source   backendconfig.SourceT
+	conf     *sourceConfig
+	topic    string
+	decode   decoder
+	stats    *workerStats
+	sleepFor func(ctx context.Context, d time.Duration) error
+}
+
+func (w *worker) run(ctx context.Context) {
+	var c consumer
+	err := w.retry(ctx, "creating consumer", func() (err error) {
+		c, err = w.handle.newConsumer(w.conf, w.topic)
+		return err
+	})
+	if err != nil {
+		return // context canceled
+	}
+	defer func() {
+		closeCtx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+		defer cancel()
+		if err := c.Close(closeCtx); err != nil {
+			w.handle.logger.Warnf("Could not close consumer of Kafka source %s topic %s: %v", w.source.ID, w.topic, err)
+		}
+	}()
+
+	for {
+		batch, err := w.fetchBatch(ctx, c)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			w.handle.logger.Errorf("Could not fetch messages of Kafka source %s topic %s: %v", w.source.ID, w.topic, err)
+			if w.sleepFor(ctx, time.Second) != nil {
+				return
+			}
+			continue
+		}
+		// once fetched, a batch is stored and committed even if the context gets canceled in the meantime, unless
+		// it cannot be within shutdownTimeout. The messages of a batch interrupted after being partly stored are
+		// consumed again, with the same message ids derived from their topic, partition and offset, so that the
+		// events stored twice get deduplicated.
+		finishCtx, cancel := withGracePeriod(ctx, shutdownTimeout)
+		err = w.storeAndCommit(finishCtx, finishCtx, c, batch)
+		cancel()
+		if err != nil {
+			return
+		}
+	}
+}
+
+// withGracePeriod returns a context which gets canceled gracePeriod after ctx, or when the returned cancel func is called
+func withGracePeriod(ctx context.Context, gracePeriod time.Duration) (context.Context, context.CancelFunc) {
+	graceCtx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-graceCtx.Done():
+			return
+		}
+		select {
+		case <-time.After(gracePeriod):
+			cancel()
+		case <-graceCtx.Done():
+		}
+	}()
+	return graceCtx, cancel
+}
+
+// fetchBatch fetches messages until either maxBatchSize messages or maxBatchSizeInBytes bytes are fetched or
+// maxBatchWait has elapsed since the first message has been fetched
+func (w *worker) fetchBatch(ctx context.Context, c consumer) ([]client.Message, error) {
+	msg, err := c.Fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	batch := []client.Message{msg}
+	size := len(msg.Value)
+
+	batchCtx, cancel := context.WithTimeout(ctx, maxBatchWait)
+	defer cancel()
+	for len(batch) < maxBatchSize && size < maxBatchSizeInBytes {
+		msg, err = c.Fetch(batchCtx)
+		if err != nil {
+			if ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+				break
+			}
+			return nil, err
+		}
+		batch = append(batch, msg)
+		size += len(msg.Value)
+	}
+	w.stats.received.Count(len(batch))
+	return batch, nil
+}
+
+// storeAndCommit stores the events of the batch in the gateway and commits the offsets of its messages, retrying
+// until it succeeds. An error is returned only if retrying is interrupted by the cancellation of retryCtx.
+// Events rejected permanently by the gateway are skipped, so that they do not block the partition forever.
+func (w *worker) storeAndCommit(ctx, retryCtx context.Context, c consumer, batch []client.Message) error {
+	var payloads []batchPayload
+	err := w.retry(retryCtx, "decoding messages", func() (err error) {
+		payloads, err = w.toBatchPayloads(ctx, batch)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, p := range payloads {
+		err = w.retry(retryCtx, "storing events", func() error {
+			return w.store(p.payload, p.events)
+		})
+		var rejected *rejectedError
+		if errors.As(err, &rejected) {
+			w.stats.dropped.Count(p.events)
+			w.handle.logger.Errorf(
+				"Skipping %d events of Kafka source %s topic %s rejected by the gateway: %v", p.events, w.source.ID, w.topic, err,
+			)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return w.retry(retryCtx, "committing offsets", func() error {
+		if err := c.Commit(ctx, batch...); err != nil {
+			w.stats.commitErrors.Increment()
+			return err
+		}
+		return nil
+	})
+}
+
+// batchPayload is a batch request payload along with the number of events it contains
+type batchPayload struct {
+	payload []byte
+	events  int
+}
+
+// toBatchPayloads converts the messages into batch request payloads no larger than the maximum request size of the
+// gateway. Messages that cannot be decoded into events or that are larger than a request are skipped, while an error
+// is returned if the schema registry could not be reached.
+func (w *worker) toBatchPayloads(ctx context.Context, batch []client.Message) ([]batchPayload, error) {
+	const (
+		prefix = `{"batch":[`
+		suffix = `]}`
+	)
+	maxReqSize := w.handle.gwHandle.MaxReqSize()
+	var (
+		payloads []batchPayload
+		current  batchPayload
+		payload  = bytes.NewBufferString(prefix)
+	)
+	flush := func() {
+		if current.events == 0 {
+			return
+		}
+		payload.WriteString(suffix)
+		current.payload = payload.Bytes()
+		payloads = append(payloads, current)
+		current = batchPayload{}
+		payload = bytes.NewBufferString(prefix)
+	}
+	for i := range batch {
+		value, err := w.decode(ctx, batch[i].Value)
+		if err != nil && w.conf.Format == schemaRegistryFormat && !errors.Is(err, schemaregistry.ErrInvalidMessage) {
+			return nil, err
+		}
+		if err == nil {
+			value, err = w.conf.toRudderEvent(&batch[i], value)
+		}
+		if err == nil && len(prefix)+len(value)+len(suffix) > maxReqSize {
+			err = fmt.Errorf("event of %d bytes exceeds the maximum request size of %d bytes", len(value), maxReqSize)
+		}
+		if err != nil {
+			w.stats.decodeErrors.Increment()
+			w.handle.logger.Warnf(
+				"Skipping message %s/%d/%d of Kafka source %s: %v",
+				batch[i].Topic, batch[i].Partition, batch[i].Offset, w.source.ID, err,
+			)
+			continue
+		}
+		if current.events > 0 && payload.Len()+1+len(value)+len(suffix) > maxReqSize {
+			flush()
+		}
+		if current.events > 0 {
+			payload.WriteByte(',')
+		}
+		payload.Write(value)
+		current.events++
+	}
+	flush()
+	return payloads, nil
+}
+
+func (w *worker) store(payload []byte, events int) error {
+	start := time.Now()
+	defer w.stats.batchStoreTime.Since(start)
+
+	req, err := http.NewRequest(http.MethodPost, "/v1/batch", http.NoBody)
+	if err != nil {
+		return err
+	}
+	// events of the same topic are handled by the same gateway worker, preserving their order
+	req.Header.Set("AnonymousId", w.source.ID+"-"+w.topic)
+
+	w.handle.gwHandle.IncrementRecvCount(1)
+	var rw http.ResponseWriter
+	errorMessage := w.handle.gwHandle.ProcessWebRequest(&rw, req, "batch", payload, w.source.WriteKey)
+	w.handle.gwHandle.IncrementAckCount(1)
+	w.handle.gwHandle.TrackRequestMetrics(errorMessage)
+	if errorMessage != "" {
+		w.stats.storeErrors.Increment()
+		for _, permanent := range permanentStoreErrors {
+			if errorMessage == permanent {
+				return backoff.Permanent(&rejectedError{message: errorMessage})
+			}
+		}
+		return errors.New(errorMessage)
+	}
+	w.stats.stored.Count(events)
+	return nil
+}
+
+// retry retries the operation with an exponential backoff until it succeeds or the context is canceled
+func (w *worker) retry(ctx context.Context, operation string, f func() error) error {
+	b := backoff.NewExponentialBackOff()
+	b.MaxInterval = maxRetryInterval
+	b.MaxElapsedTime = 0
+	return backoff.RetryNotify(f, backoff.WithContext(b, ctx), func(err error, d time.Duration) {
+		w.handle.logger.Errorf(
+			"Kafka source %s topic %s: error while %s, retrying in %s: %v", w.source.ID, w.topic, operation, d, err,
+		)
+	})
+}
@@ -0,0 +1,402 @@
+package kafkasource
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/rudderlabs/rudder-server/config"
+	backendconfig "github.com/rudderlabs/rudder-server/config/backend-config"
+	"github.com/rudderlabs/rudder-server/gateway/response"
+	"github.com/rudderlabs/rudder-server/services/streammanager/kafka/client"
+	"github.com/rudderlabs/rudder-server/services/streammanager/kafka/schemaregistry"
+	"github.com/rudderlabs/rudder-server/utils/logger"
+)
+
+func init() {
+	config.Reset()
+	logger.Reset()
+	Init()
+	maxBatchWait = 50 * time.Millisecond
+	maxRetryInterval = 10 * time.Millisecond
+	shutdownTimeout = 50 * time.Millisecond
+}
+
+func TestParseSourceConfig(t *testing.T) {
+	source := backendconfig.SourceT{ID: "sourceID", Config: map[string]interface{}{
+		"hostName": "host1, host2",
+		"port":     "9092",
+		"topics":   "topic1, ,topic2",
+	}}
+	conf, err := parseSourceConfig(&source)
+	require.NoError(t, err)
+	require.Equal(t, "rudder-sourceID", conf.ConsumerGroup)
+	require.Equal(t, jsonFormat, conf.Format)
+	require.Equal(t, []string{"topic1", "topic2"}, conf.topics())
+	require.Equal(t, []string{"host1:9092", "host2:9092"}, conf.addresses())
+
+	for name, invalid := range map[string]map[string]interface{}{
+		"missing topics":          {"hostName": "host", "port": "9092"},
+		"missing host":            {"port": "9092", "topics": "topic"},
+		"invalid port":            {"hostName": "host", "port": "abc", "topics": "topic"},
+		"unknown format":          {"hostName": "host", "port": "9092", "topics": "topic", "format": "XML"},
+		"missing schema registry": {"hostName": "host", "port": "9092", "topics": "topic", "format": "SCHEMA_REGISTRY"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := parseSourceConfig(&backendconfig.SourceT{ID: "sourceID", Config: invalid})
+			require.ErrorContains(t, err, "invalid configuration")
+		})
+	}
+}
+
+func TestToRudderEvent(t *testing.T) {
+	msg := &client.Message{
+		Topic:     "topic",
+		Partition: 1,
+		Offset:    42,
+		Timestamp: time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+
+	t.Run("plain payload", func(t *testing.T) {
+		conf := &sourceConfig{}
+		event, err := conf.toRudderEvent(msg, []byte(`{"amount":10}`))
+		require.NoError(t, err)
+		require.JSONEq(t, `{
+			"type": "track",
+			"event": "topic",
+			"properties": {"amount": 10},
+			"messageId": "topic-1-42",
+			"anonymousId": "topic-1-42",
+			"originalTimestamp": "2022-01-02T03:04:05Z",
+			"context": {"kafka": {"topic": "topic", "partition": 1, "offset": 42}}
+		}`, string(event))
+	})
+
+	t.Run("plain payload with event name and key", func(t *testing.T) {
+		conf := &sourceConfig{EventName: "Order Completed"}
+		keyed := *msg
+		keyed.Key = []byte("user-key")
+		event, err := conf.toRudderEvent(&keyed, []byte(`{"amount":10}`))
+		require.NoError(t, err)
+		require.Equal(t, "Order Completed", gjson.GetBytes(event, "event").String())
+		require.Equal(t, "user-key", gjson.GetBytes(event, "anonymousId").String())
+	})
+
+	t.Run("rudder event", func(t *testing.T) {
+		conf := &sourceConfig{EventName: "ignored"}
+		event, err := conf.toRudderEvent(msg, []byte(`{"type":"identify","userId":"u1","messageId":"m1","context":{"app":"a"}}`))
+		require.NoError(t, err)
+		require.JSONEq(t, `{
+			"type": "identify",
+			"userId": "u1",
+			"messageId": "m1",
+			"originalTimestamp": "2022-01-02T03:04:05Z",
+			"context": {"app": "a", "kafka": {"topic": "topic", "partition": 1, "offset": 42}}
+		}`, string(event))
+	})
+
+	t.Run("not an object", func(t *testing.T) {
+		_, err := (&sourceConfig{}).toRudderEvent(msg, []byte(`[1,2]`))
+		require.Error(t, err)
+	})
+}
+
+func TestWorker(t *testing.T) {
+	t.Run("stores batches and commits them afterwards", func(t *testing.T) {
+		gw := &gatewayMock{}
+		c := newConsumerMock(
+			message(0, `{"a":1}`),
+			message(1, `not json`),
+			message(2, `{"type":"track","event":"e","userId":"u"}`),
+		)
+		h, source := newTestHandle(t, gw, c)
+		h.Sync([]backendconfig.SourceT{source})
+
+		require.Eventually(t, func() bool { return len(c.committedOffsets()) == 3 }, time.Second, time.Millisecond)
+		require.NoError(t, h.Shutdown())
+
+		requests := gw.storedRequests()
+		require.Len(t, requests, 1)
+		require.Equal(t, "writeKey", requests[0].writeKey)
+		require.Equal(t, "sourceID-topic", requests[0].anonymousIDHeader)
+		events := gjson.GetBytes(requests[0].payload, "batch").Array()
+		require.Len(t, events, 2, "undecodable messages should be skipped")
+		require.Equal(t, "topic-0-0", events[0].Get("messageId").String())
+		require.Equal(t, "topic-0-2", events[1].Get("messageId").String())
+		require.True(t, c.closed())
+	})
+
+	t.Run("retries storing before committing", func(t *testing.T) {
+		gw := &gatewayMock{failures: 2}
+		c := newConsumerMock(message(0, `{"a":1}`))
+		h, source := newTestHandle(t, gw, c)
+		h.Sync([]backendconfig.SourceT{source})
+
+		require.Eventually(t, func() bool { return len(c.committedOffsets()) == 1 }, time.Second, time.Millisecond)
+		require.NoError(t, h.Shutdown())
+		require.Len(t, gw.storedRequests(), 3)
+	})
+
+	t.Run("does not commit if the batch could not be stored", func(t *testing.T) {
+		gw := &gatewayMock{failures: -1}
+		c := newConsumerMock(message(0, `{"a":1}`))
+		h, source := newTestHandle(t, gw, c)
+		h.Sync([]backendconfig.SourceT{source})
+
+		require.Eventually(t, func() bool { return len(gw.storedRequests()) > 1 }, time.Second, time.Millisecond)
+		require.NoError(t, h.Shutdown())
+		require.Empty(t, c.committedOffsets())
+	})
+
+	t.Run("skips events rejected permanently and commits them", func(t *testing.T) {
+		gw := &gatewayMock{failures: 1, failure: response.RequestBodyTooLarge}
+		c := newConsumerMock(message(0, `{"a":1}`))
+		h, source := newTestHandle(t, gw, c)
+		h.Sync([]backendconfig.SourceT{source})
+
+		require.Eventually(t, func() bool { return len(c.committedOffsets()) == 1 }, time.Second, time.Millisecond)
+		require.NoError(t, h.Shutdown())
+		require.Len(t, gw.storedRequests(), 1, "permanent rejections should not be retried")
+	})
+
+	t.Run("retries events of disabled sources without committing them", func(t *testing.T) {
+		for _, failure := range []string{response.SourceDisabled, response.InvalidWriteKey} {
+			gw := &gatewayMock{failures: -1, failure: failure}
+			c := newConsumerMock(message(0, `{"a":1}`))
+			h, source := newTestHandle(t, gw, c)
+			h.Sync([]backendconfig.SourceT{source})
+
+			require.Eventually(t, func() bool { return len(gw.storedRequests()) > 1 }, time.Second, time.Millisecond, failure)
+			h.Sync(nil)
+			require.Empty(t, c.committedOffsets(), "the batch should be consumed again once the source is enabled")
+		}
+	})
+
+	t.Run("finishes storing the batch on shutdown", func(t *testing.T) {
+		defer func(timeout time.Duration) { shutdownTimeout = timeout }(shutdownTimeout)
+		shutdownTimeout = 10 * time.Second
+		gw := &gatewayMock{failures: 3}
+		c := newConsumerMock(message(0, `{"a":1}`))
+		h, source := newTestHandle(t, gw, c)
+		h.Sync([]backendconfig.SourceT{source})
+
+		require.Eventually(t, func() bool { return len(gw.storedRequests()) > 0 }, time.Second, time.Millisecond)
+		require.NoError(t, h.Shutdown())
+		require.Equal(t, []int64{0}, c.committedOffsets())
+		require.Len(t, gw.storedRequests(), 4)
+	})
+
+	t.Run("splits batches larger than a gateway request", func(t *testing.T) {
+		msg := message(0, `{"a":1}`)
+		event, err := (&sourceConfig{}).toRudderEvent(&msg, msg.Value)
+		require.NoError(t, err)
+		// room for two events of about the same size per request
+		gw := &gatewayMock{maxReqSize: len(`{"batch":[]}`) + 2*len(event) + 16}
+		c := newConsumerMock(
+			message(0, `{"a":1}`),
+			message(1, `{"a":2}`),
+			message(2, `{"a":3}`),
+			message(3, `{"a":"`+strings.Repeat("x", gw.maxReqSize)+`"}`),
+		)
+		h, source := newTestHandle(t, gw, c)
+		h.Sync([]backendconfig.SourceT{source})
+
+		require.Eventually(t, func() bool { return len(c.committedOffsets()) == 4 }, time.Second, time.Millisecond)
+		require.NoError(t, h.Shutdown())
+
+		requests := gw.storedRequests()
+		require.Len(t, requests, 2)
+		for _, request := range requests {
+			require.LessOrEqual(t, len(request.payload), gw.maxReqSize)
+		}
+		require.Len(t, gjson.GetBytes(requests[0].payload, "batch").Array(), 2)
+		require.Len(t, gjson.GetBytes(requests[1].payload, "batch").Array(), 1, "events larger than a request should be skipped")
+	})
+
+	t.Run("retries decoding while the schema registry is unavailable", func(t *testing.T) {
+		gw := &gatewayMock{}
+		c := newConsumerMock(message(0, `registry`), message(1, `invalid`))
+		h, source := newTestHandle(t, gw, c)
+		var attempts int
+		decode := func(_ context.Context, value []byte) ([]byte, error) {
+			if string(value) == "invalid" {
+				return nil, schemaregistry.ErrInvalidMessage
+			}
+			if attempts++; attempts < 3 {
+				return nil, errors.New("registry unavailable")
+			}
+			return []byte(`{"a":1}`), nil
+		}
+		w := &worker{
+			handle:   h,
+			source:   source,
+			conf:     &sourceConfig{Format: schemaRegistryFormat},
+			topic:    "topic",
+			decode:   decode,
+			stats:    newWorkerStats(source.ID, "topic"),
+			sleepFor: func(context.Context, time.Duration) error { return nil },
+		}
+		ctx := context.Background()
+		batch, err := w.fetchBatch(ctx, c)
+		require.NoError(t, err)
+		require.NoError(t, w.storeAndCommit(ctx, ctx, c, batch))
+		require.Equal(t, 3, attempts)
+		require.Len(t, gw.storedRequests(), 1)
+		require.Len(t, gjson.GetBytes(gw.storedRequests()[0].payload, "batch").Array(), 1)
+		require.Equal(t, []int64{0, 1}, c.committedOffsets())
+	})
+
+	t.Run("restarts consumers when the configuration changes", func(t *testing.T) {
+		gw := &gatewayMock{}
+		c := newConsumerMock()
+		h, source := newTestHandle(t, gw, c)
+		var created []string
+		var mu sync.Mutex
+		h.newConsumer = func(conf *sourceConfig, topic string) (consumer, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			created = append(created, topic)
+			return newConsumerMock(), nil
+		}
+		h.Sync([]backendconfig.SourceT{source})
+		h.Sync([]backendconfig.SourceT{source})
+		source.Config = map[string]interface{}{"hostName": "host", "port": "9092", "topics": "topic,other"}
+		h.Sync([]backendconfig.SourceT{source})
+		require.Eventually(t, func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+			return len(created) == 3
+		}, time.Second, time.Millisecond)
+
+		h.Sync(nil)
+		require.Empty(t, h.sources)
+		require.NoError(t, h.Shutdown())
+		h.Sync([]backendconfig.SourceT{source})
+		require.Empty(t, h.sources, "no source should be started after shutdown")
+	})
+}
+
+func newTestHandle(t *testing.T, gw GatewayI, c consumer) (*HandleT, backendconfig.SourceT) {
+	t.Helper()
+	h := Setup(gw)
+	h.newConsumer = func(*sourceConfig, string) (consumer, error) { return c, nil }
+	t.Cleanup(func() { _ = h.Shutdown() })
+	return h, backendconfig.SourceT{
+		ID:       "sourceID",
+		WriteKey: "writeKey",
+		Enabled:  true,
+		Config:   map[string]interface{}{"hostName": "host", "port": "9092", "topics": "topic"},
+	}
+}
+
+func message(offset int64, value string) client.Message {
+	return client.Message{Topic: "topic", Offset: offset, Value: []byte(value), Timestamp: time.Now()}
+}
+
+type storedRequest struct {
+	payload           []byte
+	writeKey          string
+	anonymousIDHeader string
+}
+
+type gatewayMock struct {
+	mu         sync.Mutex
+	failures   int    // number of requests failing, -1 for all of them
+	failure    string // error message of the failing requests
+	maxReqSize int
+	requests   []storedRequest
+}
+
+func (*gatewayMock) IncrementRecvCount(uint64)  {}
+func (*gatewayMock) IncrementAckCount(uint64)   {}
+func (*gatewayMock) TrackRequestMetrics(string) {}
+
+func (g *gatewayMock) MaxReqSize() int {
+	if g.maxReqSize == 0 {
+		return 4000 * 1024
+	}
+	return g.maxReqSize
+}
+
+func (g *gatewayMock) ProcessWebRequest(_ *http.ResponseWriter, req *http.Request, _ string, payload []byte, writeKey string) string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.requests = append(g.requests, storedRequest{
+		payload:           payload,
+		writeKey:          writeKey,
+		anonymousIDHeader: req.Header.Get("AnonymousId"),
+	})
+	if g.failures != 0 {
+		g.failures--
+		if g.failure != "" {
+			return g.failure
+		}
+		return "store failed"
+	}
+	return ""
+}
+
+func (g *gatewayMock) storedRequests() []storedRequest {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]storedRequest(nil), g.requests...)
+}
+
+type consumerMock struct {
+	messages chan client.Message
+	mu       sync.Mutex
+	commits  []int64
+	isClosed bool
+}
+
+func newConsumerMock(msgs ...client.Message) *consumerMock {
+	c := &consumerMock{messages: make(chan client.Message, len(msgs))}
+	for _, msg := range msgs {
+		c.messages <- msg
+	}
+	return c
+}
+
+func (c *consumerMock) Fetch(ctx context.Context) (client.Message, error) {
+	select {
+	case msg := <-c.messages:
+		return msg, nil
+	case <-ctx.Done():
+		return client.Message{}, ctx.Err()
+	}
+}
+
+func (c *consumerMock) Commit(_ context.Context, msgs ...client.Message) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, msg := range msgs {
+		c.commits = append(c.commits, msg.Offset)
+	}
+	return nil
+}
+
+func (c *consumerMock) Close(context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.isClosed = true
+	return nil
+}
+
+func (c *consumerMock) committedOffsets() []int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]int64(nil), c.commits...)
+}
+
+func (c *consumerMock) closed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.isClosed
+}
@@ -28,6 +28,7 @@ import (
 	backendconfig "github.com/rudderlabs/rudder-server/config/backend-config"
 	eventschema "github.com/rudderlabs/rudder-server/event-schema"
 	"github.com/rudderlabs/rudder-server/gateway"
+	"github.com/rudderlabs/rudder-server/gateway/kafkasource"
 	"github.com/rudderlabs/rudder-server/gateway/webhook"
 	"github.com/rudderlabs/rudder-server/jobsdb"
 	"github.com/rudderlabs/rudder-server/processor"
@@ -68,6 +69,7 @@ import (
 	"github.com/rudderlabs/rudder-server/warehouse/postgres"
 	"github.com/rudderlabs/rudder-server/warehouse/redshift"
 	"github.com/rudderlabs/rudder-server/warehouse/snowflake"
+	"github.com/rudderlabs/rudder-server/warehouse/sqlite"
 	warehouseutils "github.com/rudderlabs/rudder-server/warehouse/utils"
 	"github.com/rudderlabs/rudder-server/warehouse/validations"
 )
@@ -168,8 +170,10 @@ func runAllInit() {
 	redshift.Init()
 	snowflake.Init()
 	deltalake.Init()
+	sqlite.Init()
 	transformer.Init()
 	webhook.Init()
+	kafkasource.Init()
 	batchrouter.Init()
 	batchrouter.Init2()
 	asyncdestinationmanager.Init()
@@ -0,0 +1,55 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/rudderlabs/rudder-server/services/streammanager/jetstream (interfaces: JetStreamClient)
+
+// Package mock_jetstream is a generated GoMock package.
+package mock_jetstream
+
+import (
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	nats "github.com/nats-io/nats.go"
+)
+
+// MockJetStreamClient is a mock of JetStreamClient interface.
+type MockJetStreamClient struct {
+	ctrl     *gomock.Controller
+	recorder *MockJetStreamClientMockRecorder
+}
+
+// MockJetStreamClientMockRecorder is the mock recorder for MockJetStreamClient.
+type MockJetStreamClientMockRecorder struct {
+	mock *MockJetStreamClient
+}
+
+// NewMockJetStreamClient creates a new mock instance.
+func NewMockJetStreamClient(ctrl *gomock.Controller) *MockJetStreamClient {
+	mock := &MockJetStreamClient{ctrl: ctrl}
+	mock.recorder = &MockJetStreamClientMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockJetStreamClient) EXPECT() *MockJetStreamClientMockRecorder {
+	return m.recorder
+}
+
+// PublishMsg mocks base method.
+func (m *MockJetStreamClient) PublishMsg(arg0 *nats.Msg, arg1 ...nats.PubOpt) (*nats.PubAck, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{arg0}
+	for _, a := range arg1 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "PublishMsg", varargs...)
+	ret0, _ := ret[0].(*nats.PubAck)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PublishMsg indicates an expected call of PublishMsg.
+func (mr *MockJetStreamClientMockRecorder) PublishMsg(arg0 interface{}, arg1 ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{arg0}, arg1...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PublishMsg", reflect.TypeOf((*MockJetStreamClient)(nil).PublishMsg), varargs...)
+}
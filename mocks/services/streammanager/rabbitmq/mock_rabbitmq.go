@@ -0,0 +1,64 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/rudderlabs/rudder-server/services/streammanager/rabbitmq (interfaces: RabbitMQClient)
+
+// Package mock_rabbitmq is a generated GoMock package.
+package mock_rabbitmq
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	amqp091 "github.com/rabbitmq/amqp091-go"
+)
+
+// MockRabbitMQClient is a mock of RabbitMQClient interface.
+type MockRabbitMQClient struct {
+	ctrl     *gomock.Controller
+	recorder *MockRabbitMQClientMockRecorder
+}
+
+// MockRabbitMQClientMockRecorder is the mock recorder for MockRabbitMQClient.
+type MockRabbitMQClientMockRecorder struct {
+	mock *MockRabbitMQClient
+}
+
+// NewMockRabbitMQClient creates a new mock instance.
+func NewMockRabbitMQClient(ctrl *gomock.Controller) *MockRabbitMQClient {
+	mock := &MockRabbitMQClient{ctrl: ctrl}
+	mock.recorder = &MockRabbitMQClientMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockRabbitMQClient) EXPECT() *MockRabbitMQClientMockRecorder {
+	return m.recorder
+}
+
+// Close mocks base method.
+func (m *MockRabbitMQClient) Close() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Close")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Close indicates an expected call of Close.
+func (mr *MockRabbitMQClientMockRecorder) Close() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockRabbitMQClient)(nil).Close))
+}
+
+// Publish mocks base method.
+func (m *MockRabbitMQClient) Publish(arg0 context.Context, arg1, arg2 string, arg3 bool, arg4 amqp091.Publishing) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Publish", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Publish indicates an expected call of Publish.
+func (mr *MockRabbitMQClientMockRecorder) Publish(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Publish", reflect.TypeOf((*MockRabbitMQClient)(nil).Publish), arg0, arg1, arg2, arg3, arg4)
+}
@@ -0,0 +1,63 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/rudderlabs/rudder-server/services/streammanager/redisstreams (interfaces: RedisStreamsClient)
+
+// Package mock_redisstreams is a generated GoMock package.
+package mock_redisstreams
+
+import (
+	reflect "reflect"
+
+	redis "github.com/go-redis/redis"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockRedisStreamsClient is a mock of RedisStreamsClient interface.
+type MockRedisStreamsClient struct {
+	ctrl     *gomock.Controller
+	recorder *MockRedisStreamsClientMockRecorder
+}
+
+// MockRedisStreamsClientMockRecorder is the mock recorder for MockRedisStreamsClient.
+type MockRedisStreamsClientMockRecorder struct {
+	mock *MockRedisStreamsClient
+}
+
+// NewMockRedisStreamsClient creates a new mock instance.
+func NewMockRedisStreamsClient(ctrl *gomock.Controller) *MockRedisStreamsClient {
+	mock := &MockRedisStreamsClient{ctrl: ctrl}
+	mock.recorder = &MockRedisStreamsClientMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockRedisStreamsClient) EXPECT() *MockRedisStreamsClientMockRecorder {
+	return m.recorder
+}
+
+// Close mocks base method.
+func (m *MockRedisStreamsClient) Close() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Close")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Close indicates an expected call of Close.
+func (mr *MockRedisStreamsClientMockRecorder) Close() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockRedisStreamsClient)(nil).Close))
+}
+
+// XAdd mocks base method.
+func (m *MockRedisStreamsClient) XAdd(arg0 *redis.XAddArgs) *redis.StringCmd {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "XAdd", arg0)
+	ret0, _ := ret[0].(*redis.StringCmd)
+	return ret0
+}
+
+// XAdd indicates an expected call of XAdd.
+func (mr *MockRedisStreamsClientMockRecorder) XAdd(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "XAdd", reflect.TypeOf((*MockRedisStreamsClient)(nil).XAdd), arg0)
+}
@@ -20,6 +20,13 @@ var (
 
 	destTransformURL      string
 	postParametersTFields []string
+
+	// transformerDestTypes maps the destinations the transformer has no transformation of its own to the destination
+	// whose transformation they share. Events for SQLite are transformed like the ones for postgres, since both
+	// use lower case identifiers and the same data types.
+	transformerDestTypes = map[string]string{
+		warehouseutils.SQLITE: warehouseutils.POSTGRES,
+	}
 )
 
 func Init() {
@@ -165,7 +172,11 @@ func GetTransformerURL() string {
 
 // GetDestinationURL returns node URL
 func GetDestinationURL(destType string) string {
-	destinationEndPoint := fmt.Sprintf("%s/v0/%s", destTransformURL, strings.ToLower(destType))
+	transformerDestType := destType
+	if mappedDestType, ok := transformerDestTypes[destType]; ok {
+		transformerDestType = mappedDestType
+	}
+	destinationEndPoint := fmt.Sprintf("%s/v0/%s", destTransformURL, strings.ToLower(transformerDestType))
 	if misc.Contains(warehouseutils.WarehouseDestinations, destType) {
 		whSchemaVersionQueryParam := fmt.Sprintf("whSchemaVersion=%s&whIDResolve=%v", config.GetString("Warehouse.schemaVersion", "v1"), warehouseutils.IDResolutionEnabled())
 		if destType == "RS" {
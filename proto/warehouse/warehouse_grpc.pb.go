@@ -33,6 +33,13 @@ type WarehouseClient interface {
 	RetryWHUploads(ctx context.Context, in *RetryWHUploadsRequest, opts ...grpc.CallOption) (*RetryWHUploadsResponse, error)
 	ValidateObjectStorageDestination(ctx context.Context, in *ValidateObjectStorageRequest, opts ...grpc.CallOption) (*ValidateObjectStorageResponse, error)
 	CountWHUploadsToRetry(ctx context.Context, in *RetryWHUploadsRequest, opts ...grpc.CallOption) (*RetryWHUploadsResponse, error)
+	GetWHSchemaHistory(ctx context.Context, in *WHSchemaHistoryRequest, opts ...grpc.CallOption) (*WHSchemaHistoryResponse, error)
+	GetWHColumnLineage(ctx context.Context, in *WHColumnLineageRequest, opts ...grpc.CallOption) (*WHColumnLineageResponse, error)
+	DryRunWHUpload(ctx context.Context, in *WHDryRunRequest, opts ...grpc.CallOption) (*WHDryRunResponse, error)
+	GetWHSLABreaches(ctx context.Context, in *WHSLABreachesRequest, opts ...grpc.CallOption) (*WHSLABreachesResponse, error)
+	GetWHUsage(ctx context.Context, in *WHUsageRequest, opts ...grpc.CallOption) (*WHUsageResponse, error)
+	GetWHUsageSummary(ctx context.Context, in *WHUsageRequest, opts ...grpc.CallOption) (*WHUsageResponse, error)
+	QueryWH(ctx context.Context, in *WHQueryRequest, opts ...grpc.CallOption) (Warehouse_QueryWHClient, error)
 }
 
 type warehouseClient struct {
@@ -124,6 +131,92 @@ func (c *warehouseClient) CountWHUploadsToRetry(ctx context.Context, in *RetryWH
 	return out, nil
 }
 
+func (c *warehouseClient) GetWHSchemaHistory(ctx context.Context, in *WHSchemaHistoryRequest, opts ...grpc.CallOption) (*WHSchemaHistoryResponse, error) {
+	out := new(WHSchemaHistoryResponse)
+	err := c.cc.Invoke(ctx, "/proto.Warehouse/GetWHSchemaHistory", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *warehouseClient) GetWHColumnLineage(ctx context.Context, in *WHColumnLineageRequest, opts ...grpc.CallOption) (*WHColumnLineageResponse, error) {
+	out := new(WHColumnLineageResponse)
+	err := c.cc.Invoke(ctx, "/proto.Warehouse/GetWHColumnLineage", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *warehouseClient) DryRunWHUpload(ctx context.Context, in *WHDryRunRequest, opts ...grpc.CallOption) (*WHDryRunResponse, error) {
+	out := new(WHDryRunResponse)
+	err := c.cc.Invoke(ctx, "/proto.Warehouse/DryRunWHUpload", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *warehouseClient) GetWHSLABreaches(ctx context.Context, in *WHSLABreachesRequest, opts ...grpc.CallOption) (*WHSLABreachesResponse, error) {
+	out := new(WHSLABreachesResponse)
+	err := c.cc.Invoke(ctx, "/proto.Warehouse/GetWHSLABreaches", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *warehouseClient) GetWHUsage(ctx context.Context, in *WHUsageRequest, opts ...grpc.CallOption) (*WHUsageResponse, error) {
+	out := new(WHUsageResponse)
+	err := c.cc.Invoke(ctx, "/proto.Warehouse/GetWHUsage", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *warehouseClient) GetWHUsageSummary(ctx context.Context, in *WHUsageRequest, opts ...grpc.CallOption) (*WHUsageResponse, error) {
+	out := new(WHUsageResponse)
+	err := c.cc.Invoke(ctx, "/proto.Warehouse/GetWHUsageSummary", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *warehouseClient) QueryWH(ctx context.Context, in *WHQueryRequest, opts ...grpc.CallOption) (Warehouse_QueryWHClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Warehouse_ServiceDesc.Streams[0], "/proto.Warehouse/QueryWH", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &warehouseQueryWHClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Warehouse_QueryWHClient interface {
+	Recv() (*WHQueryResponse, error)
+	grpc.ClientStream
+}
+
+type warehouseQueryWHClient struct {
+	grpc.ClientStream
+}
+
+func (x *warehouseQueryWHClient) Recv() (*WHQueryResponse, error) {
+	m := new(WHQueryResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // WarehouseServer is the server API for Warehouse service.
 // All implementations must embed UnimplementedWarehouseServer
 // for forward compatibility
@@ -137,6 +230,13 @@ type WarehouseServer interface {
 	RetryWHUploads(context.Context, *RetryWHUploadsRequest) (*RetryWHUploadsResponse, error)
 	ValidateObjectStorageDestination(context.Context, *ValidateObjectStorageRequest) (*ValidateObjectStorageResponse, error)
 	CountWHUploadsToRetry(context.Context, *RetryWHUploadsRequest) (*RetryWHUploadsResponse, error)
+	GetWHSchemaHistory(context.Context, *WHSchemaHistoryRequest) (*WHSchemaHistoryResponse, error)
+	GetWHColumnLineage(context.Context, *WHColumnLineageRequest) (*WHColumnLineageResponse, error)
+	DryRunWHUpload(context.Context, *WHDryRunRequest) (*WHDryRunResponse, error)
+	GetWHSLABreaches(context.Context, *WHSLABreachesRequest) (*WHSLABreachesResponse, error)
+	GetWHUsage(context.Context, *WHUsageRequest) (*WHUsageResponse, error)
+	GetWHUsageSummary(context.Context, *WHUsageRequest) (*WHUsageResponse, error)
+	QueryWH(*WHQueryRequest, Warehouse_QueryWHServer) error
 	mustEmbedUnimplementedWarehouseServer()
 }
 
@@ -171,6 +271,27 @@ func (UnimplementedWarehouseServer) ValidateObjectStorageDestination(context.Con
 func (UnimplementedWarehouseServer) CountWHUploadsToRetry(context.Context, *RetryWHUploadsRequest) (*RetryWHUploadsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method CountWHUploadsToRetry not implemented")
 }
+func (UnimplementedWarehouseServer) GetWHSchemaHistory(context.Context, *WHSchemaHistoryRequest) (*WHSchemaHistoryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetWHSchemaHistory not implemented")
+}
+func (UnimplementedWarehouseServer) GetWHColumnLineage(context.Context, *WHColumnLineageRequest) (*WHColumnLineageResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetWHColumnLineage not implemented")
+}
+func (UnimplementedWarehouseServer) DryRunWHUpload(context.Context, *WHDryRunRequest) (*WHDryRunResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DryRunWHUpload not implemented")
+}
+func (UnimplementedWarehouseServer) GetWHSLABreaches(context.Context, *WHSLABreachesRequest) (*WHSLABreachesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetWHSLABreaches not implemented")
+}
+func (UnimplementedWarehouseServer) GetWHUsage(context.Context, *WHUsageRequest) (*WHUsageResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetWHUsage not implemented")
+}
+func (UnimplementedWarehouseServer) GetWHUsageSummary(context.Context, *WHUsageRequest) (*WHUsageResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetWHUsageSummary not implemented")
+}
+func (UnimplementedWarehouseServer) QueryWH(*WHQueryRequest, Warehouse_QueryWHServer) error {
+	return status.Errorf(codes.Unimplemented, "method QueryWH not implemented")
+}
 func (UnimplementedWarehouseServer) mustEmbedUnimplementedWarehouseServer() {}
 
 // UnsafeWarehouseServer may be embedded to opt out of forward compatibility for this service.
@@ -346,6 +467,135 @@ func _Warehouse_CountWHUploadsToRetry_Handler(srv interface{}, ctx context.Conte
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Warehouse_GetWHSchemaHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WHSchemaHistoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WarehouseServer).GetWHSchemaHistory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.Warehouse/GetWHSchemaHistory",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WarehouseServer).GetWHSchemaHistory(ctx, req.(*WHSchemaHistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Warehouse_GetWHColumnLineage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WHColumnLineageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WarehouseServer).GetWHColumnLineage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.Warehouse/GetWHColumnLineage",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WarehouseServer).GetWHColumnLineage(ctx, req.(*WHColumnLineageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Warehouse_DryRunWHUpload_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WHDryRunRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WarehouseServer).DryRunWHUpload(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.Warehouse/DryRunWHUpload",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WarehouseServer).DryRunWHUpload(ctx, req.(*WHDryRunRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Warehouse_GetWHSLABreaches_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WHSLABreachesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WarehouseServer).GetWHSLABreaches(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.Warehouse/GetWHSLABreaches",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WarehouseServer).GetWHSLABreaches(ctx, req.(*WHSLABreachesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Warehouse_GetWHUsage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WHUsageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WarehouseServer).GetWHUsage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.Warehouse/GetWHUsage",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WarehouseServer).GetWHUsage(ctx, req.(*WHUsageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Warehouse_GetWHUsageSummary_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WHUsageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WarehouseServer).GetWHUsageSummary(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.Warehouse/GetWHUsageSummary",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WarehouseServer).GetWHUsageSummary(ctx, req.(*WHUsageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Warehouse_QueryWH_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WHQueryRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(WarehouseServer).QueryWH(m, &warehouseQueryWHServer{stream})
+}
+
+type Warehouse_QueryWHServer interface {
+	Send(*WHQueryResponse) error
+	grpc.ServerStream
+}
+
+type warehouseQueryWHServer struct {
+	grpc.ServerStream
+}
+
+func (x *warehouseQueryWHServer) Send(m *WHQueryResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 // Warehouse_ServiceDesc is the grpc.ServiceDesc for Warehouse service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -389,7 +639,37 @@ var Warehouse_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "CountWHUploadsToRetry",
 			Handler:    _Warehouse_CountWHUploadsToRetry_Handler,
 		},
+		{
+			MethodName: "GetWHSchemaHistory",
+			Handler:    _Warehouse_GetWHSchemaHistory_Handler,
+		},
+		{
+			MethodName: "GetWHColumnLineage",
+			Handler:    _Warehouse_GetWHColumnLineage_Handler,
+		},
+		{
+			MethodName: "DryRunWHUpload",
+			Handler:    _Warehouse_DryRunWHUpload_Handler,
+		},
+		{
+			MethodName: "GetWHSLABreaches",
+			Handler:    _Warehouse_GetWHSLABreaches_Handler,
+		},
+		{
+			MethodName: "GetWHUsage",
+			Handler:    _Warehouse_GetWHUsage_Handler,
+		},
+		{
+			MethodName: "GetWHUsageSummary",
+			Handler:    _Warehouse_GetWHUsageSummary_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "QueryWH",
+			Handler:       _Warehouse_QueryWH_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "proto/warehouse/warehouse.proto",
 }
@@ -1,18 +1,13 @@
 package asyncdestinationmanager
 
 import (
-	"bufio"
 	stdjson "encoding/json"
-	"os"
-	"strings"
 	"sync"
 	"time"
 
 	jsoniter "github.com/json-iterator/go"
 	"github.com/rudderlabs/rudder-server/config"
-	"github.com/rudderlabs/rudder-server/jobsdb"
 	"github.com/rudderlabs/rudder-server/services/rsources"
-	"github.com/rudderlabs/rudder-server/services/stats"
 	"github.com/rudderlabs/rudder-server/utils/logger"
 	"github.com/rudderlabs/rudder-server/utils/misc"
 	"github.com/tidwall/gjson"
@@ -87,12 +82,14 @@ type Parameters struct {
 var json = jsoniter.ConfigCompatibleWithStandardLibrary
 
 var (
-	HTTPTimeout time.Duration
-	pkgLogger   logger.Logger
+	HTTPTimeout    time.Duration
+	transformerURL string
+	pkgLogger      logger.Logger
 )
 
 func loadConfig() {
 	config.RegisterDurationConfigVariable(600, &HTTPTimeout, true, time.Second, "AsyncDestination.HTTPTimeout")
+	transformerURL = config.GetString("DEST_TRANSFORM_URL", "http://localhost:9090")
 }
 
 func Init() {
@@ -124,172 +121,6 @@ func CleanUpData(keyMap map[string]interface{}, importingJobIDs []int64) ([]int6
 	return succesfulJobIDs, failedJobIDsTrans
 }
 
-func Upload(url, filePath string, config map[string]interface{}, destType string, failedJobIDs, importingJobIDs []int64, destinationID string) AsyncUploadOutput {
-	file, err := os.Open(filePath)
-	if err != nil {
-		panic("BRT: Read File Failed" + err.Error())
-	}
-	defer file.Close()
-	var input []AsyncJob
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		var tempJob AsyncJob
-		jobBytes := scanner.Bytes()
-		err := json.Unmarshal(jobBytes, &tempJob)
-		if err != nil {
-			panic("Unmarshalling a Single Line Failed")
-		}
-		input = append(input, tempJob)
-	}
-	var uploadT AsyncUploadT
-	uploadT.Input = input
-	uploadT.Config = config
-	uploadT.DestType = strings.ToLower(destType)
-	payload, err := json.Marshal(uploadT)
-	if err != nil {
-		panic("BRT: JSON Marshal Failed " + err.Error())
-	}
-
-	uploadTimeStat := stats.Default.NewTaggedStat("async_upload_time", stats.TimerType, map[string]string{
-		"module":   "batch_router",
-		"destType": destType,
-	})
-
-	payloadSizeStat := stats.Default.NewTaggedStat("payload_size", stats.TimerType, map[string]string{
-		"module":   "batch_router",
-		"destType": destType,
-	})
-
-	startTime := time.Now()
-	payloadSizeStat.SendTiming(time.Millisecond * time.Duration(len(payload)))
-	pkgLogger.Debugf("[Async Destination Maanger] File Upload Started for Dest Type %v", destType)
-	responseBody, statusCodeHTTP := misc.HTTPCallWithRetryWithTimeout(url, payload, HTTPTimeout)
-	pkgLogger.Debugf("[Async Destination Maanger] File Upload Finished for Dest Type %v", destType)
-	uploadTimeStat.Since(startTime)
-	var bodyBytes []byte
-	var httpFailed bool
-	var statusCode string
-	if statusCodeHTTP != 200 {
-		bodyBytes = []byte(`"error" : "HTTP Call to Transformer Returned Non 200"`)
-		httpFailed = true
-	} else {
-		bodyBytes = responseBody
-		statusCode = gjson.GetBytes(bodyBytes, "statusCode").String()
-	}
-
-	var uploadResponse AsyncUploadOutput
-	if httpFailed {
-		uploadResponse = AsyncUploadOutput{
-			FailedJobIDs:  append(failedJobIDs, importingJobIDs...),
-			FailedReason:  string(bodyBytes),
-			FailedCount:   len(failedJobIDs) + len(importingJobIDs),
-			DestinationID: destinationID,
-		}
-	} else if statusCode == "200" {
-		var responseStruct UploadStruct
-		err := json.Unmarshal(bodyBytes, &responseStruct)
-		if err != nil {
-			panic("Incorrect Response from Transformer: " + err.Error())
-		}
-		var parameters Parameters
-		parameters.ImportId = responseStruct.ImportId
-		parameters.PollUrl = responseStruct.PollUrl
-		metaDataString, ok := responseStruct.Metadata["csvHeader"].(string)
-		if !ok {
-			parameters.MetaData = MetaDataT{CSVHeaders: ""}
-		} else {
-			parameters.MetaData = MetaDataT{CSVHeaders: metaDataString}
-		}
-		importParameters, err := json.Marshal(parameters)
-		if err != nil {
-			panic("Errored in Marshalling" + err.Error())
-		}
-		successfulJobIDs, failedJobIDsTrans := CleanUpData(responseStruct.Metadata, importingJobIDs)
-
-		uploadResponse = AsyncUploadOutput{
-			ImportingJobIDs:     successfulJobIDs,
-			FailedJobIDs:        append(failedJobIDs, failedJobIDsTrans...),
-			FailedReason:        `{"error":"Jobs flowed over the prescribed limit"}`,
-			ImportingParameters: stdjson.RawMessage(importParameters),
-			importingCount:      len(importingJobIDs),
-			FailedCount:         len(failedJobIDs) + len(failedJobIDsTrans),
-			DestinationID:       destinationID,
-		}
-	} else if statusCode == "400" {
-		var responseStruct UploadStruct
-		err := json.Unmarshal(bodyBytes, &responseStruct)
-		if err != nil {
-			panic("Incorrect Response from Transformer: " + err.Error())
-		}
-		eventsAbortedStat := stats.Default.NewTaggedStat("events_delivery_aborted", stats.CountType, map[string]string{
-			"module":   "batch_router",
-			"destType": destType,
-		})
-		abortedJobIDs, failedJobIDsTrans := CleanUpData(responseStruct.Metadata, importingJobIDs)
-		eventsAbortedStat.Count(len(abortedJobIDs))
-		uploadResponse = AsyncUploadOutput{
-			AbortJobIDs:   abortedJobIDs,
-			FailedJobIDs:  append(failedJobIDs, failedJobIDsTrans...),
-			FailedReason:  `{"error":"Jobs flowed over the prescribed limit"}`,
-			AbortReason:   string(bodyBytes),
-			AbortCount:    len(importingJobIDs),
-			FailedCount:   len(failedJobIDs) + len(failedJobIDsTrans),
-			DestinationID: destinationID,
-		}
-	} else {
-		uploadResponse = AsyncUploadOutput{
-			FailedJobIDs:  append(failedJobIDs, importingJobIDs...),
-			FailedReason:  string(bodyBytes),
-			FailedCount:   len(failedJobIDs) + len(importingJobIDs),
-			DestinationID: destinationID,
-		}
-	}
-	return uploadResponse
-}
-
 func GetTransformedData(payload stdjson.RawMessage) string {
 	return gjson.Get(string(payload), "body.JSON").String()
 }
-
-func GetMarshalledData(payload string, jobID int64) string {
-	var job AsyncJob
-	err := json.Unmarshal([]byte(payload), &job.Message)
-	if err != nil {
-		panic("Unmarshalling Transformer Response Failed")
-	}
-	job.Metadata = make(map[string]interface{})
-	job.Metadata["job_id"] = jobID
-	responsePayload, err := json.Marshal(job)
-	if err != nil {
-		panic("Marshalling Response Payload Failed")
-	}
-	return string(responsePayload)
-}
-
-func GenerateFailedPayload(config map[string]interface{}, jobs []*jobsdb.JobT, importID, destType, csvHeaders string) []byte {
-	var failedPayloadT AsyncFailedPayload
-	failedPayloadT.Input = make([]map[string]interface{}, len(jobs))
-	index := 0
-	failedPayloadT.Config = config
-	for _, job := range jobs {
-		failedPayloadT.Input[index] = make(map[string]interface{})
-		var message map[string]interface{}
-		metadata := make(map[string]interface{})
-		err := json.Unmarshal([]byte(GetTransformedData(job.EventPayload)), &message)
-		if err != nil {
-			panic("Unmarshalling Transformer Data to JSON Failed")
-		}
-		metadata["job_id"] = job.JobID
-		failedPayloadT.Input[index]["message"] = message
-		failedPayloadT.Input[index]["metadata"] = metadata
-		index++
-	}
-	failedPayloadT.DestType = strings.ToLower(destType)
-	failedPayloadT.ImportId = importID
-	failedPayloadT.MetaData = MetaDataT{CSVHeaders: csvHeaders}
-	payload, err := json.Marshal(failedPayloadT)
-	if err != nil {
-		panic("JSON Marshal Failed" + err.Error())
-	}
-	return payload
-}
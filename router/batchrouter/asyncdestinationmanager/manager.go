@@ -0,0 +1,87 @@
+package asyncdestinationmanager
+
+import (
+	stdjson "encoding/json"
+	"errors"
+	"fmt"
+
+	backendconfig "github.com/rudderlabs/rudder-server/config/backend-config"
+	"github.com/rudderlabs/rudder-server/jobsdb"
+)
+
+// ErrInvalidFailedRecords is returned by FetchFailedRecords when the destination reported a
+// result for the import that could not be mapped back to job ids. All importing jobs of the
+// import are then marked as failed, so that they are retried.
+var ErrInvalidFailedRecords = errors.New("failed records could not be mapped to job ids")
+
+// AsyncDestinationManager is implemented by every destination which accepts events through a bulk
+// import API. The batch router writes jobs into a file, uploads it once it is full or the upload
+// interval has elapsed and keeps the uploaded jobs in importing state until polling reports that the
+// import has finished.
+type AsyncDestinationManager interface {
+	// Transform converts a batch router job into a single line of the upload file
+	Transform(job *jobsdb.JobT) (string, error)
+	// Upload uploads the file of the async destination struct and reports which jobs are importing,
+	// which have failed and which have been aborted
+	Upload(destination *backendconfig.DestinationT, asyncDestStruct *AsyncDestinationStruct) AsyncUploadOutput
+	// Poll checks the status of the import started by Upload. The importing parameters are the
+	// ones returned by Upload in AsyncUploadOutput.ImportingParameters
+	Poll(pollInput AsyncPoll) (PollStatusResponse, int)
+	// FetchFailedRecords is called for a finished import with failures and maps the outcome of every
+	// record back to the job it originated from
+	FetchFailedRecords(input FetchFailedRecordsInput) (FailedRecordsResponse, error)
+}
+
+// AsyncPoll is the input of AsyncDestinationManager.Poll
+type AsyncPoll struct {
+	Config              map[string]interface{}
+	ImportingParameters stdjson.RawMessage
+}
+
+// PollStatusResponse is the status of an import as reported by the destination
+type PollStatusResponse struct {
+	Success        bool
+	StatusCode     int
+	HasFailed      bool
+	HasWarning     bool
+	FailedJobsURL  string
+	WarningJobsURL string
+}
+
+// FetchFailedRecordsInput is the input of AsyncDestinationManager.FetchFailedRecords
+type FetchFailedRecordsInput struct {
+	Config              map[string]interface{}
+	ImportingParameters stdjson.RawMessage
+	ImportingJobs       []*jobsdb.JobT
+	PollResponse        PollStatusResponse
+}
+
+// FailedRecordsResponse holds the outcome of a finished import per job id. Importing jobs which are
+// not part of any list are considered failed and are retried.
+type FailedRecordsResponse struct {
+	SucceededJobIDs []int64
+	WarningJobIDs   []int64
+	AbortedJobIDs   []int64
+	AbortedReasons  map[int64]string
+}
+
+var managerFactories = map[string]func(destType string) AsyncDestinationManager{
+	"MARKETO_BULK_UPLOAD": func(destType string) AsyncDestinationManager {
+		return NewTransformerBulkUploader(destType, transformerURL)
+	},
+}
+
+// IsAsyncDestination returns true if the destination type is handled by an AsyncDestinationManager
+func IsAsyncDestination(destType string) bool {
+	_, ok := managerFactories[destType]
+	return ok
+}
+
+// NewManager returns the AsyncDestinationManager for the destination type
+func NewManager(destType string) (AsyncDestinationManager, error) {
+	factory, ok := managerFactories[destType]
+	if !ok {
+		return nil, fmt.Errorf("async destination manager not found for destination type: %s", destType)
+	}
+	return factory(destType), nil
+}
@@ -0,0 +1,289 @@
+package asyncdestinationmanager
+
+import (
+	"bufio"
+	stdjson "encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/tidwall/gjson"
+
+	backendconfig "github.com/rudderlabs/rudder-server/config/backend-config"
+	"github.com/rudderlabs/rudder-server/jobsdb"
+	"github.com/rudderlabs/rudder-server/services/stats"
+	"github.com/rudderlabs/rudder-server/utils/misc"
+)
+
+// TransformerBulkUploader is an AsyncDestinationManager which delegates the communication with the
+// bulk import API of the destination to the transformer's upload, poll and failed jobs endpoints.
+type TransformerBulkUploader struct {
+	destType       string
+	transformerURL string
+}
+
+func NewTransformerBulkUploader(destType, transformerURL string) *TransformerBulkUploader {
+	return &TransformerBulkUploader{
+		destType:       destType,
+		transformerURL: transformerURL,
+	}
+}
+
+func (b *TransformerBulkUploader) Transform(job *jobsdb.JobT) (string, error) {
+	var asyncJob AsyncJob
+	err := json.Unmarshal([]byte(GetTransformedData(job.EventPayload)), &asyncJob.Message)
+	if err != nil {
+		return "", fmt.Errorf("unmarshalling transformer response of job %d: %w", job.JobID, err)
+	}
+	asyncJob.Metadata = map[string]interface{}{"job_id": job.JobID}
+	responsePayload, err := json.Marshal(asyncJob)
+	if err != nil {
+		return "", fmt.Errorf("marshalling async job %d: %w", job.JobID, err)
+	}
+	return string(responsePayload), nil
+}
+
+func (b *TransformerBulkUploader) Upload(destination *backendconfig.DestinationT, asyncDestStruct *AsyncDestinationStruct) AsyncUploadOutput {
+	failedJobIDs := asyncDestStruct.FailedJobIDs
+	importingJobIDs := asyncDestStruct.ImportingJobIDs
+	destinationID := destination.ID
+
+	file, err := os.Open(asyncDestStruct.FileName)
+	if err != nil {
+		panic("BRT: Read File Failed" + err.Error())
+	}
+	defer file.Close()
+	var input []AsyncJob
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var tempJob AsyncJob
+		jobBytes := scanner.Bytes()
+		err := json.Unmarshal(jobBytes, &tempJob)
+		if err != nil {
+			panic("Unmarshalling a Single Line Failed")
+		}
+		input = append(input, tempJob)
+	}
+	var uploadT AsyncUploadT
+	uploadT.Input = input
+	uploadT.Config = destination.Config
+	uploadT.DestType = strings.ToLower(b.destType)
+	payload, err := json.Marshal(uploadT)
+	if err != nil {
+		panic("BRT: JSON Marshal Failed " + err.Error())
+	}
+
+	uploadTimeStat := stats.Default.NewTaggedStat("async_upload_time", stats.TimerType, map[string]string{
+		"module":   "batch_router",
+		"destType": b.destType,
+	})
+
+	payloadSizeStat := stats.Default.NewTaggedStat("payload_size", stats.TimerType, map[string]string{
+		"module":   "batch_router",
+		"destType": b.destType,
+	})
+
+	startTime := time.Now()
+	payloadSizeStat.SendTiming(time.Millisecond * time.Duration(len(payload)))
+	pkgLogger.Debugf("[Async Destination Maanger] File Upload Started for Dest Type %v", b.destType)
+	responseBody, statusCodeHTTP := misc.HTTPCallWithRetryWithTimeout(b.resolveURL(asyncDestStruct.URL), payload, HTTPTimeout)
+	pkgLogger.Debugf("[Async Destination Maanger] File Upload Finished for Dest Type %v", b.destType)
+	uploadTimeStat.Since(startTime)
+	var bodyBytes []byte
+	var httpFailed bool
+	var statusCode string
+	if statusCodeHTTP != 200 {
+		bodyBytes = []byte(`"error" : "HTTP Call to Transformer Returned Non 200"`)
+		httpFailed = true
+	} else {
+		bodyBytes = responseBody
+		statusCode = gjson.GetBytes(bodyBytes, "statusCode").String()
+	}
+
+	var uploadResponse AsyncUploadOutput
+	if httpFailed {
+		uploadResponse = AsyncUploadOutput{
+			FailedJobIDs:  append(failedJobIDs, importingJobIDs...),
+			FailedReason:  string(bodyBytes),
+			FailedCount:   len(failedJobIDs) + len(importingJobIDs),
+			DestinationID: destinationID,
+		}
+	} else if statusCode == "200" {
+		var responseStruct UploadStruct
+		err := json.Unmarshal(bodyBytes, &responseStruct)
+		if err != nil {
+			panic("Incorrect Response from Transformer: " + err.Error())
+		}
+		var parameters Parameters
+		parameters.ImportId = responseStruct.ImportId
+		parameters.PollUrl = responseStruct.PollUrl
+		metaDataString, ok := responseStruct.Metadata["csvHeader"].(string)
+		if !ok {
+			parameters.MetaData = MetaDataT{CSVHeaders: ""}
+		} else {
+			parameters.MetaData = MetaDataT{CSVHeaders: metaDataString}
+		}
+		importParameters, err := json.Marshal(parameters)
+		if err != nil {
+			panic("Errored in Marshalling" + err.Error())
+		}
+		successfulJobIDs, failedJobIDsTrans := CleanUpData(responseStruct.Metadata, importingJobIDs)
+
+		uploadResponse = AsyncUploadOutput{
+			ImportingJobIDs:     successfulJobIDs,
+			FailedJobIDs:        append(failedJobIDs, failedJobIDsTrans...),
+			FailedReason:        `{"error":"Jobs flowed over the prescribed limit"}`,
+			ImportingParameters: stdjson.RawMessage(importParameters),
+			importingCount:      len(importingJobIDs),
+			FailedCount:         len(failedJobIDs) + len(failedJobIDsTrans),
+			DestinationID:       destinationID,
+		}
+	} else if statusCode == "400" {
+		var responseStruct UploadStruct
+		err := json.Unmarshal(bodyBytes, &responseStruct)
+		if err != nil {
+			panic("Incorrect Response from Transformer: " + err.Error())
+		}
+		eventsAbortedStat := stats.Default.NewTaggedStat("events_delivery_aborted", stats.CountType, map[string]string{
+			"module":   "batch_router",
+			"destType": b.destType,
+		})
+		abortedJobIDs, failedJobIDsTrans := CleanUpData(responseStruct.Metadata, importingJobIDs)
+		eventsAbortedStat.Count(len(abortedJobIDs))
+		uploadResponse = AsyncUploadOutput{
+			AbortJobIDs:   abortedJobIDs,
+			FailedJobIDs:  append(failedJobIDs, failedJobIDsTrans...),
+			FailedReason:  `{"error":"Jobs flowed over the prescribed limit"}`,
+			AbortReason:   string(bodyBytes),
+			AbortCount:    len(importingJobIDs),
+			FailedCount:   len(failedJobIDs) + len(failedJobIDsTrans),
+			DestinationID: destinationID,
+		}
+	} else {
+		uploadResponse = AsyncUploadOutput{
+			FailedJobIDs:  append(failedJobIDs, importingJobIDs...),
+			FailedReason:  string(bodyBytes),
+			FailedCount:   len(failedJobIDs) + len(importingJobIDs),
+			DestinationID: destinationID,
+		}
+	}
+	return uploadResponse
+}
+
+type asyncPollPayload struct {
+	Config   map[string]interface{} `json:"config"`
+	ImportId string                 `json:"importId"`
+	DestType string                 `json:"destType"`
+}
+
+func (b *TransformerBulkUploader) Poll(pollInput AsyncPoll) (PollStatusResponse, int) {
+	payload, err := json.Marshal(asyncPollPayload{
+		Config:   pollInput.Config,
+		ImportId: gjson.GetBytes(pollInput.ImportingParameters, "importId").String(),
+		DestType: strings.ToLower(b.destType),
+	})
+	if err != nil {
+		panic("JSON Marshal Failed" + err.Error())
+	}
+
+	pollURL := gjson.GetBytes(pollInput.ImportingParameters, "pollURL").String()
+	pkgLogger.Debugf("[Async Destination Manager] Poll Status Started for Dest Type %v", b.destType)
+	bodyBytes, statusCode := misc.HTTPCallWithRetryWithTimeout(b.transformerURL+pollURL, payload, HTTPTimeout)
+	pkgLogger.Debugf("[Async Destination Manager] Poll Status Finished for Dest Type %v", b.destType)
+
+	var pollResponse PollStatusResponse
+	if statusCode != 200 {
+		return pollResponse, statusCode
+	}
+	err = json.Unmarshal(bodyBytes, &pollResponse)
+	if err != nil {
+		panic("JSON Unmarshal Failed" + err.Error())
+	}
+	return pollResponse, statusCode
+}
+
+func (b *TransformerBulkUploader) FetchFailedRecords(input FetchFailedRecordsInput) (FailedRecordsResponse, error) {
+	var response FailedRecordsResponse
+	importID := gjson.GetBytes(input.ImportingParameters, "importId").String()
+	csvHeaders := gjson.GetBytes(input.ImportingParameters, "metadata.csvHeader").String()
+	payload := b.generateFailedPayload(input.Config, input.ImportingJobs, importID, csvHeaders)
+
+	pkgLogger.Debugf("[Async Destination Manager] Fetching Failed Jobs Started for Dest Type %v", b.destType)
+	failedBodyBytes, statusCode := misc.HTTPCallWithRetryWithTimeout(b.transformerURL+input.PollResponse.FailedJobsURL, payload, HTTPTimeout)
+	pkgLogger.Debugf("[Async Destination Manager] Fetching Failed Jobs Finished for Dest Type %v", b.destType)
+	if statusCode != 200 {
+		return response, fmt.Errorf("fetching failed jobs for %s returned status code %d", b.destType, statusCode)
+	}
+
+	var failedJobsResponse map[string]interface{}
+	err := json.Unmarshal(failedBodyBytes, &failedJobsResponse)
+	if err != nil {
+		panic("JSON Unmarshal Failed" + err.Error())
+	}
+	internalStatusCode, ok := failedJobsResponse["status"].(string)
+	if internalStatusCode != "200" || !ok {
+		return response, fmt.Errorf("fetching failed jobs for %s returned status code %v and body %v", b.destType, internalStatusCode, string(failedBodyBytes))
+	}
+	metadata, ok := failedJobsResponse["metadata"].(map[string]interface{})
+	if !ok {
+		return response, fmt.Errorf("typecasting failed jobs metadata for %s with body %v", b.destType, string(failedBodyBytes))
+	}
+
+	failedKeys, errFailed := misc.ConvertStringInterfaceToIntArray(metadata["failedKeys"])
+	warningKeys, errWarning := misc.ConvertStringInterfaceToIntArray(metadata["warningKeys"])
+	succeededKeys, errSuccess := misc.ConvertStringInterfaceToIntArray(metadata["succeededKeys"])
+	if errFailed != nil || errWarning != nil || errSuccess != nil {
+		return response, ErrInvalidFailedRecords
+	}
+
+	response.SucceededJobIDs = succeededKeys
+	response.WarningJobIDs = warningKeys
+	response.AbortedJobIDs = failedKeys
+	response.AbortedReasons = make(map[int64]string, len(failedKeys))
+	for _, jobID := range failedKeys {
+		response.AbortedReasons[jobID] = gjson.GetBytes(failedBodyBytes, fmt.Sprintf("metadata.failedReasons.%v", jobID)).String()
+	}
+	return response, nil
+}
+
+func (b *TransformerBulkUploader) generateFailedPayload(config map[string]interface{}, jobs []*jobsdb.JobT, importID, csvHeaders string) []byte {
+	var failedPayloadT AsyncFailedPayload
+	failedPayloadT.Input = make([]map[string]interface{}, len(jobs))
+	index := 0
+	failedPayloadT.Config = config
+	for _, job := range jobs {
+		failedPayloadT.Input[index] = make(map[string]interface{})
+		var message map[string]interface{}
+		metadata := make(map[string]interface{})
+		err := json.Unmarshal([]byte(GetTransformedData(job.EventPayload)), &message)
+		if err != nil {
+			panic("Unmarshalling Transformer Data to JSON Failed")
+		}
+		metadata["job_id"] = job.JobID
+		failedPayloadT.Input[index]["message"] = message
+		failedPayloadT.Input[index]["metadata"] = metadata
+		index++
+	}
+	failedPayloadT.DestType = strings.ToLower(b.destType)
+	failedPayloadT.ImportId = importID
+	failedPayloadT.MetaData = MetaDataT{CSVHeaders: csvHeaders}
+	payload, err := json.Marshal(failedPayloadT)
+	if err != nil {
+		panic("JSON Marshal Failed" + err.Error())
+	}
+	return payload
+}
+
+func (b *TransformerBulkUploader) resolveURL(relative string) string {
+	baseURL, err := url.Parse(b.transformerURL)
+	if err != nil {
+		pkgLogger.Fatal(err)
+	}
+	relURL, err := url.Parse(relative)
+	if err != nil {
+		pkgLogger.Fatal(err)
+	}
+	return baseURL.ResolveReference(relURL).String()
+}
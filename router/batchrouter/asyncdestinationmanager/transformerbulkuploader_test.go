@@ -0,0 +1,141 @@
+package asyncdestinationmanager
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	backendconfig "github.com/rudderlabs/rudder-server/config/backend-config"
+	"github.com/rudderlabs/rudder-server/jobsdb"
+	"github.com/rudderlabs/rudder-server/utils/logger"
+)
+
+func setupTest(t *testing.T, handler http.HandlerFunc) *TransformerBulkUploader {
+	t.Helper()
+	pkgLogger = logger.NOP
+	HTTPTimeout = 10 * time.Second
+
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return NewTransformerBulkUploader("MARKETO_BULK_UPLOAD", srv.URL)
+}
+
+func TestNewManager(t *testing.T) {
+	require.True(t, IsAsyncDestination("MARKETO_BULK_UPLOAD"))
+	require.False(t, IsAsyncDestination("S3"))
+
+	manager, err := NewManager("MARKETO_BULK_UPLOAD")
+	require.NoError(t, err)
+	require.IsType(t, &TransformerBulkUploader{}, manager)
+
+	_, err = NewManager("S3")
+	require.Error(t, err)
+}
+
+func TestTransformerBulkUploader(t *testing.T) {
+	importingParameters := []byte(`{"importId":"import-1","pollURL":"/poll","metadata":{"csvHeader":"email"}}`)
+	jobs := []*jobsdb.JobT{
+		{JobID: 1, EventPayload: []byte(`{"body":{"JSON":{"email":"a@example.com"}}}`)},
+		{JobID: 2, EventPayload: []byte(`{"body":{"JSON":{"email":"b@example.com"}}}`)},
+		{JobID: 3, EventPayload: []byte(`{"body":{"JSON":{"email":"c@example.com"}}}`)},
+	}
+
+	t.Run("transform", func(t *testing.T) {
+		b := setupTest(t, func(w http.ResponseWriter, r *http.Request) {})
+		line, err := b.Transform(jobs[0])
+		require.NoError(t, err)
+		require.JSONEq(t, `{"message":{"email":"a@example.com"},"metadata":{"job_id":1}}`, line)
+
+		_, err = b.Transform(&jobsdb.JobT{JobID: 4, EventPayload: []byte(`{"body":{"JSON":"invalid"}}`)})
+		require.Error(t, err)
+	})
+
+	t.Run("upload", func(t *testing.T) {
+		b := setupTest(t, func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, "/marketo/upload", r.URL.Path)
+			body, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			require.Equal(t, "marketo_bulk_upload", gjson.GetBytes(body, "destType").String())
+			require.Len(t, gjson.GetBytes(body, "input").Array(), 2)
+			_, _ = w.Write([]byte(`{"statusCode":200,"importId":"import-1","pollURL":"/poll","metadata":{"successfulJobs":["1"],"unsuccessfulJobs":["2"],"csvHeader":"email"}}`))
+		})
+
+		fileName := filepath.Join(t.TempDir(), "upload.txt")
+		var content string
+		for _, job := range jobs[:2] {
+			line, err := b.Transform(job)
+			require.NoError(t, err)
+			content += line + "\n"
+		}
+		require.NoError(t, os.WriteFile(fileName, []byte(content), 0o600))
+
+		output := b.Upload(&backendconfig.DestinationT{ID: "destination-1"}, &AsyncDestinationStruct{
+			ImportingJobIDs: []int64{1, 2},
+			FailedJobIDs:    []int64{3},
+			FileName:        fileName,
+			URL:             "/marketo/upload",
+		})
+		require.Equal(t, "destination-1", output.DestinationID)
+		require.Equal(t, []int64{1}, output.ImportingJobIDs)
+		require.Equal(t, []int64{3, 2}, output.FailedJobIDs)
+		require.JSONEq(t, string(importingParameters), string(output.ImportingParameters))
+	})
+
+	t.Run("poll", func(t *testing.T) {
+		b := setupTest(t, func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, "/poll", r.URL.Path)
+			body, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			require.Equal(t, "import-1", gjson.GetBytes(body, "importId").String())
+			_, _ = w.Write([]byte(`{"Success":true,"StatusCode":200,"HasFailed":true,"FailedJobsURL":"/failed"}`))
+		})
+
+		pollResponse, statusCode := b.Poll(AsyncPoll{ImportingParameters: importingParameters})
+		require.Equal(t, http.StatusOK, statusCode)
+		require.Equal(t, PollStatusResponse{Success: true, StatusCode: 200, HasFailed: true, FailedJobsURL: "/failed"}, pollResponse)
+	})
+
+	t.Run("fetch failed records", func(t *testing.T) {
+		b := setupTest(t, func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, "/failed", r.URL.Path)
+			body, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			require.Equal(t, "email", gjson.GetBytes(body, "metadata.csvHeader").String())
+			require.Len(t, gjson.GetBytes(body, "input").Array(), 3)
+			_, _ = w.Write([]byte(`{"status":"200","metadata":{"succeededKeys":["1"],"warningKeys":["2"],"failedKeys":["3"],"failedReasons":{"3":"invalid email"}}}`))
+		})
+
+		failedRecords, err := b.FetchFailedRecords(FetchFailedRecordsInput{
+			ImportingParameters: importingParameters,
+			ImportingJobs:       jobs,
+			PollResponse:        PollStatusResponse{FailedJobsURL: "/failed"},
+		})
+		require.NoError(t, err)
+		require.Equal(t, FailedRecordsResponse{
+			SucceededJobIDs: []int64{1},
+			WarningJobIDs:   []int64{2},
+			AbortedJobIDs:   []int64{3},
+			AbortedReasons:  map[int64]string{3: "invalid email"},
+		}, failedRecords)
+	})
+
+	t.Run("fetch invalid failed records", func(t *testing.T) {
+		b := setupTest(t, func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(`{"status":"200","metadata":{"succeededKeys":["invalid"]}}`))
+		})
+
+		_, err := b.FetchFailedRecords(FetchFailedRecordsInput{
+			ImportingParameters: importingParameters,
+			ImportingJobs:       jobs,
+			PollResponse:        PollStatusResponse{FailedJobsURL: "/failed"},
+		})
+		require.ErrorIs(t, err, ErrInvalidFailedRecords)
+	})
+}
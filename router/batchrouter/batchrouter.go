@@ -9,7 +9,6 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"net/url"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -61,14 +60,12 @@ var (
 	warehouseServiceFailedTime         time.Time
 	warehouseServiceFailedTimeLock     sync.RWMutex
 	warehouseServiceMaxRetryTime       time.Duration
-	asyncDestinations                  []string
 	pkgLogger                          logger.Logger
 	Diagnostics                        diagnostics.DiagnosticsI
 	readPerDestination                 bool
 	disableEgress                      bool
 	toAbortDestinationIDs              string
 	netClientTimeout                   time.Duration
-	transformerURL                     string
 	datePrefixOverride                 string
 	dateFormatLayouts                  map[string]string // string -> string
 	dateFormatMap                      map[string]string // (sourceId:destinationId) -> dateFormat
@@ -112,6 +109,7 @@ type HandleT struct {
 	isBackendConfigInitialized  bool
 	backendConfigInitialized    chan bool
 	asyncDestinationStruct      map[string]*asyncdestinationmanager.AsyncDestinationStruct
+	asyncDestinationManager     asyncdestinationmanager.AsyncDestinationManager
 	jobQueryBatchSize           int
 	pollStatusLoopSleep         time.Duration
 	pollTimeStat                stats.Measurement
@@ -131,6 +129,8 @@ type HandleT struct {
 	jobsDBCommandTimeout      time.Duration
 	jobdDBQueryRequestTimeout time.Duration
 	jobdDBMaxRetries          int
+
+	warehouseStagingFileFormat string
 }
 
 type BatchDestinationDataT struct {
@@ -138,12 +138,6 @@ type BatchDestinationDataT struct {
 	jobs             []*jobsdb.JobT
 	parentWG         *sync.WaitGroup
 }
-type AsyncPollT struct {
-	Config   map[string]interface{} `json:"config"`
-	ImportId string                 `json:"importId"`
-	DestType string                 `json:"destType"`
-}
-
 type ObjectStorageT struct {
 	Config          map[string]interface{}
 	Key             string
@@ -230,26 +224,19 @@ type batchRequestMetric struct {
 }
 
 type StorageUploadOutput struct {
-	Config           map[string]interface{}
-	Key              string
-	FileLocation     string
-	LocalFilePaths   []string
-	JournalOpID      int64
-	Error            error
-	FirstEventAt     string
-	LastEventAt      string
-	TotalEvents      int
-	UseRudderStorage bool
-}
-
-type AsyncStatusResponse struct {
-	Success        bool
-	StatusCode     int
-	HasFailed      bool
-	HasWarning     bool
-	FailedJobsURL  string
-	WarningJobsURL string
+	Config            map[string]interface{}
+	Key               string
+	FileLocation      string
+	LocalFilePaths    []string
+	JournalOpID       int64
+	Error             error
+	FirstEventAt      string
+	LastEventAt       string
+	TotalEvents       int
+	UseRudderStorage  bool
+	StagingFileFormat string
 }
+
 type ErrorResponseT struct {
 	Error string
 }
@@ -310,303 +297,169 @@ func (brt *HandleT) pollAsyncStatus(ctx context.Context) {
 			for key := range destinationsMap {
 				if IsAsyncDestination(brt.destType) {
 					pkgLogger.Debugf("pollAsyncStatus Started for Dest type: %s", brt.destType)
-					parameterFilters := make([]jobsdb.ParameterFilterT, 0)
-					for _, param := range jobsdb.CacheKeyParameterFilters {
-						parameterFilter := jobsdb.ParameterFilterT{
-							Name:     param,
-							Value:    key,
-							Optional: false,
-						}
-						parameterFilters = append(parameterFilters, parameterFilter)
-					}
-					job, err := misc.QueryWithRetriesAndNotify(ctx, brt.jobdDBQueryRequestTimeout, brt.jobdDBMaxRetries, func(ctx context.Context) (jobsdb.JobsResult, error) {
-						return brt.jobsDB.GetImporting(
-							ctx,
-							jobsdb.GetQueryParamsT{
-								CustomValFilters: []string{brt.destType},
-								JobsLimit:        1,
-								ParameterFilters: parameterFilters,
-								PayloadSizeLimit: brt.payloadLimit,
-							},
-						)
-					}, sendQueryRetryStats)
-					if err != nil {
-						pkgLogger.Errorf("Error while getting job for dest type: %s, err: %v", brt.destType, err)
-						panic(err)
-					}
-					importingJob := job.Jobs
-					if len(importingJob) != 0 {
-						importingJob := importingJob[0]
-						parameters := importingJob.LastJobStatus.Parameters
-						pollUrl := gjson.GetBytes(parameters, "pollURL").String()
-						importId := gjson.GetBytes(parameters, "importId").String()
-						csvHeaders := gjson.GetBytes(parameters, "metadata.csvHeader").String()
-						var pollStruct AsyncPollT
-						pollStruct.ImportId = importId
-						pollStruct.Config = brt.destinationsMap[key].Destination.Config
-						pollStruct.DestType = strings.ToLower(brt.destType)
-						payload, err := json.Marshal(pollStruct)
-						if err != nil {
-							panic("JSON Marshal Failed" + err.Error())
-						}
-
-						startPollTime := time.Now()
-						pkgLogger.Debugf("[Batch Router] Poll Status Started for Dest Type %v", brt.destType)
-						bodyBytes, statusCode := misc.HTTPCallWithRetryWithTimeout(transformerURL+pollUrl, payload, asyncdestinationmanager.HTTPTimeout)
-						pkgLogger.Debugf("[Batch Router] Poll Status Finished for Dest Type %v", brt.destType)
-						brt.pollTimeStat.Since(startPollTime)
+					brt.pollAsyncDestination(ctx, key, destinationsMap[key].Destination.Config)
+				}
+			}
+		}
+	}
+}
 
-						if err != nil {
-							panic("HTTP Request Failed" + err.Error())
-						}
-						if statusCode == 200 {
-							var asyncResponse AsyncStatusResponse
-							if err != nil {
-								panic("Read Body Failed" + err.Error())
-							}
-							err = json.Unmarshal(bodyBytes, &asyncResponse)
-							if err != nil {
-								panic("JSON Unmarshal Failed" + err.Error())
-							}
-							uploadStatus := asyncResponse.Success
-							statusCode := asyncResponse.StatusCode
-							abortedJobs := make([]*jobsdb.JobT, 0)
-							if uploadStatus {
-								var statusList []*jobsdb.JobStatusT
-								list, err := misc.QueryWithRetriesAndNotify(ctx, brt.jobdDBQueryRequestTimeout, brt.jobdDBMaxRetries, func(ctx context.Context) (jobsdb.JobsResult, error) {
-									return brt.jobsDB.GetImporting(
-										ctx,
-										jobsdb.GetQueryParamsT{
-											CustomValFilters: []string{brt.destType},
-											JobsLimit:        brt.maxEventsInABatch,
-											ParameterFilters: parameterFilters,
-											PayloadSizeLimit: brt.payloadLimit,
-										},
-									)
-								}, sendQueryRetryStats)
-								if err != nil {
-									panic(err)
-								}
+// pollAsyncDestination polls the status of the import which is in progress for the destination, if any,
+// and updates the statuses of its importing jobs once the import has finished
+func (brt *HandleT) pollAsyncDestination(ctx context.Context, destinationID string, destConfig map[string]interface{}) {
+	parameterFilters := make([]jobsdb.ParameterFilterT, 0)
+	for _, param := range jobsdb.CacheKeyParameterFilters {
+		parameterFilter := jobsdb.ParameterFilterT{
+			Name:     param,
+			Value:    destinationID,
+			Optional: false,
+		}
+		parameterFilters = append(parameterFilters, parameterFilter)
+	}
+	job, err := misc.QueryWithRetriesAndNotify(ctx, brt.jobdDBQueryRequestTimeout, brt.jobdDBMaxRetries, func(ctx context.Context) (jobsdb.JobsResult, error) {
+		return brt.jobsDB.GetImporting(
+			ctx,
+			jobsdb.GetQueryParamsT{
+				CustomValFilters: []string{brt.destType},
+				JobsLimit:        1,
+				ParameterFilters: parameterFilters,
+				PayloadSizeLimit: brt.payloadLimit,
+			},
+		)
+	}, sendQueryRetryStats)
+	if err != nil {
+		pkgLogger.Errorf("Error while getting job for dest type: %s, err: %v", brt.destType, err)
+		panic(err)
+	}
+	if len(job.Jobs) == 0 {
+		return
+	}
+	importingParameters := job.Jobs[0].LastJobStatus.Parameters
 
-								importingList := list.Jobs
-								if !asyncResponse.HasFailed {
-									for _, job := range importingList {
-										status := jobsdb.JobStatusT{
-											JobID:         job.JobID,
-											JobState:      jobsdb.Succeeded.State,
-											ExecTime:      time.Now(),
-											RetryTime:     time.Now(),
-											ErrorCode:     "",
-											ErrorResponse: []byte(`{}`),
-											Parameters:    []byte(`{}`),
-											WorkspaceId:   job.WorkspaceId,
-										}
-										statusList = append(statusList, &status)
-									}
-									brt.successfulJobCount.Count(len(statusList))
-								} else {
-									failedJobUrl := asyncResponse.FailedJobsURL
-									payload = asyncdestinationmanager.GenerateFailedPayload(brt.destinationsMap[key].Destination.Config, importingList, importId, brt.destType, csvHeaders)
-									startFailedJobsPollTime := time.Now()
-									pkgLogger.Debugf("[Batch Router] Fetching Failed Jobs Started for Dest Type %v", brt.destType)
-									failedBodyBytes, statusCode := misc.HTTPCallWithRetryWithTimeout(transformerURL+failedJobUrl, payload, asyncdestinationmanager.HTTPTimeout)
-									pkgLogger.Debugf("[Batch Router] Fetching Failed Jobs for Dest Type %v", brt.destType)
-									brt.failedJobsTimeStat.Since(startFailedJobsPollTime)
-
-									if statusCode != 200 {
-										continue
-									}
-									var failedJobsResponse map[string]interface{}
-									err = json.Unmarshal(failedBodyBytes, &failedJobsResponse)
-									if err != nil {
-										panic("JSON Unmarshal Failed" + err.Error())
-									}
-									internalStatusCode, ok := failedJobsResponse["status"].(string)
-									if internalStatusCode != "200" || !ok {
-										pkgLogger.Errorf("[Batch Router] Failed to fetch failed jobs for Dest Type %v with statusCode %v and body %v", brt.destType, internalStatusCode, string(failedBodyBytes))
-										continue
-									}
-									metadata, ok := failedJobsResponse["metadata"].(map[string]interface{})
-									if !ok {
-										pkgLogger.Errorf("[Batch Router] Failed to typecast failed jobs response for Dest Type %v with statusCode %v and body %v with error %v", brt.destType, internalStatusCode, string(failedBodyBytes), err)
-										continue
-									}
-									failedKeys, errFailed := misc.ConvertStringInterfaceToIntArray(metadata["failedKeys"])
-									warningKeys, errWarning := misc.ConvertStringInterfaceToIntArray(metadata["warningKeys"])
-									succeededKeys, errSuccess := misc.ConvertStringInterfaceToIntArray(metadata["succeededKeys"])
-									var status *jobsdb.JobStatusT
-									if errFailed != nil || errWarning != nil || errSuccess != nil || statusCode != 200 {
-										for _, job := range importingList {
-											jobID := job.JobID
-											status = &jobsdb.JobStatusT{
-												JobID:         jobID,
-												JobState:      jobsdb.Failed.State,
-												ExecTime:      time.Now(),
-												RetryTime:     time.Now(),
-												ErrorCode:     strconv.Itoa(statusCode),
-												ErrorResponse: []byte(`{}`),
-												Parameters:    []byte(`{}`),
-												WorkspaceId:   job.WorkspaceId,
-											}
-											statusList = append(statusList, status)
-										}
-										brt.failedJobCount.Count(len(statusList))
-										err := misc.RetryWithNotify(context.Background(), brt.jobsDBCommandTimeout, brt.jobdDBMaxRetries, func(ctx context.Context) error {
-											return brt.jobsDB.WithUpdateSafeTx(ctx, func(tx jobsdb.UpdateSafeTx) error {
-												err = brt.jobsDB.UpdateJobStatusInTx(ctx, tx, statusList, []string{brt.destType}, parameterFilters)
-												if err != nil {
-													return fmt.Errorf("updating %s job statuses: %w", brt.destType, err)
-												}
-												// no need to update rsources stats here since no terminal job state is recorded
-												return nil
-											})
-										}, sendRetryUpdateStats)
-										if err != nil {
-											panic(err)
-										}
-										brt.updateProcessedEventsMetrics(statusList)
-										continue
-									}
-									for _, job := range importingList {
-										jobID := job.JobID
-										if misc.Contains(append(succeededKeys, warningKeys...), jobID) {
-											status = &jobsdb.JobStatusT{
-												JobID:         jobID,
-												JobState:      jobsdb.Succeeded.State,
-												ExecTime:      time.Now(),
-												RetryTime:     time.Now(),
-												ErrorCode:     "200",
-												ErrorResponse: []byte(`{}`),
-												Parameters:    []byte(`{}`),
-												WorkspaceId:   job.WorkspaceId,
-											}
-										} else if misc.Contains(failedKeys, job.JobID) {
-											errorResp, _ := json.Marshal(ErrorResponseT{Error: gjson.GetBytes(failedBodyBytes, fmt.Sprintf("metadata.failedReasons.%v", job.JobID)).String()})
-											status = &jobsdb.JobStatusT{
-												JobID:         jobID,
-												JobState:      jobsdb.Aborted.State,
-												ExecTime:      time.Now(),
-												RetryTime:     time.Now(),
-												ErrorCode:     "",
-												ErrorResponse: errorResp,
-												Parameters:    []byte(`{}`),
-												WorkspaceId:   job.WorkspaceId,
-											}
-											abortedJobs = append(abortedJobs, job)
-										}
-										statusList = append(statusList, status)
-									}
-								}
-								brt.successfulJobCount.Count(len(statusList) - len(abortedJobs))
-								brt.abortedJobCount.Count(len(abortedJobs))
-
-								if len(abortedJobs) > 0 {
-									err := misc.RetryWithNotify(context.Background(), brt.jobsDBCommandTimeout, brt.jobdDBMaxRetries, func(ctx context.Context) error {
-										return brt.errorDB.Store(ctx, abortedJobs)
-									}, sendRetryStoreStats)
-									if err != nil {
-										panic(fmt.Errorf("storing %s jobs into ErrorDB: %w", brt.destType, err))
-									}
-								}
-								err = misc.RetryWithNotify(context.Background(), brt.jobsDBCommandTimeout, brt.jobdDBMaxRetries, func(ctx context.Context) error {
-									return brt.jobsDB.WithUpdateSafeTx(ctx, func(tx jobsdb.UpdateSafeTx) error {
-										err = brt.jobsDB.UpdateJobStatusInTx(ctx, tx, statusList, []string{brt.destType}, parameterFilters)
-										if err != nil {
-											return fmt.Errorf("updating %s job statuses: %w", brt.destType, err)
-										}
-
-										// rsources stats
-										return brt.updateRudderSourcesStats(ctx, tx, importingList, statusList)
-									})
-								}, sendRetryUpdateStats)
-								if err != nil {
-									panic(err)
-								}
-								brt.updateProcessedEventsMetrics(statusList)
-							} else if statusCode != 0 {
-								var statusList []*jobsdb.JobStatusT
-								list, err := misc.QueryWithRetriesAndNotify(ctx, brt.jobdDBQueryRequestTimeout, brt.jobdDBMaxRetries, func(ctx context.Context) (jobsdb.JobsResult, error) {
-									return brt.jobsDB.GetImporting(
-										ctx,
-										jobsdb.GetQueryParamsT{
-											CustomValFilters: []string{brt.destType},
-											JobsLimit:        brt.maxEventsInABatch,
-											ParameterFilters: parameterFilters,
-											PayloadSizeLimit: brt.payloadLimit,
-										},
-									)
-								}, sendQueryRetryStats)
-								if err != nil {
-									panic(err)
-								}
+	startPollTime := time.Now()
+	pollResponse, statusCode := brt.asyncDestinationManager.Poll(asyncdestinationmanager.AsyncPoll{
+		Config:              destConfig,
+		ImportingParameters: importingParameters,
+	})
+	brt.pollTimeStat.Since(startPollTime)
+	if statusCode != 200 {
+		return
+	}
+	if !pollResponse.Success && pollResponse.StatusCode == 0 {
+		// import is still in progress
+		return
+	}
 
-								importingList := list.Jobs
-								if isJobTerminated(statusCode) {
-									for _, job := range importingList {
-										status := jobsdb.JobStatusT{
-											JobID:         job.JobID,
-											JobState:      jobsdb.Aborted.State,
-											ExecTime:      time.Now(),
-											RetryTime:     time.Now(),
-											ErrorCode:     "",
-											ErrorResponse: []byte(`{}`),
-											Parameters:    []byte(`{}`),
-											WorkspaceId:   job.WorkspaceId,
-										}
-										statusList = append(statusList, &status)
-										abortedJobs = append(abortedJobs, job)
-									}
-									brt.abortedJobCount.Count(len(importingList))
-								} else {
-									for _, job := range importingList {
-										status := jobsdb.JobStatusT{
-											JobID:         job.JobID,
-											JobState:      jobsdb.Failed.State,
-											ExecTime:      time.Now(),
-											RetryTime:     time.Now(),
-											ErrorCode:     "",
-											ErrorResponse: []byte(`{}`),
-											Parameters:    []byte(`{}`),
-											WorkspaceId:   job.WorkspaceId,
-										}
-										statusList = append(statusList, &status)
-									}
-									brt.failedJobCount.Count(len(importingList))
-								}
-								if len(abortedJobs) > 0 {
-									err := misc.RetryWithNotify(context.Background(), brt.jobsDBCommandTimeout, brt.jobdDBMaxRetries, func(ctx context.Context) error {
-										return brt.errorDB.Store(ctx, abortedJobs)
-									}, sendRetryStoreStats)
-									if err != nil {
-										panic(fmt.Errorf("storing %s jobs into ErrorDB: %w", brt.destType, err))
-									}
-								}
+	list, err := misc.QueryWithRetriesAndNotify(ctx, brt.jobdDBQueryRequestTimeout, brt.jobdDBMaxRetries, func(ctx context.Context) (jobsdb.JobsResult, error) {
+		return brt.jobsDB.GetImporting(
+			ctx,
+			jobsdb.GetQueryParamsT{
+				CustomValFilters: []string{brt.destType},
+				JobsLimit:        brt.maxEventsInABatch,
+				ParameterFilters: parameterFilters,
+				PayloadSizeLimit: brt.payloadLimit,
+			},
+		)
+	}, sendQueryRetryStats)
+	if err != nil {
+		panic(err)
+	}
+	importingList := list.Jobs
 
-								err = misc.RetryWithNotify(context.Background(), brt.jobsDBCommandTimeout, brt.jobdDBMaxRetries, func(ctx context.Context) error {
-									return brt.jobsDB.WithUpdateSafeTx(ctx, func(tx jobsdb.UpdateSafeTx) error {
-										err = brt.jobsDB.UpdateJobStatusInTx(ctx, tx, statusList, []string{brt.destType}, parameterFilters)
-										if err != nil {
-											return fmt.Errorf("updating %s job statuses: %w", brt.destType, err)
-										}
-										// rsources stats
-										return brt.updateRudderSourcesStats(ctx, tx, importingList, statusList)
-									})
-								}, sendRetryUpdateStats)
-								if err != nil {
-									panic(err)
-								}
-								brt.updateProcessedEventsMetrics(statusList)
-							} else {
-								continue
-							}
-						} else {
-							continue
-						}
+	var statusList []*jobsdb.JobStatusT
+	abortedJobs := make([]*jobsdb.JobT, 0)
+	switch {
+	case pollResponse.Success && !pollResponse.HasFailed:
+		for _, job := range importingList {
+			statusList = append(statusList, asyncJobStatus(job, jobsdb.Succeeded.State, "", []byte(`{}`)))
+		}
+		brt.successfulJobCount.Count(len(statusList))
+	case pollResponse.Success:
+		startFailedJobsPollTime := time.Now()
+		failedRecords, err := brt.asyncDestinationManager.FetchFailedRecords(asyncdestinationmanager.FetchFailedRecordsInput{
+			Config:              destConfig,
+			ImportingParameters: importingParameters,
+			ImportingJobs:       importingList,
+			PollResponse:        pollResponse,
+		})
+		brt.failedJobsTimeStat.Since(startFailedJobsPollTime)
+		if errors.Is(err, asyncdestinationmanager.ErrInvalidFailedRecords) {
+			for _, job := range importingList {
+				statusList = append(statusList, asyncJobStatus(job, jobsdb.Failed.State, strconv.Itoa(statusCode), []byte(`{}`)))
+			}
+			brt.failedJobCount.Count(len(statusList))
+			break
+		}
+		if err != nil {
+			pkgLogger.Errorf("[Batch Router] Failed to fetch failed jobs for Dest Type %v: %v", brt.destType, err)
+			return
+		}
 
-					}
-				}
+		succeededJobIDs := append(failedRecords.SucceededJobIDs, failedRecords.WarningJobIDs...)
+		var failedCount int
+		for _, job := range importingList {
+			switch {
+			case misc.Contains(succeededJobIDs, job.JobID):
+				statusList = append(statusList, asyncJobStatus(job, jobsdb.Succeeded.State, "200", []byte(`{}`)))
+			case misc.Contains(failedRecords.AbortedJobIDs, job.JobID):
+				errorResp, _ := json.Marshal(ErrorResponseT{Error: failedRecords.AbortedReasons[job.JobID]})
+				statusList = append(statusList, asyncJobStatus(job, jobsdb.Aborted.State, "", errorResp))
+				abortedJobs = append(abortedJobs, job)
+			default:
+				statusList = append(statusList, asyncJobStatus(job, jobsdb.Failed.State, "", []byte(`{}`)))
+				failedCount++
 			}
 		}
+		brt.successfulJobCount.Count(len(statusList) - len(abortedJobs) - failedCount)
+		brt.abortedJobCount.Count(len(abortedJobs))
+		brt.failedJobCount.Count(failedCount)
+	case isJobTerminated(pollResponse.StatusCode):
+		for _, job := range importingList {
+			statusList = append(statusList, asyncJobStatus(job, jobsdb.Aborted.State, "", []byte(`{}`)))
+			abortedJobs = append(abortedJobs, job)
+		}
+		brt.abortedJobCount.Count(len(importingList))
+	default:
+		for _, job := range importingList {
+			statusList = append(statusList, asyncJobStatus(job, jobsdb.Failed.State, "", []byte(`{}`)))
+		}
+		brt.failedJobCount.Count(len(importingList))
+	}
+
+	if len(abortedJobs) > 0 {
+		err := misc.RetryWithNotify(context.Background(), brt.jobsDBCommandTimeout, brt.jobdDBMaxRetries, func(ctx context.Context) error {
+			return brt.errorDB.Store(ctx, abortedJobs)
+		}, sendRetryStoreStats)
+		if err != nil {
+			panic(fmt.Errorf("storing %s jobs into ErrorDB: %w", brt.destType, err))
+		}
+	}
+	err = misc.RetryWithNotify(context.Background(), brt.jobsDBCommandTimeout, brt.jobdDBMaxRetries, func(ctx context.Context) error {
+		return brt.jobsDB.WithUpdateSafeTx(ctx, func(tx jobsdb.UpdateSafeTx) error {
+			err := brt.jobsDB.UpdateJobStatusInTx(ctx, tx, statusList, []string{brt.destType}, parameterFilters)
+			if err != nil {
+				return fmt.Errorf("updating %s job statuses: %w", brt.destType, err)
+			}
+			// rsources stats
+			return brt.updateRudderSourcesStats(ctx, tx, importingList, statusList)
+		})
+	}, sendRetryUpdateStats)
+	if err != nil {
+		panic(err)
+	}
+	brt.updateProcessedEventsMetrics(statusList)
+}
+
+func asyncJobStatus(job *jobsdb.JobT, state, errorCode string, errorResponse []byte) *jobsdb.JobStatusT {
+	return &jobsdb.JobStatusT{
+		JobID:         job.JobID,
+		JobState:      state,
+		ExecTime:      time.Now(),
+		RetryTime:     time.Now(),
+		ErrorCode:     errorCode,
+		ErrorResponse: errorResponse,
+		Parameters:    []byte(`{}`),
+		WorkspaceId:   job.WorkspaceId,
 	}
 }
 
@@ -629,17 +482,47 @@ func (brt *HandleT) copyJobsToStorage(provider string, batchJobs *BatchJobsT, is
 	if err != nil {
 		panic(err)
 	}
-	path := fmt.Sprintf("%v%v.json", tmpDirPath+localTmpDirName, fmt.Sprintf("%v.%v.%v", time.Now().Unix(), batchJobs.BatchDestination.Source.ID, uuid))
+	// warehouse staging files can be written in a columnar format, for the warehouse slaves to split them by table without parsing the events
+	stagingFileFormat := warehouseutils.StagingFileFormatJSON
+	if isWarehouse && brt.warehouseStagingFileFormat == warehouseutils.StagingFileFormatParquet {
+		stagingFileFormat = warehouseutils.StagingFileFormatParquet
+	}
+	path := fmt.Sprintf("%v%v", tmpDirPath+localTmpDirName, fmt.Sprintf("%v.%v.%v", time.Now().Unix(), batchJobs.BatchDestination.Source.ID, uuid))
 
-	gzipFilePath := fmt.Sprintf(`%v.gz`, path)
-	err = os.MkdirAll(filepath.Dir(gzipFilePath), os.ModePerm)
+	var (
+		gzWriter       misc.GZipWriter
+		columnarWriter *warehouseutils.ColumnarStagingFileWriter
+		localFilePath  string
+	)
+	if stagingFileFormat == warehouseutils.StagingFileFormatParquet {
+		localFilePath = fmt.Sprintf(`%v.parquet.tar`, path)
+	} else {
+		localFilePath = fmt.Sprintf(`%v.json.gz`, path)
+	}
+	err = os.MkdirAll(filepath.Dir(localFilePath), os.ModePerm)
 	if err != nil {
 		panic(err)
 	}
-	gzWriter, err := misc.CreateGZ(gzipFilePath)
+	if stagingFileFormat == warehouseutils.StagingFileFormatParquet {
+		columnarWriter, err = warehouseutils.CreateColumnarStagingFile(localFilePath)
+	} else {
+		gzWriter, err = misc.CreateGZ(localFilePath)
+	}
 	if err != nil {
 		panic(err)
 	}
+	writeEvent := func(payload []byte) error {
+		if columnarWriter == nil {
+			return gzWriter.WriteGZ(string(payload) + "\n")
+		}
+		return columnarWriter.WriteEvent(payload)
+	}
+	closeWriter := func() error {
+		if columnarWriter == nil {
+			return gzWriter.CloseGZ()
+		}
+		return columnarWriter.Close()
+	}
 
 	var dedupedIDMergeRuleJobs int
 	eventsFound := false
@@ -666,23 +549,34 @@ func (brt *HandleT) copyJobsToStorage(provider string, batchJobs *BatchJobsT, is
 		}
 
 		eventID := gjson.GetBytes(job.EventPayload, "messageId").String()
-		var ok bool
 		interruptedEventsMap, isDestInterrupted := brt.uploadedRawDataJobsCache[batchJobs.BatchDestination.Destination.ID]
 		if isDestInterrupted {
-			if _, ok = interruptedEventsMap[eventID]; !ok {
-				eventsFound = true
-				_ = gzWriter.WriteGZ(string(job.EventPayload) + "\n")
+			if _, ok := interruptedEventsMap[eventID]; ok {
+				continue
+			}
+		}
+		eventsFound = true
+		// an event missing from the staging file would be lost, so the whole batch fails and is retried instead
+		if err = writeEvent(job.EventPayload); err != nil {
+			brt.logger.Errorf("BRT: Failed to write event of job %d to staging file %s: %v", job.JobID, localFilePath, err)
+			_ = closeWriter()
+			return StorageUploadOutput{
+				Error:          err,
+				LocalFilePaths: []string{localFilePath},
 			}
-		} else {
-			eventsFound = true
-			_ = gzWriter.WriteGZ(string(job.EventPayload) + "\n")
 		}
 	}
-	_ = gzWriter.CloseGZ()
+	if err = closeWriter(); err != nil {
+		brt.logger.Errorf("BRT: Failed to close staging file %s: %v", localFilePath, err)
+		return StorageUploadOutput{
+			Error:          err,
+			LocalFilePaths: []string{localFilePath},
+		}
+	}
 	if !eventsFound {
 		brt.logger.Infof("BRT: No events in this batch for upload to %s. Events are either de-deuplicated or skipped", provider)
 		return StorageUploadOutput{
-			LocalFilePaths: []string{gzipFilePath},
+			LocalFilePaths: []string{localFilePath},
 		}
 	}
 	// assumes events from warehouse have receivedAt in metadata
@@ -710,7 +604,7 @@ func (brt *HandleT) copyJobsToStorage(provider string, batchJobs *BatchJobsT, is
 		lastEventAt = gjson.GetBytes(batchJobs.Jobs[len(batchJobs.Jobs)-1].EventPayload, "receivedAt").String()
 	}
 
-	brt.logger.Debugf("BRT: Logged to local file: %v", gzipFilePath)
+	brt.logger.Debugf("BRT: Logged to local file: %v", localFilePath)
 	useRudderStorage := isWarehouse && misc.IsConfiguredToUseRudderObjectStorage(batchJobs.BatchDestination.Destination.Config)
 	uploader, err := brt.fileManagerFactory.New(&filemanager.SettingsT{
 		Provider: provider,
@@ -724,11 +618,11 @@ func (brt *HandleT) copyJobsToStorage(provider string, batchJobs *BatchJobsT, is
 	if err != nil {
 		return StorageUploadOutput{
 			Error:          err,
-			LocalFilePaths: []string{gzipFilePath},
+			LocalFilePaths: []string{localFilePath},
 		}
 	}
 
-	outputFile, err := os.Open(gzipFilePath)
+	outputFile, err := os.Open(localFilePath)
 	if err != nil {
 		panic(err)
 	}
@@ -758,7 +652,7 @@ func (brt *HandleT) copyJobsToStorage(provider string, batchJobs *BatchJobsT, is
 	}
 	keyPrefixes := []string{folderName, batchJobs.BatchDestination.Source.ID, datePrefixLayout}
 
-	_, fileName := filepath.Split(gzipFilePath)
+	_, fileName := filepath.Split(localFilePath)
 	var (
 		opID      int64
 		opPayload stdjson.RawMessage
@@ -789,20 +683,21 @@ func (brt *HandleT) copyJobsToStorage(provider string, batchJobs *BatchJobsT, is
 		return StorageUploadOutput{
 			Error:          err,
 			JournalOpID:    opID,
-			LocalFilePaths: []string{gzipFilePath},
+			LocalFilePaths: []string{localFilePath},
 		}
 	}
 
 	return StorageUploadOutput{
-		Config:           batchJobs.BatchDestination.Destination.Config,
-		Key:              uploadOutput.ObjectName,
-		FileLocation:     uploadOutput.Location,
-		LocalFilePaths:   []string{gzipFilePath},
-		JournalOpID:      opID,
-		FirstEventAt:     firstEventAt,
-		LastEventAt:      lastEventAt,
-		TotalEvents:      len(batchJobs.Jobs) - dedupedIDMergeRuleJobs,
-		UseRudderStorage: useRudderStorage,
+		Config:            batchJobs.BatchDestination.Destination.Config,
+		Key:               uploadOutput.ObjectName,
+		FileLocation:      uploadOutput.Location,
+		LocalFilePaths:    []string{localFilePath},
+		JournalOpID:       opID,
+		FirstEventAt:      firstEventAt,
+		LastEventAt:       lastEventAt,
+		TotalEvents:       len(batchJobs.Jobs) - dedupedIDMergeRuleJobs,
+		UseRudderStorage:  useRudderStorage,
+		StagingFileFormat: stagingFileFormat,
 	}
 }
 
@@ -863,9 +758,11 @@ func (brt *HandleT) sendJobsToStorage(batchJobs BatchJobsT) {
 
 	brt.asyncDestinationStruct[destinationID].RsourcesStats.BeginProcessing(batchJobs.Jobs)
 	for _, job := range batchJobs.Jobs {
-		transformedData := asyncdestinationmanager.GetTransformedData(job.EventPayload)
 		if brt.asyncDestinationStruct[destinationID].Count < brt.maxEventsInABatch {
-			fileData := asyncdestinationmanager.GetMarshalledData(transformedData, job.JobID)
+			fileData, err := brt.asyncDestinationManager.Transform(job)
+			if err != nil {
+				panic(fmt.Errorf("BRT: %s: transforming job %d failed: %w", brt.destType, job.JobID, err))
+			}
 			brt.asyncDestinationStruct[destinationID].Size = brt.asyncDestinationStruct[destinationID].Size + len([]byte(fileData+"\n"))
 			jobString = jobString + fileData + "\n"
 			brt.asyncDestinationStruct[destinationID].ImportingJobIDs = append(brt.asyncDestinationStruct[destinationID].ImportingJobIDs, job.JobID)
@@ -912,7 +809,7 @@ func (brt *HandleT) asyncUploadWorker(ctx context.Context) {
 				timeout := uploadIntervalMap[destinationID]
 				if brt.asyncDestinationStruct[destinationID].Exists && (brt.asyncDestinationStruct[destinationID].CanUpload || timeElapsed > timeout) {
 					brt.asyncDestinationStruct[destinationID].CanUpload = true
-					uploadResponse := asyncdestinationmanager.Upload(resolveURL(transformerURL, brt.asyncDestinationStruct[destinationID].URL), brt.asyncDestinationStruct[destinationID].FileName, destinationsMap[destinationID].Destination.Config, brt.destType, brt.asyncDestinationStruct[destinationID].FailedJobIDs, brt.asyncDestinationStruct[destinationID].ImportingJobIDs, destinationID)
+					uploadResponse := brt.asyncDestinationManager.Upload(&destinationsMap[destinationID].Destination, brt.asyncDestinationStruct[destinationID])
 					brt.setMultipleJobStatus(uploadResponse, brt.asyncDestinationStruct[destinationID].RsourcesStats)
 					brt.asyncStructCleanUp(destinationID)
 				}
@@ -922,19 +819,6 @@ func (brt *HandleT) asyncUploadWorker(ctx context.Context) {
 	}
 }
 
-func resolveURL(base, relative string) string {
-	baseURL, err := url.Parse(base)
-	if err != nil {
-		pkgLogger.Fatal(err)
-	}
-	relURL, err := url.Parse(relative)
-	if err != nil {
-		pkgLogger.Fatal(err)
-	}
-	destURL := baseURL.ResolveReference(relURL).String()
-	return destURL
-}
-
 func (brt *HandleT) parseUploadIntervalFromConfig(destinationConfig map[string]interface{}) time.Duration {
 	uploadInterval, ok := destinationConfig["uploadInterval"]
 	if !ok {
@@ -1107,6 +991,7 @@ func (brt *HandleT) postToWarehouse(batchJobs *BatchJobsT, output StorageUploadO
 		LastEventAt:           output.LastEventAt,
 		TotalEvents:           output.TotalEvents,
 		UseRudderStorage:      output.UseRudderStorage,
+		StagingFileFormat:     output.StagingFileFormat,
 		SourceBatchID:         sampleParameters.SourceBatchID,
 		SourceTaskID:          sampleParameters.SourceTaskID,
 		SourceTaskRunID:       sampleParameters.SourceTaskRunID,
@@ -1118,6 +1003,7 @@ func (brt *HandleT) postToWarehouse(batchJobs *BatchJobsT, output StorageUploadO
 	if misc.Contains(warehouseutils.TimeWindowDestinations, brt.destType) {
 		payload.TimeWindow = batchJobs.TimeWindow
 	}
+	payload.Namespace = batchJobs.Namespace
 
 	jsonPayload, err := json.Marshal(&payload)
 	if err != nil {
@@ -1832,23 +1718,26 @@ func (worker *workerT) workerProcess() {
 					destUploadStat := stats.Default.NewStat(fmt.Sprintf(`batch_router.%s_%s_dest_upload_time`, brt.destType, objectStorageType), stats.TimerType)
 					destUploadStat.Start()
 					splitBatchJobs := brt.splitBatchJobsOnTimeWindow(batchJobs)
-					for _, batchJob := range splitBatchJobs {
-						output := brt.copyJobsToStorage(objectStorageType, batchJob, true)
-						postToWarehouseErr := false
-						if output.Error == nil && output.Key != "" {
-							output.Error = brt.postToWarehouse(batchJob, output)
-							if output.Error != nil {
-								postToWarehouseErr = true
+					for _, timeWindowBatchJob := range splitBatchJobs {
+						// a staging file is generated for every namespace the events are routed to
+						for _, batchJob := range brt.splitBatchJobsOnNamespace(timeWindowBatchJob) {
+							output := brt.copyJobsToStorage(objectStorageType, batchJob, true)
+							postToWarehouseErr := false
+							if output.Error == nil && output.Key != "" {
+								output.Error = brt.postToWarehouse(batchJob, output)
+								if output.Error != nil {
+									postToWarehouseErr = true
+								}
+								warehouseutils.DestStat(stats.CountType, "generate_staging_files", batchJob.BatchDestination.Destination.ID).Count(1)
+								warehouseutils.DestStat(stats.CountType, "staging_file_batch_size", batchJob.BatchDestination.Destination.ID).Count(len(batchJob.Jobs))
 							}
-							warehouseutils.DestStat(stats.CountType, "generate_staging_files", batchJob.BatchDestination.Destination.ID).Count(1)
-							warehouseutils.DestStat(stats.CountType, "staging_file_batch_size", batchJob.BatchDestination.Destination.ID).Count(len(batchJob.Jobs))
+							brt.recordDeliveryStatus(*batchJob.BatchDestination, output, true)
+							brt.setJobStatus(batchJob, true, output.Error, postToWarehouseErr)
+							misc.RemoveFilePaths(output.LocalFilePaths...)
 						}
-						brt.recordDeliveryStatus(*batchJob.BatchDestination, output, true)
-						brt.setJobStatus(batchJob, true, output.Error, postToWarehouseErr)
-						misc.RemoveFilePaths(output.LocalFilePaths...)
 					}
 					destUploadStat.End()
-				case misc.Contains(asyncDestinations, brt.destType):
+				case IsAsyncDestination(brt.destType):
 					destUploadStat := stats.Default.NewStat(fmt.Sprintf(`batch_router.%s_dest_upload_time`, brt.destType), stats.TimerType)
 					destUploadStat.Start()
 					brt.sendJobsToStorage(batchJobs)
@@ -1901,6 +1790,7 @@ type BatchJobsT struct {
 	Jobs             []*jobsdb.JobT
 	BatchDestination *DestinationT
 	TimeWindow       time.Time
+	Namespace        string
 }
 
 func connectionIdentifier(batchDestination DestinationT) string {
@@ -2166,7 +2056,7 @@ func (brt *HandleT) holdFetchingJobs(parameterFilters []jobsdb.ParameterFilterT)
 }
 
 func IsAsyncDestination(destType string) bool {
-	return misc.Contains(asyncDestinations, destType)
+	return asyncdestinationmanager.IsAsyncDestination(destType)
 }
 
 func (brt *HandleT) crashRecover() {
@@ -2216,6 +2106,34 @@ func (brt *HandleT) splitBatchJobsOnTimeWindow(batchJobs BatchJobsT) map[time.Ti
 	return splitBatches
 }
 
+func (brt *HandleT) splitBatchJobsOnNamespace(batchJobs *BatchJobsT) map[string]*BatchJobsT {
+	splitBatches := map[string]*BatchJobsT{}
+	routing, ok := warehouseutils.GetNamespaceRouting(batchJobs.BatchDestination.Destination)
+	if !ok {
+		// return only one batchJob if the events of the destination are not routed to namespaces
+		splitBatches[""] = batchJobs
+		return splitBatches
+	}
+
+	// split batchJobs based on the namespace the events are routed to
+	for _, job := range batchJobs.Jobs {
+		namespace := routing.RouteEvent(brt.destType, job.EventPayload)
+
+		// create batchJob for namespace if it does not exist
+		if _, ok := splitBatches[namespace]; !ok {
+			splitBatches[namespace] = &BatchJobsT{
+				Jobs:             make([]*jobsdb.JobT, 0),
+				BatchDestination: batchJobs.BatchDestination,
+				TimeWindow:       batchJobs.TimeWindow,
+				Namespace:        namespace,
+			}
+		}
+
+		splitBatches[namespace].Jobs = append(splitBatches[namespace].Jobs, job)
+	}
+	return splitBatches
+}
+
 func (brt *HandleT) collectMetrics(ctx context.Context) {
 	if !diagnostics.EnableBatchRouterMetric {
 		return
@@ -2256,7 +2174,6 @@ func loadConfig() {
 	config.RegisterDurationConfigVariable(2, &mainLoopSleep, true, time.Second, []string{"BatchRouter.mainLoopSleep", "BatchRouter.mainLoopSleepInS"}...)
 	config.RegisterInt64ConfigVariable(30, &uploadFreqInS, true, 1, "BatchRouter.uploadFreqInS")
 	objectStorageDestinations = []string{"S3", "GCS", "AZURE_BLOB", "MINIO", "DIGITAL_OCEAN_SPACES"}
-	asyncDestinations = []string{"MARKETO_BULK_UPLOAD"}
 	warehouseURL = misc.GetWarehouseURL()
 	// Time period for diagnosis ticker
 	config.RegisterDurationConfigVariable(600, &diagnosisTickerTime, false, time.Second, []string{"Diagnostics.batchRouterTimePeriod", "Diagnostics.batchRouterTimePeriodInS"}...)
@@ -2265,7 +2182,6 @@ func loadConfig() {
 	config.RegisterBoolConfigVariable(true, &readPerDestination, false, "BatchRouter.readPerDestination")
 	config.RegisterStringConfigVariable("", &toAbortDestinationIDs, true, "BatchRouter.toAbortDestinationIDs")
 	config.RegisterDurationConfigVariable(10, &netClientTimeout, false, time.Second, "BatchRouter.httpTimeout")
-	transformerURL = config.GetString("DEST_TRANSFORM_URL", "http://localhost:9090")
 	config.RegisterStringConfigVariable("", &datePrefixOverride, true, "BatchRouter.datePrefixOverride")
 	dateFormatLayouts = map[string]string{
 		"01-02-2006": "MM-DD-YYYY",
@@ -2312,6 +2228,13 @@ func (brt *HandleT) Setup(backendConfig backendconfig.BackendConfig, jobsDB, err
 	brt.errorDB = errorDB
 	brt.isEnabled = true
 	brt.asyncDestinationStruct = make(map[string]*asyncdestinationmanager.AsyncDestinationStruct)
+	if IsAsyncDestination(destType) {
+		asyncDestinationManager, err := asyncdestinationmanager.NewManager(destType)
+		if err != nil {
+			panic(err)
+		}
+		brt.asyncDestinationManager = asyncDestinationManager
+	}
 	brt.noOfWorkers = getBatchRouterConfigInt("noOfWorkers", destType, 8)
 	config.RegisterDurationConfigVariable(10, &brt.pollStatusLoopSleep, true, time.Second, []string{"BatchRouter." + brt.destType + "." + "pollStatusLoopSleep", "BatchRouter.pollStatusLoopSleep"}...)
 	config.RegisterIntConfigVariable(100000, &brt.jobQueryBatchSize, true, 1, []string{"BatchRouter." + brt.destType + "." + "jobQueryBatchSize", "BatchRouter.jobQueryBatchSize"}...)
@@ -2323,6 +2246,7 @@ func (brt *HandleT) Setup(backendConfig backendconfig.BackendConfig, jobsDB, err
 	config.RegisterIntConfigVariable(3, &brt.jobdDBMaxRetries, true, 1, []string{"JobsDB.BatchRouter.MaxRetries", "JobsDB.MaxRetries"}...)
 	config.RegisterDurationConfigVariable(60, &brt.jobdDBQueryRequestTimeout, true, time.Second, []string{"JobsDB.BatchRouter.QueryRequestTimeout", "JobsDB.QueryRequestTimeout"}...)
 	config.RegisterDurationConfigVariable(90, &brt.jobsDBCommandTimeout, true, time.Second, []string{"JobsDB.BatchRouter.CommandRequestTimeout", "JobsDB.CommandRequestTimeout"}...)
+	config.RegisterStringConfigVariable(warehouseutils.StagingFileFormatJSON, &brt.warehouseStagingFileFormat, true, []string{"BatchRouter." + brt.destType + "." + "warehouseStagingFileFormat", "BatchRouter.warehouseStagingFileFormat"}...)
 	brt.uploadIntervalMap = map[string]time.Duration{}
 
 	tr := &http.Transport{}
@@ -290,6 +290,56 @@ var _ = Describe("BatchRouter", func() {
 	})
 })
 
+var _ = Describe("BatchRouter namespace routing", func() {
+	Context("splitBatchJobsOnNamespace", func() {
+		batchJobs := func(config map[string]interface{}) *BatchJobsT {
+			return &BatchJobsT{
+				Jobs: []*jobsdb.JobT{
+					{JobID: 1, EventPayload: []byte(`{"metadata":{"table":"tracks"},"data":{"context_tenant":"acme"}}`)},
+					{JobID: 2, EventPayload: []byte(`{"metadata":{"table":"tracks"},"data":{"context_tenant":"globex"}}`)},
+					{JobID: 3, EventPayload: []byte(`{"metadata":{"table":"users"},"data":{"id":"1"}}`)},
+					{JobID: 4, EventPayload: []byte(`{"metadata":{"table":"tracks"},"data":{"context_tenant":"acme"}}`)},
+				},
+				BatchDestination: &DestinationT{Destination: backendconfig.DestinationT{Config: config}},
+			}
+		}
+
+		It("should not split batchJobs without namespace routing", func() {
+			brt := &HandleT{destType: "POSTGRES"}
+			splitBatchJobs := brt.splitBatchJobsOnNamespace(batchJobs(map[string]interface{}{}))
+			Expect(splitBatchJobs).To(HaveLen(1))
+			Expect(splitBatchJobs[""].Jobs).To(HaveLen(4))
+		})
+
+		It("should split batchJobs based on the routed namespace", func() {
+			brt := &HandleT{destType: "POSTGRES"}
+			splitBatchJobs := brt.splitBatchJobsOnNamespace(batchJobs(map[string]interface{}{
+				"namespaceRoutingField":         "context.tenant",
+				"namespaceRoutingAllowedValues": []interface{}{"acme", "globex"},
+			}))
+			Expect(splitBatchJobs).To(HaveLen(3))
+			for namespace, jobIDs := range map[string][]int64{"acme": {1, 4}, "globex": {2}, "": {3}} {
+				Expect(splitBatchJobs[namespace].Namespace).To(Equal(namespace))
+				Expect(splitBatchJobs[namespace].Jobs).To(HaveLen(len(jobIDs)))
+				for i, job := range splitBatchJobs[namespace].Jobs {
+					Expect(job.JobID).To(Equal(jobIDs[i]))
+				}
+			}
+		})
+
+		It("should keep batchJobs with values that are not allowed in the default namespace", func() {
+			brt := &HandleT{destType: "POSTGRES"}
+			splitBatchJobs := brt.splitBatchJobsOnNamespace(batchJobs(map[string]interface{}{
+				"namespaceRoutingField":         "context.tenant",
+				"namespaceRoutingAllowedValues": []interface{}{"acme"},
+			}))
+			Expect(splitBatchJobs).To(HaveLen(2))
+			Expect(splitBatchJobs["acme"].Jobs).To(HaveLen(2))
+			Expect(splitBatchJobs[""].Jobs).To(HaveLen(2))
+		})
+	})
+})
+
 func assertJobStatus(job *jobsdb.JobT, status *jobsdb.JobStatusT, expectedState, errorResponse string, attemptNum int) {
 	Expect(status.JobID).To(Equal(job.JobID))
 	Expect(status.JobState).To(Equal(expectedState))
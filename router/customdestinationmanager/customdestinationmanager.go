@@ -42,6 +42,17 @@ type DestinationManager interface {
 	BackendConfigInitialized() <-chan struct{}
 }
 
+// TransactionalDestinationManager is implemented by destination managers able to deliver events within
+// transactions, one per partition of users, see common.TransactionalStreamProducer
+type TransactionalDestinationManager interface {
+	DestinationManager
+	IsTransactional(destID string) bool
+	TransactionPartition(destID, userID string) (int, error)
+	BeginTransaction(destID string, partition int) error
+	SendDataInTransaction(jsonData json.RawMessage, destID string, partition int, jobIDs []int64) (int, string)
+	CommitTransaction(destID string, partition int) error
+}
+
 // CustomManagerT handles this module
 type CustomManagerT struct {
 	destType    string
@@ -76,7 +87,7 @@ func Init() {
 }
 
 func loadConfig() {
-	ObjectStreamDestinations = []string{"KINESIS", "KAFKA", "AZURE_EVENT_HUB", "FIREHOSE", "EVENTBRIDGE", "GOOGLEPUBSUB", "CONFLUENT_CLOUD", "PERSONALIZE", "GOOGLESHEETS", "BQSTREAM", "LAMBDA"}
+	ObjectStreamDestinations = []string{"KINESIS", "KAFKA", "AZURE_EVENT_HUB", "FIREHOSE", "EVENTBRIDGE", "GOOGLEPUBSUB", "CONFLUENT_CLOUD", "PERSONALIZE", "GOOGLESHEETS", "BQSTREAM", "LAMBDA", "NATS_JETSTREAM", "RABBITMQ", "REDIS_STREAMS"}
 	KVStoreDestinations = []string{"REDIS"}
 	Destinations = append(ObjectStreamDestinations, KVStoreDestinations...)
 	config.RegisterBoolConfigVariable(false, &disableEgress, false, "disableEgress")
@@ -151,11 +162,37 @@ func (customManager *CustomManagerT) SendData(jsonData json.RawMessage, destID s
 		return 200, `200: outgoing disabled`
 	}
 
+	customDestination, clientLock, statusCode, errMsg := customManager.getClient(destID)
+	if customDestination == nil {
+		return statusCode, errMsg
+	}
+
+	respStatusCode, respBody := customManager.send(jsonData, customDestination.client, customDestination.config)
+
+	if respStatusCode == CLIENT_EXPIRED_CODE {
+		clientLock.Lock()
+		err := customManager.refreshClient(destID)
+		clientLock.Unlock()
+		if err != nil {
+			return 400, fmt.Sprintf("[CDM %s] Unable to refresh client for %s %s", customManager.destType, destID, err.Error())
+		}
+		clientLock.RLock()
+		customDestination = customManager.client[destID]
+		clientLock.RUnlock()
+		respStatusCode, respBody = customManager.send(jsonData, customDestination.client, customDestination.config)
+	}
+
+	return respStatusCode, respBody
+}
+
+// getClient returns the client of the destination along with its lock, creating the client if needed.
+// If the client is not available a status code and an error message are returned instead.
+func (customManager *CustomManagerT) getClient(destID string) (*clientHolder, *sync.RWMutex, int, string) {
 	customManager.stateMu.RLock()
 	clientLock, ok := customManager.clientMu[destID]
 	customManager.stateMu.RUnlock()
 	if !ok {
-		return 500, fmt.Sprintf("[CDM %s] Unexpected state: Lock missing for %s. Config might not have been updated. Please wait for a min before sending events.", customManager.destType, destID)
+		return nil, nil, 500, fmt.Sprintf("[CDM %s] Unexpected state: Lock missing for %s. Config might not have been updated. Please wait for a min before sending events.", customManager.destType, destID)
 	}
 
 	clientLock.RLock()
@@ -170,29 +207,80 @@ func (customManager *CustomManagerT) SendData(jsonData json.RawMessage, destID s
 		}
 		clientLock.Unlock()
 		if err != nil {
-			return 400, fmt.Sprintf("[CDM %s] Unable to create client for %s %s", customManager.destType, destID, err.Error())
+			return nil, nil, 400, fmt.Sprintf("[CDM %s] Unable to create client for %s %s", customManager.destType, destID, err.Error())
 		}
 		clientLock.RLock()
 		customDestination = customManager.client[destID]
 	}
 	clientLock.RUnlock()
 
-	respStatusCode, respBody := customManager.send(jsonData, customDestination.client, customDestination.config)
+	return customDestination, clientLock, 0, ""
+}
 
-	if respStatusCode == CLIENT_EXPIRED_CODE {
-		clientLock.Lock()
-		err := customManager.refreshClient(destID)
-		clientLock.Unlock()
-		if err != nil {
-			return 400, fmt.Sprintf("[CDM %s] Unable to refresh client for %s %s", customManager.destType, destID, err.Error())
-		}
-		clientLock.RLock()
-		customDestination = customManager.client[destID]
-		clientLock.RUnlock()
-		respStatusCode, respBody = customManager.send(jsonData, customDestination.client, customDestination.config)
+// IsTransactional returns true if the events of the destination are delivered within transactions
+func (customManager *CustomManagerT) IsTransactional(destID string) bool {
+	if disableEgress || customManager.managerType != STREAM {
+		return false
 	}
+	customDestination, _, _, _ := customManager.getClient(destID)
+	if customDestination == nil {
+		return false
+	}
+	producer, ok := customDestination.client.(common.TransactionalStreamProducer)
+	return ok && producer.IsTransactional()
+}
 
-	return respStatusCode, respBody
+// TransactionPartition returns the partition of the transactions delivering the events of the user
+func (customManager *CustomManagerT) TransactionPartition(destID, userID string) (int, error) {
+	producer, err := customManager.getTransactionalProducer(destID)
+	if err != nil {
+		return 0, err
+	}
+	return producer.TransactionPartition(userID), nil
+}
+
+// BeginTransaction opens a new transaction for the partition
+func (customManager *CustomManagerT) BeginTransaction(destID string, partition int) error {
+	producer, err := customManager.getTransactionalProducer(destID)
+	if err != nil {
+		return err
+	}
+	return producer.BeginTransaction(partition)
+}
+
+// SendDataInTransaction sends the data of the jobs within the open transaction of the partition
+func (customManager *CustomManagerT) SendDataInTransaction(jsonData json.RawMessage, destID string, partition int, jobIDs []int64) (int, string) {
+	customDestination, _, statusCode, errMsg := customManager.getClient(destID)
+	if customDestination == nil {
+		return statusCode, errMsg
+	}
+	producer, ok := customDestination.client.(common.TransactionalStreamProducer)
+	if !ok {
+		return 500, fmt.Sprintf("[CDM %s] Transactions are not supported for %s", customManager.destType, destID)
+	}
+	statusCode, _, respBody := producer.ProduceInTransaction(partition, jobIDs, jsonData, customDestination.config)
+	return statusCode, respBody
+}
+
+// CommitTransaction commits the open transaction of the partition
+func (customManager *CustomManagerT) CommitTransaction(destID string, partition int) error {
+	producer, err := customManager.getTransactionalProducer(destID)
+	if err != nil {
+		return err
+	}
+	return producer.CommitTransaction(partition)
+}
+
+func (customManager *CustomManagerT) getTransactionalProducer(destID string) (common.TransactionalStreamProducer, error) {
+	customDestination, _, _, errMsg := customManager.getClient(destID)
+	if customDestination == nil {
+		return nil, errors.New(errMsg)
+	}
+	producer, ok := customDestination.client.(common.TransactionalStreamProducer)
+	if !ok {
+		return nil, fmt.Errorf("[CDM %s] Transactions are not supported for %s", customManager.destType, destID)
+	}
+	return producer, nil
 }
 
 func (customManager *CustomManagerT) close(destID string) {
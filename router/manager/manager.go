@@ -18,8 +18,7 @@ import (
 
 var (
 	objectStorageDestinations = []string{"S3", "GCS", "AZURE_BLOB", "MINIO", "DIGITAL_OCEAN_SPACES"}
-	asyncDestinations         = []string{"MARKETO_BULK_UPLOAD"}
-	warehouseDestinations     = []string{"RS", "BQ", "SNOWFLAKE", "POSTGRES", "CLICKHOUSE", "MSSQL", "AZURE_SYNAPSE", "S3_DATALAKE", "GCS_DATALAKE", "AZURE_DATALAKE", "DELTALAKE"}
+	warehouseDestinations     = []string{"RS", "BQ", "SNOWFLAKE", "POSTGRES", "CLICKHOUSE", "MSSQL", "AZURE_SYNAPSE", "S3_DATALAKE", "GCS_DATALAKE", "AZURE_DATALAKE", "DELTALAKE", "SQLITE"}
 	pkgLogger                 = logger.NewLogger().Child("router")
 )
 
@@ -117,7 +116,7 @@ loop:
 						// For batch router destinations
 						if misc.Contains(objectStorageDestinations, destination.DestinationDefinition.Name) ||
 							misc.Contains(warehouseDestinations, destination.DestinationDefinition.Name) ||
-							misc.Contains(asyncDestinations, destination.DestinationDefinition.Name) {
+							batchrouter.IsAsyncDestination(destination.DestinationDefinition.Name) {
 							_, ok := dstToBatchRouter[destination.DestinationDefinition.Name]
 							if !ok {
 								pkgLogger.Infof("Starting a new Batch Destination Router: %s", destination.DestinationDefinition.Name)
@@ -577,6 +577,7 @@ func (worker *workerT) processDestinationJobs() {
 	failedUserIDsMap := make(map[string]struct{})
 	apiCallsCount := make(map[string]*destJobCountsT)
 	routerJobResponses := make([]*JobResponse, 0)
+	transactions := newWorkerTransactions()
 
 	sort.Slice(worker.destinationJobs, func(i, j int) bool {
 		return worker.destinationJobs[i].JobMetadataArray[0].JobID < worker.destinationJobs[j].JobMetadataArray[0].JobID
@@ -632,7 +633,7 @@ func (worker *workerT) processDestinationJobs() {
 							panic(fmt.Errorf("different destinations are grouped together"))
 						}
 					}
-					respStatusCode, respBody = worker.rt.customDestinationManager.SendData(destinationJob.Message, destinationID)
+					respStatusCode, respBody = worker.sendToCustomDestination(&destinationJob, destinationID, transactions)
 					errorAt = routerutils.ERROR_AT_CUST
 				} else {
 					result, err := getIterableStruct(destinationJob.Message, transformAt)
@@ -790,6 +791,8 @@ func (worker *workerT) processDestinationJobs() {
 		}
 	}
 
+	worker.commitTransactions(transactions, routerJobResponses)
+
 	sort.Slice(routerJobResponses, func(i, j int) bool {
 		return routerJobResponses[i].jobID < routerJobResponses[j].jobID
 	})
@@ -877,6 +880,104 @@ func (worker *workerT) processDestinationJobs() {
 	worker.jobCountsByDestAndUser = make(map[string]*destJobCountsT)
 }
 
+// transactionKey identifies a transaction opened by a worker for delivering events exactly once
+type transactionKey struct {
+	destinationID string
+	partition     int
+}
+
+// workerTransactions are the transactions of a worker delivering a batch of jobs exactly once.
+// Since a partition can have a single open transaction, a worker holds at most one partition at a time:
+// the open transaction is committed before the one of another partition is opened, so that workers never
+// wait for a partition while holding another one.
+type workerTransactions struct {
+	open   *transactionKey
+	jobIDs []int64                  // jobs sent within the open transaction
+	failed map[transactionKey]error // partitions with a transaction which could not be committed in the batch
+	jobs   map[int64]error          // jobs sent within the transactions which could not be committed
+}
+
+func newWorkerTransactions() *workerTransactions {
+	return &workerTransactions{
+		failed: make(map[transactionKey]error),
+		jobs:   make(map[int64]error),
+	}
+}
+
+// sendToCustomDestination sends the job to the custom destination. For destinations delivering events exactly once,
+// the job is sent within the transaction of its destination and partition, which stays open for the following jobs
+// of the same partition. The partition of a job is determined by its user, independently of the worker.
+// Once a transaction of a partition fails to commit, the following jobs of the partition in the batch are failed
+// as well, so that the events of a user are not delivered out of order.
+func (worker *workerT) sendToCustomDestination(destinationJob *types.DestinationJobT, destinationID string, transactions *workerTransactions) (int, string) {
+	txManager, ok := worker.rt.customDestinationManager.(customDestinationManager.TransactionalDestinationManager)
+	if !ok || !txManager.IsTransactional(destinationID) {
+		return worker.rt.customDestinationManager.SendData(destinationJob.Message, destinationID)
+	}
+
+	var userID string
+	if len(destinationJob.JobMetadataArray) > 0 {
+		userID = destinationJob.JobMetadataArray[0].UserID
+	}
+	partition, err := txManager.TransactionPartition(destinationID, userID)
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Sprintf("could not get transaction partition: %v", err)
+	}
+	key := transactionKey{destinationID: destinationID, partition: partition}
+	if err, ok := transactions.failed[key]; ok {
+		return http.StatusInternalServerError, fmt.Sprintf("transaction commit failed: %v", err)
+	}
+	if transactions.open == nil || *transactions.open != key {
+		worker.commitTransaction(transactions)
+		if err := txManager.BeginTransaction(destinationID, partition); err != nil {
+			return http.StatusInternalServerError, fmt.Sprintf("could not begin transaction: %v", err)
+		}
+		transactions.open = &key
+	}
+	jobIDs := make([]int64, len(destinationJob.JobMetadataArray))
+	for i := range destinationJob.JobMetadataArray {
+		jobIDs[i] = destinationJob.JobMetadataArray[i].JobID
+	}
+	transactions.jobIDs = append(transactions.jobIDs, jobIDs...)
+	return txManager.SendDataInTransaction(destinationJob.Message, destinationID, partition, jobIDs)
+}
+
+// commitTransaction commits the open transaction of the worker, if any, releasing its partition
+func (worker *workerT) commitTransaction(transactions *workerTransactions) {
+	if transactions.open == nil {
+		return
+	}
+	key, jobIDs := *transactions.open, transactions.jobIDs
+	transactions.open, transactions.jobIDs = nil, nil
+
+	txManager := worker.rt.customDestinationManager.(customDestinationManager.TransactionalDestinationManager)
+	err := txManager.CommitTransaction(key.destinationID, key.partition)
+	if err == nil {
+		return
+	}
+	worker.rt.logger.Errorf("[%v Router] :: Committing transaction of partition %d for destination %s failed: %v", worker.rt.destName, key.partition, key.destinationID, err)
+	transactions.failed[key] = err
+	for _, jobID := range jobIDs {
+		transactions.jobs[jobID] = err
+	}
+}
+
+// commitTransactions commits the last transaction opened by sendToCustomDestination before the job statuses are built,
+// so that jobs are marked as succeeded only if their events are part of a committed transaction.
+func (worker *workerT) commitTransactions(transactions *workerTransactions, routerJobResponses []*JobResponse) {
+	worker.commitTransaction(transactions)
+	if len(transactions.jobs) == 0 {
+		return
+	}
+	for _, routerJobResponse := range routerJobResponses {
+		err, ok := transactions.jobs[routerJobResponse.jobID]
+		if ok && isSuccessStatus(routerJobResponse.respStatusCode) {
+			routerJobResponse.respStatusCode = http.StatusInternalServerError
+			routerJobResponse.respBody = fmt.Sprintf("transaction commit failed: %v", err)
+		}
+	}
+}
+
 func (worker *workerT) canSendJobToDestination(prevRespStatusCode int, failedUserIDsMap map[string]struct{}, destinationJob *types.DestinationJobT) bool {
 	if prevRespStatusCode == 0 {
 		return true
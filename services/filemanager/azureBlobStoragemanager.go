@@ -192,6 +192,9 @@ func (manager *AzureBlobStorageManager) Download(ctx context.Context, output *os
 	// Here's how to download the blob
 	downloadResponse, err := blobURL.Download(ctx, 0, azblob.CountToEnd, azblob.BlobAccessConditions{}, false, azblob.ClientProvidedKeyOptions{})
 	if err != nil {
+		if storageError, ok := err.(azblob.StorageError); ok && storageError.ServiceCode() == azblob.ServiceCodeBlobNotFound {
+			return ErrKeyNotFound
+		}
 		return err
 	}
 
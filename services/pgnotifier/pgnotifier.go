@@ -28,6 +28,7 @@ var (
 	trackBatchInterval time.Duration
 	maxPollSleep       time.Duration
 	jobOrphanTimeout   time.Duration
+	backend            string
 	pkgLogger          logger.Logger
 )
 
@@ -52,12 +53,64 @@ const (
 	UploadJobType = "upload"
 )
 
+// backends of the work queue between the warehouse master and slaves
+const (
+	PostgresBackend = "postgres"
+	RedisBackend    = "redis"
+)
+
 func Init() {
 	loadPGNotifierConfig()
 	queueName = "pg_notifier_queue"
 	pkgLogger = logger.NewLogger().Child("warehouse").Child("pgnotifier")
 }
 
+// NotifierI is the work queue through which the warehouse master publishes jobs for the slaves to claim.
+// Jobs are claimed by ascending priority, and the jobs claimed by dead slaves are claimed again after PgNotifier.jobOrphanTimeout.
+// Failed jobs are retried until they were attempted PgNotifier.maxAttempt times, after which they are aborted.
+type NotifierI interface {
+	// Publish adds a batch of jobs to the queue, returning a channel which receives the responses of the jobs once all of them succeeded or aborted
+	Publish(ctx context.Context, payload MessagePayload, schema *whUtils.SchemaT, priority int) (ch chan []ResponseT, err error)
+	// Subscribe returns a channel of the jobs claimed for the worker, until the context is done
+	Subscribe(ctx context.Context, workerId string, jobsBufferSize int) chan ClaimT
+	// UpdateClaimedEvent records the outcome of a claimed job
+	UpdateClaimedEvent(claim *ClaimT, response *ClaimResponseT)
+	// RunMaintenanceWorker (blocking) requeues the jobs left behind by dead workers
+	RunMaintenanceWorker(ctx context.Context) error
+	// ClearJobs deletes the jobs published by the workspace
+	ClearJobs(ctx context.Context) error
+	// CheckHealth indicates if the queue can be reached
+	CheckHealth(ctx context.Context) bool
+	// GetQueueStats returns the backlog of the queue, across all the workspaces
+	GetQueueStats(ctx context.Context) (QueueStatsT, error)
+}
+
+// QueueStatsT is the backlog of the queue, from which the number of slaves needed is derived
+type QueueStatsT struct {
+	// WaitingJobs are the jobs waiting to be claimed, including the failed jobs to be retried
+	WaitingJobs int64
+	// ExecutingJobs are the jobs claimed by the slaves
+	ExecutingJobs int64
+	// OldestWaitingJobAge is the time since the oldest waiting job was published, zero without waiting jobs
+	OldestWaitingJobAge time.Duration
+}
+
+// NewNotifier returns the notifier of the backend configured in PgNotifier.backend, postgres by default
+func NewNotifier(workspaceIdentifier, fallbackConnectionInfo string) (NotifierI, error) {
+	switch backend {
+	case PostgresBackend, "":
+		notifier, err := New(workspaceIdentifier, fallbackConnectionInfo)
+		if err != nil {
+			return nil, err
+		}
+		return &notifier, nil
+	case RedisBackend:
+		return NewRedisNotifier(workspaceIdentifier, GetRedisNotifierOptions())
+	default:
+		return nil, fmt.Errorf("unknown pgnotifier backend: %q", backend)
+	}
+}
+
 type PgNotifierT struct {
 	URI                 string
 	dbHandle            *sql.DB
@@ -115,6 +168,21 @@ func loadPGNotifierConfig() {
 	trackBatchInterval = time.Duration(config.GetInt("PgNotifier.trackBatchIntervalInS", 2)) * time.Second
 	config.RegisterDurationConfigVariable(5000, &maxPollSleep, true, time.Millisecond, "PgNotifier.maxPollSleep")
 	config.RegisterDurationConfigVariable(120, &jobOrphanTimeout, true, time.Second, "PgNotifier.jobOrphanTimeout")
+	backend = config.GetString("PgNotifier.backend", PostgresBackend)
+	loadRedisNotifierConfig()
+}
+
+func setupStats() {
+	pgNotifierModuleTag := whUtils.Tag{Name: "module", Value: "pgnotifier"}
+	// publish metrics
+	pgNotifierPublish = whUtils.NewCounterStat("pgnotifier_publish", pgNotifierModuleTag)
+	pgNotifierPublishTime = whUtils.NewTimerStat("pgnotifier_publish_time", pgNotifierModuleTag)
+	// claim metrics
+	pgNotifierClaimSucceeded = whUtils.NewCounterStat("pgnotifier_claim", pgNotifierModuleTag, whUtils.Tag{Name: "status", Value: "succeeded"})
+	pgNotifierClaimFailed = whUtils.NewCounterStat("pgnotifier_claim", pgNotifierModuleTag, whUtils.Tag{Name: "status", Value: "failed"})
+	pgNotifierClaimSucceededTime = whUtils.NewTimerStat("pgnotifier_claim_time", pgNotifierModuleTag, whUtils.Tag{Name: "status", Value: "succeeded"})
+	pgNotifierClaimFailedTime = whUtils.NewTimerStat("pgnotifier_claim_time", pgNotifierModuleTag, whUtils.Tag{Name: "status", Value: "failed"})
+	pgNotifierClaimUpdateFailed = whUtils.NewCounterStat("pgnotifier_claim_update_failed", pgNotifierModuleTag)
 }
 
 // New Given default connection info return pg notifier object from it
@@ -133,16 +201,7 @@ func New(workspaceIdentifier, fallbackConnectionInfo string) (notifier PgNotifie
 	}
 
 	// setup metrics
-	pgNotifierModuleTag := whUtils.Tag{Name: "module", Value: "pgnotifier"}
-	// publish metrics
-	pgNotifierPublish = whUtils.NewCounterStat("pgnotifier_publish", pgNotifierModuleTag)
-	pgNotifierPublishTime = whUtils.NewTimerStat("pgnotifier_publish_time", pgNotifierModuleTag)
-	// claim metrics
-	pgNotifierClaimSucceeded = whUtils.NewCounterStat("pgnotifier_claim", pgNotifierModuleTag, whUtils.Tag{Name: "status", Value: "succeeded"})
-	pgNotifierClaimFailed = whUtils.NewCounterStat("pgnotifier_claim", pgNotifierModuleTag, whUtils.Tag{Name: "status", Value: "failed"})
-	pgNotifierClaimSucceededTime = whUtils.NewTimerStat("pgnotifier_claim_time", pgNotifierModuleTag, whUtils.Tag{Name: "status", Value: "succeeded"})
-	pgNotifierClaimFailedTime = whUtils.NewTimerStat("pgnotifier_claim_time", pgNotifierModuleTag, whUtils.Tag{Name: "status", Value: "failed"})
-	pgNotifierClaimUpdateFailed = whUtils.NewCounterStat("pgnotifier_claim_update_failed", pgNotifierModuleTag)
+	setupStats()
 
 	notifier = PgNotifierT{
 		dbHandle:            dbHandle,
@@ -157,6 +216,19 @@ func (notifier PgNotifierT) GetDBHandle() *sql.DB {
 	return notifier.dbHandle
 }
 
+func (notifier *PgNotifierT) CheckHealth(ctx context.Context) bool {
+	if notifier.dbHandle == nil {
+		return false
+	}
+	rows, err := notifier.dbHandle.QueryContext(ctx, `SELECT 'Rudder PgNotifier Health Check'::text as message`)
+	if err != nil {
+		pkgLogger.Error(err)
+		return false
+	}
+	defer func() { _ = rows.Close() }()
+	return true
+}
+
 func (notifier PgNotifierT) ClearJobs(ctx context.Context) (err error) {
 	// clean up all jobs in pgnotifier for same workspace
 	// additional safety check to not delete all jobs with empty workspaceIdentifier
@@ -180,6 +252,50 @@ func (notifier PgNotifierT) ClearJobs(ctx context.Context) (err error) {
 	return
 }
 
+func (notifier *PgNotifierT) GetQueueStats(ctx context.Context) (queueStats QueueStatsT, err error) {
+	stmt := fmt.Sprintf(`
+		SELECT 
+		  COUNT(*) FILTER (
+			WHERE 
+			  status = '%[2]s' 
+			  OR status = '%[3]s'
+		  ), 
+		  COUNT(*) FILTER (
+			WHERE 
+			  status = '%[4]s'
+		  ), 
+		  COALESCE(
+			EXTRACT(
+			  EPOCH 
+			  FROM 
+				NOW() - MIN(created_at) FILTER (
+				  WHERE 
+					status = '%[2]s' 
+					OR status = '%[3]s'
+				)
+			), 
+			0
+		  ) 
+		FROM 
+		  %[1]s;
+`,
+		queueName,
+		WaitingState,
+		FailedState,
+		ExecutingState,
+	)
+	var oldestWaitingJobAgeInS float64
+	err = notifier.dbHandle.QueryRowContext(ctx, stmt).Scan(&queueStats.WaitingJobs, &queueStats.ExecutingJobs, &oldestWaitingJobAgeInS)
+	if err != nil {
+		err = fmt.Errorf("PgNotifier: Failed to get queue stats: %w", err)
+		return
+	}
+	if oldestWaitingJobAgeInS > 0 {
+		queueStats.OldestWaitingJobAge = time.Duration(oldestWaitingJobAgeInS * float64(time.Second))
+	}
+	return
+}
+
 // CheckForPGNotifierEnvVars Checks if all the required Env Variables for PG Notifier are present
 func CheckForPGNotifierEnvVars() bool {
 	return config.IsSet("PGNOTIFIER_DB_HOST") &&
@@ -496,7 +612,7 @@ func (notifier *PgNotifierT) claim(workerID string) (claim ClaimT, err error) {
 	return claim, nil
 }
 
-func (notifier *PgNotifierT) Publish(payload MessagePayload, schema *whUtils.SchemaT, priority int) (ch chan []ResponseT, err error) {
+func (notifier *PgNotifierT) Publish(ctx context.Context, payload MessagePayload, schema *whUtils.SchemaT, priority int) (ch chan []ResponseT, err error) {
 	publishStartTime := time.Now()
 	jobs := payload.Jobs
 	defer func() {
@@ -509,7 +625,7 @@ func (notifier *PgNotifierT) Publish(payload MessagePayload, schema *whUtils.Sch
 	ch = make(chan []ResponseT)
 
 	// Using transactions for bulk copying
-	txn, err := notifier.dbHandle.Begin()
+	txn, err := notifier.dbHandle.BeginTx(ctx, nil)
 	if err != nil {
 		err = fmt.Errorf("PgNotifier: Failed creating transaction for publishing with error: %w", err)
 		return
@@ -0,0 +1,721 @@
+package pgnotifier
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/gofrs/uuid"
+
+	"github.com/rudderlabs/rudder-server/config"
+	"github.com/rudderlabs/rudder-server/rruntime"
+	"github.com/rudderlabs/rudder-server/services/stats"
+	whUtils "github.com/rudderlabs/rudder-server/warehouse/utils"
+)
+
+const (
+	// consumer group of the slaves claiming jobs from the streams
+	redisConsumerGroup = "workers"
+	// number of pending entries of a stream inspected at once by the maintenance worker
+	redisPendingPageSize = 100
+)
+
+var (
+	pgNotifierRedisAddresses, pgNotifierRedisPassword string
+	pgNotifierRedisDB                                 int
+	maxTrackBatchBackoff                              time.Duration
+)
+
+func loadRedisNotifierConfig() {
+	pgNotifierRedisAddresses = config.GetString("PGNOTIFIER_REDIS_ADDRESS", "localhost:6379")
+	pgNotifierRedisPassword = config.GetString("PGNOTIFIER_REDIS_PASSWORD", "")
+	pgNotifierRedisDB = config.GetInt("PgNotifier.redis.db", 0)
+	maxTrackBatchBackoff = config.GetDuration("PgNotifier.redis.maxTrackBatchBackoff", 60, time.Second)
+}
+
+// RedisNotifierOptions are the options of the redis server or cluster backing the notifier
+type RedisNotifierOptions struct {
+	Addresses []string
+	Password  string
+	DB        int
+}
+
+// GetRedisNotifierOptions returns the redis options configured with PGNOTIFIER_REDIS_ADDRESS, a comma separated list for clusters
+func GetRedisNotifierOptions() RedisNotifierOptions {
+	var addresses []string
+	for _, address := range strings.Split(pgNotifierRedisAddresses, ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			addresses = append(addresses, address)
+		}
+	}
+	return RedisNotifierOptions{
+		Addresses: addresses,
+		Password:  pgNotifierRedisPassword,
+		DB:        pgNotifierRedisDB,
+	}
+}
+
+// RedisNotifierT is a notifier backed by redis streams, one stream for every priority, read by the slaves through a consumer group.
+// The jobs are kept in hashes next to the streams, so that the streams only hold the ids of the jobs waiting to be claimed or being executed.
+// All the keys share the hash tag of the queue, so that the scripts updating them atomically also run on clusters.
+type RedisNotifierT struct {
+	client              redis.UniversalClient
+	workspaceIdentifier string
+}
+
+var (
+	// claimJobScript marks the job of a stream entry as executing by the worker and returns its fields.
+	// Only waiting and failed jobs are claimed: the entries of deleted jobs, and of jobs which completed or are executing, are dropped.
+	claimJobScript = redis.NewScript(`
+redis.replicate_commands()
+local job, stream = KEYS[1], KEYS[2]
+local group, streamID, workerID, now = ARGV[1], ARGV[2], ARGV[3], ARGV[4]
+local status = redis.call('HGET', job, 'status')
+if status ~= 'waiting' and status ~= 'failed' then
+  redis.call('XACK', stream, group, streamID)
+  redis.call('XDEL', stream, streamID)
+  return false
+end
+redis.call('HSET', job, 'status', 'executing', 'updated_at', now, 'last_exec_time', now, 'worker_id', workerID, 'stream', stream, 'stream_id', streamID)
+return redis.call('HMGET', job, 'batch_id', 'status', 'payload', 'workspace', 'attempt', 'job_type')
+`)
+	// updateClaimedJobScript acks the stream entry of a claimed job and records its outcome, requeueing failed jobs and counting down the pending jobs of the batch on completion
+	updateClaimedJobScript = redis.NewScript(`
+redis.replicate_commands()
+local job, pending = KEYS[1], KEYS[2]
+local succeeded, value, maxAttempt, now, group, jobID = ARGV[1] == '1', ARGV[2], tonumber(ARGV[3]), ARGV[4], ARGV[5], ARGV[6]
+if redis.call('EXISTS', job) == 0 then
+  return 0
+end
+local status = redis.call('HGET', job, 'status')
+local stream = redis.call('HGET', job, 'stream')
+local streamID = redis.call('HGET', job, 'stream_id')
+if stream and streamID then
+  redis.call('XACK', stream, group, streamID)
+  redis.call('XDEL', stream, streamID)
+end
+local done = status == 'succeeded' or status == 'aborted'
+local newStatus = 'succeeded'
+if succeeded then
+  redis.call('HSET', job, 'status', newStatus, 'payload', value, 'updated_at', now)
+else
+  local attempt = tonumber(redis.call('HGET', job, 'attempt'))
+  if attempt > maxAttempt then
+    newStatus = 'aborted'
+  else
+    newStatus = 'failed'
+  end
+  redis.call('HSET', job, 'status', newStatus, 'attempt', attempt + 1, 'error', value, 'updated_at', now)
+  if newStatus == 'failed' and stream then
+    redis.call('XADD', stream, '*', 'job_id', jobID)
+  end
+end
+if not done and newStatus ~= 'failed' then
+  redis.call('DECR', pending)
+end
+return 1
+`)
+	// requeueJobScript puts back in the stream of its priority a job left behind by a dead worker
+	requeueJobScript = redis.NewScript(`
+redis.replicate_commands()
+local job, stream = KEYS[1], KEYS[2]
+local group, streamID, jobID, now = ARGV[1], ARGV[2], ARGV[3], ARGV[4]
+redis.call('XACK', stream, group, streamID)
+redis.call('XDEL', stream, streamID)
+if redis.call('HGET', job, 'status') ~= 'executing' then
+  return 0
+end
+redis.call('HSET', job, 'status', 'waiting', 'updated_at', now)
+redis.call('XADD', stream, '*', 'job_id', jobID)
+return 1
+`)
+)
+
+// NewRedisNotifier returns a notifier backed by redis streams
+func NewRedisNotifier(workspaceIdentifier string, opts RedisNotifierOptions) (*RedisNotifierT, error) {
+	if len(opts.Addresses) == 0 {
+		return nil, errors.New("PgNotifier: redis address is required")
+	}
+	pkgLogger.Infof("PgNotifier: Initializing redis notifier...")
+	setupStats()
+	notifier := &RedisNotifierT{
+		client: redis.NewUniversalClient(&redis.UniversalOptions{
+			Addrs:    opts.Addresses,
+			Password: opts.Password,
+			DB:       opts.DB,
+		}),
+		workspaceIdentifier: workspaceIdentifier,
+	}
+	if err := notifier.client.Ping().Err(); err != nil {
+		return nil, fmt.Errorf("PgNotifier: Failed connecting to redis: %w", err)
+	}
+	return notifier, nil
+}
+
+func (*RedisNotifierT) key(parts ...string) string {
+	return fmt.Sprintf("{%s}:%s", queueName, strings.Join(parts, ":"))
+}
+
+func (notifier *RedisNotifierT) jobKey(jobID int64) string {
+	return notifier.key("job", strconv.FormatInt(jobID, 10))
+}
+
+func (notifier *RedisNotifierT) streamKey(priority int) string {
+	return notifier.key("stream", strconv.Itoa(priority))
+}
+
+func (notifier *RedisNotifierT) CheckHealth(context.Context) bool {
+	if err := notifier.client.Ping().Err(); err != nil {
+		pkgLogger.Error(err)
+		return false
+	}
+	return true
+}
+
+func (notifier *RedisNotifierT) Publish(ctx context.Context, payload MessagePayload, schema *whUtils.SchemaT, priority int) (ch chan []ResponseT, err error) {
+	publishStartTime := time.Now()
+	jobs := payload.Jobs
+	defer func() {
+		if err == nil {
+			pgNotifierPublishTime.Since(publishStartTime)
+			pgNotifierPublish.Increment()
+		}
+	}()
+
+	ch = make(chan []ResponseT)
+	stream := notifier.streamKey(priority)
+	if err = notifier.client.XGroupCreateMkStream(stream, redisConsumerGroup, "0").Err(); err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		err = fmt.Errorf("PgNotifier: Failed creating consumer group for publishing with error: %w", err)
+		return
+	}
+
+	uploadSchemaJSON, err := json.Marshal(*schema)
+	if err != nil {
+		err = fmt.Errorf("PgNotifier: Failed marshalling uploadschema for publishing with error: %w", err)
+		return
+	}
+	lastJobID, err := notifier.client.IncrBy(notifier.key("job_id"), int64(len(jobs))).Result()
+	if err != nil {
+		err = fmt.Errorf("PgNotifier: Failed generating job ids for publishing with error: %w", err)
+		return
+	}
+
+	batchID := uuid.Must(uuid.NewV4()).String()
+	batchKey := notifier.key("batch", batchID)
+	pkgLogger.Infof("PgNotifier: Inserting %d records into %s as batch: %s", len(jobs), queueName, batchID)
+	_, err = notifier.client.TxPipelined(func(pipe redis.Pipeliner) error {
+		for idx, job := range jobs {
+			jobID := lastJobID - int64(len(jobs)) + int64(idx) + 1
+			// same as the upload schema merged into the payload of the jobs in postgres
+			jobPayload := map[string]json.RawMessage{}
+			if err := json.Unmarshal(job, &jobPayload); err != nil {
+				return err
+			}
+			jobPayload["UploadSchema"] = uploadSchemaJSON
+			jobPayloadJSON, err := json.Marshal(jobPayload)
+			if err != nil {
+				return err
+			}
+			pipe.HMSet(notifier.jobKey(jobID), map[string]interface{}{
+				"batch_id":   batchID,
+				"status":     WaitingState,
+				"payload":    string(jobPayloadJSON),
+				"workspace":  notifier.workspaceIdentifier,
+				"priority":   priority,
+				"attempt":    0,
+				"job_type":   payload.JobType,
+				"stream":     stream,
+				"created_at": GetCurrentSQLTimestamp(),
+			})
+			pipe.RPush(batchKey, jobID)
+			pipe.XAdd(&redis.XAddArgs{Stream: stream, ID: "*", Values: map[string]interface{}{"job_id": jobID}})
+		}
+		pipe.Set(notifier.key("batch", batchID, "pending"), len(jobs), 0)
+		pipe.SAdd(notifier.key("workspace", notifier.workspaceIdentifier, "batches"), batchID)
+		pipe.ZAdd(notifier.key("priorities"), redis.Z{Score: float64(priority), Member: stream})
+		return nil
+	})
+	if err != nil {
+		err = fmt.Errorf("PgNotifier: Failed publishing with error: %w", err)
+		return
+	}
+
+	pkgLogger.Infof("PgNotifier: Inserted %d records into %s as batch: %s", len(jobs), queueName, batchID)
+	stats.Default.NewTaggedStat("pg_notifier_insert_records", stats.CountType, map[string]string{
+		"queueName": queueName,
+		"module":    "pg_notifier",
+	}).Count(len(jobs))
+	notifier.trackBatch(ctx, batchID, payload.JobType, &ch)
+	return
+}
+
+// trackBatch tracks the batch until all of its jobs succeeded or aborted and triggers output through channel of type ResponseT,
+// with the same responses as trackUploadBatch and trackAsyncBatch for the jobs in postgres.
+// Redis errors are retried with an exponential backoff, and the channel is closed without responses once ctx is cancelled.
+func (notifier *RedisNotifierT) trackBatch(ctx context.Context, batchID, jobType string, ch *chan []ResponseT) {
+	rruntime.GoForWarehouse(func() {
+		pendingKey := notifier.key("batch", batchID, "pending")
+		interval := trackBatchInterval
+		for {
+			select {
+			case <-ctx.Done():
+				pkgLogger.Infof("PgNotifier: Stopped tracking batch: %s", batchID)
+				close(*ch)
+				return
+			case <-time.After(interval):
+			}
+			pending, err := notifier.client.Get(pendingKey).Int64()
+			if err == redis.Nil {
+				// the jobs of the batch were cleared
+				*ch <- nil
+				return
+			}
+			if err != nil {
+				pkgLogger.Errorf("PgNotifier: Failed tracking jobs of batch: %s, error: %v", batchID, err)
+				if jobType == AsyncJobType {
+					*ch <- nil
+					return
+				}
+				interval = trackBatchBackoff(interval)
+				continue
+			}
+			interval = trackBatchInterval
+			if pending > 0 {
+				pkgLogger.Debugf("PgNotifier: Pending %d files to process in batch: %s", pending, batchID)
+				continue
+			}
+
+			responses, jobKeys, err := notifier.batchResponses(batchID, jobType)
+			if err != nil {
+				pkgLogger.Errorf("PgNotifier: Failed getting jobs of batch: %s, error: %v", batchID, err)
+				if jobType == AsyncJobType {
+					*ch <- responses
+					return
+				}
+				interval = trackBatchBackoff(interval)
+				continue
+			}
+			*ch <- responses
+			pkgLogger.Infof("PgNotifier: Completed processing all files  in batch: %s", batchID)
+			if err := notifier.deleteBatch(batchID, jobKeys); err != nil {
+				pkgLogger.Errorf("PgNotifier: Error deleting batch_id:%s : %v", batchID, err)
+			}
+			return
+		}
+	})
+}
+
+// trackBatchBackoff doubles the interval between the attempts to track a batch, up to PgNotifier.redis.maxTrackBatchBackoff
+func trackBatchBackoff(interval time.Duration) time.Duration {
+	interval = 2*interval + time.Duration(rand.Intn(100))*time.Millisecond
+	if interval > maxTrackBatchBackoff {
+		interval = maxTrackBatchBackoff
+	}
+	return interval
+}
+
+func (notifier *RedisNotifierT) batchResponses(batchID, jobType string) (responses []ResponseT, jobKeys []string, err error) {
+	jobIDs, err := notifier.client.LRange(notifier.key("batch", batchID), 0, -1).Result()
+	if err != nil {
+		return
+	}
+	pipe := notifier.client.Pipeline()
+	cmds := make([]*redis.SliceCmd, len(jobIDs))
+	for idx, jobID := range jobIDs {
+		jobKeys = append(jobKeys, notifier.key("job", jobID))
+		cmds[idx] = pipe.HMGet(jobKeys[idx], "payload", "status", "error")
+	}
+	if _, err = pipe.Exec(); err != nil && err != redis.Nil {
+		return
+	}
+	err = nil
+	for _, cmd := range cmds {
+		values := cmd.Val()
+		payload, _ := values[0].(string)
+		status, _ := values[1].(string)
+		jobError, _ := values[2].(string)
+		response := ResponseT{
+			Output: json.RawMessage(payload),
+			Status: status,
+			Error:  jobError,
+		}
+		if jobType != AsyncJobType {
+			var uploadPayload struct {
+				StagingFileID int64
+				Output        json.RawMessage
+			}
+			_ = json.Unmarshal([]byte(payload), &uploadPayload)
+			response.JobID = uploadPayload.StagingFileID
+			response.Output = uploadPayload.Output
+		}
+		responses = append(responses, response)
+	}
+	return
+}
+
+func (notifier *RedisNotifierT) deleteBatch(batchID string, jobKeys []string) error {
+	keys := append(jobKeys, notifier.key("batch", batchID), notifier.key("batch", batchID, "pending"))
+	_, err := notifier.client.TxPipelined(func(pipe redis.Pipeliner) error {
+		pipe.Del(keys...)
+		pipe.SRem(notifier.key("workspace", notifier.workspaceIdentifier, "batches"), batchID)
+		return nil
+	})
+	return err
+}
+
+func (notifier *RedisNotifierT) ClearJobs(context.Context) error {
+	// additional safety check to not delete all jobs with empty workspaceIdentifier
+	if notifier.workspaceIdentifier == "" {
+		return nil
+	}
+	pkgLogger.Infof("PgNotifier: Deleting all jobs for workspace: %s", notifier.workspaceIdentifier)
+	batchIDs, err := notifier.client.SMembers(notifier.key("workspace", notifier.workspaceIdentifier, "batches")).Result()
+	if err != nil {
+		return err
+	}
+	// the entries of the deleted jobs in the streams are dropped when claimed
+	for _, batchID := range batchIDs {
+		jobIDs, err := notifier.client.LRange(notifier.key("batch", batchID), 0, -1).Result()
+		if err != nil {
+			return err
+		}
+		var jobKeys []string
+		for _, jobID := range jobIDs {
+			jobKeys = append(jobKeys, notifier.key("job", jobID))
+		}
+		if err := notifier.deleteBatch(batchID, jobKeys); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (notifier *RedisNotifierT) UpdateClaimedEvent(claim *ClaimT, response *ClaimResponseT) {
+	succeeded, value := "1", string(response.Payload)
+	if response.Err != nil {
+		pkgLogger.Error(response.Err.Error())
+		succeeded, value = "0", response.Err.Error()
+	}
+	err := updateClaimedJobScript.Run(
+		notifier.client,
+		[]string{notifier.jobKey(claim.ID), notifier.key("batch", claim.BatchID, "pending")},
+		succeeded, value, maxAttempt, GetCurrentSQLTimestamp(), redisConsumerGroup, claim.ID,
+	).Err()
+	if err != nil && err != redis.Nil {
+		pgNotifierClaimUpdateFailed.Increment()
+		pkgLogger.Errorf("PgNotifier: Failed to update claimed event: %v", err)
+	}
+
+	// Sending stats when we mark pg_notifier status as aborted.
+	if response.Err != nil && claim.Attempt > maxAttempt {
+		stats.Default.NewTaggedStat("pg_notifier_aborted_records", stats.CountType, map[string]string{
+			"queueName": queueName,
+			"workspace": claim.Workspace,
+			"module":    "pg_notifier",
+		}).Increment()
+	}
+}
+
+// claim reads the next job from the streams, by ascending priority
+func (notifier *RedisNotifierT) claim(workerID string) (claim ClaimT, err error) {
+	claimStartTime := time.Now()
+	defer func() {
+		if err != nil {
+			pgNotifierClaimFailedTime.Since(claimStartTime)
+			pgNotifierClaimFailed.Increment()
+			return
+		}
+		pgNotifierClaimSucceededTime.Since(claimStartTime)
+		pgNotifierClaimSucceeded.Increment()
+	}()
+
+	streams, err := notifier.client.ZRange(notifier.key("priorities"), 0, -1).Result()
+	if err != nil {
+		return
+	}
+	for _, stream := range streams {
+		for {
+			var result []redis.XStream
+			result, err = notifier.client.XReadGroup(&redis.XReadGroupArgs{
+				Group:    redisConsumerGroup,
+				Consumer: workerID,
+				Streams:  []string{stream, ">"},
+				Count:    1,
+				Block:    -1,
+			}).Result()
+			if err == redis.Nil || (err == nil && (len(result) == 0 || len(result[0].Messages) == 0)) {
+				break
+			}
+			if err != nil {
+				pkgLogger.Errorf("PgNotifier: Claim failed: %v", err)
+				return
+			}
+			message := result[0].Messages[0]
+			var ok bool
+			claim, ok, err = notifier.claimMessage(stream, message, workerID)
+			if err != nil {
+				pkgLogger.Errorf("PgNotifier: Claim failed: %v", err)
+				return
+			}
+			if ok {
+				return claim, nil
+			}
+		}
+	}
+	return claim, redis.Nil
+}
+
+// claimMessage marks the job of a stream entry as executing by the worker.
+// Entries of deleted jobs and of jobs which are not waiting to be retried, e.g. which already completed, are dropped.
+func (notifier *RedisNotifierT) claimMessage(stream string, message redis.XMessage, workerID string) (claim ClaimT, ok bool, err error) {
+	jobIDValue, _ := message.Values["job_id"].(string)
+	jobID, _ := strconv.ParseInt(jobIDValue, 10, 64)
+
+	result, err := claimJobScript.Run(
+		notifier.client,
+		[]string{notifier.jobKey(jobID), stream},
+		redisConsumerGroup, message.ID, workerID, GetCurrentSQLTimestamp(),
+	).Result()
+	if err == redis.Nil {
+		return claim, false, nil
+	}
+	if err != nil {
+		return
+	}
+	values, _ := result.([]interface{})
+	if len(values) != 6 {
+		return claim, false, fmt.Errorf("unexpected fields of claimed job %d: %v", jobID, result)
+	}
+	batchID, _ := values[0].(string)
+	status, _ := values[1].(string)
+	payload, _ := values[2].(string)
+	workspace, _ := values[3].(string)
+	attemptValue, _ := values[4].(string)
+	attempt, _ := strconv.Atoi(attemptValue)
+	jobType, _ := values[5].(string)
+	return ClaimT{
+		ID:        jobID,
+		BatchID:   batchID,
+		Status:    status,
+		Payload:   json.RawMessage(payload),
+		Attempt:   attempt,
+		Workspace: workspace,
+		JobType:   jobType,
+	}, true, nil
+}
+
+func (notifier *RedisNotifierT) Subscribe(ctx context.Context, workerId string, jobsBufferSize int) chan ClaimT {
+	jobs := make(chan ClaimT, jobsBufferSize)
+	rruntime.GoForWarehouse(func() {
+		pollSleep := time.Duration(0)
+		defer close(jobs)
+		for {
+			claimedJob, err := notifier.claim(workerId)
+			if err == nil {
+				jobs <- claimedJob
+				pollSleep = time.Duration(0)
+			} else {
+				pollSleep = 2*pollSleep + time.Duration(rand.Intn(100))*time.Millisecond
+				if pollSleep > maxPollSleep {
+					pollSleep = maxPollSleep
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(pollSleep):
+			}
+		}
+	})
+	return jobs
+}
+
+// GetQueueStats derives the backlog from the streams, in which the entries pending in the consumer group are the executing jobs
+// and the entries after the last one delivered to the group are the waiting jobs.
+// The entries of cleared jobs are counted as waiting until they are dropped when claimed.
+func (notifier *RedisNotifierT) GetQueueStats(context.Context) (queueStats QueueStatsT, err error) {
+	streams, err := notifier.client.ZRange(notifier.key("priorities"), 0, -1).Result()
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	for _, stream := range streams {
+		var (
+			entries          int64
+			pending          *redis.XPending
+			lastDeliveredID  string
+			oldestWaitingAge time.Duration
+		)
+		if entries, err = notifier.client.XLen(stream).Result(); err != nil {
+			return
+		}
+		if pending, err = notifier.client.XPending(stream, redisConsumerGroup).Result(); err != nil && err != redis.Nil {
+			return
+		}
+		executing := int64(0)
+		if pending != nil {
+			executing = pending.Count
+		}
+		queueStats.ExecutingJobs += executing
+		queueStats.WaitingJobs += entries - executing
+
+		if lastDeliveredID, err = notifier.lastDeliveredID(stream); err != nil {
+			return
+		}
+		if oldestWaitingAge, err = notifier.oldestWaitingAge(stream, lastDeliveredID, now); err != nil {
+			return
+		}
+		if oldestWaitingAge > queueStats.OldestWaitingJobAge {
+			queueStats.OldestWaitingJobAge = oldestWaitingAge
+		}
+	}
+	return queueStats, nil
+}
+
+// lastDeliveredID returns the id of the last entry of the stream delivered to the consumer group
+func (notifier *RedisNotifierT) lastDeliveredID(stream string) (string, error) {
+	cmd := redis.NewSliceCmd("XINFO", "GROUPS", stream)
+	if err := notifier.client.Process(cmd); err != nil {
+		return "", err
+	}
+	for _, groupInfo := range cmd.Val() {
+		fields, _ := groupInfo.([]interface{})
+		info := make(map[string]string)
+		for i := 0; i+1 < len(fields); i += 2 {
+			name, _ := fields[i].(string)
+			value, _ := fields[i+1].(string)
+			info[name] = value
+		}
+		if info["name"] == redisConsumerGroup {
+			return info["last-delivered-id"], nil
+		}
+	}
+	return "0-0", nil
+}
+
+// oldestWaitingAge returns the time since the first entry of the stream after the last delivered one was added, as recorded in its id
+func (notifier *RedisNotifierT) oldestWaitingAge(stream, lastDeliveredID string, now time.Time) (time.Duration, error) {
+	messages, err := notifier.client.XRangeN(stream, lastDeliveredID, "+", 2).Result()
+	if err != nil && err != redis.Nil {
+		return 0, err
+	}
+	for _, message := range messages {
+		if message.ID == lastDeliveredID {
+			continue
+		}
+		addedAtInMs, err := strconv.ParseInt(strings.SplitN(message.ID, "-", 2)[0], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid id of stream entry %q: %w", message.ID, err)
+		}
+		if age := now.Sub(time.Unix(0, addedAtInMs*int64(time.Millisecond))); age > 0 {
+			return age, nil
+		}
+		return 0, nil
+	}
+	return 0, nil
+}
+
+// RunMaintenanceWorker (blocking - to be called from go routine) re-triggers zombie jobs
+// which were left behind by dead workers in executing state.
+// Claiming the pending entries of the streams only succeeds for one of the maintenance workers, so they do not need a lock.
+func (notifier *RedisNotifierT) RunMaintenanceWorker(ctx context.Context) error {
+	for {
+		if err := notifier.requeueOrphanJobs(ctx); err != nil {
+			pkgLogger.Errorf("PgNotifier: Error re-triggering zombie jobs: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(jobOrphanTimeout / 5):
+		}
+	}
+}
+
+func (notifier *RedisNotifierT) requeueOrphanJobs(ctx context.Context) error {
+	streams, err := notifier.client.ZRange(notifier.key("priorities"), 0, -1).Result()
+	if err != nil {
+		return err
+	}
+	for _, stream := range streams {
+		// pages through all the pending entries of the stream, since the orphans can be behind any number of executing jobs
+		start := "-"
+		for ctx.Err() == nil {
+			pendingEntries, err := notifier.client.XPendingExt(&redis.XPendingExtArgs{
+				Stream: stream,
+				Group:  redisConsumerGroup,
+				Start:  start,
+				End:    "+",
+				Count:  redisPendingPageSize,
+			}).Result()
+			if err != nil && err != redis.Nil {
+				return err
+			}
+			if err := notifier.requeueOrphanEntries(stream, pendingEntries); err != nil {
+				return err
+			}
+			if len(pendingEntries) < redisPendingPageSize {
+				break
+			}
+			if start, err = nextStreamID(pendingEntries[len(pendingEntries)-1].Id); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// requeueOrphanEntries claims the pending entries idle for longer than the orphan timeout and requeues their jobs
+func (notifier *RedisNotifierT) requeueOrphanEntries(stream string, pendingEntries []redis.XPendingExt) error {
+	var orphanIDs []string
+	for _, pendingEntry := range pendingEntries {
+		if pendingEntry.Idle >= jobOrphanTimeout {
+			orphanIDs = append(orphanIDs, pendingEntry.Id)
+		}
+	}
+	if len(orphanIDs) == 0 {
+		return nil
+	}
+	messages, err := notifier.client.XClaim(&redis.XClaimArgs{
+		Stream:   stream,
+		Group:    redisConsumerGroup,
+		Consumer: "maintenance",
+		MinIdle:  jobOrphanTimeout,
+		Messages: orphanIDs,
+	}).Result()
+	if err != nil && err != redis.Nil {
+		return err
+	}
+	for _, message := range messages {
+		jobID, _ := message.Values["job_id"].(string)
+		err := requeueJobScript.Run(
+			notifier.client,
+			[]string{notifier.key("job", jobID), stream},
+			redisConsumerGroup, message.ID, jobID, GetCurrentSQLTimestamp(),
+		).Err()
+		if err != nil && err != redis.Nil {
+			return err
+		}
+		pkgLogger.Debugf("PgNotifier: Re-triggered job id: %v", jobID)
+	}
+	return nil
+}
+
+// nextStreamID returns the id following the one of a stream entry, to read the entries after it
+func nextStreamID(id string) (string, error) {
+	parts := strings.SplitN(id, "-", 2)
+	if len(parts) != 2 {
+		return "", fmt.Errorf("invalid id of stream entry %q", id)
+	}
+	sequence, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid id of stream entry %q: %w", id, err)
+	}
+	return fmt.Sprintf("%s-%d", parts[0], sequence+1), nil
+}
@@ -0,0 +1,297 @@
+package pgnotifier
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/ory/dockertest/v3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rudderlabs/rudder-server/config"
+	"github.com/rudderlabs/rudder-server/testhelper/destination"
+	"github.com/rudderlabs/rudder-server/utils/logger"
+	whUtils "github.com/rudderlabs/rudder-server/warehouse/utils"
+)
+
+func setupRedisNotifier(t *testing.T) *RedisNotifierT {
+	t.Helper()
+	config.Reset()
+	logger.Reset()
+	Init()
+	trackBatchInterval = 10 * time.Millisecond
+	maxPollSleep = 10 * time.Millisecond
+
+	pool, err := dockertest.NewPool("")
+	require.NoError(t, err)
+	redisResource, err := destination.SetupRedis(pool, t)
+	require.NoError(t, err)
+
+	notifier, err := NewRedisNotifier("test-workspace", RedisNotifierOptions{Addresses: []string{redisResource.RedisAddress}})
+	require.NoError(t, err)
+	require.True(t, notifier.CheckHealth(context.Background()))
+	return notifier
+}
+
+func uploadJobs(stagingFileIDs ...int64) []JobPayload {
+	var jobs []JobPayload
+	for _, stagingFileID := range stagingFileIDs {
+		jobs = append(jobs, JobPayload(fmt.Sprintf(`{"StagingFileID": %d}`, stagingFileID)))
+	}
+	return jobs
+}
+
+func receiveResponses(t *testing.T, ch chan []ResponseT) []ResponseT {
+	t.Helper()
+	select {
+	case responses := <-ch:
+		return responses
+	case <-time.After(10 * time.Second):
+		require.FailNow(t, "batch was not completed")
+		return nil
+	}
+}
+
+func TestRedisNotifier(t *testing.T) {
+	notifier := setupRedisNotifier(t)
+	schema := whUtils.SchemaT{"tracks": {"id": "string"}}
+
+	t.Run("claims jobs by priority and completes the batch", func(t *testing.T) {
+		lowCh, err := notifier.Publish(context.Background(), MessagePayload{Jobs: uploadJobs(1), JobType: UploadJobType}, &schema, 100)
+		require.NoError(t, err)
+		highCh, err := notifier.Publish(context.Background(), MessagePayload{Jobs: uploadJobs(2, 3), JobType: UploadJobType}, &schema, 0)
+		require.NoError(t, err)
+
+		var claimedStagingFileIDs []int64
+		for i := 0; i < 3; i++ {
+			claim, err := notifier.claim("worker-1")
+			require.NoError(t, err)
+			require.Equal(t, ExecutingState, claim.Status)
+			require.Equal(t, UploadJobType, claim.JobType)
+			require.Equal(t, "test-workspace", claim.Workspace)
+
+			var payload map[string]json.RawMessage
+			require.NoError(t, json.Unmarshal(claim.Payload, &payload))
+			require.JSONEq(t, `{"tracks": {"id": "string"}}`, string(payload["UploadSchema"]))
+			var stagingFileID int64
+			require.NoError(t, json.Unmarshal(payload["StagingFileID"], &stagingFileID))
+			claimedStagingFileIDs = append(claimedStagingFileIDs, stagingFileID)
+
+			payload["Output"] = json.RawMessage(fmt.Sprintf(`[{"TableName": "tracks", "StagingFileID": %d}]`, stagingFileID))
+			output, err := json.Marshal(payload)
+			require.NoError(t, err)
+			notifier.UpdateClaimedEvent(&claim, &ClaimResponseT{Payload: output})
+		}
+		require.Equal(t, []int64{2, 3, 1}, claimedStagingFileIDs, "higher priority jobs, with lower values, are claimed first")
+
+		_, err = notifier.claim("worker-1")
+		require.Error(t, err, "no jobs left")
+
+		responses := receiveResponses(t, highCh)
+		require.Len(t, responses, 2)
+		for idx, response := range responses {
+			require.Equal(t, SucceededState, response.Status)
+			require.Equal(t, int64(idx+2), response.JobID)
+			require.JSONEq(t, fmt.Sprintf(`[{"TableName": "tracks", "StagingFileID": %d}]`, idx+2), string(response.Output))
+		}
+		require.Len(t, receiveResponses(t, lowCh), 1)
+	})
+
+	t.Run("retries failed jobs until aborted", func(t *testing.T) {
+		ch, err := notifier.Publish(context.Background(), MessagePayload{Jobs: []JobPayload{JobPayload(`{"id": "async"}`)}, JobType: AsyncJobType}, &schema, 100)
+		require.NoError(t, err)
+
+		for attempt := 0; attempt <= maxAttempt+1; attempt++ {
+			claim, err := notifier.claim("worker-1")
+			require.NoError(t, err)
+			require.Equal(t, attempt, claim.Attempt)
+			notifier.UpdateClaimedEvent(&claim, &ClaimResponseT{Err: errors.New("failed")})
+		}
+
+		responses := receiveResponses(t, ch)
+		require.Len(t, responses, 1)
+		require.Equal(t, AbortedState, responses[0].Status)
+		require.Equal(t, "failed", responses[0].Error)
+		_, err = notifier.claim("worker-1")
+		require.Error(t, err, "aborted jobs are not claimed again")
+	})
+
+	t.Run("requeues the jobs of dead workers", func(t *testing.T) {
+		jobOrphanTimeout = 100 * time.Millisecond
+		ch, err := notifier.Publish(context.Background(), MessagePayload{Jobs: uploadJobs(4), JobType: UploadJobType}, &schema, 0)
+		require.NoError(t, err)
+
+		_, err = notifier.claim("dead-worker")
+		require.NoError(t, err)
+		_, err = notifier.claim("worker-1")
+		require.Error(t, err)
+
+		time.Sleep(2 * jobOrphanTimeout)
+		require.NoError(t, notifier.requeueOrphanJobs(context.Background()))
+
+		claim, err := notifier.claim("worker-1")
+		require.NoError(t, err)
+		notifier.UpdateClaimedEvent(&claim, &ClaimResponseT{Payload: claim.Payload})
+		require.Len(t, receiveResponses(t, ch), 1)
+	})
+
+	t.Run("requeues the jobs of dead workers beyond the first page of pending entries", func(t *testing.T) {
+		jobOrphanTimeout = 100 * time.Millisecond
+		var stagingFileIDs []int64
+		for stagingFileID := int64(100); stagingFileID < 100+2*redisPendingPageSize+1; stagingFileID++ {
+			stagingFileIDs = append(stagingFileIDs, stagingFileID)
+		}
+		ch, err := notifier.Publish(context.Background(), MessagePayload{Jobs: uploadJobs(stagingFileIDs...), JobType: UploadJobType}, &schema, 0)
+		require.NoError(t, err)
+		for range stagingFileIDs {
+			_, err = notifier.claim("dead-worker")
+			require.NoError(t, err)
+		}
+
+		time.Sleep(2 * jobOrphanTimeout)
+		require.NoError(t, notifier.requeueOrphanJobs(context.Background()))
+
+		for range stagingFileIDs {
+			claim, err := notifier.claim("worker-1")
+			require.NoError(t, err)
+			notifier.UpdateClaimedEvent(&claim, &ClaimResponseT{Payload: claim.Payload})
+		}
+		require.Len(t, receiveResponses(t, ch), len(stagingFileIDs))
+	})
+
+	t.Run("does not claim completed jobs again", func(t *testing.T) {
+		_, err := notifier.Publish(context.Background(), MessagePayload{Jobs: uploadJobs(8, 9), JobType: UploadJobType}, &schema, 0)
+		require.NoError(t, err)
+
+		completed, err := notifier.claim("worker-1")
+		require.NoError(t, err)
+		notifier.UpdateClaimedEvent(&completed, &ClaimResponseT{Payload: completed.Payload})
+		// a stale entry of the completed job, e.g. left behind by a worker which was considered dead
+		require.NoError(t, notifier.client.XAdd(&redis.XAddArgs{
+			Stream: notifier.streamKey(0),
+			ID:     "*",
+			Values: map[string]interface{}{"job_id": completed.ID},
+		}).Err())
+
+		claim, err := notifier.claim("worker-1")
+		require.NoError(t, err)
+		require.NotEqual(t, completed.ID, claim.ID)
+		_, err = notifier.claim("worker-1")
+		require.Error(t, err, "the entry of the completed job is dropped")
+		status, err := notifier.client.HGet(notifier.jobKey(completed.ID), "status").Result()
+		require.NoError(t, err)
+		require.Equal(t, SucceededState, status)
+		notifier.UpdateClaimedEvent(&claim, &ClaimResponseT{Payload: claim.Payload})
+	})
+
+	t.Run("stops tracking the batch once cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		ch, err := notifier.Publish(ctx, MessagePayload{Jobs: uploadJobs(10), JobType: UploadJobType}, &schema, 0)
+		require.NoError(t, err)
+		cancel()
+
+		select {
+		case _, ok := <-ch:
+			require.False(t, ok, "the channel is closed without responses")
+		case <-time.After(10 * time.Second):
+			require.FailNow(t, "batch is still tracked")
+		}
+		claim, err := notifier.claim("worker-1")
+		require.NoError(t, err)
+		notifier.UpdateClaimedEvent(&claim, &ClaimResponseT{Payload: claim.Payload})
+	})
+
+	t.Run("clears the jobs of the workspace", func(t *testing.T) {
+		_, err := notifier.Publish(context.Background(), MessagePayload{Jobs: uploadJobs(5, 6), JobType: UploadJobType}, &schema, 0)
+		require.NoError(t, err)
+		require.NoError(t, notifier.ClearJobs(context.Background()))
+
+		_, err = notifier.claim("worker-1")
+		require.Error(t, err, "the entries of cleared jobs are dropped")
+	})
+
+	t.Run("subscribes to claimed jobs", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		_, err := notifier.Publish(context.Background(), MessagePayload{Jobs: uploadJobs(7), JobType: UploadJobType}, &schema, 0)
+		require.NoError(t, err)
+
+		select {
+		case claim := <-notifier.Subscribe(ctx, "worker-1", 1):
+			require.Equal(t, ExecutingState, claim.Status)
+		case <-time.After(10 * time.Second):
+			require.FailNow(t, "job was not claimed")
+		}
+	})
+}
+
+func TestRedisNotifierQueueStats(t *testing.T) {
+	notifier := setupRedisNotifier(t)
+	schema := whUtils.SchemaT{"tracks": {"id": "string"}}
+
+	queueStats, err := notifier.GetQueueStats(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, QueueStatsT{}, queueStats)
+
+	_, err = notifier.Publish(context.Background(), MessagePayload{Jobs: uploadJobs(1, 2), JobType: UploadJobType}, &schema, 0)
+	require.NoError(t, err)
+	_, err = notifier.Publish(context.Background(), MessagePayload{Jobs: uploadJobs(3), JobType: UploadJobType}, &schema, 100)
+	require.NoError(t, err)
+	time.Sleep(10 * time.Millisecond)
+
+	_, err = notifier.claim("worker-1")
+	require.NoError(t, err)
+	queueStats, err = notifier.GetQueueStats(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, int64(2), queueStats.WaitingJobs)
+	require.Equal(t, int64(1), queueStats.ExecutingJobs)
+	require.Greater(t, queueStats.OldestWaitingJobAge, time.Duration(0))
+
+	for i := 0; i < 2; i++ {
+		_, err = notifier.claim("worker-1")
+		require.NoError(t, err)
+	}
+	queueStats, err = notifier.GetQueueStats(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, QueueStatsT{ExecutingJobs: 3}, queueStats, "no jobs are waiting once all of them are claimed")
+}
+
+func TestNextStreamID(t *testing.T) {
+	id, err := nextStreamID("1526919030474-55")
+	require.NoError(t, err)
+	require.Equal(t, "1526919030474-56", id)
+
+	_, err = nextStreamID("1526919030474")
+	require.Error(t, err)
+}
+
+func TestTrackBatchBackoff(t *testing.T) {
+	config.Reset()
+	Init()
+
+	interval := trackBatchInterval
+	for i := 0; i < 10; i++ {
+		next := trackBatchBackoff(interval)
+		require.GreaterOrEqual(t, next, interval)
+		require.LessOrEqual(t, next, maxTrackBatchBackoff)
+		interval = next
+	}
+	require.Equal(t, maxTrackBatchBackoff, interval)
+}
+
+func TestNewNotifier(t *testing.T) {
+	config.Reset()
+	logger.Reset()
+	Init()
+
+	backend = "kafka"
+	_, err := NewNotifier("test-workspace", "")
+	require.EqualError(t, err, `unknown pgnotifier backend: "kafka"`)
+
+	_, err = NewRedisNotifier("test-workspace", RedisNotifierOptions{})
+	require.Error(t, err)
+}
@@ -16,6 +16,27 @@ type StreamProducer interface {
 	Produce(jsonData json.RawMessage, destConfig interface{}) (int, string, string)
 }
 
+// TransactionalStreamProducer is implemented by stream producers which are able to deliver events exactly once.
+// Users are assigned to partitions and every partition uses its own transactions: the events produced in a
+// transaction become visible only once it is committed, while the ids of the jobs being committed are kept by the
+// producer so that jobs which have already been delivered are not produced again if they are retried, e.g. after a
+// crash. A partition has at most one open transaction at a time.
+type TransactionalStreamProducer interface {
+	StreamProducer
+	// IsTransactional returns true if exactly-once delivery is enabled for the destination
+	IsTransactional() bool
+	// TransactionPartition returns the partition of the transactions delivering the events of the user
+	TransactionPartition(userID string) int
+	// BeginTransaction opens a new transaction for the partition
+	BeginTransaction(partition int) error
+	// ProduceInTransaction produces the event of the provided jobs within the open transaction of the partition
+	ProduceInTransaction(partition int, jobIDs []int64, jsonData json.RawMessage, destConfig interface{}) (int, string, string)
+	// CommitTransaction commits the open transaction of the partition
+	CommitTransaction(partition int) error
+	// AbortTransaction aborts the open transaction of the partition
+	AbortTransaction(partition int) error
+}
+
 type Opts struct {
 	Timeout time.Duration
 }
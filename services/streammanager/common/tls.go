@@ -0,0 +1,44 @@
+package common
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+)
+
+// TLSConfig is the TLS configuration shared by the destinations of self-hosted brokers
+type TLSConfig struct {
+	SslEnabled bool `json:"sslEnabled"`
+	// CACertificate is the PEM encoded certificate of the CA, the system pool is used if empty
+	CACertificate string `json:"caCertificate"`
+	// ClientCertificate and ClientKey are the PEM encoded certificate and key used for mutual TLS
+	ClientCertificate  string `json:"clientCertificate"`
+	ClientKey          string `json:"clientKey"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify"`
+}
+
+// Build returns the *tls.Config of the configuration or nil if SSL is not enabled
+func (c *TLSConfig) Build() (*tls.Config, error) {
+	if !c.SslEnabled {
+		return nil, nil
+	}
+	conf := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: c.InsecureSkipVerify, // skipcq: GSC-G402
+	}
+	if c.CACertificate != "" {
+		caCertPool := x509.NewCertPool()
+		if !caCertPool.AppendCertsFromPEM([]byte(c.CACertificate)) {
+			return nil, fmt.Errorf("could not append CA certificate to pool")
+		}
+		conf.RootCAs = caCertPool
+	}
+	if c.ClientCertificate != "" || c.ClientKey != "" {
+		cert, err := tls.X509KeyPair([]byte(c.ClientCertificate), []byte(c.ClientKey))
+		if err != nil {
+			return nil, fmt.Errorf("could not load client certificate: %w", err)
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+	return conf, nil
+}
@@ -0,0 +1,205 @@
+//go:generate mockgen -destination=../../../mocks/services/streammanager/jetstream/mock_jetstream.go -package mock_jetstream github.com/rudderlabs/rudder-server/services/streammanager/jetstream JetStreamClient
+
+package jetstream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nkeys"
+	"github.com/tidwall/gjson"
+
+	backendconfig "github.com/rudderlabs/rudder-server/config/backend-config"
+	"github.com/rudderlabs/rudder-server/services/streammanager/common"
+	"github.com/rudderlabs/rudder-server/utils/logger"
+)
+
+var pkgLogger logger.Logger
+
+func init() {
+	pkgLogger = logger.NewLogger().Child("streammanager").Child("jetstream")
+}
+
+// Config is the config of a NATS JetStream destination.
+// Only one authentication method is used, in order: user credentials, nkey seed, token and username/password.
+type Config struct {
+	// URL is a comma separated list of server URLs, e.g. nats://host1:4222,nats://host2:4222
+	URL string `json:"url"`
+	// Subject is the default subject of the messages, it can be overridden by the "subject" of the payload
+	Subject  string `json:"subject"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Token    string `json:"token"`
+	NKeySeed string `json:"nkeySeed"`
+	// UserJWT and UserSeed are the decorated JWT and the seed of a user credentials file
+	UserJWT  string `json:"userJWT"`
+	UserSeed string `json:"userSeed"`
+	common.TLSConfig
+}
+
+func (c *Config) validate() error {
+	if strings.TrimSpace(c.URL) == "" {
+		return fmt.Errorf("url cannot be empty")
+	}
+	if (c.UserJWT == "") != (c.UserSeed == "") {
+		return fmt.Errorf("both user jwt and user seed are required for user credentials authentication")
+	}
+	return nil
+}
+
+func (c *Config) options(o common.Opts) ([]nats.Option, error) {
+	opts := []nats.Option{nats.Name("rudder-server")}
+	if o.Timeout > 0 {
+		opts = append(opts, nats.Timeout(o.Timeout))
+	}
+	switch {
+	case c.UserJWT != "":
+		opts = append(opts, nats.UserJWTAndSeed(c.UserJWT, c.UserSeed))
+	case c.NKeySeed != "":
+		kp, err := nkeys.FromSeed([]byte(c.NKeySeed))
+		if err != nil {
+			return nil, fmt.Errorf("invalid nkey seed: %w", err)
+		}
+		publicKey, err := kp.PublicKey()
+		if err != nil {
+			return nil, fmt.Errorf("invalid nkey seed: %w", err)
+		}
+		opts = append(opts, nats.Nkey(publicKey, kp.Sign))
+	case c.Token != "":
+		opts = append(opts, nats.Token(c.Token))
+	case c.Username != "":
+		opts = append(opts, nats.UserInfo(c.Username, c.Password))
+	}
+	tlsConfig, err := c.TLSConfig.Build()
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		opts = append(opts, nats.Secure(tlsConfig))
+	}
+	return opts, nil
+}
+
+// JetStreamClient publishes messages to JetStream waiting for their acknowledgement
+type JetStreamClient interface {
+	PublishMsg(m *nats.Msg, opts ...nats.PubOpt) (*nats.PubAck, error)
+}
+
+type JetStreamProducer struct {
+	conn    *nats.Conn
+	client  JetStreamClient
+	subject string
+	opts    common.Opts
+}
+
+// NewProducer creates a producer based on destination config
+func NewProducer(destination *backendconfig.DestinationT, o common.Opts) (*JetStreamProducer, error) {
+	var config Config
+	jsonConfig, err := json.Marshal(destination.Config)
+	if err != nil {
+		return nil, fmt.Errorf("[JetStream] error while marshalling destination config: %w", err)
+	}
+	if err = json.Unmarshal(jsonConfig, &config); err != nil {
+		return nil, fmt.Errorf("[JetStream] error while unmarshalling destination config: %w", err)
+	}
+	if err = config.validate(); err != nil {
+		return nil, fmt.Errorf("[JetStream] invalid configuration: %w", err)
+	}
+	opts, err := config.options(o)
+	if err != nil {
+		return nil, fmt.Errorf("[JetStream] invalid configuration: %w", err)
+	}
+	conn, err := nats.Connect(config.URL, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("[JetStream] could not connect: %w", err)
+	}
+	js, err := conn.JetStream()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("[JetStream] could not create context: %w", err)
+	}
+	return &JetStreamProducer{conn: conn, client: js, subject: config.Subject, opts: o}, nil
+}
+
+// Produce publishes the message of the payload and waits for the stream to acknowledge it.
+// The payload is expected to contain the "message" and optionally the "subject", a "messageId" used by JetStream
+// to discard duplicates and the "headers" of the message.
+func (producer *JetStreamProducer) Produce(jsonData json.RawMessage, _ interface{}) (int, string, string) {
+	client := producer.client
+	if client == nil {
+		return 400, "Failure", "[JetStream] error :: Could not create producer"
+	}
+	parsedJSON := gjson.ParseBytes(jsonData)
+	message := parsedJSON.Get("message")
+	if !message.Exists() {
+		return 400, "Failure", "[JetStream] error :: message from payload not found"
+	}
+	subject := producer.subject
+	if s := parsedJSON.Get("subject").String(); s != "" {
+		subject = s
+	}
+	if subject == "" {
+		return 400, "Failure", "[JetStream] error :: subject not found"
+	}
+
+	msg := nats.NewMsg(subject)
+	msg.Data = []byte(message.Raw)
+	parsedJSON.Get("headers").ForEach(func(key, value gjson.Result) bool {
+		msg.Header.Set(key.String(), value.String())
+		return true
+	})
+
+	ctx := context.Background()
+	if producer.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, producer.opts.Timeout)
+		defer cancel()
+	}
+	pubOpts := []nats.PubOpt{nats.Context(ctx)}
+	if messageID := parsedJSON.Get("messageId").String(); messageID != "" {
+		pubOpts = append(pubOpts, nats.MsgId(messageID))
+	}
+
+	ack, err := client.PublishMsg(msg, pubOpts...)
+	if err != nil {
+		statusCode := getStatusCode(err)
+		pkgLogger.Errorf("[JetStream] error :: %d : %v", statusCode, err)
+		return statusCode, "Failure", "[JetStream] error :: Failed to publish: " + err.Error()
+	}
+	responseMessage := fmt.Sprintf("Message published to stream %s with sequence %d", ack.Stream, ack.Sequence)
+	if ack.Duplicate {
+		responseMessage += " (duplicate)"
+	}
+	return 200, "Success", responseMessage
+}
+
+// getStatusCode maps publishing errors to status codes, errors which might be temporary are retried
+func getStatusCode(err error) int {
+	var apiErr *nats.APIError
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, nats.ErrTimeout):
+		return 504
+	case errors.Is(err, nats.ErrMaxPayload), errors.Is(err, nats.ErrBadSubject):
+		return 400
+	case errors.As(err, &apiErr):
+		if apiErr.Code >= 400 && apiErr.Code < 500 {
+			return 400
+		}
+		return 500
+	default:
+		// e.g. no stream acknowledging the subject while its leader is being elected or the connection being lost
+		return 500
+	}
+}
+
+// Close drains the connection of the producer
+func (producer *JetStreamProducer) Close() error {
+	if producer.conn == nil {
+		return nil
+	}
+	return producer.conn.Drain()
+}
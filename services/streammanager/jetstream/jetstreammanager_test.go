@@ -0,0 +1,149 @@
+package jetstream
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/nats-io/nats.go"
+	"github.com/ory/dockertest/v3"
+	"github.com/stretchr/testify/require"
+
+	backendconfig "github.com/rudderlabs/rudder-server/config/backend-config"
+	mock_jetstream "github.com/rudderlabs/rudder-server/mocks/services/streammanager/jetstream"
+	"github.com/rudderlabs/rudder-server/services/streammanager/common"
+	"github.com/rudderlabs/rudder-server/testhelper/destination"
+)
+
+func TestNewProducer(t *testing.T) {
+	t.Run("invalid configuration", func(t *testing.T) {
+		for name, config := range map[string]map[string]interface{}{
+			"missing url":           {"subject": "subject"},
+			"incomplete creds":      {"url": "nats://localhost:4222", "userJWT": "jwt"},
+			"invalid nkey seed":     {"url": "nats://localhost:4222", "nkeySeed": "invalid"},
+			"invalid CA":            {"url": "nats://localhost:4222", "sslEnabled": true, "caCertificate": "invalid"},
+			"invalid client cert":   {"url": "nats://localhost:4222", "sslEnabled": true, "clientCertificate": "invalid"},
+			"unreachable server":    {"url": "nats://localhost:1"},
+			"invalid config format": {"url": 1},
+		} {
+			t.Run(name, func(t *testing.T) {
+				p, err := NewProducer(&backendconfig.DestinationT{Config: config}, common.Opts{Timeout: time.Second})
+				require.Error(t, err)
+				require.Nil(t, p)
+			})
+		}
+	})
+}
+
+func TestProduce(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	client := mock_jetstream.NewMockJetStreamClient(ctrl)
+	producer := &JetStreamProducer{client: client, subject: "default.subject", opts: common.Opts{Timeout: time.Second}}
+
+	t.Run("publishes with the subject, id and headers of the payload", func(t *testing.T) {
+		client.EXPECT().PublishMsg(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(msg *nats.Msg, opts ...nats.PubOpt) (*nats.PubAck, error) {
+				require.Equal(t, "orders.created", msg.Subject)
+				require.JSONEq(t, `{"a":1}`, string(msg.Data))
+				require.Equal(t, "value", msg.Header.Get("key"))
+				require.Len(t, opts, 2, "context and message id")
+				return &nats.PubAck{Stream: "ORDERS", Sequence: 3}, nil
+			})
+		statusCode, respStatus, respMessage := producer.Produce([]byte(`{
+			"message": {"a":1},
+			"subject": "orders.created",
+			"messageId": "id",
+			"headers": {"key": "value"}
+		}`), nil)
+		require.Equal(t, 200, statusCode)
+		require.Equal(t, "Success", respStatus)
+		require.Equal(t, "Message published to stream ORDERS with sequence 3", respMessage)
+	})
+
+	t.Run("defaults to the subject of the configuration", func(t *testing.T) {
+		client.EXPECT().PublishMsg(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(msg *nats.Msg, opts ...nats.PubOpt) (*nats.PubAck, error) {
+				require.Equal(t, "default.subject", msg.Subject)
+				require.Len(t, opts, 1)
+				return &nats.PubAck{Stream: "DEFAULT", Sequence: 1, Duplicate: true}, nil
+			})
+		statusCode, _, respMessage := producer.Produce([]byte(`{"message":"text"}`), nil)
+		require.Equal(t, 200, statusCode)
+		require.Contains(t, respMessage, "(duplicate)")
+	})
+
+	t.Run("invalid payload", func(t *testing.T) {
+		statusCode, _, respMessage := producer.Produce([]byte(`{"subject":"subject"}`), nil)
+		require.Equal(t, 400, statusCode)
+		require.Contains(t, respMessage, "message from payload not found")
+
+		noSubject := &JetStreamProducer{client: client}
+		statusCode, _, respMessage = noSubject.Produce([]byte(`{"message":{}}`), nil)
+		require.Equal(t, 400, statusCode)
+		require.Contains(t, respMessage, "subject not found")
+
+		statusCode, _, _ = (&JetStreamProducer{}).Produce([]byte(`{"message":{}}`), nil)
+		require.Equal(t, 400, statusCode)
+	})
+
+	t.Run("publishing errors", func(t *testing.T) {
+		for err, statusCode := range map[error]int{
+			nats.ErrTimeout:                               504,
+			context.DeadlineExceeded:                      504,
+			nats.ErrMaxPayload:                            400,
+			nats.ErrNoStreamResponse:                      500,
+			nats.ErrConnectionClosed:                      500,
+			&nats.APIError{Code: 400}:                     400,
+			&nats.APIError{Code: 503}:                     500,
+			fmt.Errorf("wrapped: %w", nats.ErrBadSubject): 400,
+		} {
+			client.EXPECT().PublishMsg(gomock.Any(), gomock.Any()).Return(nil, err)
+			actual, respStatus, respMessage := producer.Produce([]byte(`{"message":{}}`), nil)
+			require.Equal(t, statusCode, actual, err.Error())
+			require.Equal(t, "Failure", respStatus)
+			require.Contains(t, respMessage, err.Error())
+		}
+	})
+}
+
+func TestProduceWithServer(t *testing.T) {
+	pool, err := dockertest.NewPool("")
+	require.NoError(t, err)
+	natsResource, err := destination.SetupNATS(pool, t)
+	require.NoError(t, err)
+
+	conn, err := nats.Connect(natsResource.URL)
+	require.NoError(t, err)
+	t.Cleanup(conn.Close)
+	js, err := conn.JetStream()
+	require.NoError(t, err)
+	_, err = js.AddStream(&nats.StreamConfig{Name: "EVENTS", Subjects: []string{"events.>"}})
+	require.NoError(t, err)
+
+	producer, err := NewProducer(&backendconfig.DestinationT{Config: map[string]interface{}{
+		"url":     natsResource.URL,
+		"subject": "events.default",
+	}}, common.Opts{Timeout: 10 * time.Second})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = producer.Close() })
+
+	statusCode, _, respMessage := producer.Produce([]byte(`{"message":{"a":1},"messageId":"1"}`), nil)
+	require.Equal(t, 200, statusCode, respMessage)
+	statusCode, _, respMessage = producer.Produce([]byte(`{"message":{"a":1},"messageId":"1"}`), nil)
+	require.Equal(t, 200, statusCode, respMessage)
+	require.Contains(t, respMessage, "(duplicate)")
+
+	msg, err := js.GetLastMsg("EVENTS", "events.default")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"a":1}`, string(msg.Data))
+
+	statusCode, _, respMessage = producer.Produce([]byte(`{"message":{"a":1},"subject":"unbound.subject"}`), nil)
+	require.Equal(t, 500, statusCode, respMessage)
+
+	require.Eventually(t, func() bool {
+		info, err := js.StreamInfo("EVENTS")
+		return err == nil && info.State.Msgs == 1
+	}, 10*time.Second, 100*time.Millisecond)
+}
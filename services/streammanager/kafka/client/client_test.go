@@ -548,6 +548,76 @@ func TestProducer_Timeout(t *testing.T) {
 	require.ErrorIs(t, err, context.DeadlineExceeded)
 }
 
+func TestTransactionalProducer(t *testing.T) {
+	pool, err := dockertest.NewPool("")
+	require.NoError(t, err)
+
+	kafkaContainer, err := destination.SetupKafka(pool, &testCleanup{t},
+		destination.WithLogger(t),
+		destination.WithBrokers(1))
+	require.NoError(t, err)
+
+	kafkaHost := fmt.Sprintf("localhost:%s", kafkaContainer.Port)
+	c, err := New("tcp", []string{kafkaHost}, Config{ClientID: "some-client", DialTimeout: 5 * time.Second})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	tc := testutil.NewWithDialer(c.dialer, c.network, c.addresses...)
+	require.NoError(t, c.Ping(ctx))
+	require.Eventually(t, func() bool {
+		err := tc.CreateTopic(ctx, t.Name(), 1, 1)
+		if err != nil {
+			t.Logf("Could not create topic: %v", err)
+		}
+		return err == nil
+	}, defaultTestTimeout, time.Second)
+
+	newProducer := func() *TransactionalProducer {
+		p, err := c.NewTransactionalProducer(t.Name(), TransactionalProducerConfig{TransactionalID: "tx-01"})
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := p.Close(ctx); err != nil {
+				t.Logf("Error closing producer: %v", err)
+			}
+		})
+		return p
+	}
+
+	p := newProducer()
+	metadata, err := p.LastCommittedMetadata(ctx)
+	require.NoError(t, err)
+	require.Empty(t, metadata)
+
+	require.NoError(t, p.BeginTransaction())
+	require.NoError(t, p.Publish(ctx, Message{Key: []byte("hello"), Value: []byte("world")}))
+	require.NoError(t, p.CommitTransaction(ctx, "first"))
+
+	require.NoError(t, p.BeginTransaction())
+	require.NoError(t, p.Publish(ctx, Message{Key: []byte("hello"), Value: []byte("again")}))
+	require.NoError(t, p.AbortTransaction(ctx))
+
+	metadata, err = p.LastCommittedMetadata(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "first", metadata, "metadata of aborted transactions should not be visible")
+
+	// a new producer with the same transactional id fences the previous one and reads back the metadata of the
+	// last committed transaction
+	p = newProducer()
+	metadata, err = p.LastCommittedMetadata(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "first", metadata)
+
+	require.NoError(t, p.BeginTransaction())
+	require.NoError(t, p.CommitTransaction(ctx, "second"))
+	metadata, err = p.LastCommittedMetadata(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "second", metadata)
+}
+
 func TestIsProducerErrTemporary(t *testing.T) {
 	// Prepare cluster - Zookeeper and one Kafka broker
 	pool, err := dockertest.NewPool("")
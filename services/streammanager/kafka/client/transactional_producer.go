@@ -0,0 +1,277 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/kmsg"
+	"github.com/twmb/franz-go/pkg/sasl"
+	"github.com/twmb/franz-go/pkg/sasl/plain"
+	"github.com/twmb/franz-go/pkg/sasl/scram"
+)
+
+type TransactionalProducerConfig struct {
+	ClientID string
+	// TransactionalID identifies the producer across restarts. Kafka keeps the producer id and epoch of a
+	// transactional id, so that a restarted producer fences its previous incarnation and aborts any
+	// transaction left open by it.
+	TransactionalID    string
+	TransactionTimeout time.Duration
+	WriteTimeout       time.Duration
+}
+
+func (c *TransactionalProducerConfig) defaults() {
+	if c.TransactionTimeout < 1 {
+		c.TransactionTimeout = 60 * time.Second
+	}
+	if c.WriteTimeout < 1 {
+		c.WriteTimeout = 10 * time.Second
+	}
+}
+
+// TransactionalProducer provides a high-level API for producing messages to Kafka within transactions.
+// It is built on franz-go, since kafka-go, which backs the other producers, does not implement the transactional
+// protocol (InitProducerID, AddPartitionsToTxn, TxnOffsetCommit and EndTxn), and exactly once delivery needs both
+// the transactions and the offsets committed within them.
+// Messages published within a transaction are visible to consumers using the read_committed isolation level
+// only once the transaction is committed.
+//
+// Along with the messages, every transaction commits a metadata string in the consumer group named after the
+// transactional id. The metadata of the last committed transaction can be read back with LastCommittedMetadata,
+// which allows to find out which messages have been delivered by a producer before it was restarted.
+type TransactionalProducer struct {
+	client *kgo.Client
+	topic  string
+	config TransactionalProducerConfig
+}
+
+// NewTransactionalProducer instantiates a new transactional producer
+func (c *Client) NewTransactionalProducer(topic string, producerConf TransactionalProducerConfig) (*TransactionalProducer, error) { // skipcq: CRT-P0003
+	producerConf.defaults()
+	if producerConf.TransactionalID == "" {
+		return nil, fmt.Errorf("transactional id cannot be empty")
+	}
+
+	dialer := &net.Dialer{
+		Timeout: c.config.DialTimeout,
+	}
+	opts := []kgo.Opt{
+		kgo.SeedBrokers(c.addresses...),
+		kgo.Dialer(dialer.DialContext),
+		kgo.DefaultProduceTopic(topic),
+		kgo.TransactionalID(producerConf.TransactionalID),
+		kgo.TransactionTimeout(producerConf.TransactionTimeout),
+		kgo.ProduceRequestTimeout(producerConf.WriteTimeout),
+		kgo.RequiredAcks(kgo.AllISRAcks()),
+		kgo.AllowAutoTopicCreation(),
+	}
+	if producerConf.ClientID != "" {
+		opts = append(opts, kgo.ClientID(producerConf.ClientID))
+	} else if c.config.ClientID != "" {
+		opts = append(opts, kgo.ClientID(c.config.ClientID))
+	}
+	if c.config.TLS != nil {
+		tlsConfig, err := c.config.TLS.build()
+		if err != nil {
+			return nil, fmt.Errorf("could not build TLS configuration: %w", err)
+		}
+		opts = append(opts, kgo.DialTLSConfig(tlsConfig))
+	}
+	if c.config.SASL != nil {
+		mechanism, err := c.config.SASL.buildTransactional()
+		if err != nil {
+			return nil, fmt.Errorf("could not build SASL configuration: %w", err)
+		}
+		opts = append(opts, kgo.SASL(mechanism))
+	}
+
+	kc, err := kgo.NewClient(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("could not create transactional client: %w", err)
+	}
+	return &TransactionalProducer{
+		client: kc,
+		topic:  topic,
+		config: producerConf,
+	}, nil
+}
+
+// BeginTransaction opens a new transaction. Only one transaction can be open at a time.
+func (p *TransactionalProducer) BeginTransaction() error {
+	return p.client.BeginTransaction()
+}
+
+// Publish synchronously publishes one or more messages within the open transaction
+func (p *TransactionalProducer) Publish(ctx context.Context, msgs ...Message) error {
+	records := make([]*kgo.Record, len(msgs))
+	for i := range msgs {
+		var headers []kgo.RecordHeader
+		if l := len(msgs[i].Headers); l > 0 {
+			headers = make([]kgo.RecordHeader, l)
+			for k := range msgs[i].Headers {
+				headers[k] = kgo.RecordHeader{
+					Key:   msgs[i].Headers[k].Key,
+					Value: msgs[i].Headers[k].Value,
+				}
+			}
+		}
+		records[i] = &kgo.Record{
+			Key:       msgs[i].Key,
+			Value:     msgs[i].Value,
+			Topic:     msgs[i].Topic,
+			Timestamp: msgs[i].Timestamp,
+			Headers:   headers,
+		}
+	}
+	return p.client.ProduceSync(ctx, records...).FirstErr()
+}
+
+// CommitTransaction commits the open transaction along with the provided metadata
+func (p *TransactionalProducer) CommitTransaction(ctx context.Context, metadata string) error {
+	if err := p.commitMetadata(ctx, metadata); err != nil {
+		if abortErr := p.AbortTransaction(ctx); abortErr != nil {
+			return fmt.Errorf("could not abort transaction after failing to commit metadata (%v): %w", err, abortErr)
+		}
+		return fmt.Errorf("could not commit transaction metadata: %w", err)
+	}
+	if err := p.client.Flush(ctx); err != nil {
+		return fmt.Errorf("could not flush transaction: %w", err)
+	}
+	return p.client.EndTransaction(ctx, kgo.TryCommit)
+}
+
+// AbortTransaction aborts the open transaction, discarding any message that is still buffered
+func (p *TransactionalProducer) AbortTransaction(ctx context.Context) error {
+	if err := p.client.AbortBufferedRecords(ctx); err != nil {
+		return fmt.Errorf("could not abort buffered records: %w", err)
+	}
+	return p.client.EndTransaction(ctx, kgo.TryAbort)
+}
+
+// LastCommittedMetadata returns the metadata of the last transaction committed with the producer's transactional id.
+// An empty string is returned if no transaction has been committed yet.
+func (p *TransactionalProducer) LastCommittedMetadata(ctx context.Context) (string, error) {
+	req := kmsg.NewPtrOffsetFetchRequest()
+	req.Group = p.config.TransactionalID
+	req.RequireStable = true
+	reqTopic := kmsg.NewOffsetFetchRequestTopic()
+	reqTopic.Topic = p.topic
+	reqTopic.Partitions = []int32{0}
+	req.Topics = append(req.Topics, reqTopic)
+
+	resp, err := req.RequestWith(ctx, p.client)
+	if err != nil {
+		return "", fmt.Errorf("could not fetch transaction metadata: %w", err)
+	}
+	if err = kerr.ErrorForCode(resp.ErrorCode); err != nil {
+		return "", fmt.Errorf("could not fetch transaction metadata: %w", err)
+	}
+	for _, group := range resp.Groups {
+		if err = kerr.ErrorForCode(group.ErrorCode); err != nil {
+			return "", fmt.Errorf("could not fetch transaction metadata: %w", err)
+		}
+		for _, topic := range group.Topics {
+			for _, partition := range topic.Partitions {
+				if err = kerr.ErrorForCode(partition.ErrorCode); err != nil {
+					return "", fmt.Errorf("could not fetch transaction metadata: %w", err)
+				}
+				if partition.Offset >= 0 && partition.Metadata != nil {
+					return *partition.Metadata, nil
+				}
+			}
+		}
+	}
+	for _, topic := range resp.Topics {
+		for _, partition := range topic.Partitions {
+			if err = kerr.ErrorForCode(partition.ErrorCode); err != nil {
+				return "", fmt.Errorf("could not fetch transaction metadata: %w", err)
+			}
+			if partition.Offset >= 0 && partition.Metadata != nil {
+				return *partition.Metadata, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// Close closes the producer, aborting any open transaction
+func (p *TransactionalProducer) Close(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		p.client.Close()
+		close(done)
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-done:
+		return nil
+	}
+}
+
+// commitMetadata adds the consumer group named after the transactional id to the open transaction and
+// commits the metadata into it, so that the metadata becomes visible only if the transaction is committed.
+func (p *TransactionalProducer) commitMetadata(ctx context.Context, metadata string) error {
+	id, epoch, err := p.client.ProducerID(ctx)
+	if err != nil {
+		return fmt.Errorf("could not get producer id: %w", err)
+	}
+
+	addReq := kmsg.NewPtrAddOffsetsToTxnRequest()
+	addReq.TransactionalID = p.config.TransactionalID
+	addReq.ProducerID = id
+	addReq.ProducerEpoch = epoch
+	addReq.Group = p.config.TransactionalID
+	addResp, err := addReq.RequestWith(ctx, p.client)
+	if err != nil {
+		return fmt.Errorf("could not add offsets to transaction: %w", err)
+	}
+	if err = kerr.ErrorForCode(addResp.ErrorCode); err != nil {
+		return fmt.Errorf("could not add offsets to transaction: %w", err)
+	}
+
+	commitReq := kmsg.NewPtrTxnOffsetCommitRequest()
+	commitReq.TransactionalID = p.config.TransactionalID
+	commitReq.Group = p.config.TransactionalID
+	commitReq.ProducerID = id
+	commitReq.ProducerEpoch = epoch
+	commitReq.Generation = -1
+	reqTopic := kmsg.NewTxnOffsetCommitRequestTopic()
+	reqTopic.Topic = p.topic
+	reqPartition := kmsg.NewTxnOffsetCommitRequestTopicPartition()
+	reqPartition.Partition = 0
+	reqPartition.Offset = time.Now().UnixMilli()
+	reqPartition.Metadata = &metadata
+	reqTopic.Partitions = append(reqTopic.Partitions, reqPartition)
+	commitReq.Topics = append(commitReq.Topics, reqTopic)
+	commitResp, err := commitReq.RequestWith(ctx, p.client)
+	if err != nil {
+		return fmt.Errorf("could not commit offsets in transaction: %w", err)
+	}
+	for _, topic := range commitResp.Topics {
+		for _, partition := range topic.Partitions {
+			if err = kerr.ErrorForCode(partition.ErrorCode); err != nil {
+				return fmt.Errorf("could not commit offsets in transaction: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+func (c *SASL) buildTransactional() (sasl.Mechanism, error) {
+	switch c.ScramHashGen {
+	case ScramPlainText:
+		return plain.Auth{User: c.Username, Pass: c.Password}.AsMechanism(), nil
+	case ScramSHA256:
+		return scram.Auth{User: c.Username, Pass: c.Password}.AsSha256Mechanism(), nil
+	case ScramSHA512:
+		return scram.Auth{User: c.Username, Pass: c.Password}.AsSha512Mechanism(), nil
+	default:
+		return nil, fmt.Errorf("scram hash generator out of the known domain: %v", c.ScramHashGen)
+	}
+}
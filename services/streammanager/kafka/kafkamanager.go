@@ -3,6 +3,7 @@ package kafka
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -18,6 +19,7 @@ import (
 	"github.com/rudderlabs/rudder-server/services/stats"
 	"github.com/rudderlabs/rudder-server/services/streammanager/common"
 	"github.com/rudderlabs/rudder-server/services/streammanager/kafka/client"
+	"github.com/rudderlabs/rudder-server/services/streammanager/kafka/schemaregistry"
 	rslogger "github.com/rudderlabs/rudder-server/utils/logger"
 )
 
@@ -40,6 +42,23 @@ type configuration struct {
 	Password      string
 	ConvertToAvro bool
 	AvroSchemas   []avroSchema
+	// EnableExactlyOnce enables the delivery of events within transactions, see ProducerManager.BeginTransaction
+	EnableExactlyOnce bool
+	// UseSchemaRegistry enables the serialization of events in the Schema Registry wire format
+	UseSchemaRegistry      bool
+	SchemaRegistryURL      string
+	SchemaRegistryUsername string
+	SchemaRegistryPassword string
+	// SchemaType is one of AVRO (default), PROTOBUF or JSON
+	SchemaType string
+	// SchemaSubject defaults to "<topic>-value"
+	SchemaSubject string
+	// RegistrySchema is registered under the subject if AutoRegisterSchema is enabled,
+	// otherwise the latest schema of the subject is used
+	RegistrySchema     string
+	AutoRegisterSchema bool
+	// ProtobufMessageName defaults to the first message of the Protobuf schema
+	ProtobufMessageName string
 }
 
 func (c *configuration) validate() error {
@@ -56,6 +75,17 @@ func (c *configuration) validate() error {
 	if port < 1 {
 		return fmt.Errorf("invalid port: %d", port)
 	}
+	if c.UseSchemaRegistry {
+		if c.ConvertToAvro {
+			return fmt.Errorf("convertToAvro cannot be used along with the schema registry")
+		}
+		if c.SchemaRegistryURL == "" {
+			return fmt.Errorf("schema registry url cannot be empty")
+		}
+		if _, err := schemaregistry.SchemaTypeFromString(c.SchemaType); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -111,11 +141,17 @@ type publisher interface {
 	Publish(context.Context, ...client.Message) error
 }
 
+// valueSerializer serializes message values, e.g. in the Schema Registry wire format
+type valueSerializer interface {
+	Serialize(ctx context.Context, topic string, value []byte) ([]byte, error)
+}
+
 type producerManager interface {
 	io.Closer
 	publisher
 	getTimeout() time.Duration
 	getCodecs() map[string]*goavro.Codec
+	getSerializer() valueSerializer
 }
 
 type internalProducer interface {
@@ -124,9 +160,11 @@ type internalProducer interface {
 }
 
 type ProducerManager struct {
-	p       internalProducer
-	timeout time.Duration
-	codecs  map[string]*goavro.Codec
+	p            internalProducer
+	timeout      time.Duration
+	codecs       map[string]*goavro.Codec
+	serializer   valueSerializer // nil unless the schema registry is enabled
+	transactions *transactions   // nil unless exactly-once delivery is enabled
 }
 
 func (p *ProducerManager) getTimeout() time.Duration {
@@ -140,6 +178,10 @@ func (p *ProducerManager) getCodecs() map[string]*goavro.Codec {
 	return p.codecs
 }
 
+func (p *ProducerManager) getSerializer() valueSerializer {
+	return p.serializer
+}
+
 type logger interface {
 	Error(args ...interface{})
 	Errorf(format string, args ...interface{})
@@ -157,14 +199,19 @@ type managerStats struct {
 	closeProducerTime          stats.Measurement
 	jsonSerializationMsgErr    stats.Measurement
 	avroSerializationErr       stats.Measurement
+	registrySerializationErr   stats.Measurement
 }
 
 const (
 	defaultPublishTimeout = 10 * time.Second
 )
 
+// errRegistryUnavailable is returned when events cannot be serialized because the schema registry is unavailable
+var errRegistryUnavailable = errors.New("schema registry unavailable")
+
 var (
-	_ producerManager = &ProducerManager{}
+	_ producerManager                    = &ProducerManager{}
+	_ common.TransactionalStreamProducer = &ProducerManager{}
 
 	clientCert, clientKey                []byte
 	kafkaDialTimeout                     = 10 * time.Second
@@ -172,6 +219,12 @@ var (
 	kafkaWriteTimeout                    = 2 * time.Second
 	kafkaBatchingEnabled                 bool
 	allowReqsWithoutUserIDAndAnonymousID bool
+	exactlyOnceTransactionTimeout        = 60 * time.Second
+	exactlyOnceTransactionLockTimeout    = 10 * time.Second
+	exactlyOnceTransactionPartitions     = 16
+	exactlyOnceMaxMetadataSize           = 4000
+	schemaRegistryTimeout                = 10 * time.Second
+	schemaRegistryRefreshInterval        = 5 * time.Minute
 
 	kafkaStats managerStats
 	pkgLogger  logger
@@ -211,6 +264,23 @@ func Init() {
 	config.RegisterBoolConfigVariable(
 		false, &allowReqsWithoutUserIDAndAnonymousID, true, "Gateway.allowReqsWithoutUserIDAndAnonymousID",
 	)
+	config.RegisterDurationConfigVariable(
+		60, &exactlyOnceTransactionTimeout, false, time.Second, "Router.KAFKA.exactlyOnce.transactionTimeout",
+	)
+	config.RegisterDurationConfigVariable(
+		10, &exactlyOnceTransactionLockTimeout, false, time.Second, "Router.KAFKA.exactlyOnce.transactionLockTimeout",
+	)
+	// changing the number of partitions moves users to other transactional ids, whose committed jobs are unknown
+	config.RegisterIntConfigVariable(16, &exactlyOnceTransactionPartitions, false, 1, "Router.KAFKA.exactlyOnce.partitions")
+	config.RegisterDurationConfigVariable(
+		10, &schemaRegistryTimeout, false, time.Second, "Router.KAFKA.schemaRegistryTimeout",
+	)
+	config.RegisterDurationConfigVariable(
+		5, &schemaRegistryRefreshInterval, false, time.Minute, "Router.KAFKA.schemaRegistryRefreshInterval",
+	)
+	// the committed job ids are stored as offset metadata, which Kafka limits to 4096 bytes by default.
+	// The oldest ranges are dropped once they do not fit, bounding how far back retried jobs are deduplicated.
+	config.RegisterIntConfigVariable(4000, &exactlyOnceMaxMetadataSize, false, 1, "Router.KAFKA.exactlyOnce.maxMetadataSize")
 
 	pkgLogger = rslogger.NewLogger().Child("streammanager").Child("kafka")
 	kafkaStats = managerStats{
@@ -225,6 +295,7 @@ func Init() {
 		closeProducerTime:          stats.Default.NewStat("router.kafka.close_producer_time", stats.TimerType),
 		jsonSerializationMsgErr:    stats.Default.NewStat("router.kafka.json_serialization_msg_err", stats.CountType),
 		avroSerializationErr:       stats.Default.NewStat("router.kafka.avro_serialization_err", stats.CountType),
+		registrySerializationErr:   stats.Default.NewStat("router.kafka.registry_serialization_err", stats.CountType),
 	}
 }
 
@@ -319,7 +390,26 @@ func NewProducer(destination *backendconfig.DestinationT, o common.Opts) (*Produ
 	if err != nil {
 		return nil, err
 	}
-	return &ProducerManager{p: p, timeout: o.Timeout, codecs: codecs}, nil
+	pm := &ProducerManager{p: p, timeout: o.Timeout, codecs: codecs}
+	if destConfig.UseSchemaRegistry {
+		if pm.serializer, err = newRegistrySerializer(&destConfig); err != nil {
+			return nil, fmt.Errorf("[Kafka] invalid schema registry configuration: %w", err)
+		}
+	}
+	if destConfig.EnableExactlyOnce {
+		transactionalIDPrefix := "rudder-" + destination.ID
+		if instanceID := config.GetInstanceID(); instanceID != "" {
+			transactionalIDPrefix = "rudder-" + instanceID + "-" + destination.ID
+		}
+		pm.transactions = newTransactions(transactionalIDPrefix, func(transactionalID string) (transactionalProducer, error) {
+			return c.NewTransactionalProducer(destConfig.Topic, client.TransactionalProducerConfig{
+				TransactionalID:    transactionalID,
+				TransactionTimeout: exactlyOnceTransactionTimeout,
+				WriteTimeout:       kafkaWriteTimeout,
+			})
+		})
+	}
+	return pm, nil
 }
 
 // NewProducerForAzureEventHubs creates a producer for Azure event hub based on destination config
@@ -425,6 +515,30 @@ func NewProducerForConfluentCloud(destination *backendconfig.DestinationT, o com
 	return &ProducerManager{p: p, timeout: o.Timeout}, nil
 }
 
+func newRegistrySerializer(destConfig *configuration) (*schemaregistry.Serializer, error) {
+	schemaType, err := schemaregistry.SchemaTypeFromString(destConfig.SchemaType)
+	if err != nil {
+		return nil, err
+	}
+	registryClient, err := schemaregistry.NewClient(schemaregistry.Config{
+		URL:      destConfig.SchemaRegistryURL,
+		Username: destConfig.SchemaRegistryUsername,
+		Password: destConfig.SchemaRegistryPassword,
+		Timeout:  schemaRegistryTimeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return schemaregistry.NewSerializer(registryClient, schemaregistry.SerializerConfig{
+		Type:            schemaType,
+		Subject:         destConfig.SchemaSubject,
+		Schema:          destConfig.RegistrySchema,
+		AutoRegister:    destConfig.AutoRegisterSchema,
+		MessageName:     destConfig.ProtobufMessageName,
+		RefreshInterval: schemaRegistryRefreshInterval,
+	})
+}
+
 func prepareMessage(topic, key string, message []byte, timestamp time.Time) client.Message {
 	return client.Message{
 		Topic:     topic,
@@ -450,7 +564,7 @@ func serializeAvroMessage(value []byte, codec goavro.Codec) ([]byte, error) {
 	return binary, nil
 }
 
-func prepareBatchOfMessages(topic string, batch []map[string]interface{}, timestamp time.Time, p producerManager) (
+func prepareBatchOfMessages(ctx context.Context, topic string, batch []map[string]interface{}, timestamp time.Time, p producerManager) (
 	[]client.Message, error,
 ) {
 	start := now()
@@ -497,6 +611,18 @@ func prepareBatchOfMessages(topic string, batch []map[string]interface{}, timest
 				continue
 			}
 		}
+		if serializer := p.getSerializer(); serializer != nil {
+			marshalledMsg, err = serializer.Serialize(ctx, topic, marshalledMsg)
+			if err != nil {
+				kafkaStats.registrySerializationErr.Increment()
+				if isTemporaryRegistryError(err) {
+					// the whole batch is retried rather than dropping events which could be serialized later on
+					return nil, fmt.Errorf("%w: unable to serialize the event of index: %d: %v", errRegistryUnavailable, i, err)
+				}
+				pkgLogger.Errorf("unable to serialize the event of index: %d with the schema registry, with error: %s", i, err)
+				continue
+			}
+		}
 		messages = append(messages, prepareMessage(topic, userID, marshalledMsg, timestamp))
 	}
 	if len(messages) == 0 {
@@ -514,6 +640,10 @@ func (p *ProducerManager) Close() error {
 	start := now()
 	defer func() { kafkaStats.closeProducerTime.SendTiming(since(start)) }()
 
+	if p.transactions != nil {
+		p.transactions.close()
+	}
+
 	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Second)
 	defer cancel()
 	if err := p.p.Close(ctx); err != nil {
@@ -559,6 +689,74 @@ func (p *ProducerManager) Produce(jsonData json.RawMessage, destConfig interface
 	return sendMessage(ctx, jsonData, p, conf.Topic)
 }
 
+// IsTransactional returns true if exactly-once delivery is enabled
+func (p *ProducerManager) IsTransactional() bool {
+	return p.transactions != nil
+}
+
+// TransactionPartition returns the partition of the transactions delivering the events of the user.
+// Partitions do not depend on the router workers, so that a job is always checked against and committed with the
+// jobs of the same partition.
+func (p *ProducerManager) TransactionPartition(userID string) int {
+	if p.transactions == nil {
+		return 0
+	}
+	return p.transactions.partition(userID)
+}
+
+// BeginTransaction opens a new transaction for the partition, waiting for any other open transaction of the
+// partition to be committed or aborted.
+// The first transaction of a partition loads the ids of the jobs committed before the last restart.
+func (p *ProducerManager) BeginTransaction(partition int) error {
+	if p.transactions == nil {
+		return fmt.Errorf("exactly-once delivery is not enabled")
+	}
+	return p.transactions.begin(partition)
+}
+
+// ProduceInTransaction sends the data within the open transaction of the partition.
+// Jobs which have already been delivered by a committed transaction are not produced again.
+func (p *ProducerManager) ProduceInTransaction(
+	partition int, jobIDs []int64, jsonData json.RawMessage, destConfig interface{},
+) (int, string, string) {
+	if p.transactions == nil {
+		return 400, "Failure", "Exactly-once delivery is not enabled"
+	}
+
+	start := now()
+	defer func() { kafkaStats.produceTime.SendTiming(since(start)) }()
+
+	conf := configuration{}
+	jsonConfig, err := json.Marshal(destConfig)
+	if err != nil {
+		return makeErrorResponse(err) // returning 500 for retrying, in case of bad configuration
+	}
+	err = json.Unmarshal(jsonConfig, &conf)
+	if err != nil {
+		return makeErrorResponse(err) // returning 500 for retrying, in case of bad configuration
+	}
+	if conf.Topic == "" {
+		return makeErrorResponse(fmt.Errorf("invalid destination configuration: no topic"))
+	}
+	return p.transactions.produce(partition, jobIDs, jsonData, conf.Topic, p.getTimeout(), p.codecs, p.serializer)
+}
+
+// CommitTransaction commits the open transaction of the partition along with the ids of the delivered jobs
+func (p *ProducerManager) CommitTransaction(partition int) error {
+	if p.transactions == nil {
+		return fmt.Errorf("exactly-once delivery is not enabled")
+	}
+	return p.transactions.commit(partition)
+}
+
+// AbortTransaction aborts the open transaction of the partition
+func (p *ProducerManager) AbortTransaction(partition int) error {
+	if p.transactions == nil {
+		return fmt.Errorf("exactly-once delivery is not enabled")
+	}
+	return p.transactions.abort(partition)
+}
+
 func sendBatchedMessage(ctx context.Context, jsonData json.RawMessage, p producerManager, topic string) (int, string, string) {
 	var batch []map[string]interface{}
 	err := json.Unmarshal(jsonData, &batch)
@@ -567,8 +765,11 @@ func sendBatchedMessage(ctx context.Context, jsonData json.RawMessage, p produce
 	}
 
 	timestamp := time.Now()
-	batchOfMessages, err := prepareBatchOfMessages(topic, batch, timestamp, p)
+	batchOfMessages, err := prepareBatchOfMessages(ctx, topic, batch, timestamp, p)
 	if err != nil {
+		if errors.Is(err, errRegistryUnavailable) {
+			return 500, "Failure", err.Error() // would retry the batch once the registry is available
+		}
 		return 400, "Failure", "Error while preparing batched message: " + err.Error()
 	}
 
@@ -611,6 +812,12 @@ func sendMessage(ctx context.Context, jsonData json.RawMessage, p producerManage
 			return makeErrorResponse(fmt.Errorf("unable to serialize event with messageId: %s, with error %s", messageId, err))
 		}
 	}
+	if serializer := p.getSerializer(); serializer != nil {
+		value, err = serializer.Serialize(ctx, topic, value)
+		if err != nil {
+			return makeRegistryErrorResponse(err)
+		}
+	}
 	message := prepareMessage(topic, userID, value, timestamp)
 	if err = publish(ctx, p, message); err != nil {
 		return makeErrorResponse(fmt.Errorf("could not publish to %q: %w", topic, err))
@@ -632,6 +839,23 @@ func makeErrorResponse(err error) (int, string, string) {
 	return getStatusCodeFromError(err), returnMessage, err.Error()
 }
 
+// makeRegistryErrorResponse returns 400 for events not matching the schema and 500 for any other error,
+// e.g. the registry being unavailable, so that the event gets retried
+func makeRegistryErrorResponse(err error) (int, string, string) {
+	returnMessage := fmt.Sprintf("%s error occurred.", err)
+	pkgLogger.Error(returnMessage)
+	if !isTemporaryRegistryError(err) {
+		return 400, returnMessage, err.Error()
+	}
+	return 500, returnMessage, err.Error()
+}
+
+// isTemporaryRegistryError returns true if the error occurred while talking to the schema registry and retrying
+// might succeed
+func isTemporaryRegistryError(err error) bool {
+	return !errors.Is(err, schemaregistry.ErrInvalidMessage) && schemaregistry.IsTemporary(err)
+}
+
 // getStatusCodeFromError parses the error and returns the status so that event gets retried or failed.
 func getStatusCodeFromError(err error) int {
 	if client.IsProducerErrTemporary(err) {
@@ -19,6 +19,8 @@ import (
 	mockStats "github.com/rudderlabs/rudder-server/mocks/services/stats"
 	"github.com/rudderlabs/rudder-server/services/streammanager/common"
 	"github.com/rudderlabs/rudder-server/services/streammanager/kafka/client"
+	"github.com/rudderlabs/rudder-server/services/streammanager/kafka/schemaregistry"
+	"github.com/rudderlabs/rudder-server/services/streammanager/kafka/schemaregistry/registrytest"
 	"github.com/rudderlabs/rudder-server/testhelper/destination"
 )
 
@@ -95,6 +97,55 @@ func TestNewProducer(t *testing.T) {
 			require.Nil(t, p)
 			require.ErrorContains(t, err, `invalid configuration: invalid port: 0`)
 		})
+		t.Run("schema registry without url", func(t *testing.T) {
+			kafkaStats.creationTime = getMockedTimer(t, gomock.NewController(t))
+
+			destConfig := map[string]interface{}{
+				"topic":             "some-topic",
+				"hostname":          "some-hostname",
+				"port":              "9090",
+				"useSchemaRegistry": true,
+			}
+			dest := backendconfig.DestinationT{Config: destConfig}
+
+			p, err := NewProducer(&dest, common.Opts{})
+			require.Nil(t, p)
+			require.ErrorContains(t, err, "invalid configuration: schema registry url cannot be empty")
+		})
+		t.Run("schema registry with invalid schema type", func(t *testing.T) {
+			kafkaStats.creationTime = getMockedTimer(t, gomock.NewController(t))
+
+			destConfig := map[string]interface{}{
+				"topic":             "some-topic",
+				"hostname":          "some-hostname",
+				"port":              "9090",
+				"useSchemaRegistry": true,
+				"schemaRegistryURL": "http://localhost:8081",
+				"schemaType":        "XML",
+			}
+			dest := backendconfig.DestinationT{Config: destConfig}
+
+			p, err := NewProducer(&dest, common.Opts{})
+			require.Nil(t, p)
+			require.ErrorContains(t, err, `invalid configuration: unknown schema type: "XML"`)
+		})
+		t.Run("schema registry with avro conversion", func(t *testing.T) {
+			kafkaStats.creationTime = getMockedTimer(t, gomock.NewController(t))
+
+			destConfig := map[string]interface{}{
+				"topic":             "some-topic",
+				"hostname":          "some-hostname",
+				"port":              "9090",
+				"convertToAvro":     true,
+				"useSchemaRegistry": true,
+				"schemaRegistryURL": "http://localhost:8081",
+			}
+			dest := backendconfig.DestinationT{Config: destConfig}
+
+			p, err := NewProducer(&dest, common.Opts{})
+			require.Nil(t, p)
+			require.ErrorContains(t, err, "invalid configuration: convertToAvro cannot be used along with the schema registry")
+		})
 		t.Run("invalid schema", func(t *testing.T) {
 			kafkaStats.creationTime = getMockedTimer(t, gomock.NewController(t))
 
@@ -320,7 +371,7 @@ func TestPrepareBatchOfMessages(t *testing.T) {
 
 		var data []map[string]interface{}
 		pm := &ProducerManager{p: &pMockErr{error: nil}}
-		batch, err := prepareBatchOfMessages("some-topic", data, time.Now(), pm)
+		batch, err := prepareBatchOfMessages(context.Background(), "some-topic", data, time.Now(), pm)
 		require.Equal(t, []client.Message(nil), batch)
 		require.Equal(t, fmt.Errorf("unable to process any of the event in the batch"), err)
 	})
@@ -332,7 +383,7 @@ func TestPrepareBatchOfMessages(t *testing.T) {
 		data := []map[string]interface{}{{
 			"not-interesting": "some value",
 		}}
-		batch, err := prepareBatchOfMessages("some-topic", data, time.Now(), pm)
+		batch, err := prepareBatchOfMessages(context.Background(), "some-topic", data, time.Now(), pm)
 		require.Equal(t, []client.Message(nil), batch)
 		require.Equal(t, fmt.Errorf("unable to process any of the event in the batch"), err)
 	})
@@ -350,7 +401,7 @@ func TestPrepareBatchOfMessages(t *testing.T) {
 			{"message": map[string]interface{}{"a": 1, "b": 2}, "userId": "456"},
 		}
 		pm := &ProducerManager{p: &pMockErr{error: nil}}
-		batch, err := prepareBatchOfMessages("some-topic", data, now, pm)
+		batch, err := prepareBatchOfMessages(context.Background(), "some-topic", data, now, pm)
 		require.NoError(t, err)
 		require.ElementsMatch(t, []client.Message{
 			{
@@ -379,7 +430,7 @@ func TestPrepareBatchOfMessages(t *testing.T) {
 			{"message": "msg01"},
 		}
 		pm := &ProducerManager{p: &pMockErr{error: nil}}
-		batch, err := prepareBatchOfMessages("some-topic", data, now, pm)
+		batch, err := prepareBatchOfMessages(context.Background(), "some-topic", data, now, pm)
 		require.NoError(t, err)
 		require.ElementsMatch(t, []client.Message{
 			{
@@ -602,6 +653,110 @@ func TestSendBatchedMessage(t *testing.T) {
 		require.InDelta(t, time.Now().Unix(), p.calls[0][0].Timestamp.Unix(), 1)
 	})
 
+	t.Run("schema registry", func(t *testing.T) {
+		registry := registrytest.New()
+		t.Cleanup(registry.Close)
+		id := registry.Register("some-topic-value", "", `{
+			"type": "record",
+			"name": "myrecord",
+			"fields": [{"name": "uid", "type": "int"}, {"name": "somefield", "type": "string"}]
+		}`)
+		registryClient, err := schemaregistry.NewClient(schemaregistry.Config{URL: registry.URL})
+		require.NoError(t, err)
+		serializer, err := schemaregistry.NewSerializer(registryClient, schemaregistry.SerializerConfig{Type: schemaregistry.Avro})
+		require.NoError(t, err)
+
+		t.Run("ok", func(t *testing.T) {
+			kafkaStats.publishTime = getMockedTimer(t, gomock.NewController(t))
+
+			p := &pMockErr{error: nil}
+			pm := &ProducerManager{p: p, serializer: serializer}
+			sc, res, err := sendMessage(
+				context.Background(),
+				json.RawMessage(`{"message":{"uid":1,"somefield":"hello"},"userId":"123"}`),
+				pm,
+				"some-topic",
+			)
+			require.Equal(t, 200, sc)
+			require.Equal(t, "Message delivered to topic: some-topic", res)
+			require.Equal(t, "Message delivered to topic: some-topic", err)
+			require.Len(t, p.calls, 1)
+			require.Len(t, p.calls[0], 1)
+			value := p.calls[0][0].Value
+			require.Equal(t, []byte{0, 0, 0, 0, byte(id)}, value[:5], "value should start with magic byte and schema id")
+		})
+
+		t.Run("invalid message", func(t *testing.T) {
+			p := &pMockErr{error: nil}
+			pm := &ProducerManager{p: p, serializer: serializer}
+			sc, _, err := sendMessage(
+				context.Background(),
+				json.RawMessage(`{"message":{"uid":"not a number"},"userId":"123"}`),
+				pm,
+				"some-topic",
+			)
+			require.Equal(t, 400, sc)
+			require.Contains(t, err, "message does not match the schema")
+			require.Empty(t, p.calls)
+		})
+
+		t.Run("schema of another type", func(t *testing.T) {
+			registry.Register("json-topic-value", "JSON", `{"type":"object"}`)
+			p := &pMockErr{error: nil}
+			pm := &ProducerManager{p: p, serializer: serializer}
+			sc, _, err := sendMessage(
+				context.Background(),
+				json.RawMessage(`{"message":{"uid":1,"somefield":"hello"},"userId":"123"}`),
+				pm,
+				"json-topic",
+			)
+			require.Equal(t, 400, sc, "messages should not be retried when their schema cannot be used: %s", err)
+			require.Empty(t, p.calls)
+		})
+
+		t.Run("registry unavailable", func(t *testing.T) {
+			unavailableClient, err := schemaregistry.NewClient(schemaregistry.Config{URL: "http://localhost:1"})
+			require.NoError(t, err)
+			unavailableSerializer, err := schemaregistry.NewSerializer(unavailableClient, schemaregistry.SerializerConfig{})
+			require.NoError(t, err)
+
+			p := &pMockErr{error: nil}
+			pm := &ProducerManager{p: p, serializer: unavailableSerializer}
+			sc, _, _ := sendMessage(
+				context.Background(),
+				json.RawMessage(`{"message":{"uid":1,"somefield":"hello"},"userId":"123"}`),
+				pm,
+				"some-topic",
+			)
+			require.Equal(t, 500, sc, "messages should be retried while the registry is unavailable")
+			require.Empty(t, p.calls)
+		})
+
+		t.Run("registry unavailable with batching", func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			kafkaStats.prepareBatchTime = getMockedTimer(t, ctrl)
+			registrySerializationErr := mockStats.NewMockMeasurement(ctrl)
+			registrySerializationErr.EXPECT().Increment().Times(1)
+			kafkaStats.registrySerializationErr = registrySerializationErr
+
+			unavailableClient, err := schemaregistry.NewClient(schemaregistry.Config{URL: "http://localhost:1"})
+			require.NoError(t, err)
+			unavailableSerializer, err := schemaregistry.NewSerializer(unavailableClient, schemaregistry.SerializerConfig{})
+			require.NoError(t, err)
+
+			p := &pMockErr{error: nil}
+			pm := &ProducerManager{p: p, serializer: unavailableSerializer}
+			sc, _, _ := sendBatchedMessage(
+				context.Background(),
+				json.RawMessage(`[{"message":{"uid":1,"somefield":"hello"},"userId":"123"},{"message":{"uid":2,"somefield":"hello"},"userId":"456"}]`),
+				pm,
+				"some-topic",
+			)
+			require.Equal(t, 500, sc, "the batch should be retried while the registry is unavailable")
+			require.Empty(t, p.calls)
+		})
+	})
+
 	t.Run("schemaId not available", func(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		kafkaStats.prepareBatchTime = getMockedTimer(t, ctrl)
@@ -825,6 +980,7 @@ func (*pmMockErr) getTimeout() time.Duration                            { return
 func (pm *pmMockErr) getCodecs() map[string]*goavro.Codec {
 	return pm.codecs
 }
+func (*pmMockErr) getSerializer() valueSerializer { return nil }
 
 type pMockErr struct {
 	error error
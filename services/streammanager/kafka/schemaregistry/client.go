@@ -0,0 +1,243 @@
+// Package schemaregistry implements a client for the Confluent Schema Registry along with serializers producing
+// messages in the registry wire format, i.e. a magic byte and the schema id followed by the encoded payload.
+package schemaregistry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SchemaType is the format of a schema as reported by the registry
+type SchemaType string
+
+const (
+	Avro       SchemaType = "AVRO"
+	Protobuf   SchemaType = "PROTOBUF"
+	JSONSchema SchemaType = "JSON"
+
+	contentType    = "application/vnd.schemaregistry.v1+json"
+	defaultTimeout = 10 * time.Second
+)
+
+// SchemaTypeFromString returns the schema type matching the provided string (case-insensitive).
+// An empty string defaults to Avro like the registry does.
+func SchemaTypeFromString(s string) (SchemaType, error) {
+	switch SchemaType(strings.ToUpper(s)) {
+	case "", Avro:
+		return Avro, nil
+	case Protobuf:
+		return Protobuf, nil
+	case JSONSchema, "JSONSCHEMA", "JSON_SCHEMA":
+		return JSONSchema, nil
+	default:
+		return "", fmt.Errorf("unknown schema type: %q", s)
+	}
+}
+
+// Schema is a schema registered under a subject
+type Schema struct {
+	ID      int
+	Subject string
+	Version int
+	Type    SchemaType
+	Schema  string
+}
+
+// Error is an error returned by the registry API
+type Error struct {
+	StatusCode int
+	ErrorCode  int    `json:"error_code"`
+	Message    string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("schema registry error %d (status %d): %s", e.ErrorCode, e.StatusCode, e.Message)
+}
+
+// IsNotFound returns true if the error reports that a subject, version or schema does not exist
+func IsNotFound(err error) bool {
+	var registryErr *Error
+	return errors.As(err, &registryErr) && registryErr.StatusCode == http.StatusNotFound
+}
+
+// IsTemporary returns true if the request might succeed when retried, i.e. if the registry could not be reached,
+// timed out or replied with a server error. Any other error, e.g. a schema that cannot be compiled, is permanent.
+func IsTemporary(err error) bool {
+	var registryErr *Error
+	if errors.As(err, &registryErr) {
+		return registryErr.StatusCode >= 500 || registryErr.StatusCode == http.StatusTooManyRequests
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+type Config struct {
+	URL      string
+	Username string
+	Password string
+	Timeout  time.Duration
+}
+
+// Client is a Schema Registry client caching the schemas it looks up by id, so that the registry is queried at most
+// once per schema id for the lifetime of the client. Schemas are immutable, while the latest schema of a subject is
+// always looked up since new versions can be registered at any time.
+type Client struct {
+	baseURL    *url.URL
+	config     Config
+	httpClient *http.Client
+
+	mu   sync.RWMutex
+	byID map[int]*Schema
+}
+
+// NewClient returns a new Schema Registry client
+func NewClient(conf Config) (*Client, error) {
+	if conf.URL == "" {
+		return nil, fmt.Errorf("schema registry url cannot be empty")
+	}
+	baseURL, err := url.Parse(strings.TrimSuffix(conf.URL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema registry url: %w", err)
+	}
+	if baseURL.Scheme != "http" && baseURL.Scheme != "https" {
+		return nil, fmt.Errorf("invalid schema registry url scheme: %q", baseURL.Scheme)
+	}
+	if conf.Timeout < 1 {
+		conf.Timeout = defaultTimeout
+	}
+	return &Client{
+		baseURL:    baseURL,
+		config:     conf,
+		httpClient: &http.Client{Timeout: conf.Timeout},
+		byID:       make(map[int]*Schema),
+	}, nil
+}
+
+type schemaResponse struct {
+	ID         int        `json:"id"`
+	Subject    string     `json:"subject"`
+	Version    int        `json:"version"`
+	SchemaType SchemaType `json:"schemaType"`
+	Schema     string     `json:"schema"`
+}
+
+func (r *schemaResponse) toSchema() *Schema {
+	schemaType := r.SchemaType
+	if schemaType == "" {
+		schemaType = Avro
+	}
+	return &Schema{ID: r.ID, Subject: r.Subject, Version: r.Version, Type: schemaType, Schema: r.Schema}
+}
+
+// GetLatestSchema returns the latest version of the schema registered under the subject
+func (c *Client) GetLatestSchema(ctx context.Context, subject string) (*Schema, error) {
+	var resp schemaResponse
+	if err := c.do(ctx, http.MethodGet, "/subjects/"+url.PathEscape(subject)+"/versions/latest", nil, &resp); err != nil {
+		return nil, fmt.Errorf("could not get latest schema of subject %q: %w", subject, err)
+	}
+	schema := resp.toSchema()
+	c.cache(schema)
+	return schema, nil
+}
+
+// GetSchemaByID returns the schema with the provided id
+func (c *Client) GetSchemaByID(ctx context.Context, id int) (*Schema, error) {
+	c.mu.RLock()
+	schema, ok := c.byID[id]
+	c.mu.RUnlock()
+	if ok {
+		return schema, nil
+	}
+
+	var resp schemaResponse
+	if err := c.do(ctx, http.MethodGet, "/schemas/ids/"+strconv.Itoa(id), nil, &resp); err != nil {
+		return nil, fmt.Errorf("could not get schema %d: %w", id, err)
+	}
+	resp.ID = id
+	schema = resp.toSchema()
+	c.mu.Lock()
+	c.byID[id] = schema
+	c.mu.Unlock()
+	return schema, nil
+}
+
+// Register registers the schema under the subject returning its id.
+// Registering a schema that is already registered under the subject returns the id of the existing schema.
+func (c *Client) Register(ctx context.Context, subject string, schemaType SchemaType, schema string) (*Schema, error) {
+	req := struct {
+		SchemaType SchemaType `json:"schemaType,omitempty"`
+		Schema     string     `json:"schema"`
+	}{Schema: schema}
+	if schemaType != Avro {
+		req.SchemaType = schemaType
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal schema: %w", err)
+	}
+
+	var resp schemaResponse
+	if err = c.do(ctx, http.MethodPost, "/subjects/"+url.PathEscape(subject)+"/versions", body, &resp); err != nil {
+		return nil, fmt.Errorf("could not register schema under subject %q: %w", subject, err)
+	}
+	registered := &Schema{ID: resp.ID, Subject: subject, Type: schemaType, Schema: schema}
+	c.cache(registered)
+	return registered, nil
+}
+
+func (c *Client) cache(schema *Schema) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.byID[schema.ID] = schema
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body []byte, v interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		reqBody = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL.String()+path, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", contentType)
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.config.Username != "" || c.config.Password != "" {
+		req.SetBasicAuth(c.config.Username, c.config.Password)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("could not read response body: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		registryErr := &Error{StatusCode: resp.StatusCode}
+		if err = json.Unmarshal(respBody, registryErr); err != nil || registryErr.Message == "" {
+			registryErr.Message = string(respBody)
+		}
+		return registryErr
+	}
+	if err = json.Unmarshal(respBody, v); err != nil {
+		return fmt.Errorf("could not unmarshal response body: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package schemaregistry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rudderlabs/rudder-server/services/streammanager/kafka/schemaregistry/registrytest"
+)
+
+func TestClient(t *testing.T) {
+	registry := registrytest.New()
+	t.Cleanup(registry.Close)
+	ctx := context.Background()
+
+	c, err := NewClient(Config{URL: registry.URL})
+	require.NoError(t, err)
+
+	t.Run("subject not found", func(t *testing.T) {
+		_, err := c.GetLatestSchema(ctx, "unknown-value")
+		require.Error(t, err)
+		require.True(t, IsNotFound(err))
+		require.False(t, IsTemporary(err))
+	})
+
+	t.Run("server errors are temporary", func(t *testing.T) {
+		for status, temporary := range map[int]bool{
+			http.StatusServiceUnavailable:  true,
+			http.StatusTooManyRequests:     true,
+			http.StatusUnprocessableEntity: false,
+		} {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(status)
+			}))
+			c, err := NewClient(Config{URL: srv.URL})
+			require.NoError(t, err)
+			_, err = c.GetLatestSchema(ctx, "s")
+			srv.Close()
+			require.Error(t, err)
+			require.Equal(t, temporary, IsTemporary(err), status)
+		}
+	})
+
+	t.Run("network errors are temporary", func(t *testing.T) {
+		c, err := NewClient(Config{URL: "http://localhost:1"})
+		require.NoError(t, err)
+		_, err = c.GetLatestSchema(ctx, "s")
+		require.Error(t, err)
+		require.True(t, IsTemporary(err))
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+		}))
+		t.Cleanup(srv.Close)
+		c, err = NewClient(Config{URL: srv.URL, Timeout: 10 * time.Millisecond})
+		require.NoError(t, err)
+		_, err = c.GetLatestSchema(ctx, "s")
+		require.Error(t, err)
+		require.True(t, IsTemporary(err), "timeouts should be temporary")
+	})
+
+	t.Run("local errors are permanent", func(t *testing.T) {
+		require.False(t, IsTemporary(errors.New("schema cannot be compiled")))
+		require.False(t, IsTemporary(fmt.Errorf("%w: invalid value", ErrInvalidMessage)))
+	})
+
+	t.Run("latest schema is cached by id", func(t *testing.T) {
+		registry.Register("cached-value", "", `"string"`)
+		id := registry.Register("cached-value", "PROTOBUF", `syntax = "proto3"; message A { string a = 1; }`)
+
+		requests := registry.Requests()
+		schema, err := c.GetLatestSchema(ctx, "cached-value")
+		require.NoError(t, err)
+		require.Equal(t, &Schema{
+			ID:      id,
+			Subject: "cached-value",
+			Version: 2,
+			Type:    Protobuf,
+			Schema:  `syntax = "proto3"; message A { string a = 1; }`,
+		}, schema)
+
+		byID, err := c.GetSchemaByID(ctx, id)
+		require.NoError(t, err)
+		require.Equal(t, schema, byID)
+		require.Equal(t, requests+1, registry.Requests())
+
+		newID := registry.Register("cached-value", "", `"int"`)
+		latest, err := c.GetLatestSchema(ctx, "cached-value")
+		require.NoError(t, err)
+		require.Equal(t, newID, latest.ID, "new versions of the subject should be picked up")
+	})
+
+	t.Run("register", func(t *testing.T) {
+		schema, err := c.Register(ctx, "registered-value", JSONSchema, `{"type":"object"}`)
+		require.NoError(t, err)
+		again, err := c.Register(ctx, "registered-value", JSONSchema, `{"type":"object"}`)
+		require.NoError(t, err)
+		require.Equal(t, schema.ID, again.ID)
+
+		byID, err := c.GetSchemaByID(ctx, schema.ID)
+		require.NoError(t, err)
+		require.Equal(t, JSONSchema, byID.Type)
+		require.Equal(t, `{"type":"object"}`, byID.Schema)
+	})
+
+	t.Run("basic auth", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			username, password, ok := r.BasicAuth()
+			if !ok || username != "user" || password != "pass" {
+				w.WriteHeader(http.StatusUnauthorized)
+				_, _ = w.Write([]byte(`{"error_code":401,"message":"Unauthorized"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"id":1,"subject":"s","version":1,"schema":"\"string\""}`))
+		}))
+		t.Cleanup(srv.Close)
+
+		c, err := NewClient(Config{URL: srv.URL, Username: "user", Password: "pass"})
+		require.NoError(t, err)
+		schema, err := c.GetLatestSchema(ctx, "s")
+		require.NoError(t, err)
+		require.Equal(t, Avro, schema.Type)
+
+		c, err = NewClient(Config{URL: srv.URL, Username: "user", Password: "wrong"})
+		require.NoError(t, err)
+		_, err = c.GetLatestSchema(ctx, "s")
+		require.ErrorContains(t, err, "Unauthorized")
+	})
+
+	t.Run("invalid configuration", func(t *testing.T) {
+		_, err := NewClient(Config{})
+		require.Error(t, err)
+		_, err = NewClient(Config{URL: "ftp://localhost"})
+		require.Error(t, err)
+	})
+}
+
+func TestSchemaTypeFromString(t *testing.T) {
+	for input, expected := range map[string]SchemaType{
+		"": Avro, "avro": Avro, "PROTOBUF": Protobuf, "json": JSONSchema, "JSON_SCHEMA": JSONSchema,
+	} {
+		schemaType, err := SchemaTypeFromString(input)
+		require.NoError(t, err)
+		require.Equal(t, expected, schemaType)
+	}
+	_, err := SchemaTypeFromString("xml")
+	require.Error(t, err)
+}
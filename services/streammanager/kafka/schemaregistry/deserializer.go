@@ -0,0 +1,84 @@
+package schemaregistry
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/linkedin/goavro"
+)
+
+// Deserializer decodes messages in the registry wire format into JSON.
+// Avro and JSON Schema encoded messages are supported.
+type Deserializer struct {
+	client *Client
+
+	mu     sync.Mutex
+	codecs map[int]*goavro.Codec // keyed by schema id
+}
+
+// NewDeserializer returns a new deserializer using the provided client
+func NewDeserializer(client *Client) (*Deserializer, error) {
+	if client == nil {
+		return nil, fmt.Errorf("schema registry client cannot be nil")
+	}
+	return &Deserializer{client: client, codecs: make(map[int]*goavro.Codec)}, nil
+}
+
+// Deserialize looks up the schema referenced by the message and returns the message decoded as JSON.
+// Messages that are not in the registry wire format or that cannot be decoded with their schema produce an error
+// wrapping ErrInvalidMessage.
+func (d *Deserializer) Deserialize(ctx context.Context, data []byte) (json.RawMessage, error) {
+	if len(data) < 5 || data[0] != magicByte {
+		return nil, fmt.Errorf("%w: not in the schema registry wire format", ErrInvalidMessage)
+	}
+	id := int(binary.BigEndian.Uint32(data[1:5]))
+	schema, err := d.client.GetSchemaByID(ctx, id)
+	if err != nil {
+		if IsNotFound(err) {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidMessage, err)
+		}
+		return nil, err
+	}
+
+	payload := data[5:]
+	switch schema.Type {
+	case Avro:
+		codec, err := d.codec(schema)
+		if err != nil {
+			return nil, err
+		}
+		native, _, err := codec.NativeFromBinary(payload)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidMessage, err)
+		}
+		decoded, err := json.Marshal(native)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidMessage, err)
+		}
+		return decoded, nil
+	case JSONSchema:
+		if !json.Valid(payload) {
+			return nil, fmt.Errorf("%w: invalid JSON", ErrInvalidMessage)
+		}
+		return payload, nil
+	default:
+		return nil, fmt.Errorf("%w: unsupported schema type %s", ErrInvalidMessage, schema.Type)
+	}
+}
+
+func (d *Deserializer) codec(schema *Schema) (*goavro.Codec, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if codec, ok := d.codecs[schema.ID]; ok {
+		return codec, nil
+	}
+	codec, err := goavro.NewCodec(schema.Schema)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid schema %d: %v", ErrInvalidMessage, schema.ID, err)
+	}
+	d.codecs[schema.ID] = codec
+	return codec, nil
+}
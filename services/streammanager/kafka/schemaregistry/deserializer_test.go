@@ -0,0 +1,57 @@
+package schemaregistry
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rudderlabs/rudder-server/services/streammanager/kafka/schemaregistry/registrytest"
+)
+
+func TestDeserializer(t *testing.T) {
+	registry := registrytest.New()
+	t.Cleanup(registry.Close)
+	ctx := context.Background()
+
+	c, err := NewClient(Config{URL: registry.URL})
+	require.NoError(t, err)
+	d, err := NewDeserializer(c)
+	require.NoError(t, err)
+
+	t.Run("avro", func(t *testing.T) {
+		registry.Register("avro-topic-value", "", avroSchema)
+		s, err := NewSerializer(c, SerializerConfig{Type: Avro})
+		require.NoError(t, err)
+		serialized, err := s.Serialize(ctx, "avro-topic", []byte(`{"uid":1,"somefield":"hello"}`))
+		require.NoError(t, err)
+
+		decoded, err := d.Deserialize(ctx, serialized)
+		require.NoError(t, err)
+		require.JSONEq(t, `{"uid":1,"somefield":"hello"}`, string(decoded))
+
+		_, err = d.Deserialize(ctx, serialized[:6])
+		require.ErrorIs(t, err, ErrInvalidMessage)
+	})
+
+	t.Run("json schema", func(t *testing.T) {
+		s, err := NewSerializer(c, SerializerConfig{Type: JSONSchema, Schema: jsonSchema, AutoRegister: true})
+		require.NoError(t, err)
+		serialized, err := s.Serialize(ctx, "json-topic", []byte(`{"uid":1}`))
+		require.NoError(t, err)
+
+		decoded, err := d.Deserialize(ctx, serialized)
+		require.NoError(t, err)
+		require.JSONEq(t, `{"uid":1}`, string(decoded))
+	})
+
+	t.Run("not registry encoded", func(t *testing.T) {
+		_, err := d.Deserialize(ctx, []byte(`{"uid":1}`))
+		require.ErrorIs(t, err, ErrInvalidMessage)
+	})
+
+	t.Run("unknown schema", func(t *testing.T) {
+		_, err := d.Deserialize(ctx, []byte{0, 0, 0, 1, 0, 1})
+		require.ErrorIs(t, err, ErrInvalidMessage)
+	})
+}
@@ -0,0 +1,132 @@
+// Package registrytest provides an in-memory stand-in of the Confluent Schema Registry for tests
+package registrytest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type schema struct {
+	ID         int    `json:"id"`
+	Subject    string `json:"subject,omitempty"`
+	Version    int    `json:"version,omitempty"`
+	SchemaType string `json:"schemaType,omitempty"`
+	Schema     string `json:"schema"`
+}
+
+// Registry is an in-memory schema registry supporting the endpoints needed to look up and register schemas:
+//
+//	GET  /subjects/{subject}/versions/latest
+//	POST /subjects/{subject}/versions
+//	GET  /schemas/ids/{id}
+type Registry struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	subjects map[string][]*schema
+	ids      map[int]*schema
+	requests int
+}
+
+// New starts a new registry. The registry must be closed once done.
+func New() *Registry {
+	r := &Registry{
+		subjects: make(map[string][]*schema),
+		ids:      make(map[int]*schema),
+	}
+	r.Server = httptest.NewServer(http.HandlerFunc(r.handle))
+	return r
+}
+
+// Register registers a schema under the subject returning its id.
+// Use an empty schema type for Avro.
+func (r *Registry) Register(subject, schemaType, s string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.register(subject, schemaType, s).ID
+}
+
+// Requests returns the number of requests served so far
+func (r *Registry) Requests() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.requests
+}
+
+func (r *Registry) register(subject, schemaType, s string) *schema {
+	if schemaType == "AVRO" {
+		schemaType = ""
+	}
+	for _, existing := range r.subjects[subject] {
+		if existing.Schema == s && existing.SchemaType == schemaType {
+			return existing
+		}
+	}
+	registered := &schema{
+		ID:         len(r.ids) + 1,
+		Subject:    subject,
+		Version:    len(r.subjects[subject]) + 1,
+		SchemaType: schemaType,
+		Schema:     s,
+	}
+	r.subjects[subject] = append(r.subjects[subject], registered)
+	r.ids[registered.ID] = registered
+	return registered
+}
+
+func (r *Registry) handle(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests++
+
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	switch {
+	case req.Method == http.MethodGet && len(parts) == 4 && parts[0] == "subjects" && parts[2] == "versions" && parts[3] == "latest":
+		versions := r.subjects[parts[1]]
+		if len(versions) == 0 {
+			writeError(w, http.StatusNotFound, 40401, "Subject '"+parts[1]+"' not found.")
+			return
+		}
+		writeJSON(w, versions[len(versions)-1])
+	case req.Method == http.MethodPost && len(parts) == 3 && parts[0] == "subjects" && parts[2] == "versions":
+		var body schema
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil || body.Schema == "" {
+			writeError(w, http.StatusUnprocessableEntity, 42201, "Invalid schema")
+			return
+		}
+		writeJSON(w, struct {
+			ID int `json:"id"`
+		}{ID: r.register(parts[1], body.SchemaType, body.Schema).ID})
+	case req.Method == http.MethodGet && len(parts) == 3 && parts[0] == "schemas" && parts[1] == "ids":
+		id, _ := strconv.Atoi(parts[2])
+		s, ok := r.ids[id]
+		if !ok {
+			writeError(w, http.StatusNotFound, 40403, "Schema "+parts[2]+" not found")
+			return
+		}
+		writeJSON(w, struct {
+			SchemaType string `json:"schemaType,omitempty"`
+			Schema     string `json:"schema"`
+		}{SchemaType: s.SchemaType, Schema: s.Schema})
+	default:
+		writeError(w, http.StatusNotFound, 404, "HTTP 404 Not Found")
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, statusCode, errorCode int, message string) {
+	w.Header().Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(struct {
+		ErrorCode int    `json:"error_code"`
+		Message   string `json:"message"`
+	}{ErrorCode: errorCode, Message: message})
+}
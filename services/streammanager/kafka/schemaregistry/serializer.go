@@ -0,0 +1,276 @@
+package schemaregistry
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/protoparse"
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/linkedin/goavro"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// magicByte is the first byte of every message in the registry wire format
+const magicByte byte = 0
+
+const defaultRefreshInterval = 5 * time.Minute
+
+// ErrInvalidMessage is returned when a message cannot be encoded or decoded with its schema.
+// Retrying such messages is pointless, contrary to errors occurring while talking to the registry.
+var ErrInvalidMessage = errors.New("message does not match the schema")
+
+type SerializerConfig struct {
+	Type SchemaType
+	// Subject is the subject of the schema, it defaults to "<topic>-value" (i.e. the TopicNameStrategy)
+	Subject string
+	// Schema is registered under the subject if AutoRegister is true, otherwise the latest schema of the
+	// subject is used
+	Schema       string
+	AutoRegister bool
+	// MessageName is the name of the Protobuf message to serialize, it defaults to the first message of the schema
+	MessageName string
+	// RefreshInterval is how long the schema of a subject is used before looking it up again, so that new versions
+	// get picked up. It defaults to 5 minutes.
+	RefreshInterval time.Duration
+}
+
+// Serializer serializes JSON values in the registry wire format
+type Serializer struct {
+	client *Client
+	config SerializerConfig
+
+	mu       sync.Mutex
+	encoders map[string]*encoder // keyed by subject
+}
+
+// encoder encodes values with a specific schema
+type encoder struct {
+	schemaID  int
+	header    []byte // magic byte, schema id and, for Protobuf, the message indexes
+	encode    func(value []byte) ([]byte, error)
+	fetchedAt time.Time
+}
+
+// NewSerializer returns a new serializer using the provided client
+func NewSerializer(client *Client, conf SerializerConfig) (*Serializer, error) {
+	if client == nil {
+		return nil, fmt.Errorf("schema registry client cannot be nil")
+	}
+	if conf.Type == "" {
+		conf.Type = Avro
+	}
+	if conf.AutoRegister && conf.Schema == "" {
+		return nil, fmt.Errorf("schema cannot be empty when auto registering schemas")
+	}
+	if conf.RefreshInterval < 1 {
+		conf.RefreshInterval = defaultRefreshInterval
+	}
+	return &Serializer{
+		client:   client,
+		config:   conf,
+		encoders: make(map[string]*encoder),
+	}, nil
+}
+
+// Subject returns the subject of the schema used for messages of the topic
+func (s *Serializer) Subject(topic string) string {
+	if s.config.Subject != "" {
+		return s.config.Subject
+	}
+	return topic + "-value"
+}
+
+// Serialize encodes the JSON value with the schema of the topic's subject and prefixes it with the magic byte and
+// the schema id. Values that cannot be encoded with the schema produce an error wrapping ErrInvalidMessage.
+func (s *Serializer) Serialize(ctx context.Context, topic string, value []byte) ([]byte, error) {
+	enc, err := s.encoder(ctx, s.Subject(topic))
+	if err != nil {
+		return nil, err
+	}
+	payload, err := enc.encode(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidMessage, err)
+	}
+	serialized := make([]byte, 0, len(enc.header)+len(payload))
+	serialized = append(serialized, enc.header...)
+	return append(serialized, payload...), nil
+}
+
+// encoder returns the encoder of the subject, looking up its schema again once the refresh interval elapsed.
+// If the registry cannot be reached the encoder of the previous schema keeps being used until the next refresh.
+func (s *Serializer) encoder(ctx context.Context, subject string) (*encoder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cached, ok := s.encoders[subject]
+	if ok && time.Since(cached.fetchedAt) < s.config.RefreshInterval {
+		return cached, nil
+	}
+
+	var (
+		schema *Schema
+		err    error
+	)
+	if s.config.AutoRegister {
+		schema, err = s.client.Register(ctx, subject, s.config.Type, s.config.Schema)
+	} else {
+		schema, err = s.client.GetLatestSchema(ctx, subject)
+	}
+	if err != nil {
+		if ok && IsTemporary(err) {
+			cached.fetchedAt = time.Now()
+			return cached, nil
+		}
+		return nil, err
+	}
+	if ok && cached.schemaID == schema.ID {
+		cached.fetchedAt = time.Now()
+		return cached, nil
+	}
+	if schema.Type != s.config.Type {
+		return nil, fmt.Errorf("schema %d of subject %q is of type %s instead of %s", schema.ID, subject, schema.Type, s.config.Type)
+	}
+
+	header := make([]byte, 5, 6)
+	header[0] = magicByte
+	binary.BigEndian.PutUint32(header[1:], uint32(schema.ID))
+
+	enc := &encoder{schemaID: schema.ID, header: header, fetchedAt: time.Now()}
+	switch schema.Type {
+	case Avro:
+		enc.encode, err = newAvroEncoder(schema.Schema)
+	case JSONSchema:
+		enc.encode, err = newJSONSchemaEncoder(schema.Schema)
+	case Protobuf:
+		var indexes []byte
+		enc.encode, indexes, err = newProtobufEncoder(schema.Schema, s.config.MessageName)
+		enc.header = append(enc.header, indexes...)
+	default:
+		err = fmt.Errorf("unsupported schema type: %s", schema.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema %d of subject %q: %w", schema.ID, subject, err)
+	}
+	s.encoders[subject] = enc
+	return enc, nil
+}
+
+func newAvroEncoder(schema string) (func([]byte) ([]byte, error), error) {
+	codec, err := goavro.NewCodec(schema)
+	if err != nil {
+		return nil, err
+	}
+	return func(value []byte) ([]byte, error) {
+		native, _, err := codec.NativeFromTextual(value)
+		if err != nil {
+			return nil, fmt.Errorf("unable to convert the event to native from textual: %w", err)
+		}
+		return codec.BinaryFromNative(nil, native)
+	}, nil
+}
+
+func newJSONSchemaEncoder(schema string) (func([]byte) ([]byte, error), error) {
+	compiled, err := gojsonschema.NewSchema(gojsonschema.NewStringLoader(schema))
+	if err != nil {
+		return nil, err
+	}
+	return func(value []byte) ([]byte, error) {
+		result, err := compiled.Validate(gojsonschema.NewBytesLoader(value))
+		if err != nil {
+			return nil, err
+		}
+		if !result.Valid() {
+			errs := make([]string, len(result.Errors()))
+			for i, resultErr := range result.Errors() {
+				errs[i] = resultErr.String()
+			}
+			return nil, fmt.Errorf("%s", strings.Join(errs, "; "))
+		}
+		return value, nil
+	}, nil
+}
+
+// newProtobufEncoder returns an encoder for the message of the schema along with the message indexes identifying the
+// message within the schema, as required by the wire format
+func newProtobufEncoder(schema, messageName string) (func([]byte) ([]byte, error), []byte, error) {
+	const fileName = "schema.proto"
+	parser := protoparse.Parser{
+		Accessor: protoparse.FileContentsFromMap(map[string]string{fileName: schema}),
+	}
+	files, err := parser.ParseFiles(fileName)
+	if err != nil {
+		return nil, nil, err
+	}
+	fd := files[0]
+
+	var md *desc.MessageDescriptor
+	if messageName == "" {
+		if len(fd.GetMessageTypes()) == 0 {
+			return nil, nil, fmt.Errorf("no message defined")
+		}
+		md = fd.GetMessageTypes()[0]
+	} else {
+		md = fd.FindMessage(messageName)
+		if md == nil && fd.GetPackage() != "" {
+			md = fd.FindMessage(fd.GetPackage() + "." + messageName)
+		}
+		if md == nil {
+			return nil, nil, fmt.Errorf("message %q not found", messageName)
+		}
+	}
+
+	unmarshaler := &jsonpb.Unmarshaler{AllowUnknownFields: true}
+	encode := func(value []byte) ([]byte, error) {
+		msg := dynamic.NewMessage(md)
+		if err := msg.UnmarshalJSONPB(unmarshaler, value); err != nil {
+			return nil, err
+		}
+		return msg.Marshal()
+	}
+	return encode, encodeMessageIndexes(messageIndexes(md)), nil
+}
+
+// messageIndexes returns the path of the message within its file, e.g. [1, 0] is the first nested message of the
+// second top-level message
+func messageIndexes(md *desc.MessageDescriptor) []int {
+	var indexes []int
+	for {
+		var siblings []*desc.MessageDescriptor
+		parent, isNested := md.GetParent().(*desc.MessageDescriptor)
+		if isNested {
+			siblings = parent.GetNestedMessageTypes()
+		} else {
+			siblings = md.GetFile().GetMessageTypes()
+		}
+		for i, sibling := range siblings {
+			if sibling == md {
+				indexes = append([]int{i}, indexes...)
+				break
+			}
+		}
+		if !isNested {
+			return indexes
+		}
+		md = parent
+	}
+}
+
+// encodeMessageIndexes encodes the message indexes as zig-zag varints prefixed by their count.
+// The common case of the first top-level message is encoded as a single 0 byte.
+func encodeMessageIndexes(indexes []int) []byte {
+	if len(indexes) == 1 && indexes[0] == 0 {
+		return []byte{0}
+	}
+	buf := make([]byte, binary.MaxVarintLen64*(len(indexes)+1))
+	n := binary.PutVarint(buf, int64(len(indexes)))
+	for _, index := range indexes {
+		n += binary.PutVarint(buf[n:], int64(index))
+	}
+	return buf[:n]
+}
@@ -0,0 +1,183 @@
+package schemaregistry
+
+import (
+	"context"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/jhump/protoreflect/desc/protoparse"
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/linkedin/goavro"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rudderlabs/rudder-server/services/streammanager/kafka/schemaregistry/registrytest"
+)
+
+const (
+	avroSchema = `{
+		"type": "record",
+		"name": "myrecord",
+		"fields": [{"name": "uid", "type": "int"}, {"name": "somefield", "type": "string"}]
+	}`
+	protobufSchema = `syntax = "proto3";
+package test;
+message First { string a = 1; }
+message Second {
+	message Nested { string b = 1; int32 c = 2; }
+	string d = 1;
+}`
+	jsonSchema = `{"type":"object","properties":{"uid":{"type":"integer"}},"required":["uid"]}`
+)
+
+func TestSerializer(t *testing.T) {
+	registry := registrytest.New()
+	t.Cleanup(registry.Close)
+	ctx := context.Background()
+
+	c, err := NewClient(Config{URL: registry.URL})
+	require.NoError(t, err)
+
+	requireHeader := func(t *testing.T, serialized []byte, id int) []byte {
+		t.Helper()
+		require.GreaterOrEqual(t, len(serialized), 5)
+		require.Equal(t, magicByte, serialized[0])
+		require.EqualValues(t, id, binary.BigEndian.Uint32(serialized[1:5]))
+		return serialized[5:]
+	}
+
+	t.Run("avro", func(t *testing.T) {
+		id := registry.Register("avro-topic-value", "", avroSchema)
+		s, err := NewSerializer(c, SerializerConfig{Type: Avro})
+		require.NoError(t, err)
+
+		serialized, err := s.Serialize(ctx, "avro-topic", []byte(`{"uid":1,"somefield":"hello"}`))
+		require.NoError(t, err)
+		payload := requireHeader(t, serialized, id)
+
+		codec, err := goavro.NewCodec(avroSchema)
+		require.NoError(t, err)
+		native, _, err := codec.NativeFromBinary(payload)
+		require.NoError(t, err)
+		require.Equal(t, map[string]interface{}{"uid": int32(1), "somefield": "hello"}, native)
+
+		_, err = s.Serialize(ctx, "avro-topic", []byte(`{"uid":"not a number"}`))
+		require.ErrorIs(t, err, ErrInvalidMessage)
+	})
+
+	t.Run("protobuf", func(t *testing.T) {
+		id := registry.Register("proto-subject", "PROTOBUF", protobufSchema)
+		parser := protoparse.Parser{
+			Accessor: protoparse.FileContentsFromMap(map[string]string{"schema.proto": protobufSchema}),
+		}
+		files, err := parser.ParseFiles("schema.proto")
+		require.NoError(t, err)
+
+		t.Run("first message", func(t *testing.T) {
+			s, err := NewSerializer(c, SerializerConfig{Type: Protobuf, Subject: "proto-subject"})
+			require.NoError(t, err)
+			serialized, err := s.Serialize(ctx, "any-topic", []byte(`{"a":"hello"}`))
+			require.NoError(t, err)
+			payload := requireHeader(t, serialized, id)
+			require.Equal(t, byte(0), payload[0], "first message should be encoded as a single 0 byte")
+
+			msg := dynamic.NewMessage(files[0].FindMessage("test.First"))
+			require.NoError(t, msg.Unmarshal(payload[1:]))
+			require.Equal(t, "hello", msg.GetFieldByName("a"))
+		})
+
+		t.Run("nested message", func(t *testing.T) {
+			s, err := NewSerializer(c, SerializerConfig{Type: Protobuf, Subject: "proto-subject", MessageName: "Second.Nested"})
+			require.NoError(t, err)
+			serialized, err := s.Serialize(ctx, "any-topic", []byte(`{"b":"hello","c":2}`))
+			require.NoError(t, err)
+			payload := requireHeader(t, serialized, id)
+			require.Equal(t, []byte{4, 2, 0}, payload[:3], "message indexes [1, 0] as zig-zag varints")
+
+			msg := dynamic.NewMessage(files[0].FindMessage("test.Second.Nested"))
+			require.NoError(t, msg.Unmarshal(payload[3:]))
+			require.Equal(t, "hello", msg.GetFieldByName("b"))
+			require.Equal(t, int32(2), msg.GetFieldByName("c"))
+
+			_, err = s.Serialize(ctx, "any-topic", []byte(`{"c":"not a number"}`))
+			require.ErrorIs(t, err, ErrInvalidMessage)
+		})
+
+		t.Run("unknown message", func(t *testing.T) {
+			s, err := NewSerializer(c, SerializerConfig{Type: Protobuf, Subject: "proto-subject", MessageName: "Unknown"})
+			require.NoError(t, err)
+			_, err = s.Serialize(ctx, "any-topic", []byte(`{}`))
+			require.ErrorContains(t, err, `message "Unknown" not found`)
+		})
+	})
+
+	t.Run("json schema with auto registration", func(t *testing.T) {
+		s, err := NewSerializer(c, SerializerConfig{Type: JSONSchema, Schema: jsonSchema, AutoRegister: true})
+		require.NoError(t, err)
+
+		serialized, err := s.Serialize(ctx, "json-topic", []byte(`{"uid":1}`))
+		require.NoError(t, err)
+		schema, err := c.GetLatestSchema(ctx, "json-topic-value")
+		require.NoError(t, err)
+		require.Equal(t, []byte(`{"uid":1}`), requireHeader(t, serialized, schema.ID))
+
+		_, err = s.Serialize(ctx, "json-topic", []byte(`{"uid":"not a number"}`))
+		require.ErrorIs(t, err, ErrInvalidMessage)
+	})
+
+	t.Run("schema type mismatch", func(t *testing.T) {
+		registry.Register("mismatch-value", "", avroSchema)
+		s, err := NewSerializer(c, SerializerConfig{Type: JSONSchema})
+		require.NoError(t, err)
+		_, err = s.Serialize(ctx, "mismatch", []byte(`{}`))
+		require.ErrorContains(t, err, "is of type AVRO instead of JSON")
+		require.NotErrorIs(t, err, ErrInvalidMessage)
+	})
+
+	t.Run("missing subject", func(t *testing.T) {
+		s, err := NewSerializer(c, SerializerConfig{Type: Avro})
+		require.NoError(t, err)
+		_, err = s.Serialize(ctx, "missing", []byte(`{}`))
+		require.True(t, IsNotFound(err))
+	})
+
+	t.Run("new schema versions are picked up once the refresh interval elapsed", func(t *testing.T) {
+		id := registry.Register("refreshed-value", "", avroSchema)
+		s, err := NewSerializer(c, SerializerConfig{Type: Avro, RefreshInterval: time.Hour})
+		require.NoError(t, err)
+		serialized, err := s.Serialize(ctx, "refreshed", []byte(`{"uid":1,"somefield":"hello"}`))
+		require.NoError(t, err)
+		requireHeader(t, serialized, id)
+
+		newID := registry.Register("refreshed-value", "", `"string"`)
+		serialized, err = s.Serialize(ctx, "refreshed", []byte(`{"uid":1,"somefield":"hello"}`))
+		require.NoError(t, err)
+		requireHeader(t, serialized, id)
+
+		s.config.RefreshInterval = time.Nanosecond
+		serialized, err = s.Serialize(ctx, "refreshed", []byte(`"hello"`))
+		require.NoError(t, err)
+		requireHeader(t, serialized, newID)
+	})
+
+	t.Run("previous schema is used while the registry is unavailable", func(t *testing.T) {
+		unavailable := registrytest.New()
+		id := unavailable.Register("unavailable-value", "", avroSchema)
+		uc, err := NewClient(Config{URL: unavailable.URL})
+		require.NoError(t, err)
+		s, err := NewSerializer(uc, SerializerConfig{Type: Avro, RefreshInterval: time.Nanosecond})
+		require.NoError(t, err)
+		_, err = s.Serialize(ctx, "unavailable", []byte(`{"uid":1,"somefield":"hello"}`))
+		require.NoError(t, err)
+
+		unavailable.Close()
+		serialized, err := s.Serialize(ctx, "unavailable", []byte(`{"uid":1,"somefield":"hello"}`))
+		require.NoError(t, err)
+		requireHeader(t, serialized, id)
+	})
+
+	t.Run("auto registration without schema", func(t *testing.T) {
+		_, err := NewSerializer(c, SerializerConfig{Type: Avro, AutoRegister: true})
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,463 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/linkedin/goavro"
+
+	"github.com/rudderlabs/rudder-server/services/streammanager/kafka/client"
+	"github.com/rudderlabs/rudder-server/utils/misc"
+)
+
+// committedJobsVersion prefixes the encoded committed jobs so that the format can evolve
+const committedJobsVersion = "v1:"
+
+type transactionalProducer interface {
+	publisher
+	BeginTransaction() error
+	CommitTransaction(ctx context.Context, metadata string) error
+	AbortTransaction(ctx context.Context) error
+	LastCommittedMetadata(ctx context.Context) (string, error)
+	Close(context.Context) error
+}
+
+// jobRange is an inclusive range of job ids
+type jobRange struct {
+	first, last int64
+}
+
+// committedJobs keeps the ranges of the job ids that have been delivered by the committed transactions of a partition.
+// The ranges are committed along with every transaction, so that they survive restarts.
+type committedJobs struct {
+	ranges []jobRange // sorted and non-overlapping
+}
+
+func (c *committedJobs) contains(jobID int64) bool {
+	i := sort.Search(len(c.ranges), func(i int) bool { return c.ranges[i].last >= jobID })
+	return i < len(c.ranges) && c.ranges[i].first <= jobID
+}
+
+// containsAll returns true if all the job ids have already been committed
+func (c *committedJobs) containsAll(jobIDs []int64) bool {
+	if len(jobIDs) == 0 {
+		return false
+	}
+	for _, jobID := range jobIDs {
+		if !c.contains(jobID) {
+			return false
+		}
+	}
+	return true
+}
+
+// with returns a copy of the committed jobs including the provided job ids
+func (c *committedJobs) with(jobIDs []int64) *committedJobs {
+	ranges := make([]jobRange, 0, len(c.ranges)+len(jobIDs))
+	ranges = append(ranges, c.ranges...)
+	for _, jobID := range jobIDs {
+		ranges = append(ranges, jobRange{first: jobID, last: jobID})
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].first < ranges[j].first })
+
+	merged := make([]jobRange, 0, len(ranges))
+	for _, r := range ranges {
+		if l := len(merged); l > 0 && r.first <= merged[l-1].last+1 {
+			if r.last > merged[l-1].last {
+				merged[l-1].last = r.last
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return &committedJobs{ranges: merged}
+}
+
+// encode encodes the ranges dropping the oldest ones (i.e. the ones with the lowest job ids) if the encoded
+// value would exceed maxSize bytes. The ranges including job ids greater than or equal to keepFrom are never
+// dropped: an error is returned if they do not fit.
+func (c *committedJobs) encode(maxSize int, keepFrom int64) (string, error) {
+	size := len(committedJobsVersion)
+	encoded := make([]string, 0, len(c.ranges))
+	for i := len(c.ranges) - 1; i >= 0; i-- {
+		r := c.ranges[i]
+		s := strconv.FormatInt(r.first, 10)
+		if r.last != r.first {
+			s += "-" + strconv.FormatInt(r.last, 10)
+		}
+		if len(encoded) > 0 {
+			size++ // separator
+		}
+		if size+len(s) > maxSize {
+			if r.last >= keepFrom {
+				return "", fmt.Errorf("committed jobs exceed the maximum metadata size of %d bytes", maxSize)
+			}
+			break
+		}
+		size += len(s)
+		encoded = append(encoded, s)
+	}
+	for i, j := 0, len(encoded)-1; i < j; i, j = i+1, j-1 {
+		encoded[i], encoded[j] = encoded[j], encoded[i]
+	}
+	return committedJobsVersion + strings.Join(encoded, ","), nil
+}
+
+// minJobID returns the lowest of the job ids, or math.MaxInt64 if there are none
+func minJobID(jobIDs []int64) int64 {
+	minID := int64(math.MaxInt64)
+	for _, jobID := range jobIDs {
+		if jobID < minID {
+			minID = jobID
+		}
+	}
+	return minID
+}
+
+func decodeCommittedJobs(metadata string) (*committedJobs, error) {
+	if metadata == "" {
+		return &committedJobs{}, nil
+	}
+	if !strings.HasPrefix(metadata, committedJobsVersion) {
+		return nil, fmt.Errorf("unknown committed jobs format: %q", metadata)
+	}
+	metadata = strings.TrimPrefix(metadata, committedJobsVersion)
+	if metadata == "" {
+		return &committedJobs{}, nil
+	}
+
+	parts := strings.Split(metadata, ",")
+	ranges := make([]jobRange, len(parts))
+	for i, part := range parts {
+		first, last, isRange := strings.Cut(part, "-")
+		var err error
+		if ranges[i].first, err = strconv.ParseInt(first, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid committed job range %q: %w", part, err)
+		}
+		ranges[i].last = ranges[i].first
+		if isRange {
+			if ranges[i].last, err = strconv.ParseInt(last, 10, 64); err != nil {
+				return nil, fmt.Errorf("invalid committed job range %q: %w", part, err)
+			}
+		}
+		if ranges[i].last < ranges[i].first || (i > 0 && ranges[i].first <= ranges[i-1].last) {
+			return nil, fmt.Errorf("invalid committed job range %q", part)
+		}
+	}
+	return &committedJobs{ranges: ranges}, nil
+}
+
+// partitionTransaction is the transactional state of a single partition
+type partitionTransaction struct {
+	producer  transactionalProducer
+	committed *committedJobs
+	pending   []int64 // job ids produced within the open transaction
+	open      bool
+	failed    bool // true if producing failed within the open transaction
+	truncated bool // true once the oldest committed jobs got dropped from the metadata
+}
+
+// transactions manages one transactional producer per partition. The events of a user always belong to the same
+// partition, regardless of the router worker delivering them, and each partition uses a stable transactional id
+// derived from the destination and the partition, so that after a restart the new producer fences the previous one,
+// any transaction left open by it gets aborted and the job ids it committed can be read back.
+// A partition can have only one open transaction at a time, thus a transaction holds its partition until it gets
+// committed or aborted. Callers should not hold more than one partition at a time, or they could wait for each other.
+//
+// The job ids committed by a partition are kept in the offset metadata of its transactions, which is limited to
+// Router.KAFKA.exactlyOnce.maxMetadataSize bytes. Once they do not fit anymore, the ranges with the lowest job ids
+// are dropped: jobs are only deduplicated as long as their ids are not lower than the ones of the retained ranges,
+// which is the case for the jobs retried soon after a failure. Transactions never drop the jobs they deliver, the
+// jobs which would not fit are left to the next transaction instead.
+type transactions struct {
+	transactionalIDPrefix string
+	newProducer           func(transactionalID string) (transactionalProducer, error)
+	numPartitions         int
+	transactionTimeout    time.Duration
+	lockTimeout           time.Duration
+
+	mu         sync.Mutex
+	locks      map[int]chan struct{}
+	partitions map[int]*partitionTransaction
+}
+
+func newTransactions(
+	transactionalIDPrefix string, newProducer func(transactionalID string) (transactionalProducer, error),
+) *transactions {
+	return &transactions{
+		transactionalIDPrefix: transactionalIDPrefix,
+		newProducer:           newProducer,
+		numPartitions:         exactlyOnceTransactionPartitions,
+		transactionTimeout:    exactlyOnceTransactionTimeout,
+		lockTimeout:           exactlyOnceTransactionLockTimeout,
+		locks:                 make(map[int]chan struct{}),
+		partitions:            make(map[int]*partitionTransaction),
+	}
+}
+
+// partition returns the partition of the transactions delivering the events of the user
+func (t *transactions) partition(userID string) int {
+	if t.numPartitions < 1 {
+		return 0
+	}
+	return misc.GetHash(userID) % t.numPartitions
+}
+
+func (t *transactions) transactionalID(partition int) string {
+	return t.transactionalIDPrefix + "-" + strconv.Itoa(partition)
+}
+
+func (t *transactions) get(partition int) (*partitionTransaction, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	w, ok := t.partitions[partition]
+	return w, ok
+}
+
+// lock waits for the open transaction of the partition, if any, to be committed or aborted
+func (t *transactions) lock(partition int) error {
+	t.mu.Lock()
+	lock, ok := t.locks[partition]
+	if !ok {
+		lock = make(chan struct{}, 1)
+		t.locks[partition] = lock
+	}
+	t.mu.Unlock()
+
+	select {
+	case lock <- struct{}{}:
+		return nil
+	case <-time.After(t.lockTimeout):
+		return fmt.Errorf("timed out waiting for the open transaction of partition %d", partition)
+	}
+}
+
+func (t *transactions) unlock(partition int) {
+	t.mu.Lock()
+	lock := t.locks[partition]
+	t.mu.Unlock()
+	select {
+	case <-lock:
+	default:
+	}
+}
+
+// discard closes the producer of the partition, so that the committed jobs get reloaded on the next transaction
+func (t *transactions) discard(partition int) {
+	t.mu.Lock()
+	w, ok := t.partitions[partition]
+	delete(t.partitions, partition)
+	t.mu.Unlock()
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Second)
+	defer cancel()
+	if err := w.producer.Close(ctx); err != nil {
+		pkgLogger.Errorf("could not close transactional producer %q: %v", t.transactionalID(partition), err)
+	}
+}
+
+// begin opens a new transaction for the partition, holding the partition until the transaction gets committed or
+// aborted
+func (t *transactions) begin(partition int) error {
+	if err := t.lock(partition); err != nil {
+		return err
+	}
+	if err := t.beginLocked(partition); err != nil {
+		t.unlock(partition)
+		return err
+	}
+	return nil
+}
+
+func (t *transactions) beginLocked(partition int) error {
+	w, ok := t.get(partition)
+	if !ok {
+		transactionalID := t.transactionalID(partition)
+		producer, err := t.newProducer(transactionalID)
+		if err != nil {
+			return fmt.Errorf("could not create transactional producer %q: %w", transactionalID, err)
+		}
+		ctx, cancel := context.WithTimeout(context.TODO(), t.transactionTimeout)
+		defer cancel()
+		metadata, err := producer.LastCommittedMetadata(ctx)
+		if err == nil {
+			w = &partitionTransaction{producer: producer}
+			w.committed, err = decodeCommittedJobs(metadata)
+		}
+		if err != nil {
+			_ = producer.Close(ctx)
+			return fmt.Errorf("could not load committed jobs of %q: %w", transactionalID, err)
+		}
+		t.mu.Lock()
+		t.partitions[partition] = w
+		t.mu.Unlock()
+	}
+	if w.open {
+		if err := t.abortLocked(partition); err != nil {
+			return err
+		}
+		return t.beginLocked(partition)
+	}
+	if err := w.producer.BeginTransaction(); err != nil {
+		t.discard(partition)
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+	w.open, w.failed, w.pending = true, false, nil
+	return nil
+}
+
+func (t *transactions) produce(
+	partition int, jobIDs []int64, jsonData json.RawMessage, topic string, timeout time.Duration,
+	codecs map[string]*goavro.Codec, serializer valueSerializer,
+) (int, string, string) {
+	w, ok := t.get(partition)
+	if !ok || !w.open {
+		return 500, "Failure", "No open transaction for partition " + strconv.Itoa(partition)
+	}
+	if w.committed.containsAll(jobIDs) {
+		returnMessage := "Kafka: Message already delivered in a committed transaction"
+		return 200, returnMessage, returnMessage
+	}
+
+	// the jobs are left to the next transaction if their ids could not be committed along with the open one
+	pending := make([]int64, 0, len(w.pending)+len(jobIDs))
+	pending = append(append(pending, w.pending...), jobIDs...)
+	if _, err := w.committed.with(pending).encode(exactlyOnceMaxMetadataSize, minJobID(pending)); err != nil {
+		return 500, "Failure", "Kafka: Jobs do not fit in the open transaction: " + err.Error()
+	}
+
+	p := &transactionPublisher{producer: w.producer, timeout: timeout, codecs: codecs, serializer: serializer}
+	ctx, cancel := context.WithTimeout(context.TODO(), p.getTimeout())
+	defer cancel()
+	var (
+		statusCode          int
+		respStatus, respMsg string
+	)
+	if kafkaBatchingEnabled {
+		statusCode, respStatus, respMsg = sendBatchedMessage(ctx, jsonData, p, topic)
+	} else {
+		statusCode, respStatus, respMsg = sendMessage(ctx, jsonData, p, topic)
+	}
+	if statusCode == 200 {
+		w.pending = pending
+	} else {
+		// the outcome of a failed publish is unknown, thus the whole transaction is going to be aborted
+		w.failed = true
+	}
+	return statusCode, respStatus, respMsg
+}
+
+// commit commits the open transaction of the partition and releases the partition
+func (t *transactions) commit(partition int) error {
+	w, ok := t.get(partition)
+	if !ok || !w.open {
+		return fmt.Errorf("no open transaction for partition %d", partition)
+	}
+	defer t.unlock(partition)
+	if w.failed {
+		if err := t.abortLocked(partition); err != nil {
+			return err
+		}
+		return fmt.Errorf("transaction aborted since producing failed within it")
+	}
+
+	committed := w.committed.with(w.pending)
+	metadata, err := committed.encode(exactlyOnceMaxMetadataSize, minJobID(w.pending))
+	if err != nil {
+		if abortErr := t.abortLocked(partition); abortErr != nil {
+			return abortErr
+		}
+		return fmt.Errorf("transaction aborted: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.TODO(), t.transactionTimeout)
+	defer cancel()
+	if err := w.producer.CommitTransaction(ctx, metadata); err != nil {
+		// the transaction might have been committed anyway, the committed jobs are reloaded on the next begin
+		t.discard(partition)
+		return fmt.Errorf("could not commit transaction: %w", err)
+	}
+	// the committed jobs are the ones persisted in the metadata, so that deduplication is the same after a restart
+	if retained, err := decodeCommittedJobs(metadata); err == nil && len(retained.ranges) < len(committed.ranges) {
+		if !w.truncated && len(retained.ranges) > 0 {
+			pkgLogger.Errorf(
+				"Committed jobs of %q exceed %d bytes, jobs with ids lower than %d are no longer deduplicated",
+				t.transactionalID(partition), exactlyOnceMaxMetadataSize, retained.ranges[0].first,
+			)
+		}
+		w.truncated = true
+		committed = retained
+	}
+	w.committed, w.open, w.pending = committed, false, nil
+	return nil
+}
+
+// abort aborts the open transaction of the partition, if any, and releases the partition
+func (t *transactions) abort(partition int) error {
+	w, ok := t.get(partition)
+	if !ok || !w.open {
+		return nil
+	}
+	defer t.unlock(partition)
+	return t.abortLocked(partition)
+}
+
+func (t *transactions) abortLocked(partition int) error {
+	w, ok := t.get(partition)
+	if !ok || !w.open {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.TODO(), t.transactionTimeout)
+	defer cancel()
+	if err := w.producer.AbortTransaction(ctx); err != nil {
+		t.discard(partition)
+		return fmt.Errorf("could not abort transaction: %w", err)
+	}
+	w.open, w.failed, w.pending = false, false, nil
+	return nil
+}
+
+func (t *transactions) close() {
+	t.mu.Lock()
+	partitions := make([]int, 0, len(t.partitions))
+	for partition := range t.partitions {
+		partitions = append(partitions, partition)
+	}
+	t.mu.Unlock()
+	for _, partition := range partitions {
+		t.discard(partition)
+	}
+}
+
+// transactionPublisher allows to reuse sendMessage and sendBatchedMessage with a transactional producer
+type transactionPublisher struct {
+	producer   transactionalProducer
+	timeout    time.Duration
+	codecs     map[string]*goavro.Codec
+	serializer valueSerializer
+}
+
+func (p *transactionPublisher) Publish(ctx context.Context, msgs ...client.Message) error {
+	return p.producer.Publish(ctx, msgs...)
+}
+
+func (*transactionPublisher) Close() error { return nil }
+
+func (p *transactionPublisher) getTimeout() time.Duration {
+	if p.timeout < 1 {
+		return defaultPublishTimeout
+	}
+	return p.timeout
+}
+
+func (p *transactionPublisher) getCodecs() map[string]*goavro.Codec { return p.codecs }
+
+func (p *transactionPublisher) getSerializer() valueSerializer { return p.serializer }
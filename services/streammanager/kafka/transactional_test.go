@@ -0,0 +1,267 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	mockStats "github.com/rudderlabs/rudder-server/mocks/services/stats"
+	"github.com/rudderlabs/rudder-server/services/streammanager/kafka/client"
+)
+
+func TestCommittedJobs(t *testing.T) {
+	t.Run("with and contains", func(t *testing.T) {
+		c := (&committedJobs{}).with([]int64{5, 1, 2, 3, 10})
+		require.Equal(t, []jobRange{{1, 3}, {5, 5}, {10, 10}}, c.ranges)
+		c = c.with([]int64{4, 11, 20})
+		require.Equal(t, []jobRange{{1, 5}, {10, 11}, {20, 20}}, c.ranges)
+
+		for _, jobID := range []int64{1, 3, 5, 10, 11, 20} {
+			require.True(t, c.contains(jobID), jobID)
+		}
+		for _, jobID := range []int64{0, 6, 9, 12, 19, 21} {
+			require.False(t, c.contains(jobID), jobID)
+		}
+		require.True(t, c.containsAll([]int64{1, 11}))
+		require.False(t, c.containsAll([]int64{1, 12}))
+		require.False(t, c.containsAll(nil))
+	})
+
+	t.Run("encode and decode", func(t *testing.T) {
+		c := (&committedJobs{}).with([]int64{1, 2, 3, 5, 10, 11, 20})
+		encoded, err := c.encode(100, 20)
+		require.NoError(t, err)
+		require.Equal(t, "v1:1-3,5,10-11,20", encoded)
+
+		decoded, err := decodeCommittedJobs(encoded)
+		require.NoError(t, err)
+		require.Equal(t, c, decoded)
+	})
+
+	t.Run("encode drops the oldest ranges", func(t *testing.T) {
+		c := (&committedJobs{}).with([]int64{1, 2, 3, 5, 10, 11, 20})
+		encode := func(maxSize int, keepFrom int64) string {
+			encoded, err := c.encode(maxSize, keepFrom)
+			require.NoError(t, err)
+			return encoded
+		}
+		require.Equal(t, "v1:10-11,20", encode(len("v1:10-11,20"), 11))
+		require.Equal(t, "v1:20", encode(len("v1:10-11,20")-1, 20))
+		require.Equal(t, "v1:", encode(0, math.MaxInt64))
+	})
+
+	t.Run("encode never drops the ranges to keep", func(t *testing.T) {
+		c := (&committedJobs{}).with([]int64{1, 2, 3, 5, 10, 11, 20})
+		_, err := c.encode(len("v1:10-11,20")-1, 11)
+		require.Error(t, err)
+		_, err = c.encode(0, 20)
+		require.Error(t, err)
+	})
+
+	t.Run("decode", func(t *testing.T) {
+		c, err := decodeCommittedJobs("")
+		require.NoError(t, err)
+		require.Empty(t, c.ranges)
+
+		c, err = decodeCommittedJobs("v1:")
+		require.NoError(t, err)
+		require.Empty(t, c.ranges)
+
+		for _, invalid := range []string{"1-3", "v2:1-3", "v1:a", "v1:1-b", "v1:3-1", "v1:1-3,2"} {
+			_, err = decodeCommittedJobs(invalid)
+			require.Error(t, err, invalid)
+		}
+	})
+}
+
+func TestTransactions(t *testing.T) {
+	newTestTransactions := func(t *testing.T, p *txProducerMock) *transactions {
+		publishTime := mockStats.NewMockMeasurement(gomock.NewController(t))
+		publishTime.EXPECT().SendTiming(sinceDuration).AnyTimes()
+		kafkaStats.publishTime = publishTime
+		return newTransactions("rudder-dest", func(transactionalID string) (transactionalProducer, error) {
+			require.Equal(t, "rudder-dest-1", transactionalID)
+			p.created++
+			return p, nil
+		})
+	}
+	defer func(maxSize int) { exactlyOnceMaxMetadataSize = maxSize }(exactlyOnceMaxMetadataSize)
+	message := json.RawMessage(`{"message":"ciao","userId":"123"}`)
+
+	t.Run("commit", func(t *testing.T) {
+		p := &txProducerMock{metadata: "v1:1-2"}
+		tx := newTestTransactions(t, p)
+
+		require.NoError(t, tx.begin(1))
+		sc, _, _ := tx.produce(1, []int64{2}, message, "some-topic", 0, nil, nil)
+		require.Equal(t, 200, sc)
+		require.Empty(t, p.published, "already committed jobs should not be produced again")
+
+		sc, _, _ = tx.produce(1, []int64{3}, message, "some-topic", 0, nil, nil)
+		require.Equal(t, 200, sc)
+		require.Len(t, p.published, 1)
+
+		require.NoError(t, tx.commit(1))
+		require.Equal(t, []string{"v1:1-3"}, p.commits)
+
+		require.NoError(t, tx.begin(1))
+		require.NoError(t, tx.commit(1))
+		require.Equal(t, []string{"v1:1-3", "v1:1-3"}, p.commits)
+		require.Equal(t, 1, p.created)
+	})
+
+	t.Run("produce without transaction", func(t *testing.T) {
+		tx := newTestTransactions(t, &txProducerMock{})
+		sc, _, _ := tx.produce(1, []int64{1}, message, "some-topic", 0, nil, nil)
+		require.Equal(t, 500, sc)
+		require.Error(t, tx.commit(1))
+	})
+
+	t.Run("failed publish aborts the transaction", func(t *testing.T) {
+		p := &txProducerMock{publishErr: fmt.Errorf("something bad")}
+		tx := newTestTransactions(t, p)
+
+		require.NoError(t, tx.begin(1))
+		sc, _, _ := tx.produce(1, []int64{1}, message, "some-topic", 0, nil, nil)
+		require.NotEqual(t, 200, sc)
+		require.Error(t, tx.commit(1))
+		require.Empty(t, p.commits)
+		require.Equal(t, 1, p.aborts)
+	})
+
+	t.Run("failed commit reloads the committed jobs", func(t *testing.T) {
+		p := &txProducerMock{commitErr: fmt.Errorf("something bad")}
+		tx := newTestTransactions(t, p)
+
+		require.NoError(t, tx.begin(1))
+		sc, _, _ := tx.produce(1, []int64{1}, message, "some-topic", 0, nil, nil)
+		require.Equal(t, 200, sc)
+		require.Error(t, tx.commit(1))
+		require.True(t, p.closed)
+
+		p.commitErr, p.metadata = nil, "v1:1"
+		require.NoError(t, tx.begin(1))
+		require.Equal(t, 2, p.created)
+		sc, _, _ = tx.produce(1, []int64{1}, message, "some-topic", 0, nil, nil)
+		require.Equal(t, 200, sc)
+		require.Len(t, p.published, 1, "job committed before the failure should not be produced again")
+	})
+
+	t.Run("jobs exceeding the metadata size are left to the next transaction", func(t *testing.T) {
+		exactlyOnceMaxMetadataSize = len("v1:1,3")
+		p := &txProducerMock{}
+		tx := newTestTransactions(t, p)
+
+		require.NoError(t, tx.begin(1))
+		sc, _, _ := tx.produce(1, []int64{1}, message, "some-topic", 0, nil, nil)
+		require.Equal(t, 200, sc)
+		sc, _, _ = tx.produce(1, []int64{3}, message, "some-topic", 0, nil, nil)
+		require.Equal(t, 200, sc)
+		sc, _, _ = tx.produce(1, []int64{5}, message, "some-topic", 0, nil, nil)
+		require.Equal(t, 500, sc, "job ids not fitting in the metadata should not be produced")
+		require.Len(t, p.published, 2)
+		require.NoError(t, tx.commit(1))
+		require.Equal(t, []string{"v1:1,3"}, p.commits)
+
+		require.NoError(t, tx.begin(1))
+		sc, _, _ = tx.produce(1, []int64{5}, message, "some-topic", 0, nil, nil)
+		require.Equal(t, 200, sc)
+		require.NoError(t, tx.commit(1))
+		require.Equal(t, []string{"v1:1,3", "v1:3,5"}, p.commits, "older ranges can be dropped")
+		w, ok := tx.get(1)
+		require.True(t, ok)
+		require.False(t, w.committed.contains(1), "jobs dropped from the metadata are not kept in memory either")
+		require.True(t, w.committed.containsAll([]int64{3, 5}))
+	})
+
+	t.Run("a partition has one open transaction at a time", func(t *testing.T) {
+		p := &txProducerMock{}
+		tx := newTestTransactions(t, p)
+		tx.lockTimeout = 10 * time.Millisecond
+
+		require.NoError(t, tx.begin(1))
+		require.Error(t, tx.begin(1), "partition should be held by the open transaction")
+		require.NoError(t, tx.commit(1))
+		require.NoError(t, tx.begin(1))
+		require.NoError(t, tx.abort(1))
+		require.NoError(t, tx.begin(1))
+
+		tx.lockTimeout = time.Second
+		released := make(chan struct{})
+		go func() {
+			defer close(released)
+			require.NoError(t, tx.begin(1))
+		}()
+		require.NoError(t, tx.commit(1))
+		<-released
+		require.NoError(t, tx.commit(1))
+	})
+
+	t.Run("partitions depend on the user only", func(t *testing.T) {
+		tx := newTestTransactions(t, &txProducerMock{})
+		tx.numPartitions = 16
+		for _, userID := range []string{"", "user-1", "user-2", "some other user"} {
+			partition := tx.partition(userID)
+			require.GreaterOrEqual(t, partition, 0)
+			require.Less(t, partition, 16)
+			require.Equal(t, partition, tx.partition(userID))
+		}
+	})
+
+	t.Run("invalid metadata", func(t *testing.T) {
+		p := &txProducerMock{metadata: "invalid"}
+		tx := newTestTransactions(t, p)
+		require.Error(t, tx.begin(1))
+		require.True(t, p.closed)
+	})
+}
+
+type txProducerMock struct {
+	metadata   string
+	publishErr error
+	commitErr  error
+
+	created   int
+	published []client.Message
+	commits   []string
+	aborts    int
+	closed    bool
+}
+
+func (*txProducerMock) BeginTransaction() error { return nil }
+
+func (p *txProducerMock) Publish(_ context.Context, msgs ...client.Message) error {
+	if p.publishErr != nil {
+		return p.publishErr
+	}
+	p.published = append(p.published, msgs...)
+	return nil
+}
+
+func (p *txProducerMock) CommitTransaction(_ context.Context, metadata string) error {
+	if p.commitErr != nil {
+		return p.commitErr
+	}
+	p.commits = append(p.commits, metadata)
+	return nil
+}
+
+func (p *txProducerMock) AbortTransaction(_ context.Context) error {
+	p.aborts++
+	return nil
+}
+
+func (p *txProducerMock) LastCommittedMetadata(_ context.Context) (string, error) {
+	return p.metadata, nil
+}
+
+func (p *txProducerMock) Close(_ context.Context) error {
+	p.closed = true
+	return nil
+}
@@ -0,0 +1,129 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+var errNacked = errors.New("message not acknowledged by the broker")
+
+// ReturnedError is returned when a mandatory message could not be routed to any queue
+type ReturnedError struct {
+	ReplyCode uint16
+	ReplyText string
+}
+
+func (e *ReturnedError) Error() string {
+	return fmt.Sprintf("message returned by the broker: %d %s", e.ReplyCode, e.ReplyText)
+}
+
+// confirmingClient publishes messages on a channel in confirm mode.
+// Publishing is serialized, so that the confirmation and the possible return of every message can be matched with
+// it. The connection and the channel are reopened lazily once they get closed, e.g. after a channel exception.
+type confirmingClient struct {
+	url    string
+	config amqp.Config
+
+	mu       sync.Mutex
+	conn     *amqp.Connection
+	channel  *amqp.Channel
+	closed   chan *amqp.Error
+	confirms chan amqp.Confirmation
+	returns  chan amqp.Return
+}
+
+func (c *confirmingClient) Publish(ctx context.Context, exchange, routingKey string, mandatory bool, msg amqp.Publishing) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.open(); err != nil {
+		return err
+	}
+	if err := c.channel.PublishWithContext(ctx, exchange, routingKey, mandatory, false, msg); err != nil {
+		c.resetChannel()
+		return err
+	}
+
+	select {
+	case confirm, ok := <-c.confirms:
+		if !ok {
+			return c.closeError()
+		}
+		// the broker sends the return of an unroutable message before its confirmation
+		select {
+		case returned := <-c.returns:
+			return &ReturnedError{ReplyCode: returned.ReplyCode, ReplyText: returned.ReplyText}
+		default:
+		}
+		if !confirm.Ack {
+			return errNacked
+		}
+		return nil
+	case <-ctx.Done():
+		// the confirmation might still arrive, starting over with a new channel to not mistake it for the next one
+		c.resetChannel()
+		return ctx.Err()
+	}
+}
+
+// open opens the connection and the channel if they are not open already
+func (c *confirmingClient) open() error {
+	if c.channel != nil {
+		select {
+		case <-c.closed:
+			c.channel = nil
+		default:
+			return nil
+		}
+	}
+	if c.conn == nil || c.conn.IsClosed() {
+		conn, err := amqp.DialConfig(c.url, c.config)
+		if err != nil {
+			return err
+		}
+		c.conn = conn
+	}
+	channel, err := c.conn.Channel()
+	if err != nil {
+		return err
+	}
+	if err = channel.Confirm(false); err != nil {
+		_ = channel.Close()
+		return err
+	}
+	c.channel = channel
+	c.closed = channel.NotifyClose(make(chan *amqp.Error, 1))
+	c.confirms = channel.NotifyPublish(make(chan amqp.Confirmation, 1))
+	c.returns = channel.NotifyReturn(make(chan amqp.Return, 1))
+	return nil
+}
+
+// closeError returns the reason why the channel got closed
+func (c *confirmingClient) closeError() error {
+	err, ok := <-c.closed
+	c.channel = nil
+	if ok && err != nil {
+		return err
+	}
+	return amqp.ErrClosed
+}
+
+func (c *confirmingClient) resetChannel() {
+	if c.channel != nil {
+		_ = c.channel.Close()
+		c.channel = nil
+	}
+}
+
+func (c *confirmingClient) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.resetChannel()
+	if c.conn == nil || c.conn.IsClosed() {
+		return nil
+	}
+	return c.conn.Close()
+}
@@ -0,0 +1,202 @@
+//go:generate mockgen -destination=../../../mocks/services/streammanager/rabbitmq/mock_rabbitmq.go -package mock_rabbitmq github.com/rudderlabs/rudder-server/services/streammanager/rabbitmq RabbitMQClient
+
+package rabbitmq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/tidwall/gjson"
+
+	backendconfig "github.com/rudderlabs/rudder-server/config/backend-config"
+	"github.com/rudderlabs/rudder-server/services/streammanager/common"
+	"github.com/rudderlabs/rudder-server/utils/logger"
+)
+
+var pkgLogger logger.Logger
+
+func init() {
+	pkgLogger = logger.NewLogger().Child("streammanager").Child("rabbitmq")
+}
+
+// Config is the config of a RabbitMQ destination
+type Config struct {
+	Host        string `json:"host"`
+	Port        string `json:"port"`
+	VirtualHost string `json:"virtualHost"`
+	Username    string `json:"username"`
+	Password    string `json:"password"`
+	// Exchange is the exchange the messages are published to, the default exchange is used if empty
+	Exchange string `json:"exchange"`
+	// RoutingKey is the default routing key of the messages, it can be overridden by the "routingKey" of the payload
+	RoutingKey string `json:"routingKey"`
+	// Persistent makes the broker store the messages on disk
+	Persistent bool `json:"persistent"`
+	// Mandatory makes the broker reject the messages which cannot be routed to any queue
+	Mandatory bool `json:"mandatory"`
+	common.TLSConfig
+}
+
+func (c *Config) validate() error {
+	if c.Host == "" {
+		return fmt.Errorf("host cannot be empty")
+	}
+	port, err := strconv.Atoi(c.Port)
+	if err != nil {
+		return fmt.Errorf("invalid port: %w", err)
+	}
+	if port < 1 {
+		return fmt.Errorf("invalid port: %d", port)
+	}
+	return nil
+}
+
+func (c *Config) url() string {
+	scheme := "amqp"
+	if c.SslEnabled {
+		scheme = "amqps"
+	}
+	u := url.URL{
+		Scheme: scheme,
+		Host:   net.JoinHostPort(c.Host, c.Port),
+		Path:   "/" + c.VirtualHost,
+	}
+	if c.Username != "" {
+		u.User = url.UserPassword(c.Username, c.Password)
+	}
+	return u.String()
+}
+
+// RabbitMQClient publishes messages waiting for the broker to confirm them
+type RabbitMQClient interface {
+	Publish(ctx context.Context, exchange, routingKey string, mandatory bool, msg amqp.Publishing) error
+	Close() error
+}
+
+type RabbitMQProducer struct {
+	client RabbitMQClient
+	config Config
+	opts   common.Opts
+}
+
+// NewProducer creates a producer based on destination config
+func NewProducer(destination *backendconfig.DestinationT, o common.Opts) (*RabbitMQProducer, error) {
+	var config Config
+	jsonConfig, err := json.Marshal(destination.Config)
+	if err != nil {
+		return nil, fmt.Errorf("[RabbitMQ] error while marshalling destination config: %w", err)
+	}
+	if err = json.Unmarshal(jsonConfig, &config); err != nil {
+		return nil, fmt.Errorf("[RabbitMQ] error while unmarshalling destination config: %w", err)
+	}
+	if err = config.validate(); err != nil {
+		return nil, fmt.Errorf("[RabbitMQ] invalid configuration: %w", err)
+	}
+	tlsConfig, err := config.TLSConfig.Build()
+	if err != nil {
+		return nil, fmt.Errorf("[RabbitMQ] invalid configuration: %w", err)
+	}
+	amqpConfig := amqp.Config{
+		Properties:      amqp.NewConnectionProperties(),
+		TLSClientConfig: tlsConfig,
+	}
+	amqpConfig.Properties.SetClientConnectionName("rudder-server")
+	if o.Timeout > 0 {
+		amqpConfig.Dial = amqp.DefaultDial(o.Timeout)
+	}
+	client := &confirmingClient{url: config.url(), config: amqpConfig}
+	// connecting eagerly so that invalid configurations are reported as soon as the producer is created
+	if err = client.open(); err != nil {
+		return nil, fmt.Errorf("[RabbitMQ] could not connect: %w", err)
+	}
+	return &RabbitMQProducer{client: client, config: config, opts: o}, nil
+}
+
+// Produce publishes the message of the payload and waits for the broker to confirm it.
+// The payload is expected to contain the "message" and optionally the "routingKey", the "messageId" and the
+// "headers" of the message.
+func (producer *RabbitMQProducer) Produce(jsonData json.RawMessage, _ interface{}) (int, string, string) {
+	client := producer.client
+	if client == nil {
+		return 400, "Failure", "[RabbitMQ] error :: Could not create producer"
+	}
+	parsedJSON := gjson.ParseBytes(jsonData)
+	message := parsedJSON.Get("message")
+	if !message.Exists() {
+		return 400, "Failure", "[RabbitMQ] error :: message from payload not found"
+	}
+	routingKey := producer.config.RoutingKey
+	if key := parsedJSON.Get("routingKey").String(); key != "" {
+		routingKey = key
+	}
+
+	msg := amqp.Publishing{
+		ContentType: "application/json",
+		MessageId:   parsedJSON.Get("messageId").String(),
+		Timestamp:   time.Now(),
+		Body:        []byte(message.Raw),
+	}
+	if producer.config.Persistent {
+		msg.DeliveryMode = amqp.Persistent
+	}
+	if headers := parsedJSON.Get("headers"); headers.IsObject() {
+		msg.Headers = amqp.Table{}
+		headers.ForEach(func(key, value gjson.Result) bool {
+			msg.Headers[key.String()] = value.String()
+			return true
+		})
+	}
+
+	ctx := context.Background()
+	if producer.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, producer.opts.Timeout)
+		defer cancel()
+	}
+	err := client.Publish(ctx, producer.config.Exchange, routingKey, producer.config.Mandatory, msg)
+	if err != nil {
+		statusCode := getStatusCode(err)
+		pkgLogger.Errorf("[RabbitMQ] error :: %d : %v", statusCode, err)
+		return statusCode, "Failure", "[RabbitMQ] error :: Failed to publish: " + err.Error()
+	}
+	return 200, "Success", "Message published and confirmed by the broker"
+}
+
+// getStatusCode maps publishing errors to status codes, errors which might be temporary are retried
+func getStatusCode(err error) int {
+	var (
+		amqpErr     *amqp.Error
+		returnedErr *ReturnedError
+	)
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return 504
+	case errors.As(err, &returnedErr):
+		return 400
+	case errors.As(err, &amqpErr):
+		switch amqpErr.Code {
+		case amqp.NotFound, amqp.AccessRefused, amqp.PreconditionFailed, amqp.NotAllowed, amqp.FrameError:
+			return 400
+		default:
+			return 500
+		}
+	default:
+		// e.g. negative acknowledgements or connection errors
+		return 500
+	}
+}
+
+// Close closes the connection of the producer
+func (producer *RabbitMQProducer) Close() error {
+	if producer.client == nil {
+		return nil
+	}
+	return producer.client.Close()
+}
@@ -0,0 +1,192 @@
+//go:generate mockgen -destination=../../../mocks/services/streammanager/redisstreams/mock_redisstreams.go -package mock_redisstreams github.com/rudderlabs/rudder-server/services/streammanager/redisstreams RedisStreamsClient
+
+package redisstreams
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/go-redis/redis"
+	"github.com/tidwall/gjson"
+
+	backendconfig "github.com/rudderlabs/rudder-server/config/backend-config"
+	"github.com/rudderlabs/rudder-server/services/streammanager/common"
+	"github.com/rudderlabs/rudder-server/utils/logger"
+)
+
+var pkgLogger logger.Logger
+
+// retryableErrorPrefixes are the prefixes of the errors replied by servers which are temporarily unavailable
+var retryableErrorPrefixes = []string{"LOADING", "READONLY", "MASTERDOWN", "TRYAGAIN", "CLUSTERDOWN", "BUSY", "OOM"}
+
+func init() {
+	pkgLogger = logger.NewLogger().Child("streammanager").Child("redisstreams")
+}
+
+// Config is the config of a Redis Streams destination
+type Config struct {
+	// Address is a comma separated list of addresses in cluster mode
+	Address     string `json:"address"`
+	Password    string `json:"password"`
+	Database    string `json:"database"`
+	ClusterMode bool   `json:"clusterMode"`
+	// Stream is the default stream of the entries, it can be overridden by the "stream" of the payload
+	Stream string `json:"stream"`
+	// MaxLen is the approximate number of entries the streams are trimmed to, streams are not trimmed if 0
+	MaxLen int64 `json:"maxLen"`
+	common.TLSConfig
+}
+
+func (c *Config) validate() error {
+	if strings.TrimSpace(c.Address) == "" {
+		return fmt.Errorf("address cannot be empty")
+	}
+	if c.MaxLen < 0 {
+		return fmt.Errorf("invalid max length: %d", c.MaxLen)
+	}
+	return nil
+}
+
+// RedisStreamsClient adds entries to streams
+type RedisStreamsClient interface {
+	XAdd(a *redis.XAddArgs) *redis.StringCmd
+	Close() error
+}
+
+type RedisStreamsProducer struct {
+	client RedisStreamsClient
+	config Config
+}
+
+// NewProducer creates a producer based on destination config
+func NewProducer(destination *backendconfig.DestinationT, o common.Opts) (*RedisStreamsProducer, error) {
+	var config Config
+	jsonConfig, err := json.Marshal(destination.Config)
+	if err != nil {
+		return nil, fmt.Errorf("[RedisStreams] error while marshalling destination config: %w", err)
+	}
+	if err = json.Unmarshal(jsonConfig, &config); err != nil {
+		return nil, fmt.Errorf("[RedisStreams] error while unmarshalling destination config: %w", err)
+	}
+	if err = config.validate(); err != nil {
+		return nil, fmt.Errorf("[RedisStreams] invalid configuration: %w", err)
+	}
+	tlsConfig, err := config.TLSConfig.Build()
+	if err != nil {
+		return nil, fmt.Errorf("[RedisStreams] invalid configuration: %w", err)
+	}
+
+	var client redis.UniversalClient
+	if config.ClusterMode {
+		addrs := strings.Split(config.Address, ",")
+		for i := range addrs {
+			addrs[i] = strings.TrimSpace(addrs[i])
+		}
+		client = redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:        addrs,
+			Password:     config.Password,
+			TLSConfig:    tlsConfig,
+			ReadTimeout:  o.Timeout,
+			WriteTimeout: o.Timeout,
+		})
+	} else {
+		var db int
+		if config.Database != "" {
+			if db, err = strconv.Atoi(config.Database); err != nil {
+				return nil, fmt.Errorf("[RedisStreams] invalid database: %w", err)
+			}
+		}
+		client = redis.NewClient(&redis.Options{
+			Addr:         strings.TrimSpace(config.Address),
+			Password:     config.Password,
+			DB:           db,
+			TLSConfig:    tlsConfig,
+			ReadTimeout:  o.Timeout,
+			WriteTimeout: o.Timeout,
+		})
+	}
+	return &RedisStreamsProducer{client: client, config: config}, nil
+}
+
+// Produce adds the message of the payload as an entry of the stream. Every top level field of the message becomes
+// a field of the entry, with values other than strings encoded as JSON.
+// The payload is expected to contain the "message" and optionally the "stream".
+func (producer *RedisStreamsProducer) Produce(jsonData json.RawMessage, _ interface{}) (int, string, string) {
+	client := producer.client
+	if client == nil {
+		return 400, "Failure", "[RedisStreams] error :: Could not create producer"
+	}
+	parsedJSON := gjson.ParseBytes(jsonData)
+	message := parsedJSON.Get("message")
+	if !message.IsObject() {
+		return 400, "Failure", "[RedisStreams] error :: message from payload not found or not an object"
+	}
+	stream := producer.config.Stream
+	if s := parsedJSON.Get("stream").String(); s != "" {
+		stream = s
+	}
+	if stream == "" {
+		return 400, "Failure", "[RedisStreams] error :: stream not found"
+	}
+
+	values := make(map[string]interface{})
+	message.ForEach(func(key, value gjson.Result) bool {
+		if value.Type == gjson.String {
+			values[key.String()] = value.String()
+		} else {
+			values[key.String()] = value.Raw
+		}
+		return true
+	})
+	if len(values) == 0 {
+		return 400, "Failure", "[RedisStreams] error :: message cannot be empty"
+	}
+
+	id, err := client.XAdd(&redis.XAddArgs{
+		Stream:       stream,
+		MaxLenApprox: producer.config.MaxLen,
+		ID:           "*",
+		Values:       values,
+	}).Result()
+	if err != nil {
+		statusCode := getStatusCode(err)
+		pkgLogger.Errorf("[RedisStreams] error :: %d : %v", statusCode, err)
+		return statusCode, "Failure", "[RedisStreams] error :: Failed to add entry: " + err.Error()
+	}
+	return 200, "Success", fmt.Sprintf("Entry added to stream %s with id %s", stream, id)
+}
+
+// getStatusCode maps errors to status codes, errors which might be temporary are retried
+func getStatusCode(err error) int {
+	var netErr net.Error
+	switch {
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return 504
+	case errors.As(err, &netErr), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return 500
+	}
+	msg := err.Error()
+	if strings.HasPrefix(msg, "redis: ") { // client errors, e.g. pool timeouts
+		return 500
+	}
+	for _, prefix := range retryableErrorPrefixes {
+		if strings.HasPrefix(msg, prefix) {
+			return 500
+		}
+	}
+	// errors replied by the server which won't go away by retrying, e.g. WRONGTYPE or NOAUTH
+	return 400
+}
+
+// Close closes the client of the producer
+func (producer *RedisStreamsProducer) Close() error {
+	if producer.client == nil {
+		return nil
+	}
+	return producer.client.Close()
+}
@@ -0,0 +1,148 @@
+package redisstreams
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/golang/mock/gomock"
+	"github.com/ory/dockertest/v3"
+	"github.com/stretchr/testify/require"
+
+	backendconfig "github.com/rudderlabs/rudder-server/config/backend-config"
+	mock_redisstreams "github.com/rudderlabs/rudder-server/mocks/services/streammanager/redisstreams"
+	"github.com/rudderlabs/rudder-server/services/streammanager/common"
+	"github.com/rudderlabs/rudder-server/testhelper/destination"
+)
+
+func TestNewProducer(t *testing.T) {
+	t.Run("invalid configuration", func(t *testing.T) {
+		for name, config := range map[string]map[string]interface{}{
+			"missing address":  {"stream": "stream"},
+			"invalid max len":  {"address": "localhost:6379", "maxLen": -1},
+			"invalid database": {"address": "localhost:6379", "database": "abc"},
+			"invalid CA":       {"address": "localhost:6379", "sslEnabled": true, "caCertificate": "invalid"},
+		} {
+			t.Run(name, func(t *testing.T) {
+				p, err := NewProducer(&backendconfig.DestinationT{Config: config}, common.Opts{Timeout: time.Second})
+				require.Error(t, err)
+				require.Nil(t, p)
+			})
+		}
+	})
+
+	t.Run("cluster mode", func(t *testing.T) {
+		p, err := NewProducer(&backendconfig.DestinationT{Config: map[string]interface{}{
+			"address":     "host1:6379, host2:6379",
+			"clusterMode": true,
+		}}, common.Opts{Timeout: time.Second})
+		require.NoError(t, err)
+		require.IsType(t, &redis.ClusterClient{}, p.client)
+		require.NoError(t, p.Close())
+	})
+}
+
+func TestProduce(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	client := mock_redisstreams.NewMockRedisStreamsClient(ctrl)
+	producer := &RedisStreamsProducer{client: client, config: Config{Stream: "default", MaxLen: 1000}}
+
+	t.Run("adds the fields of the message to the stream of the payload", func(t *testing.T) {
+		client.EXPECT().XAdd(&redis.XAddArgs{
+			Stream:       "orders",
+			MaxLenApprox: 1000,
+			ID:           "*",
+			Values:       map[string]interface{}{"event": "Order Completed", "amount": "10", "properties": `{"a":[1,2]}`},
+		}).Return(redis.NewStringResult("1-0", nil))
+		statusCode, respStatus, respMessage := producer.Produce([]byte(`{
+			"message": {"event": "Order Completed", "amount": 10, "properties": {"a":[1,2]}},
+			"stream": "orders"
+		}`), nil)
+		require.Equal(t, 200, statusCode)
+		require.Equal(t, "Success", respStatus)
+		require.Equal(t, "Entry added to stream orders with id 1-0", respMessage)
+	})
+
+	t.Run("defaults to the stream of the configuration", func(t *testing.T) {
+		client.EXPECT().XAdd(gomock.Any()).DoAndReturn(func(a *redis.XAddArgs) *redis.StringCmd {
+			require.Equal(t, "default", a.Stream)
+			return redis.NewStringResult("1-1", nil)
+		})
+		statusCode, _, _ := producer.Produce([]byte(`{"message":{"a":1}}`), nil)
+		require.Equal(t, 200, statusCode)
+	})
+
+	t.Run("invalid payload", func(t *testing.T) {
+		for payload, respMessage := range map[string]string{
+			`{"stream":"stream"}`: "message from payload not found",
+			`{"message":"text"}`:  "message from payload not found",
+			`{"message":{}}`:      "message cannot be empty",
+		} {
+			statusCode, _, actual := producer.Produce([]byte(payload), nil)
+			require.Equal(t, 400, statusCode, payload)
+			require.Contains(t, actual, respMessage)
+		}
+
+		noStream := &RedisStreamsProducer{client: client}
+		statusCode, _, respMessage := noStream.Produce([]byte(`{"message":{"a":1}}`), nil)
+		require.Equal(t, 400, statusCode)
+		require.Contains(t, respMessage, "stream not found")
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		for err, statusCode := range map[error]int{
+			&net.OpError{Op: "read", Err: timeoutError{}}:                   504,
+			&net.OpError{Op: "dial", Err: errors.New("connection refused")}: 500,
+			io.EOF: 500,
+			errors.New("redis: connection pool timeout"):                                    500,
+			errors.New("LOADING Redis is loading the dataset in memory"):                    500,
+			errors.New("WRONGTYPE Operation against a key holding the wrong kind of value"): 400,
+			errors.New("NOAUTH Authentication required."):                                   400,
+		} {
+			client.EXPECT().XAdd(gomock.Any()).Return(redis.NewStringResult("", err))
+			actual, respStatus, respMessage := producer.Produce([]byte(`{"message":{"a":1}}`), nil)
+			require.Equal(t, statusCode, actual, err.Error())
+			require.Equal(t, "Failure", respStatus)
+			require.Contains(t, respMessage, err.Error())
+		}
+	})
+}
+
+func TestProduceWithServer(t *testing.T) {
+	pool, err := dockertest.NewPool("")
+	require.NoError(t, err)
+	redisResource, err := destination.SetupRedis(pool, t)
+	require.NoError(t, err)
+
+	producer, err := NewProducer(&backendconfig.DestinationT{Config: map[string]interface{}{
+		"address": redisResource.RedisAddress,
+		"stream":  "events",
+		"maxLen":  10,
+	}}, common.Opts{Timeout: 10 * time.Second})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = producer.Close() })
+
+	statusCode, _, respMessage := producer.Produce([]byte(`{"message":{"event":"e","amount":10}}`), nil)
+	require.Equal(t, 200, statusCode, respMessage)
+
+	client := redis.NewClient(&redis.Options{Addr: redisResource.RedisAddress})
+	t.Cleanup(func() { _ = client.Close() })
+	entries, err := client.XRange("events", "-", "+").Result()
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, map[string]interface{}{"event": "e", "amount": "10"}, entries[0].Values)
+
+	require.NoError(t, client.Set("not-a-stream", "value", 0).Err())
+	statusCode, _, respMessage = producer.Produce([]byte(`{"message":{"a":1},"stream":"not-a-stream"}`), nil)
+	require.Equal(t, 400, statusCode, respMessage)
+	require.Contains(t, respMessage, "WRONGTYPE")
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
@@ -11,10 +11,13 @@ import (
 	"github.com/rudderlabs/rudder-server/services/streammanager/firehose"
 	"github.com/rudderlabs/rudder-server/services/streammanager/googlepubsub"
 	"github.com/rudderlabs/rudder-server/services/streammanager/googlesheets"
+	"github.com/rudderlabs/rudder-server/services/streammanager/jetstream"
 	"github.com/rudderlabs/rudder-server/services/streammanager/kafka"
 	"github.com/rudderlabs/rudder-server/services/streammanager/kinesis"
 	"github.com/rudderlabs/rudder-server/services/streammanager/lambda"
 	"github.com/rudderlabs/rudder-server/services/streammanager/personalize"
+	"github.com/rudderlabs/rudder-server/services/streammanager/rabbitmq"
+	"github.com/rudderlabs/rudder-server/services/streammanager/redisstreams"
 )
 
 // NewProducer delegates the call to the appropriate based on parameter destination for creating producer
@@ -45,6 +48,12 @@ func NewProducer(destination *backendconfig.DestinationT, opts common.Opts) (com
 		return bqstream.NewProducer(destination, opts)
 	case "LAMBDA":
 		return lambda.NewProducer(destination, opts)
+	case "NATS_JETSTREAM":
+		return jetstream.NewProducer(destination, opts)
+	case "RABBITMQ":
+		return rabbitmq.NewProducer(destination, opts)
+	case "REDIS_STREAMS":
+		return redisstreams.NewProducer(destination, opts)
 	default:
 		return nil, fmt.Errorf("no provider configured for StreamManager") // 404, "No provider configured for StreamManager", ""
 	}
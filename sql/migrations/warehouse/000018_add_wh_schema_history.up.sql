@@ -0,0 +1,25 @@
+--
+-- wh_schema_history
+--
+
+CREATE TABLE IF NOT EXISTS wh_schema_history (
+    id BIGSERIAL PRIMARY KEY,
+    workspace_id VARCHAR NOT NULL DEFAULT '',
+    source_id VARCHAR(64) NOT NULL,
+    destination_id VARCHAR(64) NOT NULL,
+    destination_type VARCHAR(64) NOT NULL,
+    namespace VARCHAR(64) NOT NULL,
+    upload_id BIGINT NOT NULL,
+    table_name TEXT NOT NULL,
+    column_name TEXT NOT NULL,
+    change_type VARCHAR(64) NOT NULL,
+    from_type VARCHAR(64) NOT NULL DEFAULT '',
+    to_type VARCHAR(64) NOT NULL,
+    staging_file_id BIGINT,
+    event_sample JSONB,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS wh_schema_history_destination_table_column_index ON wh_schema_history (destination_id, namespace, table_name, column_name);
+
+CREATE INDEX IF NOT EXISTS wh_schema_history_upload_id_index ON wh_schema_history (upload_id);
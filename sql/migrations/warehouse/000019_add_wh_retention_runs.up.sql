@@ -0,0 +1,24 @@
+--
+-- wh_retention_runs
+--
+
+CREATE TABLE IF NOT EXISTS wh_retention_runs (
+    id BIGSERIAL PRIMARY KEY,
+    workspace_id VARCHAR NOT NULL DEFAULT '',
+    destination_id VARCHAR(64) NOT NULL,
+    destination_type VARCHAR(64) NOT NULL,
+    namespace VARCHAR(64) NOT NULL,
+    table_name TEXT NOT NULL,
+    retention_column TEXT NOT NULL,
+    retention_days INTEGER NOT NULL,
+    cutoff_at TIMESTAMP NOT NULL,
+    status VARCHAR(64) NOT NULL,
+    method VARCHAR(64) NOT NULL DEFAULT '',
+    rows_deleted BIGINT NOT NULL DEFAULT 0,
+    partitions_dropped BIGINT NOT NULL DEFAULT 0,
+    error TEXT,
+    started_at TIMESTAMP NOT NULL,
+    completed_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS wh_retention_runs_destination_table_index ON wh_retention_runs (destination_id, namespace, table_name, status);
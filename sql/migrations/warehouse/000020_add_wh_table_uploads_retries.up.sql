@@ -0,0 +1,7 @@
+--
+-- wh_table_uploads
+--
+
+ALTER TABLE wh_table_uploads ADD COLUMN IF NOT EXISTS attempt INT NOT NULL DEFAULT 0;
+
+ALTER TABLE wh_table_uploads ADD COLUMN IF NOT EXISTS next_retry_time TIMESTAMP;
@@ -0,0 +1,34 @@
+--
+-- wh_reverse_etl_runs
+--
+
+CREATE TABLE IF NOT EXISTS wh_reverse_etl_runs (
+    id BIGSERIAL PRIMARY KEY,
+    workspace_id VARCHAR NOT NULL DEFAULT '',
+    destination_id VARCHAR(64) NOT NULL,
+    destination_type VARCHAR(64) NOT NULL,
+    model_name TEXT NOT NULL,
+    source_id VARCHAR(64) NOT NULL,
+    status VARCHAR(64) NOT NULL,
+    rows_queried BIGINT NOT NULL DEFAULT 0,
+    rows_changed BIGINT NOT NULL DEFAULT 0,
+    rows_removed BIGINT NOT NULL DEFAULT 0,
+    error TEXT,
+    started_at TIMESTAMP NOT NULL,
+    completed_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS wh_reverse_etl_runs_destination_model_index ON wh_reverse_etl_runs (destination_id, model_name, status);
+
+--
+-- wh_reverse_etl_snapshots
+--
+
+CREATE TABLE IF NOT EXISTS wh_reverse_etl_snapshots (
+    destination_id VARCHAR(64) NOT NULL,
+    model_name TEXT NOT NULL,
+    row_key TEXT NOT NULL,
+    row_hash VARCHAR(32) NOT NULL,
+    synced_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (destination_id, model_name, row_key)
+);
@@ -0,0 +1,19 @@
+--
+-- wh_sla_breaches
+--
+
+CREATE TABLE IF NOT EXISTS wh_sla_breaches (
+    id BIGSERIAL PRIMARY KEY,
+    workspace_id VARCHAR NOT NULL DEFAULT '',
+    source_id VARCHAR(64) NOT NULL,
+    destination_id VARCHAR(64) NOT NULL,
+    destination_type VARCHAR(64) NOT NULL,
+    namespace VARCHAR(64) NOT NULL,
+    breach_type VARCHAR(64) NOT NULL,
+    message TEXT NOT NULL,
+    started_at TIMESTAMP NOT NULL,
+    alerted_at TIMESTAMP,
+    resolved_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS wh_sla_breaches_source_destination_index ON wh_sla_breaches (source_id, destination_id, resolved_at);
@@ -0,0 +1,23 @@
+--
+-- wh_usage
+--
+
+CREATE TABLE IF NOT EXISTS wh_usage (
+    id BIGSERIAL PRIMARY KEY,
+    workspace_id VARCHAR NOT NULL DEFAULT '',
+    source_id VARCHAR(64) NOT NULL,
+    destination_id VARCHAR(64) NOT NULL,
+    destination_type VARCHAR(64) NOT NULL,
+    namespace VARCHAR(64) NOT NULL,
+    table_name TEXT NOT NULL,
+    day DATE NOT NULL,
+    uploads BIGINT NOT NULL DEFAULT 0,
+    rows BIGINT NOT NULL DEFAULT 0,
+    bytes BIGINT NOT NULL DEFAULT 0,
+    load_duration_ms BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    UNIQUE (source_id, destination_id, namespace, table_name, day)
+);
+
+CREATE INDEX IF NOT EXISTS wh_usage_workspace_id_day_index ON wh_usage (workspace_id, day);
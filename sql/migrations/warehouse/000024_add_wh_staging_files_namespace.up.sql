@@ -0,0 +1,7 @@
+--
+-- wh_staging_files
+--
+
+ALTER TABLE wh_staging_files ADD COLUMN IF NOT EXISTS namespace VARCHAR(64) NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS wh_staging_files_namespace_index ON wh_staging_files (source_id, destination_id, namespace, id);
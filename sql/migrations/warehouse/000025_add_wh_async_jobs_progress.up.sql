@@ -0,0 +1,7 @@
+--
+-- wh_async_jobs
+--
+
+ALTER TABLE wh_async_jobs ADD COLUMN IF NOT EXISTS progress JSONB NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS wh_async_jobs_destination_id_status_index ON wh_async_jobs (destination_id, status);
@@ -0,0 +1,42 @@
+package destination
+
+import (
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+	"github.com/ory/dockertest/v3"
+)
+
+type NATSResource struct {
+	URL string
+}
+
+// SetupNATS starts a NATS server with JetStream enabled
+func SetupNATS(pool *dockertest.Pool, d cleaner) (*NATSResource, error) {
+	natsContainer, err := pool.RunWithOptions(&dockertest.RunOptions{
+		Repository: "nats",
+		Tag:        "2.9-alpine",
+		Cmd:        []string{"-js"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	d.Cleanup(func() {
+		if err := pool.Purge(natsContainer); err != nil {
+			d.Log("Could not purge resource:", err)
+		}
+	})
+	// exponential backoff-retry, because the application in the container might not be ready to accept connections yet
+	natsURL := fmt.Sprintf("nats://localhost:%s", natsContainer.GetPort("4222/tcp"))
+	if err := pool.Retry(func() error {
+		conn, err := nats.Connect(natsURL)
+		if err != nil {
+			return err
+		}
+		conn.Close()
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return &NATSResource{URL: natsURL}, nil
+}
@@ -0,0 +1,55 @@
+package destination
+
+import (
+	"fmt"
+
+	"github.com/ory/dockertest/v3"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	rabbitMQDefaultUser     = "rudder"
+	rabbitMQDefaultPassword = "password"
+)
+
+type RabbitMQResource struct {
+	URL      string
+	Host     string
+	Port     string
+	User     string
+	Password string
+}
+
+func SetupRabbitMQ(pool *dockertest.Pool, d cleaner) (*RabbitMQResource, error) {
+	rabbitMQContainer, err := pool.Run("rabbitmq", "3.11-alpine", []string{
+		"RABBITMQ_DEFAULT_USER=" + rabbitMQDefaultUser,
+		"RABBITMQ_DEFAULT_PASS=" + rabbitMQDefaultPassword,
+	})
+	if err != nil {
+		return nil, err
+	}
+	d.Cleanup(func() {
+		if err := pool.Purge(rabbitMQContainer); err != nil {
+			d.Log("Could not purge resource:", err)
+		}
+	})
+	port := rabbitMQContainer.GetPort("5672/tcp")
+	rabbitMQURL := fmt.Sprintf("amqp://%s:%s@localhost:%s/", rabbitMQDefaultUser, rabbitMQDefaultPassword, port)
+	// exponential backoff-retry, because the application in the container might not be ready to accept connections yet
+	if err := pool.Retry(func() error {
+		conn, err := amqp.Dial(rabbitMQURL)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}); err != nil {
+		return nil, err
+	}
+	return &RabbitMQResource{
+		URL:      rabbitMQURL,
+		Host:     "localhost",
+		Port:     port,
+		User:     rabbitMQDefaultUser,
+		Password: rabbitMQDefaultPassword,
+	}, nil
+}
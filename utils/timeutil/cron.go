@@ -0,0 +1,148 @@
+package timeutil
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronMaxLookBackDays bounds the search of previous activations, covering the schedules running only on the 29th of February
+const cronMaxLookBackDays = 8 * 366
+
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// CronSchedule is a schedule parsed from a standard cron expression with the fields minute, hour, day of month, month and day of week
+type CronSchedule struct {
+	minutes, hours, daysOfMonth, months, daysOfWeek []bool
+	// as in most cron implementations, when both the day of month and the day of week are restricted, a day matching either of them is activated
+	restrictedDaysOfMonth, restrictedDaysOfWeek bool
+}
+
+// ParseCron parses a cron expression like `*/15 9-17 * * 1-5`, supporting lists, ranges, steps and macros like `@daily`
+func ParseCron(expr string) (*CronSchedule, error) {
+	expr = strings.TrimSpace(expr)
+	if macro, ok := cronMacros[expr]; ok {
+		expr = macro
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression %q must have 5 fields, has %d", expr, len(fields))
+	}
+
+	var (
+		schedule CronSchedule
+		err      error
+	)
+	if schedule.minutes, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, fmt.Errorf("invalid minutes in cron expression %q: %w", expr, err)
+	}
+	if schedule.hours, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, fmt.Errorf("invalid hours in cron expression %q: %w", expr, err)
+	}
+	if schedule.daysOfMonth, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, fmt.Errorf("invalid days of month in cron expression %q: %w", expr, err)
+	}
+	if schedule.months, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, fmt.Errorf("invalid months in cron expression %q: %w", expr, err)
+	}
+	// 7 is sunday as well as 0
+	if schedule.daysOfWeek, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, fmt.Errorf("invalid days of week in cron expression %q: %w", expr, err)
+	}
+	schedule.daysOfWeek[0] = schedule.daysOfWeek[0] || schedule.daysOfWeek[7]
+	schedule.restrictedDaysOfMonth = !strings.HasPrefix(fields[2], "*")
+	schedule.restrictedDaysOfWeek = !strings.HasPrefix(fields[4], "*")
+	return &schedule, nil
+}
+
+func parseCronField(field string, min, max int) ([]bool, error) {
+	values := make([]bool, max+1)
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if idx := strings.Index(part, "/"); idx >= 0 {
+			var err error
+			rangePart = part[:idx]
+			if step, err = strconv.Atoi(part[idx+1:]); err != nil || step <= 0 {
+				return nil, fmt.Errorf("invalid step in %q", part)
+			}
+		}
+
+		start, end := min, max
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err1, err2 error
+			start, err1 = strconv.Atoi(bounds[0])
+			end, err2 = strconv.Atoi(bounds[1])
+			if err1 != nil || err2 != nil {
+				return nil, fmt.Errorf("invalid range in %q", part)
+			}
+		default:
+			var err error
+			if start, err = strconv.Atoi(rangePart); err != nil {
+				return nil, fmt.Errorf("invalid value in %q", part)
+			}
+			// a single value with a step, like 5/15, runs until the end of the range
+			if step == 1 {
+				end = start
+			}
+		}
+		if start < min || end > max || start > end {
+			return nil, fmt.Errorf("%q is out of range %d-%d", part, min, max)
+		}
+		for value := start; value <= end; value += step {
+			values[value] = true
+		}
+	}
+	return values, nil
+}
+
+func (s *CronSchedule) matchesDay(t time.Time) bool {
+	if !s.months[t.Month()] {
+		return false
+	}
+	dayOfMonth, dayOfWeek := s.daysOfMonth[t.Day()], s.daysOfWeek[t.Weekday()]
+	if s.restrictedDaysOfMonth && s.restrictedDaysOfWeek {
+		return dayOfMonth || dayOfWeek
+	}
+	return dayOfMonth && dayOfWeek
+}
+
+// Prev returns the latest activation of the schedule at or before t, evaluated in the location of t.
+// It returns the zero time if the schedule was not activated in the last years.
+func (s *CronSchedule) Prev(t time.Time) time.Time {
+	t = t.Truncate(time.Minute)
+	day := StartOfDay(t)
+	for i := 0; i < cronMaxLookBackDays; i++ {
+		if s.matchesDay(day) {
+			for hour := 23; hour >= 0; hour-- {
+				if !s.hours[hour] {
+					continue
+				}
+				for minute := 59; minute >= 0; minute-- {
+					if !s.minutes[minute] {
+						continue
+					}
+					activation := time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, t.Location())
+					// skipping the wall clock times which do not exist on the day, like the ones skipped when daylight saving time starts
+					if activation.Hour() != hour || activation.After(t) {
+						continue
+					}
+					return activation
+				}
+			}
+		}
+		day = time.Date(day.Year(), day.Month(), day.Day()-1, 0, 0, 0, 0, t.Location())
+	}
+	return time.Time{}
+}
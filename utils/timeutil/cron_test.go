@@ -0,0 +1,116 @@
+package timeutil_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rudderlabs/rudder-server/utils/timeutil"
+)
+
+func TestCronSchedulePrev(t *testing.T) {
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	require.NoError(t, err)
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name     string
+		expr     string
+		now      time.Time
+		expected time.Time
+	}{
+		{
+			name:     "every 15 minutes",
+			expr:     "*/15 * * * *",
+			now:      time.Date(2022, 6, 15, 10, 44, 59, 0, time.UTC),
+			expected: time.Date(2022, 6, 15, 10, 30, 0, 0, time.UTC),
+		},
+		{
+			name:     "at activation",
+			expr:     "*/15 * * * *",
+			now:      time.Date(2022, 6, 15, 10, 45, 0, 0, time.UTC),
+			expected: time.Date(2022, 6, 15, 10, 45, 0, 0, time.UTC),
+		},
+		{
+			name:     "working hours on weekdays",
+			expr:     "0 9-17 * * 1-5",
+			now:      time.Date(2022, 6, 18, 12, 0, 0, 0, time.UTC), // saturday
+			expected: time.Date(2022, 6, 17, 17, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "lists",
+			expr:     "30 6,18 * * *",
+			now:      time.Date(2022, 6, 15, 5, 0, 0, 0, time.UTC),
+			expected: time.Date(2022, 6, 14, 18, 30, 0, 0, time.UTC),
+		},
+		{
+			name:     "sunday as 7",
+			expr:     "0 0 * * 7",
+			now:      time.Date(2022, 6, 15, 0, 0, 0, 0, time.UTC),
+			expected: time.Date(2022, 6, 12, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "day of month or day of week",
+			expr:     "0 0 1 * 5",
+			now:      time.Date(2022, 6, 2, 12, 0, 0, 0, time.UTC),
+			expected: time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "leap day",
+			expr:     "0 0 29 2 *",
+			now:      time.Date(2022, 6, 15, 0, 0, 0, 0, time.UTC),
+			expected: time.Date(2020, 2, 29, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "macro",
+			expr:     "@daily",
+			now:      time.Date(2022, 6, 15, 10, 0, 0, 0, time.UTC),
+			expected: time.Date(2022, 6, 15, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "timezone",
+			expr:     "0 2 * * *",
+			now:      time.Date(2022, 6, 15, 1, 0, 0, 0, time.UTC).In(kolkata),
+			expected: time.Date(2022, 6, 15, 2, 0, 0, 0, kolkata),
+		},
+		{
+			name:     "skipped by daylight saving time",
+			expr:     "30 2 * * *",
+			now:      time.Date(2022, 3, 13, 12, 0, 0, 0, newYork),
+			expected: time.Date(2022, 3, 12, 2, 30, 0, 0, newYork),
+		},
+		{
+			name: "never",
+			expr: "0 0 31 2 *",
+			now:  time.Date(2022, 6, 15, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			schedule, err := timeutil.ParseCron(tc.expr)
+			require.NoError(t, err)
+			require.True(t, tc.expected.Equal(schedule.Prev(tc.now)), "expected %v, got %v", tc.expected, schedule.Prev(tc.now))
+		})
+	}
+}
+
+func TestParseCronErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+	} {
+		_, err := timeutil.ParseCron(expr)
+		require.Error(t, err, expr)
+	}
+}
@@ -224,6 +224,11 @@ func archiveUploads(dbHandle *sql.DB) {
 		hasUsedRudderStorage := usedRudderStorage(u.uploadMetdata)
 
 		// archive staging files
+		// the staging files of the uploads of routed namespaces are in overlapping ranges
+		var namespaceSQL string
+		if routedNamespace, ok := getRoutedNamespace(u.uploadMetdata); ok {
+			namespaceSQL = fmt.Sprintf(`AND namespace = %s`, misc.QuoteLiteral(routedNamespace))
+		}
 		stmt := fmt.Sprintf(`
 			SELECT 
 			  id, 
@@ -234,13 +239,15 @@ func archiveUploads(dbHandle *sql.DB) {
 			  source_id = '%s' 
 			  AND destination_id = '%s' 
 			  AND id >= %d 
-			  and id <= %d;
+			  and id <= %d 
+			  %s;
 `,
 			warehouseutils.WarehouseStagingFilesTable,
 			u.sourceID,
 			u.destID,
 			u.startStagingFileId,
 			u.endStagingFileId,
+			namespaceSQL,
 		)
 
 		stagingFileRows, err := txn.Query(stmt)
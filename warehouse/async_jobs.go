@@ -0,0 +1,531 @@
+package warehouse
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tidwall/gjson"
+
+	"github.com/rudderlabs/rudder-server/config"
+	"github.com/rudderlabs/rudder-server/services/filemanager"
+	"github.com/rudderlabs/rudder-server/utils/misc"
+	"github.com/rudderlabs/rudder-server/warehouse/identity"
+	"github.com/rudderlabs/rudder-server/warehouse/jobs"
+	"github.com/rudderlabs/rudder-server/warehouse/manager"
+	warehouseutils "github.com/rudderlabs/rudder-server/warehouse/utils"
+)
+
+const (
+	gatewayBackupsTmpDirName = "rudder-warehouse-gateway-backups"
+	// gatewayBackupsListingTTL is how long the gateway backups listed for the backfill jobs of a run are kept
+	gatewayBackupsListingTTL = time.Hour
+)
+
+// gatewayBackupsListings keeps the gateway backups listed for the backfill jobs of a run, one job being added per
+// table, so that the backups are listed once per run rather than once per table
+var gatewayBackupsListings = struct {
+	sync.Mutex
+	byRun map[string]*gatewayBackupsListingT
+}{byRun: make(map[string]*gatewayBackupsListingT)}
+
+type gatewayBackupsListingT struct {
+	sync.Mutex
+	keys     []string
+	listed   bool
+	listedAt time.Time
+}
+
+// backfillColumnWarehouses are the warehouses whose columns can be backfilled with an UPDATE of the rows by id
+var backfillColumnWarehouses = []string{warehouseutils.POSTGRES, warehouseutils.RS, warehouseutils.SNOWFLAKE, warehouseutils.MSSQL, warehouseutils.AZURE_SYNAPSE}
+
+// asyncJobUploader is the uploader of the manager running an async job.
+// It exposes the schema of the warehouse and the identity mappings load file of the job to the manager.
+type asyncJobUploader struct {
+	*jobs.WhAsyncJob
+	destType          string
+	schemaInWarehouse warehouseutils.SchemaT
+	loadFile          warehouseutils.LoadFileT
+}
+
+func (uploader *asyncJobUploader) GetSchemaInWarehouse() warehouseutils.SchemaT {
+	return uploader.schemaInWarehouse
+}
+
+func (uploader *asyncJobUploader) GetTableSchemaInWarehouse(tableName string) warehouseutils.TableSchemaT {
+	return uploader.schemaInWarehouse[tableName]
+}
+
+func (uploader *asyncJobUploader) GetLoadFilesMetadata(warehouseutils.GetLoadFilesOptionsT) []warehouseutils.LoadFileT {
+	return []warehouseutils.LoadFileT{uploader.loadFile}
+}
+
+func (uploader *asyncJobUploader) GetSampleLoadFileLocation(string) (string, error) {
+	return uploader.loadFile.Location, nil
+}
+
+func (uploader *asyncJobUploader) GetSingleLoadFile(string) (warehouseutils.LoadFileT, error) {
+	return uploader.loadFile, nil
+}
+
+func (uploader *asyncJobUploader) GetLoadFileType() string {
+	return warehouseutils.GetLoadFileType(uploader.destType)
+}
+
+// asyncJobProgressT reports the progress of the job run by the slave, stopping it once the job got cancelled
+// or its deadline passed
+type asyncJobProgressT struct {
+	id       string
+	total    int64
+	deadline time.Time
+}
+
+func newAsyncJobProgress(asyncjob jobs.AsyncJobPayloadT, total int64) *asyncJobProgressT {
+	return &asyncJobProgressT{
+		id:       asyncjob.Id,
+		total:    total,
+		deadline: time.Now().Add(jobs.AsyncJobTimeout(asyncjob.AsyncJobType)),
+	}
+}
+
+func (progress *asyncJobProgressT) report(done int64) error {
+	if time.Now().After(progress.deadline) {
+		return jobs.ErrAsyncJobTimedOut
+	}
+	cancelled, err := jobs.IsAsyncJobCancelled(context.TODO(), dbHandle, progress.id)
+	if err != nil {
+		return err
+	}
+	if cancelled {
+		return jobs.ErrAsyncJobCancelled
+	}
+	return jobs.UpdateAsyncJobProgress(context.TODO(), dbHandle, progress.id, jobs.AsyncJobProgressT{Done: done, Total: progress.total})
+}
+
+// getAsyncJobParams returns the parameters of the backfill, identity resolution and rewrite jobs
+func getAsyncJobParams(asyncjob jobs.AsyncJobPayloadT) (jobs.AsyncJobParamsT, error) {
+	var metadata jobs.WhJobsMetaData
+	if err := json.Unmarshal(asyncjob.MetaData, &metadata); err != nil {
+		return jobs.AsyncJobParamsT{}, err
+	}
+	if metadata.Params == nil {
+		return jobs.AsyncJobParamsT{}, fmt.Errorf("missing params of async job: %s", asyncjob.Id)
+	}
+	return *metadata.Params, nil
+}
+
+/*
+ * Backfill column
+ */
+
+// backfillColumn backfills the column of the table with the property of the raw events the gateway backed up in the range.
+// The rows of the table are matched with the events on their message id. The progress is reported per backup file.
+func backfillColumn(asyncjob jobs.AsyncJobPayloadT, warehouse warehouseutils.Warehouse, whManager manager.WarehouseOperations) error {
+	if !misc.Contains(backfillColumnWarehouses, warehouse.Type) {
+		return fmt.Errorf("backfilling columns is not supported for %s", warehouse.Type)
+	}
+	params, err := getAsyncJobParams(asyncjob)
+	if err != nil {
+		return err
+	}
+	start, end, err := params.TimeRange()
+	if err != nil {
+		return err
+	}
+	tableName := warehouseutils.ToProviderCase(warehouse.Type, asyncjob.TableName)
+	columnName := warehouseutils.ToProviderCase(warehouse.Type, params.Column)
+
+	schema, err := whManager.FetchSchema(warehouse)
+	if err != nil {
+		return fmt.Errorf("fetching schema: %w", err)
+	}
+	tableSchema, ok := schema[tableName]
+	if !ok {
+		return fmt.Errorf("table %s not found in namespace %s", tableName, warehouse.Namespace)
+	}
+	if columnType, ok := tableSchema[columnName]; !ok {
+		if err = whManager.AddColumn(tableName, columnName, "string"); err != nil {
+			return fmt.Errorf("adding column %s: %w", columnName, err)
+		}
+	} else if columnType != "string" && columnType != "text" {
+		return fmt.Errorf("column %s of type %s cannot be backfilled, only string columns can", columnName, columnType)
+	}
+
+	fm, err := filemanager.DefaultFileManagerFactory.New(&filemanager.SettingsT{
+		Provider: config.GetString("JOBS_BACKUP_STORAGE_PROVIDER", "S3"),
+		Config:   filemanager.GetProviderConfigForBackupsFromEnv(context.TODO()),
+	})
+	if err != nil {
+		return fmt.Errorf("creating file manager for gateway backups: %w", err)
+	}
+	keys, err := getGatewayBackupsOfRun(context.TODO(), fm, asyncJobRunKey(asyncjob), start, end)
+	if err != nil {
+		return fmt.Errorf("listing gateway backups: %w", err)
+	}
+
+	client, err := whManager.Connect(warehouse)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	progress := newAsyncJobProgress(asyncjob, int64(len(keys)))
+	if err = progress.report(0); err != nil {
+		return err
+	}
+	for idx, key := range keys {
+		values, err := downloadBackfillValues(fm, key, asyncjob.SourceID, params.Property, start, end)
+		if err != nil {
+			return fmt.Errorf("reading gateway backup %s: %w", key, err)
+		}
+		for _, sqlStatement := range backfillColumnSQL(warehouse.Type, warehouse.Namespace, tableName, columnName, values, backfillBatchSize) {
+			if _, err = client.Query(sqlStatement); err != nil {
+				return fmt.Errorf("backfilling column %s of table %s: %w", columnName, tableName, err)
+			}
+		}
+		if err = progress.report(int64(idx + 1)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// asyncJobRunKey identifies the run which added the async job, along with the other jobs of the request
+func asyncJobRunKey(asyncjob jobs.AsyncJobPayloadT) string {
+	var metadata jobs.WhJobsMetaData
+	_ = json.Unmarshal(asyncjob.MetaData, &metadata)
+	return strings.Join([]string{asyncjob.SourceID, asyncjob.DestinationID, metadata.JobRunID, metadata.TaskRunID}, ":")
+}
+
+// getGatewayBackupsOfRun returns the keys of the gateway backups in the range, listing them only for the first job of the run.
+// Listings which failed are not kept, so that the next job lists the backups again.
+func getGatewayBackupsOfRun(ctx context.Context, fm filemanager.FileManager, runKey string, start, end time.Time) ([]string, error) {
+	listingKey := fmt.Sprintf("%s:%d:%d", runKey, start.UnixMilli(), end.UnixMilli())
+	gatewayBackupsListings.Lock()
+	for key, listing := range gatewayBackupsListings.byRun {
+		if listing.listed && time.Since(listing.listedAt) > gatewayBackupsListingTTL {
+			delete(gatewayBackupsListings.byRun, key)
+		}
+	}
+	listing, ok := gatewayBackupsListings.byRun[listingKey]
+	if !ok {
+		listing = &gatewayBackupsListingT{}
+		gatewayBackupsListings.byRun[listingKey] = listing
+	}
+	gatewayBackupsListings.Unlock()
+
+	listing.Lock()
+	defer listing.Unlock()
+	if !listing.listed {
+		keys, err := getGatewayBackupsInRange(ctx, fm, start, end)
+		if err != nil {
+			gatewayBackupsListings.Lock()
+			if gatewayBackupsListings.byRun[listingKey] == listing {
+				delete(gatewayBackupsListings.byRun, listingKey)
+			}
+			gatewayBackupsListings.Unlock()
+			return nil, err
+		}
+		listing.keys, listing.listed, listing.listedAt = keys, true, time.Now()
+	}
+	return listing.keys, nil
+}
+
+// getGatewayBackupsInRange returns the keys of the backups of the gateway jobs created in the range, in the order they were created.
+// Backups are named gw_jobs_<table_index>.<min_job_id>.<max_job_id>.<min_created_at>.<max_created_at>.gz, with times in ms.
+func getGatewayBackupsInRange(ctx context.Context, fm filemanager.FileManager, start, end time.Time) ([]string, error) {
+	type backupT struct {
+		key          string
+		minCreatedAt int64
+	}
+	var backups []backupT
+	iter := filemanager.IterateFilesWithPrefix(ctx, strings.TrimSpace(config.GetString("JOBS_BACKUP_PREFIX", "")), "", 1000, &fm)
+	for iter.Next() {
+		key := iter.Get().Key
+		minCreatedAt, maxCreatedAt, ok := parseGatewayBackupKey(key)
+		if !ok {
+			continue
+		}
+		if maxCreatedAt >= start.UnixMilli() && minCreatedAt < end.UnixMilli() {
+			backups = append(backups, backupT{key: key, minCreatedAt: minCreatedAt})
+		}
+	}
+	if iter.Err() != nil {
+		return nil, iter.Err()
+	}
+	sort.SliceStable(backups, func(i, j int) bool {
+		return backups[i].minCreatedAt < backups[j].minCreatedAt
+	})
+	keys := make([]string, 0, len(backups))
+	for _, backup := range backups {
+		keys = append(keys, backup.key)
+	}
+	return keys, nil
+}
+
+// parseGatewayBackupKey returns the range of the creation times of the jobs in the gateway backup
+func parseGatewayBackupKey(key string) (minCreatedAt, maxCreatedAt int64, ok bool) {
+	name := path.Base(key)
+	if !strings.HasPrefix(name, "gw_jobs_") {
+		return 0, 0, false
+	}
+	tokens := strings.Split(strings.TrimPrefix(name, "gw_jobs_"), ".")
+	if len(tokens) != 6 || tokens[5] != "gz" {
+		return 0, 0, false
+	}
+	var err error
+	if minCreatedAt, err = strconv.ParseInt(tokens[3], 10, 64); err != nil {
+		return 0, 0, false
+	}
+	if maxCreatedAt, err = strconv.ParseInt(tokens[4], 10, 64); err != nil {
+		return 0, 0, false
+	}
+	return minCreatedAt, maxCreatedAt, true
+}
+
+func downloadBackfillValues(fm filemanager.FileManager, key, sourceID, property string, start, end time.Time) (map[string]string, error) {
+	tmpDirPath, err := misc.CreateTMPDIR()
+	if err != nil {
+		return nil, err
+	}
+	filePath := fmt.Sprintf(`%s/%s/%s`, tmpDirPath, gatewayBackupsTmpDirName, path.Base(key))
+	if err = os.MkdirAll(filepath.Dir(filePath), os.ModePerm); err != nil {
+		return nil, err
+	}
+	defer misc.RemoveFilePaths(filePath)
+	file, err := os.Create(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if err = fm.Download(context.TODO(), file, key); err != nil {
+		return nil, err
+	}
+	if _, err = file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, err
+	}
+	defer gzipReader.Close()
+	return getBackfillValues(gzipReader, sourceID, property, start, end)
+}
+
+// getBackfillValues returns the values of the property by message id of the events of the source in the gateway backup,
+// which were received in the range. Events without the property are skipped.
+func getBackfillValues(r io.Reader, sourceID, property string, start, end time.Time) (map[string]string, error) {
+	values := make(map[string]string)
+	sc := bufio.NewScanner(r)
+	maxCapacity := maxStagingFileReadBufferCapacityInK * 1024
+	sc.Buffer(make([]byte, maxCapacity), maxCapacity)
+	for sc.Scan() {
+		line := sc.Bytes()
+		if gjson.GetBytes(line, "parameters.source_id").String() != sourceID {
+			continue
+		}
+		createdAt, err := time.Parse(misc.POSTGRESTIMEFORMATPARSE, gjson.GetBytes(line, "created_at").String())
+		if err != nil || createdAt.Before(start) || !createdAt.Before(end) {
+			continue
+		}
+		gjson.GetBytes(line, "event_payload.batch").ForEach(func(_, event gjson.Result) bool {
+			messageID := event.Get("messageId").String()
+			value := event.Get(property)
+			if messageID != "" && value.Exists() && value.Type != gjson.Null {
+				values[messageID] = value.String()
+			}
+			return true
+		})
+	}
+	return values, sc.Err()
+}
+
+// backfillColumnSQL returns the statements updating the column of the rows by id in batches
+func backfillColumnSQL(provider, namespace, tableName, columnName string, values map[string]string, batchSize int) []string {
+	ids := make([]string, 0, len(values))
+	for id := range values {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	idColumn := warehouseutils.ToProviderCase(provider, "id")
+	var sqlStatements []string
+	for i := 0; i < len(ids); i += batchSize {
+		batch := ids[i:misc.MinInt(i+batchSize, len(ids))]
+		var cases, quotedIDs []string
+		for _, id := range batch {
+			cases = append(cases, fmt.Sprintf(`WHEN %s THEN %s`, quoteSQLLiteral(provider, id), quoteSQLLiteral(provider, values[id])))
+			quotedIDs = append(quotedIDs, quoteSQLLiteral(provider, id))
+		}
+		sqlStatements = append(sqlStatements, fmt.Sprintf(`UPDATE %q.%q SET %q = CASE %q %s END WHERE %q IN (%s)`,
+			namespace,
+			tableName,
+			columnName,
+			idColumn,
+			strings.Join(cases, " "),
+			idColumn,
+			strings.Join(quotedIDs, ", "),
+		))
+	}
+	return sqlStatements
+}
+
+// quoteSQLLiteral quotes the string literal for the SQL of the warehouse
+func quoteSQLLiteral(provider, value string) string {
+	switch provider {
+	case warehouseutils.RS, warehouseutils.SNOWFLAKE:
+		// backslashes are escape characters in the string literals of redshift and snowflake
+		value = strings.ReplaceAll(value, `\`, `\\`)
+	}
+	value = strings.ReplaceAll(value, `'`, `''`)
+	switch provider {
+	case warehouseutils.MSSQL, warehouseutils.AZURE_SYNAPSE:
+		return fmt.Sprintf(`N'%s'`, value)
+	default:
+		return fmt.Sprintf(`'%s'`, value)
+	}
+}
+
+/*
+ * Identity resolution
+ */
+
+// resolveIdentitiesInRange re-applies the merge rules added in the range and loads the changed mappings into the warehouse.
+// The progress is reported in merge rules.
+func resolveIdentitiesInRange(asyncjob jobs.AsyncJobPayloadT, warehouse warehouseutils.Warehouse, whManager manager.WarehouseOperations, uploader *asyncJobUploader) error {
+	if !misc.Contains(warehouseutils.IdentityEnabledWarehouses, warehouse.Type) {
+		return fmt.Errorf("identity resolution is not supported for %s", warehouse.Type)
+	}
+	params, err := getAsyncJobParams(asyncjob)
+	if err != nil {
+		return err
+	}
+	start, end, err := params.TimeRange()
+	if err != nil {
+		return err
+	}
+
+	progress := newAsyncJobProgress(asyncjob, 0)
+	idr := identity.HandleT{
+		Warehouse:        warehouse,
+		DbHandle:         dbHandle,
+		Uploader:         uploader,
+		WarehouseManager: whManager,
+	}
+	loadFile, err := idr.ResolveRange(start, end, func(applied, total int) error {
+		progress.total = int64(total)
+		return progress.report(int64(applied))
+	})
+	if err != nil {
+		return err
+	}
+	if loadFile.Location == "" {
+		return nil
+	}
+
+	if uploader.schemaInWarehouse, err = whManager.FetchSchema(warehouse); err != nil {
+		return fmt.Errorf("fetching schema: %w", err)
+	}
+	uploader.loadFile = loadFile
+	return whManager.LoadIdentityMappingsTable()
+}
+
+/*
+ * Rewrite table
+ */
+
+// tableRewriteChangeT is a column added to the table or whose type is changed to rewrite it to the new schema
+type tableRewriteChangeT struct {
+	column   string
+	dataType string
+	add      bool
+}
+
+// rewriteTable rewrites the table to the new schema, adding the missing columns and changing the type of the others.
+// Columns missing from the new schema are kept. The progress is reported in columns.
+func rewriteTable(asyncjob jobs.AsyncJobPayloadT, warehouse warehouseutils.Warehouse, whManager manager.WarehouseOperations, uploader *asyncJobUploader) error {
+	if !misc.Contains(warehouseutils.ColumnTypeEvolutionWarehouses, warehouse.Type) {
+		return fmt.Errorf("rewriting tables is not supported for %s", warehouse.Type)
+	}
+	params, err := getAsyncJobParams(asyncjob)
+	if err != nil {
+		return err
+	}
+	tableName := warehouseutils.ToProviderCase(warehouse.Type, asyncjob.TableName)
+	newSchema := make(warehouseutils.TableSchemaT, len(params.Schema))
+	for column, dataType := range params.Schema {
+		newSchema[warehouseutils.ToProviderCase(warehouse.Type, column)] = dataType
+	}
+
+	schema, err := whManager.FetchSchema(warehouse)
+	if err != nil {
+		return fmt.Errorf("fetching schema: %w", err)
+	}
+	tableSchema, ok := schema[tableName]
+	if !ok {
+		return fmt.Errorf("table %s not found in namespace %s", tableName, warehouse.Namespace)
+	}
+	uploader.schemaInWarehouse = schema
+
+	changes := getTableRewriteChanges(tableSchema, newSchema)
+	progress := newAsyncJobProgress(asyncjob, int64(len(changes)))
+	for idx, change := range changes {
+		if err = progress.report(int64(idx)); err != nil {
+			return err
+		}
+		if change.add {
+			err = whManager.AddColumn(tableName, change.column, change.dataType)
+		} else {
+			_, err = whManager.AlterColumn(tableName, change.column, change.dataType)
+		}
+		if err != nil {
+			return fmt.Errorf("rewriting column %s of table %s to %s: %w", change.column, tableName, change.dataType, err)
+		}
+	}
+
+	// the warehouses ignore the type changes they do not support
+	if schema, err = whManager.FetchSchema(warehouse); err != nil {
+		return fmt.Errorf("fetching schema: %w", err)
+	}
+	for _, column := range warehouseutils.SortColumnKeysFromColumnMap(newSchema) {
+		if currentType, ok := schema[tableName][column]; !ok || !isSameDataType(currentType, newSchema[column]) {
+			return fmt.Errorf("column %s of table %s could not be rewritten to %s", column, tableName, newSchema[column])
+		}
+	}
+	return progress.report(int64(len(changes)))
+}
+
+// getTableRewriteChanges returns the changes rewriting the table to the new schema, ordered by column
+func getTableRewriteChanges(tableSchema, newSchema warehouseutils.TableSchemaT) []tableRewriteChangeT {
+	var changes []tableRewriteChangeT
+	for _, column := range warehouseutils.SortColumnKeysFromColumnMap(newSchema) {
+		dataType := newSchema[column]
+		currentType, ok := tableSchema[column]
+		switch {
+		case !ok:
+			changes = append(changes, tableRewriteChangeT{column: column, dataType: dataType, add: true})
+		case currentType != dataType:
+			changes = append(changes, tableRewriteChangeT{column: column, dataType: dataType})
+		}
+	}
+	return changes
+}
+
+// isSameDataType reports whether the column of the data type has the expected one, text being stored as string in most warehouses
+func isSameDataType(dataType, expectedDataType string) bool {
+	if expectedDataType == "text" {
+		return dataType == "text" || dataType == "string"
+	}
+	return dataType == expectedDataType
+}
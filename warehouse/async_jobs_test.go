@@ -0,0 +1,113 @@
+//go:build !warehouse_integration
+
+package warehouse
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	mock_filemanager "github.com/rudderlabs/rudder-server/mocks/services/filemanager"
+	"github.com/rudderlabs/rudder-server/services/filemanager"
+	warehouseutils "github.com/rudderlabs/rudder-server/warehouse/utils"
+)
+
+var _ = Describe("Async jobs", func() {
+	DescribeTable("Parse gateway backup key", func(key string, expectedMin, expectedMax int64, expectedOk bool) {
+		minCreatedAt, maxCreatedAt, ok := parseGatewayBackupKey(key)
+		Expect(ok).To(Equal(expectedOk))
+		Expect(minCreatedAt).To(Equal(expectedMin))
+		Expect(maxCreatedAt).To(Equal(expectedMax))
+	},
+		Entry("Gateway backup", "prefix/gw_jobs_9710.974705928.974806056.1604871241214.1604872598504.gz", int64(1604871241214), int64(1604872598504), true),
+		Entry("Gateway backup without prefix", "gw_jobs_1.1.2.100.200.gz", int64(100), int64(200), true),
+		Entry("Gateway job status backup", "prefix/gw_job_status_9710.974705928.974806056.1604871241214.1604872598504.gz", int64(0), int64(0), false),
+		Entry("Router backup", "prefix/rt_jobs_9710.974705928.974806056.1604871241214.1604872598504.gz", int64(0), int64(0), false),
+		Entry("Migrated backup", "prefix/gw_jobs_1_1.gz", int64(0), int64(0), false),
+		Entry("Invalid created at", "prefix/gw_jobs_1.1.2.abc.200.gz", int64(0), int64(0), false),
+	)
+
+	It("Should get the values of the property by message id", func() {
+		maxStagingFileReadBufferCapacityInK = 1024
+		start := time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC)
+		end := time.Date(2022, 10, 2, 0, 0, 0, 0, time.UTC)
+		backup := strings.Join([]string{
+			`{"created_at":"2022-10-01T10:00:00.123456","parameters":{"source_id":"test-sourceID"},"event_payload":{"batch":[{"messageId":"m1","context":{"campaign":{"name":"fall"}}},{"messageId":"m2","context":{}},{"messageId":"m3","context":{"campaign":{"name":null}}}]}}`,
+			`{"created_at":"2022-10-01T11:00:00","parameters":{"source_id":"test-sourceID"},"event_payload":{"batch":[{"messageId":"m4","context":{"campaign":{"name":{"a":1}}}}]}}`,
+			`{"created_at":"2022-10-01T12:00:00","parameters":{"source_id":"other-sourceID"},"event_payload":{"batch":[{"messageId":"m5","context":{"campaign":{"name":"winter"}}}]}}`,
+			`{"created_at":"2022-10-02T00:00:00","parameters":{"source_id":"test-sourceID"},"event_payload":{"batch":[{"messageId":"m6","context":{"campaign":{"name":"spring"}}}]}}`,
+		}, "\n")
+
+		values, err := getBackfillValues(strings.NewReader(backup), "test-sourceID", "context.campaign.name", start, end)
+		Expect(err).To(BeNil())
+		Expect(values).To(Equal(map[string]string{
+			"m1": "fall",
+			"m4": `{"a":1}`,
+		}))
+	})
+
+	It("Should list the gateway backups once per run", func() {
+		start := time.UnixMilli(1000)
+		end := time.UnixMilli(2000)
+		mockFileManager := mock_filemanager.NewMockFileManager(gomock.NewController(GinkgoT()))
+		mockFileManager.EXPECT().ListFilesWithPrefix(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return([]*filemanager.FileObject{
+			{Key: "prefix/gw_jobs_1.3.4.1500.1900.gz"},
+			{Key: "prefix/gw_jobs_1.1.2.500.1100.gz"},
+			{Key: "prefix/gw_jobs_1.5.6.2000.2100.gz"},
+		}, nil).Times(1)
+		mockFileManager.EXPECT().ListFilesWithPrefix(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil).Times(1)
+
+		for i := 0; i < 3; i++ {
+			keys, err := getGatewayBackupsOfRun(context.TODO(), mockFileManager, "test-run", start, end)
+			Expect(err).To(BeNil())
+			Expect(keys).To(Equal([]string{"prefix/gw_jobs_1.1.2.500.1100.gz", "prefix/gw_jobs_1.3.4.1500.1900.gz"}))
+		}
+	})
+
+	It("Should backfill the column by id in batches", func() {
+		values := map[string]string{"m1": "fall", "m2": "o'winter", "m3": "spring"}
+		Expect(backfillColumnSQL(warehouseutils.POSTGRES, "test_namespace", "tracks", "campaign_name", values, 2)).To(Equal([]string{
+			`UPDATE "test_namespace"."tracks" SET "campaign_name" = CASE "id" WHEN 'm1' THEN 'fall' WHEN 'm2' THEN 'o''winter' END WHERE "id" IN ('m1', 'm2')`,
+			`UPDATE "test_namespace"."tracks" SET "campaign_name" = CASE "id" WHEN 'm3' THEN 'spring' END WHERE "id" IN ('m3')`,
+		}))
+		Expect(backfillColumnSQL(warehouseutils.SNOWFLAKE, "TEST_NAMESPACE", "TRACKS", "CAMPAIGN_NAME", map[string]string{"m1": "fall"}, 2)).To(Equal([]string{
+			`UPDATE "TEST_NAMESPACE"."TRACKS" SET "CAMPAIGN_NAME" = CASE "ID" WHEN 'm1' THEN 'fall' END WHERE "ID" IN ('m1')`,
+		}))
+		Expect(backfillColumnSQL(warehouseutils.POSTGRES, "test_namespace", "tracks", "campaign_name", map[string]string{}, 2)).To(BeEmpty())
+	})
+
+	DescribeTable("Quote SQL literal", func(provider, value, expected string) {
+		Expect(quoteSQLLiteral(provider, value)).To(Equal(expected))
+	},
+		Entry("Postgres", warehouseutils.POSTGRES, `o'a\b`, `'o''a\b'`),
+		Entry("Redshift", warehouseutils.RS, `o'a\b`, `'o''a\\b'`),
+		Entry("Snowflake", warehouseutils.SNOWFLAKE, `o'a\b`, `'o''a\\b'`),
+		Entry("MSSQL", warehouseutils.MSSQL, `o'a\b`, `N'o''a\b'`),
+		Entry("Azure synapse", warehouseutils.AZURE_SYNAPSE, `o'a\b`, `N'o''a\b'`),
+	)
+
+	It("Should get the changes rewriting the table to the new schema", func() {
+		tableSchema := warehouseutils.TableSchemaT{"id": "string", "revenue": "string", "count": "int", "name": "string"}
+		newSchema := warehouseutils.TableSchemaT{"revenue": "float", "count": "int", "name": "text", "plan": "string"}
+		Expect(getTableRewriteChanges(tableSchema, newSchema)).To(Equal([]tableRewriteChangeT{
+			{column: "name", dataType: "text"},
+			{column: "plan", dataType: "string", add: true},
+			{column: "revenue", dataType: "float"},
+		}))
+		Expect(getTableRewriteChanges(newSchema, newSchema)).To(BeEmpty())
+	})
+
+	DescribeTable("Same data type", func(dataType, expectedDataType string, expected bool) {
+		Expect(isSameDataType(dataType, expectedDataType)).To(Equal(expected))
+	},
+		Entry("Same", "int", "int", true),
+		Entry("Different", "string", "int", false),
+		Entry("Text stored as string", "string", "text", true),
+		Entry("Text", "text", "text", true),
+		Entry("String is not text", "text", "string", false),
+	)
+})
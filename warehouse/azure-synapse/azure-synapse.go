@@ -36,6 +36,14 @@ const (
 	sslMode  = "sslMode"
 )
 
+var (
+	identityMergeRulesColumns = []string{"merge_property_1_type", "merge_property_1_value", "merge_property_2_type", "merge_property_2_value"}
+	identityMappingsColumns   = []string{"merge_property_type", "merge_property_value", "rudder_id", "updated_at"}
+)
+
+// identityStagingRowIDColumn orders the rows copied into the identity staging tables
+const identityStagingRowIDColumn = "_rudder_staging_row_id"
+
 const (
 	mssqlStringLengthLimit = 512
 	provider               = warehouseutils.AZURE_SYNAPSE
@@ -95,16 +103,11 @@ type credentialsT struct {
 	timeout  time.Duration
 }
 
-var primaryKeyMap = map[string]string{
-	warehouseutils.UsersTable:      "id",
-	warehouseutils.IdentifiesTable: "id",
-	warehouseutils.DiscardsTable:   "row_id",
-}
-
-var partitionKeyMap = map[string]string{
-	warehouseutils.UsersTable:      "id",
-	warehouseutils.IdentifiesTable: "id",
-	warehouseutils.DiscardsTable:   "row_id, column_name, table_name",
+// primaryKeyMap are the columns deduplicating the rows of the tables in the merge load mode, defaulting to id
+var primaryKeyMap = map[string][]string{
+	warehouseutils.UsersTable:      {"id"},
+	warehouseutils.IdentifiesTable: {"id"},
+	warehouseutils.DiscardsTable:   {"row_id", "column_name", "table_name"},
 }
 
 func connect(cred credentialsT) (*sql.DB, error) {
@@ -171,6 +174,10 @@ func (*HandleT) IsEmpty(_ warehouseutils.Warehouse) (empty bool, err error) {
 
 func (as *HandleT) DownloadLoadFiles(tableName string) ([]string, error) {
 	objects := as.Uploader.GetLoadFilesMetadata(warehouseutils.GetLoadFilesOptionsT{Table: tableName})
+	return as.downloadLoadFiles(tableName, objects)
+}
+
+func (as *HandleT) downloadLoadFiles(tableName string, objects []warehouseutils.LoadFileT) ([]string, error) {
 	storageProvider := warehouseutils.ObjectStorageType(as.Warehouse.Destination.DestinationDefinition.Name, as.Warehouse.Destination.Config, as.Uploader.UseRudderStorage())
 	downloader, err := filemanager.DefaultFileManagerFactory.New(&filemanager.SettingsT{
 		Provider: storageProvider,
@@ -373,25 +380,7 @@ func (as *HandleT) loadTable(tableName string, tableSchemaInUpload warehouseutil
 					}
 				case "string":
 					{
-						// This is needed to enable diacritic support Ex: Ü,ç Ç,©,∆,ß,á,ù,ñ,ê
-						// A substitute to this PR; https://github.com/denisenkom/go-mssqldb/pull/576/files
-						// An alternate to this approach is to use nvarchar(instead of varchar)
-						if len(strValue) > mssqlStringLengthLimit {
-							strValue = strValue[:mssqlStringLengthLimit]
-						}
-						var byteArr []byte
-						if hasDiacritics(strValue) {
-							pkgLogger.Debug("diacritics " + strValue)
-							byteArr = str2ucs2(strValue)
-							// This is needed as with above operation every character occupies 2 bytes
-							if len(byteArr) > mssqlStringLengthLimit {
-								byteArr = byteArr[:mssqlStringLengthLimit]
-							}
-							finalColumnValues = append(finalColumnValues, byteArr)
-						} else {
-							pkgLogger.Debug("non-diacritic : " + strValue)
-							finalColumnValues = append(finalColumnValues, strValue)
-						}
+						finalColumnValues = append(finalColumnValues, stringColumnValue(strValue))
 					}
 				default:
 					finalColumnValues = append(finalColumnValues, value)
@@ -422,28 +411,25 @@ func (as *HandleT) loadTable(tableName string, tableSchemaInUpload warehouseutil
 		return
 
 	}
-	// deduplication process
-	primaryKey := "id"
-	if column, ok := primaryKeyMap[tableName]; ok {
-		primaryKey = column
-	}
-	partitionKey := "id"
-	if column, ok := partitionKeyMap[tableName]; ok {
-		partitionKey = column
-	}
-	var additionalJoinClause string
-	if tableName == warehouseutils.DiscardsTable {
-		additionalJoinClause = fmt.Sprintf(`AND _source.%[3]s = "%[1]s"."%[2]s"."%[3]s" AND _source.%[4]s = "%[1]s"."%[2]s"."%[4]s"`, as.Namespace, tableName, "table_name", "column_name")
-	}
-	sqlStatement = fmt.Sprintf(`DELETE FROM "%[1]s"."%[2]s" FROM "%[1]s"."%[3]s" as  _source where (_source.%[4]s = "%[1]s"."%[2]s"."%[4]s" %[5]s)`, as.Namespace, tableName, stagingTableName, primaryKey, additionalJoinClause)
-	pkgLogger.Infof("AZ: Deduplicate records for table:%s using staging table: %s\n", tableName, sqlStatement)
-	_, err = txn.Exec(sqlStatement)
-	if err != nil {
-		pkgLogger.Errorf("AZ: Error deleting from original table for dedup: %v\n", err)
-		txn.Rollback()
-		return
+	if as.Warehouse.LoadMode(warehouseutils.LoadModeMerge) == warehouseutils.LoadModeAppend {
+		sqlStatement = fmt.Sprintf(`INSERT INTO "%[1]s"."%[2]s" (%[3]s) SELECT %[3]s FROM "%[1]s"."%[4]s"`, as.Namespace, tableName, sortedColumnString, stagingTableName)
+	} else {
+		// deduplication process
+		primaryKeys := as.primaryKeys(tableName)
+		joinClause := warehouseutils.JoinWithFormatting(primaryKeys, func(_ int, key string) string {
+			return fmt.Sprintf(`_source.%[3]q = "%[1]s"."%[2]s".%[3]q`, as.Namespace, tableName, key)
+		}, " AND ")
+		sqlStatement = fmt.Sprintf(`DELETE FROM "%[1]s"."%[2]s" FROM "%[1]s"."%[3]s" as  _source where (%[4]s)`, as.Namespace, tableName, stagingTableName, joinClause)
+		pkgLogger.Infof("AZ: Deduplicate records for table:%s using staging table: %s\n", tableName, sqlStatement)
+		_, err = txn.Exec(sqlStatement)
+		if err != nil {
+			pkgLogger.Errorf("AZ: Error deleting from original table for dedup: %v\n", err)
+			txn.Rollback()
+			return
+		}
+
+		sqlStatement = fmt.Sprintf(`INSERT INTO "%[1]s"."%[2]s" (%[3]s) SELECT %[3]s FROM ( SELECT *, row_number() OVER (PARTITION BY %[5]s ORDER BY received_at DESC) AS _rudder_staging_row_number FROM "%[1]s"."%[4]s" ) AS _ where _rudder_staging_row_number = 1`, as.Namespace, tableName, sortedColumnString, stagingTableName, warehouseutils.DoubleQuoteAndJoinByComma(primaryKeys))
 	}
-	sqlStatement = fmt.Sprintf(`INSERT INTO "%[1]s"."%[2]s" (%[3]s) SELECT %[3]s FROM ( SELECT *, row_number() OVER (PARTITION BY %[5]s ORDER BY received_at DESC) AS _rudder_staging_row_number FROM "%[1]s"."%[4]s" ) AS _ where _rudder_staging_row_number = 1`, as.Namespace, tableName, sortedColumnString, stagingTableName, partitionKey)
 	pkgLogger.Infof("AZ: Inserting records for table:%s using staging table: %s\n", tableName, sqlStatement)
 	_, err = txn.Exec(sqlStatement)
 
@@ -473,6 +459,27 @@ func str2ucs2(s string) []byte {
 	return ucs2
 }
 
+// stringColumnValue truncates strValue to the string length limit of the column.
+func stringColumnValue(strValue string) interface{} {
+	// This is needed to enable diacritic support Ex: Ü,ç Ç,©,∆,ß,á,ù,ñ,ê
+	// A substitute to this PR; https://github.com/denisenkom/go-mssqldb/pull/576/files
+	// An alternate to this approach is to use nvarchar(instead of varchar)
+	if len(strValue) > mssqlStringLengthLimit {
+		strValue = strValue[:mssqlStringLengthLimit]
+	}
+	if hasDiacritics(strValue) {
+		pkgLogger.Debug("diacritics " + strValue)
+		byteArr := str2ucs2(strValue)
+		// This is needed as with above operation every character occupies 2 bytes
+		if len(byteArr) > mssqlStringLengthLimit {
+			byteArr = byteArr[:mssqlStringLengthLimit]
+		}
+		return byteArr
+	}
+	pkgLogger.Debug("non-diacritic : " + strValue)
+	return strValue
+}
+
 func hasDiacritics(str string) bool {
 	for _, x := range str {
 		if utf8.RuneLen(x) > 1 {
@@ -603,6 +610,18 @@ func (*HandleT) DeleteBy([]string, warehouseutils.DeleteByParams) error {
 	return fmt.Errorf(warehouseutils.NotImplementedErrorCode)
 }
 
+// PruneTable deletes the rows of the table older than the retention, one window of time at a time
+func (as *HandleT) PruneTable(ctx context.Context, tableName string, params warehouseutils.RetentionParamsT) (warehouseutils.RetentionStatsT, error) {
+	pkgLogger.Infof("AZ: Pruning rows with %s before %s in table %s for AZ:%s", params.Column, params.Before, tableName, as.Warehouse.Destination.ID)
+	table := fmt.Sprintf(`"%s"."%s"`, as.Namespace, tableName)
+	batchedDelete := warehouseutils.BatchedDeleteT{
+		Db:          as.Db,
+		OldestQuery: fmt.Sprintf(`SELECT MIN(%q) FROM %s`, params.Column, table),
+		DeleteQuery: fmt.Sprintf(`DELETE FROM %[1]s WHERE %[2]q >= @p1 AND %[2]q < @p2`, table, params.Column),
+	}
+	return batchedDelete.Run(ctx, params)
+}
+
 func (as *HandleT) CreateSchema() (err error) {
 	sqlStatement := fmt.Sprintf(`IF NOT EXISTS ( SELECT  * FROM  sys.schemas WHERE   name = N'%s' )
     EXEC('CREATE SCHEMA [%s]');
@@ -658,7 +677,21 @@ func (as *HandleT) AddColumn(tableName, columnName, columnType string) (err erro
 	return err
 }
 
-func (*HandleT) AlterColumn(_, _, _ string) (err error) {
+// AlterColumn changes the type of the column in place, the existing values are converted implicitly to the new type
+func (as *HandleT) AlterColumn(tableName, columnName, columnType string) (response warehouseutils.AlterColumnResponseT, err error) {
+	dataType, ok := rudderDataTypesMapToMssql[columnType]
+	if !ok {
+		return
+	}
+	sqlStatement := fmt.Sprintf(`ALTER TABLE %[1]s ALTER COLUMN %[2]s %[3]s`, as.Namespace+"."+tableName, columnName, dataType)
+	pkgLogger.Infof("AZ: Altering column in synapse for AZ:%s : %v", as.Warehouse.Destination.ID, sqlStatement)
+	if _, err = as.Db.Exec(sqlStatement); err != nil {
+		return
+	}
+	response = warehouseutils.AlterColumnResponseT{
+		Strategy: warehouseutils.AlterColumnInPlace,
+		Queries:  []string{sqlStatement},
+	}
 	return
 }
 
@@ -694,6 +727,15 @@ func (as *HandleT) Setup(warehouse warehouseutils.Warehouse, uploader warehouseu
 	return err
 }
 
+// SetupDryRun sets up the warehouse like Setup, running statements on db instead of connecting to azure synapse
+func (as *HandleT) SetupDryRun(warehouse warehouseutils.Warehouse, uploader warehouseutils.UploaderI, db *sql.DB) {
+	as.Warehouse = warehouse
+	as.Namespace = warehouse.Namespace
+	as.Uploader = uploader
+	as.ObjectStorage = warehouseutils.ObjectStorageType(warehouseutils.AZURE_SYNAPSE, warehouse.Destination.Config, as.Uploader.UseRudderStorage())
+	as.Db = db
+}
+
 func (as *HandleT) CrashRecover(warehouse warehouseutils.Warehouse) (err error) {
 	as.Warehouse = warehouse
 	as.Namespace = warehouse.Namespace
@@ -806,6 +848,15 @@ func (as *HandleT) LoadUserTables() map[string]error {
 	return as.loadUserTables()
 }
 
+// primaryKeys returns the columns deduplicating the rows of tableName in the merge load mode
+func (as *HandleT) primaryKeys(tableName string) []string {
+	keys, ok := primaryKeyMap[tableName]
+	if !ok {
+		keys = []string{"id"}
+	}
+	return as.Warehouse.MergePrimaryKeys(tableName, keys)
+}
+
 func (as *HandleT) LoadTable(tableName string) error {
 	_, err := as.loadTable(tableName, as.Uploader.GetTableSchemaInUpload(tableName), false)
 	return err
@@ -819,15 +870,159 @@ func (as *HandleT) Cleanup() {
 	}
 }
 
-func (*HandleT) LoadIdentityMergeRulesTable() (err error) {
-	return
+func (as *HandleT) LoadIdentityMergeRulesTable() (err error) {
+	return as.loadIdentityTable(warehouseutils.IdentityMergeRulesTable, identityMergeRulesColumns, false)
+}
+
+func (as *HandleT) LoadIdentityMappingsTable() (err error) {
+	return as.loadIdentityTable(warehouseutils.IdentityMappingsTable, identityMappingsColumns, true)
 }
 
-func (*HandleT) LoadIdentityMappingsTable() (err error) {
+// loadIdentityTable loads the identity load file generated for the upload into tableName through a staging table.
+// Merge rules are appended, whereas mappings are upserted on merge property keeping the last mapping in the load file.
+func (as *HandleT) loadIdentityTable(tableName string, columns []string, upsert bool) (err error) {
+	pkgLogger.Infof("AZ: Starting load for identity table:%s", tableName)
+	loadFile, err := as.Uploader.GetSingleLoadFile(tableName)
+	if err != nil {
+		return
+	}
+	fileNames, err := as.downloadLoadFiles(tableName, []warehouseutils.LoadFileT{loadFile})
+	defer misc.RemoveFilePaths(fileNames...)
+	if err != nil {
+		return
+	}
+
+	stagingTableName := warehouseutils.StagingTableName(provider, tableName, tableNameLimit)
+	quotedColumnNames := warehouseutils.DoubleQuoteAndJoinByComma(columns)
+	sqlStatement := fmt.Sprintf(`select top 0 %[4]s, cast(0 as bigint) as %[5]s into %[1]s.%[2]s from %[1]s.%[3]s`, as.Namespace, stagingTableName, tableName, quotedColumnNames, identityStagingRowIDColumn)
+	pkgLogger.Infof("AZ: Creating staging table for identity table:%s at %s", tableName, sqlStatement)
+	if _, err = as.Db.Exec(sqlStatement); err != nil {
+		return
+	}
+	defer as.dropStagingTable(stagingTableName)
+
+	txn, err := as.Db.Begin()
+	if err != nil {
+		pkgLogger.Errorf("AZ: Error while beginning a transaction in db for loading in table:%s: %v", tableName, err)
+		return
+	}
+	defer func() {
+		if err != nil {
+			txn.Rollback()
+		}
+	}()
+
+	// the row id keeps the order of the rows in the load file
+	stmt, err := txn.Prepare(mssql.CopyIn(as.Namespace+"."+stagingTableName, mssql.BulkOptions{CheckConstraints: false}, append(columns, identityStagingRowIDColumn)...))
+	if err != nil {
+		return
+	}
+	var rowID int64
+	for _, fileName := range fileNames {
+		err = readLoadFile(fileName, len(columns), func(record []string) error {
+			rowID++
+			values := identityColumnValues(columns, record)
+			_, err := stmt.Exec(append(values, rowID)...)
+			return err
+		})
+		if err != nil {
+			pkgLogger.Errorf("AZ: Error loading file %s into staging table:%s: %v", fileName, stagingTableName, err)
+			return
+		}
+	}
+	if _, err = stmt.Exec(); err != nil {
+		return
+	}
+
+	if upsert {
+		sqlStatement = fmt.Sprintf(`DELETE FROM "%[1]s"."%[2]s" FROM "%[1]s"."%[3]s" AS _source WHERE _source.merge_property_type = "%[1]s"."%[2]s".merge_property_type AND _source.merge_property_value = "%[1]s"."%[2]s".merge_property_value`, as.Namespace, tableName, stagingTableName)
+		pkgLogger.Infof("AZ: Deduplicate records for identity table:%s using staging table: %s", tableName, sqlStatement)
+		if _, err = txn.Exec(sqlStatement); err != nil {
+			return
+		}
+		sqlStatement = fmt.Sprintf(`INSERT INTO "%[1]s"."%[2]s" (%[3]s) SELECT %[3]s FROM ( SELECT *, row_number() OVER (PARTITION BY merge_property_type, merge_property_value ORDER BY %[5]s DESC) AS _rudder_staging_row_number FROM "%[1]s"."%[4]s" ) AS _ WHERE _rudder_staging_row_number = 1`, as.Namespace, tableName, quotedColumnNames, stagingTableName, identityStagingRowIDColumn)
+	} else {
+		sqlStatement = fmt.Sprintf(`INSERT INTO "%[1]s"."%[2]s" (%[3]s) SELECT %[3]s FROM "%[1]s"."%[4]s"`, as.Namespace, tableName, quotedColumnNames, stagingTableName)
+	}
+	pkgLogger.Infof("AZ: Inserting records for identity table:%s using staging table: %s", tableName, sqlStatement)
+	if _, err = txn.Exec(sqlStatement); err != nil {
+		return
+	}
+
+	if err = txn.Commit(); err != nil {
+		return
+	}
+	pkgLogger.Infof("AZ: Complete load for identity table:%s", tableName)
 	return
 }
 
-func (*HandleT) DownloadIdentityRules(*misc.GZipWriter) (err error) {
+// identityColumnValues converts the csv record of an identity load file into the values of the columns.
+func identityColumnValues(columns, record []string) []interface{} {
+	values := make([]interface{}, len(record))
+	for i, value := range record {
+		if strings.TrimSpace(value) == "" {
+			continue
+		}
+		if columns[i] == "updated_at" {
+			if updatedAt, err := time.Parse(time.RFC3339, value); err == nil {
+				values[i] = updatedAt
+			}
+			continue
+		}
+		values[i] = stringColumnValue(value)
+	}
+	return values
+}
+
+// readLoadFile calls processRecord for every row of the gzipped csv load file.
+func readLoadFile(fileName string, columnCount int, processRecord func(record []string) error) error {
+	gzipFile, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer gzipFile.Close()
+	gzipReader, err := gzip.NewReader(gzipFile)
+	if err != nil {
+		return err
+	}
+	defer gzipReader.Close()
+
+	csvReader := csv.NewReader(gzipReader)
+	for {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(record) != columnCount {
+			return fmt.Errorf("load file CSV columns for a row mismatch: found %d, expected %d", len(record), columnCount)
+		}
+		if err = processRecord(record); err != nil {
+			return err
+		}
+	}
+}
+
+func (as *HandleT) DownloadIdentityRules(gzWriter *misc.GZipWriter) (err error) {
+	for _, tableName := range warehouseutils.IdentityRulesTables {
+		fields, ok := warehouseutils.IdentityRulesSelectFields(as.Uploader.GetTableSchemaInWarehouse(tableName))
+		if !ok {
+			pkgLogger.Infof("AZ: anonymous_id, user_id columns not present in table: %s", tableName)
+			continue
+		}
+
+		sqlStatement := fmt.Sprintf(`SELECT DISTINCT %s FROM %q.%q`, fields, as.Namespace, tableName)
+		pkgLogger.Infof("AZ: Downloading distinct combinations of anonymous_id, user_id: %s", sqlStatement)
+		var rows *sql.Rows
+		if rows, err = as.Db.Query(sqlStatement); err != nil {
+			return
+		}
+		if err = warehouseutils.WriteIdentityRules(rows, gzWriter); err != nil {
+			return
+		}
+	}
 	return
 }
 
@@ -63,6 +63,15 @@ var dataTypesMap = map[string]bigquery.FieldType{
 	"datetime": bigquery.TimestampFieldType,
 }
 
+// maps datatype stored in rudder to the name of the datatype in bigquery standard SQL
+var dataTypesMapToSQL = map[string]string{
+	"boolean":  "BOOL",
+	"int":      "INT64",
+	"float":    "FLOAT64",
+	"string":   "STRING",
+	"datetime": "TIMESTAMP",
+}
+
 // maps datatype in bigquery to datatype stored in rudder
 var dataTypesMapToRudder = map[bigquery.FieldType]string{
 	"BOOLEAN":   "boolean",
@@ -123,7 +132,7 @@ func (bq *HandleT) CreateTable(tableName string, columnMap map[string]string) (e
 		return
 	}
 
-	if !dedupEnabled() {
+	if !bq.dedupEnabled() {
 		err = bq.createTableView(tableName, columnMap)
 	}
 	return
@@ -134,7 +143,7 @@ func (bq *HandleT) DropTable(tableName string) (err error) {
 	if err != nil {
 		return
 	}
-	if !dedupEnabled() {
+	if !bq.dedupEnabled() {
 		err = bq.DeleteTable(tableName + "_view")
 	}
 	return
@@ -292,6 +301,92 @@ func (bq *HandleT) DeleteBy(tableNames []string, params warehouseutils.DeleteByP
 	return nil
 }
 
+// PruneTable drops the partitions of the table older than the retention if the table is partitioned on the retention column,
+// and deletes the rows otherwise.
+func (bq *HandleT) PruneTable(ctx context.Context, tableName string, params warehouseutils.RetentionParamsT) (warehouseutils.RetentionStatsT, error) {
+	pkgLogger.Infof("BQ: Pruning rows with %s before %s in table %s for BQ:%s", params.Column, params.Before, tableName, bq.warehouse.Destination.ID)
+	metadata, err := bq.db.Dataset(bq.namespace).Table(tableName).Metadata(ctx)
+	if err != nil {
+		return warehouseutils.RetentionStatsT{}, err
+	}
+	if partitionedOnColumn(metadata.TimePartitioning, params.Column) {
+		return bq.dropPartitions(ctx, tableName, params.Before)
+	}
+
+	stats := warehouseutils.RetentionStatsT{Method: warehouseutils.RetentionMethodBatchedDelete}
+	sqlStatement := fmt.Sprintf("DELETE FROM `%s.%s.%s` WHERE `%s` < @before", bq.projectID, bq.namespace, tableName, params.Column)
+	query := bq.db.Query(sqlStatement)
+	query.Parameters = []bigquery.QueryParameter{
+		{Name: "before", Value: params.Before},
+	}
+	job, err := query.Run(ctx)
+	if err != nil {
+		return stats, err
+	}
+	status, err := job.Wait(ctx)
+	if err != nil {
+		return stats, err
+	}
+	if status.Err() != nil {
+		return stats, status.Err()
+	}
+	if queryStats, ok := status.Statistics.Details.(*bigquery.QueryStatistics); ok {
+		stats.RowsDeleted = queryStats.NumDMLAffectedRows
+	}
+	return stats, nil
+}
+
+// partitionedOnColumn reports whether the daily or hourly partitions of the table hold the rows by the day of the column.
+// Tables created by Rudderstack are ingestion-time partitioned, and rows are loaded into the partition of the day they are
+// loaded in, which is never before the day they are received: their partitions are by received_at as far as retention goes.
+func partitionedOnColumn(partitioning *bigquery.TimePartitioning, column string) bool {
+	if partitioning == nil {
+		return false
+	}
+	switch partitioning.Type {
+	case "", bigquery.DayPartitioningType, bigquery.HourPartitioningType:
+	default:
+		return false
+	}
+	if partitioning.Field == "" {
+		return column == "received_at"
+	}
+	return partitioning.Field == column
+}
+
+// dropPartitions deletes the daily or hourly partitions of the table of the days before the given time
+func (bq *HandleT) dropPartitions(ctx context.Context, tableName string, before time.Time) (warehouseutils.RetentionStatsT, error) {
+	stats := warehouseutils.RetentionStatsT{Method: warehouseutils.RetentionMethodPartitionDrop}
+	sqlStatement := fmt.Sprintf("SELECT partition_id, total_rows FROM `%s.%s.INFORMATION_SCHEMA.PARTITIONS` WHERE table_name = @table AND partition_id < @cutoff AND partition_id NOT IN ('__NULL__', '__UNPARTITIONED__')", bq.projectID, bq.namespace)
+	query := bq.db.Query(sqlStatement)
+	query.Parameters = []bigquery.QueryParameter{
+		{Name: "table", Value: tableName},
+		{Name: "cutoff", Value: before.UTC().Format("20060102")},
+	}
+	it, err := query.Read(ctx)
+	if err != nil {
+		return stats, err
+	}
+	for {
+		var values []bigquery.Value
+		err := it.Next(&values)
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return stats, err
+		}
+		partitionID, _ := values[0].(string)
+		totalRows, _ := values[1].(int64)
+		if err := bq.db.Dataset(bq.namespace).Table(fmt.Sprintf(`%s$%s`, tableName, partitionID)).Delete(ctx); err != nil {
+			return stats, fmt.Errorf("dropping partition %s: %w", partitionID, err)
+		}
+		stats.PartitionsDropped++
+		stats.RowsDeleted += totalRows
+	}
+	return stats, nil
+}
+
 func partitionedTable(tableName, partitionDate string) string {
 	return fmt.Sprintf(`%s$%v`, tableName, strings.ReplaceAll(partitionDate, "-", ""))
 }
@@ -381,17 +476,12 @@ func (bq *HandleT) loadTable(tableName string, _, getLoadFileLocFromTableUploads
 			defer bq.dropStagingTable(stagingTableName)
 		}
 
-		primaryKey := "id"
-		if column, ok := primaryKeyMap[tableName]; ok {
-			primaryKey = column
-		}
-
-		partitionKey := "id"
-		if column, ok := partitionKeyMap[tableName]; ok {
-			partitionKey = column
+		tableColMap := bq.uploader.GetTableSchemaInWarehouse(tableName)
+		primaryKeys, partitionKeys, err := bq.mergeKeys(tableName, tableColMap)
+		if err != nil {
+			return err
 		}
 
-		tableColMap := bq.uploader.GetTableSchemaInWarehouse(tableName)
 		var tableColNames []string
 		for colName := range tableColMap {
 			tableColNames = append(tableColNames, fmt.Sprintf("`%s`", colName))
@@ -406,17 +496,22 @@ func (bq *HandleT) loadTable(tableName string, _, getLoadFileLocFromTableUploads
 		stagingColumnNames := strings.Join(stagingColumnNamesList, ",")
 		columnsWithValues := strings.Join(columnsWithValuesList, ",")
 
-		var primaryKeyList []string
-		for _, str := range strings.Split(primaryKey, ",") {
-			primaryKeyList = append(primaryKeyList, fmt.Sprintf(`original.%[1]s = staging.%[1]s`, strings.Trim(str, " ")))
+		var primaryKeyList, partitionKeyList []string
+		for _, column := range primaryKeys {
+			primaryKeyList = append(primaryKeyList, fmt.Sprintf("original.`%[1]s` = staging.`%[1]s`", column))
+		}
+		for _, column := range partitionKeys {
+			partitionKeyList = append(partitionKeyList, fmt.Sprintf("`%s`", column))
 		}
 		primaryJoinClause := strings.Join(primaryKeyList, " AND ")
+		partitionKey := strings.Join(partitionKeyList, ", ")
 		bqTable := func(name string) string { return fmt.Sprintf("`%s`.`%s`", bq.namespace, name) }
 
+		// rows received at the same time are ordered by their content, so that the same row is kept on every retry
 		sqlStatement := fmt.Sprintf(`MERGE INTO %[1]s AS original
 										USING (
-											SELECT * FROM (
-												SELECT *, row_number() OVER (PARTITION BY %[7]s ORDER BY RECEIVED_AT DESC) AS _rudder_staging_row_number FROM %[2]s
+											SELECT * EXCEPT (_rudder_staging_row_number) FROM (
+												SELECT *, row_number() OVER (PARTITION BY %[7]s ORDER BY RECEIVED_AT DESC, TO_JSON_STRING(s) DESC) AS _rudder_staging_row_number FROM %[2]s AS s
 											) AS q WHERE _rudder_staging_row_number = 1
 										) AS staging
 										ON (%[3]s)
@@ -444,7 +539,7 @@ func (bq *HandleT) loadTable(tableName string, _, getLoadFileLocFromTableUploads
 		return
 	}
 
-	if !dedupEnabled() {
+	if !bq.dedupEnabled() {
 		err = loadTableByAppend()
 		return
 	}
@@ -513,7 +608,7 @@ func (bq *HandleT) LoadUserTables() (errorMap map[string]error) {
 	bqIdentifiesTable := bqTable(warehouseutils.IdentifiesTable)
 	partition := fmt.Sprintf("TIMESTAMP('%s')", identifyLoadTable.partitionDate)
 	var identifiesFrom string
-	if dedupEnabled() {
+	if bq.dedupEnabled() {
 		identifiesFrom = fmt.Sprintf(`%s WHERE user_id IS NOT NULL %s`, bqTable(identifyLoadTable.stagingTableName), loadedAtFilter())
 	} else {
 		identifiesFrom = fmt.Sprintf(`%s WHERE _PARTITIONTIME = %s AND user_id IS NOT NULL %s`, bqIdentifiesTable, partition, loadedAtFilter())
@@ -633,7 +728,7 @@ func (bq *HandleT) LoadUserTables() (errorMap map[string]error) {
 		}
 	}
 
-	if !dedupEnabled() {
+	if !bq.dedupEnabled() {
 		loadUserTableByAppend()
 		return
 	}
@@ -680,15 +775,49 @@ func Init() {
 	pkgLogger = logger.NewLogger().Child("warehouse").Child("bigquery")
 }
 
-func dedupEnabled() bool {
-	return isDedupEnabled || isUsersTableDedupEnabled
+// dedupEnabled returns whether the tables are loaded in the merge load mode of the destination,
+// which defaults to merge if dedup is enabled for all the destinations.
+func (bq *HandleT) dedupEnabled() bool {
+	defaultMode := warehouseutils.LoadModeAppend
+	if isDedupEnabled || isUsersTableDedupEnabled {
+		defaultMode = warehouseutils.LoadModeMerge
+	}
+	return bq.warehouse.LoadMode(defaultMode) == warehouseutils.LoadModeMerge
 }
 
-func (bq *HandleT) CrashRecover(warehouse warehouseutils.Warehouse) (err error) {
-	if !dedupEnabled() {
+// mergeKeys returns the columns deduplicating the rows of tableName in the merge load mode, the primary keys matching
+// the rows of the staging table with the rows of the table and the partition keys deduplicating the rows of the staging table.
+// Configured primary keys have to be columns of the table.
+func (bq *HandleT) mergeKeys(tableName string, tableSchema warehouseutils.TableSchemaT) (primaryKeys, partitionKeys []string, err error) {
+	splitKeys := func(keys string) (columns []string) {
+		for _, column := range strings.Split(keys, ",") {
+			columns = append(columns, strings.TrimSpace(column))
+		}
 		return
 	}
+	primaryKeys, partitionKeys = []string{"id"}, []string{"id"}
+	if keys, ok := primaryKeyMap[tableName]; ok {
+		primaryKeys = splitKeys(keys)
+	}
+	if keys, ok := partitionKeyMap[tableName]; ok {
+		partitionKeys = splitKeys(keys)
+	}
+	if keys := bq.warehouse.MergePrimaryKeys(tableName, nil); len(keys) > 0 {
+		for _, column := range keys {
+			if _, ok := tableSchema[column]; !ok {
+				return nil, nil, fmt.Errorf("merge primary key %q of table %s is not a column of the table", column, tableName)
+			}
+		}
+		primaryKeys, partitionKeys = keys, keys
+	}
+	return
+}
+
+func (bq *HandleT) CrashRecover(warehouse warehouseutils.Warehouse) (err error) {
 	bq.warehouse = warehouse
+	if !bq.dedupEnabled() {
+		return
+	}
 	bq.namespace = warehouse.Namespace
 	bq.projectID = strings.TrimSpace(warehouseutils.GetConfigValue(GCPProjectID, bq.warehouse))
 	bq.db, err = bq.connect(BQCredentialsT{
@@ -837,10 +966,58 @@ func (bq *HandleT) AddColumn(tableName, columnName, columnType string) (err erro
 	return err
 }
 
-func (*HandleT) AlterColumn(_, _, _ string) (err error) {
+// AlterColumn changes the type of the column in place if bigquery can coerce the existing values to the new type,
+// i.e. from INT64 to FLOAT64. Otherwise, the column is swapped with a backfilled shadow column.
+func (bq *HandleT) AlterColumn(tableName, columnName, columnType string) (response warehouseutils.AlterColumnResponseT, err error) {
+	// text is stored as string in bigquery
+	dataType, ok := dataTypesMapToSQL[columnType]
+	if !ok {
+		return
+	}
+	tableIdentifier := fmt.Sprintf("`%s`.`%s`", bq.namespace, tableName)
+	currentType := bq.uploader.GetTableSchemaInWarehouse(tableName)[columnName]
+
+	var queries []string
+	if currentType == "int" && columnType == "float" {
+		response.Strategy = warehouseutils.AlterColumnInPlace
+		queries = []string{fmt.Sprintf("ALTER TABLE %s ALTER COLUMN `%s` SET DATA TYPE %s", tableIdentifier, columnName, dataType)}
+	} else {
+		response.Strategy = warehouseutils.AlterColumnShadowColumn
+		shadowColumnName := columnName + warehouseutils.ShadowColumnSuffix
+		backupColumnName := columnName + warehouseutils.BackupColumnSuffix
+		queries = warehouseutils.ShadowColumnQueries(
+			tableIdentifier,
+			fmt.Sprintf("`%s`", columnName),
+			fmt.Sprintf("`%s`", shadowColumnName),
+			fmt.Sprintf("`%s`", backupColumnName),
+			dataType,
+			fmt.Sprintf("CAST(`%s` AS %s)", columnName, dataType),
+			warehouseutils.GetShadowColumnState(bq.uploader.GetTableSchemaInWarehouse(tableName), columnName, shadowColumnName, backupColumnName),
+		)
+	}
+
+	for _, sqlStatement := range queries {
+		pkgLogger.Infof("BQ: Altering column in bigquery for BQ:%s : %v", bq.warehouse.Destination.ID, sqlStatement)
+		if err = bq.runQuery(sqlStatement); err != nil {
+			return warehouseutils.AlterColumnResponseT{}, err
+		}
+	}
+	response.Queries = queries
 	return
 }
 
+func (bq *HandleT) runQuery(sqlStatement string) error {
+	job, err := bq.db.Query(sqlStatement).Run(bq.backgroundContext)
+	if err != nil {
+		return err
+	}
+	status, err := job.Wait(bq.backgroundContext)
+	if err != nil {
+		return err
+	}
+	return status.Err()
+}
+
 // FetchSchema queries bigquery and returns the schema assoiciated with provided namespace
 func (bq *HandleT) FetchSchema(warehouse warehouseutils.Warehouse) (schema warehouseutils.SchemaT, err error) {
 	bq.warehouse = warehouse
@@ -45,8 +45,20 @@ var (
 	commitTimeOutInSeconds      time.Duration
 	loadTableFailureRetries     int
 	numWorkersDownloadLoadFiles int
+	optimizeTableInterval       time.Duration
 )
 
+// tablesOptimizedAt keeps the last time tables were optimized in merge mode, keyed by destination, namespace and table
+var (
+	tablesOptimizedAt     = make(map[string]time.Time)
+	tablesOptimizedAtLock sync.Mutex
+)
+
+// Primary Key mappings for tables deduplicated in merge mode, defaulting to id
+var primaryKeyMap = map[string][]string{
+	warehouseutils.DiscardsTable: {"row_id", "column_name", "table_name"},
+}
+
 var clickhouseDefaultDateTime, _ = time.Parse(time.RFC3339, "1970-01-01 00:00:00")
 
 const (
@@ -76,6 +88,21 @@ var rudderDataTypesMapToClickHouse = map[string]string{
 	"array(boolean)":  "Array(UInt8)",
 }
 
+var (
+	identityMergeRulesSchema = warehouseutils.TableSchemaT{
+		"merge_property_1_type":  "string",
+		"merge_property_1_value": "string",
+		"merge_property_2_type":  "string",
+		"merge_property_2_value": "string",
+	}
+	identityMappingsSchema = warehouseutils.TableSchemaT{
+		"merge_property_type":  "string",
+		"merge_property_value": "string",
+		"rudder_id":            "string",
+		"updated_at":           "datetime",
+	}
+)
+
 var clickhouseSpecificColumnNameMappings = map[string]string{
 	"event":      "LowCardinality(String)",
 	"event_text": "LowCardinality(String)",
@@ -248,6 +275,7 @@ func loadConfig() {
 	config.RegisterDurationConfigVariable(600, &commitTimeOutInSeconds, true, time.Second, "Warehouse.clickhouse.commitTimeOutInSeconds")
 	config.RegisterIntConfigVariable(3, &loadTableFailureRetries, true, 1, "Warehouse.clickhouse.loadTableFailureRetries")
 	config.RegisterIntConfigVariable(8, &numWorkersDownloadLoadFiles, true, 1, "Warehouse.clickhouse.numWorkersDownloadLoadFiles")
+	config.RegisterDurationConfigVariable(24, &optimizeTableInterval, true, time.Hour, "Warehouse.clickhouse.optimizeTableInterval")
 }
 
 /*
@@ -338,6 +366,10 @@ func (ch *HandleT) DownloadLoadFiles(tableName string) ([]string, error) {
 	pkgLogger.Infof("%s DownloadLoadFiles Started", ch.GetLogIdentifier(tableName))
 	defer pkgLogger.Infof("%s DownloadLoadFiles Completed", ch.GetLogIdentifier(tableName))
 	objects := ch.Uploader.GetLoadFilesMetadata(warehouseutils.GetLoadFilesOptionsT{Table: tableName})
+	return ch.downloadLoadFiles(tableName, objects)
+}
+
+func (ch *HandleT) downloadLoadFiles(tableName string, objects []warehouseutils.LoadFileT) ([]string, error) {
 	storageProvider := warehouseutils.ObjectStorageType(ch.Warehouse.Destination.DestinationDefinition.Name, ch.Warehouse.Destination.Config, ch.Uploader.UseRudderStorage())
 	downloader, err := filemanager.DefaultFileManagerFactory.New(&filemanager.SettingsT{
 		Provider: storageProvider,
@@ -384,6 +416,69 @@ func (*HandleT) DeleteBy([]string, warehouseutils.DeleteByParams) error {
 	return fmt.Errorf(warehouseutils.NotImplementedErrorCode)
 }
 
+// PruneTable drops the daily partitions of the table older than the retention, when the table is partitioned by the retention column.
+// Other tables fall back to a mutation deleting the rows.
+func (ch *HandleT) PruneTable(ctx context.Context, tableName string, params warehouseutils.RetentionParamsT) (stats warehouseutils.RetentionStatsT, err error) {
+	pkgLogger.Infof("CH: Pruning rows with %s before %s in table %s for CH:%s", params.Column, params.Before, tableName, ch.Warehouse.Destination.ID)
+	cluster := warehouseutils.GetConfigValue(Cluster, ch.Warehouse)
+	clusterClause := ""
+	if len(strings.TrimSpace(cluster)) > 0 {
+		clusterClause = fmt.Sprintf(`ON CLUSTER %q`, cluster)
+	}
+
+	var partitionKey string
+	err = ch.Db.QueryRowContext(ctx, `SELECT partition_key FROM system.tables WHERE database = ? AND name = ?`, ch.Namespace, tableName).Scan(&partitionKey)
+	if err != nil {
+		return stats, fmt.Errorf("fetching partition key: %w", err)
+	}
+
+	if partitionKey == fmt.Sprintf(`toDate(%s)`, params.Column) {
+		stats.Method = warehouseutils.RetentionMethodPartitionDrop
+		// max_date is only set for Date partition keys, the parts of DateTime columns record their range in max_time instead.
+		// A partition is dropped once all of its parts are older than the retention.
+		rows, err := ch.Db.QueryContext(ctx, `SELECT partition_id, sum(rows) FROM system.parts WHERE active AND database = ? AND table = ? GROUP BY partition_id HAVING max(max_time) < ?`, ch.Namespace, tableName, params.Before.UTC())
+		if err != nil {
+			return stats, fmt.Errorf("fetching partitions: %w", err)
+		}
+		defer rows.Close()
+		partitions := make(map[string]uint64)
+		for rows.Next() {
+			var partitionID string
+			var partitionRows uint64
+			if err := rows.Scan(&partitionID, &partitionRows); err != nil {
+				return stats, fmt.Errorf("fetching partitions: %w", err)
+			}
+			partitions[partitionID] = partitionRows
+		}
+		if err := rows.Err(); err != nil {
+			return stats, fmt.Errorf("fetching partitions: %w", err)
+		}
+		for partitionID, partitionRows := range partitions {
+			sqlStatement := fmt.Sprintf(`ALTER TABLE %q.%q %s DROP PARTITION ID '%s'`, ch.Namespace, tableName, clusterClause, partitionID)
+			if _, err := ch.Db.ExecContext(ctx, sqlStatement); err != nil {
+				return stats, fmt.Errorf("dropping partition %s: %w", partitionID, err)
+			}
+			stats.PartitionsDropped++
+			stats.RowsDeleted += int64(partitionRows)
+		}
+		return stats, nil
+	}
+
+	stats.Method = warehouseutils.RetentionMethodBatchedDelete
+	err = ch.Db.QueryRowContext(ctx, fmt.Sprintf(`SELECT count() FROM %q.%q WHERE %q < ?`, ch.Namespace, tableName, params.Column), params.Before.UTC()).Scan(&stats.RowsDeleted)
+	if err != nil {
+		return stats, fmt.Errorf("counting rows to delete: %w", err)
+	}
+	if stats.RowsDeleted == 0 {
+		return stats, nil
+	}
+	sqlStatement := fmt.Sprintf(`ALTER TABLE %q.%q %s DELETE WHERE %q < ?`, ch.Namespace, tableName, clusterClause, params.Column)
+	if _, err = ch.Db.ExecContext(ctx, sqlStatement, params.Before.UTC()); err != nil {
+		return stats, fmt.Errorf("deleting rows: %w", err)
+	}
+	return stats, nil
+}
+
 func (ch *HandleT) downloadLoadFile(object *warehouseutils.LoadFileT, tableName string, downloader filemanager.FileManager, storageProvider string) (fileName string, err error) {
 	pkgLogger.Debugf("%s DownloadLoadFile Started", ch.GetLogIdentifier(tableName, storageProvider))
 	defer pkgLogger.Debugf("%s DownloadLoadFile Completed", ch.GetLogIdentifier(tableName, storageProvider))
@@ -523,6 +618,12 @@ func typecastDataFromType(data, dataType string) interface{} {
 
 // loadTable loads table to clickhouse from the load files
 func (ch *HandleT) loadTable(tableName string, tableSchemaInUpload warehouseutils.TableSchemaT) (err error) {
+	objects := ch.Uploader.GetLoadFilesMetadata(warehouseutils.GetLoadFilesOptionsT{Table: tableName})
+	return ch.loadTableFromLoadFiles(tableName, tableSchemaInUpload, objects)
+}
+
+// loadTableFromLoadFiles loads table to clickhouse from the given load files
+func (ch *HandleT) loadTableFromLoadFiles(tableName string, tableSchemaInUpload warehouseutils.TableSchemaT, objects []warehouseutils.LoadFileT) (err error) {
 	pkgLogger.Infof("%s LoadTable Started", ch.GetLogIdentifier(tableName))
 	defer pkgLogger.Infof("%s LoadTable Completed", ch.GetLogIdentifier(tableName))
 
@@ -530,7 +631,7 @@ func (ch *HandleT) loadTable(tableName string, tableSchemaInUpload warehouseutil
 	chStats := ch.newClickHouseStat(tableName)
 
 	chStats.downloadLoadFilesTime.Start()
-	fileNames, err := ch.DownloadLoadFiles(tableName)
+	fileNames, err := ch.downloadLoadFiles(tableName, objects)
 	chStats.downloadLoadFilesTime.End()
 	if err != nil {
 		return
@@ -798,18 +899,39 @@ func (ch *HandleT) CreateTable(tableName string, columns map[string]string) (err
 	if strings.HasPrefix(tableName, warehouseutils.CTStagingTablePrefix) {
 		sortKeyFields = []string{"id"}
 	}
+	// in merge mode rows sharing the primary keys are replaced by the one received last
+	var versionColumn string
+	if ch.loadMode() == warehouseutils.LoadModeMerge && !strings.HasPrefix(tableName, warehouseutils.CTStagingTablePrefix) {
+		sortKeyFields = ch.primaryKeys(tableName)
+		versionColumn = partitionField
+	}
+	// identity merge rules are deduplicated on the rule, whereas mappings keep the latest rudder_id of a merge property
+	switch tableName {
+	case warehouseutils.IdentityMergeRulesTable:
+		sortKeyFields = warehouseutils.SortColumnKeysFromColumnMap(identityMergeRulesSchema)
+		versionColumn = ""
+	case warehouseutils.IdentityMappingsTable:
+		sortKeyFields = []string{"merge_property_type", "merge_property_value"}
+		versionColumn = "updated_at"
+	}
 	var sqlStatement string
 	if tableName == warehouseutils.UsersTable {
 		return ch.createUsersTable(tableName, columns)
 	}
 	clusterClause := ""
 	engine := "ReplacingMergeTree"
-	engineOptions := ""
+	var engineOptions []string
 	cluster := warehouseutils.GetConfigValue(Cluster, ch.Warehouse)
 	if len(strings.TrimSpace(cluster)) > 0 {
 		clusterClause = fmt.Sprintf(`ON CLUSTER %q`, cluster)
 		engine = fmt.Sprintf(`%s%s`, "Replicated", engine)
-		engineOptions = `'/clickhouse/{cluster}/tables/{database}/{table}', '{replica}'`
+		engineOptions = append(engineOptions, `'/clickhouse/{cluster}/tables/{database}/{table}', '{replica}'`)
+	}
+	// the version column of the engine cannot be nullable
+	notNullableColumns := append([]string{}, sortKeyFields...)
+	if versionColumn != "" {
+		engineOptions = append(engineOptions, fmt.Sprintf(`%q`, versionColumn))
+		notNullableColumns = append(notNullableColumns, versionColumn)
 	}
 	var orderByClause string
 	if len(sortKeyFields) > 0 {
@@ -821,7 +943,7 @@ func (ch *HandleT) CreateTable(tableName string, columns map[string]string) (err
 		partitionByClause = fmt.Sprintf(`PARTITION BY toDate(%s)`, partitionField)
 	}
 
-	sqlStatement = fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %q.%q %s ( %v ) ENGINE = %s(%s) %s %s`, ch.Namespace, tableName, clusterClause, ColumnsWithDataTypes(tableName, columns, sortKeyFields), engine, engineOptions, orderByClause, partitionByClause)
+	sqlStatement = fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %q.%q %s ( %v ) ENGINE = %s(%s) %s %s`, ch.Namespace, tableName, clusterClause, ColumnsWithDataTypes(tableName, columns, notNullableColumns), engine, strings.Join(engineOptions, ", "), orderByClause, partitionByClause)
 
 	pkgLogger.Infof("CH: Creating table in clickhouse for ch:%s : %v", ch.Warehouse.Destination.ID, sqlStatement)
 	_, err = ch.Db.Exec(sqlStatement)
@@ -860,7 +982,27 @@ func (ch *HandleT) CreateSchema() (err error) {
 	return err
 }
 
-func (*HandleT) AlterColumn(_, _, _ string) (err error) {
+// AlterColumn modifies the type of the column in place, clickhouse converts the existing values to the new type
+func (ch *HandleT) AlterColumn(tableName, columnName, columnType string) (response warehouseutils.AlterColumnResponseT, err error) {
+	// text is stored as String in clickhouse same as string
+	dataType, ok := rudderDataTypesMapToClickHouse[columnType]
+	if !ok {
+		return
+	}
+	cluster := warehouseutils.GetConfigValue(Cluster, ch.Warehouse)
+	clusterClause := ""
+	if len(strings.TrimSpace(cluster)) > 0 {
+		clusterClause = fmt.Sprintf(`ON CLUSTER %q`, cluster)
+	}
+	sqlStatement := fmt.Sprintf(`ALTER TABLE %q.%q %s MODIFY COLUMN %q %s`, ch.Namespace, tableName, clusterClause, columnName, getClickHouseColumnTypeForSpecificTable(tableName, columnName, dataType, false))
+	pkgLogger.Infof("CH: Altering column in clickhouse for ch:%s : %v", ch.Warehouse.Destination.ID, sqlStatement)
+	if _, err = ch.Db.Exec(sqlStatement); err != nil {
+		return
+	}
+	response = warehouseutils.AlterColumnResponseT{
+		Strategy: warehouseutils.AlterColumnInPlace,
+		Queries:  []string{sqlStatement},
+	}
 	return
 }
 
@@ -898,6 +1040,16 @@ func (ch *HandleT) Setup(warehouse warehouseutils.Warehouse, uploader warehouseu
 	return err
 }
 
+// SetupDryRun sets up the warehouse like Setup, running statements on db instead of connecting to clickhouse
+func (ch *HandleT) SetupDryRun(warehouse warehouseutils.Warehouse, uploader warehouseutils.UploaderI, db *sql.DB) {
+	ch.Warehouse = warehouse
+	ch.Namespace = warehouse.Namespace
+	ch.Uploader = uploader
+	ch.stats = stats.Default
+	ch.ObjectStorage = warehouseutils.ObjectStorageType(warehouseutils.CLICKHOUSE, warehouse.Destination.Config, ch.Uploader.UseRudderStorage())
+	ch.Db = db
+}
+
 func (*HandleT) CrashRecover(_ warehouseutils.Warehouse) (err error) {
 	return
 }
@@ -970,9 +1122,97 @@ func (ch *HandleT) LoadUserTables() (errorMap map[string]error) {
 	return
 }
 
+// LoadTable inserts the load files of the upload into tableName. In merge mode rows sharing the primary keys are replaced
+// by the background merges of the ReplacingMergeTree engine, which are forced at most once every optimizeTableInterval.
 func (ch *HandleT) LoadTable(tableName string) error {
-	err := ch.loadTable(tableName, ch.Uploader.GetTableSchemaInUpload(tableName))
-	return err
+	if ch.loadMode() != warehouseutils.LoadModeMerge {
+		return ch.loadTable(tableName, ch.Uploader.GetTableSchemaInUpload(tableName))
+	}
+	replacing, err := ch.isReplacingTable(tableName)
+	if err != nil {
+		return err
+	}
+	if err = ch.loadTable(tableName, ch.Uploader.GetTableSchemaInUpload(tableName)); err != nil || !replacing {
+		return err
+	}
+	return ch.optimizeTableIfDue(tableName)
+}
+
+// isReplacingTable returns whether the rows of tableName sharing the primary keys are replaced, i.e. whether the table was
+// created with the ReplacingMergeTree engine sorted by the primary keys. Tables created before merge mode was enabled
+// keep appending rows, since the engine of a table cannot be changed, which is warned about.
+func (ch *HandleT) isReplacingTable(tableName string) (bool, error) {
+	switch tableName {
+	case warehouseutils.UsersTable, warehouseutils.IdentityMergeRulesTable, warehouseutils.IdentityMappingsTable:
+		// users and identity tables are deduplicated in every mode
+		return true, nil
+	}
+	var engine, sortingKey string
+	sqlStatement := `SELECT engine, sorting_key FROM system.tables WHERE database = ? AND name = ?`
+	if err := ch.Db.QueryRow(sqlStatement, ch.Namespace, tableName).Scan(&engine, &sortingKey); err != nil {
+		return false, fmt.Errorf("%s Error getting the engine of the table: %w", ch.GetLogIdentifier(tableName), err)
+	}
+	primaryKeys := ch.primaryKeys(tableName)
+	sortingKeys := strings.Split(sortingKey, ",")
+	for i := range sortingKeys {
+		sortingKeys[i] = strings.Trim(strings.TrimSpace(sortingKeys[i]), "`\"")
+	}
+	if strings.HasSuffix(engine, "ReplacingMergeTree") && strings.Join(sortingKeys, ",") == strings.Join(primaryKeys, ",") {
+		return true, nil
+	}
+	pkgLogger.Warnf("%s Table has engine %s sorted by (%s) instead of ReplacingMergeTree sorted by (%s), as it was created before merge load mode was enabled: rows are appended. Recreate the table to deduplicate its rows.",
+		ch.GetLogIdentifier(tableName), engine, sortingKey, strings.Join(primaryKeys, ", "))
+	return false, nil
+}
+
+// loadMode returns the load mode of the destination, defaulting to append
+func (ch *HandleT) loadMode() string {
+	return ch.Warehouse.LoadMode(warehouseutils.LoadModeAppend)
+}
+
+// primaryKeys returns the sort keys deduplicating the rows of tableName in merge mode
+func (ch *HandleT) primaryKeys(tableName string) []string {
+	keys, ok := primaryKeyMap[tableName]
+	if !ok {
+		keys = []string{"id"}
+	}
+	return ch.Warehouse.MergePrimaryKeys(tableName, keys)
+}
+
+// optimizeTableIfDue optimizes tableName if it was not optimized in the last optimizeTableInterval,
+// as optimizing rewrites every part of the table
+func (ch *HandleT) optimizeTableIfDue(tableName string) error {
+	key := fmt.Sprintf("%s::%s::%s", ch.Warehouse.Destination.ID, ch.Namespace, tableName)
+	tablesOptimizedAtLock.Lock()
+	optimizedAt, ok := tablesOptimizedAt[key]
+	tablesOptimizedAtLock.Unlock()
+	if ok && time.Since(optimizedAt) < optimizeTableInterval {
+		return nil
+	}
+	if err := ch.optimizeTable(tableName); err != nil {
+		return err
+	}
+	tablesOptimizedAtLock.Lock()
+	tablesOptimizedAt[key] = time.Now()
+	tablesOptimizedAtLock.Unlock()
+	return nil
+}
+
+// optimizeTable forces the ReplacingMergeTree engine to merge the parts of tableName,
+// so that the rows sharing the primary keys are replaced without waiting for the background merges.
+func (ch *HandleT) optimizeTable(tableName string) (err error) {
+	cluster := warehouseutils.GetConfigValue(Cluster, ch.Warehouse)
+	clusterClause := ""
+	if len(strings.TrimSpace(cluster)) > 0 {
+		clusterClause = fmt.Sprintf(`ON CLUSTER %q`, cluster)
+	}
+	sqlStatement := fmt.Sprintf(`OPTIMIZE TABLE %q.%q %s FINAL`, ch.Namespace, tableName, clusterClause)
+	pkgLogger.Infof("%s Optimizing table: %s", ch.GetLogIdentifier(tableName), sqlStatement)
+	_, err = ch.Db.Exec(sqlStatement)
+	if err != nil {
+		err = fmt.Errorf("%s Error optimizing table with error: %w", ch.GetLogIdentifier(tableName), err)
+	}
+	return
 }
 
 func (ch *HandleT) Cleanup() {
@@ -981,15 +1221,42 @@ func (ch *HandleT) Cleanup() {
 	}
 }
 
-func (*HandleT) LoadIdentityMergeRulesTable() (err error) {
-	return
+func (ch *HandleT) LoadIdentityMergeRulesTable() (err error) {
+	return ch.loadIdentityTable(warehouseutils.IdentityMergeRulesTable, identityMergeRulesSchema)
 }
 
-func (*HandleT) LoadIdentityMappingsTable() (err error) {
-	return
+func (ch *HandleT) LoadIdentityMappingsTable() (err error) {
+	return ch.loadIdentityTable(warehouseutils.IdentityMappingsTable, identityMappingsSchema)
 }
 
-func (*HandleT) DownloadIdentityRules(*misc.GZipWriter) (err error) {
+// loadIdentityTable inserts the identity load file generated for the upload into tableName.
+// Duplicate merge rules and outdated mappings are replaced by the ReplacingMergeTree engine of the table.
+func (ch *HandleT) loadIdentityTable(tableName string, tableSchema warehouseutils.TableSchemaT) (err error) {
+	loadFile, err := ch.Uploader.GetSingleLoadFile(tableName)
+	if err != nil {
+		return
+	}
+	return ch.loadTableFromLoadFiles(tableName, tableSchema, []warehouseutils.LoadFileT{loadFile})
+}
+
+func (ch *HandleT) DownloadIdentityRules(gzWriter *misc.GZipWriter) (err error) {
+	for _, tableName := range warehouseutils.IdentityRulesTables {
+		fields, ok := warehouseutils.IdentityRulesSelectFields(ch.Uploader.GetTableSchemaInWarehouse(tableName))
+		if !ok {
+			pkgLogger.Infof("CH: anonymous_id, user_id columns not present in table: %s", tableName)
+			continue
+		}
+
+		sqlStatement := fmt.Sprintf(`SELECT DISTINCT %s FROM %q.%q`, fields, ch.Namespace, tableName)
+		pkgLogger.Infof("CH: Downloading distinct combinations of anonymous_id, user_id: %s", sqlStatement)
+		var rows *sql.Rows
+		if rows, err = ch.Db.Query(sqlStatement); err != nil {
+			return
+		}
+		if err = warehouseutils.WriteIdentityRules(rows, gzWriter); err != nil {
+			return
+		}
+	}
 	return
 }
 
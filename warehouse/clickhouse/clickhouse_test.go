@@ -3,12 +3,14 @@
 package clickhouse_test
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	backendconfig "github.com/rudderlabs/rudder-server/config/backend-config"
 	"github.com/rudderlabs/rudder-server/utils/timeutil"
@@ -178,6 +180,9 @@ func TestClickHouseIntegration(t *testing.T) {
 		testhelper.VerifyEventsInLoadFiles(t, warehouseTest, testhelper.LoadFilesEventsMap())
 		testhelper.VerifyEventsInTableUploads(t, warehouseTest, testhelper.TableUploadsEventsMap())
 		testhelper.VerifyEventsInWareHouse(t, warehouseTest, testhelper.WarehouseEventsMap())
+
+		// Scenario 3 (merge load mode, duplicate message ids)
+		testhelper.VerifyMergeLoadMode(t, warehouseTest, warehouseutils.CLICKHOUSE)
 	})
 
 	t.Run("Cluster Mode Setup", func(t *testing.T) {
@@ -233,6 +238,50 @@ func TestClickHouseIntegration(t *testing.T) {
 	})
 }
 
+func TestClickhousePruneTable(t *testing.T) {
+	tableName := "retention_partitions"
+	_, err := handle.DB.Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %q.%q (id String, received_at DateTime) ENGINE = MergeTree() PARTITION BY toDate(received_at) ORDER BY id`, handle.Schema, tableName))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_, _ = handle.DB.Exec(fmt.Sprintf(`DROP TABLE IF EXISTS %q.%q`, handle.Schema, tableName))
+	})
+
+	now := time.Now().UTC().Truncate(time.Second)
+	for id, receivedAt := range map[string]time.Time{"expired": now.Add(-10 * 24 * time.Hour), "recent": now.Add(-time.Hour)} {
+		txn, err := handle.DB.Begin()
+		require.NoError(t, err)
+		stmt, err := txn.Prepare(fmt.Sprintf(`INSERT INTO %q.%q (id, received_at) VALUES (?, ?)`, handle.Schema, tableName))
+		require.NoError(t, err)
+		_, err = stmt.Exec(id, receivedAt)
+		require.NoError(t, err)
+		require.NoError(t, stmt.Close())
+		require.NoError(t, txn.Commit())
+	}
+
+	ch := &clickhouse.HandleT{
+		Db:        handle.DB,
+		Namespace: handle.Schema,
+		Warehouse: warehouseutils.Warehouse{Destination: backendconfig.DestinationT{Config: map[string]interface{}{}}},
+	}
+	stats, err := ch.PruneTable(context.Background(), tableName, warehouseutils.RetentionParamsT{Column: "received_at", Before: now.Add(-5 * 24 * time.Hour)})
+	require.NoError(t, err)
+	require.Equal(t, warehouseutils.RetentionMethodPartitionDrop, stats.Method)
+	require.Equal(t, int64(1), stats.PartitionsDropped)
+	require.Equal(t, int64(1), stats.RowsDeleted)
+
+	var ids []string
+	rows, err := handle.DB.Query(fmt.Sprintf(`SELECT id FROM %q.%q`, handle.Schema, tableName))
+	require.NoError(t, err)
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		require.NoError(t, rows.Scan(&id))
+		ids = append(ids, id)
+	}
+	require.NoError(t, rows.Err())
+	require.Equal(t, []string{"recent"}, ids, "the partition newer than the retention is kept")
+}
+
 func TestClickhouseConfigurationValidation(t *testing.T) {
 	configurations := testhelper.PopulateTemplateConfigurations()
 	destination := backendconfig.DestinationT{
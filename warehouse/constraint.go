@@ -1,7 +1,12 @@
 package warehouse
 
 import (
+	"encoding/json"
 	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
 
 	"github.com/gofrs/uuid"
 	"github.com/iancoleman/strcase"
@@ -14,6 +19,30 @@ var (
 	enableConstraintsViolations bool
 )
 
+// policies applied to the values violating a constraint
+const (
+	ConstraintPolicyTruncate = "truncate" // truncate the value to the limit of the constraint
+	ConstraintPolicyNull     = "null"     // load null instead of the value
+	ConstraintPolicyDiscard  = "discard"  // load null instead of the value and route the value to rudder_discards
+	ConstraintPolicyFail     = "fail"     // fail generating the load files of the upload
+)
+
+// types of the constraints configurable on destinations
+const (
+	MaxLengthConstraint     = "max_length"
+	NotNullConstraint       = "not_null"
+	RegexConstraint         = "regex"
+	AllowedValuesConstraint = "allowed_values"
+	IndexConstraint         = "index"
+)
+
+const (
+	// ConstraintsField is the key of the constraints in the config of destinations
+	ConstraintsField = "constraints"
+	// ConstraintViolationsField is the key of the constraint violation counts in the metadata of uploads
+	ConstraintViolationsField = "constraintViolations"
+)
+
 type ConstraintsI interface {
 	violates(brEvent *BatchRouterEventT, columnName string) (cv *ConstraintsViolationT)
 }
@@ -21,6 +50,60 @@ type ConstraintsI interface {
 type ConstraintsViolationT struct {
 	IsViolated         bool
 	ViolatedIdentifier string
+	Constraint         string
+	Policy             string
+	// TruncatedValue is the value to load instead of the violating one with the truncate policy
+	TruncatedValue interface{}
+}
+
+// ConstraintConfigT is a constraint configured on a column of a destination table, like
+// {"table": "tracks", "column": "event", "type": "max_length", "maxLength": 64, "policy": "truncate"}
+type ConstraintConfigT struct {
+	Table         string   `json:"table"`
+	Column        string   `json:"column"`
+	Type          string   `json:"type"`
+	Policy        string   `json:"policy"`
+	MaxLength     int      `json:"maxLength,omitempty"`
+	Pattern       string   `json:"pattern,omitempty"`
+	AllowedValues []string `json:"allowedValues,omitempty"`
+}
+
+// columnConstraintT is the column a configured constraint applies to, matched case-insensitively as some destinations change the case of identifiers
+type columnConstraintT struct {
+	TableName  string
+	ColumnName string
+	Policy     string
+}
+
+func (cc *columnConstraintT) appliesTo(brEvent *BatchRouterEventT, columnName string) bool {
+	return strings.EqualFold(brEvent.Metadata.Table, cc.TableName) && strings.EqualFold(columnName, cc.ColumnName)
+}
+
+func (cc *columnConstraintT) violation(constraint string) *ConstraintsViolationT {
+	return &ConstraintsViolationT{
+		IsViolated: true,
+		Constraint: constraint,
+		Policy:     cc.Policy,
+	}
+}
+
+type MaxLengthConstraintT struct {
+	columnConstraintT
+	MaxLength int
+}
+
+type NotNullConstraintT struct {
+	columnConstraintT
+}
+
+type RegexConstraintT struct {
+	columnConstraintT
+	Pattern *regexp.Regexp
+}
+
+type AllowedValuesConstraintT struct {
+	columnConstraintT
+	AllowedValues map[string]struct{}
 }
 
 type IndexConstraintT struct {
@@ -64,19 +147,98 @@ func Init6() {
 	}
 }
 
-func ViolatedConstraints(destinationType string, brEvent *BatchRouterEventT, columnName string) (cv *ConstraintsViolationT) {
-	cv = &ConstraintsViolationT{}
-	if !enableConstraintsViolations {
+// ConfiguredConstraints returns the constraints configured in the config of a destination
+func ConfiguredConstraints(destConfig map[string]interface{}) (constraints []ConstraintsI, err error) {
+	rawConstraints, ok := destConfig[ConstraintsField]
+	if !ok || rawConstraints == nil {
 		return
 	}
-	constraints, ok := constraintsMap[destinationType]
-	if !ok {
+	constraintsJSON, err := json.Marshal(rawConstraints)
+	if err != nil {
+		return nil, fmt.Errorf("invalid constraints config: %w", err)
+	}
+	var constraintConfigs []ConstraintConfigT
+	if err = json.Unmarshal(constraintsJSON, &constraintConfigs); err != nil {
+		return nil, fmt.Errorf("invalid constraints config: %w", err)
+	}
+	for _, constraintConfig := range constraintConfigs {
+		constraint, err := newConstraint(constraintConfig)
+		if err != nil {
+			return nil, fmt.Errorf("invalid constraint %s on %s.%s: %w", constraintConfig.Type, constraintConfig.Table, constraintConfig.Column, err)
+		}
+		constraints = append(constraints, constraint)
+	}
+	return
+}
+
+func newConstraint(constraintConfig ConstraintConfigT) (ConstraintsI, error) {
+	if constraintConfig.Table == "" || constraintConfig.Column == "" {
+		return nil, fmt.Errorf("table and column are required")
+	}
+	policy := constraintConfig.Policy
+	if policy == "" {
+		policy = ConstraintPolicyDiscard
+	}
+	switch policy {
+	case ConstraintPolicyDiscard, ConstraintPolicyFail:
+	case ConstraintPolicyTruncate:
+		if constraintConfig.Type != MaxLengthConstraint {
+			return nil, fmt.Errorf("policy %s is only supported by %s constraints", policy, MaxLengthConstraint)
+		}
+	case ConstraintPolicyNull:
+		if constraintConfig.Type == NotNullConstraint {
+			return nil, fmt.Errorf("policy %s is not supported by %s constraints", policy, NotNullConstraint)
+		}
+	default:
+		return nil, fmt.Errorf("unknown policy %q", policy)
+	}
+	column := columnConstraintT{
+		TableName:  constraintConfig.Table,
+		ColumnName: constraintConfig.Column,
+		Policy:     policy,
+	}
+
+	switch constraintConfig.Type {
+	case MaxLengthConstraint:
+		if constraintConfig.MaxLength <= 0 {
+			return nil, fmt.Errorf("maxLength must be positive")
+		}
+		return &MaxLengthConstraintT{columnConstraintT: column, MaxLength: constraintConfig.MaxLength}, nil
+	case NotNullConstraint:
+		return &NotNullConstraintT{columnConstraintT: column}, nil
+	case RegexConstraint:
+		pattern, err := regexp.Compile(constraintConfig.Pattern)
+		if err != nil {
+			return nil, err
+		}
+		return &RegexConstraintT{columnConstraintT: column, Pattern: pattern}, nil
+	case AllowedValuesConstraint:
+		if len(constraintConfig.AllowedValues) == 0 {
+			return nil, fmt.Errorf("allowedValues are required")
+		}
+		allowedValues := make(map[string]struct{}, len(constraintConfig.AllowedValues))
+		for _, value := range constraintConfig.AllowedValues {
+			allowedValues[value] = struct{}{}
+		}
+		return &AllowedValuesConstraintT{columnConstraintT: column, AllowedValues: allowedValues}, nil
+	default:
+		return nil, fmt.Errorf("unknown constraint type %q", constraintConfig.Type)
+	}
+}
+
+// ViolatedConstraints returns the first constraint violated by the column of the event,
+// checking the constraints of the destination type before the ones configured on the destination
+func ViolatedConstraints(destinationType string, brEvent *BatchRouterEventT, columnName string, configured ...ConstraintsI) (cv *ConstraintsViolationT) {
+	cv = &ConstraintsViolationT{}
+	if !enableConstraintsViolations {
 		return
 	}
-	for _, constraint := range constraints {
-		cv = constraint.violates(brEvent, columnName)
-		if cv.IsViolated {
-			return
+	for _, constraints := range [][]ConstraintsI{constraintsMap[destinationType], configured} {
+		for _, constraint := range constraints {
+			cv = constraint.violates(brEvent, columnName)
+			if cv.IsViolated {
+				return
+			}
 		}
 	}
 	return
@@ -103,5 +265,132 @@ func (ic *IndexConstraintT) violates(brEvent *BatchRouterEventT, columnName stri
 	return &ConstraintsViolationT{
 		IsViolated:         concatenatedLength > ic.Limit,
 		ViolatedIdentifier: fmt.Sprintf(`%s-%s`, strcase.ToKebab(warehouseutils.DiscardsTable), uuid.Must(uuid.NewV4()).String()),
+		Constraint:         IndexConstraint,
+		Policy:             ConstraintPolicyDiscard,
+	}
+}
+
+func (mc *MaxLengthConstraintT) violates(brEvent *BatchRouterEventT, columnName string) (cv *ConstraintsViolationT) {
+	if !mc.appliesTo(brEvent, columnName) {
+		return &ConstraintsViolationT{}
+	}
+	columnVal, ok := brEvent.Data[columnName].(string)
+	if !ok || utf8.RuneCountInString(columnVal) <= mc.MaxLength {
+		return &ConstraintsViolationT{}
+	}
+	cv = mc.violation(MaxLengthConstraint)
+	cv.TruncatedValue = string([]rune(columnVal)[:mc.MaxLength])
+	return
+}
+
+func (nc *NotNullConstraintT) violates(brEvent *BatchRouterEventT, columnName string) (cv *ConstraintsViolationT) {
+	if !nc.appliesTo(brEvent, columnName) {
+		return &ConstraintsViolationT{}
+	}
+	if columnVal, ok := brEvent.Data[columnName]; ok && columnVal != nil {
+		return &ConstraintsViolationT{}
+	}
+	return nc.violation(NotNullConstraint)
+}
+
+func (rc *RegexConstraintT) violates(brEvent *BatchRouterEventT, columnName string) (cv *ConstraintsViolationT) {
+	if !rc.appliesTo(brEvent, columnName) {
+		return &ConstraintsViolationT{}
+	}
+	columnVal, ok := brEvent.Data[columnName]
+	if !ok || columnVal == nil || rc.Pattern.MatchString(fmt.Sprintf("%v", columnVal)) {
+		return &ConstraintsViolationT{}
+	}
+	return rc.violation(RegexConstraint)
+}
+
+func (ac *AllowedValuesConstraintT) violates(brEvent *BatchRouterEventT, columnName string) (cv *ConstraintsViolationT) {
+	if !ac.appliesTo(brEvent, columnName) {
+		return &ConstraintsViolationT{}
+	}
+	columnVal, ok := brEvent.Data[columnName]
+	if !ok || columnVal == nil {
+		return &ConstraintsViolationT{}
+	}
+	if _, ok := ac.AllowedValues[fmt.Sprintf("%v", columnVal)]; ok {
+		return &ConstraintsViolationT{}
+	}
+	return ac.violation(AllowedValuesConstraint)
+}
+
+// constraintViolationsT counts the constraint violations of the load files generated for an upload, by table, column and constraint
+type constraintViolationsT struct {
+	lock   sync.Mutex
+	counts map[string]map[string]map[string]int
+}
+
+func (cv *constraintViolationsT) add(loadFiles []loadFileUploadOutputT) {
+	cv.lock.Lock()
+	defer cv.lock.Unlock()
+	for _, loadFile := range loadFiles {
+		cv.counts = addConstraintViolations(cv.counts, map[string]map[string]map[string]int{loadFile.TableName: loadFile.ConstraintViolations})
+	}
+}
+
+func addConstraintViolations(counts, added map[string]map[string]map[string]int) map[string]map[string]map[string]int {
+	for tableName, columns := range added {
+		for columnName, constraints := range columns {
+			for constraint, count := range constraints {
+				if counts == nil {
+					counts = make(map[string]map[string]map[string]int)
+				}
+				if counts[tableName] == nil {
+					counts[tableName] = make(map[string]map[string]int)
+				}
+				if counts[tableName][columnName] == nil {
+					counts[tableName][columnName] = make(map[string]int)
+				}
+				counts[tableName][columnName][constraint] += count
+			}
+		}
+	}
+	return counts
+}
+
+// recordConstraintViolations records the constraint violations counted while generating load files in the metadata of the upload.
+// The counts of the staging files processed earlier are kept when only some of the staging files were processed again.
+func (job *UploadJobT) recordConstraintViolations(counts map[string]map[string]map[string]int, keepRecorded bool) error {
+	job.uploadLock.Lock()
+	defer job.uploadLock.Unlock()
+
+	var metadata map[string]json.RawMessage
+	if err := json.Unmarshal(job.upload.Metadata, &metadata); err != nil {
+		metadata = make(map[string]json.RawMessage)
+	}
+	if keepRecorded {
+		var recorded map[string]map[string]map[string]int
+		if rawRecorded, ok := metadata[ConstraintViolationsField]; ok {
+			_ = json.Unmarshal(rawRecorded, &recorded)
+		}
+		counts = addConstraintViolations(recorded, counts)
+	}
+	if len(counts) == 0 {
+		return nil
+	}
+	countsJSON, err := json.Marshal(counts)
+	if err != nil {
+		return err
 	}
+	sqlStatement := fmt.Sprintf(`
+		UPDATE 
+		  %[1]s 
+		SET 
+		  metadata = jsonb_set(
+		    COALESCE(metadata, '{}' :: jsonb), 
+		    '{%[2]s}', 
+		    $1 :: jsonb
+		  ) 
+		WHERE 
+		  id = $2 RETURNING metadata;
+`,
+		warehouseutils.WarehouseUploadsTable,
+		ConstraintViolationsField,
+	)
+	// keeping the metadata of the upload in sync, as it is written back on failures
+	return job.dbHandle.QueryRow(sqlStatement, string(countsJSON), job.upload.ID).Scan(&job.upload.Metadata)
 }
@@ -70,4 +70,83 @@ var _ = Describe("Constraint", func() {
 			},
 		),
 	)
+
+	DescribeTable("ConfiguredConstraints", func(constraintsConfig interface{}, expectedConstraints int, expectedErr string) {
+		constraints, err := ConfiguredConstraints(map[string]interface{}{ConstraintsField: constraintsConfig})
+		if expectedErr != "" {
+			Expect(err).To(MatchError(ContainSubstring(expectedErr)))
+			return
+		}
+		Expect(err).NotTo(HaveOccurred())
+		Expect(constraints).To(HaveLen(expectedConstraints))
+	},
+		Entry("Not configured", nil, 0, ""),
+		Entry("All types", []interface{}{
+			map[string]interface{}{"table": "tracks", "column": "event", "type": MaxLengthConstraint, "maxLength": 64, "policy": ConstraintPolicyTruncate},
+			map[string]interface{}{"table": "tracks", "column": "user_id", "type": NotNullConstraint, "policy": ConstraintPolicyFail},
+			map[string]interface{}{"table": "tracks", "column": "email", "type": RegexConstraint, "pattern": "^.+@.+$", "policy": ConstraintPolicyNull},
+			map[string]interface{}{"table": "tracks", "column": "plan", "type": AllowedValuesConstraint, "allowedValues": []string{"free", "paid"}},
+		}, 4, ""),
+		Entry("Invalid config", "max_length", 0, "invalid constraints config"),
+		Entry("Missing column", []interface{}{map[string]interface{}{"table": "tracks", "type": NotNullConstraint}}, 0, "table and column are required"),
+		Entry("Unknown type", []interface{}{map[string]interface{}{"table": "tracks", "column": "event", "type": "unique"}}, 0, `unknown constraint type "unique"`),
+		Entry("Unknown policy", []interface{}{map[string]interface{}{"table": "tracks", "column": "event", "type": NotNullConstraint, "policy": "drop"}}, 0, `unknown policy "drop"`),
+		Entry("Truncating without max length", []interface{}{map[string]interface{}{"table": "tracks", "column": "event", "type": RegexConstraint, "pattern": "a", "policy": ConstraintPolicyTruncate}}, 0, "policy truncate is only supported by max_length constraints"),
+		Entry("Nulling not null", []interface{}{map[string]interface{}{"table": "tracks", "column": "event", "type": NotNullConstraint, "policy": ConstraintPolicyNull}}, 0, "policy null is not supported by not_null constraints"),
+		Entry("Invalid max length", []interface{}{map[string]interface{}{"table": "tracks", "column": "event", "type": MaxLengthConstraint}}, 0, "maxLength must be positive"),
+		Entry("Invalid pattern", []interface{}{map[string]interface{}{"table": "tracks", "column": "event", "type": RegexConstraint, "pattern": "("}}, 0, "error parsing regexp"),
+		Entry("Missing allowed values", []interface{}{map[string]interface{}{"table": "tracks", "column": "event", "type": AllowedValuesConstraint}}, 0, "allowedValues are required"),
+	)
+
+	DescribeTable("Violated configured constraints", func(constraintConfig map[string]interface{}, data map[string]interface{}, expected *ConstraintsViolationT) {
+		constraints, err := ConfiguredConstraints(map[string]interface{}{ConstraintsField: []interface{}{constraintConfig}})
+		Expect(err).NotTo(HaveOccurred())
+
+		brEvent := &BatchRouterEventT{
+			Metadata: MetadataT{Table: "TRACKS", Columns: map[string]string{"EVENT": "string"}},
+			Data:     data,
+		}
+		Expect(ViolatedConstraints(warehouseutils.SNOWFLAKE, brEvent, "EVENT", constraints...)).To(Equal(expected))
+	},
+		Entry("Max length within limit",
+			map[string]interface{}{"table": "tracks", "column": "event", "type": MaxLengthConstraint, "maxLength": 5},
+			map[string]interface{}{"EVENT": "héllo"},
+			&ConstraintsViolationT{},
+		),
+		Entry("Max length truncated",
+			map[string]interface{}{"table": "tracks", "column": "event", "type": MaxLengthConstraint, "maxLength": 2, "policy": ConstraintPolicyTruncate},
+			map[string]interface{}{"EVENT": "héllo"},
+			&ConstraintsViolationT{IsViolated: true, Constraint: MaxLengthConstraint, Policy: ConstraintPolicyTruncate, TruncatedValue: "hé"},
+		),
+		Entry("Not null",
+			map[string]interface{}{"table": "tracks", "column": "event", "type": NotNullConstraint, "policy": ConstraintPolicyFail},
+			map[string]interface{}{},
+			&ConstraintsViolationT{IsViolated: true, Constraint: NotNullConstraint, Policy: ConstraintPolicyFail},
+		),
+		Entry("Regex matching",
+			map[string]interface{}{"table": "tracks", "column": "event", "type": RegexConstraint, "pattern": "^[a-z_]+$"},
+			map[string]interface{}{"EVENT": "product_viewed"},
+			&ConstraintsViolationT{},
+		),
+		Entry("Regex not matching",
+			map[string]interface{}{"table": "tracks", "column": "event", "type": RegexConstraint, "pattern": "^[a-z_]+$", "policy": ConstraintPolicyNull},
+			map[string]interface{}{"EVENT": "Product Viewed"},
+			&ConstraintsViolationT{IsViolated: true, Constraint: RegexConstraint, Policy: ConstraintPolicyNull},
+		),
+		Entry("Allowed value",
+			map[string]interface{}{"table": "tracks", "column": "event", "type": AllowedValuesConstraint, "allowedValues": []string{"a", "b"}},
+			map[string]interface{}{"EVENT": "a"},
+			&ConstraintsViolationT{},
+		),
+		Entry("Not allowed value discarded by default",
+			map[string]interface{}{"table": "tracks", "column": "event", "type": AllowedValuesConstraint, "allowedValues": []string{"a", "b"}},
+			map[string]interface{}{"EVENT": "c"},
+			&ConstraintsViolationT{IsViolated: true, Constraint: AllowedValuesConstraint, Policy: ConstraintPolicyDiscard},
+		),
+		Entry("Other column",
+			map[string]interface{}{"table": "tracks", "column": "user_id", "type": NotNullConstraint},
+			map[string]interface{}{},
+			&ConstraintsViolationT{},
+		),
+	)
 })
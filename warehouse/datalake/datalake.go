@@ -5,26 +5,40 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/rudderlabs/rudder-server/config"
 	"github.com/rudderlabs/rudder-server/utils/logger"
 	"github.com/rudderlabs/rudder-server/utils/misc"
 	"github.com/rudderlabs/rudder-server/warehouse/client"
+	"github.com/rudderlabs/rudder-server/warehouse/datalake/iceberg"
 	schemarepository "github.com/rudderlabs/rudder-server/warehouse/datalake/schema-repository"
 	warehouseutils "github.com/rudderlabs/rudder-server/warehouse/utils"
 )
 
 // TODO: Handle error using error types.
 var (
-	pkgLogger logger.Logger
+	pkgLogger               logger.Logger
+	icebergLocalCatalogDir  string
+	icebergCatalogTimeout   time.Duration
+	icebergMaxCommitRetries int
 )
 
 func Init() {
+	loadConfig()
 	pkgLogger = logger.NewLogger().Child("warehouse").Child("datalake")
 }
 
+func loadConfig() {
+	config.RegisterStringConfigVariable("", &icebergLocalCatalogDir, false, "Warehouse.datalake.iceberg.localCatalogDir")
+	config.RegisterDurationConfigVariable(30, &icebergCatalogTimeout, true, time.Second, "Warehouse.datalake.iceberg.catalogTimeout")
+	config.RegisterIntConfigVariable(3, &icebergMaxCommitRetries, true, 1, "Warehouse.datalake.iceberg.maxCommitRetries")
+}
+
 type HandleT struct {
 	SchemaRepository schemarepository.SchemaRepository
 	Warehouse        warehouseutils.Warehouse
 	Uploader         warehouseutils.UploaderI
+	ObjectStorage    string
+	IcebergCommitter *iceberg.Committer
 }
 
 func (wh *HandleT) Setup(warehouse warehouseutils.Warehouse, uploader warehouseutils.UploaderI) (err error) {
@@ -32,8 +46,13 @@ func (wh *HandleT) Setup(warehouse warehouseutils.Warehouse, uploader warehouseu
 	wh.Uploader = uploader
 
 	wh.SchemaRepository, err = schemarepository.NewSchemaRepository(wh.Warehouse, wh.Uploader)
-
-	return err
+	if err != nil {
+		return err
+	}
+	if wh.icebergEnabled() {
+		return wh.setupIceberg()
+	}
+	return nil
 }
 
 func (*HandleT) CrashRecover(_ warehouseutils.Warehouse) (err error) {
@@ -60,11 +79,17 @@ func (wh *HandleT) AddColumn(tableName, columnName, columnType string) (err erro
 	return wh.SchemaRepository.AddColumn(tableName, columnName, columnType)
 }
 
-func (wh *HandleT) AlterColumn(tableName, columnName, columnType string) (err error) {
-	return wh.SchemaRepository.AlterColumn(tableName, columnName, columnType)
+func (wh *HandleT) AlterColumn(tableName, columnName, columnType string) (warehouseutils.AlterColumnResponseT, error) {
+	return warehouseutils.AlterColumnResponseT{}, wh.SchemaRepository.AlterColumn(tableName, columnName, columnType)
 }
 
+// LoadTable is a no-op since the load files are already written to the object storage,
+// unless the destination uses the iceberg table format, in which case the load files are committed as a snapshot of the table.
+// Datalakes only support the append load mode, the merge load mode of the destination is ignored.
 func (wh *HandleT) LoadTable(tableName string) error {
+	if wh.icebergEnabled() {
+		return wh.commitIcebergTable(tableName)
+	}
 	pkgLogger.Infof("Skipping load for table %s : %s is a datalake destination", tableName, wh.Warehouse.Destination.ID)
 	return nil
 }
@@ -73,7 +98,18 @@ func (*HandleT) DeleteBy([]string, warehouseutils.DeleteByParams) (err error) {
 	return fmt.Errorf(warehouseutils.NotImplementedErrorCode)
 }
 
+func (*HandleT) PruneTable(context.Context, string, warehouseutils.RetentionParamsT) (warehouseutils.RetentionStatsT, error) {
+	return warehouseutils.RetentionStatsT{}, fmt.Errorf(warehouseutils.NotImplementedErrorCode)
+}
+
 func (wh *HandleT) LoadUserTables() map[string]error {
+	if wh.icebergEnabled() {
+		errorMap := map[string]error{warehouseutils.IdentifiesTable: wh.commitIcebergTable(warehouseutils.IdentifiesTable)}
+		if len(wh.Uploader.GetTableSchemaInUpload(warehouseutils.UsersTable)) > 0 {
+			errorMap[warehouseutils.UsersTable] = wh.commitIcebergTable(warehouseutils.UsersTable)
+		}
+		return errorMap
+	}
 	pkgLogger.Infof("Skipping load for user tables : %s is a datalake destination", wh.Warehouse.Destination.ID)
 	// return map with nil error entries for identifies and users(if any) tables
 	// this is so that they are marked as succeeded
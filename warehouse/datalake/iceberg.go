@@ -0,0 +1,191 @@
+package datalake
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/tidwall/gjson"
+
+	"github.com/rudderlabs/rudder-server/services/filemanager"
+	"github.com/rudderlabs/rudder-server/utils/misc"
+	"github.com/rudderlabs/rudder-server/warehouse/datalake/iceberg"
+	warehouseutils "github.com/rudderlabs/rudder-server/warehouse/utils"
+)
+
+const (
+	tableFormat         = "tableFormat"
+	icebergCatalogType  = "icebergCatalogType"
+	icebergCatalogURL   = "icebergCatalogURL"
+	icebergCatalogToken = "icebergCatalogToken"
+)
+
+const (
+	tableFormatIceberg        = "iceberg"
+	icebergCatalogTypeLocal   = "local"
+	icebergCatalogTypeREST    = "rest"
+	icebergLocalCatalogFolder = "rudder-iceberg-catalog"
+	// icebergCatalogScheme is the scheme of the locations of the catalog pointers, which are only read and written by
+	// the file manager of the destination from their path
+	icebergCatalogScheme = "rudder-object-storage"
+)
+
+// icebergEnabled returns whether the load files are committed to iceberg tables
+func (wh *HandleT) icebergEnabled() bool {
+	return strings.EqualFold(warehouseutils.GetConfigValue(tableFormat, wh.Warehouse), tableFormatIceberg)
+}
+
+// setupIceberg creates the committer of the load files using the catalog configured for the destination
+func (wh *HandleT) setupIceberg() error {
+	wh.ObjectStorage = warehouseutils.ObjectStorageType(wh.Warehouse.Destination.DestinationDefinition.Name, wh.Warehouse.Destination.Config, wh.Uploader.UseRudderStorage())
+	fileManager, err := filemanager.DefaultFileManagerFactory.New(&filemanager.SettingsT{
+		Provider: wh.ObjectStorage,
+		Config: misc.GetObjectStorageConfig(misc.ObjectStorageOptsT{
+			Provider:         wh.ObjectStorage,
+			Config:           wh.Warehouse.Destination.Config,
+			UseRudderStorage: wh.Uploader.UseRudderStorage(),
+			WorkspaceID:      wh.Warehouse.Destination.WorkspaceID,
+		}),
+	})
+	if err != nil {
+		return fmt.Errorf("creating file manager for iceberg metadata: %w", err)
+	}
+	fileIO := &icebergFileIO{fileManager: fileManager}
+
+	var catalog iceberg.Catalog
+	switch catalogType := warehouseutils.GetConfigValue(icebergCatalogType, wh.Warehouse); catalogType {
+	case "", icebergCatalogTypeLocal:
+		// pointers are kept in a local directory only if a persistent one is configured, since losing them
+		// would start the tables over without their snapshots. By default they are kept next to the tables.
+		if icebergLocalCatalogDir != "" {
+			catalog = iceberg.NewLocalCatalog(filepath.Join(icebergLocalCatalogDir, wh.Warehouse.Destination.ID), fileIO)
+			break
+		}
+		catalogKey := path.Join(fileManager.GetConfiguredPrefix(), icebergLocalCatalogFolder, wh.Warehouse.Destination.ID)
+		catalog = iceberg.NewObjectStorageCatalog(icebergCatalogScheme+":///"+strings.TrimPrefix(catalogKey, "/"), fileIO)
+	case icebergCatalogTypeREST:
+		catalogURL := warehouseutils.GetConfigValue(icebergCatalogURL, wh.Warehouse)
+		if catalogURL == "" {
+			return fmt.Errorf("iceberg rest catalog url is not configured for destination: %s", wh.Warehouse.Destination.ID)
+		}
+		catalog = iceberg.NewRESTCatalog(catalogURL, warehouseutils.GetConfigValue(icebergCatalogToken, wh.Warehouse), icebergCatalogTimeout)
+	default:
+		return fmt.Errorf("unsupported iceberg catalog type: %s", catalogType)
+	}
+	wh.IcebergCommitter = iceberg.NewCommitter(catalog, fileIO, icebergMaxCommitRetries)
+	return nil
+}
+
+// commitIcebergTable appends the load files of the table in the upload as a snapshot of the iceberg table
+func (wh *HandleT) commitIcebergTable(tableName string) error {
+	loadFiles := wh.Uploader.GetLoadFilesMetadata(warehouseutils.GetLoadFilesOptionsT{Table: tableName})
+	if len(loadFiles) == 0 {
+		return nil
+	}
+
+	tablePath := warehouseutils.GetTablePathInObjectStorage(wh.Warehouse.Namespace, tableName)
+	var tableLocation string
+	dataFiles := make([]iceberg.DataFile, 0, len(loadFiles))
+	for _, loadFile := range loadFiles {
+		folder := warehouseutils.GetObjectFolderForDeltalake(wh.ObjectStorage, loadFile.Location)
+		idx := strings.Index(folder, tablePath)
+		if idx == -1 {
+			return fmt.Errorf("load file %s is not stored under the path of table %s", loadFile.Location, tablePath)
+		}
+		tableLocation = folder[:idx+len(tablePath)]
+
+		dataFiles = append(dataFiles, iceberg.DataFile{
+			Path:            folder + "/" + path.Base(loadFile.Location),
+			RecordCount:     gjson.GetBytes(loadFile.Metadata, "total_rows").Int(),
+			FileSizeInBytes: gjson.GetBytes(loadFile.Metadata, "content_length").Int(),
+		})
+	}
+
+	ident := iceberg.TableIdentifier{Namespace: wh.Warehouse.Namespace, Name: tableName}
+	table, err := wh.IcebergCommitter.Append(context.TODO(), ident, tableLocation, wh.Uploader.GetTableSchemaInWarehouse(tableName), dataFiles)
+	if err != nil {
+		return err
+	}
+	pkgLogger.Infof("Committed %d load files to iceberg table %s for %s, metadata: %s", len(dataFiles), ident, wh.Warehouse.Destination.ID, table.MetadataLocation)
+	return nil
+}
+
+// icebergFileIO stores the iceberg metadata files next to the data files in the object storage of the destination
+type icebergFileIO struct {
+	fileManager filemanager.FileManager
+}
+
+// objectKey returns the key of the object at location relative to the bucket, or the container for azure
+func objectKey(location string) (string, error) {
+	objectURL, err := url.Parse(location)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(objectURL.Path, "/"), nil
+}
+
+func (fio *icebergFileIO) Write(ctx context.Context, location, name string, data []byte) (string, error) {
+	key, err := objectKey(location + "/" + name)
+	if err != nil {
+		return "", err
+	}
+	tmpDirPath, err := misc.CreateTMPDIR()
+	if err != nil {
+		return "", err
+	}
+	dir, err := os.MkdirTemp(tmpDirPath, "iceberg")
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	file, err := os.Create(filepath.Join(dir, path.Base(key)))
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = file.Close() }()
+	if _, err = file.Write(data); err != nil {
+		return "", err
+	}
+	if _, err = file.Seek(0, 0); err != nil {
+		return "", err
+	}
+
+	// the file manager prepends the configured prefix, which is already part of the table location
+	prefix := strings.TrimPrefix(path.Dir(key), fio.fileManager.GetConfiguredPrefix())
+	if _, err = fio.fileManager.Upload(ctx, file, strings.Trim(prefix, "/")); err != nil {
+		return "", err
+	}
+	return location + "/" + name, nil
+}
+
+func (fio *icebergFileIO) Read(ctx context.Context, location string) ([]byte, error) {
+	key, err := objectKey(location)
+	if err != nil {
+		return nil, err
+	}
+	tmpDirPath, err := misc.CreateTMPDIR()
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.CreateTemp(tmpDirPath, "iceberg")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+	}()
+	if err = fio.fileManager.Download(ctx, file, key); err != nil {
+		if errors.Is(err, filemanager.ErrKeyNotFound) {
+			return nil, fmt.Errorf("%s: %w", location, iceberg.ErrNoSuchFile)
+		}
+		return nil, err
+	}
+	return os.ReadFile(file.Name())
+}
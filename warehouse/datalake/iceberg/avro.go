@@ -0,0 +1,107 @@
+package iceberg
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+)
+
+// ocfMagic is the header of Avro object container files
+var ocfMagic = []byte{'O', 'b', 'j', 1}
+
+// avroEncoder encodes values with the Avro binary encoding.
+// It is used instead of a codec since the manifests of unpartitioned tables contain empty records, which codecs refuse.
+type avroEncoder struct {
+	buf bytes.Buffer
+}
+
+func (e *avroEncoder) writeLong(v int64) {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(b[:], v)
+	e.buf.Write(b[:n])
+}
+
+func (e *avroEncoder) writeInt(v int32) {
+	e.writeLong(int64(v))
+}
+
+func (e *avroEncoder) writeBytes(v []byte) {
+	e.writeLong(int64(len(v)))
+	e.buf.Write(v)
+}
+
+func (e *avroEncoder) writeString(v string) {
+	e.writeBytes([]byte(v))
+}
+
+// writeOptionalLong writes a ["null", "long"] union
+func (e *avroEncoder) writeOptionalLong(v *int64) {
+	if v == nil {
+		e.writeLong(0)
+		return
+	}
+	e.writeLong(1)
+	e.writeLong(*v)
+}
+
+// writeNull writes the null branch of an optional union
+func (e *avroEncoder) writeNull() {
+	e.writeLong(0)
+}
+
+// ocfWriter writes the records of an Avro object container file in a single block
+type ocfWriter struct {
+	schema   string
+	metadata map[string]string
+	records  avroEncoder
+	count    int64
+}
+
+func newOCFWriter(schema string, metadata map[string]string) *ocfWriter {
+	return &ocfWriter{schema: schema, metadata: metadata}
+}
+
+// append encodes a record with the given function
+func (w *ocfWriter) append(encode func(e *avroEncoder)) {
+	encode(&w.records)
+	w.count++
+}
+
+func (w *ocfWriter) writeTo(out io.Writer) (int64, error) {
+	var sync [16]byte
+	if _, err := rand.Read(sync[:]); err != nil {
+		return 0, err
+	}
+
+	var e avroEncoder
+	e.buf.Write(ocfMagic)
+	// the header metadata is a map block followed by the end of map marker
+	e.writeLong(int64(len(w.metadata) + 2))
+	e.writeString("avro.schema")
+	e.writeString(w.schema)
+	e.writeString("avro.codec")
+	e.writeString("null")
+	for _, key := range sortedKeys(w.metadata) {
+		e.writeString(key)
+		e.writeString(w.metadata[key])
+	}
+	e.writeLong(0)
+	e.buf.Write(sync[:])
+
+	if w.count > 0 {
+		e.writeLong(w.count)
+		e.writeLong(int64(w.records.buf.Len()))
+		e.buf.Write(w.records.buf.Bytes())
+		e.buf.Write(sync[:])
+	}
+	return e.buf.WriteTo(out)
+}
+
+func (w *ocfWriter) bytes() ([]byte, error) {
+	var out bytes.Buffer
+	if _, err := w.writeTo(&out); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,52 @@
+// Package iceberg writes the load files of datalake destinations as Apache Iceberg tables.
+// Every upload appends the load files of a table as a new snapshot, committed through a pluggable catalog.
+package iceberg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrNoSuchTable  = errors.New("iceberg: no such table")
+	ErrCommitFailed = errors.New("iceberg: commit failed, the table has been updated concurrently")
+	ErrTableExists  = errors.New("iceberg: table already exists")
+	ErrNoSuchFile   = errors.New("iceberg: no such file")
+)
+
+// TableIdentifier identifies a table of a catalog
+type TableIdentifier struct {
+	Namespace string
+	Name      string
+}
+
+func (ident TableIdentifier) String() string {
+	return fmt.Sprintf("%s.%s", ident.Namespace, ident.Name)
+}
+
+// Table is a table loaded from a catalog along with the location of its metadata file
+type Table struct {
+	Identifier       TableIdentifier
+	MetadataLocation string
+	Metadata         *TableMetadata
+}
+
+// Catalog tracks the current metadata of iceberg tables
+type Catalog interface {
+	// LoadTable returns ErrNoSuchTable if the table does not exist
+	LoadTable(ctx context.Context, ident TableIdentifier) (*Table, error)
+	// CreateTable returns ErrTableExists if the table has been created concurrently
+	CreateTable(ctx context.Context, ident TableIdentifier, metadata *TableMetadata) (*Table, error)
+	// CommitTable replaces the metadata of base with metadata.
+	// It returns ErrCommitFailed if the table is no longer at base, in which case the commit can be retried on top of the latest table.
+	CommitTable(ctx context.Context, base *Table, metadata *TableMetadata) (*Table, error)
+}
+
+// FileIO writes and reads the metadata files of the tables
+type FileIO interface {
+	// Write stores data as the file name relative to the table location and returns its location
+	Write(ctx context.Context, location, name string, data []byte) (string, error)
+	// Read returns an error wrapping ErrNoSuchFile if there is no file at location
+	Read(ctx context.Context, location string) ([]byte, error)
+}
@@ -0,0 +1,186 @@
+package iceberg
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gofrs/uuid"
+
+	"github.com/rudderlabs/rudder-server/utils/timeutil"
+	warehouseutils "github.com/rudderlabs/rudder-server/warehouse/utils"
+)
+
+// commitKeySummaryProperty records the data files of a snapshot in its summary,
+// so that a retried upload does not append the same data files twice
+const commitKeySummaryProperty = "rudder-commit-key"
+
+// Committer appends data files to the tables of a catalog
+type Committer struct {
+	Catalog Catalog
+	IO      FileIO
+	// MaxRetries is the number of times a commit is retried on top of the latest table after a concurrent update
+	MaxRetries int
+}
+
+func NewCommitter(catalog Catalog, io FileIO, maxRetries int) *Committer {
+	return &Committer{Catalog: catalog, IO: io, MaxRetries: maxRetries}
+}
+
+// Append commits a snapshot appending dataFiles to the table, creating the table at location if it does not exist.
+// Columns of tableSchema missing in the table are added to the iceberg schema as part of the same commit.
+func (c *Committer) Append(ctx context.Context, ident TableIdentifier, location string, tableSchema warehouseutils.TableSchemaT, dataFiles []DataFile) (*Table, error) {
+	if len(dataFiles) == 0 {
+		return nil, nil
+	}
+	commitKey := dataFilesCommitKey(dataFiles)
+
+	var err error
+	for attempt := 0; attempt <= c.MaxRetries; attempt++ {
+		var table *Table
+		table, err = c.loadOrCreateTable(ctx, ident, location, tableSchema)
+		if errors.Is(err, ErrTableExists) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if table.Metadata.hasCommit(commitKey) {
+			return table, nil
+		}
+
+		var metadata *TableMetadata
+		metadata, err = c.appendSnapshot(ctx, table.Metadata, tableSchema, dataFiles, commitKey)
+		if err != nil {
+			return nil, err
+		}
+		table, err = c.Catalog.CommitTable(ctx, table, metadata)
+		if errors.Is(err, ErrCommitFailed) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("committing snapshot of table %s: %w", ident, err)
+		}
+		return table, nil
+	}
+	return nil, fmt.Errorf("appending to table %s after %d retries: %w", ident, c.MaxRetries, err)
+}
+
+func (c *Committer) loadOrCreateTable(ctx context.Context, ident TableIdentifier, location string, tableSchema warehouseutils.TableSchemaT) (*Table, error) {
+	table, err := c.Catalog.LoadTable(ctx, ident)
+	if errors.Is(err, ErrNoSuchTable) {
+		return c.Catalog.CreateTable(ctx, ident, NewTableMetadata(location, tableSchema))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("loading table %s: %w", ident, err)
+	}
+	return table, nil
+}
+
+// appendSnapshot writes the manifest and the manifest list of a new snapshot and returns the metadata with the snapshot added
+func (c *Committer) appendSnapshot(ctx context.Context, base *TableMetadata, tableSchema warehouseutils.TableSchemaT, dataFiles []DataFile, commitKey string) (*TableMetadata, error) {
+	metadata, err := base.Clone()
+	if err != nil {
+		return nil, err
+	}
+	metadata.EvolveSchema(tableSchema)
+
+	snapshotID := newSnapshotID()
+	sequenceNumber := metadata.LastSequenceNumber + 1
+	manifestData, err := writeManifest(metadata.CurrentSchema(), snapshotID, dataFiles)
+	if err != nil {
+		return nil, fmt.Errorf("writing manifest: %w", err)
+	}
+	manifestLocation, err := c.IO.Write(ctx, metadata.Location, fmt.Sprintf("metadata/%s-m0.avro", uuid.Must(uuid.NewV4()).String()), manifestData)
+	if err != nil {
+		return nil, fmt.Errorf("uploading manifest: %w", err)
+	}
+
+	var addedRecords, addedFilesSize int64
+	for _, dataFile := range dataFiles {
+		addedRecords += dataFile.RecordCount
+		addedFilesSize += dataFile.FileSizeInBytes
+	}
+	manifests := []manifestFile{{
+		Path:              manifestLocation,
+		Length:            int64(len(manifestData)),
+		SequenceNumber:    sequenceNumber,
+		MinSequenceNumber: sequenceNumber,
+		AddedSnapshotID:   snapshotID,
+		AddedFilesCount:   int32(len(dataFiles)),
+		AddedRowsCount:    addedRecords,
+	}}
+
+	// the manifests of the parent snapshot are carried over, since every snapshot lists all the data files of the table
+	parent := metadata.CurrentSnapshot()
+	var parentSnapshotID *int64
+	if parent != nil {
+		parentSnapshotID = &parent.SnapshotID
+		data, err := c.IO.Read(ctx, parent.ManifestList)
+		if err != nil {
+			return nil, fmt.Errorf("reading manifest list %s: %w", parent.ManifestList, err)
+		}
+		parentManifests, err := readManifestList(data)
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, parentManifests...)
+	}
+
+	manifestListData, err := writeManifestList(snapshotID, parentSnapshotID, sequenceNumber, manifests)
+	if err != nil {
+		return nil, fmt.Errorf("writing manifest list: %w", err)
+	}
+	manifestListLocation, err := c.IO.Write(ctx, metadata.Location, fmt.Sprintf("metadata/snap-%d-1-%s.avro", snapshotID, uuid.Must(uuid.NewV4()).String()), manifestListData)
+	if err != nil {
+		return nil, fmt.Errorf("uploading manifest list: %w", err)
+	}
+
+	schemaID := metadata.CurrentSchemaID
+	metadata.addSnapshot(Snapshot{
+		SnapshotID:       snapshotID,
+		ParentSnapshotID: parentSnapshotID,
+		SequenceNumber:   sequenceNumber,
+		TimestampMs:      timeutil.Now().UnixMilli(),
+		ManifestList:     manifestListLocation,
+		SchemaID:         &schemaID,
+		Summary: map[string]string{
+			"operation":              "append",
+			"added-data-files":       strconv.Itoa(len(dataFiles)),
+			"added-records":          strconv.FormatInt(addedRecords, 10),
+			"added-files-size":       strconv.FormatInt(addedFilesSize, 10),
+			"total-data-files":       strconv.FormatInt(summaryInt(parent, "total-data-files")+int64(len(dataFiles)), 10),
+			"total-records":          strconv.FormatInt(summaryInt(parent, "total-records")+addedRecords, 10),
+			"total-files-size":       strconv.FormatInt(summaryInt(parent, "total-files-size")+addedFilesSize, 10),
+			"total-delete-files":     "0",
+			"total-position-deletes": "0",
+			"total-equality-deletes": "0",
+			commitKeySummaryProperty: commitKey,
+		},
+	})
+	return metadata, nil
+}
+
+// dataFilesCommitKey returns a digest of the paths of the data files
+func dataFilesCommitKey(dataFiles []DataFile) string {
+	paths := make([]string, 0, len(dataFiles))
+	for _, dataFile := range dataFiles {
+		paths = append(paths, dataFile.Path)
+	}
+	sort.Strings(paths)
+	sum := sha256.Sum256([]byte(strings.Join(paths, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// newSnapshotID returns a random positive snapshot id
+func newSnapshotID() int64 {
+	id := uuid.Must(uuid.NewV4())
+	return int64(binary.BigEndian.Uint64(id[:8]) & math.MaxInt64)
+}
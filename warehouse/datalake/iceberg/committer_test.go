@@ -0,0 +1,281 @@
+package iceberg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	warehouseutils "github.com/rudderlabs/rudder-server/warehouse/utils"
+)
+
+type memoryFileIO struct {
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+func newMemoryFileIO() *memoryFileIO {
+	return &memoryFileIO{files: map[string][]byte{}}
+}
+
+func (m *memoryFileIO) Write(_ context.Context, location, name string, data []byte) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.files[location+"/"+name] = data
+	return location + "/" + name, nil
+}
+
+func (m *memoryFileIO) Read(_ context.Context, location string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.files[location]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", location, ErrNoSuchFile)
+	}
+	return data, nil
+}
+
+// restCatalogServer is a fake of the iceberg REST catalog, applying the updates requested by the commits
+type restCatalogServer struct {
+	mu     sync.Mutex
+	io     FileIO
+	tables map[string]*Table
+	token  string
+}
+
+func (s *restCatalogServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.Header.Get("Authorization") != "Bearer "+s.token {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/v1/namespaces"), "/")
+	switch {
+	case len(parts) == 1:
+		w.WriteHeader(http.StatusOK)
+	case len(parts) == 3 && r.Method == http.MethodPost:
+		var request restCreateTableRequest
+		_ = json.NewDecoder(r.Body).Decode(&request)
+		metadata := NewTableMetadata(request.Location, nil)
+		metadata.Schemas = []Schema{request.Schema}
+		metadata.LastColumnID = len(request.Schema.Fields)
+		metadata.Properties = request.Properties
+		s.write(w, TableIdentifier{Namespace: parts[1], Name: request.Name}, metadata)
+	case len(parts) == 4 && r.Method == http.MethodGet:
+		table, ok := s.tables[parts[1]+"."+parts[3]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(restLoadTableResult{MetadataLocation: table.MetadataLocation, Metadata: table.Metadata})
+	case len(parts) == 4 && r.Method == http.MethodPost:
+		table, ok := s.tables[parts[1]+"."+parts[3]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var request struct {
+			Requirements []map[string]interface{} `json:"requirements"`
+			Updates      []struct {
+				Action     string            `json:"action"`
+				Schema     Schema            `json:"schema"`
+				SchemaID   int               `json:"schema-id"`
+				Snapshot   Snapshot          `json:"snapshot"`
+				Properties map[string]string `json:"updates"`
+				SnapshotID int64             `json:"snapshot-id"`
+			} `json:"updates"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&request)
+
+		metadata, _ := table.Metadata.Clone()
+		for _, requirement := range request.Requirements {
+			if requirement["type"] != "assert-ref-snapshot-id" {
+				continue
+			}
+			var current interface{}
+			if ref, ok := metadata.Refs[mainBranch]; ok {
+				current = float64(ref.SnapshotID)
+			}
+			if requirement["snapshot-id"] != current {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+		}
+		for _, update := range request.Updates {
+			switch update.Action {
+			case "add-schema":
+				metadata.Schemas = append(metadata.Schemas, update.Schema)
+			case "set-current-schema":
+				metadata.CurrentSchemaID = update.SchemaID
+			case "set-properties":
+				metadata.Properties = update.Properties
+			case "add-snapshot":
+				metadata.addSnapshot(update.Snapshot)
+			}
+		}
+		s.write(w, table.Identifier, metadata)
+	}
+}
+
+func (s *restCatalogServer) write(w http.ResponseWriter, ident TableIdentifier, metadata *TableMetadata) {
+	data, _ := json.Marshal(metadata)
+	location, _ := s.io.Write(context.Background(), metadata.Location, metadata.metadataFileName(), data)
+	s.tables[ident.String()] = &Table{Identifier: ident, MetadataLocation: location, Metadata: metadata}
+	_ = json.NewEncoder(w).Encode(restLoadTableResult{MetadataLocation: location, Metadata: metadata})
+}
+
+func TestCommitterAppend(t *testing.T) {
+	const location = "s3://bucket/rudder-datalake/ns/tracks"
+	ident := TableIdentifier{Namespace: "ns", Name: "tracks"}
+
+	catalogs := map[string]func(t *testing.T, io FileIO) Catalog{
+		"local catalog": func(t *testing.T, io FileIO) Catalog {
+			return NewLocalCatalog(t.TempDir(), io)
+		},
+		"object storage catalog": func(t *testing.T, io FileIO) Catalog {
+			return NewObjectStorageCatalog("s3://bucket/rudder-iceberg-catalog/", io)
+		},
+		"rest catalog": func(t *testing.T, io FileIO) Catalog {
+			server := httptest.NewServer(&restCatalogServer{io: io, tables: map[string]*Table{}, token: "token"})
+			t.Cleanup(server.Close)
+			return NewRESTCatalog(server.URL+"/", "token", time.Minute)
+		},
+	}
+	for name, newCatalog := range catalogs {
+		newCatalog := newCatalog
+		t.Run(name, func(t *testing.T) {
+			io := newMemoryFileIO()
+			catalog := newCatalog(t, io)
+			committer := NewCommitter(catalog, io, 3)
+
+			table, err := committer.Append(context.Background(), ident, location, warehouseutils.TableSchemaT{"id": "string"}, nil)
+			require.NoError(t, err)
+			require.Nil(t, table, "nothing is committed without data files")
+
+			firstFiles := []DataFile{{Path: location + "/1.parquet", RecordCount: 10, FileSizeInBytes: 100}}
+			table, err = committer.Append(context.Background(), ident, location, warehouseutils.TableSchemaT{"id": "string"}, firstFiles)
+			require.NoError(t, err)
+			require.Len(t, table.Metadata.Snapshots, 1)
+
+			// appending the same data files again, like a retried upload does, is a no-op
+			table, err = committer.Append(context.Background(), ident, location, warehouseutils.TableSchemaT{"id": "string"}, firstFiles)
+			require.NoError(t, err)
+			require.Len(t, table.Metadata.Snapshots, 1)
+
+			secondFiles := []DataFile{
+				{Path: location + "/2.parquet", RecordCount: 5, FileSizeInBytes: 50},
+				{Path: location + "/3.parquet", RecordCount: 1, FileSizeInBytes: 10},
+			}
+			table, err = committer.Append(context.Background(), ident, location, warehouseutils.TableSchemaT{"id": "string", "revenue": "float"}, secondFiles)
+			require.NoError(t, err)
+
+			loaded, err := catalog.LoadTable(context.Background(), ident)
+			require.NoError(t, err)
+			require.Equal(t, table.MetadataLocation, loaded.MetadataLocation)
+			require.Equal(t, warehouseutils.TableSchemaT{"id": "string", "revenue": "float"}, loaded.Metadata.Schema())
+			require.Len(t, loaded.Metadata.Snapshots, 2)
+
+			snapshot := loaded.Metadata.CurrentSnapshot()
+			require.NotNil(t, snapshot)
+			require.Equal(t, loaded.Metadata.Snapshots[0].SnapshotID, *snapshot.ParentSnapshotID)
+			require.Equal(t, int64(2), snapshot.SequenceNumber)
+			require.Equal(t, "16", snapshot.Summary["total-records"])
+			require.Equal(t, "3", snapshot.Summary["total-data-files"])
+			require.Equal(t, loaded.Metadata.CurrentSchemaID, *snapshot.SchemaID)
+
+			manifestListData, err := io.Read(context.Background(), snapshot.ManifestList)
+			require.NoError(t, err)
+			manifests, err := readManifestList(manifestListData)
+			require.NoError(t, err)
+			require.Len(t, manifests, 2, "the manifests of the parent snapshot must be carried over")
+			require.Equal(t, int32(2), manifests[0].AddedFilesCount)
+			require.Equal(t, int64(2), manifests[0].SequenceNumber)
+			require.Equal(t, int64(10), manifests[1].AddedRowsCount)
+			require.Equal(t, int64(1), manifests[1].SequenceNumber)
+		})
+	}
+}
+
+func TestLocalCatalogConcurrentCommit(t *testing.T) {
+	io := newMemoryFileIO()
+	catalog := NewLocalCatalog(t.TempDir(), io)
+	ident := TableIdentifier{Namespace: "ns", Name: "tracks"}
+
+	_, err := catalog.LoadTable(context.Background(), ident)
+	require.ErrorIs(t, err, ErrNoSuchTable)
+
+	base, err := catalog.CreateTable(context.Background(), ident, NewTableMetadata("s3://bucket/tracks", warehouseutils.TableSchemaT{"id": "string"}))
+	require.NoError(t, err)
+	_, err = catalog.CreateTable(context.Background(), ident, base.Metadata)
+	require.ErrorIs(t, err, ErrTableExists)
+
+	updated, err := catalog.CommitTable(context.Background(), base, base.Metadata)
+	require.NoError(t, err)
+	require.Len(t, updated.Metadata.MetadataLog, 1)
+	require.Equal(t, base.MetadataLocation, updated.Metadata.MetadataLog[0].MetadataFile)
+	require.Contains(t, updated.MetadataLocation, "/metadata/00002-")
+
+	_, err = catalog.CommitTable(context.Background(), base, base.Metadata)
+	require.ErrorIs(t, err, ErrCommitFailed, "committing on top of a stale base must fail")
+
+	// the committer retries on top of the latest table after a concurrent commit
+	committer := NewCommitter(&conflictingCatalog{Catalog: catalog, conflicts: 1}, io, 3)
+	table, err := committer.Append(context.Background(), ident, "s3://bucket/tracks", warehouseutils.TableSchemaT{"id": "string"}, []DataFile{{Path: "s3://bucket/tracks/1.parquet", RecordCount: 1}})
+	require.NoError(t, err)
+	require.Len(t, table.Metadata.Snapshots, 1)
+
+	committer = NewCommitter(&conflictingCatalog{Catalog: catalog, conflicts: 5}, io, 3)
+	_, err = committer.Append(context.Background(), ident, "s3://bucket/tracks", warehouseutils.TableSchemaT{"id": "string"}, []DataFile{{Path: "s3://bucket/tracks/2.parquet", RecordCount: 1}})
+	require.ErrorIs(t, err, ErrCommitFailed)
+}
+
+func TestObjectStorageCatalogSharedPointers(t *testing.T) {
+	io := newMemoryFileIO()
+	ident := TableIdentifier{Namespace: "ns", Name: "tracks"}
+	files := []DataFile{{Path: "s3://bucket/tracks/1.parquet", RecordCount: 1}}
+
+	_, err := NewObjectStorageCatalog("s3://bucket/catalog", io).LoadTable(context.Background(), ident)
+	require.ErrorIs(t, err, ErrNoSuchTable)
+
+	table, err := NewCommitter(NewObjectStorageCatalog("s3://bucket/catalog", io), io, 3).Append(context.Background(), ident, "s3://bucket/tracks", warehouseutils.TableSchemaT{"id": "string"}, files)
+	require.NoError(t, err)
+	require.Contains(t, io.files, "s3://bucket/catalog/ns/tracks.json")
+
+	// another process, or the same one after a restart, picks the table up along with its snapshots
+	catalog := NewObjectStorageCatalog("s3://bucket/catalog", io)
+	loaded, err := catalog.LoadTable(context.Background(), ident)
+	require.NoError(t, err)
+	require.Equal(t, table.MetadataLocation, loaded.MetadataLocation)
+	_, err = catalog.CreateTable(context.Background(), ident, loaded.Metadata)
+	require.ErrorIs(t, err, ErrTableExists)
+
+	table, err = NewCommitter(catalog, io, 3).Append(context.Background(), ident, "s3://bucket/tracks", warehouseutils.TableSchemaT{"id": "string"}, files)
+	require.NoError(t, err)
+	require.Len(t, table.Metadata.Snapshots, 1, "data files committed before the restart should not be committed again")
+
+	_, err = catalog.CommitTable(context.Background(), &Table{Identifier: ident, MetadataLocation: "stale", Metadata: loaded.Metadata}, loaded.Metadata)
+	require.ErrorIs(t, err, ErrCommitFailed)
+}
+
+// conflictingCatalog fails the first commits as if the table had been updated concurrently
+type conflictingCatalog struct {
+	Catalog
+	conflicts int
+}
+
+func (c *conflictingCatalog) CommitTable(ctx context.Context, base *Table, metadata *TableMetadata) (*Table, error) {
+	if c.conflicts > 0 {
+		c.conflicts--
+		return nil, ErrCommitFailed
+	}
+	return c.Catalog.CommitTable(ctx, base, metadata)
+}
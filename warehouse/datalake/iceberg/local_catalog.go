@@ -0,0 +1,127 @@
+package iceberg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// localCatalogLock serializes the commits of the local catalogs of the process
+var localCatalogLock sync.Mutex
+
+// LocalCatalog is a file based catalog, which keeps a pointer to the current metadata file of every table in a local directory.
+// It is meant for single node setups with a persistent directory, commits of concurrent processes sharing the directory are not serialized.
+type LocalCatalog struct {
+	Directory string
+	IO        FileIO
+}
+
+type localTablePointer struct {
+	MetadataLocation string `json:"metadata-location"`
+}
+
+func NewLocalCatalog(directory string, io FileIO) *LocalCatalog {
+	return &LocalCatalog{Directory: directory, IO: io}
+}
+
+func (lc *LocalCatalog) pointerPath(ident TableIdentifier) string {
+	return filepath.Join(lc.Directory, ident.Namespace, ident.Name+".json")
+}
+
+func (lc *LocalCatalog) LoadTable(ctx context.Context, ident TableIdentifier) (*Table, error) {
+	data, err := os.ReadFile(lc.pointerPath(ident))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNoSuchTable
+	}
+	if err != nil {
+		return nil, err
+	}
+	var pointer localTablePointer
+	if err = json.Unmarshal(data, &pointer); err != nil {
+		return nil, fmt.Errorf("unmarshalling pointer of table %s: %w", ident, err)
+	}
+
+	data, err = lc.IO.Read(ctx, pointer.MetadataLocation)
+	if err != nil {
+		return nil, fmt.Errorf("reading metadata %s of table %s: %w", pointer.MetadataLocation, ident, err)
+	}
+	var metadata TableMetadata
+	if err = json.Unmarshal(data, &metadata); err != nil {
+		return nil, fmt.Errorf("unmarshalling metadata %s of table %s: %w", pointer.MetadataLocation, ident, err)
+	}
+	if metadata.Properties == nil {
+		metadata.Properties = map[string]string{}
+	}
+	return &Table{Identifier: ident, MetadataLocation: pointer.MetadataLocation, Metadata: &metadata}, nil
+}
+
+func (lc *LocalCatalog) CreateTable(ctx context.Context, ident TableIdentifier, metadata *TableMetadata) (*Table, error) {
+	localCatalogLock.Lock()
+	defer localCatalogLock.Unlock()
+
+	if _, err := os.Stat(lc.pointerPath(ident)); err == nil {
+		return nil, ErrTableExists
+	}
+	return lc.writeTable(ctx, ident, metadata)
+}
+
+func (lc *LocalCatalog) CommitTable(ctx context.Context, base *Table, metadata *TableMetadata) (*Table, error) {
+	localCatalogLock.Lock()
+	defer localCatalogLock.Unlock()
+
+	data, err := os.ReadFile(lc.pointerPath(base.Identifier))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNoSuchTable
+	}
+	if err != nil {
+		return nil, err
+	}
+	var pointer localTablePointer
+	if err = json.Unmarshal(data, &pointer); err != nil {
+		return nil, fmt.Errorf("unmarshalling pointer of table %s: %w", base.Identifier, err)
+	}
+	if pointer.MetadataLocation != base.MetadataLocation {
+		return nil, ErrCommitFailed
+	}
+
+	metadata, err = metadata.Clone()
+	if err != nil {
+		return nil, err
+	}
+	metadata.MetadataLog = append(metadata.MetadataLog, MetadataLogEntry{MetadataFile: base.MetadataLocation, TimestampMs: base.Metadata.LastUpdatedMs})
+	return lc.writeTable(ctx, base.Identifier, metadata)
+}
+
+// writeTable writes the metadata file and then points the table to it
+func (lc *LocalCatalog) writeTable(ctx context.Context, ident TableIdentifier, metadata *TableMetadata) (*Table, error) {
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, err
+	}
+	metadataLocation, err := lc.IO.Write(ctx, metadata.Location, metadata.metadataFileName(), data)
+	if err != nil {
+		return nil, fmt.Errorf("writing metadata of table %s: %w", ident, err)
+	}
+
+	pointerPath := lc.pointerPath(ident)
+	if err = os.MkdirAll(filepath.Dir(pointerPath), os.ModePerm); err != nil {
+		return nil, err
+	}
+	data, err = json.Marshal(localTablePointer{MetadataLocation: metadataLocation})
+	if err != nil {
+		return nil, err
+	}
+	// the pointer is replaced by a rename, so that readers never see a partially written pointer
+	tmpPath := pointerPath + ".tmp"
+	if err = os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return nil, err
+	}
+	if err = os.Rename(tmpPath, pointerPath); err != nil {
+		return nil, err
+	}
+	return &Table{Identifier: ident, MetadataLocation: metadataLocation, Metadata: metadata}, nil
+}
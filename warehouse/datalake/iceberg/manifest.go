@@ -0,0 +1,182 @@
+package iceberg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/linkedin/goavro"
+)
+
+const (
+	manifestStatusAdded = 1
+	dataFileContentData = 0
+	manifestContentData = 0
+)
+
+// manifestEntrySchema is the avro schema of the entries of v2 data manifests of unpartitioned tables.
+// Sequence numbers of the entries are left null, so that they are inherited from the manifest list.
+const manifestEntrySchema = `{
+	"type": "record",
+	"name": "manifest_entry",
+	"fields": [
+		{"name": "status", "type": "int", "field-id": 0},
+		{"name": "snapshot_id", "type": ["null", "long"], "default": null, "field-id": 1},
+		{"name": "sequence_number", "type": ["null", "long"], "default": null, "field-id": 3},
+		{"name": "file_sequence_number", "type": ["null", "long"], "default": null, "field-id": 4},
+		{"name": "data_file", "type": {
+			"type": "record",
+			"name": "r2",
+			"fields": [
+				{"name": "content", "type": "int", "field-id": 134},
+				{"name": "file_path", "type": "string", "field-id": 100},
+				{"name": "file_format", "type": "string", "field-id": 101},
+				{"name": "partition", "type": {"type": "record", "name": "r102", "fields": []}, "field-id": 102},
+				{"name": "record_count", "type": "long", "field-id": 103},
+				{"name": "file_size_in_bytes", "type": "long", "field-id": 104}
+			]
+		}, "field-id": 2}
+	]
+}`
+
+// manifestFileSchema is the avro schema of the entries of v2 manifest lists
+const manifestFileSchema = `{
+	"type": "record",
+	"name": "manifest_file",
+	"fields": [
+		{"name": "manifest_path", "type": "string", "field-id": 500},
+		{"name": "manifest_length", "type": "long", "field-id": 501},
+		{"name": "partition_spec_id", "type": "int", "field-id": 502},
+		{"name": "content", "type": "int", "field-id": 517},
+		{"name": "sequence_number", "type": "long", "field-id": 515},
+		{"name": "min_sequence_number", "type": "long", "field-id": 516},
+		{"name": "added_snapshot_id", "type": "long", "field-id": 503},
+		{"name": "added_files_count", "type": "int", "field-id": 504},
+		{"name": "existing_files_count", "type": "int", "field-id": 505},
+		{"name": "deleted_files_count", "type": "int", "field-id": 506},
+		{"name": "added_rows_count", "type": "long", "field-id": 512},
+		{"name": "existing_rows_count", "type": "long", "field-id": 513},
+		{"name": "deleted_rows_count", "type": "long", "field-id": 514}
+	]
+}`
+
+// DataFile is a parquet file appended to a table
+type DataFile struct {
+	Path            string
+	RecordCount     int64
+	FileSizeInBytes int64
+}
+
+// manifestFile is an entry of a manifest list
+type manifestFile struct {
+	Path               string
+	Length             int64
+	SequenceNumber     int64
+	MinSequenceNumber  int64
+	AddedSnapshotID    int64
+	AddedFilesCount    int32
+	ExistingFilesCount int32
+	DeletedFilesCount  int32
+	AddedRowsCount     int64
+	ExistingRowsCount  int64
+	DeletedRowsCount   int64
+}
+
+// writeManifest returns the manifest of the data files added by the snapshot
+func writeManifest(schema Schema, snapshotID int64, dataFiles []DataFile) ([]byte, error) {
+	schemaJSON, err := json.Marshal(schema)
+	if err != nil {
+		return nil, err
+	}
+	writer := newOCFWriter(manifestEntrySchema, map[string]string{
+		"schema":            string(schemaJSON),
+		"schema-id":         strconv.Itoa(schema.SchemaID),
+		"partition-spec":    "[]",
+		"partition-spec-id": "0",
+		"format-version":    strconv.Itoa(formatVersion),
+		"content":           "data",
+	})
+	for _, dataFile := range dataFiles {
+		dataFile := dataFile
+		writer.append(func(e *avroEncoder) {
+			e.writeInt(manifestStatusAdded)
+			e.writeOptionalLong(&snapshotID)
+			e.writeNull()
+			e.writeNull()
+			e.writeInt(dataFileContentData)
+			e.writeString(dataFile.Path)
+			e.writeString("PARQUET")
+			// the partition is an empty record, which has no encoded bytes
+			e.writeLong(dataFile.RecordCount)
+			e.writeLong(dataFile.FileSizeInBytes)
+		})
+	}
+	return writer.bytes()
+}
+
+// writeManifestList returns the manifest list of a snapshot
+func writeManifestList(snapshotID int64, parentSnapshotID *int64, sequenceNumber int64, manifests []manifestFile) ([]byte, error) {
+	metadata := map[string]string{
+		"snapshot-id":     strconv.FormatInt(snapshotID, 10),
+		"sequence-number": strconv.FormatInt(sequenceNumber, 10),
+		"format-version":  strconv.Itoa(formatVersion),
+	}
+	if parentSnapshotID != nil {
+		metadata["parent-snapshot-id"] = strconv.FormatInt(*parentSnapshotID, 10)
+	}
+	writer := newOCFWriter(manifestFileSchema, metadata)
+	for _, manifest := range manifests {
+		manifest := manifest
+		writer.append(func(e *avroEncoder) {
+			e.writeString(manifest.Path)
+			e.writeLong(manifest.Length)
+			e.writeInt(0)
+			e.writeInt(manifestContentData)
+			e.writeLong(manifest.SequenceNumber)
+			e.writeLong(manifest.MinSequenceNumber)
+			e.writeLong(manifest.AddedSnapshotID)
+			e.writeInt(manifest.AddedFilesCount)
+			e.writeInt(manifest.ExistingFilesCount)
+			e.writeInt(manifest.DeletedFilesCount)
+			e.writeLong(manifest.AddedRowsCount)
+			e.writeLong(manifest.ExistingRowsCount)
+			e.writeLong(manifest.DeletedRowsCount)
+		})
+	}
+	return writer.bytes()
+}
+
+// readManifestList returns the entries of a manifest list, so that they can be carried over to the next snapshot
+func readManifestList(data []byte) ([]manifestFile, error) {
+	reader, err := goavro.NewOCFReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("reading manifest list: %w", err)
+	}
+
+	var manifests []manifestFile
+	for reader.Scan() {
+		datum, err := reader.Read()
+		if err != nil {
+			return nil, fmt.Errorf("reading manifest list entry: %w", err)
+		}
+		record, ok := datum.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unexpected manifest list entry: %T", datum)
+		}
+		manifest := manifestFile{}
+		manifest.Path, _ = record["manifest_path"].(string)
+		manifest.Length, _ = record["manifest_length"].(int64)
+		manifest.SequenceNumber, _ = record["sequence_number"].(int64)
+		manifest.MinSequenceNumber, _ = record["min_sequence_number"].(int64)
+		manifest.AddedSnapshotID, _ = record["added_snapshot_id"].(int64)
+		manifest.AddedFilesCount, _ = record["added_files_count"].(int32)
+		manifest.ExistingFilesCount, _ = record["existing_files_count"].(int32)
+		manifest.DeletedFilesCount, _ = record["deleted_files_count"].(int32)
+		manifest.AddedRowsCount, _ = record["added_rows_count"].(int64)
+		manifest.ExistingRowsCount, _ = record["existing_rows_count"].(int64)
+		manifest.DeletedRowsCount, _ = record["deleted_rows_count"].(int64)
+		manifests = append(manifests, manifest)
+	}
+	return manifests, reader.Err()
+}
@@ -0,0 +1,125 @@
+package iceberg
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/linkedin/goavro"
+	"github.com/stretchr/testify/require"
+)
+
+// readManifestEntries decodes the entries of a manifest.
+// The empty partition record is dropped from the schema, since goavro refuses empty records and they have no encoded bytes.
+func readManifestEntries(t *testing.T, data []byte) (map[string][]byte, []map[string]interface{}) {
+	t.Helper()
+
+	headerCodec, err := goavro.NewCodec(`{
+		"type": "record",
+		"name": "org.apache.avro.file.Header",
+		"fields": [
+			{"name": "magic", "type": {"type": "fixed", "name": "Magic", "size": 4}},
+			{"name": "meta", "type": {"type": "map", "values": "bytes"}},
+			{"name": "sync", "type": {"type": "fixed", "name": "Sync", "size": 16}}
+		]
+	}`)
+	require.NoError(t, err)
+	header, buf, err := headerCodec.NativeFromBinary(data)
+	require.NoError(t, err)
+	meta := make(map[string][]byte)
+	for key, value := range header.(map[string]interface{})["meta"].(map[string]interface{}) {
+		meta[key] = value.([]byte)
+	}
+
+	entryCodec, err := goavro.NewCodec(`{
+		"type": "record",
+		"name": "manifest_entry",
+		"fields": [
+			{"name": "status", "type": "int"},
+			{"name": "snapshot_id", "type": ["null", "long"]},
+			{"name": "sequence_number", "type": ["null", "long"]},
+			{"name": "file_sequence_number", "type": ["null", "long"]},
+			{"name": "data_file", "type": {
+				"type": "record",
+				"name": "r2",
+				"fields": [
+					{"name": "content", "type": "int"},
+					{"name": "file_path", "type": "string"},
+					{"name": "file_format", "type": "string"},
+					{"name": "record_count", "type": "long"},
+					{"name": "file_size_in_bytes", "type": "long"}
+				]
+			}}
+		]
+	}`)
+	require.NoError(t, err)
+	longCodec, err := goavro.NewCodec(`"long"`)
+	require.NoError(t, err)
+
+	count, buf, err := longCodec.NativeFromBinary(buf)
+	require.NoError(t, err)
+	_, buf, err = longCodec.NativeFromBinary(buf)
+	require.NoError(t, err)
+
+	var entries []map[string]interface{}
+	for i := int64(0); i < count.(int64); i++ {
+		var entry interface{}
+		entry, buf, err = entryCodec.NativeFromBinary(buf)
+		require.NoError(t, err)
+		entries = append(entries, entry.(map[string]interface{}))
+	}
+	require.Len(t, buf, 16, "the block must be followed by the sync marker only")
+	return meta, entries
+}
+
+func TestWriteManifest(t *testing.T) {
+	schema := Schema{SchemaID: 1, Type: "struct", Fields: []Field{{ID: 1, Name: "id", Type: "string"}}}
+	data, err := writeManifest(schema, 42, []DataFile{
+		{Path: "s3://bucket/rudder-datalake/ns/tracks/1.parquet", RecordCount: 10, FileSizeInBytes: 1024},
+		{Path: "s3://bucket/rudder-datalake/ns/tracks/2.parquet", RecordCount: 5, FileSizeInBytes: 512},
+	})
+	require.NoError(t, err)
+
+	meta, entries := readManifestEntries(t, data)
+	require.Equal(t, "1", string(meta["schema-id"]))
+	require.Equal(t, "2", string(meta["format-version"]))
+	require.Equal(t, "[]", string(meta["partition-spec"]))
+	require.JSONEq(t, `{"schema-id": 1, "type": "struct", "fields": [{"id": 1, "name": "id", "required": false, "type": "string"}]}`, string(meta["schema"]))
+	_, err = goavro.NewCodec(string(meta["avro.schema"]))
+	require.Error(t, err, "the manifest schema contains the empty partition record")
+
+	require.Len(t, entries, 2)
+	require.Equal(t, int32(manifestStatusAdded), entries[0]["status"])
+	require.Equal(t, map[string]interface{}{"long": int64(42)}, entries[0]["snapshot_id"])
+	require.Nil(t, entries[0]["sequence_number"])
+	require.Equal(t, map[string]interface{}{
+		"content":            int32(0),
+		"file_path":          "s3://bucket/rudder-datalake/ns/tracks/2.parquet",
+		"file_format":        "PARQUET",
+		"record_count":       int64(5),
+		"file_size_in_bytes": int64(512),
+	}, entries[1]["data_file"])
+}
+
+func TestManifestList(t *testing.T) {
+	parentSnapshotID := int64(1)
+	manifests := []manifestFile{
+		{Path: "s3://bucket/metadata/2-m0.avro", Length: 100, SequenceNumber: 2, MinSequenceNumber: 2, AddedSnapshotID: 2, AddedFilesCount: 1, AddedRowsCount: 5},
+		{Path: "s3://bucket/metadata/1-m0.avro", Length: 200, SequenceNumber: 1, MinSequenceNumber: 1, AddedSnapshotID: 1, AddedFilesCount: 3, AddedRowsCount: 30},
+	}
+	data, err := writeManifestList(2, &parentSnapshotID, 2, manifests)
+	require.NoError(t, err)
+
+	reader, err := goavro.NewOCFReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	require.Equal(t, "1", string(reader.MetaData()["parent-snapshot-id"]))
+
+	read, err := readManifestList(data)
+	require.NoError(t, err)
+	require.Equal(t, manifests, read)
+
+	data, err = writeManifestList(1, nil, 1, nil)
+	require.NoError(t, err)
+	read, err = readManifestList(data)
+	require.NoError(t, err)
+	require.Empty(t, read)
+}
@@ -0,0 +1,284 @@
+package iceberg
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/gofrs/uuid"
+
+	warehouseutils "github.com/rudderlabs/rudder-server/warehouse/utils"
+)
+
+const (
+	formatVersion = 2
+	mainBranch    = "main"
+
+	// nameMappingProperty maps the columns of the parquet load files, which carry no field ids, to the fields of the schema
+	nameMappingProperty = "schema.name-mapping.default"
+)
+
+// dataTypesMap maps the rudder data types to the iceberg primitive types of the columns written in the parquet load files
+var dataTypesMap = map[string]string{
+	"boolean":  "boolean",
+	"int":      "long",
+	"bigint":   "long",
+	"float":    "double",
+	"string":   "string",
+	"text":     "string",
+	"json":     "string",
+	"datetime": "timestamptz",
+}
+
+var dataTypesMapToRudder = map[string]string{
+	"boolean":     "boolean",
+	"int":         "int",
+	"long":        "int",
+	"float":       "float",
+	"double":      "float",
+	"string":      "string",
+	"timestamp":   "datetime",
+	"timestamptz": "datetime",
+}
+
+// TableMetadata is the iceberg table metadata of format version 2
+type TableMetadata struct {
+	FormatVersion      int                    `json:"format-version"`
+	TableUUID          string                 `json:"table-uuid"`
+	Location           string                 `json:"location"`
+	LastSequenceNumber int64                  `json:"last-sequence-number"`
+	LastUpdatedMs      int64                  `json:"last-updated-ms"`
+	LastColumnID       int                    `json:"last-column-id"`
+	Schemas            []Schema               `json:"schemas"`
+	CurrentSchemaID    int                    `json:"current-schema-id"`
+	PartitionSpecs     []PartitionSpec        `json:"partition-specs"`
+	DefaultSpecID      int                    `json:"default-spec-id"`
+	LastPartitionID    int                    `json:"last-partition-id"`
+	Properties         map[string]string      `json:"properties,omitempty"`
+	CurrentSnapshotID  *int64                 `json:"current-snapshot-id,omitempty"`
+	Snapshots          []Snapshot             `json:"snapshots,omitempty"`
+	SnapshotLog        []SnapshotLogEntry     `json:"snapshot-log,omitempty"`
+	MetadataLog        []MetadataLogEntry     `json:"metadata-log,omitempty"`
+	SortOrders         []SortOrder            `json:"sort-orders"`
+	DefaultSortOrderID int                    `json:"default-sort-order-id"`
+	Refs               map[string]SnapshotRef `json:"refs,omitempty"`
+}
+
+type Schema struct {
+	SchemaID int     `json:"schema-id"`
+	Type     string  `json:"type"`
+	Fields   []Field `json:"fields"`
+}
+
+type Field struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Required bool   `json:"required"`
+	Type     string `json:"type"`
+}
+
+type PartitionSpec struct {
+	SpecID int           `json:"spec-id"`
+	Fields []interface{} `json:"fields"`
+}
+
+type SortOrder struct {
+	OrderID int           `json:"order-id"`
+	Fields  []interface{} `json:"fields"`
+}
+
+type Snapshot struct {
+	SnapshotID       int64             `json:"snapshot-id"`
+	ParentSnapshotID *int64            `json:"parent-snapshot-id,omitempty"`
+	SequenceNumber   int64             `json:"sequence-number"`
+	TimestampMs      int64             `json:"timestamp-ms"`
+	ManifestList     string            `json:"manifest-list"`
+	Summary          map[string]string `json:"summary"`
+	SchemaID         *int              `json:"schema-id,omitempty"`
+}
+
+type SnapshotLogEntry struct {
+	SnapshotID  int64 `json:"snapshot-id"`
+	TimestampMs int64 `json:"timestamp-ms"`
+}
+
+type MetadataLogEntry struct {
+	MetadataFile string `json:"metadata-file"`
+	TimestampMs  int64  `json:"timestamp-ms"`
+}
+
+type SnapshotRef struct {
+	SnapshotID int64  `json:"snapshot-id"`
+	Type       string `json:"type"`
+}
+
+// NewTableMetadata returns the metadata of an unpartitioned table without snapshots
+func NewTableMetadata(location string, tableSchema warehouseutils.TableSchemaT) *TableMetadata {
+	metadata := &TableMetadata{
+		FormatVersion:  formatVersion,
+		TableUUID:      uuid.Must(uuid.NewV4()).String(),
+		Location:       location,
+		Schemas:        []Schema{{SchemaID: 0, Type: "struct", Fields: []Field{}}},
+		PartitionSpecs: []PartitionSpec{{SpecID: 0, Fields: []interface{}{}}},
+		// partition field ids start at 1000
+		LastPartitionID: 999,
+		SortOrders:      []SortOrder{{OrderID: 0, Fields: []interface{}{}}},
+		Properties:      map[string]string{},
+	}
+	metadata.EvolveSchema(tableSchema)
+	return metadata
+}
+
+// Clone returns a deep copy of the metadata, so that the base of a commit is left untouched
+func (m *TableMetadata) Clone() (*TableMetadata, error) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	var clone TableMetadata
+	if err = json.Unmarshal(data, &clone); err != nil {
+		return nil, err
+	}
+	if clone.Properties == nil {
+		clone.Properties = map[string]string{}
+	}
+	return &clone, nil
+}
+
+// CurrentSchema returns the schema with the current schema id
+func (m *TableMetadata) CurrentSchema() Schema {
+	for _, schema := range m.Schemas {
+		if schema.SchemaID == m.CurrentSchemaID {
+			return schema
+		}
+	}
+	return Schema{Type: "struct", Fields: []Field{}}
+}
+
+// CurrentSnapshot returns the snapshot referenced by the current snapshot id, if any
+func (m *TableMetadata) CurrentSnapshot() *Snapshot {
+	if m.CurrentSnapshotID == nil {
+		return nil
+	}
+	for i := range m.Snapshots {
+		if m.Snapshots[i].SnapshotID == *m.CurrentSnapshotID {
+			return &m.Snapshots[i]
+		}
+	}
+	return nil
+}
+
+// EvolveSchema adds the columns of tableSchema missing in the current schema as optional fields of a new schema.
+// Iceberg cannot change long and double fields to the types of the columns evolved in the warehouse,
+// so the type of existing fields is kept. It returns whether a new schema has been added.
+func (m *TableMetadata) EvolveSchema(tableSchema warehouseutils.TableSchemaT) bool {
+	current := m.CurrentSchema()
+	existing := make(map[string]struct{}, len(current.Fields))
+	for _, field := range current.Fields {
+		existing[field.Name] = struct{}{}
+	}
+
+	fields := append([]Field{}, current.Fields...)
+	for _, columnName := range warehouseutils.SortColumnKeysFromColumnMap(tableSchema) {
+		if _, ok := existing[columnName]; ok {
+			continue
+		}
+		dataType, ok := dataTypesMap[tableSchema[columnName]]
+		if !ok {
+			continue
+		}
+		m.LastColumnID++
+		fields = append(fields, Field{ID: m.LastColumnID, Name: columnName, Type: dataType})
+	}
+	if len(fields) == len(current.Fields) && len(m.Schemas) > 0 && len(current.Fields) > 0 {
+		return false
+	}
+
+	schemaID := 0
+	for _, schema := range m.Schemas {
+		if schema.SchemaID >= schemaID {
+			schemaID = schema.SchemaID + 1
+		}
+	}
+	if len(current.Fields) == 0 {
+		// the initial empty schema is replaced instead of kept in the history
+		schemaID = current.SchemaID
+		m.Schemas = m.Schemas[:0]
+	}
+	m.Schemas = append(m.Schemas, Schema{SchemaID: schemaID, Type: "struct", Fields: fields})
+	m.CurrentSchemaID = schemaID
+	m.Properties[nameMappingProperty] = nameMapping(fields)
+	return true
+}
+
+// nameMapping returns the default name mapping of the fields as json
+func nameMapping(fields []Field) string {
+	type mappedField struct {
+		FieldID int      `json:"field-id"`
+		Names   []string `json:"names"`
+	}
+	mapping := make([]mappedField, 0, len(fields))
+	for _, field := range fields {
+		mapping = append(mapping, mappedField{FieldID: field.ID, Names: []string{field.Name}})
+	}
+	data, _ := json.Marshal(mapping)
+	return string(data)
+}
+
+// Schema returns the rudder schema of the current iceberg schema
+func (m *TableMetadata) Schema() warehouseutils.TableSchemaT {
+	tableSchema := warehouseutils.TableSchemaT{}
+	for _, field := range m.CurrentSchema().Fields {
+		if dataType, ok := dataTypesMapToRudder[field.Type]; ok {
+			tableSchema[field.Name] = dataType
+		}
+	}
+	return tableSchema
+}
+
+// addSnapshot makes snapshot the current snapshot of the main branch
+func (m *TableMetadata) addSnapshot(snapshot Snapshot) {
+	m.Snapshots = append(m.Snapshots, snapshot)
+	m.SnapshotLog = append(m.SnapshotLog, SnapshotLogEntry{SnapshotID: snapshot.SnapshotID, TimestampMs: snapshot.TimestampMs})
+	m.CurrentSnapshotID = &snapshot.SnapshotID
+	m.LastSequenceNumber = snapshot.SequenceNumber
+	m.LastUpdatedMs = snapshot.TimestampMs
+	if m.Refs == nil {
+		m.Refs = map[string]SnapshotRef{}
+	}
+	m.Refs[mainBranch] = SnapshotRef{SnapshotID: snapshot.SnapshotID, Type: "branch"}
+}
+
+// hasCommit returns whether a snapshot has been committed with commitKey
+func (m *TableMetadata) hasCommit(commitKey string) bool {
+	for _, snapshot := range m.Snapshots {
+		if snapshot.Summary[commitKeySummaryProperty] == commitKey {
+			return true
+		}
+	}
+	return false
+}
+
+// metadataFileName returns the name of the next metadata file version
+func (m *TableMetadata) metadataFileName() string {
+	return fmt.Sprintf("metadata/%05d-%s.metadata.json", len(m.MetadataLog)+1, uuid.Must(uuid.NewV4()).String())
+}
+
+// summaryInt returns the summary property of the snapshot as a number
+func summaryInt(snapshot *Snapshot, property string) int64 {
+	if snapshot == nil {
+		return 0
+	}
+	value, _ := strconv.ParseInt(snapshot.Summary[property], 10, 64)
+	return value
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,65 @@
+package iceberg
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	warehouseutils "github.com/rudderlabs/rudder-server/warehouse/utils"
+)
+
+func TestNewTableMetadata(t *testing.T) {
+	metadata := NewTableMetadata("s3://bucket/rudder-datalake/ns/tracks", warehouseutils.TableSchemaT{
+		"id":          "string",
+		"received_at": "datetime",
+		"revenue":     "float",
+		"count":       "int",
+	})
+
+	require.Equal(t, 2, metadata.FormatVersion)
+	require.Len(t, metadata.Schemas, 1)
+	require.Equal(t, 0, metadata.CurrentSchemaID)
+	require.Equal(t, 4, metadata.LastColumnID)
+	require.Equal(t, []Field{
+		{ID: 1, Name: "count", Type: "long"},
+		{ID: 2, Name: "id", Type: "string"},
+		{ID: 3, Name: "received_at", Type: "timestamptz"},
+		{ID: 4, Name: "revenue", Type: "double"},
+	}, metadata.CurrentSchema().Fields)
+	require.Nil(t, metadata.CurrentSnapshot())
+	require.JSONEq(t, `[
+		{"field-id": 1, "names": ["count"]},
+		{"field-id": 2, "names": ["id"]},
+		{"field-id": 3, "names": ["received_at"]},
+		{"field-id": 4, "names": ["revenue"]}
+	]`, metadata.Properties[nameMappingProperty])
+
+	data, err := json.Marshal(metadata)
+	require.NoError(t, err)
+	var unmarshalled TableMetadata
+	require.NoError(t, json.Unmarshal(data, &unmarshalled))
+	require.Equal(t, metadata, &unmarshalled)
+}
+
+func TestEvolveSchema(t *testing.T) {
+	metadata := NewTableMetadata("s3://bucket/rudder-datalake/ns/tracks", warehouseutils.TableSchemaT{
+		"id":    "string",
+		"count": "int",
+	})
+	base, err := metadata.Clone()
+	require.NoError(t, err)
+
+	require.False(t, metadata.EvolveSchema(warehouseutils.TableSchemaT{"id": "string", "count": "int"}))
+	// the type of existing fields is kept, since iceberg cannot promote long to double
+	require.False(t, metadata.EvolveSchema(warehouseutils.TableSchemaT{"count": "float"}))
+	require.True(t, metadata.EvolveSchema(warehouseutils.TableSchemaT{"id": "string", "count": "float", "context_ip": "string"}))
+
+	require.Len(t, metadata.Schemas, 2)
+	require.Equal(t, 1, metadata.CurrentSchemaID)
+	require.Equal(t, 3, metadata.LastColumnID)
+	require.Equal(t, Field{ID: 3, Name: "context_ip", Type: "string"}, metadata.CurrentSchema().Fields[2])
+	require.Equal(t, warehouseutils.TableSchemaT{"id": "string", "count": "int", "context_ip": "string"}, metadata.Schema())
+
+	require.Len(t, base.Schemas, 1, "evolving the schema of a clone must not change the base")
+}
@@ -0,0 +1,123 @@
+package iceberg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// objectStorageCatalogLock serializes the commits of the object storage catalogs of the process
+var objectStorageCatalogLock sync.Mutex
+
+// ObjectStorageCatalog is a file based catalog, which keeps a pointer to the current metadata file of every table
+// in the object storage holding the tables, e.g. s3://bucket/rudder-iceberg-catalog/<namespace>/<table>.json.
+// Pointers survive restarts and are shared by every process writing the tables. Commits are serialized within a
+// process only, which is enough as long as the uploads of a table are not run concurrently by different processes.
+type ObjectStorageCatalog struct {
+	Location string
+	IO       FileIO
+}
+
+func NewObjectStorageCatalog(location string, io FileIO) *ObjectStorageCatalog {
+	return &ObjectStorageCatalog{Location: strings.TrimSuffix(location, "/"), IO: io}
+}
+
+func (oc *ObjectStorageCatalog) pointerLocation(ident TableIdentifier) (location, name string) {
+	return oc.Location + "/" + ident.Namespace, ident.Name + ".json"
+}
+
+// readPointer returns the location of the current metadata file of the table
+func (oc *ObjectStorageCatalog) readPointer(ctx context.Context, ident TableIdentifier) (string, error) {
+	location, name := oc.pointerLocation(ident)
+	data, err := oc.IO.Read(ctx, location+"/"+name)
+	if errors.Is(err, ErrNoSuchFile) {
+		return "", ErrNoSuchTable
+	}
+	if err != nil {
+		return "", fmt.Errorf("reading pointer of table %s: %w", ident, err)
+	}
+	var pointer localTablePointer
+	if err = json.Unmarshal(data, &pointer); err != nil {
+		return "", fmt.Errorf("unmarshalling pointer of table %s: %w", ident, err)
+	}
+	return pointer.MetadataLocation, nil
+}
+
+func (oc *ObjectStorageCatalog) LoadTable(ctx context.Context, ident TableIdentifier) (*Table, error) {
+	metadataLocation, err := oc.readPointer(ctx, ident)
+	if err != nil {
+		return nil, err
+	}
+	data, err := oc.IO.Read(ctx, metadataLocation)
+	if err != nil {
+		return nil, fmt.Errorf("reading metadata %s of table %s: %w", metadataLocation, ident, err)
+	}
+	var metadata TableMetadata
+	if err = json.Unmarshal(data, &metadata); err != nil {
+		return nil, fmt.Errorf("unmarshalling metadata %s of table %s: %w", metadataLocation, ident, err)
+	}
+	if metadata.Properties == nil {
+		metadata.Properties = map[string]string{}
+	}
+	return &Table{Identifier: ident, MetadataLocation: metadataLocation, Metadata: &metadata}, nil
+}
+
+func (oc *ObjectStorageCatalog) CreateTable(ctx context.Context, ident TableIdentifier, metadata *TableMetadata) (*Table, error) {
+	objectStorageCatalogLock.Lock()
+	defer objectStorageCatalogLock.Unlock()
+
+	_, err := oc.readPointer(ctx, ident)
+	if err == nil {
+		return nil, ErrTableExists
+	}
+	if !errors.Is(err, ErrNoSuchTable) {
+		return nil, err
+	}
+	return oc.writeTable(ctx, ident, metadata)
+}
+
+func (oc *ObjectStorageCatalog) CommitTable(ctx context.Context, base *Table, metadata *TableMetadata) (*Table, error) {
+	objectStorageCatalogLock.Lock()
+	defer objectStorageCatalogLock.Unlock()
+
+	metadataLocation, err := oc.readPointer(ctx, base.Identifier)
+	if err != nil {
+		return nil, err
+	}
+	if metadataLocation != base.MetadataLocation {
+		return nil, ErrCommitFailed
+	}
+
+	metadata, err = metadata.Clone()
+	if err != nil {
+		return nil, err
+	}
+	metadata.MetadataLog = append(metadata.MetadataLog, MetadataLogEntry{MetadataFile: base.MetadataLocation, TimestampMs: base.Metadata.LastUpdatedMs})
+	return oc.writeTable(ctx, base.Identifier, metadata)
+}
+
+// writeTable writes the metadata file and then points the table to it
+func (oc *ObjectStorageCatalog) writeTable(ctx context.Context, ident TableIdentifier, metadata *TableMetadata) (*Table, error) {
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, err
+	}
+	metadataLocation, err := oc.IO.Write(ctx, metadata.Location, metadata.metadataFileName(), data)
+	if err != nil {
+		return nil, fmt.Errorf("writing metadata of table %s: %w", ident, err)
+	}
+
+	data, err = json.Marshal(localTablePointer{MetadataLocation: metadataLocation})
+	if err != nil {
+		return nil, err
+	}
+	// objects are replaced as a whole, so that readers never see a partially written pointer
+	location, name := oc.pointerLocation(ident)
+	if _, err = oc.IO.Write(ctx, location, name, data); err != nil {
+		return nil, fmt.Errorf("writing pointer of table %s: %w", ident, err)
+	}
+	return &Table{Identifier: ident, MetadataLocation: metadataLocation, Metadata: metadata}, nil
+}
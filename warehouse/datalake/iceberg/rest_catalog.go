@@ -0,0 +1,217 @@
+package iceberg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// RESTCatalog is a client of the subset of the iceberg REST catalog API needed to create and append to tables
+type RESTCatalog struct {
+	URL    string
+	Token  string
+	Client *http.Client
+}
+
+func NewRESTCatalog(baseURL, token string, timeout time.Duration) *RESTCatalog {
+	return &RESTCatalog{
+		URL:    strings.TrimSuffix(baseURL, "/"),
+		Token:  token,
+		Client: &http.Client{Timeout: timeout},
+	}
+}
+
+type restLoadTableResult struct {
+	MetadataLocation string         `json:"metadata-location"`
+	Metadata         *TableMetadata `json:"metadata"`
+}
+
+type restCreateTableRequest struct {
+	Name          string            `json:"name"`
+	Location      string            `json:"location,omitempty"`
+	Schema        Schema            `json:"schema"`
+	PartitionSpec PartitionSpec     `json:"partition-spec"`
+	WriteOrder    SortOrder         `json:"write-order"`
+	Properties    map[string]string `json:"properties,omitempty"`
+}
+
+type restCommitTableRequest struct {
+	Requirements []map[string]interface{} `json:"requirements"`
+	Updates      []map[string]interface{} `json:"updates"`
+}
+
+type restErrorResponse struct {
+	Error struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+		Code    int    `json:"code"`
+	} `json:"error"`
+}
+
+func (rc *RESTCatalog) tablesPath(namespace string) string {
+	return fmt.Sprintf("%s/v1/namespaces/%s/tables", rc.URL, url.PathEscape(namespace))
+}
+
+func (rc *RESTCatalog) tablePath(ident TableIdentifier) string {
+	return fmt.Sprintf("%s/%s", rc.tablesPath(ident.Namespace), url.PathEscape(ident.Name))
+}
+
+// do sends the request and decodes the response into out, returning the status code of failed requests along with the error
+func (rc *RESTCatalog) do(ctx context.Context, method, path string, body, out interface{}) (int, error) {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, path, reqBody)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if rc.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+rc.Token)
+	}
+
+	resp, err := rc.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var errResp restErrorResponse
+		_ = json.Unmarshal(respBody, &errResp)
+		return resp.StatusCode, fmt.Errorf("%s %s: %d %s: %s", method, path, resp.StatusCode, errResp.Error.Type, errResp.Error.Message)
+	}
+	if out == nil {
+		return resp.StatusCode, nil
+	}
+	return resp.StatusCode, json.Unmarshal(respBody, out)
+}
+
+func (rc *RESTCatalog) table(ident TableIdentifier, result restLoadTableResult) (*Table, error) {
+	if result.Metadata == nil {
+		return nil, fmt.Errorf("no metadata returned for table %s", ident)
+	}
+	if result.Metadata.Properties == nil {
+		result.Metadata.Properties = map[string]string{}
+	}
+	return &Table{Identifier: ident, MetadataLocation: result.MetadataLocation, Metadata: result.Metadata}, nil
+}
+
+func (rc *RESTCatalog) LoadTable(ctx context.Context, ident TableIdentifier) (*Table, error) {
+	var result restLoadTableResult
+	statusCode, err := rc.do(ctx, http.MethodGet, rc.tablePath(ident), nil, &result)
+	if statusCode == http.StatusNotFound {
+		return nil, ErrNoSuchTable
+	}
+	if err != nil {
+		return nil, err
+	}
+	return rc.table(ident, result)
+}
+
+func (rc *RESTCatalog) CreateTable(ctx context.Context, ident TableIdentifier, metadata *TableMetadata) (*Table, error) {
+	namespace := map[string]interface{}{"namespace": []string{ident.Namespace}, "properties": map[string]string{}}
+	statusCode, err := rc.do(ctx, http.MethodPost, rc.URL+"/v1/namespaces", namespace, nil)
+	if err != nil && statusCode != http.StatusConflict {
+		return nil, fmt.Errorf("creating namespace %s: %w", ident.Namespace, err)
+	}
+
+	var result restLoadTableResult
+	statusCode, err = rc.do(ctx, http.MethodPost, rc.tablesPath(ident.Namespace), restCreateTableRequest{
+		Name:          ident.Name,
+		Location:      metadata.Location,
+		Schema:        metadata.CurrentSchema(),
+		PartitionSpec: PartitionSpec{SpecID: 0, Fields: []interface{}{}},
+		WriteOrder:    SortOrder{OrderID: 0, Fields: []interface{}{}},
+		Properties:    metadata.Properties,
+	}, &result)
+	if statusCode == http.StatusConflict {
+		return nil, ErrTableExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	return rc.table(ident, result)
+}
+
+func (rc *RESTCatalog) CommitTable(ctx context.Context, base *Table, metadata *TableMetadata) (*Table, error) {
+	var result restLoadTableResult
+	statusCode, err := rc.do(ctx, http.MethodPost, rc.tablePath(base.Identifier), commitRequest(base.Metadata, metadata), &result)
+	switch statusCode {
+	case http.StatusNotFound:
+		return nil, ErrNoSuchTable
+	case http.StatusConflict:
+		return nil, ErrCommitFailed
+	}
+	if err != nil {
+		return nil, err
+	}
+	return rc.table(base.Identifier, result)
+}
+
+// commitRequest returns the requirements on base and the updates turning base into metadata
+func commitRequest(base, metadata *TableMetadata) restCommitTableRequest {
+	var baseSnapshotID interface{}
+	if ref, ok := base.Refs[mainBranch]; ok {
+		baseSnapshotID = ref.SnapshotID
+	}
+	request := restCommitTableRequest{
+		Requirements: []map[string]interface{}{
+			{"type": "assert-table-uuid", "uuid": base.TableUUID},
+			{"type": "assert-ref-snapshot-id", "ref": mainBranch, "snapshot-id": baseSnapshotID},
+		},
+		Updates: []map[string]interface{}{},
+	}
+
+	baseSchemas := make(map[int]struct{}, len(base.Schemas))
+	for _, schema := range base.Schemas {
+		baseSchemas[schema.SchemaID] = struct{}{}
+	}
+	for _, schema := range metadata.Schemas {
+		if _, ok := baseSchemas[schema.SchemaID]; ok {
+			continue
+		}
+		request.Updates = append(request.Updates, map[string]interface{}{"action": "add-schema", "schema": schema, "last-column-id": metadata.LastColumnID})
+	}
+	if metadata.LastColumnID != base.LastColumnID {
+		// the ids of the added fields must not collide with the fields of a concurrently added schema
+		request.Requirements = append(request.Requirements, map[string]interface{}{"type": "assert-last-assigned-field-id", "last-assigned-field-id": base.LastColumnID})
+	}
+	if metadata.CurrentSchemaID != base.CurrentSchemaID {
+		request.Updates = append(request.Updates, map[string]interface{}{"action": "set-current-schema", "schema-id": metadata.CurrentSchemaID})
+	}
+	if !reflect.DeepEqual(base.Properties, metadata.Properties) {
+		request.Updates = append(request.Updates, map[string]interface{}{"action": "set-properties", "updates": metadata.Properties})
+	}
+
+	baseSnapshots := make(map[int64]struct{}, len(base.Snapshots))
+	for _, snapshot := range base.Snapshots {
+		baseSnapshots[snapshot.SnapshotID] = struct{}{}
+	}
+	for _, snapshot := range metadata.Snapshots {
+		if _, ok := baseSnapshots[snapshot.SnapshotID]; ok {
+			continue
+		}
+		request.Updates = append(request.Updates, map[string]interface{}{"action": "add-snapshot", "snapshot": snapshot})
+	}
+	if ref, ok := metadata.Refs[mainBranch]; ok && ref != base.Refs[mainBranch] {
+		request.Updates = append(request.Updates, map[string]interface{}{"action": "set-snapshot-ref", "ref-name": mainBranch, "type": ref.Type, "snapshot-id": ref.SnapshotID})
+	}
+	return request
+}
@@ -3,9 +3,13 @@ package warehouse
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"strings"
+	"time"
+
+	"github.com/lib/pq"
 
 	warehouseutils "github.com/rudderlabs/rudder-server/warehouse/utils"
 )
@@ -24,9 +28,14 @@ func NewWarehouseDB(handle *sql.DB) *DB {
 	return &DB{handle}
 }
 
-func (db *DB) GetLatestUploadStatus(ctx context.Context, destType, sourceID, destinationID string) (int64, string, int, error) {
+func (db *DB) GetLatestUploadStatus(ctx context.Context, destType, sourceID, destinationID string, filterClauses ...FilterClause) (int64, string, int, error) {
 	pkgLogger.Debugf("Fetching latest upload status for: destType: %s, sourceID: %s, destID: %s", destType, sourceID, destinationID)
 
+	clausesQuery, clausesArgs := ClauseQueryArgs(filterClauses...)
+	if clausesQuery != "" {
+		clausesQuery = "AND " + clausesQuery
+	}
+
 	query := fmt.Sprintf(`	
 		SELECT 
 		  id, 
@@ -38,6 +47,7 @@ func (db *DB) GetLatestUploadStatus(ctx context.Context, destType, sourceID, des
 		  UT.destination_type = '%[2]s' 
 		  AND UT.source_id = '%[3]s' 
 		  AND UT.destination_id = '%[4]s' 
+		  %[5]s 
 		ORDER BY 
 		  id DESC 
 		LIMIT 
@@ -47,6 +57,7 @@ func (db *DB) GetLatestUploadStatus(ctx context.Context, destType, sourceID, des
 		destType,
 		sourceID,
 		destinationID,
+		clausesQuery,
 	)
 
 	var (
@@ -55,7 +66,7 @@ func (db *DB) GetLatestUploadStatus(ctx context.Context, destType, sourceID, des
 		priority int
 	)
 
-	err := db.handle.QueryRowContext(ctx, query).Scan(&uploadID, &status, &priority)
+	err := db.handle.QueryRowContext(ctx, query, clausesArgs...).Scan(&uploadID, &status, &priority)
 	if err != nil && err != sql.ErrNoRows {
 		pkgLogger.Errorf(`Error getting latest upload status for warehouse: %v`, err)
 		return 0, "", 0, fmt.Errorf("unable to get latest upload status for warehouse: %w", err)
@@ -140,3 +151,411 @@ func ClauseQueryArgs(filterClauses ...FilterClause) (string, []interface{}) {
 	}
 	return clausesQuery, clausesArgs
 }
+
+// InsertSchemaChanges adds the changes to the schema history
+func (db *DB) InsertSchemaChanges(ctx context.Context, changes []SchemaChangeT) (err error) {
+	txn, err := db.handle.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = txn.Rollback()
+		}
+	}()
+
+	stmt, err := txn.PrepareContext(ctx, pq.CopyIn(warehouseutils.WarehouseSchemaHistoryTable, "workspace_id", "source_id", "destination_id", "destination_type", "namespace", "upload_id", "table_name", "column_name", "change_type", "from_type", "to_type", "staging_file_id", "event_sample", "created_at"))
+	if err != nil {
+		return
+	}
+	defer func() { _ = stmt.Close() }()
+
+	for _, change := range changes {
+		var (
+			stagingFileID sql.NullInt64
+			eventSample   sql.NullString
+		)
+		if change.StagingFileID != 0 {
+			stagingFileID = sql.NullInt64{Int64: change.StagingFileID, Valid: true}
+		}
+		if len(change.EventSample) > 0 {
+			eventSample = sql.NullString{String: string(change.EventSample), Valid: true}
+		}
+		_, err = stmt.ExecContext(ctx, change.WorkspaceID, change.SourceID, change.DestinationID, change.DestinationType, change.Namespace, change.UploadID, change.TableName, change.ColumnName, change.ChangeType, change.FromType, change.ToType, stagingFileID, eventSample, change.CreatedAt)
+		if err != nil {
+			return
+		}
+	}
+	if _, err = stmt.ExecContext(ctx); err != nil {
+		return
+	}
+	return txn.Commit()
+}
+
+// SetSchemaChangeEventSamples sets the event samples of the changes of the columns of the table made by the upload
+func (db *DB) SetSchemaChangeEventSamples(ctx context.Context, uploadID int64, tableName string, samples map[string]json.RawMessage) error {
+	sqlStatement := fmt.Sprintf(`
+		UPDATE
+		  %[1]s
+		SET
+		  event_sample = $4
+		WHERE
+		  upload_id = $1
+		  AND table_name = $2
+		  AND column_name = $3
+		  AND event_sample IS NULL;`,
+		warehouseutils.WarehouseSchemaHistoryTable,
+	)
+	for columnName, sample := range samples {
+		if _, err := db.handle.ExecContext(ctx, sqlStatement, uploadID, tableName, columnName, string(sample)); err != nil {
+			return fmt.Errorf("query: %s failed with Error : %w", sqlStatement, err)
+		}
+	}
+	return nil
+}
+
+// ClearSchemaChangeEventSamples removes the event samples of the changes made before the time, keeping the changes themselves
+func (db *DB) ClearSchemaChangeEventSamples(ctx context.Context, before time.Time) (int64, error) {
+	sqlStatement := fmt.Sprintf(`
+		UPDATE
+		  %[1]s
+		SET
+		  event_sample = NULL
+		WHERE
+		  event_sample IS NOT NULL
+		  AND created_at < $1;`,
+		warehouseutils.WarehouseSchemaHistoryTable,
+	)
+	result, err := db.handle.ExecContext(ctx, sqlStatement, before)
+	if err != nil {
+		return 0, fmt.Errorf("query: %s failed with Error : %w", sqlStatement, err)
+	}
+	return result.RowsAffected()
+}
+
+// GetSchemaChanges returns the changes of the schema history matching the filter clauses along with their total count.
+// Changes are ordered by the time they were made, the latest first unless ascending is set.
+func (db *DB) GetSchemaChanges(ctx context.Context, ascending bool, limit, offset int32, filterClauses ...FilterClause) ([]SchemaChangeT, int64, error) {
+	clausesQuery, clausesArgs := ClauseQueryArgs(filterClauses...)
+	whereClausesQuery := ""
+	if len(clausesArgs) > 0 {
+		whereClausesQuery = fmt.Sprintf(`WHERE %s`, clausesQuery)
+	}
+	order := "DESC"
+	if ascending {
+		order = "ASC"
+	}
+	preparedStatement := fmt.Sprintf(`
+		SELECT
+		  id,
+		  workspace_id,
+		  source_id,
+		  destination_id,
+		  destination_type,
+		  namespace,
+		  upload_id,
+		  table_name,
+		  column_name,
+		  change_type,
+		  from_type,
+		  to_type,
+		  staging_file_id,
+		  event_sample,
+		  created_at,
+		  COUNT(*) OVER() AS total
+		FROM
+		  %[1]s
+		%[2]s
+		ORDER BY
+		  id %[3]s
+		LIMIT
+		  %[4]d OFFSET %[5]d;`,
+		warehouseutils.WarehouseSchemaHistoryTable,
+		whereClausesQuery,
+		order,
+		limit,
+		offset,
+	)
+	pkgLogger.Debugf("[GetSchemaChanges] sqlStatement: %s", preparedStatement)
+
+	rows, err := db.handle.QueryContext(ctx, preparedStatement, clausesArgs...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var (
+		changes []SchemaChangeT
+		total   int64
+	)
+	for rows.Next() {
+		var (
+			change        SchemaChangeT
+			stagingFileID sql.NullInt64
+			eventSample   sql.NullString
+		)
+		err = rows.Scan(
+			&change.ID,
+			&change.WorkspaceID,
+			&change.SourceID,
+			&change.DestinationID,
+			&change.DestinationType,
+			&change.Namespace,
+			&change.UploadID,
+			&change.TableName,
+			&change.ColumnName,
+			&change.ChangeType,
+			&change.FromType,
+			&change.ToType,
+			&stagingFileID,
+			&eventSample,
+			&change.CreatedAt,
+			&total,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+		change.StagingFileID = stagingFileID.Int64
+		if eventSample.Valid {
+			change.EventSample = json.RawMessage(eventSample.String)
+		}
+		changes = append(changes, change)
+	}
+	return changes, total, rows.Err()
+}
+
+// GetSLABreaches returns the SLA breaches matching the filter clauses along with their total count, the latest first.
+// Resolved breaches are only returned if includeResolved is set.
+func (db *DB) GetSLABreaches(ctx context.Context, includeResolved bool, limit, offset int32, filterClauses ...FilterClause) ([]SLABreachT, int64, error) {
+	clausesQuery, clausesArgs := ClauseQueryArgs(filterClauses...)
+	var conditions []string
+	if len(clausesArgs) > 0 {
+		conditions = append(conditions, clausesQuery)
+	}
+	if !includeResolved {
+		conditions = append(conditions, `resolved_at IS NULL`)
+	}
+	whereClausesQuery := ""
+	if len(conditions) > 0 {
+		whereClausesQuery = fmt.Sprintf(`WHERE %s`, strings.Join(conditions, " AND "))
+	}
+	preparedStatement := fmt.Sprintf(`
+		SELECT
+		  id,
+		  workspace_id,
+		  source_id,
+		  destination_id,
+		  destination_type,
+		  namespace,
+		  breach_type,
+		  message,
+		  started_at,
+		  alerted_at,
+		  resolved_at,
+		  COUNT(*) OVER() AS total
+		FROM
+		  %[1]s
+		%[2]s
+		ORDER BY
+		  id DESC
+		LIMIT
+		  %[3]d OFFSET %[4]d;`,
+		warehouseutils.WarehouseSLABreachesTable,
+		whereClausesQuery,
+		limit,
+		offset,
+	)
+	pkgLogger.Debugf("[GetSLABreaches] sqlStatement: %s", preparedStatement)
+
+	rows, err := db.handle.QueryContext(ctx, preparedStatement, clausesArgs...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var (
+		breaches []SLABreachT
+		total    int64
+	)
+	for rows.Next() {
+		var (
+			breach     SLABreachT
+			alertedAt  sql.NullTime
+			resolvedAt sql.NullTime
+		)
+		err = rows.Scan(
+			&breach.ID,
+			&breach.WorkspaceID,
+			&breach.SourceID,
+			&breach.DestinationID,
+			&breach.DestinationType,
+			&breach.Namespace,
+			&breach.BreachType,
+			&breach.Message,
+			&breach.StartedAt,
+			&alertedAt,
+			&resolvedAt,
+			&total,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+		breach.AlertedAt = alertedAt.Time
+		breach.ResolvedAt = resolvedAt.Time
+		breaches = append(breaches, breach)
+	}
+	return breaches, total, rows.Err()
+}
+
+// GetUsage returns the daily usage matching the filter clauses along with their total count, the latest day first
+func (db *DB) GetUsage(ctx context.Context, limit, offset int32, filterClauses ...FilterClause) ([]UsageT, int64, error) {
+	clausesQuery, clausesArgs := ClauseQueryArgs(filterClauses...)
+	whereClausesQuery := ""
+	if len(clausesArgs) > 0 {
+		whereClausesQuery = fmt.Sprintf(`WHERE %s`, clausesQuery)
+	}
+	preparedStatement := fmt.Sprintf(`
+		SELECT
+		  workspace_id,
+		  source_id,
+		  destination_id,
+		  destination_type,
+		  namespace,
+		  table_name,
+		  day,
+		  uploads,
+		  rows,
+		  bytes,
+		  load_duration_ms,
+		  COUNT(*) OVER() AS total
+		FROM
+		  %[1]s
+		%[2]s
+		ORDER BY
+		  day DESC,
+		  id DESC
+		LIMIT
+		  %[3]d OFFSET %[4]d;`,
+		warehouseutils.WarehouseUsageTable,
+		whereClausesQuery,
+		limit,
+		offset,
+	)
+	pkgLogger.Debugf("[GetUsage] sqlStatement: %s", preparedStatement)
+
+	rows, err := db.handle.QueryContext(ctx, preparedStatement, clausesArgs...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var (
+		usages []UsageT
+		total  int64
+	)
+	for rows.Next() {
+		var (
+			usage          UsageT
+			loadDurationMs int64
+		)
+		err = rows.Scan(
+			&usage.WorkspaceID,
+			&usage.SourceID,
+			&usage.DestinationID,
+			&usage.DestinationType,
+			&usage.Namespace,
+			&usage.TableName,
+			&usage.Day,
+			&usage.Uploads,
+			&usage.Rows,
+			&usage.Bytes,
+			&loadDurationMs,
+			&total,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+		usage.LoadDuration = time.Duration(loadDurationMs) * time.Millisecond
+		usages = append(usages, usage)
+	}
+	return usages, total, rows.Err()
+}
+
+// GetUsageSummary returns the usage matching the filter clauses summed by source and destination, and by table if byTable is set,
+// along with the total count of the sums. Sums are ordered by bytes, the largest first.
+func (db *DB) GetUsageSummary(ctx context.Context, byTable bool, limit, offset int32, filterClauses ...FilterClause) ([]UsageT, int64, error) {
+	clausesQuery, clausesArgs := ClauseQueryArgs(filterClauses...)
+	whereClausesQuery := ""
+	if len(clausesArgs) > 0 {
+		whereClausesQuery = fmt.Sprintf(`WHERE %s`, clausesQuery)
+	}
+	tableColumn := `''`
+	groupBy := `source_id, destination_id, destination_type`
+	if byTable {
+		tableColumn = `table_name`
+		groupBy += `, table_name`
+	}
+	preparedStatement := fmt.Sprintf(`
+		SELECT
+		  source_id,
+		  destination_id,
+		  destination_type,
+		  %[3]s,
+		  SUM(uploads),
+		  SUM(rows),
+		  SUM(bytes),
+		  SUM(load_duration_ms),
+		  COUNT(*) OVER() AS total
+		FROM
+		  %[1]s
+		%[2]s
+		GROUP BY
+		  %[4]s
+		ORDER BY
+		  SUM(bytes) DESC,
+		  %[4]s
+		LIMIT
+		  %[5]d OFFSET %[6]d;`,
+		warehouseutils.WarehouseUsageTable,
+		whereClausesQuery,
+		tableColumn,
+		groupBy,
+		limit,
+		offset,
+	)
+	pkgLogger.Debugf("[GetUsageSummary] sqlStatement: %s", preparedStatement)
+
+	rows, err := db.handle.QueryContext(ctx, preparedStatement, clausesArgs...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var (
+		usages []UsageT
+		total  int64
+	)
+	for rows.Next() {
+		var (
+			usage          UsageT
+			loadDurationMs int64
+		)
+		err = rows.Scan(
+			&usage.SourceID,
+			&usage.DestinationID,
+			&usage.DestinationType,
+			&usage.TableName,
+			&usage.Uploads,
+			&usage.Rows,
+			&usage.Bytes,
+			&loadDurationMs,
+			&total,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+		usage.LoadDuration = time.Duration(loadDurationMs) * time.Millisecond
+		usages = append(usages, usage)
+	}
+	return usages, total, rows.Err()
+}
@@ -104,11 +104,11 @@ var excludeColumnsMap = map[string]bool{
 	"event_date": true,
 }
 
-// Primary Key mappings for tables
-var primaryKeyMap = map[string]string{
-	warehouseutils.UsersTable:      "id",
-	warehouseutils.IdentifiesTable: "id",
-	warehouseutils.DiscardsTable:   "row_id",
+// Primary Key mappings for tables, defaulting to id
+var primaryKeyMap = map[string][]string{
+	warehouseutils.UsersTable:      {"id"},
+	warehouseutils.IdentifiesTable: {"id"},
+	warehouseutils.DiscardsTable:   {"row_id"},
 }
 
 type HandleT struct {
@@ -270,6 +270,36 @@ func (*HandleT) DeleteBy([]string, warehouseutils.DeleteByParams) error {
 	return fmt.Errorf(warehouseutils.NotImplementedErrorCode)
 }
 
+// PruneTable deletes the rows of the table older than the retention and vacuums the table,
+// so that the data files which are no longer referenced by the table are removed from the object storage.
+func (dl *HandleT) PruneTable(ctx context.Context, tableName string, params warehouseutils.RetentionParamsT) (stats warehouseutils.RetentionStatsT, err error) {
+	pkgLogger.Infof("%s Pruning rows with %s before %s in table %s", dl.GetLogIdentifier(tableName), params.Column, params.Before, tableName)
+	stats.Method = warehouseutils.RetentionMethodDeleteVacuum
+	condition := fmt.Sprintf(`%s < '%s'`, params.Column, params.Before.UTC().Format(time.RFC3339))
+
+	response, err := dl.dbHandleT.Client.FetchTotalCountInTable(ctx, &proto.FetchTotalCountInTableRequest{
+		Config:       dl.dbHandleT.CredConfig,
+		Identifier:   dl.dbHandleT.CredIdentifier,
+		SqlStatement: fmt.Sprintf(`SELECT COUNT(*) FROM %s.%s WHERE %s;`, dl.Namespace, tableName, condition),
+	})
+	if err != nil {
+		return stats, fmt.Errorf("%s Error while counting rows to delete: %v", dl.GetLogIdentifier(tableName), err)
+	}
+	if !checkAndIgnoreAlreadyExistError(response.GetErrorCode(), tableOrViewNotFound) {
+		return stats, fmt.Errorf("%s Error while counting rows to delete with response: %v", dl.GetLogIdentifier(tableName), response.GetErrorMessage())
+	}
+	stats.RowsDeleted = response.GetCount()
+	if stats.RowsDeleted == 0 {
+		return stats, nil
+	}
+
+	if err = dl.ExecuteSQL(fmt.Sprintf(`DELETE FROM %s.%s WHERE %s;`, dl.Namespace, tableName, condition), "PruneTable"); err != nil {
+		return stats, err
+	}
+	err = dl.ExecuteSQL(fmt.Sprintf(`VACUUM %s.%s;`, dl.Namespace, tableName), "VacuumTable")
+	return stats, err
+}
+
 // fetchTables fetch tables with tableNames
 func (dl *HandleT) fetchTables(dbT *databricks.DBHandleT, schema string) (tableNames []string, err error) {
 	fetchTablesExecTime := stats.Default.NewTaggedStat("warehouse.deltalake.grpcExecTime", stats.TimerType, map[string]string{
@@ -606,12 +636,14 @@ func (dl *HandleT) loadTable(tableName string, tableSchemaInUpload, tableSchemaA
 		return
 	}
 
-	if loadTableStrategy == "APPEND" {
+	loadMode := dl.loadMode()
+	if loadMode == warehouseutils.LoadModeAppend {
 		sqlStatement = appendLoadTableSQLStatement(
 			dl.Namespace,
 			tableName,
 			stagingTableName,
 			warehouseutils.SortColumnKeysFromColumnMap(tableSchemaAfterUpload),
+			dl.primaryKeys(tableName),
 		)
 	} else {
 		// Partition query
@@ -627,13 +659,14 @@ func (dl *HandleT) loadTable(tableName string, tableSchemaInUpload, tableSchemaA
 			tableName,
 			stagingTableName,
 			sortedColumnKeys,
+			dl.primaryKeys(tableName),
 			partitionQuery,
 		)
 	}
 	pkgLogger.Infof("%v Inserting records using staging table with SQL: %s\n", dl.GetLogIdentifier(tableName), sqlStatement)
 
 	// Executing load table sql statement
-	err = dl.ExecuteSQL(sqlStatement, fmt.Sprintf("LT::%s", strcase.ToCamel(loadTableStrategy)))
+	err = dl.ExecuteSQL(sqlStatement, fmt.Sprintf("LT::%s", strcase.ToCamel(loadMode)))
 	if err != nil {
 		pkgLogger.Errorf("%v Error inserting into original table: %v\n", dl.GetLogIdentifier(tableName), err)
 		return
@@ -643,6 +676,24 @@ func (dl *HandleT) loadTable(tableName string, tableSchemaInUpload, tableSchemaA
 	return
 }
 
+// loadMode returns the load mode of the destination, defaulting to the configured load table strategy
+func (dl *HandleT) loadMode() string {
+	defaultMode := warehouseutils.LoadModeMerge
+	if loadTableStrategy == "APPEND" {
+		defaultMode = warehouseutils.LoadModeAppend
+	}
+	return dl.Warehouse.LoadMode(defaultMode)
+}
+
+// primaryKeys returns the columns deduplicating the rows of tableName
+func (dl *HandleT) primaryKeys(tableName string) []string {
+	keys, ok := primaryKeyMap[tableName]
+	if !ok {
+		keys = []string{"id"}
+	}
+	return dl.Warehouse.MergePrimaryKeys(tableName, keys)
+}
+
 // loadUserTables Loads users table
 func (dl *HandleT) loadUserTables() (errorMap map[string]error) {
 	// Creating errorMap
@@ -719,12 +770,14 @@ func (dl *HandleT) loadUserTables() (errorMap map[string]error) {
 	// Creating the column Keys
 	columnKeys := append([]string{`id`}, userColNames...)
 
-	if loadTableStrategy == "APPEND" {
+	loadMode := dl.loadMode()
+	if loadMode == warehouseutils.LoadModeAppend {
 		sqlStatement = appendLoadTableSQLStatement(
 			dl.Namespace,
 			warehouseutils.UsersTable,
 			stagingTableName,
 			columnKeys,
+			dl.primaryKeys(warehouseutils.UsersTable),
 		)
 	} else {
 		// Partition query
@@ -741,13 +794,14 @@ func (dl *HandleT) loadUserTables() (errorMap map[string]error) {
 			warehouseutils.UsersTable,
 			stagingTableName,
 			columnKeys,
+			dl.primaryKeys(warehouseutils.UsersTable),
 			partitionQuery,
 		)
 	}
 	pkgLogger.Infof("%s Inserting records using staging table with SQL: %s\n", dl.GetLogIdentifier(warehouseutils.UsersTable), sqlStatement)
 
 	// Executing the load users table sql statement
-	err = dl.ExecuteSQL(sqlStatement, fmt.Sprintf("LUT::%s", strcase.ToCamel(loadTableStrategy)))
+	err = dl.ExecuteSQL(sqlStatement, fmt.Sprintf("LUT::%s", strcase.ToCamel(loadMode)))
 	if err != nil {
 		pkgLogger.Errorf("%s Error inserting into users table from staging table: %v\n", err)
 		errorMap[warehouseutils.UsersTable] = err
@@ -903,8 +957,8 @@ func (dl *HandleT) CreateSchema() (err error) {
 }
 
 // AlterColumn alter table with column name and type
-func (*HandleT) AlterColumn(_, _, _ string) (err error) {
-	return
+func (*HandleT) AlterColumn(_, _, _ string) (warehouseutils.AlterColumnResponseT, error) {
+	return warehouseutils.AlterColumnResponseT{}, nil
 }
 
 // FetchSchema queries delta lake and returns the schema associated with provided namespace
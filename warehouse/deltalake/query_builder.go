@@ -2,20 +2,12 @@ package deltalake
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/rudderlabs/rudder-server/warehouse/utils"
 )
 
-func primaryKey(tableName string) string {
-	key := "id"
-	if column, ok := primaryKeyMap[tableName]; ok {
-		key = column
-	}
-	return key
-}
-
-func stagingSqlStatement(namespace, tableName, stagingTableName string, columnKeys []string) (sqlStatement string) {
-	pk := primaryKey(tableName)
+func stagingSqlStatement(namespace, tableName, stagingTableName string, columnKeys, primaryKeys []string) (sqlStatement string) {
 	if tableName == warehouseutils.UsersTable {
 		sqlStatement = fmt.Sprintf(`
 			SELECT
@@ -48,21 +40,23 @@ func stagingSqlStatement(namespace, tableName, stagingTableName string, columnKe
 		`,
 			namespace,
 			stagingTableName,
-			pk,
+			strings.Join(primaryKeys, ", "),
 		)
 	}
 	return
 }
 
 // mergeLoadTableSQLStatement merge load table sql statement
-func mergeLoadTableSQLStatement(namespace, tableName, stagingTableName string, columnKeys []string, partitionQuery string) (sqlStatement string) {
-	pk := primaryKey(tableName)
+func mergeLoadTableSQLStatement(namespace, tableName, stagingTableName string, columnKeys, primaryKeys []string, partitionQuery string) (sqlStatement string) {
 	if partitionQuery != "" {
 		partitionQuery += " AND"
 	}
-	stagingTableSqlStatement := stagingSqlStatement(namespace, tableName, stagingTableName, columnKeys)
+	stagingTableSqlStatement := stagingSqlStatement(namespace, tableName, stagingTableName, columnKeys, primaryKeys)
+	primaryJoinClause := warehouseutils.JoinWithFormatting(primaryKeys, func(_ int, key string) string {
+		return fmt.Sprintf(`MAIN.%[1]s = STAGING.%[1]s`, key)
+	}, " AND ")
 	sqlStatement = fmt.Sprintf(`
-		MERGE INTO %[1]s.%[2]s AS MAIN USING (%[3]s) AS STAGING ON %[8]s MAIN.%[4]s = STAGING.%[4]s
+		MERGE INTO %[1]s.%[2]s AS MAIN USING (%[3]s) AS STAGING ON %[8]s %[4]s
 		WHEN MATCHED THEN
 		UPDATE
 		SET
@@ -76,7 +70,7 @@ func mergeLoadTableSQLStatement(namespace, tableName, stagingTableName string, c
 		namespace,
 		tableName,
 		stagingTableSqlStatement,
-		pk,
+		primaryJoinClause,
 		columnsWithValues(columnKeys),
 		columnNames(columnKeys),
 		stagingColumnNames(columnKeys),
@@ -86,8 +80,8 @@ func mergeLoadTableSQLStatement(namespace, tableName, stagingTableName string, c
 }
 
 // appendLoadTableSQLStatement append load table sql statement
-func appendLoadTableSQLStatement(namespace, tableName, stagingTableName string, columnKeys []string) (sqlStatement string) {
-	stagingTableSqlStatement := stagingSqlStatement(namespace, tableName, stagingTableName, columnKeys)
+func appendLoadTableSQLStatement(namespace, tableName, stagingTableName string, columnKeys, primaryKeys []string) (sqlStatement string) {
+	stagingTableSqlStatement := stagingSqlStatement(namespace, tableName, stagingTableName, columnKeys, primaryKeys)
 	sqlStatement = fmt.Sprintf(`
 		INSERT INTO %[1]s.%[2]s (%[4]s)
 		SELECT
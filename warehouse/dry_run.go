@@ -0,0 +1,341 @@
+package warehouse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	proto "github.com/rudderlabs/rudder-server/proto/warehouse"
+	"github.com/rudderlabs/rudder-server/utils/misc"
+	"github.com/rudderlabs/rudder-server/warehouse/manager"
+	warehouseutils "github.com/rudderlabs/rudder-server/warehouse/utils"
+)
+
+// operations of the plan of a dry run
+const (
+	DryRunCreateSchema   = "create_schema"
+	DryRunCreateTable    = "create_table"
+	DryRunAddColumn      = "add_column"
+	DryRunAlterColumn    = "alter_column"
+	DryRunLoadTable      = "load_table"
+	DryRunLoadUserTables = "load_user_tables"
+)
+
+// dryRunLoadDestinations are the warehouses which build the statements of a load without needing its load files
+var dryRunLoadDestinations = []string{warehouseutils.POSTGRES, warehouseutils.MSSQL, warehouseutils.SQLITE}
+
+// dryRunStates are the states of an upload walked by a dry run
+var dryRunStates = []string{GeneratedUploadSchema, CreatedTableUploads, GeneratedLoadFiles, UpdatedTableUploadsCounts, CreatedRemoteSchema, ExportedData}
+
+// DryRunReqT previews the upload of the pending staging files of a connection, without changing the warehouse or the uploads
+type DryRunReqT struct {
+	WorkspaceID   string
+	SourceID      string
+	DestinationID string
+	API           UploadAPIT
+}
+
+func (dryRunReq *DryRunReqT) validateReq() error {
+	if !dryRunReq.API.enabled || dryRunReq.API.log == nil || dryRunReq.API.dbHandle == nil {
+		return errors.New("warehouse api's are not initialized")
+	}
+	if dryRunReq.WorkspaceID == "" || dryRunReq.SourceID == "" || dryRunReq.DestinationID == "" {
+		return errors.New("workspace_id, source_id and destination_id are required")
+	}
+	return nil
+}
+
+// dryRunUploaderT is the uploader of a dry run, which generates no load files and does not change the local schema
+type dryRunUploaderT struct {
+	*UploadJobT
+}
+
+func (*dryRunUploaderT) GetLoadFilesMetadata(warehouseutils.GetLoadFilesOptionsT) []warehouseutils.LoadFileT {
+	return nil
+}
+
+func (*dryRunUploaderT) GetSampleLoadFileLocation(tableName string) (string, error) {
+	return "", fmt.Errorf("no load files are generated in a dry run for table: %s", tableName)
+}
+
+func (*dryRunUploaderT) GetSingleLoadFile(tableName string) (warehouseutils.LoadFileT, error) {
+	return warehouseutils.LoadFileT{}, fmt.Errorf("no load files are generated in a dry run for table: %s", tableName)
+}
+
+func (*dryRunUploaderT) UpdateLocalSchema(warehouseutils.SchemaT) error {
+	return nil
+}
+
+// dryRunT walks the states of an upload, recording the statements the warehouse would execute in the plan
+type dryRunT struct {
+	job      *UploadJobT
+	uploader *dryRunUploaderT
+	// whManager runs the statements of the upload on a dry-run database, nil if the warehouse cannot preview its statements
+	whManager manager.ManagerI
+	recorder  *warehouseutils.StatementRecorderT
+	plan      *proto.WHDryRunResponse
+}
+
+// DryRunUpload returns the plan of the upload of the pending staging files of the connection:
+// the tables and columns it would create or alter, the rows it would load in each table and the statements the warehouse would execute.
+func (dryRunReq *DryRunReqT) DryRunUpload(_ context.Context) (*proto.WHDryRunResponse, error) {
+	if err := dryRunReq.validateReq(); err != nil {
+		return &proto.WHDryRunResponse{}, err
+	}
+	sourceIDs := UploadsReqT{WorkspaceID: dryRunReq.WorkspaceID}.authorizedSources()
+	if !misc.Contains(sourceIDs, dryRunReq.SourceID) {
+		return &proto.WHDryRunResponse{}, errors.New("unauthorized request")
+	}
+
+	connectionsMapLock.Lock()
+	warehouse, ok := connectionsMap[dryRunReq.DestinationID][dryRunReq.SourceID]
+	connectionsMapLock.Unlock()
+	if !ok {
+		return &proto.WHDryRunResponse{}, errors.New("no such connection exists")
+	}
+
+	pendingStagingFiles, err := (&HandleT{dbHandle: dryRunReq.API.dbHandle, destType: warehouse.Type}).getPendingStagingFiles(warehouse)
+	if err != nil {
+		return &proto.WHDryRunResponse{}, err
+	}
+	if len(pendingStagingFiles) == 0 {
+		return &proto.WHDryRunResponse{}, errors.New("no pending staging files found")
+	}
+	// the staging files of the next upload, batched like uploads are created from them
+	var stagingFiles []*StagingFileT
+	var stagingFileIDs []int64
+	for _, stagingFile := range pendingStagingFiles {
+		if len(stagingFiles) == stagingFilesBatchSize || len(stagingFiles) > 0 && stagingFile.UseRudderStorage != stagingFiles[0].UseRudderStorage {
+			break
+		}
+		stagingFiles = append(stagingFiles, stagingFile)
+		stagingFileIDs = append(stagingFileIDs, stagingFile.ID)
+	}
+
+	job := &UploadJobT{
+		upload: &Upload{
+			Namespace:          warehouse.Namespace,
+			WorkspaceID:        warehouse.WorkspaceID,
+			SourceID:           warehouse.Source.ID,
+			SourceType:         warehouse.Source.SourceDefinition.Name,
+			SourceCategory:     warehouse.Source.SourceDefinition.Category,
+			DestinationID:      warehouse.Destination.ID,
+			DestinationType:    warehouse.Type,
+			StartStagingFileID: stagingFiles[0].ID,
+			EndStagingFileID:   stagingFiles[len(stagingFiles)-1].ID,
+			LoadFileType:       warehouseutils.GetLoadFileType(warehouse.Type),
+			UseRudderStorage:   stagingFiles[0].UseRudderStorage,
+		},
+		dbHandle:       dryRunReq.API.dbHandle,
+		warehouse:      warehouse,
+		stagingFiles:   stagingFiles,
+		stagingFileIDs: stagingFileIDs,
+	}
+	dryRun := &dryRunT{
+		job:      job,
+		uploader: &dryRunUploaderT{UploadJobT: job},
+		plan: &proto.WHDryRunResponse{
+			SourceId:           warehouse.Source.ID,
+			DestinationId:      warehouse.Destination.ID,
+			DestinationType:    warehouse.Type,
+			Namespace:          warehouse.Namespace,
+			StartStagingFileId: job.upload.StartStagingFileID,
+			EndStagingFileId:   job.upload.EndStagingFileID,
+			LoadFileType:       job.upload.LoadFileType,
+			States:             dryRunStates,
+			Tables:             []*proto.WHDryRunTable{},
+			Operations:         []*proto.WHDryRunOperation{},
+		},
+	}
+	if err = dryRun.run(); err != nil {
+		dryRunReq.API.log.Errorf("WH: Error running dry run of upload for source %s and destination %s: %v", dryRunReq.SourceID, dryRunReq.DestinationID, err)
+		return &proto.WHDryRunResponse{}, err
+	}
+	return dryRun.plan, nil
+}
+
+func (dryRun *dryRunT) run() error {
+	job := dryRun.job
+
+	// the schema in the warehouse is fetched like the upload does, which only reads from the warehouse
+	whManager, err := manager.New(job.warehouse.Type)
+	if err != nil {
+		return err
+	}
+	if err = whManager.Setup(job.warehouse, dryRun.uploader); err != nil {
+		return err
+	}
+	defer whManager.Cleanup()
+
+	schemaHandle := &SchemaHandleT{
+		warehouse:    job.warehouse,
+		stagingFiles: job.stagingFiles,
+		dbHandle:     job.dbHandle,
+	}
+	job.schemaHandle = schemaHandle
+	schemaHandle.localSchema = schemaHandle.getLocalSchema()
+	if schemaHandle.schemaInWarehouse, err = schemaHandle.fetchSchemaFromWarehouse(whManager); err != nil {
+		return err
+	}
+	if schemaHandle.schemaInWarehouse == nil {
+		schemaHandle.schemaInWarehouse = warehouseutils.SchemaT{}
+	}
+	if hasSchemaChanged(schemaHandle.localSchema, schemaHandle.schemaInWarehouse) {
+		schemaHandle.localSchema = schemaHandle.schemaInWarehouse
+	}
+
+	dryRunManager, err := manager.New(job.warehouse.Type)
+	if err != nil {
+		return err
+	}
+	if dryRunSetup, ok := dryRunManager.(manager.WarehouseDryRun); ok {
+		db, recorder := warehouseutils.NewDryRunDB()
+		defer func() { _ = db.Close() }()
+		dryRunSetup.SetupDryRun(job.warehouse, dryRun.uploader, db)
+		dryRun.whManager = dryRunManager
+		dryRun.recorder = recorder
+		dryRun.plan.StatementsPreviewed = true
+	}
+
+	// generated_upload_schema and created_table_uploads
+	schemaHandle.uploadSchema = schemaHandle.consolidateStagingFilesSchemaUsingWarehouseSchema()
+	job.upload.UploadSchema = schemaHandle.uploadSchema
+	tables := job.tablesToUpload()
+	sort.Strings(tables)
+
+	// generated_load_files and updated_table_uploads_counts
+	rows := dryRun.countRows()
+	for _, tName := range tables {
+		_, exists := schemaHandle.schemaInWarehouse[tName]
+		dryRun.plan.Tables = append(dryRun.plan.Tables, &proto.WHDryRunTable{
+			Name:   tName,
+			Exists: exists,
+			Rows:   rows[tName],
+		})
+	}
+
+	// created_remote_schema
+	if len(schemaHandle.schemaInWarehouse) == 0 {
+		dryRun.operation(CreatedRemoteSchema, "", DryRunCreateSchema, nil, func(whManager manager.ManagerI) error {
+			return whManager.CreateSchema()
+		})
+	}
+
+	// exported_data
+	userTables := []string{job.identifiesTableName(), job.usersTableName()}
+	identityTables := []string{job.identityMergeRulesTableName(), job.identityMappingsTableName()}
+	var hasUserTables bool
+	for _, tName := range tables {
+		if _, ok := job.upload.UploadSchema[tName]; !ok {
+			continue
+		}
+		dryRun.updateSchema(tName)
+		switch {
+		case misc.Contains(userTables, tName):
+			hasUserTables = true
+		case misc.Contains(identityTables, tName):
+		default:
+			dryRun.loadOperation(tName, DryRunLoadTable, func(whManager manager.ManagerI) error {
+				return whManager.LoadTable(tName)
+			})
+		}
+	}
+	if hasUserTables {
+		dryRun.loadOperation(job.usersTableName(), DryRunLoadUserTables, func(whManager manager.ManagerI) error {
+			var loadErrors []error
+			for tName, err := range whManager.LoadUserTables() {
+				if err != nil {
+					loadErrors = append(loadErrors, fmt.Errorf("%s: %w", tName, err))
+				}
+			}
+			if len(loadErrors) > 0 {
+				return misc.ConcatErrors(loadErrors)
+			}
+			return nil
+		})
+	}
+	return nil
+}
+
+// countRows returns the rows of each table in the staging files of the upload, reading at most maxStagingFilesToCountForDryRun staging files
+func (dryRun *dryRunT) countRows() map[string]int64 {
+	rows := make(map[string]int64)
+	for _, stagingFile := range dryRun.job.stagingFiles {
+		if int(dryRun.plan.StagingFilesCounted) >= maxStagingFilesToCountForDryRun {
+			break
+		}
+		err := readStagingFile(dryRun.job.warehouse, stagingFile, func(event *BatchRouterEventT) bool {
+			rows[event.Metadata.Table]++
+			return true
+		})
+		if err != nil {
+			pkgLogger.Errorf("[WH]: Failed to count rows in staging file %d for dry run of %s: %v", stagingFile.ID, dryRun.job.warehouse.Identifier, err)
+			continue
+		}
+		dryRun.plan.StagingFilesCounted++
+	}
+	return rows
+}
+
+// updateSchema plans the changes to the schema of the table like UploadJobT.updateTableSchema makes them
+func (dryRun *dryRunT) updateSchema(tName string) {
+	job := dryRun.job
+	tableSchemaDiff := getTableSchemaDiff(tName, job.schemaHandle.schemaInWarehouse, job.upload.UploadSchema)
+	if !tableSchemaDiff.Exists {
+		return
+	}
+
+	if tableSchemaDiff.TableToBeCreated {
+		dryRun.operation(ExportedData, tName, DryRunCreateTable, tableSchemaDiff.ColumnMap, func(whManager manager.ManagerI) error {
+			return whManager.CreateTable(tName, tableSchemaDiff.ColumnMap)
+		})
+	} else {
+		for _, columnName := range warehouseutils.SortColumnKeysFromColumnMap(tableSchemaDiff.ColumnMap) {
+			columnType := tableSchemaDiff.ColumnMap[columnName]
+			dryRun.operation(ExportedData, tName, DryRunAddColumn, map[string]string{columnName: columnType}, func(whManager manager.ManagerI) error {
+				return whManager.AddColumn(tName, columnName, columnType)
+			})
+		}
+		alteredColumns := make(map[string]string, len(tableSchemaDiff.StringColumnsToBeAlteredToText)+len(tableSchemaDiff.AlteredColumnMap))
+		for _, columnName := range tableSchemaDiff.StringColumnsToBeAlteredToText {
+			alteredColumns[columnName] = "text"
+		}
+		for columnName, columnType := range tableSchemaDiff.AlteredColumnMap {
+			alteredColumns[columnName] = columnType
+		}
+		for _, columnName := range warehouseutils.SortColumnKeysFromColumnMap(alteredColumns) {
+			columnType := alteredColumns[columnName]
+			dryRun.operation(ExportedData, tName, DryRunAlterColumn, map[string]string{columnName: columnType}, func(whManager manager.ManagerI) error {
+				_, err := whManager.AlterColumn(tName, columnName, columnType)
+				return err
+			})
+		}
+	}
+	job.setUpdatedTableSchema(tName, tableSchemaDiff.UpdatedSchema)
+}
+
+// loadOperation plans the load of a table, previewing its statements for the warehouses which do not need the load files to build them
+func (dryRun *dryRunT) loadOperation(tName, operation string, load func(whManager manager.ManagerI) error) {
+	if !misc.Contains(dryRunLoadDestinations, dryRun.job.warehouse.Type) {
+		load = nil
+	}
+	dryRun.operation(ExportedData, tName, operation, nil, load)
+}
+
+// operation adds an operation to the plan along with the statements recorded while running it on the dry-run manager
+func (dryRun *dryRunT) operation(state, tName, operation string, columns map[string]string, run func(whManager manager.ManagerI) error) {
+	op := &proto.WHDryRunOperation{
+		State:     state,
+		TableName: tName,
+		Operation: operation,
+		Columns:   columns,
+	}
+	if dryRun.whManager != nil && run != nil {
+		if err := run(dryRun.whManager); err != nil {
+			op.Error = err.Error()
+		}
+		op.Statements = dryRun.recorder.Flush()
+	}
+	dryRun.plan.Operations = append(dryRun.plan.Operations, op)
+}
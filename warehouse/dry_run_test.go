@@ -0,0 +1,142 @@
+//go:build !warehouse_integration
+
+package warehouse
+
+import (
+	"database/sql"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	backendconfig "github.com/rudderlabs/rudder-server/config/backend-config"
+	proto "github.com/rudderlabs/rudder-server/proto/warehouse"
+	"github.com/rudderlabs/rudder-server/utils/logger"
+	"github.com/rudderlabs/rudder-server/warehouse/manager"
+	"github.com/rudderlabs/rudder-server/warehouse/postgres"
+	warehouseutils "github.com/rudderlabs/rudder-server/warehouse/utils"
+)
+
+var _ = Describe("DryRun", func() {
+	DescribeTable("Validate request", func(dryRunReq DryRunReqT, expectedErr string) {
+		Expect(dryRunReq.validateReq()).To(MatchError(expectedErr))
+	},
+		Entry("Not initialized", DryRunReqT{}, "warehouse api's are not initialized"),
+		Entry("Missing connection", DryRunReqT{
+			WorkspaceID: "test-workspaceID",
+			API:         UploadAPIT{enabled: true, log: logger.NOP, dbHandle: &sql.DB{}},
+		}, "workspace_id, source_id and destination_id are required"),
+	)
+
+	Describe("Plan", func() {
+		var (
+			dryRun   *dryRunT
+			recorder *warehouseutils.StatementRecorderT
+		)
+
+		BeforeEach(func() {
+			pkgLogger = logger.NOP
+			postgres.Init()
+
+			warehouse := warehouseutils.Warehouse{
+				Source: backendconfig.SourceT{ID: "test-sourceID"},
+				Destination: backendconfig.DestinationT{
+					ID: "test-destinationID",
+					Config: map[string]interface{}{
+						"bucketProvider": warehouseutils.S3,
+						"bucketName":     "test-bucket",
+					},
+				},
+				Namespace: "test_namespace",
+				Type:      warehouseutils.POSTGRES,
+			}
+			job := &UploadJobT{
+				upload: &Upload{
+					DestinationType: warehouseutils.POSTGRES,
+					LoadFileType:    warehouseutils.LOAD_FILE_TYPE_CSV,
+					UploadSchema: warehouseutils.SchemaT{
+						"tracks": {"id": "string", "event": "string", "received_at": "datetime"},
+						"pages":  {"id": "string", "name": "string", "received_at": "datetime"},
+					},
+				},
+				warehouse: warehouse,
+				schemaHandle: &SchemaHandleT{
+					schemaInWarehouse: warehouseutils.SchemaT{
+						"tracks": {"id": "string", "received_at": "datetime"},
+					},
+				},
+			}
+			job.schemaHandle.uploadSchema = job.upload.UploadSchema
+
+			var db *sql.DB
+			db, recorder = warehouseutils.NewDryRunDB()
+			DeferCleanup(db.Close)
+
+			uploader := &dryRunUploaderT{UploadJobT: job}
+			var pg postgres.HandleT
+			pg.SetupDryRun(warehouse, uploader, db)
+
+			dryRun = &dryRunT{
+				job:       job,
+				uploader:  uploader,
+				whManager: &pg,
+				recorder:  recorder,
+				plan:      &proto.WHDryRunResponse{},
+			}
+		})
+
+		It("Should plan the creation of new tables", func() {
+			dryRun.updateSchema("pages")
+
+			Expect(dryRun.plan.Operations).To(HaveLen(1))
+			op := dryRun.plan.Operations[0]
+			Expect(op.State).To(Equal(ExportedData))
+			Expect(op.TableName).To(Equal("pages"))
+			Expect(op.Operation).To(Equal(DryRunCreateTable))
+			Expect(op.Columns).To(Equal(map[string]string{"id": "string", "name": "string", "received_at": "datetime"}))
+			Expect(op.Error).To(BeEmpty())
+			Expect(op.Statements).To(HaveLen(2))
+			Expect(op.Statements[1]).To(HavePrefix(`CREATE TABLE IF NOT EXISTS "test_namespace"."pages"`))
+
+			Expect(dryRun.job.GetTableSchemaInWarehouse("pages")).To(HaveKey("name"), "later operations see the planned schema")
+		})
+
+		It("Should plan the columns added to existing tables", func() {
+			dryRun.updateSchema("tracks")
+
+			Expect(dryRun.plan.Operations).To(HaveLen(1))
+			op := dryRun.plan.Operations[0]
+			Expect(op.Operation).To(Equal(DryRunAddColumn))
+			Expect(op.Columns).To(Equal(map[string]string{"event": "string"}))
+			Expect(op.Statements).To(ContainElement(`ALTER TABLE test_namespace.tracks ADD COLUMN IF NOT EXISTS "event" text`))
+		})
+
+		It("Should preview the statements of loads without load files", func() {
+			dryRun.updateSchema("tracks")
+			dryRun.plan.Operations = nil
+
+			dryRun.loadOperation("tracks", DryRunLoadTable, func(whManager manager.ManagerI) error {
+				return whManager.LoadTable("tracks")
+			})
+
+			Expect(dryRun.plan.Operations).To(HaveLen(1))
+			op := dryRun.plan.Operations[0]
+			Expect(op.Operation).To(Equal(DryRunLoadTable))
+			Expect(op.Error).To(BeEmpty())
+			Expect(op.Statements).To(ContainElement(HavePrefix(`COPY "test_namespace"`)))
+			Expect(op.Statements).To(ContainElement(ContainSubstring(`INSERT INTO "test_namespace"."tracks"`)))
+		})
+
+		It("Should not preview the statements of loads needing load files", func() {
+			dryRun.job.warehouse.Type = warehouseutils.RS
+
+			dryRun.loadOperation("tracks", DryRunLoadTable, func(whManager manager.ManagerI) error {
+				Fail("load must not run")
+				return nil
+			})
+
+			Expect(dryRun.plan.Operations).To(HaveLen(1))
+			Expect(dryRun.plan.Operations[0].Statements).To(BeEmpty())
+			Expect(recorder.Flush()).To(BeEmpty())
+		})
+	})
+})
@@ -421,7 +421,7 @@ func (wh *HandleT) populateHistoricIdentities(warehouse warehouseutils.Warehouse
 			warehouse:            warehouse,
 			whManager:            whManager,
 			dbHandle:             wh.dbHandle,
-			pgNotifier:           &wh.notifier,
+			pgNotifier:           wh.notifier,
 			destinationValidator: validations.NewDestinationValidator(),
 		}
 
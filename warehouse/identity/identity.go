@@ -407,10 +407,27 @@ func (idr *HandleT) downloadLoadFiles(tableName string) ([]string, error) {
 }
 
 func (idr *HandleT) uploadFile(filePath string, txn *sql.Tx, tableName string, totalRecords int) (err error) {
+	location, err := idr.uploadToObjectStorage(filePath, tableName)
+	if err != nil {
+		return
+	}
+
+	sqlStatement := fmt.Sprintf(`UPDATE %s SET location='%s', total_events=%d WHERE wh_upload_id=%d AND table_name='%s'`, warehouseutils.WarehouseTableUploadsTable, location, totalRecords, idr.UploadID, warehouseutils.ToProviderCase(idr.Warehouse.Destination.DestinationDefinition.Name, tableName))
+	pkgLogger.Infof(`IDR: Updating load file location for table: %s: %s `, tableName, sqlStatement)
+	_, err = txn.Exec(sqlStatement)
+	if err != nil {
+		pkgLogger.Errorf(`IDR: Error updating load file location for table: %s: %v`, tableName, err)
+	}
+	return err
+}
+
+// uploadToObjectStorage uploads the load file of the table to the object storage of the warehouse, returning its location
+func (idr *HandleT) uploadToObjectStorage(filePath, tableName string) (location string, err error) {
 	outputFile, err := os.Open(filePath)
 	if err != nil {
 		panic(err)
 	}
+	defer outputFile.Close()
 	storageProvider := warehouseutils.ObjectStorageType(idr.Warehouse.Destination.DestinationDefinition.Name, idr.Warehouse.Destination.Config, idr.Uploader.UseRudderStorage())
 	uploader, err := filemanager.DefaultFileManagerFactory.New(&filemanager.SettingsT{
 		Provider: storageProvider,
@@ -422,20 +439,13 @@ func (idr *HandleT) uploadFile(filePath string, txn *sql.Tx, tableName string, t
 	})
 	if err != nil {
 		pkgLogger.Errorf("IDR: Error in creating a file manager for :%s: , %v", idr.Warehouse.Destination.DestinationDefinition.Name, err)
-		return err
+		return "", err
 	}
 	output, err := uploader.Upload(context.TODO(), outputFile, config.GetString("WAREHOUSE_BUCKET_LOAD_OBJECTS_FOLDER_NAME", "rudder-warehouse-load-objects"), tableName, idr.Warehouse.Source.ID, tableName)
 	if err != nil {
-		return
+		return "", err
 	}
-
-	sqlStatement := fmt.Sprintf(`UPDATE %s SET location='%s', total_events=%d WHERE wh_upload_id=%d AND table_name='%s'`, warehouseutils.WarehouseTableUploadsTable, output.Location, totalRecords, idr.UploadID, warehouseutils.ToProviderCase(idr.Warehouse.Destination.DestinationDefinition.Name, tableName))
-	pkgLogger.Infof(`IDR: Updating load file location for table: %s: %s `, tableName, sqlStatement)
-	_, err = txn.Exec(sqlStatement)
-	if err != nil {
-		pkgLogger.Errorf(`IDR: Error updating load file location for table: %s: %v`, tableName, err)
-	}
-	return err
+	return output.Location, nil
 }
 
 func (idr *HandleT) createTempGzFile(dirName string) (gzWriter misc.GZipWriter, path string) {
@@ -547,3 +557,77 @@ func (idr *HandleT) ResolveHistoricIdentities() (err error) {
 
 	return idr.processMergeRules(loadFileNames)
 }
+
+// ResolveRange re-applies the merge rules added to the local merge rules table in [start, end) in a single pg txn
+// and uploads the changed identity mappings, returning the load file of the mappings table of the warehouse.
+// The load file has no location when there are no rules in the range.
+// progress is called every batch of applied rules, the resolution being rolled back if it fails.
+func (idr *HandleT) ResolveRange(start, end time.Time, progress func(applied, total int) error) (loadFile warehouseutils.LoadFileT, err error) {
+	txn, err := idr.DbHandle.Begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = txn.Rollback()
+		}
+	}()
+
+	sqlStatement := fmt.Sprintf(`SELECT id FROM %s WHERE created_at >= $1 AND created_at < $2 ORDER BY id`, idr.mergeRulesTable())
+	rows, err := txn.Query(sqlStatement, start.UTC(), end.UTC())
+	if err != nil {
+		pkgLogger.Errorf(`IDR: Error fetching merge rules in %s: %v`, idr.mergeRulesTable(), err)
+		return
+	}
+	var ruleIDs []int64
+	for rows.Next() {
+		var ruleID int64
+		if err = rows.Scan(&ruleID); err != nil {
+			rows.Close()
+			return
+		}
+		ruleIDs = append(ruleIDs, ruleID)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return
+	}
+	if len(ruleIDs) == 0 {
+		_ = txn.Rollback()
+		err = progress(0, 0)
+		return
+	}
+
+	mappingsFileGzWriter, mappingsFilePath := idr.createTempGzFile(fmt.Sprintf(`/%s/`, misc.RudderIdentityMappingsTmp))
+	defer misc.RemoveFilePaths(mappingsFilePath)
+	var totalMappingRecords int
+	for idx, ruleID := range ruleIDs {
+		var count int
+		count, err = idr.applyRule(txn, ruleID, &mappingsFileGzWriter)
+		if err != nil {
+			pkgLogger.Errorf(`IDR: Error applying rule %d in %s: %v`, ruleID, idr.mergeRulesTable(), err)
+			mappingsFileGzWriter.CloseGZ()
+			return
+		}
+		totalMappingRecords += count
+		if (idx+1)%1000 == 0 || idx+1 == len(ruleIDs) {
+			if err = progress(idx+1, len(ruleIDs)); err != nil {
+				mappingsFileGzWriter.CloseGZ()
+				return
+			}
+		}
+	}
+	mappingsFileGzWriter.CloseGZ()
+	pkgLogger.Infof(`IDR: Re-applied %d rules of %s. Total Mapping records added: %d. Namespace: %s, Destination: %s:%s`, len(ruleIDs), idr.mergeRulesTable(), totalMappingRecords, idr.Warehouse.Namespace, idr.Warehouse.Type, idr.Warehouse.Destination.ID)
+
+	loadFile.Location, err = idr.uploadToObjectStorage(mappingsFilePath, idr.whMappingsTable())
+	if err != nil {
+		pkgLogger.Errorf(`IDR: Error uploading load file for %s at %s to object storage: %v`, idr.mappingsTable(), mappingsFilePath, err)
+		return
+	}
+
+	if err = txn.Commit(); err != nil {
+		pkgLogger.Errorf(`IDR: Error committing transaction: %v`, err)
+	}
+	return
+}
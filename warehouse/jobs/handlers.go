@@ -4,7 +4,10 @@
 	1) delete by task run id,
 	2) delete by job run id,
 	3) delete by update_at
-	4) any other update / clean up operations
+	4) backfilling a column from the raw events archived by the gateway
+	5) re-running identity resolution for a date range
+	6) rewriting tables to a new schema
+	7) any other update / clean up operations
 
 	The following handlers file is the entry point for the handlers.
 */
@@ -12,9 +15,12 @@
 package jobs
 
 import (
+	"database/sql"
 	"encoding/json"
 	"io"
 	"net/http"
+
+	warehouseutils "github.com/rudderlabs/rudder-server/warehouse/utils"
 )
 
 // The following handler gets called for adding async
@@ -142,3 +148,139 @@ func (asyncWhJob *AsyncJobWhT) StatusWarehouseJobHandler(w http.ResponseWriter,
 		return
 	}
 }
+
+// AddAsyncJobHandler adds the backfill, identity resolution and rewrite jobs, one per table.
+// Identity resolution runs once for the destination.
+func (asyncWhJob *AsyncJobWhT) AddAsyncJobHandler(w http.ResponseWriter, r *http.Request) {
+	pkgLogger.Info("[WH-Jobs] Got Async Job Add Request")
+	pkgLogger.LogRequest(r)
+	if r.Method != http.MethodPost {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		pkgLogger.Errorf("[WH-Jobs]: Error reading body: %v", err)
+		http.Error(w, "can't read body", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+	var reqPayload AsyncJobReqPayload
+	if err = json.Unmarshal(body, &reqPayload); err != nil {
+		pkgLogger.Errorf("[WH-Jobs]: Error unmarshalling body: %v", err)
+		http.Error(w, "can't unmarshall body", http.StatusBadRequest)
+		return
+	}
+	if err = validateAsyncJobPayload(reqPayload); err != nil {
+		pkgLogger.Errorf("[WH-Jobs]: Invalid Payload %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !asyncWhJob.enabled {
+		pkgLogger.Errorf("[WH-Jobs]: Error Warehouse Jobs API not initialized")
+		http.Error(w, "warehouse jobs api not initialized", http.StatusBadRequest)
+		return
+	}
+
+	tableNames := reqPayload.Tables
+	if reqPayload.AsyncJobType == IdentityResolution {
+		tableNames = []string{warehouseutils.IdentityMappingsTable}
+	}
+	params := reqPayload.Params
+	metadata, err := json.Marshal(WhJobsMetaData{
+		JobType: AsyncJobType,
+		Params:  &params,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var jobIds []int64
+	for _, tableName := range tableNames {
+		payload := AsyncJobPayloadT{
+			SourceID:      reqPayload.SourceID,
+			DestinationID: reqPayload.DestinationID,
+			TableName:     tableName,
+			AsyncJobType:  reqPayload.AsyncJobType,
+			MetaData:      metadata,
+		}
+		id, err := asyncWhJob.addJobstoDB(asyncWhJob.context, &payload)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		jobIds = append(jobIds, id)
+	}
+	response, err := json.Marshal(WhAddJobResponse{JobIds: jobIds})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_, _ = w.Write(response)
+}
+
+// AsyncJobStatusHandler returns the status and the progress of a job by id
+func (asyncWhJob *AsyncJobWhT) AsyncJobStatusHandler(w http.ResponseWriter, r *http.Request) {
+	pkgLogger.LogRequest(r)
+	if r.Method != http.MethodGet {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "id is required", http.StatusBadRequest)
+		return
+	}
+	if !asyncWhJob.enabled {
+		http.Error(w, "warehouse jobs api not initialized", http.StatusBadRequest)
+		return
+	}
+
+	response, err := asyncWhJob.getAsyncJob(r.Context(), id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "async job not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		pkgLogger.Errorf("[WH-Jobs]: Error getting async job %s: %v", id, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeResponse, err := json.Marshal(response)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_, _ = w.Write(writeResponse)
+}
+
+// CancelAsyncJobHandler cancels a job by id, unless it already succeeded, aborted or got cancelled
+func (asyncWhJob *AsyncJobWhT) CancelAsyncJobHandler(w http.ResponseWriter, r *http.Request) {
+	pkgLogger.LogRequest(r)
+	if r.Method != http.MethodPost {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "id is required", http.StatusBadRequest)
+		return
+	}
+	if !asyncWhJob.enabled {
+		http.Error(w, "warehouse jobs api not initialized", http.StatusBadRequest)
+		return
+	}
+
+	cancelled, err := asyncWhJob.cancelAsyncJob(r.Context(), id)
+	if err != nil {
+		pkgLogger.Errorf("[WH-Jobs]: Error cancelling async job %s: %v", id, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !cancelled {
+		http.Error(w, "async job not found or already terminated", http.StatusBadRequest)
+		return
+	}
+	pkgLogger.Infof("[WH-Jobs]: Cancelled async job %s", id)
+	w.WriteHeader(http.StatusOK)
+}
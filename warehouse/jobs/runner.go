@@ -3,11 +3,13 @@ package jobs
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"sync"
 	"time"
 
+	"github.com/rudderlabs/rudder-server/config"
 	"github.com/rudderlabs/rudder-server/services/pgnotifier"
 	"github.com/rudderlabs/rudder-server/utils/logger"
 	"github.com/rudderlabs/rudder-server/utils/timeutil"
@@ -15,14 +17,25 @@ import (
 	"golang.org/x/sync/errgroup"
 )
 
+var (
+	maxConcurrentJobsPerDestination int
+	longRunningJobTimeout           time.Duration
+)
+
+func loadConfig() {
+	config.RegisterIntConfigVariable(3, &maxConcurrentJobsPerDestination, true, 1, "Warehouse.jobs.maxConcurrentJobsPerDestination")
+	config.RegisterDurationConfigVariable(6, &longRunningJobTimeout, true, time.Hour, "Warehouse.jobs.longRunningJobTimeout")
+}
+
 // Initializes AsyncJobWh structure with appropriate variabless
-func InitWarehouseJobsAPI(ctx context.Context, dbHandle *sql.DB, notifier *pgnotifier.PgNotifierT) *AsyncJobWhT {
+func InitWarehouseJobsAPI(ctx context.Context, dbHandle *sql.DB, notifier pgnotifier.NotifierI) *AsyncJobWhT {
 	AsyncJobWh := AsyncJobWhT{
 		dbHandle:   dbHandle,
 		enabled:    false,
 		pgnotifier: notifier,
 		context:    ctx,
 	}
+	loadConfig()
 	pkgLogger = logger.NewLogger().Child("warehouse-asyncjob")
 	return &AsyncJobWh
 }
@@ -151,13 +164,16 @@ func (asyncWhJob *AsyncJobWhT) cleanUpAsyncTable(ctx context.Context) error {
 /*
 startAsyncJobRunner is the main runner that
 1) Periodically queries the db for any pending async jobs
-2) Groups them together
+2) Groups them together by job type
 3) Publishes them to the pgnotifier
-4) Spawns a subroutine that periodically checks for responses from pgNotifier/slave worker post trackBatch
+4) Spawns a subroutine per group that waits for the responses from pgNotifier/slave worker post trackBatch,
+so that the groups run concurrently while scanning goes on. The published jobs are executing until the slaves
+respond, thus they are not picked up again in the meantime.
 */
 func (asyncWhJob *AsyncJobWhT) startAsyncJobRunner(ctx context.Context) error {
 	pkgLogger.Info("[WH-Jobs]: Starting async job runner")
 	var wg sync.WaitGroup
+	defer wg.Wait()
 	for {
 		pkgLogger.Info("[WH-Jobs]: Scanning for waiting async job")
 		select {
@@ -177,45 +193,69 @@ func (asyncWhJob *AsyncJobWhT) startAsyncJobRunner(ctx context.Context) error {
 		if len(asyncjobpayloads) > 0 {
 			pkgLogger.Info("[WH-Jobs]: Got pending wh async jobs")
 			pkgLogger.Infof("[WH-Jobs]: Number of async wh jobs left = %d\n", len(asyncjobpayloads))
-			notifierClaims, err := getMessagePayloadsFromAsyncJobPayloads(asyncjobpayloads)
-			if err != nil {
-				pkgLogger.Errorf("Error converting the asyncJobType to notifier payload %s ", err)
-				asyncJobStatusMap := convertToPayloadStatusStructWithSingleStatus(asyncjobpayloads, WhJobFailed, err)
-				_ = asyncWhJob.updateAsyncJobs(ctx, asyncJobStatusMap)
-				continue
-			}
-			messagePayload := pgnotifier.MessagePayload{
-				Jobs:    notifierClaims,
-				JobType: AsyncJobType,
+			for _, payloads := range groupAsyncJobsByType(asyncjobpayloads) {
+				asyncWhJob.publishAsyncJobs(ctx, &wg, payloads)
 			}
-			schema := warehouseutils.SchemaT{}
-			ch, err := asyncWhJob.pgnotifier.Publish(messagePayload, &schema, 100)
-			if err != nil {
-				pkgLogger.Errorf("[WH-Jobs]: unable to get publish async jobs to pgnotifier. Task failed with error %s", err.Error())
-				asyncJobStatusMap := convertToPayloadStatusStructWithSingleStatus(asyncjobpayloads, WhJobFailed, err)
-				_ = asyncWhJob.updateAsyncJobs(ctx, asyncJobStatusMap)
-				continue
+		}
+	}
+}
+
+// publishAsyncJobs moves the jobs of the same type to executing and publishes them to the pgnotifier,
+// updating their statuses once the slaves respond. The jobs are kept executing until then: the slaves stop
+// the jobs running for longer than the timeout of their type, and pgnotifier hands the jobs of dead slaves over.
+func (asyncWhJob *AsyncJobWhT) publishAsyncJobs(ctx context.Context, wg *sync.WaitGroup, asyncjobpayloads []AsyncJobPayloadT) {
+	notifierClaims, err := getMessagePayloadsFromAsyncJobPayloads(asyncjobpayloads)
+	if err != nil {
+		pkgLogger.Errorf("Error converting the asyncJobType to notifier payload %s ", err)
+		asyncJobStatusMap := convertToPayloadStatusStructWithSingleStatus(asyncjobpayloads, WhJobFailed, err)
+		_ = asyncWhJob.updateAsyncJobs(ctx, asyncJobStatusMap)
+		return
+	}
+	// jobs which cannot be moved to executing are not published, since they would be picked up again while running
+	asyncJobStatusMap := convertToPayloadStatusStructWithSingleStatus(asyncjobpayloads, WhJobExecuting, nil)
+	if err = asyncWhJob.updateAsyncJobs(ctx, asyncJobStatusMap); err != nil {
+		pkgLogger.Errorf("[WH-Jobs]: unable to move async jobs to executing with error %s", err.Error())
+		asyncWhJob.resetAsyncJobs(asyncjobpayloads)
+		return
+	}
+	messagePayload := pgnotifier.MessagePayload{
+		Jobs:    notifierClaims,
+		JobType: AsyncJobType,
+	}
+	schema := warehouseutils.SchemaT{}
+	ch, err := asyncWhJob.pgnotifier.Publish(ctx, messagePayload, &schema, 100)
+	if err != nil {
+		pkgLogger.Errorf("[WH-Jobs]: unable to get publish async jobs to pgnotifier. Task failed with error %s", err.Error())
+		asyncJobStatusMap := convertToPayloadStatusStructWithSingleStatus(asyncjobpayloads, WhJobFailed, err)
+		_ = asyncWhJob.updateAsyncJobs(ctx, asyncJobStatusMap)
+		return
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		select {
+		case responses, ok := <-ch:
+			if !ok {
+				// the batch is no longer tracked since the runner is stopping
+				asyncWhJob.resetAsyncJobs(asyncjobpayloads)
+				return
 			}
-			asyncJobStatusMap := convertToPayloadStatusStructWithSingleStatus(asyncjobpayloads, WhJobExecuting, err)
-			asyncWhJob.updateAsyncJobs(ctx, asyncJobStatusMap)
-			wg.Add(1)
-			go func() {
-				select {
-				case responses := <-ch:
-					pkgLogger.Info("[WH-Jobs]: Response received from the pgnotifier track batch")
-					asyncJobsStatusMap := getAsyncStatusMapFromAsyncPayloads(asyncjobpayloads)
-					err = updateStatusJobPayloadsFromPgnotifierResponse(responses, asyncJobsStatusMap)
-					_ = asyncWhJob.updateAsyncJobs(ctx, asyncJobsStatusMap)
-					wg.Done()
-				case <-time.After(WhAsyncJobTimeOut):
-					pkgLogger.Errorf("Go Routine timed out waiting for a response from PgNotifier", asyncjobpayloads[0].Id)
-					asyncJobStatusMap := convertToPayloadStatusStructWithSingleStatus(asyncjobpayloads, WhJobFailed, err)
-					asyncWhJob.updateAsyncJobs(ctx, asyncJobStatusMap)
-					wg.Done()
-				}
-			}()
-			wg.Wait()
+			pkgLogger.Info("[WH-Jobs]: Response received from the pgnotifier track batch")
+			asyncJobsStatusMap := getAsyncStatusMapFromAsyncPayloads(asyncjobpayloads)
+			_ = updateStatusJobPayloadsFromPgnotifierResponse(responses, asyncJobsStatusMap)
+			_ = asyncWhJob.updateAsyncJobs(ctx, asyncJobsStatusMap)
+		case <-ctx.Done():
+			asyncWhJob.resetAsyncJobs(asyncjobpayloads)
 		}
+	}()
+}
+
+// resetAsyncJobs moves the jobs back to waiting, e.g. when the runner stops before the slaves responded,
+// so that they are picked up again instead of staying in executing
+func (asyncWhJob *AsyncJobWhT) resetAsyncJobs(asyncjobpayloads []AsyncJobPayloadT) {
+	asyncJobStatusMap := convertToPayloadStatusStructWithSingleStatus(asyncjobpayloads, WhJobWaiting, nil)
+	if err := asyncWhJob.updateAsyncJobs(context.Background(), asyncJobStatusMap); err != nil {
+		pkgLogger.Errorf("[WH-Jobs]: unable to move async jobs back to waiting with error %s", err.Error())
 	}
 }
 
@@ -226,18 +266,46 @@ func (asyncWhJob *AsyncJobWhT) getPendingAsyncJobs(ctx context.Context) ([]Async
 		return asyncjobpayloads, ctx.Err()
 	}
 	pkgLogger.Info("[WH-Jobs]: Get pending wh async jobs")
-	// Filter to get most recent row for the sourceId/destinationID combo and remaining ones should relegated to aborted.
+	// Pick the oldest pending jobs of each destination, as many as it can run on top of its executing jobs.
 	var attempt int
-	query := fmt.Sprintf(
-		`select 
-	id,
-	source_id,
-	destination_id,
-	tablename,
-	async_job_type,
-	metadata,
-	attempt from %s where (status=$1 OR status=$2) LIMIT $3`, warehouseutils.WarehouseAsyncJobTable)
-	rows, err := asyncWhJob.dbHandle.Query(query, WhJobWaiting, WhJobFailed, MaxBatchSizeToProcess)
+	query := fmt.Sprintf(`
+		SELECT
+		  id,
+		  source_id,
+		  destination_id,
+		  tablename,
+		  async_job_type,
+		  metadata,
+		  attempt
+		FROM
+		  (
+			SELECT
+			  *,
+			  ROW_NUMBER() OVER (PARTITION BY destination_id ORDER BY id) AS pending_rank
+			FROM
+			  %[1]s
+			WHERE
+			  status = $1
+			  OR status = $2
+		  ) pending
+		WHERE
+		  pending_rank + (
+			SELECT
+			  COUNT(*)
+			FROM
+			  %[1]s executing
+			WHERE
+			  executing.destination_id = pending.destination_id
+			  AND executing.status = $3
+		  ) <= $4
+		ORDER BY
+		  id
+		LIMIT
+		  $5;
+`,
+		warehouseutils.WarehouseAsyncJobTable,
+	)
+	rows, err := asyncWhJob.dbHandle.Query(query, WhJobWaiting, WhJobFailed, WhJobExecuting, maxConcurrentJobsPerDestination, MaxBatchSizeToProcess)
 	if err != nil {
 		pkgLogger.Errorf("[WH-Jobs]: Error in getting pending wh async jobs with error %s", err.Error())
 		return asyncjobpayloads, err
@@ -272,12 +340,13 @@ func (asyncWhJob *AsyncJobWhT) updateAsyncJobs(ctx context.Context, payloads map
 	pkgLogger.Info("[WH-Jobs]: Updating wh async jobs to Executing")
 	var err error
 	for _, payload := range payloads {
+		var errMessage string
 		if payload.Error != nil {
-			err = asyncWhJob.updateAsyncJobStatus(ctx, payload.Id, payload.Status, payload.Error.Error())
-			continue
+			errMessage = payload.Error.Error()
+		}
+		if updateErr := asyncWhJob.updateAsyncJobStatus(ctx, payload.Id, payload.Status, errMessage); updateErr != nil {
+			err = updateErr
 		}
-		err = asyncWhJob.updateAsyncJobStatus(ctx, payload.Id, payload.Status, "")
-
 	}
 	return err
 }
@@ -292,11 +361,11 @@ func (asyncWhJob *AsyncJobWhT) updateAsyncJobStatus(ctx context.Context, Id, sta
 															THEN $2
 															ELSE  $3
 															END) , 
-															error=$4 WHERE id=$5 AND status!=$6 AND status!=$7 `, warehouseutils.WarehouseAsyncJobTable)
+															error=$4 WHERE id=$5 AND status!=$6 AND status!=$7 AND status!=$8 `, warehouseutils.WarehouseAsyncJobTable)
 	var err error
 	for queryretry := 0; queryretry < MaxQueryRetries; queryretry++ {
 		pkgLogger.Debugf("[WH-Jobs]: updating async jobs table query %s, retry no : %d", sqlStatement, queryretry)
-		_, err = asyncWhJob.dbHandle.Query(sqlStatement, MaxAttemptsPerJob, WhJobAborted, status, errMessage, Id, WhJobAborted, WhJobSucceeded)
+		_, err = asyncWhJob.dbHandle.Exec(sqlStatement, MaxAttemptsPerJob, WhJobAborted, status, errMessage, Id, WhJobAborted, WhJobSucceeded, WhJobCancelled)
 		if err == nil {
 			pkgLogger.Info("Updation successful")
 			pkgLogger.Debugf("query: %s successfully executed", sqlStatement)
@@ -318,11 +387,11 @@ func (asyncWhJob *AsyncJobWhT) updateAsyncJobAttempt(ctx context.Context, Id str
 		return ctx.Err()
 	}
 	pkgLogger.Info("[WH-Jobs]: Incrementing wh async jobs attempt")
-	sqlStatement := fmt.Sprintf(`UPDATE %s SET attempt=attempt+1 WHERE id=$1 AND status!=$2 AND status!=$3 `, warehouseutils.WarehouseAsyncJobTable)
+	sqlStatement := fmt.Sprintf(`UPDATE %s SET attempt=attempt+1 WHERE id=$1 AND status!=$2 AND status!=$3 AND status!=$4 `, warehouseutils.WarehouseAsyncJobTable)
 	var err error
 	for queryretry := 0; queryretry < MaxQueryRetries; queryretry++ {
 		pkgLogger.Debugf("[WH-Jobs]: updating async jobs table query %s, retry no : %d", sqlStatement, queryretry)
-		_, err = asyncWhJob.dbHandle.Query(sqlStatement, Id, WhJobAborted, WhJobSucceeded)
+		_, err = asyncWhJob.dbHandle.Exec(sqlStatement, Id, WhJobAborted, WhJobSucceeded, WhJobCancelled)
 		if err == nil {
 			pkgLogger.Info("Updation successful")
 			pkgLogger.Debugf("query: %s successfully executed", sqlStatement)
@@ -386,6 +455,12 @@ func (asyncWhJob *AsyncJobWhT) getStatusAsyncJob(ctx context.Context, payload *S
 			statusResponse.Err = errMessage.String
 			return
 		}
+		if status == WhJobCancelled {
+			pkgLogger.Infof("[WH-Jobs] Async Job with job_run_id: %s, task_run_id: %s is cancelled", payload.JobRunID, payload.TaskRunID)
+			statusResponse.Status = WhJobCancelled
+			statusResponse.Err = ErrAsyncJobCancelled.Error()
+			return
+		}
 		if status != WhJobSucceeded {
 			pkgLogger.Infof("[WH-Jobs] Async Job with job_run_id: %s, task_run_id: %s is under processing", payload.JobRunID, payload.TaskRunID)
 			statusResponse.Status = WhJobExecuting
@@ -398,3 +473,90 @@ func (asyncWhJob *AsyncJobWhT) getStatusAsyncJob(ctx context.Context, payload *S
 	statusResponse.Status = WhJobSucceeded
 	return
 }
+
+// getAsyncJob returns the status and the progress of the job
+func (asyncWhJob *AsyncJobWhT) getAsyncJob(ctx context.Context, id string) (response AsyncJobStatusResponse, err error) {
+	sqlStatement := fmt.Sprintf(`
+		SELECT
+		  id,
+		  source_id,
+		  destination_id,
+		  tablename,
+		  async_job_type,
+		  status,
+		  error,
+		  attempt,
+		  progress
+		FROM
+		  %s
+		WHERE
+		  id = $1;
+`,
+		warehouseutils.WarehouseAsyncJobTable,
+	)
+	var (
+		errMessage sql.NullString
+		progress   []byte
+	)
+	err = asyncWhJob.dbHandle.QueryRowContext(ctx, sqlStatement, id).Scan(
+		&response.Id,
+		&response.SourceID,
+		&response.DestinationID,
+		&response.TableName,
+		&response.AsyncJobType,
+		&response.Status,
+		&errMessage,
+		&response.Attempt,
+		&progress,
+	)
+	if err != nil {
+		return
+	}
+	response.Error = errMessage.String
+	err = json.Unmarshal(progress, &response.Progress)
+	return
+}
+
+// cancelAsyncJob cancels the job unless it already terminated. A waiting job is no longer picked up,
+// whereas the slave running an executing job stops at its next progress report.
+func (asyncWhJob *AsyncJobWhT) cancelAsyncJob(ctx context.Context, id string) (cancelled bool, err error) {
+	sqlStatement := fmt.Sprintf(`
+		UPDATE
+		  %s
+		SET
+		  status = $1,
+		  updated_at = $2
+		WHERE
+		  id = $3
+		  AND status NOT IN ($4, $5, $6);
+`,
+		warehouseutils.WarehouseAsyncJobTable,
+	)
+	result, err := asyncWhJob.dbHandle.ExecContext(ctx, sqlStatement, WhJobCancelled, timeutil.Now(), id, WhJobSucceeded, WhJobAborted, WhJobCancelled)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	return rowsAffected > 0, err
+}
+
+// UpdateAsyncJobProgress records the progress of the job run by a slave
+func UpdateAsyncJobProgress(ctx context.Context, dbHandle *sql.DB, id string, progress AsyncJobProgressT) error {
+	progressJSON, err := json.Marshal(progress)
+	if err != nil {
+		return err
+	}
+	sqlStatement := fmt.Sprintf(`UPDATE %s SET progress=$1, updated_at=$2 WHERE id=$3`, warehouseutils.WarehouseAsyncJobTable)
+	_, err = dbHandle.ExecContext(ctx, sqlStatement, progressJSON, timeutil.Now(), id)
+	return err
+}
+
+// IsAsyncJobCancelled reports whether the job run by a slave got cancelled
+func IsAsyncJobCancelled(ctx context.Context, dbHandle *sql.DB, id string) (bool, error) {
+	var status string
+	sqlStatement := fmt.Sprintf(`SELECT status FROM %s WHERE id=$1`, warehouseutils.WarehouseAsyncJobTable)
+	if err := dbHandle.QueryRowContext(ctx, sqlStatement, id).Scan(&status); err != nil {
+		return false, err
+	}
+	return status == WhJobCancelled, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"time"
 
 	"github.com/rudderlabs/rudder-server/services/pgnotifier"
@@ -27,15 +28,55 @@ type StartJobReqPayload struct {
 type AsyncJobWhT struct {
 	dbHandle   *sql.DB
 	enabled    bool
-	pgnotifier *pgnotifier.PgNotifierT
+	pgnotifier pgnotifier.NotifierI
 	context    context.Context
 }
 
 type WhJobsMetaData struct {
-	JobRunID  string `json:"job_run_id"`
-	TaskRunID string `json:"task_run_id"`
-	JobType   string `json:"jobtype"`
-	StartTime string `json:"start_time"`
+	JobRunID  string           `json:"job_run_id"`
+	TaskRunID string           `json:"task_run_id"`
+	JobType   string           `json:"jobtype"`
+	StartTime string           `json:"start_time"`
+	Params    *AsyncJobParamsT `json:"params,omitempty"`
+}
+
+// AsyncJobReqPayload is the request to run an async job on tables of a destination, one job being added per table
+type AsyncJobReqPayload struct {
+	SourceID      string          `json:"source_id"`
+	DestinationID string          `json:"destination_id"`
+	AsyncJobType  string          `json:"async_job_type"`
+	Tables        []string        `json:"tables"`
+	Params        AsyncJobParamsT `json:"params"`
+}
+
+// AsyncJobParamsT are the parameters of the backfill, identity resolution and rewrite jobs.
+// Times are in RFC3339 and the range is [start_time, end_time).
+type AsyncJobParamsT struct {
+	// Column is the column backfilled with the Property of the raw events, e.g. context.campaign.name
+	Column   string `json:"column,omitempty"`
+	Property string `json:"property,omitempty"`
+	// Schema maps the columns of the rewritten table to their new rudder data types
+	Schema    map[string]string `json:"schema,omitempty"`
+	StartTime string            `json:"start_time,omitempty"`
+	EndTime   string            `json:"end_time,omitempty"`
+}
+
+// AsyncJobProgressT is the progress reported by the slave running a job, in units of work of the job type
+type AsyncJobProgressT struct {
+	Done  int64 `json:"done"`
+	Total int64 `json:"total"`
+}
+
+type AsyncJobStatusResponse struct {
+	Id            string            `json:"id"`
+	SourceID      string            `json:"source_id"`
+	DestinationID string            `json:"destination_id"`
+	TableName     string            `json:"tablename"`
+	AsyncJobType  string            `json:"async_job_type"`
+	Status        string            `json:"status"`
+	Error         string            `json:"error,omitempty"`
+	Attempt       int               `json:"attempt"`
+	Progress      AsyncJobProgressT `json:"progress"`
 }
 
 // For creating job payload to wh_async_jobs table
@@ -54,9 +95,24 @@ const (
 	WhJobSucceeded string = "succeeded"
 	WhJobAborted   string = "aborted"
 	WhJobFailed    string = "failed"
+	WhJobCancelled string = "cancelled"
 	AsyncJobType   string = "async_job"
 )
 
+// Async job types run by the slaves
+const (
+	DeleteByJobRunID   string = "deletebyjobrunid"
+	BackfillColumn     string = "backfill_column"
+	IdentityResolution string = "identity_resolution"
+	RewriteTable       string = "rewrite_table"
+)
+
+// ErrAsyncJobCancelled is returned by the slave running a job which got cancelled
+var ErrAsyncJobCancelled = errors.New("async job cancelled")
+
+// ErrAsyncJobTimedOut is returned by the slave running a job for longer than the timeout of its type
+var ErrAsyncJobTimedOut = errors.New("async job timed out")
+
 type PGNotifierOutput struct {
 	Id string `json:"id"`
 }
@@ -2,11 +2,25 @@ package jobs
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"regexp"
+	"time"
 
 	"github.com/rudderlabs/rudder-server/services/pgnotifier"
 )
 
+// rewriteDataTypes are the rudder data types the columns of a table can be rewritten to
+var rewriteDataTypes = map[string]bool{
+	"int":      true,
+	"float":    true,
+	"string":   true,
+	"text":     true,
+	"datetime": true,
+	"boolean":  true,
+	"json":     true,
+}
+
 func convertToPayloadStatusStructWithSingleStatus(payloads []AsyncJobPayloadT, status string, err error) map[string]AsyncJobsStatusMap {
 	asyncJobsStatusMap := make(map[string]AsyncJobsStatusMap)
 	for _, payload := range payloads {
@@ -40,6 +54,98 @@ func validatePayload(payload StartJobReqPayload) bool {
 	return true
 }
 
+// validIdentifier matches the names of the tables and columns the jobs run on, which end up in their SQL
+var validIdentifier = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+func validateTables(tables []string) error {
+	if len(tables) == 0 {
+		return errors.New("tables are required")
+	}
+	for _, table := range tables {
+		if !validIdentifier.MatchString(table) {
+			return fmt.Errorf("invalid table %q", table)
+		}
+	}
+	return nil
+}
+
+// validateAsyncJobPayload validates the request to add the jobs of a backfill, identity resolution or rewrite
+func validateAsyncJobPayload(payload AsyncJobReqPayload) error {
+	if payload.SourceID == "" || payload.DestinationID == "" {
+		return errors.New("source_id and destination_id are required")
+	}
+	params := payload.Params
+	switch payload.AsyncJobType {
+	case BackfillColumn:
+		if err := validateTables(payload.Tables); err != nil {
+			return err
+		}
+		if !validIdentifier.MatchString(params.Column) || params.Property == "" {
+			return errors.New("valid column and property are required")
+		}
+		_, _, err := params.TimeRange()
+		return err
+	case IdentityResolution:
+		_, _, err := params.TimeRange()
+		return err
+	case RewriteTable:
+		if err := validateTables(payload.Tables); err != nil {
+			return err
+		}
+		if len(params.Schema) == 0 {
+			return errors.New("schema is required")
+		}
+		for column, dataType := range params.Schema {
+			if !validIdentifier.MatchString(column) || !rewriteDataTypes[dataType] {
+				return fmt.Errorf("invalid type %q of column %q", dataType, column)
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported async job type: %q", payload.AsyncJobType)
+	}
+}
+
+// TimeRange returns the [start_time, end_time) range of the job
+func (params AsyncJobParamsT) TimeRange() (start, end time.Time, err error) {
+	if start, err = time.Parse(time.RFC3339, params.StartTime); err != nil {
+		return start, end, fmt.Errorf("invalid start_time: %w", err)
+	}
+	if end, err = time.Parse(time.RFC3339, params.EndTime); err != nil {
+		return start, end, fmt.Errorf("invalid end_time: %w", err)
+	}
+	if !start.Before(end) {
+		return start, end, errors.New("start_time should be before end_time")
+	}
+	return start, end, nil
+}
+
+// AsyncJobTimeout returns how long the slaves run the jobs of the type before stopping them
+func AsyncJobTimeout(asyncJobType string) time.Duration {
+	switch asyncJobType {
+	case BackfillColumn, IdentityResolution, RewriteTable:
+		return longRunningJobTimeout
+	default:
+		return WhAsyncJobTimeOut
+	}
+}
+
+// groupAsyncJobsByType groups the jobs by type, keeping the order in which the types were first seen
+func groupAsyncJobsByType(payloads []AsyncJobPayloadT) [][]AsyncJobPayloadT {
+	var groups [][]AsyncJobPayloadT
+	groupIndex := make(map[string]int)
+	for _, payload := range payloads {
+		idx, ok := groupIndex[payload.AsyncJobType]
+		if !ok {
+			idx = len(groups)
+			groupIndex[payload.AsyncJobType] = idx
+			groups = append(groups, nil)
+		}
+		groups[idx] = append(groups[idx], payload)
+	}
+	return groups
+}
+
 func skipTable(th string) bool {
 	if th == "RUDDER_DISCARDS" || th == "rudder_discards" {
 		return true
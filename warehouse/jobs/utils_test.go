@@ -1,7 +1,9 @@
 package jobs
 
 import (
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestValidatePayload(t *testing.T) {
@@ -33,3 +35,84 @@ func TestValidatePayload(t *testing.T) {
 		}
 	}
 }
+
+func TestValidateAsyncJobPayload(t *testing.T) {
+	params := AsyncJobParamsT{
+		Column:    "campaign_name",
+		Property:  "context.campaign.name",
+		Schema:    map[string]string{"revenue": "float"},
+		StartTime: "2022-10-01T00:00:00Z",
+		EndTime:   "2022-10-02T00:00:00Z",
+	}
+	withParams := func(update func(params *AsyncJobParamsT)) AsyncJobParamsT {
+		params := params
+		update(&params)
+		return params
+	}
+	payloadTests := []struct {
+		name     string
+		payload  AsyncJobReqPayload
+		expected bool
+	}{
+		{"backfill column", AsyncJobReqPayload{SourceID: "s", DestinationID: "d", AsyncJobType: BackfillColumn, Tables: []string{"tracks"}, Params: params}, true},
+		{"missing source", AsyncJobReqPayload{DestinationID: "d", AsyncJobType: BackfillColumn, Tables: []string{"tracks"}, Params: params}, false},
+		{"missing tables", AsyncJobReqPayload{SourceID: "s", DestinationID: "d", AsyncJobType: BackfillColumn, Params: params}, false},
+		{"invalid table", AsyncJobReqPayload{SourceID: "s", DestinationID: "d", AsyncJobType: BackfillColumn, Tables: []string{`tracks"; --`}, Params: params}, false},
+		{"invalid column", AsyncJobReqPayload{SourceID: "s", DestinationID: "d", AsyncJobType: BackfillColumn, Tables: []string{"tracks"}, Params: withParams(func(p *AsyncJobParamsT) { p.Column = "campaign name" })}, false},
+		{"missing property", AsyncJobReqPayload{SourceID: "s", DestinationID: "d", AsyncJobType: BackfillColumn, Tables: []string{"tracks"}, Params: withParams(func(p *AsyncJobParamsT) { p.Property = "" })}, false},
+		{"invalid time range", AsyncJobReqPayload{SourceID: "s", DestinationID: "d", AsyncJobType: BackfillColumn, Tables: []string{"tracks"}, Params: withParams(func(p *AsyncJobParamsT) { p.EndTime = p.StartTime })}, false},
+		{"identity resolution", AsyncJobReqPayload{SourceID: "s", DestinationID: "d", AsyncJobType: IdentityResolution, Params: params}, true},
+		{"identity resolution without time range", AsyncJobReqPayload{SourceID: "s", DestinationID: "d", AsyncJobType: IdentityResolution}, false},
+		{"rewrite table", AsyncJobReqPayload{SourceID: "s", DestinationID: "d", AsyncJobType: RewriteTable, Tables: []string{"tracks"}, Params: params}, true},
+		{"rewrite table without schema", AsyncJobReqPayload{SourceID: "s", DestinationID: "d", AsyncJobType: RewriteTable, Tables: []string{"tracks"}}, false},
+		{"rewrite table to invalid type", AsyncJobReqPayload{SourceID: "s", DestinationID: "d", AsyncJobType: RewriteTable, Tables: []string{"tracks"}, Params: withParams(func(p *AsyncJobParamsT) { p.Schema = map[string]string{"revenue": "decimal"} })}, false},
+		{"delete by job run id", AsyncJobReqPayload{SourceID: "s", DestinationID: "d", AsyncJobType: DeleteByJobRunID, Tables: []string{"tracks"}}, false},
+	}
+	for _, tt := range payloadTests {
+		err := validateAsyncJobPayload(tt.payload)
+		if (err == nil) != tt.expected {
+			t.Errorf("error in function validateAsyncJobPayload for %s, expected %t and got %v", tt.name, tt.expected, err)
+		}
+	}
+}
+
+func TestGroupAsyncJobsByType(t *testing.T) {
+	payloads := []AsyncJobPayloadT{
+		{Id: "1", AsyncJobType: BackfillColumn},
+		{Id: "2", AsyncJobType: DeleteByJobRunID},
+		{Id: "3", AsyncJobType: BackfillColumn},
+		{Id: "4", AsyncJobType: RewriteTable},
+	}
+	groups := groupAsyncJobsByType(payloads)
+	expected := [][]string{{"1", "3"}, {"2"}, {"4"}}
+	if len(groups) != len(expected) {
+		t.Fatalf("error in function groupAsyncJobsByType, expected %d groups and got %d", len(expected), len(groups))
+	}
+	for i, group := range groups {
+		var ids []string
+		for _, payload := range group {
+			ids = append(ids, payload.Id)
+		}
+		if strings.Join(ids, ",") != strings.Join(expected[i], ",") {
+			t.Errorf("error in function groupAsyncJobsByType, expected %v and got %v", expected[i], ids)
+		}
+	}
+}
+
+func TestAsyncJobTimeout(t *testing.T) {
+	longRunningJobTimeout = time.Hour
+	timeoutTests := []struct {
+		asyncJobType string
+		expected     time.Duration
+	}{
+		{DeleteByJobRunID, WhAsyncJobTimeOut},
+		{BackfillColumn, time.Hour},
+		{IdentityResolution, time.Hour},
+		{RewriteTable, time.Hour},
+	}
+	for _, tt := range timeoutTests {
+		if output := AsyncJobTimeout(tt.asyncJobType); output != tt.expected {
+			t.Errorf("error in function AsyncJobTimeout for %s, expected %s and got %s", tt.asyncJobType, tt.expected, output)
+		}
+	}
+}
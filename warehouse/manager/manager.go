@@ -2,6 +2,7 @@ package manager
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"time"
 
@@ -15,6 +16,7 @@ import (
 	"github.com/rudderlabs/rudder-server/warehouse/postgres"
 	"github.com/rudderlabs/rudder-server/warehouse/redshift"
 	"github.com/rudderlabs/rudder-server/warehouse/snowflake"
+	"github.com/rudderlabs/rudder-server/warehouse/sqlite"
 
 	"github.com/rudderlabs/rudder-server/utils/misc"
 	warehouseutils "github.com/rudderlabs/rudder-server/warehouse/utils"
@@ -27,7 +29,7 @@ type ManagerI interface {
 	CreateSchema() (err error)
 	CreateTable(tableName string, columnMap map[string]string) (err error)
 	AddColumn(tableName, columnName, columnType string) (err error)
-	AlterColumn(tableName, columnName, columnType string) (err error)
+	AlterColumn(tableName, columnName, columnType string) (warehouseutils.AlterColumnResponseT, error)
 	LoadTable(tableName string) error
 	LoadUserTables() map[string]error
 	LoadIdentityMergeRulesTable() error
@@ -47,9 +49,20 @@ type WarehouseDelete interface {
 	DeleteBy(tableName []string, params warehouseutils.DeleteByParams) error
 }
 
+// WarehouseRetention prunes the rows of a table which are older than the retention configured for it
+type WarehouseRetention interface {
+	PruneTable(ctx context.Context, tableName string, params warehouseutils.RetentionParamsT) (warehouseutils.RetentionStatsT, error)
+}
+
+// WarehouseDryRun sets up a warehouse to run the statements of an upload on a dry-run database instead of connecting to it
+type WarehouseDryRun interface {
+	SetupDryRun(warehouse warehouseutils.Warehouse, uploader warehouseutils.UploaderI, db *sql.DB)
+}
+
 type WarehouseOperations interface {
 	ManagerI
 	WarehouseDelete
+	WarehouseRetention
 }
 
 // New is a Factory function that returns a ManagerI of a given destination-type
@@ -82,6 +95,9 @@ func New(destType string) (ManagerI, error) {
 	case warehouseutils.DELTALAKE:
 		var dl deltalake.HandleT
 		return &dl, nil
+	case warehouseutils.SQLITE:
+		var sl sqlite.HandleT
+		return &sl, nil
 	}
 	return nil, fmt.Errorf("Provider of type %s is not configured for WarehouseManager", destType)
 }
@@ -116,6 +132,9 @@ func NewWarehouseOperations(destType string) (WarehouseOperations, error) {
 	case warehouseutils.DELTALAKE:
 		var dl deltalake.HandleT
 		return &dl, nil
+	case warehouseutils.SQLITE:
+		var sl sqlite.HandleT
+		return &sl, nil
 	}
 	return nil, fmt.Errorf("Provider of type %s is not configured for WarehouseManager", destType)
 }
@@ -40,6 +40,14 @@ const (
 	sslMode  = "sslMode"
 )
 
+var (
+	identityMergeRulesColumns = []string{"merge_property_1_type", "merge_property_1_value", "merge_property_2_type", "merge_property_2_value"}
+	identityMappingsColumns   = []string{"merge_property_type", "merge_property_value", "rudder_id", "updated_at"}
+)
+
+// identityStagingRowIDColumn orders the rows copied into the identity staging tables
+const identityStagingRowIDColumn = "_rudder_staging_row_id"
+
 const (
 	mssqlStringLengthLimit = 512
 	provider               = warehouseutils.MSSQL
@@ -99,16 +107,11 @@ type CredentialsT struct {
 	timeout  time.Duration
 }
 
-var primaryKeyMap = map[string]string{
-	warehouseutils.UsersTable:      "id",
-	warehouseutils.IdentifiesTable: "id",
-	warehouseutils.DiscardsTable:   "row_id",
-}
-
-var partitionKeyMap = map[string]string{
-	warehouseutils.UsersTable:      "id",
-	warehouseutils.IdentifiesTable: "id",
-	warehouseutils.DiscardsTable:   "row_id, column_name, table_name",
+// primaryKeyMap are the columns deduplicating the rows of the tables in the merge load mode, defaulting to id
+var primaryKeyMap = map[string][]string{
+	warehouseutils.UsersTable:      {"id"},
+	warehouseutils.IdentifiesTable: {"id"},
+	warehouseutils.DiscardsTable:   {"row_id", "column_name", "table_name"},
 }
 
 func Connect(cred CredentialsT) (*sql.DB, error) {
@@ -180,6 +183,10 @@ func (*HandleT) IsEmpty(_ warehouseutils.Warehouse) (empty bool, err error) {
 
 func (ms *HandleT) DownloadLoadFiles(tableName string) ([]string, error) {
 	objects := ms.Uploader.GetLoadFilesMetadata(warehouseutils.GetLoadFilesOptionsT{Table: tableName})
+	return ms.downloadLoadFiles(tableName, objects)
+}
+
+func (ms *HandleT) downloadLoadFiles(tableName string, objects []warehouseutils.LoadFileT) ([]string, error) {
 	storageProvider := warehouseutils.ObjectStorageType(ms.Warehouse.Destination.DestinationDefinition.Name, ms.Warehouse.Destination.Config, ms.Uploader.UseRudderStorage())
 	downloader, err := filemanager.DefaultFileManagerFactory.New(&filemanager.SettingsT{
 		Provider: storageProvider,
@@ -265,6 +272,18 @@ func (ms *HandleT) DeleteBy(tableNames []string, params warehouseutils.DeleteByP
 	return nil
 }
 
+// PruneTable deletes the rows of the table older than the retention, one window of time at a time
+func (ms *HandleT) PruneTable(ctx context.Context, tableName string, params warehouseutils.RetentionParamsT) (warehouseutils.RetentionStatsT, error) {
+	pkgLogger.Infof("MS: Pruning rows with %s before %s in table %s for MS:%s", params.Column, params.Before, tableName, ms.Warehouse.Destination.ID)
+	table := fmt.Sprintf(`"%s"."%s"`, ms.Namespace, tableName)
+	batchedDelete := warehouseutils.BatchedDeleteT{
+		Db:          ms.Db,
+		OldestQuery: fmt.Sprintf(`SELECT MIN(%q) FROM %s`, params.Column, table),
+		DeleteQuery: fmt.Sprintf(`DELETE FROM %[1]s WHERE %[2]q >= @p1 AND %[2]q < @p2`, table, params.Column),
+	}
+	return batchedDelete.Run(ctx, params)
+}
+
 func (ms *HandleT) loadTable(tableName string, tableSchemaInUpload warehouseutils.TableSchemaT, skipTempTableDelete bool) (stagingTableName string, err error) {
 	pkgLogger.Infof("MS: Starting load for table:%s", tableName)
 
@@ -408,25 +427,7 @@ func (ms *HandleT) loadTable(tableName string, tableSchemaInUpload warehouseutil
 					}
 				case "string":
 					{
-						// This is needed to enable diacritic support Ex: Ü,ç Ç,©,∆,ß,á,ù,ñ,ê
-						// A substitute to this PR; https://github.com/denisenkom/go-mssqldb/pull/576/files
-						// An alternate to this approach is to use nvarchar(instead of varchar)
-						if len(strValue) > mssqlStringLengthLimit {
-							strValue = strValue[:mssqlStringLengthLimit]
-						}
-						var byteArr []byte
-						if hasDiacritics(strValue) {
-							pkgLogger.Debug("diacritics " + strValue)
-							byteArr = str2ucs2(strValue)
-							// This is needed as with above operation every character occupies 2 bytes
-							if len(byteArr) > mssqlStringLengthLimit {
-								byteArr = byteArr[:mssqlStringLengthLimit]
-							}
-							finalColumnValues = append(finalColumnValues, byteArr)
-						} else {
-							pkgLogger.Debug("non-diacritic : " + strValue)
-							finalColumnValues = append(finalColumnValues, strValue)
-						}
+						finalColumnValues = append(finalColumnValues, stringColumnValue(strValue))
 					}
 				default:
 					finalColumnValues = append(finalColumnValues, value)
@@ -452,34 +453,30 @@ func (ms *HandleT) loadTable(tableName string, tableSchemaInUpload warehouseutil
 		return
 
 	}
-	// deduplication process
-	primaryKey := "id"
-	if column, ok := primaryKeyMap[tableName]; ok {
-		primaryKey = column
-	}
-	partitionKey := "id"
-	if column, ok := partitionKeyMap[tableName]; ok {
-		partitionKey = column
-	}
-	var additionalJoinClause string
-	if tableName == warehouseutils.DiscardsTable {
-		additionalJoinClause = fmt.Sprintf(`AND _source.%[3]s = "%[1]s"."%[2]s"."%[3]s" AND _source.%[4]s = "%[1]s"."%[2]s"."%[4]s"`, ms.Namespace, tableName, "table_name", "column_name")
-	}
-	sqlStatement = fmt.Sprintf(`DELETE FROM "%[1]s"."%[2]s" FROM "%[1]s"."%[3]s" as  _source where (_source.%[4]s = "%[1]s"."%[2]s"."%[4]s" %[5]s)`, ms.Namespace, tableName, stagingTableName, primaryKey, additionalJoinClause)
-	pkgLogger.Infof("MS: Deduplicate records for table:%s using staging table: %s\n", tableName, sqlStatement)
-	_, err = txn.Exec(sqlStatement)
-	if err != nil {
-		pkgLogger.Errorf("MS: Error deleting from original table for dedup: %v\n", err)
-		txn.Rollback()
-		return
-	}
-
 	quotedColumnNames := warehouseutils.DoubleQuoteAndJoinByComma(sortedColumnKeys)
-	sqlStatement = fmt.Sprintf(`INSERT INTO "%[1]s"."%[2]s" (%[3]s)
+	if ms.Warehouse.LoadMode(warehouseutils.LoadModeMerge) == warehouseutils.LoadModeAppend {
+		sqlStatement = fmt.Sprintf(`INSERT INTO "%[1]s"."%[2]s" (%[3]s) SELECT %[3]s FROM "%[1]s"."%[4]s"`, ms.Namespace, tableName, quotedColumnNames, stagingTableName)
+	} else {
+		// deduplication process
+		primaryKeys := ms.primaryKeys(tableName)
+		joinClause := warehouseutils.JoinWithFormatting(primaryKeys, func(_ int, key string) string {
+			return fmt.Sprintf(`_source.%[3]q = "%[1]s"."%[2]s".%[3]q`, ms.Namespace, tableName, key)
+		}, " AND ")
+		sqlStatement = fmt.Sprintf(`DELETE FROM "%[1]s"."%[2]s" FROM "%[1]s"."%[3]s" as  _source where (%[4]s)`, ms.Namespace, tableName, stagingTableName, joinClause)
+		pkgLogger.Infof("MS: Deduplicate records for table:%s using staging table: %s\n", tableName, sqlStatement)
+		_, err = txn.Exec(sqlStatement)
+		if err != nil {
+			pkgLogger.Errorf("MS: Error deleting from original table for dedup: %v\n", err)
+			txn.Rollback()
+			return
+		}
+
+		sqlStatement = fmt.Sprintf(`INSERT INTO "%[1]s"."%[2]s" (%[3]s)
 									SELECT %[3]s FROM (
 										SELECT *, row_number() OVER (PARTITION BY %[5]s ORDER BY received_at DESC) AS _rudder_staging_row_number FROM "%[1]s"."%[4]s"
 									) AS _ where _rudder_staging_row_number = 1
-									`, ms.Namespace, tableName, quotedColumnNames, stagingTableName, partitionKey)
+									`, ms.Namespace, tableName, quotedColumnNames, stagingTableName, warehouseutils.DoubleQuoteAndJoinByComma(primaryKeys))
+	}
 	pkgLogger.Infof("MS: Inserting records for table:%s using staging table: %s\n", tableName, sqlStatement)
 	_, err = txn.Exec(sqlStatement)
 
@@ -510,6 +507,27 @@ func str2ucs2(s string) []byte {
 	return ucs2
 }
 
+// stringColumnValue truncates strValue to the string length limit of the column.
+func stringColumnValue(strValue string) interface{} {
+	// This is needed to enable diacritic support Ex: Ü,ç Ç,©,∆,ß,á,ù,ñ,ê
+	// A substitute to this PR; https://github.com/denisenkom/go-mssqldb/pull/576/files
+	// An alternate to this approach is to use nvarchar(instead of varchar)
+	if len(strValue) > mssqlStringLengthLimit {
+		strValue = strValue[:mssqlStringLengthLimit]
+	}
+	if hasDiacritics(strValue) {
+		pkgLogger.Debug("diacritics " + strValue)
+		byteArr := str2ucs2(strValue)
+		// This is needed as with above operation every character occupies 2 bytes
+		if len(byteArr) > mssqlStringLengthLimit {
+			byteArr = byteArr[:mssqlStringLengthLimit]
+		}
+		return byteArr
+	}
+	pkgLogger.Debug("non-diacritic : " + strValue)
+	return strValue
+}
+
 func hasDiacritics(str string) bool {
 	for _, x := range str {
 		if utf8.RuneLen(x) > 1 {
@@ -693,7 +711,21 @@ func (ms *HandleT) AddColumn(tableName, columnName, columnType string) (err erro
 	return err
 }
 
-func (*HandleT) AlterColumn(_, _, _ string) (err error) {
+// AlterColumn changes the type of the column in place, the existing values are converted implicitly to the new type
+func (ms *HandleT) AlterColumn(tableName, columnName, columnType string) (response warehouseutils.AlterColumnResponseT, err error) {
+	dataType, ok := rudderDataTypesMapToMssql[columnType]
+	if !ok {
+		return
+	}
+	sqlStatement := fmt.Sprintf(`ALTER TABLE %[1]s ALTER COLUMN "%[2]s" %[3]s`, ms.Namespace+"."+tableName, columnName, dataType)
+	pkgLogger.Infof("MS: Altering column in mssql for MS:%s : %v", ms.Warehouse.Destination.ID, sqlStatement)
+	if _, err = ms.Db.Exec(sqlStatement); err != nil {
+		return
+	}
+	response = warehouseutils.AlterColumnResponseT{
+		Strategy: warehouseutils.AlterColumnInPlace,
+		Queries:  []string{sqlStatement},
+	}
 	return
 }
 
@@ -735,6 +767,15 @@ func (ms *HandleT) Setup(warehouse warehouseutils.Warehouse, uploader warehouseu
 	return err
 }
 
+// SetupDryRun sets up the warehouse like Setup, running statements on db instead of connecting to mssql
+func (ms *HandleT) SetupDryRun(warehouse warehouseutils.Warehouse, uploader warehouseutils.UploaderI, db *sql.DB) {
+	ms.Warehouse = warehouse
+	ms.Namespace = warehouse.Namespace
+	ms.Uploader = uploader
+	ms.ObjectStorage = warehouseutils.ObjectStorageType(warehouseutils.MSSQL, warehouse.Destination.Config, ms.Uploader.UseRudderStorage())
+	ms.Db = db
+}
+
 func (ms *HandleT) CrashRecover(warehouse warehouseutils.Warehouse) (err error) {
 	ms.Warehouse = warehouse
 	ms.Namespace = warehouse.Namespace
@@ -846,6 +887,15 @@ func (ms *HandleT) LoadUserTables() map[string]error {
 	return ms.loadUserTables()
 }
 
+// primaryKeys returns the columns deduplicating the rows of tableName in the merge load mode
+func (ms *HandleT) primaryKeys(tableName string) []string {
+	keys, ok := primaryKeyMap[tableName]
+	if !ok {
+		keys = []string{"id"}
+	}
+	return ms.Warehouse.MergePrimaryKeys(tableName, keys)
+}
+
 func (ms *HandleT) LoadTable(tableName string) error {
 	_, err := ms.loadTable(tableName, ms.Uploader.GetTableSchemaInUpload(tableName), false)
 	return err
@@ -859,15 +909,160 @@ func (ms *HandleT) Cleanup() {
 	}
 }
 
-func (*HandleT) LoadIdentityMergeRulesTable() (err error) {
-	return
+func (ms *HandleT) LoadIdentityMergeRulesTable() (err error) {
+	return ms.loadIdentityTable(warehouseutils.IdentityMergeRulesTable, identityMergeRulesColumns, false)
 }
 
-func (*HandleT) LoadIdentityMappingsTable() (err error) {
+func (ms *HandleT) LoadIdentityMappingsTable() (err error) {
+	return ms.loadIdentityTable(warehouseutils.IdentityMappingsTable, identityMappingsColumns, true)
+}
+
+// loadIdentityTable loads the identity load file generated for the upload into tableName through a staging table.
+// Merge rules are appended, whereas mappings are upserted on merge property keeping the last mapping in the load file.
+func (ms *HandleT) loadIdentityTable(tableName string, columns []string, upsert bool) (err error) {
+	pkgLogger.Infof("MS: Starting load for identity table:%s", tableName)
+	loadFile, err := ms.Uploader.GetSingleLoadFile(tableName)
+	if err != nil {
+		return
+	}
+	fileNames, err := ms.downloadLoadFiles(tableName, []warehouseutils.LoadFileT{loadFile})
+	defer misc.RemoveFilePaths(fileNames...)
+	if err != nil {
+		return
+	}
+
+	txn, err := ms.Db.Begin()
+	if err != nil {
+		pkgLogger.Errorf("MS: Error while beginning a transaction in db for loading in table:%s: %v", tableName, err)
+		return
+	}
+	stagingTableName := warehouseutils.StagingTableName(provider, tableName, tableNameLimit)
+	// dropping the staging table is deferred first to run after the transaction is rolled back
+	defer ms.dropStagingTable(stagingTableName)
+	defer func() {
+		if err != nil {
+			txn.Rollback()
+		}
+	}()
+
+	quotedColumnNames := warehouseutils.DoubleQuoteAndJoinByComma(columns)
+	sqlStatement := fmt.Sprintf(`select top 0 %[4]s, cast(0 as bigint) as %[5]s into %[1]s.%[2]s from %[1]s.%[3]s`, ms.Namespace, stagingTableName, tableName, quotedColumnNames, identityStagingRowIDColumn)
+	pkgLogger.Infof("MS: Creating staging table for identity table:%s at %s", tableName, sqlStatement)
+	if _, err = txn.Exec(sqlStatement); err != nil {
+		return
+	}
+
+	// the row id keeps the order of the rows in the load file
+	stmt, err := txn.Prepare(mssql.CopyIn(ms.Namespace+"."+stagingTableName, mssql.BulkOptions{CheckConstraints: false}, append(columns, identityStagingRowIDColumn)...))
+	if err != nil {
+		return
+	}
+	var rowID int64
+	for _, fileName := range fileNames {
+		err = readLoadFile(fileName, len(columns), func(record []string) error {
+			rowID++
+			values := identityColumnValues(columns, record)
+			_, err := stmt.Exec(append(values, rowID)...)
+			return err
+		})
+		if err != nil {
+			pkgLogger.Errorf("MS: Error loading file %s into staging table:%s: %v", fileName, stagingTableName, err)
+			return
+		}
+	}
+	if _, err = stmt.Exec(); err != nil {
+		return
+	}
+
+	if upsert {
+		sqlStatement = fmt.Sprintf(`DELETE FROM "%[1]s"."%[2]s" FROM "%[1]s"."%[3]s" AS _source WHERE _source.merge_property_type = "%[1]s"."%[2]s".merge_property_type AND _source.merge_property_value = "%[1]s"."%[2]s".merge_property_value`, ms.Namespace, tableName, stagingTableName)
+		pkgLogger.Infof("MS: Deduplicate records for identity table:%s using staging table: %s", tableName, sqlStatement)
+		if _, err = txn.Exec(sqlStatement); err != nil {
+			return
+		}
+		sqlStatement = fmt.Sprintf(`INSERT INTO "%[1]s"."%[2]s" (%[3]s) SELECT %[3]s FROM ( SELECT *, row_number() OVER (PARTITION BY merge_property_type, merge_property_value ORDER BY %[5]s DESC) AS _rudder_staging_row_number FROM "%[1]s"."%[4]s" ) AS _ WHERE _rudder_staging_row_number = 1`, ms.Namespace, tableName, quotedColumnNames, stagingTableName, identityStagingRowIDColumn)
+	} else {
+		sqlStatement = fmt.Sprintf(`INSERT INTO "%[1]s"."%[2]s" (%[3]s) SELECT %[3]s FROM "%[1]s"."%[4]s"`, ms.Namespace, tableName, quotedColumnNames, stagingTableName)
+	}
+	pkgLogger.Infof("MS: Inserting records for identity table:%s using staging table: %s", tableName, sqlStatement)
+	if _, err = txn.Exec(sqlStatement); err != nil {
+		return
+	}
+
+	if err = txn.Commit(); err != nil {
+		return
+	}
+	pkgLogger.Infof("MS: Complete load for identity table:%s", tableName)
 	return
 }
 
-func (*HandleT) DownloadIdentityRules(*misc.GZipWriter) (err error) {
+// identityColumnValues converts the csv record of an identity load file into the values of the columns.
+func identityColumnValues(columns, record []string) []interface{} {
+	values := make([]interface{}, len(record))
+	for i, value := range record {
+		if strings.TrimSpace(value) == "" {
+			continue
+		}
+		if columns[i] == "updated_at" {
+			if updatedAt, err := time.Parse(time.RFC3339, value); err == nil {
+				values[i] = updatedAt
+			}
+			continue
+		}
+		values[i] = stringColumnValue(value)
+	}
+	return values
+}
+
+// readLoadFile calls processRecord for every row of the gzipped csv load file.
+func readLoadFile(fileName string, columnCount int, processRecord func(record []string) error) error {
+	gzipFile, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer gzipFile.Close()
+	gzipReader, err := gzip.NewReader(gzipFile)
+	if err != nil {
+		return err
+	}
+	defer gzipReader.Close()
+
+	csvReader := csv.NewReader(gzipReader)
+	for {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(record) != columnCount {
+			return fmt.Errorf("load file CSV columns for a row mismatch: found %d, expected %d", len(record), columnCount)
+		}
+		if err = processRecord(record); err != nil {
+			return err
+		}
+	}
+}
+
+func (ms *HandleT) DownloadIdentityRules(gzWriter *misc.GZipWriter) (err error) {
+	for _, tableName := range warehouseutils.IdentityRulesTables {
+		fields, ok := warehouseutils.IdentityRulesSelectFields(ms.Uploader.GetTableSchemaInWarehouse(tableName))
+		if !ok {
+			pkgLogger.Infof("MS: anonymous_id, user_id columns not present in table: %s", tableName)
+			continue
+		}
+
+		sqlStatement := fmt.Sprintf(`SELECT DISTINCT %s FROM %q.%q`, fields, ms.Namespace, tableName)
+		pkgLogger.Infof("MS: Downloading distinct combinations of anonymous_id, user_id: %s", sqlStatement)
+		var rows *sql.Rows
+		if rows, err = ms.Db.Query(sqlStatement); err != nil {
+			return
+		}
+		if err = warehouseutils.WriteIdentityRules(rows, gzWriter); err != nil {
+			return
+		}
+	}
 	return
 }
 
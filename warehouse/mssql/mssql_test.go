@@ -92,6 +92,9 @@ func TestMSSQLIntegration(t *testing.T) {
 	testhelper.VerifyEventsInLoadFiles(t, warehouseTest, testhelper.LoadFilesEventsMap())
 	testhelper.VerifyEventsInTableUploads(t, warehouseTest, testhelper.TableUploadsEventsMap())
 	testhelper.VerifyEventsInWareHouse(t, warehouseTest, testhelper.WarehouseEventsMap())
+
+	// Scenario 3 (merge load mode, duplicate message ids)
+	testhelper.VerifyMergeLoadMode(t, warehouseTest, warehouseutils.MSSQL)
 }
 
 func TestMSSQLConfigurationValidation(t *testing.T) {
@@ -0,0 +1,80 @@
+package warehouse
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/tidwall/gjson"
+
+	"github.com/rudderlabs/rudder-server/utils/misc"
+	warehouseutils "github.com/rudderlabs/rudder-server/warehouse/utils"
+)
+
+// routedNamespaceKey is the key of the upload metadata holding the namespace its staging files were routed to
+const routedNamespaceKey = "routed_namespace"
+
+// routedWarehouse returns the warehouse the staging files routed to the namespace are uploaded to,
+// the default namespace of the warehouse if they were not routed to any
+func routedWarehouse(warehouse warehouseutils.Warehouse, routedNamespace string) warehouseutils.Warehouse {
+	warehouse.NamespaceRouting = true
+	warehouse.RoutedNamespace = routedNamespace
+	if routedNamespace != "" {
+		warehouse.Namespace = routedNamespace
+	}
+	return warehouse
+}
+
+// getRoutedNamespace returns the namespace the staging files of the upload were routed to, if the upload is of a routed warehouse
+func getRoutedNamespace(uploadMetadata []byte) (routedNamespace string, ok bool) {
+	result := gjson.GetBytes(uploadMetadata, routedNamespaceKey)
+	return result.String(), result.Exists()
+}
+
+// routedNamespaceSQL filters the staging files of a routed warehouse on its namespace.
+// The staging files of all the namespaces of a connection share the same ids, so the ranges of their uploads overlap.
+func routedNamespaceSQL(warehouse warehouseutils.Warehouse, column string) string {
+	if !warehouse.NamespaceRouting {
+		return ""
+	}
+	return fmt.Sprintf(`AND %s = %s`, column, misc.QuoteLiteral(warehouse.RoutedNamespace))
+}
+
+// routedUploadsSQL filters the uploads of a routed warehouse on the namespace their staging files were routed to.
+// Uploads created before the routing was enabled are of the default namespace.
+func routedUploadsSQL(warehouse warehouseutils.Warehouse) string {
+	if !warehouse.NamespaceRouting {
+		return ""
+	}
+	return fmt.Sprintf(`AND COALESCE(metadata ->> '%s', '') = %s`, routedNamespaceKey, misc.QuoteLiteral(warehouse.RoutedNamespace))
+}
+
+// getPendingRoutedNamespaces returns the namespaces the staging files waiting to be uploaded were routed to
+func (wh *HandleT) getPendingRoutedNamespaces(warehouse warehouseutils.Warehouse) ([]string, error) {
+	sqlStatement := fmt.Sprintf(`
+		SELECT
+		  DISTINCT namespace
+		FROM
+		  %s
+		WHERE
+		  source_id = $1
+		  AND destination_id = $2
+		  AND status = $3;
+`,
+		warehouseutils.WarehouseStagingFilesTable,
+	)
+	rows, err := wh.dbHandle.Query(sqlStatement, warehouse.Source.ID, warehouse.Destination.ID, warehouseutils.StagingFileWaitingState)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("query: %s failed with error: %w", sqlStatement, err)
+	}
+	defer rows.Close()
+
+	var namespaces []string
+	for rows.Next() {
+		var namespace string
+		if err := rows.Scan(&namespace); err != nil {
+			return nil, fmt.Errorf("failed to scan result from query: %s with error: %w", sqlStatement, err)
+		}
+		namespaces = append(namespaces, namespace)
+	}
+	return namespaces, rows.Err()
+}
@@ -0,0 +1,149 @@
+//go:build !warehouse_integration
+
+package warehouse
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/ory/dockertest/v3"
+
+	backendconfig "github.com/rudderlabs/rudder-server/config/backend-config"
+	"github.com/rudderlabs/rudder-server/testhelper"
+	"github.com/rudderlabs/rudder-server/testhelper/destination"
+	"github.com/rudderlabs/rudder-server/utils/logger"
+	warehouseutils "github.com/rudderlabs/rudder-server/warehouse/utils"
+)
+
+var _ = Describe("Namespace routing", func() {
+	warehouse := warehouseutils.Warehouse{
+		Source:      backendconfig.SourceT{ID: "test-sourceID"},
+		Destination: backendconfig.DestinationT{ID: "test-destinationID"},
+		Namespace:   "test_namespace",
+		Type:        "POSTGRES",
+	}
+
+	DescribeTable("Routed warehouse", func(routedNamespace, expectedNamespace string) {
+		routed := routedWarehouse(warehouse, routedNamespace)
+		Expect(routed.NamespaceRouting).To(BeTrue())
+		Expect(routed.RoutedNamespace).To(Equal(routedNamespace))
+		Expect(routed.Namespace).To(Equal(expectedNamespace))
+		Expect(routed.Identifier).To(Equal(warehouse.Identifier))
+	},
+		Entry("Default namespace", "", "test_namespace"),
+		Entry("Routed namespace", "acme", "acme"),
+	)
+
+	DescribeTable("Routed namespace of upload", func(metadata, expectedNamespace string, expectedOk bool) {
+		routedNamespace, ok := getRoutedNamespace([]byte(metadata))
+		Expect(ok).To(Equal(expectedOk))
+		Expect(routedNamespace).To(Equal(expectedNamespace))
+	},
+		Entry("Not routed", `{"priority": 100}`, "", false),
+		Entry("Default namespace", `{"routed_namespace": ""}`, "", true),
+		Entry("Routed namespace", `{"routed_namespace": "acme"}`, "acme", true),
+	)
+
+	It("Should filter staging files on the routed namespace", func() {
+		Expect(routedNamespaceSQL(warehouse, "ST.namespace")).To(BeEmpty())
+		Expect(routedNamespaceSQL(routedWarehouse(warehouse, ""), "ST.namespace")).To(Equal(`AND ST.namespace = ''`))
+		Expect(routedNamespaceSQL(routedWarehouse(warehouse, "o'acme"), "namespace")).To(Equal(`AND namespace = 'o''acme'`))
+	})
+
+	Describe("Pending staging files", Ordered, func() {
+		var (
+			pgResource *destination.PostgresResource
+			cleanup    = &testhelper.Cleanup{}
+			g          = GinkgoT()
+			wh         *HandleT
+		)
+
+		stagingFileIDs := func(stagingFiles []*StagingFileT) []int64 {
+			var ids []int64
+			for _, stagingFile := range stagingFiles {
+				ids = append(ids, stagingFile.ID)
+			}
+			return ids
+		}
+
+		BeforeAll(func() {
+			pool, err := dockertest.NewPool("")
+			Expect(err).To(BeNil())
+
+			pgResource = setupWarehouseJobs(pool, g, cleanup)
+
+			initWarehouse()
+
+			err = setupDB(context.TODO(), getConnectionString())
+			Expect(err).To(BeNil())
+
+			pkgLogger = logger.NOP
+
+			wh = &HandleT{
+				dbHandle:          pgResource.DB,
+				warehouseDBHandle: NewWarehouseDB(pgResource.DB),
+				destType:          "POSTGRES",
+			}
+
+			_, err = pgResource.DB.Exec(`
+				INSERT INTO wh_staging_files (id, location, schema, source_id, destination_id, status, total_events, namespace, created_at, updated_at)
+				VALUES
+				  (1, 'staging-1', '{}', 'test-sourceID', 'test-destinationID', 'waiting', 10, '', now(), now()),
+				  (2, 'staging-2', '{}', 'test-sourceID', 'test-destinationID', 'waiting', 20, 'acme', now(), now()),
+				  (3, 'staging-3', '{}', 'test-sourceID', 'test-destinationID', 'waiting', 30, 'globex', now(), now()),
+				  (4, 'staging-4', '{}', 'test-sourceID', 'test-destinationID', 'waiting', 40, 'acme', now(), now()),
+				  (5, 'staging-5', '{}', 'test-sourceID', 'test-destinationID', 'waiting', 50, '', now(), now());
+				INSERT INTO wh_uploads (id, source_id, namespace, destination_id, destination_type, start_staging_file_id, end_staging_file_id, start_load_file_id, end_load_file_id, status, schema, error, metadata, created_at, updated_at)
+				VALUES
+				  (1, 'test-sourceID', 'acme', 'test-destinationID', 'POSTGRES', 2, 2, 0, 0, 'waiting', '{}', '{}', '{"routed_namespace": "acme"}', now(), now());
+			`)
+			Expect(err).To(BeNil())
+		})
+
+		AfterAll(func() {
+			cleanup.Run()
+		})
+
+		It("Should list the routed namespaces with pending staging files", func() {
+			routedNamespaces, err := wh.getPendingRoutedNamespaces(warehouse)
+			Expect(err).To(BeNil())
+			Expect(routedNamespaces).To(ConsistOf("", "acme", "globex"))
+		})
+
+		It("Should get the pending staging files of the routed namespace", func() {
+			stagingFiles, err := wh.getPendingStagingFiles(routedWarehouse(warehouse, ""))
+			Expect(err).To(BeNil())
+			Expect(stagingFileIDs(stagingFiles)).To(Equal([]int64{1, 5}))
+
+			stagingFiles, err = wh.getPendingStagingFiles(routedWarehouse(warehouse, "acme"))
+			Expect(err).To(BeNil())
+			Expect(stagingFileIDs(stagingFiles)).To(Equal([]int64{4}))
+
+			stagingFiles, err = wh.getPendingStagingFiles(routedWarehouse(warehouse, "globex"))
+			Expect(err).To(BeNil())
+			Expect(stagingFileIDs(stagingFiles)).To(Equal([]int64{3}))
+		})
+
+		It("Should get the staging files of the routed namespace in the upload range", func() {
+			stagingFiles, err := wh.getStagingFiles(routedWarehouse(warehouse, "acme"), 1, 5)
+			Expect(err).To(BeNil())
+			Expect(stagingFileIDs(stagingFiles)).To(Equal([]int64{2, 4}))
+
+			stagingFiles, err = wh.getStagingFiles(warehouse, 1, 5)
+			Expect(err).To(BeNil())
+			Expect(stagingFileIDs(stagingFiles)).To(Equal([]int64{1, 2, 3, 4, 5}))
+		})
+
+		It("Should get the latest upload of the routed namespace", func() {
+			acme := routedWarehouse(warehouse, "acme")
+			uploadID, status, _ := wh.getLatestUploadStatus(&acme)
+			Expect(uploadID).To(Equal(int64(1)))
+			Expect(status).To(Equal(Waiting))
+
+			globex := routedWarehouse(warehouse, "globex")
+			uploadID, _, _ = wh.getLatestUploadStatus(&globex)
+			Expect(uploadID).To(BeZero())
+		})
+	})
+})
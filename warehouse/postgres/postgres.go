@@ -106,17 +106,20 @@ type CredentialsT struct {
 	timeout  time.Duration
 }
 
-var primaryKeyMap = map[string]string{
-	warehouseutils.UsersTable:      "id",
-	warehouseutils.IdentifiesTable: "id",
-	warehouseutils.DiscardsTable:   "row_id",
+// primaryKeyMap are the columns deduplicating the rows of the tables in the merge load mode, defaulting to id
+var primaryKeyMap = map[string][]string{
+	warehouseutils.UsersTable:      {"id"},
+	warehouseutils.IdentifiesTable: {"id"},
+	warehouseutils.DiscardsTable:   {"row_id", "column_name", "table_name"},
 }
 
-var partitionKeyMap = map[string]string{
-	warehouseutils.UsersTable:      "id",
-	warehouseutils.IdentifiesTable: "id",
-	warehouseutils.DiscardsTable:   "row_id, column_name, table_name",
-}
+var (
+	identityMergeRulesColumns = []string{"merge_property_1_type", "merge_property_1_value", "merge_property_2_type", "merge_property_2_value"}
+	identityMappingsColumns   = []string{"merge_property_type", "merge_property_value", "rudder_id", "updated_at"}
+)
+
+// identityStagingRowIDColumn orders the rows copied into the identity mappings staging table
+const identityStagingRowIDColumn = "_rudder_staging_row_id"
 
 func Connect(cred CredentialsT) (*sql.DB, error) {
 	url := fmt.Sprintf("user=%v password=%v host=%v port=%v dbname=%v sslmode=%v",
@@ -181,6 +184,10 @@ func (*HandleT) IsEmpty(_ warehouseutils.Warehouse) (empty bool, err error) {
 
 func (pg *HandleT) DownloadLoadFiles(tableName string) ([]string, error) {
 	objects := pg.Uploader.GetLoadFilesMetadata(warehouseutils.GetLoadFilesOptionsT{Table: tableName})
+	return pg.downloadLoadFiles(tableName, objects)
+}
+
+func (pg *HandleT) downloadLoadFiles(tableName string, objects []warehouseutils.LoadFileT) ([]string, error) {
 	storageProvider := warehouseutils.ObjectStorageType(pg.Warehouse.Destination.DestinationDefinition.Name, pg.Warehouse.Destination.Config, pg.Uploader.UseRudderStorage())
 	downloader, err := filemanager.DefaultFileManagerFactory.New(&filemanager.SettingsT{
 		Provider: storageProvider,
@@ -378,35 +385,31 @@ func (pg *HandleT) loadTable(tableName string, tableSchemaInUpload warehouseutil
 		return
 
 	}
-	// deduplication process
-	primaryKey := "id"
-	if column, ok := primaryKeyMap[tableName]; ok {
-		primaryKey = column
-	}
-	partitionKey := "id"
-	if column, ok := partitionKeyMap[tableName]; ok {
-		partitionKey = column
-	}
-	var additionalJoinClause string
-	if tableName == warehouseutils.DiscardsTable {
-		additionalJoinClause = fmt.Sprintf(`AND _source.%[3]s = "%[1]s"."%[2]s"."%[3]s" AND _source.%[4]s = "%[1]s"."%[2]s"."%[4]s"`, pg.Namespace, tableName, "table_name", "column_name")
-	}
-	sqlStatement = fmt.Sprintf(`DELETE FROM "%[1]s"."%[2]s" USING "%[1]s"."%[3]s" as  _source where (_source.%[4]s = "%[1]s"."%[2]s"."%[4]s" %[5]s)`, pg.Namespace, tableName, stagingTableName, primaryKey, additionalJoinClause)
-	pkgLogger.Infof("PG: Deduplicate records for table:%s using staging table: %s\n", tableName, sqlStatement)
-	err = handleExec(&QueryParams{txn: txn, query: sqlStatement, enableWithQueryPlan: enableSQLStatementExecutionPlan})
-	if err != nil {
-		pkgLogger.Errorf("PG: Error deleting from original table for dedup: %v\n", err)
-		tags["stage"] = deleteDedup
-		runRollbackWithTimeout(txn.Rollback, handleRollbackTimeout, txnRollbackTimeout, tags)
-		return
-	}
-
 	quotedColumnNames := warehouseutils.DoubleQuoteAndJoinByComma(sortedColumnKeys)
-	sqlStatement = fmt.Sprintf(`INSERT INTO "%[1]s"."%[2]s" (%[3]s)
+	if pg.Warehouse.LoadMode(warehouseutils.LoadModeMerge) == warehouseutils.LoadModeAppend {
+		sqlStatement = fmt.Sprintf(`INSERT INTO "%[1]s"."%[2]s" (%[3]s) SELECT %[3]s FROM "%[1]s"."%[4]s"`, pg.Namespace, tableName, quotedColumnNames, stagingTableName)
+	} else {
+		// deduplication process
+		primaryKeys := pg.primaryKeys(tableName)
+		joinClause := warehouseutils.JoinWithFormatting(primaryKeys, func(_ int, key string) string {
+			return fmt.Sprintf(`_source.%[3]q = "%[1]s"."%[2]s".%[3]q`, pg.Namespace, tableName, key)
+		}, " AND ")
+		sqlStatement = fmt.Sprintf(`DELETE FROM "%[1]s"."%[2]s" USING "%[1]s"."%[3]s" as  _source where (%[4]s)`, pg.Namespace, tableName, stagingTableName, joinClause)
+		pkgLogger.Infof("PG: Deduplicate records for table:%s using staging table: %s\n", tableName, sqlStatement)
+		err = handleExec(&QueryParams{txn: txn, query: sqlStatement, enableWithQueryPlan: enableSQLStatementExecutionPlan})
+		if err != nil {
+			pkgLogger.Errorf("PG: Error deleting from original table for dedup: %v\n", err)
+			tags["stage"] = deleteDedup
+			runRollbackWithTimeout(txn.Rollback, handleRollbackTimeout, txnRollbackTimeout, tags)
+			return
+		}
+
+		sqlStatement = fmt.Sprintf(`INSERT INTO "%[1]s"."%[2]s" (%[3]s)
 									SELECT %[3]s FROM (
 										SELECT *, row_number() OVER (PARTITION BY %[5]s ORDER BY received_at DESC) AS _rudder_staging_row_number FROM "%[1]s"."%[4]s"
 									) AS _ where _rudder_staging_row_number = 1
-									`, pg.Namespace, tableName, quotedColumnNames, stagingTableName, partitionKey)
+									`, pg.Namespace, tableName, quotedColumnNames, stagingTableName, warehouseutils.DoubleQuoteAndJoinByComma(primaryKeys))
+	}
 	pkgLogger.Infof("PG: Inserting records for table:%s using staging table: %s\n", tableName, sqlStatement)
 	err = handleExec(&QueryParams{txn: txn, query: sqlStatement, enableWithQueryPlan: enableSQLStatementExecutionPlan})
 
@@ -458,6 +461,18 @@ func (pq *HandleT) DeleteBy(tableNames []string, params warehouseutils.DeleteByP
 	return nil
 }
 
+// PruneTable deletes the rows of the table older than the retention, one window of time at a time
+func (pq *HandleT) PruneTable(ctx context.Context, tableName string, params warehouseutils.RetentionParamsT) (warehouseutils.RetentionStatsT, error) {
+	pkgLogger.Infof("PG: Pruning rows with %s before %s in table %s for PG:%s", params.Column, params.Before, tableName, pq.Warehouse.Destination.ID)
+	table := fmt.Sprintf(`"%s"."%s"`, pq.Namespace, tableName)
+	batchedDelete := warehouseutils.BatchedDeleteT{
+		Db:          pq.Db,
+		OldestQuery: fmt.Sprintf(`SELECT MIN(%q) FROM %s`, params.Column, table),
+		DeleteQuery: fmt.Sprintf(`DELETE FROM %[1]s WHERE %[2]q >= $1 AND %[2]q < $2`, table, params.Column),
+	}
+	return batchedDelete.Run(ctx, params)
+}
+
 func (pg *HandleT) loadUserTables() (errorMap map[string]error) {
 	errorMap = map[string]error{warehouseutils.IdentifiesTable: nil}
 	sqlStatement := fmt.Sprintf(`SET search_path to %q`, pg.Namespace)
@@ -671,7 +686,22 @@ func (pg *HandleT) AddColumn(tableName, columnName, columnType string) (err erro
 	return err
 }
 
-func (*HandleT) AlterColumn(_, _, _ string) (err error) {
+// AlterColumn changes the type of the column in place, casting the existing values to the new type
+func (pg *HandleT) AlterColumn(tableName, columnName, columnType string) (response warehouseutils.AlterColumnResponseT, err error) {
+	// text is stored as text in postgres same as string
+	dataType, ok := rudderDataTypesMapToPostgres[columnType]
+	if !ok {
+		return
+	}
+	sqlStatement := fmt.Sprintf(`ALTER TABLE %[1]q.%[2]q ALTER COLUMN %[3]q TYPE %[4]s USING %[3]q::%[4]s`, pg.Namespace, tableName, columnName, dataType)
+	pkgLogger.Infof("PG: Altering column in postgres for PG:%s : %v", pg.Warehouse.Destination.ID, sqlStatement)
+	if _, err = pg.Db.Exec(sqlStatement); err != nil {
+		return
+	}
+	response = warehouseutils.AlterColumnResponseT{
+		Strategy: warehouseutils.AlterColumnInPlace,
+		Queries:  []string{sqlStatement},
+	}
 	return
 }
 
@@ -714,6 +744,15 @@ func (pg *HandleT) Setup(warehouse warehouseutils.Warehouse, uploader warehouseu
 	return err
 }
 
+// SetupDryRun sets up the warehouse like Setup, running statements on db instead of connecting to postgres
+func (pg *HandleT) SetupDryRun(warehouse warehouseutils.Warehouse, uploader warehouseutils.UploaderI, db *sql.DB) {
+	pg.Warehouse = warehouse
+	pg.Namespace = warehouse.Namespace
+	pg.Uploader = uploader
+	pg.ObjectStorage = warehouseutils.ObjectStorageType(warehouseutils.POSTGRES, warehouse.Destination.Config, pg.Uploader.UseRudderStorage())
+	pg.Db = db
+}
+
 func (pg *HandleT) CrashRecover(warehouse warehouseutils.Warehouse) (err error) {
 	pg.Warehouse = warehouse
 	pg.Namespace = warehouse.Namespace
@@ -829,6 +868,15 @@ func (pg *HandleT) LoadUserTables() map[string]error {
 	return pg.loadUserTables()
 }
 
+// primaryKeys returns the columns deduplicating the rows of tableName in the merge load mode
+func (pg *HandleT) primaryKeys(tableName string) []string {
+	keys, ok := primaryKeyMap[tableName]
+	if !ok {
+		keys = []string{"id"}
+	}
+	return pg.Warehouse.MergePrimaryKeys(tableName, keys)
+}
+
 func (pg *HandleT) LoadTable(tableName string) error {
 	_, err := pg.loadTable(tableName, pg.Uploader.GetTableSchemaInUpload(tableName), false)
 	return err
@@ -841,15 +889,201 @@ func (pg *HandleT) Cleanup() {
 	}
 }
 
-func (*HandleT) LoadIdentityMergeRulesTable() (err error) {
-	return
+func (pg *HandleT) LoadIdentityMergeRulesTable() (err error) {
+	return pg.loadIdentityTable(warehouseutils.IdentityMergeRulesTable, identityMergeRulesColumns, false)
+}
+
+func (pg *HandleT) LoadIdentityMappingsTable() (err error) {
+	return pg.loadIdentityTable(warehouseutils.IdentityMappingsTable, identityMappingsColumns, true)
 }
 
-func (*HandleT) LoadIdentityMappingsTable() (err error) {
+// loadIdentityTable loads the identity load file generated for the upload into tableName through a staging table.
+// Merge rules are inserted unless already present, whereas mappings are upserted on merge property keeping the last mapping in the load file.
+func (pg *HandleT) loadIdentityTable(tableName string, columns []string, upsert bool) (err error) {
+	pkgLogger.Infof("PG: Starting load for identity table:%s", tableName)
+	loadFile, err := pg.Uploader.GetSingleLoadFile(tableName)
+	if err != nil {
+		return
+	}
+	fileNames, err := pg.downloadLoadFiles(tableName, []warehouseutils.LoadFileT{loadFile})
+	defer misc.RemoveFilePaths(fileNames...)
+	if err != nil {
+		return
+	}
+
+	tags := map[string]string{
+		"workspaceId":   pg.Warehouse.WorkspaceID,
+		"namepsace":     pg.Namespace,
+		"destinationID": pg.Warehouse.Destination.ID,
+		"tableName":     tableName,
+	}
+	copyTableName := warehouseutils.StagingTableName(provider, tableName, tableNameLimit)
+	defer pg.dropStagingTable(copyTableName)
+
+	txn, err := pg.Db.Begin()
+	if err != nil {
+		pkgLogger.Errorf("PG: Error while beginning a transaction in db for loading in table:%s: %v", tableName, err)
+		return
+	}
+	defer func() {
+		if err != nil {
+			runRollbackWithTimeout(txn.Rollback, handleRollbackTimeout, txnRollbackTimeout, tags)
+		}
+	}()
+
+	sqlStatement := fmt.Sprintf(`CREATE TABLE "%[1]s".%[2]s (LIKE "%[1]s"."%[3]s")`, pg.Namespace, copyTableName, tableName)
+	if upsert {
+		// the serial row id keeps the order of the mappings in the load file
+		sqlStatement = fmt.Sprintf(`CREATE TABLE "%[1]s".%[2]s (LIKE "%[1]s"."%[3]s", %[4]s bigserial)`, pg.Namespace, copyTableName, tableName, identityStagingRowIDColumn)
+	}
+	pkgLogger.Infof("PG: Creating staging table for identity table:%s at %s", tableName, sqlStatement)
+	if _, err = txn.Exec(sqlStatement); err != nil {
+		tags["stage"] = createStagingTable
+		return
+	}
+
+	stmt, err := txn.Prepare(pq.CopyInSchema(pg.Namespace, copyTableName, columns...))
+	if err != nil {
+		tags["stage"] = copyInSchemaStagingTable
+		return
+	}
+	for _, fileName := range fileNames {
+		if err = copyLoadFile(stmt, fileName, len(columns)); err != nil {
+			pkgLogger.Errorf("PG: Error loading file %s into table:%s: %v", fileName, copyTableName, err)
+			tags["stage"] = loadStagingTable
+			return
+		}
+	}
+	if _, err = stmt.Exec(); err != nil {
+		tags["stage"] = stagingTableloadStage
+		return
+	}
+
+	if upsert {
+		sqlStatement = fmt.Sprintf(`DELETE FROM "%[1]s"."%[2]s" USING "%[1]s"."%[3]s" AS _source WHERE _source.merge_property_type = "%[1]s"."%[2]s".merge_property_type AND _source.merge_property_value = "%[1]s"."%[2]s".merge_property_value`, pg.Namespace, tableName, copyTableName)
+		pkgLogger.Infof("PG: Deduplicate records for identity table:%s using staging table: %s", tableName, sqlStatement)
+		if _, err = txn.Exec(sqlStatement); err != nil {
+			tags["stage"] = deleteDedup
+			return
+		}
+
+		quotedColumnNames := warehouseutils.DoubleQuoteAndJoinByComma(columns)
+		sqlStatement = fmt.Sprintf(`INSERT INTO "%[1]s"."%[2]s" (%[3]s)
+									SELECT %[3]s FROM (
+										SELECT *, row_number() OVER (PARTITION BY merge_property_type, merge_property_value ORDER BY %[5]s DESC) AS _rudder_staging_row_number FROM "%[1]s"."%[4]s"
+									) AS _ WHERE _rudder_staging_row_number = 1`, pg.Namespace, tableName, quotedColumnNames, copyTableName, identityStagingRowIDColumn)
+		pkgLogger.Infof("PG: Inserting records for identity table:%s using staging table: %s", tableName, sqlStatement)
+		if _, err = txn.Exec(sqlStatement); err != nil {
+			tags["stage"] = insertDedup
+			return
+		}
+	} else {
+		if err = pg.createIdentityUniqueIndex(txn, tableName, columns); err != nil {
+			tags["stage"] = deleteDedup
+			return
+		}
+
+		quotedColumnNames := warehouseutils.DoubleQuoteAndJoinByComma(columns)
+		sqlStatement = fmt.Sprintf(`INSERT INTO "%[1]s"."%[2]s" (%[3]s) SELECT %[3]s FROM "%[1]s"."%[4]s" ON CONFLICT DO NOTHING`, pg.Namespace, tableName, quotedColumnNames, copyTableName)
+		pkgLogger.Infof("PG: Inserting records for identity table:%s using staging table: %s", tableName, sqlStatement)
+		if _, err = txn.Exec(sqlStatement); err != nil {
+			tags["stage"] = insertDedup
+			return
+		}
+	}
+
+	if err = txn.Commit(); err != nil {
+		tags["stage"] = dedupStage
+		return
+	}
+	pkgLogger.Infof("PG: Complete load for identity table:%s", tableName)
 	return
 }
 
-func (*HandleT) DownloadIdentityRules(*misc.GZipWriter) (err error) {
+// createIdentityUniqueIndex creates the unique index on columns of tableName which skips the rows already loaded, if missing.
+// The rows duplicated by the loads appending to the table before the index existed are deleted first.
+// Null values are indexed as empty strings, since the rules merging a single property have no second property.
+func (pg *HandleT) createIdentityUniqueIndex(txn *sql.Tx, tableName string, columns []string) error {
+	indexName := fmt.Sprintf(`%s_unique`, tableName)
+	var exists bool
+	if err := txn.QueryRow(`SELECT to_regclass($1) IS NOT NULL`, fmt.Sprintf(`"%s"."%s"`, pg.Namespace, indexName)).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	var conditions, expressions []string
+	for _, column := range columns {
+		conditions = append(conditions, fmt.Sprintf(`_duplicate.%[1]q IS NOT DISTINCT FROM "%[2]s"."%[3]s".%[1]q`, column, pg.Namespace, tableName))
+		expressions = append(expressions, fmt.Sprintf(`COALESCE(%q, '')`, column))
+	}
+	sqlStatement := fmt.Sprintf(`DELETE FROM "%[1]s"."%[2]s" USING "%[1]s"."%[2]s" AS _duplicate WHERE _duplicate.ctid < "%[1]s"."%[2]s".ctid AND %[3]s`, pg.Namespace, tableName, strings.Join(conditions, " AND "))
+	pkgLogger.Infof("PG: Deduplicate records for identity table:%s before indexing: %s", tableName, sqlStatement)
+	if _, err := txn.Exec(sqlStatement); err != nil {
+		return err
+	}
+	sqlStatement = fmt.Sprintf(`CREATE UNIQUE INDEX %[1]q ON "%[2]s"."%[3]s" (%[4]s)`, indexName, pg.Namespace, tableName, strings.Join(expressions, ", "))
+	pkgLogger.Infof("PG: Creating unique index for identity table:%s: %s", tableName, sqlStatement)
+	_, err := txn.Exec(sqlStatement)
+	return err
+}
+
+// copyLoadFile copies the rows of the gzipped csv load file into the prepared copy statement.
+func copyLoadFile(stmt *sql.Stmt, fileName string, columnCount int) error {
+	gzipFile, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer gzipFile.Close()
+	gzipReader, err := gzip.NewReader(gzipFile)
+	if err != nil {
+		return err
+	}
+	defer gzipReader.Close()
+
+	csvReader := csv.NewReader(gzipReader)
+	for {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(record) != columnCount {
+			return fmt.Errorf("load file CSV columns for a row mismatch: found %d, expected %d", len(record), columnCount)
+		}
+		recordInterface := make([]interface{}, len(record))
+		for i, value := range record {
+			if strings.TrimSpace(value) != "" {
+				recordInterface[i] = value
+			}
+		}
+		if _, err = stmt.Exec(recordInterface...); err != nil {
+			return err
+		}
+	}
+}
+
+func (pg *HandleT) DownloadIdentityRules(gzWriter *misc.GZipWriter) (err error) {
+	for _, tableName := range warehouseutils.IdentityRulesTables {
+		fields, ok := warehouseutils.IdentityRulesSelectFields(pg.Uploader.GetTableSchemaInWarehouse(tableName))
+		if !ok {
+			pkgLogger.Infof("PG: anonymous_id, user_id columns not present in table: %s", tableName)
+			continue
+		}
+
+		sqlStatement := fmt.Sprintf(`SELECT DISTINCT %s FROM %q.%q`, fields, pg.Namespace, tableName)
+		pkgLogger.Infof("PG: Downloading distinct combinations of anonymous_id, user_id: %s", sqlStatement)
+		var rows *sql.Rows
+		if rows, err = pg.Db.Query(sqlStatement); err != nil {
+			return
+		}
+		if err = warehouseutils.WriteIdentityRules(rows, gzWriter); err != nil {
+			return
+		}
+	}
 	return
 }
 
@@ -91,6 +91,9 @@ func TestPostgresIntegration(t *testing.T) {
 	testhelper.VerifyEventsInLoadFiles(t, warehouseTest, testhelper.LoadFilesEventsMap())
 	testhelper.VerifyEventsInTableUploads(t, warehouseTest, testhelper.TableUploadsEventsMap())
 	testhelper.VerifyEventsInWareHouse(t, warehouseTest, testhelper.WarehouseEventsMap())
+
+	// Scenario 3 (merge load mode, duplicate message ids)
+	testhelper.VerifyMergeLoadMode(t, warehouseTest, warehouseutils.POSTGRES)
 }
 
 func TestPostgresConfigurationValidation(t *testing.T) {
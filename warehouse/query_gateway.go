@@ -0,0 +1,526 @@
+package warehouse
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"google.golang.org/api/iterator"
+
+	proto "github.com/rudderlabs/rudder-server/proto/warehouse"
+	"github.com/rudderlabs/rudder-server/utils/misc"
+	"github.com/rudderlabs/rudder-server/warehouse/client"
+	"github.com/rudderlabs/rudder-server/warehouse/manager"
+	warehouseutils "github.com/rudderlabs/rudder-server/warehouse/utils"
+)
+
+// formats the results of the query gateway are written in
+const (
+	QueryFormatJSON = "json"
+	QueryFormatCSV  = "csv"
+)
+
+// readOnlyRoleKey is the key of the destination config holding the role queries run under:
+// the role used on snowflake and the database user impersonated on mssql and azure synapse, where it is required,
+// and the role set on postgres, where queries also run in a read-only transaction
+const readOnlyRoleKey = "readOnlyRole"
+
+// queryStreamBatchSize is the number of rows sent in a message of the query stream and written between flushes of the http response
+const queryStreamBatchSize = 100
+
+// readOnlyQueryDestinations are the warehouses the query gateway can query, each of them enforcing the queries to be read-only
+var readOnlyQueryDestinations = []string{
+	warehouseutils.POSTGRES,
+	warehouseutils.RS,
+	warehouseutils.SNOWFLAKE,
+	warehouseutils.BQ,
+	warehouseutils.CLICKHOUSE,
+	warehouseutils.MSSQL,
+	warehouseutils.AZURE_SYNAPSE,
+	warehouseutils.SQLITE,
+}
+
+var (
+	// queryLiteralsRegexes match the string literals and quoted identifiers of a statement.
+	// Warehouses disagree on whether a backslash escapes a quote, so a statement is checked with either reading of its literals.
+	queryLiteralsRegexes = []*regexp.Regexp{
+		regexp.MustCompile("'(?:[^']|'')*'|\"(?:[^\"]|\"\")*\"|`[^`]*`"),
+		regexp.MustCompile("'(?:[^'\\\\]|''|\\\\.)*'|\"(?:[^\"\\\\]|\"\"|\\\\.)*\"|`(?:[^`\\\\]|\\\\.)*`"),
+	}
+	// queryForbiddenKeywordsRegex matches the keywords of the statements changing the warehouse, which can be nested in a select
+	queryForbiddenKeywordsRegex = regexp.MustCompile(`(?i)\b(INSERT|UPDATE|DELETE|MERGE|CREATE|ALTER|DROP|TRUNCATE|GRANT|REVOKE|COPY|UNLOAD|CALL|EXEC|EXECUTE|INTO|SET|LOCK|VACUUM)\b`)
+	// queryForbiddenFunctionsRegex matches the functions with side effects a select can call, which read-only transactions do not all prevent
+	queryForbiddenFunctionsRegex = regexp.MustCompile(`(?i)\b(pg_terminate_backend|pg_cancel_backend|pg_reload_conf|pg_rotate_logfile|pg_sleep\w*|pg_advisory\w*|pg_read_\w*|pg_ls_\w*|setval|nextval|dblink\w*|lo_\w+|system\$\w+)\s*\(`)
+	querySelectRegex             = regexp.MustCompile(`(?i)^(SELECT|WITH)\b`)
+	readOnlyRoleRegex            = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_$]*$`)
+)
+
+// validateReadOnlyQuery returns the statement without its trailing semicolons if it is a single select statement, with or without common table expressions.
+// Statements with comments, several statements, keywords of statements changing the warehouse and functions with side effects are rejected,
+// even when the keyword is only a column name, as they are checked outside of the string literals only.
+// It is only a first filter, the warehouses running the queries in a read-only transaction or under a read-only role.
+func validateReadOnlyQuery(sqlStatement string) (string, error) {
+	statement := strings.TrimRight(strings.TrimSpace(sqlStatement), "; \t\r\n")
+	if statement == "" {
+		return "", errors.New("sql_statement is empty")
+	}
+	if !querySelectRegex.MatchString(statement) {
+		return "", errors.New("only select statements are allowed")
+	}
+	for _, literalsRegex := range queryLiteralsRegexes {
+		unquoted := literalsRegex.ReplaceAllString(statement, "''")
+		if strings.Contains(unquoted, ";") {
+			return "", errors.New("only a single statement is allowed")
+		}
+		if strings.Contains(unquoted, "--") || strings.Contains(unquoted, "/*") || strings.Contains(unquoted, "#") {
+			return "", errors.New("comments are not allowed")
+		}
+		if keyword := queryForbiddenKeywordsRegex.FindString(unquoted); keyword != "" {
+			return "", fmt.Errorf("keyword %s is not allowed", strings.ToUpper(keyword))
+		}
+		if function := queryForbiddenFunctionsRegex.FindStringSubmatch(unquoted); function != nil {
+			return "", fmt.Errorf("function %s is not allowed", strings.ToLower(function[1]))
+		}
+	}
+	return statement, nil
+}
+
+// readOnlyStatement limits the rows returned by the select statement.
+// The rows returned on mssql and azure synapse are limited by the session instead, as they do not allow common table expressions in a subquery.
+func readOnlyStatement(destType, statement string, limit int) string {
+	switch destType {
+	case warehouseutils.MSSQL, warehouseutils.AZURE_SYNAPSE:
+		return statement
+	case warehouseutils.CLICKHOUSE:
+		return fmt.Sprintf(`SELECT * FROM (%s) AS rudder_query LIMIT %d SETTINGS readonly = 1`, statement, limit)
+	}
+	return fmt.Sprintf(`SELECT * FROM (%s) AS rudder_query LIMIT %d`, statement, limit)
+}
+
+// readOnlyRole returns the role of the warehouse queries run under, an error if the warehouse requires one and it is not configured
+func readOnlyRole(warehouse warehouseutils.Warehouse) (string, error) {
+	role := warehouseutils.GetConfigValue(readOnlyRoleKey, warehouse)
+	if role != "" && !readOnlyRoleRegex.MatchString(role) {
+		return "", fmt.Errorf("invalid %s: %s", readOnlyRoleKey, role)
+	}
+	switch warehouse.Type {
+	case warehouseutils.SNOWFLAKE, warehouseutils.MSSQL, warehouseutils.AZURE_SYNAPSE:
+		if role == "" {
+			return "", fmt.Errorf("%s is not configured for destination: %s", readOnlyRoleKey, warehouse.Destination.ID)
+		}
+	}
+	return role, nil
+}
+
+// queryerI runs the statements of a read-only session
+type queryerI interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// readOnlySession makes the statements run on the connection read-only,
+// in a read-only transaction on postgres and redshift, under the read-only role on snowflake, mssql and azure synapse and in query only mode on sqlite.
+// Clickhouse queries are made read-only by their settings.
+func readOnlySession(ctx context.Context, conn *sql.Conn, warehouse warehouseutils.Warehouse, limit int) (queryer queryerI, release func(), err error) {
+	role, err := readOnlyRole(warehouse)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch warehouse.Type {
+	case warehouseutils.POSTGRES, warehouseutils.RS:
+		tx, err := conn.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+		if err != nil {
+			return nil, nil, err
+		}
+		release = func() { _ = tx.Rollback() }
+		if role != "" && warehouse.Type == warehouseutils.POSTGRES {
+			if _, err = tx.ExecContext(ctx, fmt.Sprintf(`SET LOCAL ROLE %s`, role)); err != nil {
+				release()
+				return nil, nil, err
+			}
+		}
+		return tx, release, nil
+	case warehouseutils.SNOWFLAKE:
+		_, err = conn.ExecContext(ctx, fmt.Sprintf(`USE ROLE %s`, role))
+	case warehouseutils.MSSQL, warehouseutils.AZURE_SYNAPSE:
+		_, err = conn.ExecContext(ctx, fmt.Sprintf(`EXECUTE AS USER = %s; SET ROWCOUNT %d`, quoteSQLLiteral(warehouse.Type, role), limit))
+	case warehouseutils.SQLITE:
+		_, err = conn.ExecContext(ctx, `PRAGMA query_only = ON`)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return conn, func() {}, nil
+}
+
+// queryValue formats a value scanned from the warehouse, a null being an empty string
+func queryValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// queryResultWriterI receives the result of a query as its rows are scanned
+type queryResultWriterI interface {
+	WriteColumns(columns []string) error
+	WriteRow(values []string) error
+	Close(truncated bool) error
+}
+
+// sqlReadOnlyQuery streams the rows of the statement run in a read-only session of the sql warehouse
+func sqlReadOnlyQuery(ctx context.Context, db *sql.DB, warehouse warehouseutils.Warehouse, statement string, limit int, w queryResultWriterI) (truncated bool, err error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	queryer, release, err := readOnlySession(ctx, conn, warehouse, limit+1)
+	if err != nil {
+		return false, err
+	}
+	defer release()
+
+	rows, err := queryer.QueryContext(ctx, readOnlyStatement(warehouse.Type, statement, limit+1))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return false, err
+	}
+	if err = w.WriteColumns(columns); err != nil {
+		return false, err
+	}
+
+	values := make([]interface{}, len(columns))
+	valuePtrs := make([]interface{}, len(columns))
+	for i := range values {
+		valuePtrs[i] = &values[i]
+	}
+	var count int
+	for rows.Next() {
+		if count == limit {
+			return true, nil
+		}
+		if err = rows.Scan(valuePtrs...); err != nil {
+			return false, err
+		}
+		row := make([]string, len(values))
+		for i, value := range values {
+			row[i] = queryValue(value)
+		}
+		if err = w.WriteRow(row); err != nil {
+			return false, err
+		}
+		count++
+	}
+	return false, rows.Err()
+}
+
+// bqReadOnlyQuery streams the rows of the statement, once a dry run of it on bigquery confirms it is a select
+func bqReadOnlyQuery(ctx context.Context, bq *bigquery.Client, statement string, limit int, w queryResultWriterI) (truncated bool, err error) {
+	query := bq.Query(readOnlyStatement(warehouseutils.BQ, statement, limit+1))
+	query.DryRun = true
+	job, err := query.Run(ctx)
+	if err != nil {
+		return false, err
+	}
+	if status := job.LastStatus(); status == nil || status.Statistics == nil {
+		return false, errors.New("dry run of the query returned no statistics")
+	} else if details, ok := status.Statistics.Details.(*bigquery.QueryStatistics); !ok || details.StatementType != "SELECT" {
+		return false, errors.New("only select statements are allowed")
+	}
+
+	query.DryRun = false
+	job, err = query.Run(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if ctx.Err() != nil {
+			_ = job.Cancel(context.Background())
+		}
+	}()
+	it, err := job.Read(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	var count int
+	columnsWritten := false
+	for {
+		var values []bigquery.Value
+		err = it.Next(&values)
+		if err != nil && err != iterator.Done {
+			return false, err
+		}
+		if !columnsWritten {
+			columns := make([]string, 0, len(it.Schema))
+			for _, field := range it.Schema {
+				columns = append(columns, field.Name)
+			}
+			if err := w.WriteColumns(columns); err != nil {
+				return false, err
+			}
+			columnsWritten = true
+		}
+		if err == iterator.Done {
+			return false, nil
+		}
+		if count == limit {
+			return true, nil
+		}
+		row := make([]string, len(values))
+		for i, value := range values {
+			row[i] = queryValue(value)
+		}
+		if err = w.WriteRow(row); err != nil {
+			return false, err
+		}
+		count++
+	}
+}
+
+// runReadOnlyQuery streams at most limit rows of the select statement run on the warehouse, returning whether more rows were left out.
+// The query is cancelled on the warehouse after Warehouse.query.timeout.
+func runReadOnlyQuery(ctx context.Context, warehouse warehouseutils.Warehouse, sqlStatement string, limit int, w queryResultWriterI) (bool, error) {
+	statement, err := validateReadOnlyQuery(sqlStatement)
+	if err != nil {
+		return false, err
+	}
+	if !misc.Contains(readOnlyQueryDestinations, warehouse.Type) {
+		return false, fmt.Errorf("querying is not supported for destination type: %s", warehouse.Type)
+	}
+
+	whManager, err := manager.New(warehouse.Type)
+	if err != nil {
+		return false, err
+	}
+	whManager.SetConnectionTimeout(queryGatewayTimeout)
+	whClient, err := whManager.Connect(warehouse)
+	if err != nil {
+		return false, err
+	}
+	defer whClient.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, queryGatewayTimeout)
+	defer cancel()
+
+	var truncated bool
+	switch whClient.Type {
+	case client.SQLClient:
+		truncated, err = sqlReadOnlyQuery(ctx, whClient.SQL, warehouse, statement, limit, w)
+	case client.BQClient:
+		truncated, err = bqReadOnlyQuery(ctx, whClient.BQ, statement, limit, w)
+	default:
+		return false, fmt.Errorf("querying is not supported for destination type: %s", warehouse.Type)
+	}
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return false, fmt.Errorf("query did not complete within %s: %w", queryGatewayTimeout, err)
+	}
+	return truncated, err
+}
+
+// QueryReqT runs a read-only query on the warehouse of a connection of a workspace
+type QueryReqT struct {
+	WorkspaceID   string
+	SourceID      string
+	DestinationID string
+	SQLStatement  string
+	Limit         int32
+	API           UploadAPIT
+}
+
+func (queryReq *QueryReqT) validateReq() error {
+	if !queryReq.API.enabled || queryReq.API.log == nil || queryReq.API.dbHandle == nil {
+		return errors.New("warehouse api's are not initialized")
+	}
+	if queryReq.WorkspaceID == "" || queryReq.DestinationID == "" {
+		return errors.New("workspace_id and destination_id are required")
+	}
+	if queryReq.Limit < 1 || int(queryReq.Limit) > queryGatewayMaxRows {
+		queryReq.Limit = int32(queryGatewayMaxRows)
+	}
+	return nil
+}
+
+// warehouse returns the warehouse of the connection of the destination with the source, or with any source of the workspace if no source is given
+func (queryReq *QueryReqT) warehouse() (warehouseutils.Warehouse, error) {
+	sourceIDs := UploadsReqT{WorkspaceID: queryReq.WorkspaceID}.authorizedSources()
+	if queryReq.SourceID != "" {
+		if !misc.Contains(sourceIDs, queryReq.SourceID) {
+			return warehouseutils.Warehouse{}, errors.New("unauthorized request")
+		}
+		sourceIDs = []string{queryReq.SourceID}
+	}
+	sourceIDs = append([]string{}, sourceIDs...)
+	sort.Strings(sourceIDs)
+
+	connectionsMapLock.Lock()
+	defer connectionsMapLock.Unlock()
+	for _, sourceID := range sourceIDs {
+		if warehouse, ok := connectionsMap[queryReq.DestinationID][sourceID]; ok {
+			return warehouse, nil
+		}
+	}
+	return warehouseutils.Warehouse{}, errors.New("no such connection exists")
+}
+
+// Query streams the result of the read-only query on the warehouse to the writer, at most Warehouse.query.maxRows rows
+func (queryReq *QueryReqT) Query(ctx context.Context, w queryResultWriterI) error {
+	if err := queryReq.validateReq(); err != nil {
+		return err
+	}
+	warehouse, err := queryReq.warehouse()
+	if err != nil {
+		return err
+	}
+
+	queryReq.API.log.Infof("[WH]: Querying warehouse %s:%s for workspace %s", warehouse.Type, warehouse.Destination.ID, queryReq.WorkspaceID)
+	truncated, err := runReadOnlyQuery(ctx, warehouse, queryReq.SQLStatement, int(queryReq.Limit), w)
+	if err != nil {
+		queryReq.API.log.Errorf("[WH]: Error querying warehouse %s:%s for workspace %s: %v", warehouse.Type, warehouse.Destination.ID, queryReq.WorkspaceID, err)
+		return err
+	}
+	return w.Close(truncated)
+}
+
+// queryStreamWriterT sends the result of a query as a stream of messages, the columns first and the rows in batches
+type queryStreamWriterT struct {
+	stream proto.Warehouse_QueryWHServer
+	rows   []*proto.WHQueryRow
+}
+
+func (qw *queryStreamWriterT) WriteColumns(columns []string) error {
+	return qw.stream.Send(&proto.WHQueryResponse{Columns: columns})
+}
+
+func (qw *queryStreamWriterT) WriteRow(values []string) error {
+	qw.rows = append(qw.rows, &proto.WHQueryRow{Values: values})
+	if len(qw.rows) < queryStreamBatchSize {
+		return nil
+	}
+	err := qw.stream.Send(&proto.WHQueryResponse{Rows: qw.rows})
+	qw.rows = nil
+	return err
+}
+
+func (qw *queryStreamWriterT) Close(truncated bool) error {
+	return qw.stream.Send(&proto.WHQueryResponse{Rows: qw.rows, Truncated: truncated})
+}
+
+// query trailers of the http response, which is streamed before it is known whether the result was truncated or the query failed
+const (
+	queryTruncatedTrailer = "X-Query-Truncated"
+	queryErrorTrailer     = "X-Query-Error"
+)
+
+// queryHTTPWriterT streams the result of a query in the http response, as json or csv
+type queryHTTPWriterT struct {
+	w       http.ResponseWriter
+	format  string
+	csv     *csv.Writer
+	rows    int
+	started bool
+}
+
+func newQueryHTTPWriter(w http.ResponseWriter, format string) *queryHTTPWriterT {
+	w.Header().Set("Trailer", queryTruncatedTrailer+", "+queryErrorTrailer)
+	return &queryHTTPWriterT{w: w, format: format, csv: csv.NewWriter(w)}
+}
+
+func (qw *queryHTTPWriterT) WriteColumns(columns []string) error {
+	qw.started = true
+	if qw.format == QueryFormatCSV {
+		qw.w.Header().Set("Content-Type", "text/csv")
+		return qw.csv.Write(columns)
+	}
+	columnsJSON, err := json.Marshal(columns)
+	if err != nil {
+		return err
+	}
+	qw.w.Header().Set("Content-Type", "application/json")
+	_, err = fmt.Fprintf(qw.w, `{"columns":%s,"rows":[`, columnsJSON)
+	return err
+}
+
+func (qw *queryHTTPWriterT) WriteRow(values []string) error {
+	var err error
+	if qw.format == QueryFormatCSV {
+		err = qw.csv.Write(values)
+	} else {
+		var valuesJSON []byte
+		if valuesJSON, err = json.Marshal(values); err != nil {
+			return err
+		}
+		if qw.rows > 0 {
+			valuesJSON = append([]byte{','}, valuesJSON...)
+		}
+		_, err = qw.w.Write(valuesJSON)
+	}
+	if err != nil {
+		return err
+	}
+	qw.rows++
+	if qw.rows%queryStreamBatchSize == 0 {
+		return qw.flush()
+	}
+	return nil
+}
+
+func (qw *queryHTTPWriterT) flush() error {
+	if qw.format == QueryFormatCSV {
+		qw.csv.Flush()
+		if err := qw.csv.Error(); err != nil {
+			return err
+		}
+	}
+	if flusher, ok := qw.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return nil
+}
+
+func (qw *queryHTTPWriterT) Close(truncated bool) error {
+	if qw.format != QueryFormatCSV {
+		if _, err := fmt.Fprintf(qw.w, `],"truncated":%t}`+"\n", truncated); err != nil {
+			return err
+		}
+	}
+	if err := qw.flush(); err != nil {
+		return err
+	}
+	qw.w.Header().Set(queryTruncatedTrailer, strconv.FormatBool(truncated))
+	return nil
+}
+
+// Fail reports the error of the query, in the response if none of the result was written and in the error trailer otherwise
+func (qw *queryHTTPWriterT) Fail(err error) {
+	if !qw.started {
+		http.Error(qw.w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	_ = qw.flush()
+	qw.w.Header().Set(queryErrorTrailer, strings.ReplaceAll(err.Error(), "\n", " "))
+}
@@ -0,0 +1,297 @@
+//go:build !warehouse_integration
+
+package warehouse
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	backendconfig "github.com/rudderlabs/rudder-server/config/backend-config"
+	proto "github.com/rudderlabs/rudder-server/proto/warehouse"
+	"github.com/rudderlabs/rudder-server/utils/logger"
+	"github.com/rudderlabs/rudder-server/warehouse/sqlite"
+	warehouseutils "github.com/rudderlabs/rudder-server/warehouse/utils"
+)
+
+// queryCollectorT collects the result written by a query
+type queryCollectorT struct {
+	columns   []string
+	rows      [][]string
+	truncated bool
+}
+
+func (qc *queryCollectorT) WriteColumns(columns []string) error {
+	qc.columns = columns
+	return nil
+}
+
+func (qc *queryCollectorT) WriteRow(values []string) error {
+	qc.rows = append(qc.rows, values)
+	return nil
+}
+
+func (qc *queryCollectorT) Close(truncated bool) error {
+	qc.truncated = truncated
+	return nil
+}
+
+// queryStreamT records the messages sent on the query stream
+type queryStreamT struct {
+	proto.Warehouse_QueryWHServer
+	messages []*proto.WHQueryResponse
+}
+
+func (qs *queryStreamT) Send(response *proto.WHQueryResponse) error {
+	qs.messages = append(qs.messages, response)
+	return nil
+}
+
+var _ = Describe("Query gateway", func() {
+	DescribeTable("Validate read-only query", func(sqlStatement, expectedStatement, expectedErr string) {
+		statement, err := validateReadOnlyQuery(sqlStatement)
+		if expectedErr != "" {
+			Expect(err).To(MatchError(expectedErr))
+			return
+		}
+		Expect(err).To(BeNil())
+		Expect(statement).To(Equal(expectedStatement))
+	},
+		Entry("Select", " SELECT id, event FROM tracks; ", "SELECT id, event FROM tracks", ""),
+		Entry("Common table expression", "WITH t AS (SELECT id FROM tracks) SELECT * FROM t", "WITH t AS (SELECT id FROM tracks) SELECT * FROM t", ""),
+		Entry("Keywords in literals", `select * from tracks where event = 'delete; drop -- it' and "update" = 1`, `select * from tracks where event = 'delete; drop -- it' and "update" = 1`, ""),
+		Entry("Escaped quote", `SELECT 'it''s' AS a`, `SELECT 'it''s' AS a`, ""),
+		Entry("Empty", " ; ", "", "sql_statement is empty"),
+		Entry("Delete", "DELETE FROM tracks", "", "only select statements are allowed"),
+		Entry("Several statements", "SELECT 1; DROP TABLE tracks", "", "only a single statement is allowed"),
+		Entry("Backslash escaped quote", `SELECT 'a\'; DROP TABLE tracks; --'`, "", "only a single statement is allowed"),
+		Entry("Backslash before quote", `SELECT 'a\' ; DELETE FROM tracks; --'`, "", "only a single statement is allowed"),
+		Entry("Line comment", "SELECT 1 -- comment", "", "comments are not allowed"),
+		Entry("Block comment", "SELECT /* comment */ 1", "", "comments are not allowed"),
+		Entry("Select into", "SELECT * INTO tracks_copy FROM tracks", "", "keyword INTO is not allowed"),
+		Entry("Nested delete", "WITH t AS (delete FROM tracks RETURNING *) SELECT * FROM t", "", "keyword DELETE is not allowed"),
+		Entry("Terminate backend", "SELECT pg_terminate_backend(pid) FROM pg_stat_activity", "", "function pg_terminate_backend is not allowed"),
+		Entry("Set sequence", "SELECT SETVAL('seq', 1)", "", "function setval is not allowed"),
+		Entry("Dblink", "SELECT dblink_exec('conn', 'drop table tracks')", "", "function dblink_exec is not allowed"),
+		Entry("Large object", "SELECT lo_unlink(1)", "", "function lo_unlink is not allowed"),
+		Entry("Sleep", "SELECT pg_sleep (100)", "", "function pg_sleep is not allowed"),
+	)
+
+	DescribeTable("Read-only statement", func(destType, expected string) {
+		Expect(readOnlyStatement(destType, "SELECT id FROM tracks", 11)).To(Equal(expected))
+	},
+		Entry("Postgres", warehouseutils.POSTGRES, `SELECT * FROM (SELECT id FROM tracks) AS rudder_query LIMIT 11`),
+		Entry("BigQuery", warehouseutils.BQ, `SELECT * FROM (SELECT id FROM tracks) AS rudder_query LIMIT 11`),
+		Entry("Clickhouse", warehouseutils.CLICKHOUSE, `SELECT * FROM (SELECT id FROM tracks) AS rudder_query LIMIT 11 SETTINGS readonly = 1`),
+		Entry("MSSQL", warehouseutils.MSSQL, `SELECT id FROM tracks`),
+		Entry("Azure synapse", warehouseutils.AZURE_SYNAPSE, `SELECT id FROM tracks`),
+	)
+
+	DescribeTable("Read-only role", func(destType, role, expectedRole, expectedErr string) {
+		warehouse := warehouseutils.Warehouse{
+			Type:        destType,
+			Destination: backendconfig.DestinationT{ID: "test-destinationID", Config: map[string]interface{}{readOnlyRoleKey: role}},
+		}
+		readOnlyRole, err := readOnlyRole(warehouse)
+		if expectedErr != "" {
+			Expect(err).To(MatchError(expectedErr))
+			return
+		}
+		Expect(err).To(BeNil())
+		Expect(readOnlyRole).To(Equal(expectedRole))
+	},
+		Entry("Postgres without role", warehouseutils.POSTGRES, "", "", ""),
+		Entry("Postgres with role", warehouseutils.POSTGRES, "analyst", "analyst", ""),
+		Entry("Snowflake with role", warehouseutils.SNOWFLAKE, "ANALYST", "ANALYST", ""),
+		Entry("Snowflake without role", warehouseutils.SNOWFLAKE, "", "", "readOnlyRole is not configured for destination: test-destinationID"),
+		Entry("MSSQL without user", warehouseutils.MSSQL, "", "", "readOnlyRole is not configured for destination: test-destinationID"),
+		Entry("Invalid role", warehouseutils.SNOWFLAKE, "analyst; drop", "", "invalid readOnlyRole: analyst; drop"),
+	)
+
+	DescribeTable("Validate request", func(queryReq QueryReqT, expectedErr string) {
+		Expect(queryReq.validateReq()).To(MatchError(expectedErr))
+	},
+		Entry("Not initialized", QueryReqT{}, "warehouse api's are not initialized"),
+		Entry("Missing destination", QueryReqT{
+			WorkspaceID: "test-workspaceID",
+			API:         UploadAPIT{enabled: true, log: logger.NOP, dbHandle: &sql.DB{}},
+		}, "workspace_id and destination_id are required"),
+	)
+
+	It("Should bound the limit to the maximum rows", func() {
+		maxRows := queryGatewayMaxRows
+		DeferCleanup(func() {
+			queryGatewayMaxRows = maxRows
+		})
+		queryGatewayMaxRows = 100
+
+		api := UploadAPIT{enabled: true, log: logger.NOP, dbHandle: &sql.DB{}}
+		for limit, expected := range map[int32]int32{0: 100, 10: 10, 1000: 100} {
+			queryReq := QueryReqT{WorkspaceID: "test-workspaceID", DestinationID: "test-destinationID", Limit: limit, API: api}
+			Expect(queryReq.validateReq()).To(BeNil())
+			Expect(queryReq.Limit).To(Equal(expected))
+		}
+	})
+
+	It("Should authorize the connection of the workspace", func() {
+		sourceIDsByWorkspaceLock.Lock()
+		prevSourceIDsByWorkspace := sourceIDsByWorkspace
+		sourceIDsByWorkspace = map[string][]string{"test-workspaceID": {"test-sourceID-2", "test-sourceID-1"}}
+		sourceIDsByWorkspaceLock.Unlock()
+		connectionsMapLock.Lock()
+		prevConnectionsMap := connectionsMap
+		connectionsMap = map[string]map[string]warehouseutils.Warehouse{
+			"test-destinationID": {
+				"test-sourceID-1": {Identifier: "test-identifier-1"},
+				"test-sourceID-2": {Identifier: "test-identifier-2"},
+				"test-sourceID-3": {Identifier: "test-identifier-3"},
+			},
+		}
+		connectionsMapLock.Unlock()
+		DeferCleanup(func() {
+			sourceIDsByWorkspaceLock.Lock()
+			sourceIDsByWorkspace = prevSourceIDsByWorkspace
+			sourceIDsByWorkspaceLock.Unlock()
+			connectionsMapLock.Lock()
+			connectionsMap = prevConnectionsMap
+			connectionsMapLock.Unlock()
+		})
+
+		warehouse, err := (&QueryReqT{WorkspaceID: "test-workspaceID", DestinationID: "test-destinationID"}).warehouse()
+		Expect(err).To(BeNil())
+		Expect(warehouse.Identifier).To(Equal("test-identifier-1"))
+
+		warehouse, err = (&QueryReqT{WorkspaceID: "test-workspaceID", SourceID: "test-sourceID-2", DestinationID: "test-destinationID"}).warehouse()
+		Expect(err).To(BeNil())
+		Expect(warehouse.Identifier).To(Equal("test-identifier-2"))
+
+		_, err = (&QueryReqT{WorkspaceID: "test-workspaceID", SourceID: "test-sourceID-3", DestinationID: "test-destinationID"}).warehouse()
+		Expect(err).To(MatchError("unauthorized request"))
+
+		_, err = (&QueryReqT{WorkspaceID: "other-workspaceID", DestinationID: "test-destinationID"}).warehouse()
+		Expect(err).To(MatchError("no such connection exists"))
+
+		_, err = (&QueryReqT{WorkspaceID: "test-workspaceID", DestinationID: "other-destinationID"}).warehouse()
+		Expect(err).To(MatchError("no such connection exists"))
+	})
+
+	Describe("Read-only query on sqlite", func() {
+		var (
+			db        *sql.DB
+			warehouse = warehouseutils.Warehouse{Type: warehouseutils.SQLITE, Namespace: "test_namespace"}
+		)
+
+		BeforeEach(func() {
+			var err error
+			db, err = sqlite.Connect(sqlite.CredentialsT{Directory: GinkgoT().TempDir(), Namespace: "test_namespace"})
+			Expect(err).To(BeNil())
+			DeferCleanup(db.Close)
+
+			_, err = db.Exec(`
+				CREATE TABLE tracks (id TEXT, event TEXT, revenue REAL);
+				INSERT INTO tracks VALUES ('1', 'signed up', 1.5), ('2', NULL, 2), ('3', 'logged in', NULL);
+			`)
+			Expect(err).To(BeNil())
+		})
+
+		It("Should stream the rows of the query up to the limit", func() {
+			var collector queryCollectorT
+			truncated, err := sqlReadOnlyQuery(context.Background(), db, warehouse, "WITH t AS (SELECT * FROM tracks) SELECT id, event, revenue FROM t ORDER BY id", 2, &collector)
+			Expect(err).To(BeNil())
+			Expect(truncated).To(BeTrue())
+			Expect(collector.columns).To(Equal([]string{"id", "event", "revenue"}))
+			Expect(collector.rows).To(Equal([][]string{{"1", "signed up", "1.5"}, {"2", "", "2"}}))
+
+			collector = queryCollectorT{}
+			truncated, err = sqlReadOnlyQuery(context.Background(), db, warehouse, "SELECT id FROM tracks ORDER BY id", 3, &collector)
+			Expect(err).To(BeNil())
+			Expect(truncated).To(BeFalse())
+			Expect(collector.rows).To(HaveLen(3))
+		})
+
+		It("Should not allow writes in the read-only session", func() {
+			conn, err := db.Conn(context.Background())
+			Expect(err).To(BeNil())
+			defer conn.Close()
+
+			queryer, release, err := readOnlySession(context.Background(), conn, warehouse, 10)
+			Expect(err).To(BeNil())
+			defer release()
+			_, err = queryer.ExecContext(context.Background(), `DELETE FROM tracks`)
+			Expect(err).NotTo(BeNil())
+		})
+
+		It("Should stop the query once the context is cancelled", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			_, err := sqlReadOnlyQuery(ctx, db, warehouse, "SELECT id FROM tracks", 10, &queryCollectorT{})
+			Expect(errors.Is(err, context.Canceled)).To(BeTrue())
+		})
+	})
+
+	Describe("Http writer", func() {
+		write := func(format string, rows int, truncated bool) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			queryWriter := newQueryHTTPWriter(recorder, format)
+			Expect(queryWriter.WriteColumns([]string{"id", "event"})).To(BeNil())
+			for i := 1; i <= rows; i++ {
+				Expect(queryWriter.WriteRow([]string{fmt.Sprint(i), `say "hi", bye`})).To(BeNil())
+			}
+			Expect(queryWriter.Close(truncated)).To(BeNil())
+			return recorder
+		}
+
+		It("Should stream the result as json", func() {
+			recorder := write(QueryFormatJSON, 2, true)
+			Expect(recorder.Header().Get("Content-Type")).To(Equal("application/json"))
+			Expect(recorder.Body.String()).To(MatchJSON(`{"columns":["id","event"],"rows":[["1","say \"hi\", bye"],["2","say \"hi\", bye"]],"truncated":true}`))
+			Expect(recorder.Result().Trailer.Get(queryTruncatedTrailer)).To(Equal("true"))
+
+			Expect(write("", 0, false).Body.String()).To(MatchJSON(`{"columns":["id","event"],"rows":[],"truncated":false}`))
+		})
+
+		It("Should stream the result as csv", func() {
+			recorder := write(QueryFormatCSV, 2, false)
+			Expect(recorder.Header().Get("Content-Type")).To(Equal("text/csv"))
+			Expect(recorder.Body.String()).To(Equal("id,event\n1,\"say \"\"hi\"\", bye\"\n2,\"say \"\"hi\"\", bye\"\n"))
+			Expect(recorder.Result().Trailer.Get(queryTruncatedTrailer)).To(Equal("false"))
+		})
+
+		It("Should report the error of the query", func() {
+			recorder := httptest.NewRecorder()
+			newQueryHTTPWriter(recorder, QueryFormatJSON).Fail(errors.New("only select statements are allowed"))
+			Expect(recorder.Code).To(Equal(400))
+			Expect(recorder.Body.String()).To(Equal("only select statements are allowed\n"))
+
+			recorder = httptest.NewRecorder()
+			queryWriter := newQueryHTTPWriter(recorder, QueryFormatCSV)
+			Expect(queryWriter.WriteColumns([]string{"id"})).To(BeNil())
+			queryWriter.Fail(errors.New("query timed out"))
+			Expect(recorder.Code).To(Equal(200))
+			Expect(recorder.Body.String()).To(Equal("id\n"))
+			Expect(recorder.Result().Trailer.Get(queryErrorTrailer)).To(Equal("query timed out"))
+		})
+	})
+
+	It("Should stream the rows in batches", func() {
+		stream := &queryStreamT{}
+		queryWriter := &queryStreamWriterT{stream: stream}
+		Expect(queryWriter.WriteColumns([]string{"id"})).To(BeNil())
+		for i := 0; i < queryStreamBatchSize+50; i++ {
+			Expect(queryWriter.WriteRow([]string{fmt.Sprint(i)})).To(BeNil())
+		}
+		Expect(queryWriter.Close(true)).To(BeNil())
+
+		Expect(stream.messages).To(HaveLen(3))
+		Expect(stream.messages[0].Columns).To(Equal([]string{"id"}))
+		Expect(stream.messages[1].Rows).To(HaveLen(queryStreamBatchSize))
+		Expect(stream.messages[1].Truncated).To(BeFalse())
+		Expect(stream.messages[2].Rows).To(HaveLen(50))
+		Expect(stream.messages[2].Truncated).To(BeTrue())
+	})
+})
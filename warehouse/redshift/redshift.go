@@ -105,16 +105,11 @@ var dataTypesMapToRudder = map[string]string{
 	"super":                       "json",
 }
 
-var primaryKeyMap = map[string]string{
-	"users":                      "id",
-	"identifies":                 "id",
-	warehouseutils.DiscardsTable: "row_id",
-}
-
-var partitionKeyMap = map[string]string{
-	"users":                      "id",
-	"identifies":                 "id",
-	warehouseutils.DiscardsTable: "row_id, column_name, table_name",
+// primaryKeyMap are the columns deduplicating the rows of the tables in the merge load mode, defaulting to id
+var primaryKeyMap = map[string][]string{
+	"users":                      {"id"},
+	"identifies":                 {"id"},
+	warehouseutils.DiscardsTable: {"row_id", "column_name", "table_name"},
 }
 
 // getRSDataType gets datatype for rs which is mapped with rudderstack datatype
@@ -210,6 +205,18 @@ func (rs *HandleT) DeleteBy(tableNames []string, params warehouseutils.DeleteByP
 	return nil
 }
 
+// PruneTable deletes the rows of the table older than the retention, one window of time at a time
+func (rs *HandleT) PruneTable(ctx context.Context, tableName string, params warehouseutils.RetentionParamsT) (warehouseutils.RetentionStatsT, error) {
+	pkgLogger.Infof("RS: Pruning rows with %s before %s in table %s for RS:%s", params.Column, params.Before, tableName, rs.Warehouse.Destination.ID)
+	table := fmt.Sprintf(`"%s"."%s"`, rs.Namespace, tableName)
+	batchedDelete := warehouseutils.BatchedDeleteT{
+		Db:          rs.Db,
+		OldestQuery: fmt.Sprintf(`SELECT MIN(%q) FROM %s`, params.Column, table),
+		DeleteQuery: fmt.Sprintf(`DELETE FROM %[1]s WHERE %[2]q >= $1 AND %[2]q < $2`, table, params.Column),
+	}
+	return batchedDelete.Run(ctx, params)
+}
+
 // alterStringToText alters column data type string(varchar(512)) to text which is varchar(max) in redshift
 func (rs *HandleT) alterStringToText(tableName, columnName string) (err error) {
 	sqlStatement := fmt.Sprintf(`ALTER TABLE %v ALTER COLUMN %q TYPE %s`, tableName, columnName, getRSDataType("text"))
@@ -371,67 +378,50 @@ func (rs *HandleT) loadTable(tableName string, tableSchemaInUpload, tableSchemaA
 		return
 	}
 
-	var (
-		primaryKey   = "id"
-		partitionKey = "id"
-	)
-
-	if column, ok := primaryKeyMap[tableName]; ok {
-		primaryKey = column
-	}
-	if column, ok := partitionKeyMap[tableName]; ok {
-		partitionKey = column
-	}
+	quotedColumnNames := warehouseutils.DoubleQuoteAndJoinByComma(strKeys)
 
-	sqlStatement = fmt.Sprintf(`
+	if rs.Warehouse.LoadMode(warehouseutils.LoadModeMerge) == warehouseutils.LoadModeAppend {
+		sqlStatement = fmt.Sprintf(`INSERT INTO "%[1]s"."%[2]s" (%[3]s) SELECT %[3]s FROM "%[1]s"."%[4]s"`, rs.Namespace, tableName, quotedColumnNames, stagingTableName)
+	} else {
+		primaryKeys := rs.primaryKeys(tableName)
+		sqlStatement = fmt.Sprintf(`
 		DELETE FROM
 			%[1]s.%[2]q 
 		USING 
 			%[1]s.%[3]q _source  
 		WHERE
-			_source.%[4]s = %[1]s.%[2]q.%[4]s
+			%[4]s
 `,
-		rs.Namespace,
-		tableName,
-		stagingTableName,
-		primaryKey,
-	)
+			rs.Namespace,
+			tableName,
+			stagingTableName,
+			warehouseutils.JoinWithFormatting(primaryKeys, func(_ int, key string) string {
+				return fmt.Sprintf(`_source.%[3]q = %[1]s.%[2]q.%[3]q`, rs.Namespace, tableName, key)
+			}, " AND "),
+		)
 
-	if dedupWindow {
-		if _, ok := tableSchemaAfterUpload["received_at"]; ok {
-			sqlStatement += fmt.Sprintf(`
+		if dedupWindow {
+			if _, ok := tableSchemaAfterUpload["received_at"]; ok {
+				sqlStatement += fmt.Sprintf(`
 				AND %[1]s.%[2]q.received_at > GETDATE() - INTERVAL '%[3]d DAY'
 `,
-				rs.Namespace,
-				tableName,
-				dedupWindowInHours/time.Hour,
-			)
+					rs.Namespace,
+					tableName,
+					dedupWindowInHours/time.Hour,
+				)
+			}
 		}
-	}
 
-	if tableName == warehouseutils.DiscardsTable {
-		sqlStatement += fmt.Sprintf(`
-			AND _source.%[3]s = %[1]s.%[2]q.%[3]s 
-			AND _source.%[4]s = %[1]s.%[2]q.%[4]s
-`,
-			rs.Namespace,
-			tableName,
-			"table_name",
-			"column_name",
-		)
-	}
+		pkgLogger.Infof("RS: Dedup records for table:%s using staging table: %s\n", tableName, sqlStatement)
+		_, err = tx.Exec(sqlStatement)
+		if err != nil {
+			pkgLogger.Errorf("RS: Error deleting from original table for dedup: %v\n", err)
+			tx.Rollback()
+			return
+		}
 
-	pkgLogger.Infof("RS: Dedup records for table:%s using staging table: %s\n", tableName, sqlStatement)
-	_, err = tx.Exec(sqlStatement)
-	if err != nil {
-		pkgLogger.Errorf("RS: Error deleting from original table for dedup: %v\n", err)
-		tx.Rollback()
-		return
+		sqlStatement = fmt.Sprintf(`INSERT INTO "%[1]s"."%[2]s" (%[3]s) SELECT %[3]s FROM ( SELECT *, row_number() OVER (PARTITION BY %[5]s ORDER BY received_at DESC) AS _rudder_staging_row_number FROM "%[1]s"."%[4]s" ) AS _ where _rudder_staging_row_number = 1`, rs.Namespace, tableName, quotedColumnNames, stagingTableName, warehouseutils.DoubleQuoteAndJoinByComma(primaryKeys))
 	}
-
-	quotedColumnNames := warehouseutils.DoubleQuoteAndJoinByComma(strKeys)
-
-	sqlStatement = fmt.Sprintf(`INSERT INTO "%[1]s"."%[2]s" (%[3]s) SELECT %[3]s FROM ( SELECT *, row_number() OVER (PARTITION BY %[5]s ORDER BY received_at ASC) AS _rudder_staging_row_number FROM "%[1]s"."%[4]s" ) AS _ where _rudder_staging_row_number = 1`, rs.Namespace, tableName, quotedColumnNames, stagingTableName, partitionKey)
 	pkgLogger.Infof("RS: Inserting records for table:%s using staging table: %s\n", tableName, sqlStatement)
 	_, err = tx.Exec(sqlStatement)
 
@@ -657,9 +647,61 @@ func (rs *HandleT) CreateSchema() (err error) {
 	return rs.createSchema()
 }
 
-func (rs *HandleT) AlterColumn(tableName, columnName, columnType string) (err error) {
-	if setVarCharMax && columnType == "text" {
-		err = rs.alterStringToText(fmt.Sprintf(`%q.%q`, rs.Namespace, tableName), columnName)
+// AlterColumn changes the type of the column. Redshift only supports increasing the length of varchars in place,
+// so string columns are altered in place to text and other columns are swapped with a backfilled shadow column.
+func (rs *HandleT) AlterColumn(tableName, columnName, columnType string) (response warehouseutils.AlterColumnResponseT, err error) {
+	tableIdentifier := fmt.Sprintf(`%q.%q`, rs.Namespace, tableName)
+	currentType := rs.Uploader.GetTableSchemaInWarehouse(tableName)[columnName]
+	if columnType == "text" && (currentType == "" || currentType == "string") {
+		if !setVarCharMax {
+			return
+		}
+		if err = rs.alterStringToText(tableIdentifier, columnName); err != nil {
+			return
+		}
+		response = warehouseutils.AlterColumnResponseT{
+			Strategy: warehouseutils.AlterColumnInPlace,
+			Queries:  []string{fmt.Sprintf(`ALTER TABLE %v ALTER COLUMN %q TYPE %s`, tableIdentifier, columnName, getRSDataType("text"))},
+		}
+		return
+	}
+
+	dataType := getRSDataType(columnType)
+	castExpression := fmt.Sprintf(`%q::%s`, columnName, dataType)
+	if currentType == "boolean" {
+		// booleans cannot be cast to varchar in redshift
+		castExpression = fmt.Sprintf(`CASE WHEN %[1]q THEN 'true' WHEN NOT %[1]q THEN 'false' END`, columnName)
+	}
+	shadowColumnName := columnName + warehouseutils.ShadowColumnSuffix
+	backupColumnName := columnName + warehouseutils.BackupColumnSuffix
+	queries := warehouseutils.ShadowColumnQueries(
+		tableIdentifier,
+		fmt.Sprintf(`%q`, columnName),
+		fmt.Sprintf(`%q`, shadowColumnName),
+		fmt.Sprintf(`%q`, backupColumnName),
+		dataType,
+		castExpression,
+		warehouseutils.GetShadowColumnState(rs.Uploader.GetTableSchemaInWarehouse(tableName), columnName, shadowColumnName, backupColumnName),
+	)
+
+	// swapping in a transaction, so that the column is never missing
+	txn, err := rs.Db.Begin()
+	if err != nil {
+		return
+	}
+	for _, sqlStatement := range queries {
+		pkgLogger.Infof("RS: Altering column in redshift for RS:%s : %v", rs.Warehouse.Destination.ID, sqlStatement)
+		if _, err = txn.Exec(sqlStatement); err != nil {
+			_ = txn.Rollback()
+			return
+		}
+	}
+	if err = txn.Commit(); err != nil {
+		return
+	}
+	response = warehouseutils.AlterColumnResponseT{
+		Strategy: warehouseutils.AlterColumnShadowColumn,
+		Queries:  queries,
 	}
 	return
 }
@@ -746,6 +788,14 @@ func (rs *HandleT) Setup(warehouse warehouseutils.Warehouse, uploader warehouseu
 	return err
 }
 
+// SetupDryRun sets up the warehouse like Setup, running statements on db instead of connecting to redshift
+func (rs *HandleT) SetupDryRun(warehouse warehouseutils.Warehouse, uploader warehouseutils.UploaderI, db *sql.DB) {
+	rs.Warehouse = warehouse
+	rs.Namespace = warehouse.Namespace
+	rs.Uploader = uploader
+	rs.Db = db
+}
+
 func (rs *HandleT) TestConnection(warehouse warehouseutils.Warehouse) (err error) {
 	rs.Warehouse = warehouse
 	rs.Db, err = Connect(rs.getConnectionCredentials())
@@ -795,6 +845,15 @@ func (rs *HandleT) LoadUserTables() map[string]error {
 	return rs.loadUserTables()
 }
 
+// primaryKeys returns the columns deduplicating the rows of tableName in the merge load mode
+func (rs *HandleT) primaryKeys(tableName string) []string {
+	keys, ok := primaryKeyMap[tableName]
+	if !ok {
+		keys = []string{"id"}
+	}
+	return rs.Warehouse.MergePrimaryKeys(tableName, keys)
+}
+
 func (rs *HandleT) LoadTable(tableName string) error {
 	_, err := rs.loadTable(tableName, rs.Uploader.GetTableSchemaInUpload(tableName), rs.Uploader.GetTableSchemaInWarehouse(tableName), false)
 	return err
@@ -106,6 +106,9 @@ func TestRedshiftIntegration(t *testing.T) {
 	testhelper.VerifyEventsInLoadFiles(t, warehouseTest, testhelper.LoadFilesEventsMap())
 	testhelper.VerifyEventsInTableUploads(t, warehouseTest, testhelper.TableUploadsEventsMap())
 	testhelper.VerifyEventsInWareHouse(t, warehouseTest, testhelper.WarehouseEventsMap())
+
+	// Scenario 3 (merge load mode, duplicate message ids)
+	testhelper.VerifyMergeLoadMode(t, warehouseTest, warehouseutils.RS)
 }
 
 func TestRedshiftConfigurationValidation(t *testing.T) {
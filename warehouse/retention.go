@@ -0,0 +1,332 @@
+package warehouse
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/rudderlabs/rudder-server/services/stats"
+	"github.com/rudderlabs/rudder-server/utils/misc"
+	"github.com/rudderlabs/rudder-server/utils/timeutil"
+	"github.com/rudderlabs/rudder-server/warehouse/jobs"
+	"github.com/rudderlabs/rudder-server/warehouse/manager"
+	warehouseutils "github.com/rudderlabs/rudder-server/warehouse/utils"
+)
+
+const (
+	retentionPolicies      = "retentionPolicies"
+	defaultRetentionColumn = "received_at"
+)
+
+const (
+	RetentionRunExecuting = "executing"
+	RetentionRunSucceeded = "succeeded"
+	RetentionRunFailed    = "failed"
+	RetentionRunAborted   = "aborted"
+)
+
+// RetentionPolicyT is the retention of a table configured for a destination, e.g.
+// {"table": "pages", "retentionDays": 90} keeps the rows of the pages table received in the last 90 days
+type RetentionPolicyT struct {
+	Table         string      `json:"table"`
+	Column        string      `json:"column"`
+	RetentionDays json.Number `json:"retentionDays"`
+}
+
+type retentionHandleT struct {
+	dbHandle   *sql.DB
+	newManager func(destType string) (manager.WarehouseOperations, error)
+}
+
+// retentionTargetT is a namespace of a destination, along with the schema of the tables loaded into it by all the sources
+type retentionTargetT struct {
+	warehouse warehouseutils.Warehouse
+	schema    warehouseutils.SchemaT
+}
+
+// getRetentionPolicies returns the valid retention policies configured for the destination of the warehouse
+func getRetentionPolicies(warehouse warehouseutils.Warehouse) []RetentionPolicyT {
+	configured, ok := warehouse.Destination.Config[retentionPolicies]
+	if !ok || configured == nil {
+		return nil
+	}
+	rawPolicies, err := json.Marshal(configured)
+	if err != nil {
+		return nil
+	}
+	var policies []json.RawMessage
+	if err = json.Unmarshal(rawPolicies, &policies); err != nil {
+		pkgLogger.Errorf("[WH]: Invalid retention policies for destination %s: %v", warehouse.Destination.ID, err)
+		return nil
+	}
+
+	var validPolicies []RetentionPolicyT
+	for _, rawPolicy := range policies {
+		var policy RetentionPolicyT
+		err := json.Unmarshal(rawPolicy, &policy)
+		if err == nil && policy.Table != "" && policy.days() > 0 {
+			if policy.Column == "" {
+				policy.Column = defaultRetentionColumn
+			}
+			validPolicies = append(validPolicies, policy)
+			continue
+		}
+		pkgLogger.Errorf("[WH]: Skipping invalid retention policy %s for destination %s", rawPolicy, warehouse.Destination.ID)
+	}
+	return validPolicies
+}
+
+func (policy RetentionPolicyT) days() int {
+	days, _ := strconv.Atoi(policy.RetentionDays.String())
+	return days
+}
+
+// retentionTargets returns the namespaces of the enabled destinations which have retention policies.
+// Datalake destinations are skipped, since their files cannot be pruned in place.
+func retentionTargets() []retentionTargetT {
+	connectionsMapLock.RLock()
+	var warehouses []warehouseutils.Warehouse
+	for _, srcMap := range connectionsMap {
+		for _, warehouse := range srcMap {
+			warehouses = append(warehouses, warehouse)
+		}
+	}
+	connectionsMapLock.RUnlock()
+
+	targetsByIdentifier := make(map[string]*retentionTargetT)
+	var identifiers []string
+	for _, warehouse := range warehouses {
+		if !warehouse.Destination.Enabled || misc.Contains(warehouseutils.TimeWindowDestinations, warehouse.Type) || len(getRetentionPolicies(warehouse)) == 0 {
+			continue
+		}
+		identifier := fmt.Sprintf(`%s_%s`, warehouse.Destination.ID, warehouse.Namespace)
+		target, ok := targetsByIdentifier[identifier]
+		if !ok {
+			target = &retentionTargetT{warehouse: warehouse, schema: warehouseutils.SchemaT{}}
+			targetsByIdentifier[identifier] = target
+			identifiers = append(identifiers, identifier)
+		}
+		sh := SchemaHandleT{warehouse: warehouse}
+		for tableName, columns := range sh.getLocalSchema() {
+			if target.schema[tableName] == nil {
+				target.schema[tableName] = make(map[string]string)
+			}
+			for columnName, columnType := range columns {
+				target.schema[tableName][columnName] = columnType
+			}
+		}
+	}
+
+	targets := make([]retentionTargetT, 0, len(identifiers))
+	for _, identifier := range identifiers {
+		targets = append(targets, *targetsByIdentifier[identifier])
+	}
+	return targets
+}
+
+// isRetentionDue returns whether the table has not been pruned successfully in the last run interval
+func (rh *retentionHandleT) isRetentionDue(warehouse warehouseutils.Warehouse, tableName string) (bool, error) {
+	sqlStatement := fmt.Sprintf(`
+		SELECT
+		  COUNT(*)
+		FROM
+		  %[1]s
+		WHERE
+		  destination_id = $1
+		  AND namespace = $2
+		  AND table_name = $3
+		  AND (
+			status = '%[2]s'
+			OR status = '%[3]s'
+		  )
+		  AND started_at > $4;
+`,
+		warehouseutils.WarehouseRetentionRunsTable,
+		RetentionRunSucceeded,
+		RetentionRunExecuting,
+	)
+	var count int
+	err := rh.dbHandle.QueryRow(sqlStatement, warehouse.Destination.ID, warehouse.Namespace, tableName, timeutil.Now().Add(-retentionRunInterval)).Scan(&count)
+	return count == 0, err
+}
+
+func (rh *retentionHandleT) startRetentionRun(warehouse warehouseutils.Warehouse, tableName string, policy RetentionPolicyT, cutoff time.Time) (id int64, err error) {
+	sqlStatement := fmt.Sprintf(`
+		INSERT INTO %s (
+		  workspace_id, destination_id, destination_type,
+		  namespace, table_name, retention_column,
+		  retention_days, cutoff_at, status, started_at
+		)
+		VALUES
+		  ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id;
+`,
+		warehouseutils.WarehouseRetentionRunsTable,
+	)
+	err = rh.dbHandle.QueryRow(sqlStatement,
+		warehouse.Destination.WorkspaceID,
+		warehouse.Destination.ID,
+		warehouse.Type,
+		warehouse.Namespace,
+		tableName,
+		policy.Column,
+		policy.days(),
+		cutoff,
+		RetentionRunExecuting,
+		timeutil.Now(),
+	).Scan(&id)
+	return
+}
+
+func (rh *retentionHandleT) completeRetentionRun(id int64, retentionStats warehouseutils.RetentionStatsT, runErr error) error {
+	status := RetentionRunSucceeded
+	var errorMessage sql.NullString
+	if runErr != nil {
+		status = RetentionRunFailed
+		errorMessage = sql.NullString{String: runErr.Error(), Valid: true}
+	}
+	sqlStatement := fmt.Sprintf(`
+		UPDATE
+		  %s
+		SET
+		  status = $1,
+		  method = $2,
+		  rows_deleted = $3,
+		  partitions_dropped = $4,
+		  error = $5,
+		  completed_at = $6
+		WHERE
+		  id = $7;
+`,
+		warehouseutils.WarehouseRetentionRunsTable,
+	)
+	_, err := rh.dbHandle.Exec(sqlStatement, status, retentionStats.Method, retentionStats.RowsDeleted, retentionStats.PartitionsDropped, errorMessage, timeutil.Now(), id)
+	return err
+}
+
+// abortRetentionRuns marks the runs left executing by a previous master as aborted, so that they are picked up again
+func (rh *retentionHandleT) abortRetentionRuns() error {
+	sqlStatement := fmt.Sprintf(`UPDATE %s SET status = $1, completed_at = $2 WHERE status = $3;`, warehouseutils.WarehouseRetentionRunsTable)
+	_, err := rh.dbHandle.Exec(sqlStatement, RetentionRunAborted, timeutil.Now(), RetentionRunExecuting)
+	return err
+}
+
+// pruneTable prunes a table as per its retention policy and records the run along with its stats
+func (rh *retentionHandleT) pruneTable(ctx context.Context, whManager manager.WarehouseRetention, warehouse warehouseutils.Warehouse, tableName string, policy RetentionPolicyT) error {
+	cutoff := timeutil.Now().AddDate(0, 0, -policy.days()).UTC()
+	id, err := rh.startRetentionRun(warehouse, tableName, policy, cutoff)
+	if err != nil {
+		return err
+	}
+
+	tags := map[string]string{
+		"workspaceId": warehouse.Destination.WorkspaceID,
+		"destination": warehouse.Destination.ID,
+		"destType":    warehouse.Type,
+		"namespace":   warehouse.Namespace,
+		"tableName":   tableName,
+	}
+	startTime := time.Now()
+	pruneCtx, cancel := context.WithTimeout(ctx, retentionRunTimeout)
+	retentionStats, runErr := whManager.PruneTable(pruneCtx, tableName, warehouseutils.RetentionParamsT{
+		Column:      policy.Column,
+		Before:      cutoff,
+		BatchWindow: retentionDeleteBatchWindow,
+	})
+	cancel()
+	stats.Default.NewTaggedStat("warehouse.retention.runTime", stats.TimerType, tags).Since(startTime)
+
+	if runErr != nil {
+		pkgLogger.Errorf("[WH]: Failed to prune table %s in namespace %s of destination %s: %v", tableName, warehouse.Namespace, warehouse.Destination.ID, runErr)
+		stats.Default.NewTaggedStat("warehouse.retention.failedRuns", stats.CountType, tags).Count(1)
+	} else {
+		pkgLogger.Infof("[WH]: Pruned table %s in namespace %s of destination %s using %s: %d rows deleted, %d partitions dropped", tableName, warehouse.Namespace, warehouse.Destination.ID, retentionStats.Method, retentionStats.RowsDeleted, retentionStats.PartitionsDropped)
+		stats.Default.NewTaggedStat("warehouse.retention.succeededRuns", stats.CountType, tags).Count(1)
+	}
+	tags["method"] = retentionStats.Method
+	stats.Default.NewTaggedStat("warehouse.retention.rowsDeleted", stats.CountType, tags).Count(int(retentionStats.RowsDeleted))
+	stats.Default.NewTaggedStat("warehouse.retention.partitionsDropped", stats.CountType, tags).Count(int(retentionStats.PartitionsDropped))
+
+	return rh.completeRetentionRun(id, retentionStats, runErr)
+}
+
+// pruneTarget prunes the tables of the namespace which are due as per the retention policies of the destination
+func (rh *retentionHandleT) pruneTarget(ctx context.Context, target retentionTargetT) {
+	warehouse := target.warehouse
+
+	type dueTableT struct {
+		tableName string
+		policy    RetentionPolicyT
+	}
+	var dueTables []dueTableT
+	for _, policy := range getRetentionPolicies(warehouse) {
+		tableName := warehouseutils.ToProviderCase(warehouse.Type, policy.Table)
+		policy.Column = warehouseutils.ToProviderCase(warehouse.Type, policy.Column)
+		columns, ok := target.schema[tableName]
+		if !ok {
+			pkgLogger.Debugf("[WH]: Skipping retention of table %s not present in namespace %s of destination %s", tableName, warehouse.Namespace, warehouse.Destination.ID)
+			continue
+		}
+		if _, ok := columns[policy.Column]; !ok {
+			pkgLogger.Errorf("[WH]: Skipping retention of table %s, column %s is not present in namespace %s of destination %s", tableName, policy.Column, warehouse.Namespace, warehouse.Destination.ID)
+			continue
+		}
+		due, err := rh.isRetentionDue(warehouse, tableName)
+		if err != nil {
+			pkgLogger.Errorf("[WH]: Failed to check retention runs of table %s of destination %s: %v", tableName, warehouse.Destination.ID, err)
+			continue
+		}
+		if due {
+			dueTables = append(dueTables, dueTableT{tableName: tableName, policy: policy})
+		}
+	}
+	if len(dueTables) == 0 {
+		return
+	}
+
+	whManager, err := rh.newManager(warehouse.Type)
+	if err != nil {
+		pkgLogger.Errorf("[WH]: Failed to create manager for retention of destination %s: %v", warehouse.Destination.ID, err)
+		return
+	}
+	if err = whManager.Setup(warehouse, &jobs.WhAsyncJob{}); err != nil {
+		pkgLogger.Errorf("[WH]: Failed to setup manager for retention of destination %s: %v", warehouse.Destination.ID, err)
+		return
+	}
+	defer whManager.Cleanup()
+
+	for _, dueTable := range dueTables {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := rh.pruneTable(ctx, whManager, warehouse, dueTable.tableName, dueTable.policy); err != nil {
+			pkgLogger.Errorf("[WH]: Failed to record retention run of table %s of destination %s: %v", dueTable.tableName, warehouse.Destination.ID, err)
+		}
+	}
+}
+
+func runRetention(ctx context.Context, dbHandle *sql.DB) {
+	rh := &retentionHandleT{
+		dbHandle:   dbHandle,
+		newManager: manager.NewWarehouseOperations,
+	}
+	if err := rh.abortRetentionRuns(); err != nil {
+		pkgLogger.Errorf("[WH]: Failed to abort retention runs: %v", err)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			pkgLogger.Infof("context is cancelled, stopped running retention")
+			return
+		case <-time.After(retentionTickerTime):
+			if !enableRetention {
+				continue
+			}
+			for _, target := range retentionTargets() {
+				rh.pruneTarget(ctx, target)
+			}
+		}
+	}
+}
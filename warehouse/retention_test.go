@@ -0,0 +1,172 @@
+//go:build !warehouse_integration
+
+package warehouse
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/ory/dockertest/v3"
+
+	backendconfig "github.com/rudderlabs/rudder-server/config/backend-config"
+	"github.com/rudderlabs/rudder-server/testhelper"
+	"github.com/rudderlabs/rudder-server/testhelper/destination"
+	"github.com/rudderlabs/rudder-server/utils/logger"
+	warehouseutils "github.com/rudderlabs/rudder-server/warehouse/utils"
+)
+
+type pruneTableCall struct {
+	tableName string
+	params    warehouseutils.RetentionParamsT
+}
+
+type retentionManager struct {
+	calls []pruneTableCall
+	stats warehouseutils.RetentionStatsT
+	err   error
+}
+
+func (m *retentionManager) PruneTable(_ context.Context, tableName string, params warehouseutils.RetentionParamsT) (warehouseutils.RetentionStatsT, error) {
+	m.calls = append(m.calls, pruneTableCall{tableName: tableName, params: params})
+	return m.stats, m.err
+}
+
+var _ = Describe("Retention", func() {
+	DescribeTable("Retention policies", func(config interface{}, expected []RetentionPolicyT) {
+		pkgLogger = logger.NOP
+		warehouse := warehouseutils.Warehouse{
+			Destination: backendconfig.DestinationT{
+				Config: map[string]interface{}{
+					"retentionPolicies": config,
+				},
+			},
+		}
+		Expect(getRetentionPolicies(warehouse)).To(Equal(expected))
+	},
+		Entry("Not configured", nil, nil),
+		Entry("Invalid", "pages", nil),
+		Entry("Numeric days", []interface{}{
+			map[string]interface{}{"table": "pages", "retentionDays": 90.0},
+		}, []RetentionPolicyT{
+			{Table: "pages", Column: "received_at", RetentionDays: "90"},
+		}),
+		Entry("Textual days and custom column", []interface{}{
+			map[string]interface{}{"table": "tracks", "retentionDays": "30", "column": "sent_at"},
+		}, []RetentionPolicyT{
+			{Table: "tracks", Column: "sent_at", RetentionDays: "30"},
+		}),
+		Entry("Invalid policies are skipped", []interface{}{
+			map[string]interface{}{"table": "", "retentionDays": 30},
+			map[string]interface{}{"table": "pages", "retentionDays": 0},
+			map[string]interface{}{"table": "pages", "retentionDays": "ninety"},
+			map[string]interface{}{"table": "screens", "retentionDays": 7},
+		}, []RetentionPolicyT{
+			{Table: "screens", Column: "received_at", RetentionDays: "7"},
+		}),
+	)
+
+	Describe("Retention runs", Ordered, func() {
+		var (
+			pgResource *destination.PostgresResource
+			cleanup    = &testhelper.Cleanup{}
+			rh         *retentionHandleT
+			warehouse  = warehouseutils.Warehouse{
+				Source: backendconfig.SourceT{ID: "test-sourceID"},
+				Destination: backendconfig.DestinationT{
+					ID:          "test-destinationID",
+					WorkspaceID: "test-workspaceID",
+				},
+				Namespace: "test-namespace",
+				Type:      "POSTGRES",
+			}
+			policy = RetentionPolicyT{Table: "pages", Column: "received_at", RetentionDays: "90"}
+		)
+
+		BeforeAll(func() {
+			pool, err := dockertest.NewPool("")
+			Expect(err).To(BeNil())
+
+			pgResource = setupWarehouseJobs(pool, GinkgoT(), cleanup)
+
+			initWarehouse()
+
+			err = setupDB(context.TODO(), getConnectionString())
+			Expect(err).To(BeNil())
+
+			pkgLogger = logger.NOP
+			rh = &retentionHandleT{dbHandle: pgResource.DB}
+		})
+
+		AfterAll(func() {
+			cleanup.Run()
+		})
+
+		It("Should record a succeeded run", func() {
+			due, err := rh.isRetentionDue(warehouse, "pages")
+			Expect(err).To(BeNil())
+			Expect(due).To(BeTrue())
+
+			whManager := &retentionManager{stats: warehouseutils.RetentionStatsT{
+				Method:            warehouseutils.RetentionMethodPartitionDrop,
+				RowsDeleted:       100,
+				PartitionsDropped: 2,
+			}}
+			err = rh.pruneTable(context.Background(), whManager, warehouse, "pages", policy)
+			Expect(err).To(BeNil())
+
+			Expect(whManager.calls).To(HaveLen(1))
+			Expect(whManager.calls[0].tableName).To(Equal("pages"))
+			Expect(whManager.calls[0].params.Column).To(Equal("received_at"))
+			Expect(whManager.calls[0].params.Before).To(BeTemporally("~", time.Now().AddDate(0, 0, -90), time.Minute))
+
+			var status, method string
+			var rowsDeleted, partitionsDropped int64
+			var retentionDays int
+			err = pgResource.DB.QueryRow(`SELECT status, method, rows_deleted, partitions_dropped, retention_days FROM wh_retention_runs WHERE table_name = 'pages'`).Scan(&status, &method, &rowsDeleted, &partitionsDropped, &retentionDays)
+			Expect(err).To(BeNil())
+			Expect(status).To(Equal(RetentionRunSucceeded))
+			Expect(method).To(Equal(warehouseutils.RetentionMethodPartitionDrop))
+			Expect(rowsDeleted).To(Equal(int64(100)))
+			Expect(partitionsDropped).To(Equal(int64(2)))
+			Expect(retentionDays).To(Equal(90))
+
+			due, err = rh.isRetentionDue(warehouse, "pages")
+			Expect(err).To(BeNil())
+			Expect(due).To(BeFalse())
+		})
+
+		It("Should record a failed run and retry it", func() {
+			whManager := &retentionManager{err: errors.New("permission denied")}
+			err := rh.pruneTable(context.Background(), whManager, warehouse, "tracks", policy)
+			Expect(err).To(BeNil())
+
+			var status, runError string
+			err = pgResource.DB.QueryRow(`SELECT status, error FROM wh_retention_runs WHERE table_name = 'tracks'`).Scan(&status, &runError)
+			Expect(err).To(BeNil())
+			Expect(status).To(Equal(RetentionRunFailed))
+			Expect(runError).To(Equal("permission denied"))
+
+			due, err := rh.isRetentionDue(warehouse, "tracks")
+			Expect(err).To(BeNil())
+			Expect(due).To(BeTrue())
+		})
+
+		It("Should abort executing runs", func() {
+			_, err := rh.startRetentionRun(warehouse, "screens", policy, time.Now())
+			Expect(err).To(BeNil())
+
+			due, err := rh.isRetentionDue(warehouse, "screens")
+			Expect(err).To(BeNil())
+			Expect(due).To(BeFalse())
+
+			Expect(rh.abortRetentionRuns()).To(BeNil())
+
+			due, err = rh.isRetentionDue(warehouse, "screens")
+			Expect(err).To(BeNil())
+			Expect(due).To(BeTrue())
+		})
+	})
+})
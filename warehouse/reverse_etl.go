@@ -0,0 +1,767 @@
+package warehouse
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/lib/pq"
+	"google.golang.org/api/iterator"
+
+	backendconfig "github.com/rudderlabs/rudder-server/config/backend-config"
+	"github.com/rudderlabs/rudder-server/rruntime"
+	"github.com/rudderlabs/rudder-server/services/stats"
+	"github.com/rudderlabs/rudder-server/utils/misc"
+	"github.com/rudderlabs/rudder-server/utils/timeutil"
+	"github.com/rudderlabs/rudder-server/warehouse/client"
+	"github.com/rudderlabs/rudder-server/warehouse/manager"
+	warehouseutils "github.com/rudderlabs/rudder-server/warehouse/utils"
+)
+
+const reverseETLModels = "reverseETLModels"
+
+const (
+	ReverseETLIdentifyEvent = "identify"
+	ReverseETLTrackEvent    = "track"
+)
+
+const (
+	ReverseETLRunExecuting = "executing"
+	ReverseETLRunSucceeded = "succeeded"
+	ReverseETLRunFailed    = "failed"
+	ReverseETLRunAborted   = "aborted"
+)
+
+// ReverseETLModelT is a SQL model run periodically against a warehouse destination, whose new and changed rows are sent as events of a source, e.g.
+// {"name": "user_plans", "sql": "SELECT user_id, plan FROM analytics.users", "primaryKey": "user_id", "sourceId": "<sourceID>"}
+// sends an identify event with the trait plan for every user whose plan changed since the last sync
+type ReverseETLModelT struct {
+	Name       string `json:"name"`
+	SQL        string `json:"sql"`
+	PrimaryKey string `json:"primaryKey"`
+	// UserIDColumn is the column holding the user id of the events, the primary key by default
+	UserIDColumn string `json:"userIdColumn"`
+	// EventType is either identify, sending the columns as traits, or track, sending them as properties of the event EventName
+	EventType string `json:"eventType"`
+	EventName string `json:"eventName"`
+	// SourceID is the source the events are sent through, reaching the destinations connected to it
+	SourceID string `json:"sourceId"`
+	// SyncFrequency is the interval between syncs in minutes, Warehouse.reverseETL.defaultSyncFrequency by default
+	SyncFrequency json.Number `json:"syncFrequency"`
+}
+
+// reverseETLGatewayI sends the batches of events of a source to the gateway
+type reverseETLGatewayI interface {
+	Send(ctx context.Context, writeKey string, payload []byte) error
+}
+
+// reverseETLGatewayT sends the batches to the batch endpoint of the gateway at Warehouse.reverseETL.gatewayURL,
+// which validates and stores them like the batches of any other client of the source
+type reverseETLGatewayT struct {
+	client *http.Client
+}
+
+func (gw *reverseETLGatewayT) Send(ctx context.Context, writeKey string, payload []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(reverseETLGatewayURL, "/")+"/v1/batch", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(writeKey, "")
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := gw.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("gateway responded with status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+type reverseETLHandleT struct {
+	dbHandle   *sql.DB
+	gateway    reverseETLGatewayI
+	newManager func(destType string) (manager.ManagerI, error)
+
+	sourcesLock sync.RWMutex
+	sources     map[string]backendconfig.SourceT
+}
+
+// reverseETLRowT is a row of the result of a model, along with the hash of its values to detect changes
+type reverseETLRowT struct {
+	key    string
+	userID string
+	hash   string
+	values map[string]interface{}
+}
+
+// anonymousID identifies the events of the rows without a user id by their primary key, since the events need either of them
+func (row reverseETLRowT) anonymousID() string {
+	if row.userID != "" {
+		return ""
+	}
+	return row.key
+}
+
+type reverseETLRunStatsT struct {
+	rowsQueried int64
+	rowsChanged int64
+	rowsRemoved int64
+}
+
+// getReverseETLModels returns the valid reverse ETL models configured for the destination of the warehouse
+func getReverseETLModels(warehouse warehouseutils.Warehouse) []ReverseETLModelT {
+	configured, ok := warehouse.Destination.Config[reverseETLModels]
+	if !ok || configured == nil {
+		return nil
+	}
+	rawModels, err := json.Marshal(configured)
+	if err != nil {
+		return nil
+	}
+	var models []json.RawMessage
+	if err = json.Unmarshal(rawModels, &models); err != nil {
+		pkgLogger.Errorf("[WH]: Invalid reverse ETL models for destination %s: %v", warehouse.Destination.ID, err)
+		return nil
+	}
+
+	var validModels []ReverseETLModelT
+	names := make(map[string]bool)
+	for _, rawModel := range models {
+		var model ReverseETLModelT
+		if err := json.Unmarshal(rawModel, &model); err != nil || !model.valid() || names[model.Name] {
+			pkgLogger.Errorf("[WH]: Skipping invalid reverse ETL model %s for destination %s", rawModel, warehouse.Destination.ID)
+			continue
+		}
+		if model.EventType == "" {
+			model.EventType = ReverseETLIdentifyEvent
+		}
+		if model.UserIDColumn == "" {
+			model.UserIDColumn = model.PrimaryKey
+		}
+		names[model.Name] = true
+		validModels = append(validModels, model)
+	}
+	return validModels
+}
+
+func (model ReverseETLModelT) valid() bool {
+	if model.Name == "" || strings.TrimSpace(model.SQL) == "" || model.PrimaryKey == "" || model.SourceID == "" {
+		return false
+	}
+	if model.SyncFrequency != "" {
+		if _, err := strconv.Atoi(model.SyncFrequency.String()); err != nil {
+			return false
+		}
+	}
+	switch model.EventType {
+	case "", ReverseETLIdentifyEvent:
+		return true
+	case ReverseETLTrackEvent:
+		return model.EventName != ""
+	default:
+		return false
+	}
+}
+
+func (model ReverseETLModelT) syncFrequency() time.Duration {
+	minutes, _ := strconv.Atoi(model.SyncFrequency.String())
+	if minutes <= 0 {
+		return reverseETLDefaultSyncFrequency
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// reverseETLTargets returns a connection of every enabled destination which has reverse ETL models.
+// The models only depend on the credentials of the destination, so the connection of the first source is used.
+func reverseETLTargets() []warehouseutils.Warehouse {
+	connectionsMapLock.RLock()
+	defer connectionsMapLock.RUnlock()
+
+	var targets []warehouseutils.Warehouse
+	for _, srcMap := range connectionsMap {
+		sourceIDs := make([]string, 0, len(srcMap))
+		for sourceID := range srcMap {
+			sourceIDs = append(sourceIDs, sourceID)
+		}
+		sort.Strings(sourceIDs)
+		for _, sourceID := range sourceIDs {
+			warehouse := srcMap[sourceID]
+			if !warehouse.Destination.Enabled || len(getReverseETLModels(warehouse)) == 0 {
+				continue
+			}
+			targets = append(targets, warehouse)
+			break
+		}
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		return targets[i].Destination.ID < targets[j].Destination.ID
+	})
+	return targets
+}
+
+// reverseETLResultT is the result of a model, with the values of its rows as scanned from the warehouse
+type reverseETLResultT struct {
+	columns []string
+	values  [][]interface{}
+}
+
+// reverseETLValue returns the value scanned from the warehouse as sent in the events, keeping its type
+func reverseETLValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case []byte:
+		return string(v)
+	case *big.Rat:
+		return bigquery.NumericString(v)
+	default:
+		return v
+	}
+}
+
+// queryReverseETLModel runs the SQL of the model, failing once it returns more than Warehouse.reverseETL.maxRows rows
+func queryReverseETLModel(ctx context.Context, whClient client.Client, statement string) (result reverseETLResultT, err error) {
+	tooManyRows := fmt.Errorf("model returned more than the maximum of %d rows", reverseETLMaxRows)
+	switch whClient.Type {
+	case client.SQLClient:
+		rows, err := whClient.SQL.QueryContext(ctx, statement)
+		if err != nil {
+			return result, err
+		}
+		defer rows.Close()
+		if result.columns, err = rows.Columns(); err != nil {
+			return result, err
+		}
+		for rows.Next() {
+			if len(result.values) == reverseETLMaxRows {
+				return result, tooManyRows
+			}
+			values := make([]interface{}, len(result.columns))
+			valuePtrs := make([]interface{}, len(result.columns))
+			for i := range values {
+				valuePtrs[i] = &values[i]
+			}
+			if err = rows.Scan(valuePtrs...); err != nil {
+				return result, err
+			}
+			for i, value := range values {
+				values[i] = reverseETLValue(value)
+			}
+			result.values = append(result.values, values)
+		}
+		return result, rows.Err()
+	case client.BQClient:
+		it, err := whClient.BQ.Query(statement).Read(ctx)
+		if err != nil {
+			return result, err
+		}
+		for {
+			var row []bigquery.Value
+			err = it.Next(&row)
+			if err == iterator.Done {
+				break
+			}
+			if err != nil {
+				return result, err
+			}
+			if len(result.values) == reverseETLMaxRows {
+				return result, tooManyRows
+			}
+			values := make([]interface{}, len(row))
+			for i, value := range row {
+				values[i] = reverseETLValue(value)
+			}
+			result.values = append(result.values, values)
+		}
+		for _, field := range it.Schema {
+			result.columns = append(result.columns, field.Name)
+		}
+		return result, nil
+	default:
+		// the databricks client only returns the values as strings
+		queryResult, err := whClient.Query(statement)
+		if err != nil {
+			return result, err
+		}
+		if len(queryResult.Values) > reverseETLMaxRows {
+			return result, tooManyRows
+		}
+		result.columns = queryResult.Columns
+		for _, row := range queryResult.Values {
+			values := make([]interface{}, len(row))
+			for i, value := range row {
+				values[i] = value
+			}
+			result.values = append(result.values, values)
+		}
+		return result, nil
+	}
+}
+
+// reverseETLRows maps the result of a model to rows keyed by its primary key
+func reverseETLRows(model ReverseETLModelT, result reverseETLResultT) ([]reverseETLRowT, error) {
+	keyIdx, userIDIdx := -1, -1
+	for idx, column := range result.columns {
+		if strings.EqualFold(column, model.PrimaryKey) {
+			keyIdx = idx
+		}
+		if strings.EqualFold(column, model.UserIDColumn) {
+			userIDIdx = idx
+		}
+	}
+	if keyIdx == -1 {
+		return nil, fmt.Errorf("primary key %s is not a column of the model, columns are %v", model.PrimaryKey, result.columns)
+	}
+	if userIDIdx == -1 {
+		return nil, fmt.Errorf("user id column %s is not a column of the model, columns are %v", model.UserIDColumn, result.columns)
+	}
+
+	rows := make([]reverseETLRowT, 0, len(result.values))
+	keys := make(map[string]bool, len(result.values))
+	for _, values := range result.values {
+		if len(values) != len(result.columns) {
+			return nil, fmt.Errorf("row has %d values for %d columns", len(values), len(result.columns))
+		}
+		if values[keyIdx] == nil {
+			return nil, fmt.Errorf("primary key %s is empty", model.PrimaryKey)
+		}
+		key := fmt.Sprint(values[keyIdx])
+		if key == "" {
+			return nil, fmt.Errorf("primary key %s is empty", model.PrimaryKey)
+		}
+		if keys[key] {
+			return nil, fmt.Errorf("primary key %s is not unique, %q is duplicated", model.PrimaryKey, key)
+		}
+		keys[key] = true
+
+		row := reverseETLRowT{key: key, values: make(map[string]interface{}, len(values))}
+		if userID := values[userIDIdx]; userID != nil {
+			row.userID = fmt.Sprint(userID)
+		}
+		for idx, value := range values {
+			row.values[result.columns[idx]] = value
+		}
+		// the keys of maps are marshalled in order, so that the hash does not depend on the order of the columns
+		marshalledValues, err := json.Marshal(row.values)
+		if err != nil {
+			return nil, err
+		}
+		hash := md5.Sum(marshalledValues)
+		row.hash = hex.EncodeToString(hash[:])
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// diffReverseETLRows returns the rows which are new or changed since the snapshot, along with the keys of the rows which are gone
+func diffReverseETLRows(rows []reverseETLRowT, snapshot map[string]string) (changed []reverseETLRowT, removed []string) {
+	keys := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		keys[row.key] = true
+		if hash, ok := snapshot[row.key]; !ok || hash != row.hash {
+			changed = append(changed, row)
+		}
+	}
+	for key := range snapshot {
+		if !keys[key] {
+			removed = append(removed, key)
+		}
+	}
+	sort.Strings(removed)
+	return changed, removed
+}
+
+// reverseETLMessageID derives the message id of the event of the row from the model and the values of the row,
+// so that the events sent again by a sync retrying a failed one get deduplicated
+func reverseETLMessageID(destinationID string, model ReverseETLModelT, row reverseETLRowT) (string, error) {
+	messageID, err := misc.GetMD5UUID(strings.Join([]string{destinationID, model.Name, row.key, row.hash}, ":"))
+	if err != nil {
+		return "", err
+	}
+	return messageID.String(), nil
+}
+
+// reverseETLBatches batches the events of the changed rows into the payloads of requests to the batch endpoint of the gateway
+func reverseETLBatches(destinationID string, model ReverseETLModelT, runID string, rows []reverseETLRowT) ([][]byte, error) {
+	var batches [][]byte
+	maxEventsPerBatch := reverseETLMaxEventsPerBatch
+	if maxEventsPerBatch < 1 {
+		maxEventsPerBatch = 1
+	}
+	for start := 0; start < len(rows); start += maxEventsPerBatch {
+		end := start + maxEventsPerBatch
+		if end > len(rows) {
+			end = len(rows)
+		}
+		now := timeutil.Now().Format(misc.RFC3339Milli)
+		batch := make([]map[string]interface{}, 0, end-start)
+		for _, row := range rows[start:end] {
+			messageID, err := reverseETLMessageID(destinationID, model, row)
+			if err != nil {
+				return nil, err
+			}
+			event := map[string]interface{}{
+				"type":              model.EventType,
+				"userId":            row.userID,
+				"anonymousId":       row.anonymousID(),
+				"messageId":         messageID,
+				"originalTimestamp": now,
+				"sentAt":            now,
+				"context": map[string]interface{}{
+					// the gateway records the job run of the first event of a batch as the job run of the batch
+					"sources": map[string]interface{}{
+						"job_run_id":  runID,
+						"task_run_id": model.Name,
+					},
+				},
+			}
+			if model.EventType == ReverseETLTrackEvent {
+				event["event"] = model.EventName
+				event["properties"] = row.values
+			} else {
+				event["traits"] = row.values
+			}
+			batch = append(batch, event)
+		}
+
+		payload, err := json.Marshal(map[string]interface{}{
+			"batch":  batch,
+			"sentAt": now,
+		})
+		if err != nil {
+			return nil, err
+		}
+		batches = append(batches, payload)
+	}
+	return batches, nil
+}
+
+func (rh *reverseETLHandleT) setSources(config map[string]backendconfig.ConfigT) {
+	sources := make(map[string]backendconfig.SourceT)
+	for _, wConfig := range config {
+		for _, source := range wConfig.Sources {
+			sources[source.ID] = source
+		}
+	}
+	rh.sourcesLock.Lock()
+	rh.sources = sources
+	rh.sourcesLock.Unlock()
+}
+
+// getSource returns the enabled source the events of a model are sent through, which has to belong to the workspace of the destination of the model
+func (rh *reverseETLHandleT) getSource(sourceID, workspaceID string) (backendconfig.SourceT, error) {
+	rh.sourcesLock.RLock()
+	source, ok := rh.sources[sourceID]
+	rh.sourcesLock.RUnlock()
+	if !ok {
+		return source, fmt.Errorf("source %s does not exist", sourceID)
+	}
+	if source.WorkspaceID != workspaceID {
+		return backendconfig.SourceT{}, fmt.Errorf("source %s does not belong to workspace %s of the destination", sourceID, workspaceID)
+	}
+	if !source.Enabled {
+		return source, fmt.Errorf("source %s is disabled", sourceID)
+	}
+	if source.WriteKey == "" {
+		return source, fmt.Errorf("source %s has no write key", sourceID)
+	}
+	return source, nil
+}
+
+// isSyncDue returns whether the model has not been synced successfully in its sync frequency
+func (rh *reverseETLHandleT) isSyncDue(warehouse warehouseutils.Warehouse, model ReverseETLModelT) (bool, error) {
+	sqlStatement := fmt.Sprintf(`
+		SELECT
+		  COUNT(*)
+		FROM
+		  %[1]s
+		WHERE
+		  destination_id = $1
+		  AND model_name = $2
+		  AND (
+			status = '%[2]s'
+			OR status = '%[3]s'
+		  )
+		  AND started_at > $3;
+`,
+		warehouseutils.WarehouseReverseETLRunsTable,
+		ReverseETLRunSucceeded,
+		ReverseETLRunExecuting,
+	)
+	var count int
+	err := rh.dbHandle.QueryRow(sqlStatement, warehouse.Destination.ID, model.Name, timeutil.Now().Add(-model.syncFrequency())).Scan(&count)
+	return count == 0, err
+}
+
+func (rh *reverseETLHandleT) startReverseETLRun(warehouse warehouseutils.Warehouse, model ReverseETLModelT) (id int64, err error) {
+	sqlStatement := fmt.Sprintf(`
+		INSERT INTO %s (
+		  workspace_id, destination_id, destination_type,
+		  model_name, source_id, status, started_at
+		)
+		VALUES
+		  ($1, $2, $3, $4, $5, $6, $7) RETURNING id;
+`,
+		warehouseutils.WarehouseReverseETLRunsTable,
+	)
+	err = rh.dbHandle.QueryRow(sqlStatement,
+		warehouse.Destination.WorkspaceID,
+		warehouse.Destination.ID,
+		warehouse.Type,
+		model.Name,
+		model.SourceID,
+		ReverseETLRunExecuting,
+		timeutil.Now(),
+	).Scan(&id)
+	return
+}
+
+func (rh *reverseETLHandleT) completeReverseETLRun(id int64, runStats reverseETLRunStatsT, runErr error) error {
+	status := ReverseETLRunSucceeded
+	var errorMessage sql.NullString
+	if runErr != nil {
+		status = ReverseETLRunFailed
+		errorMessage = sql.NullString{String: runErr.Error(), Valid: true}
+	}
+	sqlStatement := fmt.Sprintf(`
+		UPDATE
+		  %s
+		SET
+		  status = $1,
+		  rows_queried = $2,
+		  rows_changed = $3,
+		  rows_removed = $4,
+		  error = $5,
+		  completed_at = $6
+		WHERE
+		  id = $7;
+`,
+		warehouseutils.WarehouseReverseETLRunsTable,
+	)
+	_, err := rh.dbHandle.Exec(sqlStatement, status, runStats.rowsQueried, runStats.rowsChanged, runStats.rowsRemoved, errorMessage, timeutil.Now(), id)
+	return err
+}
+
+// abortReverseETLRuns marks the runs left executing by a previous master as aborted, so that they are picked up again
+func (rh *reverseETLHandleT) abortReverseETLRuns() error {
+	sqlStatement := fmt.Sprintf(`UPDATE %s SET status = $1, completed_at = $2 WHERE status = $3;`, warehouseutils.WarehouseReverseETLRunsTable)
+	_, err := rh.dbHandle.Exec(sqlStatement, ReverseETLRunAborted, timeutil.Now(), ReverseETLRunExecuting)
+	return err
+}
+
+// getSnapshot returns the hashes of the rows of the model sent in the previous syncs, by primary key
+func (rh *reverseETLHandleT) getSnapshot(destinationID, modelName string) (map[string]string, error) {
+	sqlStatement := fmt.Sprintf(`SELECT row_key, row_hash FROM %s WHERE destination_id = $1 AND model_name = $2;`, warehouseutils.WarehouseReverseETLSnapshotsTable)
+	rows, err := rh.dbHandle.Query(sqlStatement, destinationID, modelName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snapshot := make(map[string]string)
+	for rows.Next() {
+		var key, hash string
+		if err := rows.Scan(&key, &hash); err != nil {
+			return nil, err
+		}
+		snapshot[key] = hash
+	}
+	return snapshot, rows.Err()
+}
+
+// saveSnapshot records the rows sent by a sync and forgets the removed ones, so that they are sent again if they reappear
+func (rh *reverseETLHandleT) saveSnapshot(destinationID, modelName string, changed []reverseETLRowT, removed []string) (err error) {
+	txn, err := rh.dbHandle.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = txn.Rollback()
+		}
+	}()
+
+	if len(removed) > 0 {
+		sqlStatement := fmt.Sprintf(`DELETE FROM %s WHERE destination_id = $1 AND model_name = $2 AND row_key = ANY($3);`, warehouseutils.WarehouseReverseETLSnapshotsTable)
+		if _, err = txn.Exec(sqlStatement, destinationID, modelName, pq.Array(removed)); err != nil {
+			return err
+		}
+	}
+	if len(changed) > 0 {
+		sqlStatement := fmt.Sprintf(`
+			INSERT INTO %s (
+			  destination_id, model_name, row_key, row_hash, synced_at
+			)
+			VALUES
+			  ($1, $2, $3, $4, $5) ON CONFLICT (destination_id, model_name, row_key) DO
+			UPDATE
+			SET
+			  row_hash = EXCLUDED.row_hash,
+			  synced_at = EXCLUDED.synced_at;
+`,
+			warehouseutils.WarehouseReverseETLSnapshotsTable,
+		)
+		var stmt *sql.Stmt
+		if stmt, err = txn.Prepare(sqlStatement); err != nil {
+			return err
+		}
+		defer stmt.Close()
+		now := timeutil.Now()
+		for _, row := range changed {
+			if _, err = stmt.Exec(destinationID, modelName, row.key, row.hash, now); err != nil {
+				return err
+			}
+		}
+	}
+	return txn.Commit()
+}
+
+// queryModel runs the SQL of the model against the warehouse, cancelling it after Warehouse.reverseETL.queryTimeout
+func (rh *reverseETLHandleT) queryModel(ctx context.Context, warehouse warehouseutils.Warehouse, model ReverseETLModelT) ([]reverseETLRowT, error) {
+	whManager, err := rh.newManager(warehouse.Type)
+	if err != nil {
+		return nil, err
+	}
+	whManager.SetConnectionTimeout(reverseETLQueryTimeout)
+	whClient, err := whManager.Connect(warehouse)
+	if err != nil {
+		return nil, err
+	}
+	defer whClient.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, reverseETLQueryTimeout)
+	defer cancel()
+	result, err := queryReverseETLModel(ctx, whClient, model.SQL)
+	if err != nil {
+		return nil, err
+	}
+	return reverseETLRows(model, result)
+}
+
+// runModel sends the events of the rows of the model which changed since the last sync to the gateway, before recording them in the snapshot.
+// The events of a sync which fails after sending some of them are sent again by the next sync.
+func (rh *reverseETLHandleT) runModel(ctx context.Context, warehouse warehouseutils.Warehouse, model ReverseETLModelT, source backendconfig.SourceT, runID string) (runStats reverseETLRunStatsT, err error) {
+	rows, err := rh.queryModel(ctx, warehouse, model)
+	if err != nil {
+		return runStats, err
+	}
+	runStats.rowsQueried = int64(len(rows))
+
+	snapshot, err := rh.getSnapshot(warehouse.Destination.ID, model.Name)
+	if err != nil {
+		return runStats, err
+	}
+	changed, removed := diffReverseETLRows(rows, snapshot)
+	runStats.rowsChanged, runStats.rowsRemoved = int64(len(changed)), int64(len(removed))
+
+	batches, err := reverseETLBatches(warehouse.Destination.ID, model, runID, changed)
+	if err != nil {
+		return runStats, err
+	}
+	for _, batch := range batches {
+		if err = rh.gateway.Send(ctx, source.WriteKey, batch); err != nil {
+			return runStats, fmt.Errorf("sending events to gateway: %w", err)
+		}
+	}
+	return runStats, rh.saveSnapshot(warehouse.Destination.ID, model.Name, changed, removed)
+}
+
+// syncModel syncs a model and records the run along with its stats
+func (rh *reverseETLHandleT) syncModel(ctx context.Context, warehouse warehouseutils.Warehouse, model ReverseETLModelT) error {
+	id, err := rh.startReverseETLRun(warehouse, model)
+	if err != nil {
+		return err
+	}
+
+	tags := map[string]string{
+		"workspaceId": warehouse.Destination.WorkspaceID,
+		"destination": warehouse.Destination.ID,
+		"destType":    warehouse.Type,
+		"model":       model.Name,
+		"sourceID":    model.SourceID,
+	}
+	startTime := time.Now()
+	var runStats reverseETLRunStatsT
+	source, runErr := rh.getSource(model.SourceID, warehouse.Destination.WorkspaceID)
+	if runErr == nil {
+		runStats, runErr = rh.runModel(ctx, warehouse, model, source, fmt.Sprintf("reverse-etl-%d", id))
+	}
+	stats.Default.NewTaggedStat("warehouse.reverseETL.runTime", stats.TimerType, tags).Since(startTime)
+
+	if runErr != nil {
+		pkgLogger.Errorf("[WH]: Failed to sync reverse ETL model %s of destination %s: %v", model.Name, warehouse.Destination.ID, runErr)
+		stats.Default.NewTaggedStat("warehouse.reverseETL.failedRuns", stats.CountType, tags).Count(1)
+	} else {
+		pkgLogger.Infof("[WH]: Synced reverse ETL model %s of destination %s to source %s: %d rows queried, %d changed, %d removed", model.Name, warehouse.Destination.ID, model.SourceID, runStats.rowsQueried, runStats.rowsChanged, runStats.rowsRemoved)
+		stats.Default.NewTaggedStat("warehouse.reverseETL.succeededRuns", stats.CountType, tags).Count(1)
+	}
+	stats.Default.NewTaggedStat("warehouse.reverseETL.rowsChanged", stats.CountType, tags).Count(int(runStats.rowsChanged))
+
+	return rh.completeReverseETLRun(id, runStats, runErr)
+}
+
+// syncTarget syncs the models of the destination which are due
+func (rh *reverseETLHandleT) syncTarget(ctx context.Context, warehouse warehouseutils.Warehouse) {
+	for _, model := range getReverseETLModels(warehouse) {
+		if ctx.Err() != nil {
+			return
+		}
+		due, err := rh.isSyncDue(warehouse, model)
+		if err != nil {
+			pkgLogger.Errorf("[WH]: Failed to check reverse ETL runs of model %s of destination %s: %v", model.Name, warehouse.Destination.ID, err)
+			continue
+		}
+		if !due {
+			continue
+		}
+		if err := rh.syncModel(ctx, warehouse, model); err != nil {
+			pkgLogger.Errorf("[WH]: Failed to record reverse ETL run of model %s of destination %s: %v", model.Name, warehouse.Destination.ID, err)
+		}
+	}
+}
+
+func runReverseETL(ctx context.Context, dbHandle *sql.DB) {
+	rh := &reverseETLHandleT{
+		dbHandle:   dbHandle,
+		gateway:    &reverseETLGatewayT{client: &http.Client{Timeout: reverseETLGatewayTimeout}},
+		newManager: manager.New,
+	}
+	if err := rh.abortReverseETLRuns(); err != nil {
+		pkgLogger.Errorf("[WH]: Failed to abort reverse ETL runs: %v", err)
+	}
+	ch := backendconfig.DefaultBackendConfig.Subscribe(ctx, backendconfig.TopicBackendConfig)
+	rruntime.GoForWarehouse(func() {
+		for data := range ch {
+			rh.setSources(data.Data.(map[string]backendconfig.ConfigT))
+		}
+	})
+
+	for {
+		select {
+		case <-ctx.Done():
+			pkgLogger.Infof("context is cancelled, stopped running reverse ETL")
+			return
+		case <-time.After(reverseETLTickerTime):
+			if !enableReverseETL {
+				continue
+			}
+			for _, target := range reverseETLTargets() {
+				rh.syncTarget(ctx, target)
+			}
+		}
+	}
+}
@@ -0,0 +1,382 @@
+//go:build !warehouse_integration
+
+package warehouse
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/ory/dockertest/v3"
+	"github.com/tidwall/gjson"
+
+	backendconfig "github.com/rudderlabs/rudder-server/config/backend-config"
+	"github.com/rudderlabs/rudder-server/testhelper"
+	"github.com/rudderlabs/rudder-server/testhelper/destination"
+	"github.com/rudderlabs/rudder-server/utils/logger"
+	"github.com/rudderlabs/rudder-server/warehouse/client"
+	"github.com/rudderlabs/rudder-server/warehouse/manager"
+	"github.com/rudderlabs/rudder-server/warehouse/sqlite"
+	warehouseutils "github.com/rudderlabs/rudder-server/warehouse/utils"
+)
+
+type reverseETLManager struct {
+	manager.ManagerI
+	dsn string
+}
+
+func (m *reverseETLManager) SetConnectionTimeout(_ time.Duration) {}
+
+func (m *reverseETLManager) Connect(_ warehouseutils.Warehouse) (client.Client, error) {
+	db, err := sql.Open("postgres", m.dsn)
+	return client.Client{Type: client.SQLClient, SQL: db}, err
+}
+
+type reverseETLGateway struct {
+	writeKeys []string
+	batches   [][]byte
+}
+
+func (gw *reverseETLGateway) Send(_ context.Context, writeKey string, payload []byte) error {
+	gw.writeKeys = append(gw.writeKeys, writeKey)
+	gw.batches = append(gw.batches, payload)
+	return nil
+}
+
+var _ = Describe("ReverseETL", func() {
+	model := ReverseETLModelT{
+		Name:         "user_plans",
+		SQL:          "SELECT user_id, plan FROM users",
+		PrimaryKey:   "user_id",
+		UserIDColumn: "user_id",
+		EventType:    ReverseETLIdentifyEvent,
+		SourceID:     "test-sourceID",
+	}
+	source := backendconfig.SourceT{
+		ID:          "test-sourceID",
+		WriteKey:    "test-writeKey",
+		WorkspaceID: "test-workspaceID",
+		Enabled:     true,
+	}
+
+	BeforeEach(func() {
+		pkgLogger = logger.NOP
+		reverseETLMaxRows = 100000
+		reverseETLMaxEventsPerBatch = 100
+	})
+
+	DescribeTable("Reverse ETL models", func(config interface{}, expected []ReverseETLModelT) {
+		warehouse := warehouseutils.Warehouse{
+			Destination: backendconfig.DestinationT{
+				Config: map[string]interface{}{
+					"reverseETLModels": config,
+				},
+			},
+		}
+		Expect(getReverseETLModels(warehouse)).To(Equal(expected))
+	},
+		Entry("Not configured", nil, nil),
+		Entry("Invalid", "users", nil),
+		Entry("Identify by default", []interface{}{
+			map[string]interface{}{"name": "user_plans", "sql": "SELECT user_id, plan FROM users", "primaryKey": "user_id", "sourceId": "test-sourceID"},
+		}, []ReverseETLModelT{model}),
+		Entry("Track events", []interface{}{
+			map[string]interface{}{"name": "orders", "sql": "SELECT id, email FROM orders", "primaryKey": "id", "userIdColumn": "email", "eventType": "track", "eventName": "Order Updated", "sourceId": "test-sourceID", "syncFrequency": 15},
+		}, []ReverseETLModelT{
+			{Name: "orders", SQL: "SELECT id, email FROM orders", PrimaryKey: "id", UserIDColumn: "email", EventType: ReverseETLTrackEvent, EventName: "Order Updated", SourceID: "test-sourceID", SyncFrequency: "15"},
+		}),
+		Entry("Invalid models are skipped", []interface{}{
+			map[string]interface{}{"name": "no_sql", "primaryKey": "id", "sourceId": "test-sourceID"},
+			map[string]interface{}{"name": "no_key", "sql": "SELECT 1", "sourceId": "test-sourceID"},
+			map[string]interface{}{"name": "no_source", "sql": "SELECT 1", "primaryKey": "id"},
+			map[string]interface{}{"name": "no_event_name", "sql": "SELECT 1", "primaryKey": "id", "sourceId": "test-sourceID", "eventType": "track"},
+			map[string]interface{}{"name": "page", "sql": "SELECT 1", "primaryKey": "id", "sourceId": "test-sourceID", "eventType": "page"},
+			map[string]interface{}{"name": "hourly", "sql": "SELECT 1", "primaryKey": "id", "sourceId": "test-sourceID", "syncFrequency": "hourly"},
+			map[string]interface{}{"name": "user_plans", "sql": "SELECT user_id, plan FROM users", "primaryKey": "user_id", "sourceId": "test-sourceID"},
+			map[string]interface{}{"name": "user_plans", "sql": "SELECT 1", "primaryKey": "id", "sourceId": "test-sourceID"},
+		}, []ReverseETLModelT{model}),
+	)
+
+	It("Should key the rows of the result by primary key", func() {
+		rows, err := reverseETLRows(model, reverseETLResultT{
+			columns: []string{"USER_ID", "PLAN", "SEATS"},
+			values:  [][]interface{}{{"user-1", "pro", int64(3)}, {"user-2", nil, nil}, {int64(3), "free", int64(1)}},
+		})
+		Expect(err).To(BeNil())
+		Expect(rows).To(HaveLen(3))
+		Expect(rows[0].key).To(Equal("user-1"))
+		Expect(rows[0].userID).To(Equal("user-1"))
+		Expect(rows[0].values).To(Equal(map[string]interface{}{"USER_ID": "user-1", "PLAN": "pro", "SEATS": int64(3)}), "values keep their types")
+		Expect(rows[1].values).To(Equal(map[string]interface{}{"USER_ID": "user-2", "PLAN": nil, "SEATS": nil}), "NULL values are sent as null")
+		Expect(rows[2].key).To(Equal("3"))
+		Expect(rows[2].userID).To(Equal("3"))
+
+		reordered, err := reverseETLRows(model, reverseETLResultT{
+			columns: []string{"SEATS", "PLAN", "USER_ID"},
+			values:  [][]interface{}{{int64(3), "pro", "user-1"}},
+		})
+		Expect(err).To(BeNil())
+		Expect(reordered[0].hash).To(Equal(rows[0].hash), "the hash does not depend on the order of the columns")
+	})
+
+	DescribeTable("Invalid results", func(result reverseETLResultT, expectedErr string) {
+		_, err := reverseETLRows(model, result)
+		Expect(err).To(MatchError(ContainSubstring(expectedErr)))
+	},
+		Entry("Missing primary key", reverseETLResultT{columns: []string{"id"}, values: [][]interface{}{{"1"}}}, "primary key user_id is not a column"),
+		Entry("Duplicated primary key", reverseETLResultT{columns: []string{"user_id"}, values: [][]interface{}{{"1"}, {int64(1)}}}, `"1" is duplicated`),
+		Entry("Null primary key", reverseETLResultT{columns: []string{"user_id"}, values: [][]interface{}{{nil}}}, "primary key user_id is empty"),
+		Entry("Empty primary key", reverseETLResultT{columns: []string{"user_id"}, values: [][]interface{}{{""}}}, "primary key user_id is empty"),
+	)
+
+	Describe("Query model", func() {
+		var whClient client.Client
+
+		BeforeEach(func() {
+			db, err := sqlite.Connect(sqlite.CredentialsT{Directory: GinkgoT().TempDir(), Namespace: "test_namespace"})
+			Expect(err).To(BeNil())
+			DeferCleanup(db.Close)
+			_, err = db.Exec(`
+				CREATE TABLE users (user_id TEXT, plan TEXT, seats INTEGER, revenue REAL, config BLOB);
+				INSERT INTO users VALUES ('user-1', 'pro', 3, 9.5, CAST('{}' AS BLOB)), ('user-2', NULL, NULL, NULL, NULL);
+			`)
+			Expect(err).To(BeNil())
+			whClient = client.Client{Type: client.SQLClient, SQL: db}
+		})
+
+		It("Should keep the types of the values scanned from the warehouse", func() {
+			result, err := queryReverseETLModel(context.Background(), whClient, "SELECT * FROM users ORDER BY user_id")
+			Expect(err).To(BeNil())
+			Expect(result.columns).To(Equal([]string{"user_id", "plan", "seats", "revenue", "config"}))
+			Expect(result.values).To(Equal([][]interface{}{
+				{"user-1", "pro", int64(3), 9.5, "{}"},
+				{"user-2", nil, nil, nil, nil},
+			}))
+		})
+
+		It("Should fail once the model returns too many rows", func() {
+			reverseETLMaxRows = 1
+			_, err := queryReverseETLModel(context.Background(), whClient, "SELECT * FROM users")
+			Expect(err).To(MatchError("model returned more than the maximum of 1 rows"))
+		})
+	})
+
+	It("Should only send the events through sources of the workspace of the destination", func() {
+		rh := &reverseETLHandleT{}
+		rh.setSources(map[string]backendconfig.ConfigT{
+			"test-workspaceID":  {Sources: []backendconfig.SourceT{source}},
+			"other-workspaceID": {Sources: []backendconfig.SourceT{{ID: "other-sourceID", WriteKey: "other-writeKey", WorkspaceID: "other-workspaceID", Enabled: true}}},
+		})
+
+		got, err := rh.getSource(source.ID, "test-workspaceID")
+		Expect(err).To(BeNil())
+		Expect(got.WriteKey).To(Equal("test-writeKey"))
+
+		_, err = rh.getSource("other-sourceID", "test-workspaceID")
+		Expect(err).To(MatchError("source other-sourceID does not belong to workspace test-workspaceID of the destination"))
+
+		_, err = rh.getSource("test-unknown-sourceID", "test-workspaceID")
+		Expect(err).To(MatchError("source test-unknown-sourceID does not exist"))
+	})
+
+	It("Should diff the rows against the snapshot", func() {
+		rows := []reverseETLRowT{
+			{key: "unchanged", hash: "a"},
+			{key: "changed", hash: "b"},
+			{key: "new", hash: "c"},
+		}
+		changed, removed := diffReverseETLRows(rows, map[string]string{"unchanged": "a", "changed": "x", "gone": "y"})
+		Expect(changed).To(Equal(rows[1:]))
+		Expect(removed).To(Equal([]string{"gone"}))
+	})
+
+	It("Should batch the events of the rows into requests to the gateway", func() {
+		reverseETLMaxEventsPerBatch = 2
+		rows := []reverseETLRowT{
+			{key: "user-1", userID: "user-1", values: map[string]interface{}{"plan": "pro", "seats": int64(3)}},
+			{key: "user-2", userID: "user-2", values: map[string]interface{}{"plan": "free", "seats": nil}},
+			{key: "user-3", values: map[string]interface{}{"plan": "free"}},
+		}
+		batches, err := reverseETLBatches("test-destinationID", model, "reverse-etl-1", rows)
+		Expect(err).To(BeNil())
+		Expect(batches).To(HaveLen(2))
+
+		payload := batches[0]
+		Expect(gjson.GetBytes(payload, "writeKey").Exists()).To(BeFalse(), "the write key is sent as basic auth")
+		Expect(gjson.GetBytes(payload, "batch.#").Int()).To(Equal(int64(2)))
+		Expect(gjson.GetBytes(payload, "batch.0.type").String()).To(Equal("identify"))
+		Expect(gjson.GetBytes(payload, "batch.0.userId").String()).To(Equal("user-1"))
+		Expect(gjson.GetBytes(payload, "batch.0.traits.plan").String()).To(Equal("pro"))
+		Expect(gjson.GetBytes(payload, "batch.0.traits.seats").Type).To(Equal(gjson.Number))
+		Expect(gjson.GetBytes(payload, "batch.1.traits.seats").Type).To(Equal(gjson.Null))
+		Expect(gjson.GetBytes(payload, "batch.0.context.sources.job_run_id").String()).To(Equal("reverse-etl-1"))
+		Expect(gjson.GetBytes(payload, "batch.0.context.sources.task_run_id").String()).To(Equal("user_plans"))
+		Expect(gjson.GetBytes(payload, "batch.0.messageId").String()).NotTo(BeEmpty())
+		Expect(gjson.GetBytes(payload, "batch.1.messageId").String()).NotTo(Equal(gjson.GetBytes(payload, "batch.0.messageId").String()))
+
+		again, err := reverseETLBatches("test-destinationID", model, "reverse-etl-2", rows)
+		Expect(err).To(BeNil())
+		Expect(gjson.GetBytes(again[0], "batch.0.messageId").String()).To(Equal(gjson.GetBytes(payload, "batch.0.messageId").String()), "the events of a row sent again should keep their message id")
+		changedRows := []reverseETLRowT{rows[0]}
+		changedRows[0].hash = "changed"
+		changed, err := reverseETLBatches("test-destinationID", model, "reverse-etl-2", changedRows)
+		Expect(err).To(BeNil())
+		Expect(gjson.GetBytes(changed[0], "batch.0.messageId").String()).NotTo(Equal(gjson.GetBytes(payload, "batch.0.messageId").String()), "the events of changed rows should get a new message id")
+
+		Expect(gjson.GetBytes(batches[1], "batch.0.anonymousId").String()).To(Equal("user-3"), "rows without user id are sent with their primary key as anonymous id")
+
+		trackModel := model
+		trackModel.EventType, trackModel.EventName = ReverseETLTrackEvent, "Plan Changed"
+		batches, err = reverseETLBatches("test-destinationID", trackModel, "reverse-etl-1", rows[:1])
+		Expect(err).To(BeNil())
+		Expect(gjson.GetBytes(batches[0], "batch.0.event").String()).To(Equal("Plan Changed"))
+		Expect(gjson.GetBytes(batches[0], "batch.0.properties.plan").String()).To(Equal("pro"))
+	})
+
+	It("Should send the batches to the batch endpoint of the gateway", func() {
+		var (
+			path, writeKey string
+			body           []byte
+			status         = http.StatusOK
+		)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			path = r.URL.Path
+			writeKey, _, _ = r.BasicAuth()
+			body, _ = io.ReadAll(r.Body)
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte("Invalid Write Key\n"))
+		}))
+		DeferCleanup(server.Close)
+		gatewayURL := reverseETLGatewayURL
+		DeferCleanup(func() {
+			reverseETLGatewayURL = gatewayURL
+		})
+		reverseETLGatewayURL = server.URL + "/"
+
+		gateway := &reverseETLGatewayT{client: server.Client()}
+		Expect(gateway.Send(context.Background(), "test-writeKey", []byte(`{"batch":[]}`))).To(BeNil())
+		Expect(path).To(Equal("/v1/batch"))
+		Expect(writeKey).To(Equal("test-writeKey"))
+		Expect(string(body)).To(Equal(`{"batch":[]}`))
+
+		status = http.StatusUnauthorized
+		Expect(gateway.Send(context.Background(), "test-writeKey", []byte(`{"batch":[]}`))).To(MatchError("gateway responded with status 401: Invalid Write Key"))
+	})
+
+	Describe("Reverse ETL runs", Ordered, func() {
+		var (
+			pgResource *destination.PostgresResource
+			cleanup    = &testhelper.Cleanup{}
+			rh         *reverseETLHandleT
+			gateway    *reverseETLGateway
+			warehouse  = warehouseutils.Warehouse{
+				Destination: backendconfig.DestinationT{
+					ID:          "test-destinationID",
+					WorkspaceID: "test-workspaceID",
+				},
+				Type: "POSTGRES",
+			}
+		)
+
+		BeforeAll(func() {
+			pool, err := dockertest.NewPool("")
+			Expect(err).To(BeNil())
+
+			pgResource = setupWarehouseJobs(pool, GinkgoT(), cleanup)
+
+			initWarehouse()
+
+			err = setupDB(context.TODO(), getConnectionString())
+			Expect(err).To(BeNil())
+
+			_, err = pgResource.DB.Exec(`CREATE TABLE users (user_id TEXT, plan TEXT); INSERT INTO users VALUES ('user-1', 'pro'), ('user-2', 'free');`)
+			Expect(err).To(BeNil())
+
+			pkgLogger = logger.NOP
+			gateway = &reverseETLGateway{}
+			rh = &reverseETLHandleT{
+				dbHandle: pgResource.DB,
+				gateway:  gateway,
+				newManager: func(string) (manager.ManagerI, error) {
+					return &reverseETLManager{dsn: pgResource.DBDsn}, nil
+				},
+				sources: map[string]backendconfig.SourceT{source.ID: source},
+			}
+		})
+
+		AfterAll(func() {
+			cleanup.Run()
+		})
+
+		It("Should send the rows of the first sync", func() {
+			due, err := rh.isSyncDue(warehouse, model)
+			Expect(err).To(BeNil())
+			Expect(due).To(BeTrue())
+
+			Expect(rh.syncModel(context.Background(), warehouse, model)).To(BeNil())
+			Expect(gateway.batches).To(HaveLen(1))
+			Expect(gateway.writeKeys).To(Equal([]string{"test-writeKey"}))
+			Expect(gjson.GetBytes(gateway.batches[0], "batch.#").Int()).To(Equal(int64(2)))
+
+			var status string
+			var rowsQueried, rowsChanged int64
+			err = pgResource.DB.QueryRow(`SELECT status, rows_queried, rows_changed FROM wh_reverse_etl_runs WHERE model_name = 'user_plans'`).Scan(&status, &rowsQueried, &rowsChanged)
+			Expect(err).To(BeNil())
+			Expect(status).To(Equal(ReverseETLRunSucceeded))
+			Expect(rowsQueried).To(Equal(int64(2)))
+			Expect(rowsChanged).To(Equal(int64(2)))
+
+			due, err = rh.isSyncDue(warehouse, model)
+			Expect(err).To(BeNil())
+			Expect(due).To(BeFalse())
+		})
+
+		It("Should only send the changed rows", func() {
+			gateway.batches = nil
+			_, err := pgResource.DB.Exec(`UPDATE users SET plan = 'enterprise' WHERE user_id = 'user-2'; DELETE FROM users WHERE user_id = 'user-1';`)
+			Expect(err).To(BeNil())
+
+			runStats, err := rh.runModel(context.Background(), warehouse, model, source, "reverse-etl-2")
+			Expect(err).To(BeNil())
+			Expect(runStats).To(Equal(reverseETLRunStatsT{rowsQueried: 1, rowsChanged: 1, rowsRemoved: 1}))
+			Expect(gateway.batches).To(HaveLen(1))
+			Expect(gjson.GetBytes(gateway.batches[0], "batch.0.userId").String()).To(Equal("user-2"))
+			Expect(gjson.GetBytes(gateway.batches[0], "batch.0.traits.plan").String()).To(Equal("enterprise"))
+
+			gateway.batches = nil
+			runStats, err = rh.runModel(context.Background(), warehouse, model, source, "reverse-etl-3")
+			Expect(err).To(BeNil())
+			Expect(runStats).To(Equal(reverseETLRunStatsT{rowsQueried: 1}))
+			Expect(gateway.batches).To(BeEmpty())
+		})
+
+		It("Should record a failed run for unknown sources", func() {
+			unknownSourceModel := model
+			unknownSourceModel.Name, unknownSourceModel.SourceID = "unknown_source", "test-unknown-sourceID"
+			Expect(rh.syncModel(context.Background(), warehouse, unknownSourceModel)).To(BeNil())
+
+			var status, runError string
+			err := pgResource.DB.QueryRow(`SELECT status, error FROM wh_reverse_etl_runs WHERE model_name = 'unknown_source'`).Scan(&status, &runError)
+			Expect(err).To(BeNil())
+			Expect(status).To(Equal(ReverseETLRunFailed))
+			Expect(runError).To(Equal("source test-unknown-sourceID does not exist"))
+		})
+
+		It("Should abort executing runs", func() {
+			abortedModel := model
+			abortedModel.Name = "aborted"
+			_, err := rh.startReverseETLRun(warehouse, abortedModel)
+			Expect(err).To(BeNil())
+			Expect(rh.abortReverseETLRuns()).To(BeNil())
+
+			due, err := rh.isSyncDue(warehouse, abortedModel)
+			Expect(err).To(BeNil())
+			Expect(due).To(BeTrue())
+		})
+	})
+})
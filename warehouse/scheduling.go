@@ -20,10 +20,13 @@ var (
 	maxUploadBackoff        time.Duration
 	startUploadAlways       bool
 	scheduledTimesCacheLock sync.RWMutex
+	cronSchedulesCache      map[string]*timeutil.CronSchedule
+	cronSchedulesCacheLock  sync.RWMutex
 )
 
 func Init3() {
 	scheduledTimesCache = map[string][]int{}
+	cronSchedulesCache = map[string]*timeutil.CronSchedule{}
 	loadConfigScheduling()
 }
 
@@ -104,6 +107,118 @@ func GetPrevScheduledTime(syncFrequency, syncStartAt string, currTime time.Time)
 	return timeutil.StartOfDay(now).Add(time.Minute * time.Duration(allStartTimes[pos]))
 }
 
+// GetSyncLocation returns the location of the timezone the schedule of a warehouse is evaluated in, UTC by default
+func GetSyncLocation(syncTimezone string) (*time.Location, error) {
+	if syncTimezone == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(syncTimezone)
+}
+
+func getCronSchedule(syncCron string) (*timeutil.CronSchedule, error) {
+	cronSchedulesCacheLock.RLock()
+	schedule, ok := cronSchedulesCache[syncCron]
+	cronSchedulesCacheLock.RUnlock()
+	if ok {
+		return schedule, nil
+	}
+	schedule, err := timeutil.ParseCron(syncCron)
+	if err != nil {
+		return nil, err
+	}
+	cronSchedulesCacheLock.Lock()
+	cronSchedulesCache[syncCron] = schedule
+	cronSchedulesCacheLock.Unlock()
+	return schedule, nil
+}
+
+// GetPrevCronScheduledTime returns closest previous time scheduled by a cron expression, evaluated in the location of the current time
+// e.g. Syncing at `0 9-17 * * 1-5` in Asia/Kolkata, prev scheduled time for saturday 10:00 IST is friday 17:00 IST
+func GetPrevCronScheduledTime(syncCron string, currTime time.Time) (time.Time, error) {
+	schedule, err := getCronSchedule(syncCron)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return schedule.Prev(currTime), nil
+}
+
+// getConfigValueAsInt returns a numeric config of the destination, which can be set as a number or a string
+func getConfigValueAsInt(key string, warehouse warehouseutils.Warehouse) int64 {
+	switch val := warehouse.Destination.Config[key].(type) {
+	case float64:
+		return int64(val)
+	case string:
+		intVal, _ := strconv.ParseInt(val, 10, 64)
+		return intVal
+	}
+	return 0
+}
+
+// IsStagingFilesFreshnessExceeded indicates if the pending staging files have more events than maxPendingEvents or
+// if the oldest of them was created more than maxStagingFileAge ago. Limits not greater than zero are not checked.
+func IsStagingFilesFreshnessExceeded(pendingEvents int64, oldestCreatedAt time.Time, maxPendingEvents int64, maxStagingFileAge time.Duration, currTime time.Time) bool {
+	if maxPendingEvents > 0 && pendingEvents >= maxPendingEvents {
+		return true
+	}
+	if maxStagingFileAge > 0 && !oldestCreatedAt.IsZero() && currTime.Sub(oldestCreatedAt) >= maxStagingFileAge {
+		return true
+	}
+	return false
+}
+
+// getPendingStagingFilesFreshness returns the number of events in the staging files not yet picked by an upload, and the creation time of the oldest of them
+func (wh *HandleT) getPendingStagingFilesFreshness(warehouse warehouseutils.Warehouse) (pendingEvents int64, oldestCreatedAt time.Time, err error) {
+	sqlStatement := fmt.Sprintf(`
+		SELECT 
+		  COALESCE(SUM(total_events), 0), 
+		  MIN(created_at) 
+		FROM 
+		  %[1]s ST 
+		WHERE 
+		  ST.id > COALESCE(
+		    (
+		      SELECT 
+		        end_staging_file_id 
+		      FROM 
+		        %[2]s UT 
+		      WHERE 
+		        UT.source_id = $1 
+		        AND UT.destination_id = $2 
+		      ORDER BY 
+		        UT.id DESC 
+		      LIMIT 
+		        1
+		    ), 0
+		  ) 
+		  AND ST.source_id = $1 
+		  AND ST.destination_id = $2;
+`,
+		warehouseutils.WarehouseStagingFilesTable,
+		warehouseutils.WarehouseUploadsTable,
+	)
+	var oldest sql.NullTime
+	err = wh.dbHandle.QueryRow(sqlStatement, warehouse.Source.ID, warehouse.Destination.ID).Scan(&pendingEvents, &oldest)
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("query: %s failed with error: %w", sqlStatement, err)
+	}
+	return pendingEvents, oldest.Time, nil
+}
+
+// isFreshnessTriggered indicates if the staging files of the warehouse waited long enough, or are big enough, to upload them ahead of the schedule
+func (wh *HandleT) isFreshnessTriggered(warehouse warehouseutils.Warehouse) bool {
+	maxPendingEvents := getConfigValueAsInt(warehouseutils.SyncMaxPendingEvents, warehouse)
+	maxStagingFileAge := time.Duration(getConfigValueAsInt(warehouseutils.SyncMaxStagingFileAge, warehouse)) * time.Minute
+	if maxPendingEvents <= 0 && maxStagingFileAge <= 0 {
+		return false
+	}
+	pendingEvents, oldestCreatedAt, err := wh.getPendingStagingFilesFreshness(warehouse)
+	if err != nil {
+		pkgLogger.Errorf("[WH]: Failed to get freshness of pending staging files for %s: %v", warehouse.Identifier, err)
+		return false
+	}
+	return IsStagingFilesFreshnessExceeded(pendingEvents, oldestCreatedAt, maxPendingEvents, maxStagingFileAge, timeutil.Now())
+}
+
 // getLastUploadCreatedAt returns the start time of the last upload
 func (wh *HandleT) getLastUploadCreatedAt(warehouse warehouseutils.Warehouse) time.Time {
 	var t sql.NullTime
@@ -180,12 +295,30 @@ func (wh *HandleT) canCreateUpload(warehouse warehouseutils.Warehouse) bool {
 	if warehouseSyncFreqIgnore {
 		return !uploadFrequencyExceeded(warehouse, "")
 	}
+	// the cron schedule and the exclude window are evaluated in the timezone of the warehouse
+	syncLocation, err := GetSyncLocation(warehouseutils.GetConfigValue(warehouseutils.SyncTimezone, warehouse))
+	if err != nil {
+		pkgLogger.Errorf("[WH]: Invalid sync timezone for %s, using UTC: %v", warehouse.Identifier, err)
+		syncLocation = time.UTC
+	}
+	now := timeutil.Now().In(syncLocation)
 	// gets exclude window start time and end time
 	excludeWindow := warehouseutils.GetConfigValueAsMap(warehouseutils.ExcludeWindow, warehouse.Destination.Config)
 	excludeWindowStartTime, excludeWindowEndTime := GetExcludeWindowStartEndTimes(excludeWindow)
-	if CheckCurrentTimeExistsInExcludeWindow(timeutil.Now(), excludeWindowStartTime, excludeWindowEndTime) {
+	if CheckCurrentTimeExistsInExcludeWindow(now, excludeWindowStartTime, excludeWindowEndTime) {
 		return false
 	}
+	// staging files piling up or waiting for too long are uploaded without waiting for the schedule
+	if wh.isFreshnessTriggered(warehouse) {
+		return true
+	}
+	if syncCron := warehouseutils.GetConfigValue(warehouseutils.SyncCron, warehouse); syncCron != "" {
+		prevScheduledTime, err := GetPrevCronScheduledTime(syncCron, now)
+		if err == nil {
+			return wh.getLastUploadCreatedAt(warehouse).Before(prevScheduledTime)
+		}
+		pkgLogger.Errorf("[WH]: Invalid sync cron for %s, using sync frequency: %v", warehouse.Identifier, err)
+	}
 	syncFrequency := warehouseutils.GetConfigValue(warehouseutils.SyncFrequency, warehouse)
 	syncStartAt := warehouseutils.GetConfigValue(warehouseutils.SyncStartAt, warehouse)
 	if syncFrequency == "" || syncStartAt == "" {
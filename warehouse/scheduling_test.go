@@ -136,4 +136,56 @@ var _ = Describe("Scheduling", func() {
 		Entry(nil, 1, time.Second*60),
 		Entry(nil, 2, time.Second*120),
 	)
+
+	Describe("GetPrevCronScheduledTime", func() {
+		It("should return prev scheduled time in the timezone of the current time", func() {
+			loc, err := GetSyncLocation("Asia/Kolkata")
+			Expect(err).NotTo(HaveOccurred())
+
+			// saturday 10:00 IST
+			now := time.Date(2022, 0o6, 18, 10, 0, 0, 0, loc)
+			sTime, err := GetPrevCronScheduledTime("0 9-17 * * 1-5", now)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sTime).To(BeTemporally("==", time.Date(2022, 0o6, 17, 17, 0, 0, 0, loc)))
+
+			// 02:00 IST is 20:30 UTC of the previous day, 21:00 UTC is 02:30 IST
+			sTime, err = GetPrevCronScheduledTime("0 2 * * *", time.Date(2022, 0o6, 17, 21, 0, 0, 0, time.UTC).In(loc))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sTime).To(BeTemporally("==", time.Date(2022, 0o6, 17, 20, 30, 0, 0, time.UTC)))
+		})
+
+		It("should return error for invalid cron expressions", func() {
+			_, err := GetPrevCronScheduledTime("0 25 * * *", time.Now())
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	DescribeTable("GetSyncLocation", func(syncTimezone string, expected string, expectedErr bool) {
+		loc, err := GetSyncLocation(syncTimezone)
+		if expectedErr {
+			Expect(err).To(HaveOccurred())
+			return
+		}
+		Expect(err).NotTo(HaveOccurred())
+		Expect(loc.String()).To(Equal(expected))
+	},
+		Entry("Not set", "", "UTC", false),
+		Entry("IANA timezone", "America/New_York", "America/New_York", false),
+		Entry("Invalid timezone", "Mars/Olympus_Mons", "", true),
+	)
+
+	DescribeTable("IsStagingFilesFreshnessExceeded", func(pendingEvents int64, oldestAge time.Duration, maxPendingEvents int64, maxStagingFileAge time.Duration, expected bool) {
+		now := time.Date(2022, 0o6, 17, 12, 0, 0, 0, time.UTC)
+		var oldestCreatedAt time.Time
+		if oldestAge > 0 {
+			oldestCreatedAt = now.Add(-oldestAge)
+		}
+		Expect(IsStagingFilesFreshnessExceeded(pendingEvents, oldestCreatedAt, maxPendingEvents, maxStagingFileAge, now)).To(Equal(expected))
+	},
+		Entry("Not configured", int64(1000), time.Hour, int64(0), time.Duration(0), false),
+		Entry("Events below limit", int64(99), time.Minute, int64(100), time.Hour, false),
+		Entry("Events at limit", int64(100), time.Minute, int64(100), time.Hour, true),
+		Entry("Oldest staging file too old", int64(1), 2*time.Hour, int64(100), time.Hour, true),
+		Entry("No pending staging files", int64(0), time.Duration(0), int64(100), time.Hour, false),
+	)
 })
@@ -59,6 +59,38 @@ func HandleSchemaChange(existingDataType, columnType string, columnVal interface
 	return newColumnVal, true
 }
 
+// columnTypeWidenings maps the types of columns to the types they can be widened to, i.e. the types able to hold
+// all the values of the column. The values are converted by HandleSchemaChange once a column has been widened.
+var columnTypeWidenings = map[string][]string{
+	"int":      {"float", "string", "text"},
+	"float":    {"string", "text"},
+	"boolean":  {"string", "text"},
+	"datetime": {"string", "text"},
+	"string":   {"text"},
+}
+
+// isColumnTypeWidening returns true if a column of existingType can be widened to newType
+func isColumnTypeWidening(existingType, newType string) bool {
+	return misc.Contains(columnTypeWidenings[existingType], newType)
+}
+
+// columnTypeEvolutionEnabled returns true if the type of columns are widened in warehouses of warehouseType
+func columnTypeEvolutionEnabled(warehouseType string) bool {
+	return enableColumnTypeEvolution && misc.Contains(warehouseutils.ColumnTypeEvolutionWarehouses, warehouseType)
+}
+
+// widenedColumnType returns the type a column of existingType should have to also store values of columnType.
+// Strings are always widened to text, other types only if column type evolution is enabled for the warehouse.
+func widenedColumnType(warehouseType, existingType, columnType string) string {
+	if existingType == "string" && columnType == "text" {
+		return columnType
+	}
+	if columnTypeEvolutionEnabled(warehouseType) && isColumnTypeWidening(existingType, columnType) {
+		return columnType
+	}
+	return existingType
+}
+
 func (sh *SchemaHandleT) getLocalSchema() (currentSchema warehouseutils.SchemaT) {
 	sourceID := sh.warehouse.Source.ID
 	destID := sh.warehouse.Destination.ID
@@ -178,11 +210,12 @@ func mergeSchema(currentSchema warehouseutils.SchemaT, schemaList []warehouseuti
 		if !ok {
 			return false
 		}
-		if columnTypeInDB == "string" && columnType == "text" {
-			currentMergedSchema[tableName][columnName] = columnType
-			return true
+		mergedType := widenedColumnType(warehouseType, columnTypeInDB, columnType)
+		// keep the type if it has been widened further by previous schemas
+		if currentType, ok := currentMergedSchema[tableName][columnName]; ok && isColumnTypeWidening(mergedType, currentType) {
+			mergedType = currentType
 		}
-		currentMergedSchema[tableName][columnName] = columnTypeInDB
+		currentMergedSchema[tableName][columnName] = mergedType
 		return true
 	}
 
@@ -225,7 +258,9 @@ func mergeSchema(currentSchema warehouseutils.SchemaT, schemaList []warehouseuti
 					}
 				}
 				// check if we already set the columnType in currentMergedSchema
-				if _, ok := currentMergedSchema[tableName][columnName]; !ok {
+				if currentType, ok := currentMergedSchema[tableName][columnName]; !ok {
+					currentMergedSchema[tableName][columnName] = columnType
+				} else if columnTypeEvolutionEnabled(warehouseType) && isColumnTypeWidening(currentType, columnType) {
 					currentMergedSchema[tableName][columnName] = columnType
 				}
 			}
@@ -400,7 +435,16 @@ func getTableSchemaDiff(tableName string, currentSchema, uploadSchema warehouseu
 
 	diff.ColumnMap = make(map[string]string)
 	for columnName, columnType := range uploadSchema[tableName] {
-		if _, ok := currentTableSchema[columnName]; !ok {
+		if _, ok := currentTableSchema[columnName]; !ok && hasBackupColumn(currentTableSchema, columnName) {
+			// a change of the type of the column was interrupted after the column was renamed to its backup,
+			// altering it again recovers the values of the backup instead of adding an empty column
+			if diff.AlteredColumnMap == nil {
+				diff.AlteredColumnMap = make(map[string]string)
+			}
+			diff.AlteredColumnMap[columnName] = columnType
+			diff.UpdatedSchema[columnName] = columnType
+			diff.Exists = true
+		} else if !ok {
 			diff.ColumnMap[columnName] = columnType
 			diff.UpdatedSchema[columnName] = columnType
 			diff.Exists = true
@@ -408,11 +452,29 @@ func getTableSchemaDiff(tableName string, currentSchema, uploadSchema warehouseu
 			diff.StringColumnsToBeAlteredToText = append(diff.StringColumnsToBeAlteredToText, columnName)
 			diff.UpdatedSchema[columnName] = columnType
 			diff.Exists = true
+		} else if isColumnTypeWidening(currentTableSchema[columnName], columnType) {
+			// upload schema only contains widened types if column type evolution is enabled for the warehouse
+			if diff.AlteredColumnMap == nil {
+				diff.AlteredColumnMap = make(map[string]string)
+			}
+			diff.AlteredColumnMap[columnName] = columnType
+			diff.UpdatedSchema[columnName] = columnType
+			diff.Exists = true
 		}
 	}
 	return diff
 }
 
+// hasBackupColumn returns whether the table has the backup column of a column whose type is being changed,
+// the suffix being upper cased in snowflake as the rest of its column names
+func hasBackupColumn(tableSchema map[string]string, columnName string) bool {
+	if _, ok := tableSchema[columnName+warehouseutils.BackupColumnSuffix]; ok {
+		return true
+	}
+	_, ok := tableSchema[columnName+warehouseutils.ToProviderCase(warehouseutils.SNOWFLAKE, warehouseutils.BackupColumnSuffix)]
+	return ok
+}
+
 // returns the merged schema(uploadSchema+schemaInWarehousePreUpload) for all tables in uploadSchema
 func mergeUploadAndLocalSchemas(uploadSchema, schemaInWarehousePreUpload warehouseutils.SchemaT) warehouseutils.SchemaT {
 	mergedSchema := warehouseutils.SchemaT{}
@@ -443,8 +505,8 @@ func mergeUploadAndLocalSchemas(uploadSchema, schemaInWarehousePreUpload warehou
 				mergedSchema[uploadTableName][uploadColName] = uploadColType
 				continue
 			}
-			// change type of uploadCol to text if it was string in localSchema
-			if uploadColType == "text" && localColType == "string" {
+			// change type of uploadCol if it has been widened from the type in localSchema, e.g. from string to text
+			if isColumnTypeWidening(localColType, uploadColType) {
 				mergedSchema[uploadTableName][uploadColName] = uploadColType
 			}
 		}
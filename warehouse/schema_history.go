@@ -0,0 +1,414 @@
+package warehouse
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	proto "github.com/rudderlabs/rudder-server/proto/warehouse"
+	"github.com/rudderlabs/rudder-server/rruntime"
+	"github.com/rudderlabs/rudder-server/utils/misc"
+	"github.com/rudderlabs/rudder-server/utils/timeutil"
+	warehouseutils "github.com/rudderlabs/rudder-server/warehouse/utils"
+)
+
+// types of the changes recorded in the schema history
+const (
+	SchemaChangeCreateTable = "create_table"
+	SchemaChangeAddColumn   = "add_column"
+	SchemaChangeAlterColumn = "alter_column"
+)
+
+// maxColumnLineageChanges bounds the changes returned in the lineage of a column
+const maxColumnLineageChanges = 1000
+
+// SchemaChangeT is a change of a column of a table in the warehouse, made by an upload.
+// Columns of created tables are recorded as individual changes, so that every column has a lineage.
+type SchemaChangeT struct {
+	ID              int64
+	WorkspaceID     string
+	SourceID        string
+	DestinationID   string
+	DestinationType string
+	Namespace       string
+	UploadID        int64
+	TableName       string
+	ColumnName      string
+	ChangeType      string
+	FromType        string
+	ToType          string
+	// StagingFileID is the first staging file of the upload having the column
+	StagingFileID int64
+	// EventSample is the redacted value of the column in the first row of the staging file having the column
+	EventSample json.RawMessage
+	CreatedAt   time.Time
+}
+
+func (change SchemaChangeT) proto() *proto.WHSchemaChange {
+	return &proto.WHSchemaChange{
+		Id:              change.ID,
+		UploadId:        change.UploadID,
+		SourceId:        change.SourceID,
+		DestinationId:   change.DestinationID,
+		DestinationType: change.DestinationType,
+		Namespace:       change.Namespace,
+		TableName:       change.TableName,
+		ColumnName:      change.ColumnName,
+		ChangeType:      change.ChangeType,
+		FromType:        change.FromType,
+		ToType:          change.ToType,
+		StagingFileId:   change.StagingFileID,
+		EventSample:     string(change.EventSample),
+		CreatedAt:       timestamppb.New(change.CreatedAt),
+	}
+}
+
+// schemaChange returns a change of the column made by the upload
+func (job *UploadJobT) schemaChange(tName, columnName, changeType, fromType, toType string) SchemaChangeT {
+	return SchemaChangeT{
+		WorkspaceID:     job.upload.WorkspaceID,
+		SourceID:        job.upload.SourceID,
+		DestinationID:   job.upload.DestinationID,
+		DestinationType: job.upload.DestinationType,
+		Namespace:       job.warehouse.Namespace,
+		UploadID:        job.upload.ID,
+		TableName:       tName,
+		ColumnName:      columnName,
+		ChangeType:      changeType,
+		FromType:        fromType,
+		ToType:          toType,
+		CreatedAt:       timeutil.Now(),
+	}
+}
+
+// schemaChangeSamplers bounds the staging files downloaded at once to sample the schema changes
+var schemaChangeSamplers = make(chan struct{}, 1)
+
+// recordSchemaChanges adds the changes to the schema history along with the staging files the columns were first seen in.
+// Event samples are taken afterwards in the background, so that staging files are not downloaded on the export path.
+// Failing to record the history does not fail the upload.
+func (job *UploadJobT) recordSchemaChanges(tName string, changes []SchemaChangeT) {
+	if !enableSchemaHistory || len(changes) == 0 {
+		return
+	}
+	if err := job.attributeSchemaChanges(tName, changes); err != nil {
+		pkgLogger.Errorf("[WH]: Failed to find the staging files of the schema changes of table %s.%s for upload %d: %v", job.warehouse.Namespace, tName, job.upload.ID, err)
+	}
+	if err := NewWarehouseDB(job.dbHandle).InsertSchemaChanges(context.TODO(), changes); err != nil {
+		pkgLogger.Errorf("[WH]: Failed to record the schema changes of table %s.%s for upload %d: %v", job.warehouse.Namespace, tName, job.upload.ID, err)
+		return
+	}
+	if !captureSchemaChangeEventSamples {
+		return
+	}
+	select {
+	case schemaChangeSamplers <- struct{}{}:
+		rruntime.GoForWarehouse(func() {
+			defer func() { <-schemaChangeSamplers }()
+			job.sampleSchemaChanges(tName, changes)
+		})
+	default:
+		pkgLogger.Debugf("[WH]: Skipping the event samples of the schema changes of table %s.%s for upload %d, as other changes are being sampled", job.warehouse.Namespace, tName, job.upload.ID)
+	}
+}
+
+// attributeSchemaChanges sets the first staging file of the upload having the column of every change
+func (job *UploadJobT) attributeSchemaChanges(tName string, changes []SchemaChangeT) error {
+	columns := make([]string, 0, len(changes))
+	for _, change := range changes {
+		columns = append(columns, change.ColumnName)
+	}
+	sqlStatement := fmt.Sprintf(`
+		SELECT
+		  C.column_name,
+		  (
+			SELECT
+			  ST.id
+			FROM
+			  %[1]s ST
+			WHERE
+			  ST.id = ANY($1)
+			  AND ST.schema -> $2 ? C.column_name
+			ORDER BY
+			  ST.id ASC
+			LIMIT
+			  1
+		  )
+		FROM
+		  UNNEST($3 :: text[]) AS C(column_name);
+`,
+		warehouseutils.WarehouseStagingFilesTable,
+	)
+	rows, err := job.dbHandle.Query(sqlStatement, pq.Array(job.stagingFileIDs), tName, pq.Array(columns))
+	if err != nil {
+		return fmt.Errorf("query: %s failed with Error : %w", sqlStatement, err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	stagingFileIDs := make(map[string]int64, len(columns))
+	for rows.Next() {
+		var (
+			columnName    string
+			stagingFileID sql.NullInt64
+		)
+		if err := rows.Scan(&columnName, &stagingFileID); err != nil {
+			return err
+		}
+		stagingFileIDs[columnName] = stagingFileID.Int64
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i := range changes {
+		changes[i].StagingFileID = stagingFileIDs[changes[i].ColumnName]
+	}
+	return nil
+}
+
+// sampleSchemaChanges records the redacted value of the column of every change in the first row of its staging file having the column
+func (job *UploadJobT) sampleSchemaChanges(tName string, changes []SchemaChangeT) {
+	// columns to sample grouped by staging file, in the order of the staging files
+	columnsByStagingFile := make(map[int64][]string)
+	for _, change := range changes {
+		if change.StagingFileID != 0 {
+			columnsByStagingFile[change.StagingFileID] = append(columnsByStagingFile[change.StagingFileID], change.ColumnName)
+		}
+	}
+
+	samples := make(map[string]json.RawMessage, len(changes))
+	sampledStagingFiles := 0
+	for _, stagingFile := range job.stagingFiles {
+		stagingFileColumns, ok := columnsByStagingFile[stagingFile.ID]
+		if !ok {
+			continue
+		}
+		if sampledStagingFiles >= maxStagingFilesToSampleForSchemaChanges {
+			break
+		}
+		sampledStagingFiles++
+		if err := job.sampleStagingFile(stagingFile, tName, stagingFileColumns, samples); err != nil {
+			pkgLogger.Errorf("[WH]: Failed to sample staging file %d for the schema changes of table %s.%s: %v", stagingFile.ID, job.warehouse.Namespace, tName, err)
+		}
+	}
+	if len(samples) == 0 {
+		return
+	}
+	if err := NewWarehouseDB(job.dbHandle).SetSchemaChangeEventSamples(context.TODO(), job.upload.ID, tName, samples); err != nil {
+		pkgLogger.Errorf("[WH]: Failed to record the event samples of the schema changes of table %s.%s for upload %d: %v", job.warehouse.Namespace, tName, job.upload.ID, err)
+	}
+}
+
+// sampleStagingFile sets the redacted value of each of the columns in the first row of the table in the staging file having the column as their sample
+func (job *UploadJobT) sampleStagingFile(stagingFile *StagingFileT, tName string, columns []string, samples map[string]json.RawMessage) error {
+	remaining := len(columns)
+	var sampleErr error
+	err := readStagingFile(job.warehouse, stagingFile, func(event *BatchRouterEventT) bool {
+		if event.Metadata.Table != tName {
+			return true
+		}
+		for _, columnName := range columns {
+			if _, ok := samples[columnName]; ok || event.Data[columnName] == nil {
+				continue
+			}
+			var sample json.RawMessage
+			if sample, sampleErr = json.Marshal(map[string]interface{}{columnName: redactedSampleValue(event.Data[columnName])}); sampleErr != nil {
+				return false
+			}
+			samples[columnName] = sample
+			remaining--
+		}
+		return remaining > 0
+	})
+	if err != nil {
+		return err
+	}
+	return sampleErr
+}
+
+// redactedSampleValue returns the shape of the value of a column without its content, as events may hold personal data,
+// e.g. "<redacted string of 12 characters>"
+func redactedSampleValue(val interface{}) string {
+	switch v := val.(type) {
+	case string:
+		return fmt.Sprintf("<redacted string of %d characters>", len([]rune(v)))
+	case bool:
+		return "<redacted boolean>"
+	case float64, int, int64, json.Number:
+		return "<redacted number>"
+	case []interface{}:
+		return fmt.Sprintf("<redacted array of %d elements>", len(v))
+	case map[string]interface{}:
+		return fmt.Sprintf("<redacted object of %d keys>", len(v))
+	default:
+		return "<redacted>"
+	}
+}
+
+// runSchemaHistoryRetention clears the event samples of the schema history older than the configured retention
+func runSchemaHistoryRetention(ctx context.Context, dbHandle *sql.DB) {
+	for {
+		select {
+		case <-ctx.Done():
+			pkgLogger.Infof("context is cancelled, stopped clearing the event samples of the schema history")
+			return
+		case <-time.After(schemaHistoryRetentionTickerTime):
+			if !enableSchemaHistory {
+				continue
+			}
+			cleared, err := NewWarehouseDB(dbHandle).ClearSchemaChangeEventSamples(ctx, timeutil.Now().Add(-schemaHistoryEventSampleRetention))
+			if err != nil {
+				pkgLogger.Errorf("[WH]: Failed to clear the event samples of the schema history: %v", err)
+				continue
+			}
+			pkgLogger.Debugf("[WH]: Cleared the event samples of %d schema changes", cleared)
+		}
+	}
+}
+
+// SchemaHistoryReqT lists the schema changes of the sources of a workspace
+type SchemaHistoryReqT struct {
+	WorkspaceID   string
+	SourceID      string
+	DestinationID string
+	Namespace     string
+	TableName     string
+	ColumnName    string
+	UploadID      int64
+	Limit         int32
+	Offset        int32
+	API           UploadAPIT
+}
+
+func (historyReq *SchemaHistoryReqT) validateReq() error {
+	if !historyReq.API.enabled || historyReq.API.log == nil || historyReq.API.dbHandle == nil {
+		return errors.New("warehouse api's are not initialized")
+	}
+	if historyReq.WorkspaceID == "" {
+		return errors.New("workspace_id is empty")
+	}
+	if historyReq.Limit < 1 {
+		historyReq.Limit = 10
+	}
+	if historyReq.Offset < 0 {
+		historyReq.Offset = 0
+	}
+	return nil
+}
+
+func (historyReq *SchemaHistoryReqT) clausesQuery(sourceIDs []string) []FilterClause {
+	clauses := []FilterClause{{
+		Clause:    fmt.Sprintf(`source_id = ANY(%s)`, queryPlaceHolder),
+		ClauseArg: pq.Array(sourceIDs),
+	}}
+	optionalClauses := []struct {
+		column string
+		value  interface{}
+		isSet  bool
+	}{
+		{column: "source_id", value: historyReq.SourceID, isSet: historyReq.SourceID != ""},
+		{column: "destination_id", value: historyReq.DestinationID, isSet: historyReq.DestinationID != ""},
+		{column: "namespace", value: historyReq.Namespace, isSet: historyReq.Namespace != ""},
+		{column: "table_name", value: historyReq.TableName, isSet: historyReq.TableName != ""},
+		{column: "column_name", value: historyReq.ColumnName, isSet: historyReq.ColumnName != ""},
+		{column: "upload_id", value: historyReq.UploadID, isSet: historyReq.UploadID != 0},
+	}
+	for _, clause := range optionalClauses {
+		if clause.isSet {
+			clauses = append(clauses, FilterClause{
+				Clause:    fmt.Sprintf(`%s = %s`, clause.column, queryPlaceHolder),
+				ClauseArg: clause.value,
+			})
+		}
+	}
+	return clauses
+}
+
+// authorizedSources returns the sources of the workspace the history can be listed for
+func (historyReq *SchemaHistoryReqT) authorizedSources() ([]string, error) {
+	sourceIDs := UploadsReqT{WorkspaceID: historyReq.WorkspaceID}.authorizedSources()
+	if len(sourceIDs) == 0 {
+		return nil, errors.New("unauthorized request")
+	}
+	if historyReq.SourceID != "" && !misc.Contains(sourceIDs, historyReq.SourceID) {
+		return nil, errors.New("no such sourceID exists")
+	}
+	return sourceIDs, nil
+}
+
+// GetSchemaHistory returns the schema changes matching the request, the latest first
+func (historyReq *SchemaHistoryReqT) GetSchemaHistory(ctx context.Context) (*proto.WHSchemaHistoryResponse, error) {
+	if err := historyReq.validateReq(); err != nil {
+		return &proto.WHSchemaHistoryResponse{}, err
+	}
+	sourceIDs, err := historyReq.authorizedSources()
+	if err != nil {
+		return &proto.WHSchemaHistoryResponse{}, err
+	}
+
+	changes, total, err := historyReq.API.warehouseDBHandle.GetSchemaChanges(ctx, false, historyReq.Limit, historyReq.Offset, historyReq.clausesQuery(sourceIDs)...)
+	if err != nil {
+		historyReq.API.log.Errorf("WH: Error getting schema history for workspace %s: %v", historyReq.WorkspaceID, err)
+		return &proto.WHSchemaHistoryResponse{}, err
+	}
+	response := &proto.WHSchemaHistoryResponse{
+		Changes: make([]*proto.WHSchemaChange, 0, len(changes)),
+		Pagination: &proto.Pagination{
+			Limit:  historyReq.Limit,
+			Offset: historyReq.Offset,
+			Total:  int32(total),
+		},
+	}
+	for _, change := range changes {
+		response.Changes = append(response.Changes, change.proto())
+	}
+	return response, nil
+}
+
+// GetColumnLineage returns the upload and source which added the column, along with all the changes of the column
+func (historyReq *SchemaHistoryReqT) GetColumnLineage(ctx context.Context) (*proto.WHColumnLineageResponse, error) {
+	if err := historyReq.validateReq(); err != nil {
+		return &proto.WHColumnLineageResponse{}, err
+	}
+	if historyReq.DestinationID == "" || historyReq.Namespace == "" || historyReq.TableName == "" || historyReq.ColumnName == "" {
+		return &proto.WHColumnLineageResponse{}, errors.New("destination_id, namespace, table_name and column_name are required")
+	}
+	sourceIDs, err := historyReq.authorizedSources()
+	if err != nil {
+		return &proto.WHColumnLineageResponse{}, err
+	}
+
+	changes, _, err := historyReq.API.warehouseDBHandle.GetSchemaChanges(ctx, true, maxColumnLineageChanges, 0, historyReq.clausesQuery(sourceIDs)...)
+	if err != nil {
+		historyReq.API.log.Errorf("WH: Error getting lineage of column %s.%s.%s for destination %s: %v", historyReq.Namespace, historyReq.TableName, historyReq.ColumnName, historyReq.DestinationID, err)
+		return &proto.WHColumnLineageResponse{}, err
+	}
+	return columnLineage(historyReq.TableName, historyReq.ColumnName, changes), nil
+}
+
+// columnLineage returns the lineage of a column from its changes, the oldest first
+func columnLineage(tableName, columnName string, changes []SchemaChangeT) *proto.WHColumnLineageResponse {
+	lineage := &proto.WHColumnLineageResponse{
+		TableName:  tableName,
+		ColumnName: columnName,
+		Changes:    make([]*proto.WHSchemaChange, 0, len(changes)),
+		SourceIds:  []string{},
+	}
+	for _, change := range changes {
+		if lineage.Origin == nil && change.ChangeType != SchemaChangeAlterColumn {
+			lineage.Origin = change.proto()
+		}
+		if !misc.Contains(lineage.SourceIds, change.SourceID) {
+			lineage.SourceIds = append(lineage.SourceIds, change.SourceID)
+		}
+		lineage.CurrentType = change.ToType
+		lineage.Changes = append(lineage.Changes, change.proto())
+	}
+	return lineage
+}
@@ -0,0 +1,175 @@
+//go:build !warehouse_integration
+
+package warehouse
+
+import (
+	"context"
+	"os"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/ory/dockertest/v3"
+
+	proto "github.com/rudderlabs/rudder-server/proto/warehouse"
+	"github.com/rudderlabs/rudder-server/testhelper"
+	"github.com/rudderlabs/rudder-server/testhelper/destination"
+	"github.com/rudderlabs/rudder-server/utils/logger"
+	warehouseutils "github.com/rudderlabs/rudder-server/warehouse/utils"
+)
+
+var _ = Describe("SchemaHistory", func() {
+	DescribeTable("Redacted sample value", func(val interface{}, expected string) {
+		Expect(redactedSampleValue(val)).To(Equal(expected))
+	},
+		Entry(nil, "jane@rudderstack.com", "<redacted string of 20 characters>"),
+		Entry(nil, true, "<redacted boolean>"),
+		Entry(nil, 1.5, "<redacted number>"),
+		Entry(nil, []interface{}{"a", "b"}, "<redacted array of 2 elements>"),
+		Entry(nil, map[string]interface{}{"email": "jane@rudderstack.com"}, "<redacted object of 1 keys>"),
+	)
+
+	Describe("Schema history round trip", Ordered, func() {
+		var (
+			pgResource      *destination.PostgresResource
+			cleanup         = &testhelper.Cleanup{}
+			w               *warehouseGRPC
+			sourceID        = "test-sourceID"
+			destinationID   = "test-destinationID"
+			workspaceID     = "test-workspaceID"
+			destinationType = "POSTGRES"
+			namespace       = "test-namespace"
+		)
+
+		newJob := func(uploadID int64) *UploadJobT {
+			return &UploadJobT{
+				upload: &Upload{
+					ID:              uploadID,
+					WorkspaceID:     workspaceID,
+					SourceID:        sourceID,
+					DestinationID:   destinationID,
+					DestinationType: destinationType,
+				},
+				warehouse: warehouseutils.Warehouse{
+					Type:      destinationType,
+					Namespace: namespace,
+				},
+				stagingFileIDs: []int64{1, 2},
+				dbHandle:       pgResource.DB,
+			}
+		}
+
+		BeforeAll(func() {
+			pool, err := dockertest.NewPool("")
+			Expect(err).To(BeNil())
+
+			pgResource = setupWarehouseJobs(pool, GinkgoT(), cleanup)
+
+			initWarehouse()
+
+			err = setupDB(context.TODO(), getConnectionString())
+			Expect(err).To(BeNil())
+
+			sqlStatement, err := os.ReadFile("testdata/sql/6.sql")
+			Expect(err).To(BeNil())
+
+			_, err = pgResource.DB.Exec(string(sqlStatement))
+			Expect(err).To(BeNil())
+
+			pkgLogger = logger.NOP
+			captureSchemaChangeEventSamples = false
+			sourceIDsByWorkspace = map[string][]string{
+				workspaceID: {sourceID},
+			}
+			w = &warehouseGRPC{}
+		})
+
+		AfterAll(func() {
+			cleanup.Run()
+		})
+
+		It("Init warehouse api", func() {
+			Expect(InitWarehouseAPI(pgResource.DB, logger.NOP)).To(BeNil())
+		})
+
+		It("Record schema changes", func() {
+			job := newJob(1)
+			job.recordSchemaChanges("tracks", []SchemaChangeT{
+				job.schemaChange("tracks", "event", SchemaChangeCreateTable, "", "string"),
+				job.schemaChange("tracks", "id", SchemaChangeCreateTable, "", "string"),
+			})
+			job = newJob(2)
+			job.recordSchemaChanges("tracks", []SchemaChangeT{
+				job.schemaChange("tracks", "revenue", SchemaChangeAddColumn, "", "int"),
+			})
+			job.recordSchemaChanges("tracks", []SchemaChangeT{
+				job.schemaChange("tracks", "revenue", SchemaChangeAlterColumn, "int", "float"),
+			})
+		})
+
+		It("Getting schema history", func() {
+			res, err := w.GetWHSchemaHistory(context.TODO(), &proto.WHSchemaHistoryRequest{
+				WorkspaceId: workspaceID,
+				TableName:   "tracks",
+				Limit:       2,
+			})
+			Expect(err).To(BeNil())
+			Expect(res.Pagination.Total).To(BeEquivalentTo(4))
+			Expect(res.Changes).To(HaveLen(2))
+			Expect(res.Changes[0].ChangeType).To(Equal(SchemaChangeAlterColumn))
+			Expect(res.Changes[1].ChangeType).To(Equal(SchemaChangeAddColumn))
+			Expect(res.Changes[1].StagingFileId).To(BeEquivalentTo(2))
+		})
+
+		It("Getting schema history of an upload", func() {
+			res, err := w.GetWHSchemaHistory(context.TODO(), &proto.WHSchemaHistoryRequest{
+				WorkspaceId: workspaceID,
+				UploadId:    1,
+			})
+			Expect(err).To(BeNil())
+			Expect(res.Changes).To(HaveLen(2))
+			for _, change := range res.Changes {
+				Expect(change.ChangeType).To(Equal(SchemaChangeCreateTable))
+				Expect(change.StagingFileId).To(BeEquivalentTo(1))
+			}
+		})
+
+		It("Unauthorized source", func() {
+			_, err := w.GetWHSchemaHistory(context.TODO(), &proto.WHSchemaHistoryRequest{
+				WorkspaceId: workspaceID,
+				SourceId:    "unknown-sourceID",
+			})
+			Expect(err).To(MatchError("no such sourceID exists"))
+
+			_, err = w.GetWHSchemaHistory(context.TODO(), &proto.WHSchemaHistoryRequest{
+				WorkspaceId: "unknown-workspaceID",
+			})
+			Expect(err).To(MatchError("unauthorized request"))
+		})
+
+		It("Getting column lineage", func() {
+			res, err := w.GetWHColumnLineage(context.TODO(), &proto.WHColumnLineageRequest{
+				WorkspaceId:   workspaceID,
+				DestinationId: destinationID,
+				Namespace:     namespace,
+				TableName:     "tracks",
+				ColumnName:    "revenue",
+			})
+			Expect(err).To(BeNil())
+			Expect(res.CurrentType).To(Equal("float"))
+			Expect(res.Origin.UploadId).To(BeEquivalentTo(2))
+			Expect(res.Origin.ChangeType).To(Equal(SchemaChangeAddColumn))
+			Expect(res.SourceIds).To(Equal([]string{sourceID}))
+			Expect(res.Changes).To(HaveLen(2))
+		})
+
+		It("Getting column lineage without column", func() {
+			_, err := w.GetWHColumnLineage(context.TODO(), &proto.WHColumnLineageRequest{
+				WorkspaceId:   workspaceID,
+				DestinationId: destinationID,
+				Namespace:     namespace,
+				TableName:     "tracks",
+			})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -303,6 +303,55 @@ var _ = Describe("Schema", func() {
 			},
 			StringColumnsToBeAlteredToText: []string{"test-column"},
 		}),
+
+		Entry(nil, "test-table", warehouseutils.SchemaT{
+			"test-table": map[string]string{
+				"test-column-1": "int",
+				"test-column-2": "boolean",
+				"test-column-3": "string",
+			},
+		}, warehouseutils.SchemaT{
+			"test-table": map[string]string{
+				"test-column-1": "float",
+				"test-column-2": "string",
+				"test-column-3": "int",
+			},
+		}, warehouseutils.TableSchemaDiffT{
+			Exists:           true,
+			TableToBeCreated: false,
+			ColumnMap:        map[string]string{},
+			UpdatedSchema: map[string]string{
+				"test-column-1": "float",
+				"test-column-2": "string",
+				"test-column-3": "string",
+			},
+			AlteredColumnMap: map[string]string{
+				"test-column-1": "float",
+				"test-column-2": "string",
+			},
+		}),
+
+		Entry("recovers a column renamed to its backup", "test-table", warehouseutils.SchemaT{
+			"test-table": map[string]string{
+				"test-column_rudder_backup": "int",
+				"test-column_rudder_shadow": "float",
+			},
+		}, warehouseutils.SchemaT{
+			"test-table": map[string]string{
+				"test-column": "float",
+			},
+		}, warehouseutils.TableSchemaDiffT{
+			Exists:    true,
+			ColumnMap: map[string]string{},
+			UpdatedSchema: map[string]string{
+				"test-column_rudder_backup": "int",
+				"test-column_rudder_shadow": "float",
+				"test-column":               "float",
+			},
+			AlteredColumnMap: map[string]string{
+				"test-column": "float",
+			},
+		}),
 	)
 
 	DescribeTable("Merge Upload and Local Schema", func(uploadSchema, schemaInWarehousePreUpload, expected warehouseutils.SchemaT) {
@@ -363,8 +412,85 @@ var _ = Describe("Schema", func() {
 				"test-column":   "text",
 			},
 		}),
+
+		Entry(nil, warehouseutils.SchemaT{
+			"test-table": map[string]string{
+				"test-column-1": "float",
+				"test-column-2": "int",
+			},
+		}, warehouseutils.SchemaT{
+			"test-table": map[string]string{
+				"test-column-1": "int",
+				"test-column-2": "float",
+			},
+		}, warehouseutils.SchemaT{
+			"test-table": {
+				"test-column-1": "float",
+				"test-column-2": "float",
+			},
+		}),
 	)
 
+	Describe("Column type evolution", func() {
+		BeforeEach(func() {
+			enableColumnTypeEvolution = true
+			warehouseutils.ColumnTypeEvolutionWarehouses = []string{warehouseutils.POSTGRES}
+		})
+		AfterEach(func() {
+			enableColumnTypeEvolution = false
+			warehouseutils.ColumnTypeEvolutionWarehouses = nil
+		})
+
+		DescribeTable("Is column type widening", func(existingType, newType string, expected bool) {
+			Expect(isColumnTypeWidening(existingType, newType)).To(Equal(expected))
+		},
+			Entry(nil, "int", "float", true),
+			Entry(nil, "int", "string", true),
+			Entry(nil, "boolean", "string", true),
+			Entry(nil, "datetime", "text", true),
+			Entry(nil, "string", "text", true),
+			Entry(nil, "float", "int", false),
+			Entry(nil, "string", "int", false),
+			Entry(nil, "text", "string", false),
+			Entry(nil, "json", "string", false),
+			Entry(nil, "int", "int", false),
+		)
+
+		DescribeTable("Merge schema", func(currentSchema warehouseutils.SchemaT, schemaList []warehouseutils.SchemaT, warehouseType string, expected warehouseutils.SchemaT) {
+			Expect(mergeSchema(currentSchema, schemaList, warehouseutils.SchemaT{}, warehouseType)).To(Equal(expected))
+		},
+			Entry("widens the types in the warehouse", warehouseutils.SchemaT{
+				"test-table": {"test-column-1": "int", "test-column-2": "boolean", "test-column-3": "string"},
+			}, []warehouseutils.SchemaT{
+				{"test-table": {"test-column-1": "float", "test-column-2": "string", "test-column-3": "int"}},
+			}, "POSTGRES", warehouseutils.SchemaT{
+				"test-table": {"test-column-1": "float", "test-column-2": "string", "test-column-3": "string"},
+			}),
+			Entry("keeps the types widened by previous schemas", warehouseutils.SchemaT{
+				"test-table": {"test-column": "int"},
+			}, []warehouseutils.SchemaT{
+				{"test-table": {"test-column": "string"}},
+				{"test-table": {"test-column": "float"}},
+				{"test-table": {"test-column": "int"}},
+			}, "POSTGRES", warehouseutils.SchemaT{
+				"test-table": {"test-column": "string"},
+			}),
+			Entry("widens the types of new columns", warehouseutils.SchemaT{}, []warehouseutils.SchemaT{
+				{"test-table": {"test-column": "int"}},
+				{"test-table": {"test-column": "float"}},
+			}, "POSTGRES", warehouseutils.SchemaT{
+				"test-table": {"test-column": "float"},
+			}),
+			Entry("keeps the types in warehouses not supporting evolution", warehouseutils.SchemaT{
+				"test-table": {"test-column-1": "int", "test-column-2": "string"},
+			}, []warehouseutils.SchemaT{
+				{"test-table": {"test-column-1": "float", "test-column-2": "text"}},
+			}, "S3_DATALAKE", warehouseutils.SchemaT{
+				"test-table": {"test-column-1": "int", "test-column-2": "text"},
+			}),
+		)
+	})
+
 	Describe("Has schema changed", func() {
 		g := GinkgoT()
 
@@ -0,0 +1,489 @@
+package warehouse
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/tidwall/gjson"
+
+	proto "github.com/rudderlabs/rudder-server/proto/warehouse"
+	"github.com/rudderlabs/rudder-server/services/alert"
+	"github.com/rudderlabs/rudder-server/services/stats"
+	"github.com/rudderlabs/rudder-server/utils/misc"
+	"github.com/rudderlabs/rudder-server/utils/timeutil"
+	warehouseutils "github.com/rudderlabs/rudder-server/warehouse/utils"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	SLABreachDataLag             = "data_lag"
+	SLABreachConsecutiveFailures = "consecutive_failures"
+	SLABreachSuccessBefore       = "success_before"
+)
+
+// SLAT is the service level of the uploads configured for a destination, e.g.
+// {"slaMaxDataLag": "120", "slaMaxConsecutiveFailures": "3", "slaSuccessBefore": "09:00"} expects the staging files to be
+// uploaded within 2 hours, at most 3 failed attempts in a row and a successful upload every day before 09:00 in the sync timezone.
+// Limits which are not set are not checked.
+type SLAT struct {
+	MaxDataLag             time.Duration
+	MaxConsecutiveFailures int64
+	SuccessBefore          string
+}
+
+// slaStateT is the state of the uploads of a warehouse the SLA is evaluated against
+type slaStateT struct {
+	oldestPendingAt     time.Time
+	consecutiveFailures int64
+	lastSucceededAt     time.Time
+}
+
+// SLABreachT is a breach of the SLA of a warehouse, open until resolved
+type SLABreachT struct {
+	ID              int64
+	WorkspaceID     string
+	SourceID        string
+	DestinationID   string
+	DestinationType string
+	Namespace       string
+	BreachType      string
+	Message         string
+	StartedAt       time.Time
+	AlertedAt       time.Time
+	ResolvedAt      time.Time
+}
+
+func (breach SLABreachT) proto() *proto.WHSLABreach {
+	p := &proto.WHSLABreach{
+		Id:              breach.ID,
+		SourceId:        breach.SourceID,
+		DestinationId:   breach.DestinationID,
+		DestinationType: breach.DestinationType,
+		Namespace:       breach.Namespace,
+		BreachType:      breach.BreachType,
+		Message:         breach.Message,
+		StartedAt:       timestamppb.New(breach.StartedAt),
+	}
+	if !breach.AlertedAt.IsZero() {
+		p.AlertedAt = timestamppb.New(breach.AlertedAt)
+	}
+	if !breach.ResolvedAt.IsZero() {
+		p.ResolvedAt = timestamppb.New(breach.ResolvedAt)
+	}
+	return p
+}
+
+// getSLA returns the SLA configured for the destination of the warehouse
+func getSLA(warehouse warehouseutils.Warehouse) SLAT {
+	return SLAT{
+		MaxDataLag:             time.Duration(getConfigValueAsInt(warehouseutils.SLAMaxDataLag, warehouse)) * time.Minute,
+		MaxConsecutiveFailures: getConfigValueAsInt(warehouseutils.SLAMaxConsecutiveFails, warehouse),
+		SuccessBefore:          warehouseutils.GetConfigValue(warehouseutils.SLASuccessBefore, warehouse),
+	}
+}
+
+func (sla SLAT) isSet() bool {
+	return sla.MaxDataLag > 0 || sla.MaxConsecutiveFailures > 0 || sla.SuccessBefore != ""
+}
+
+// slaBreaches returns the message of each of the breaches of the SLA by type, now being in the sync timezone of the warehouse
+func slaBreaches(sla SLAT, state slaStateT, now time.Time) map[string]string {
+	breaches := make(map[string]string)
+	if sla.MaxDataLag > 0 && !state.oldestPendingAt.IsZero() {
+		if dataLag := now.Sub(state.oldestPendingAt); dataLag > sla.MaxDataLag {
+			breaches[SLABreachDataLag] = fmt.Sprintf("oldest pending staging file is %s old, more than the maximum data lag of %s", dataLag.Truncate(time.Minute), sla.MaxDataLag)
+		}
+	}
+	if sla.MaxConsecutiveFailures > 0 && state.consecutiveFailures >= sla.MaxConsecutiveFailures {
+		breaches[SLABreachConsecutiveFailures] = fmt.Sprintf("%d upload attempts failed since the last successful upload, the maximum is %d", state.consecutiveFailures, sla.MaxConsecutiveFailures)
+	}
+	if sla.SuccessBefore != "" {
+		startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		deadline := startOfDay.Add(time.Duration(timeutil.MinsOfDay(sla.SuccessBefore)) * time.Minute)
+		if !now.Before(deadline) && state.lastSucceededAt.Before(startOfDay) {
+			breaches[SLABreachSuccessBefore] = fmt.Sprintf("no successful upload today before %s %s", sla.SuccessBefore, now.Location())
+		}
+	}
+	return breaches
+}
+
+type slaHandleT struct {
+	dbHandle *sql.DB
+	alerter  alert.AlertManager
+}
+
+// getSLAWarehouses returns the warehouses the SLA of the destination is checked for: the namespaces the staging files
+// of a routed warehouse were routed to are uploaded separately, so each of them is checked on its own
+func (sh *slaHandleT) getSLAWarehouses(warehouse warehouseutils.Warehouse) ([]warehouseutils.Warehouse, error) {
+	if _, namespaceRouting := warehouseutils.GetNamespaceRouting(warehouse.Destination); !namespaceRouting {
+		return []warehouseutils.Warehouse{warehouse}, nil
+	}
+	sqlStatement := fmt.Sprintf(`
+		SELECT
+		  DISTINCT namespace
+		FROM
+		  %s
+		WHERE
+		  source_id = $1
+		  AND destination_id = $2;
+`,
+		warehouseutils.WarehouseStagingFilesTable,
+	)
+	rows, err := sh.dbHandle.Query(sqlStatement, warehouse.Source.ID, warehouse.Destination.ID)
+	if err != nil {
+		return nil, fmt.Errorf("query: %s failed with error: %w", sqlStatement, err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var warehouses []warehouseutils.Warehouse
+	for rows.Next() {
+		var routedNamespace string
+		if err = rows.Scan(&routedNamespace); err != nil {
+			return nil, err
+		}
+		warehouses = append(warehouses, routedWarehouse(warehouse, routedNamespace))
+	}
+	return warehouses, rows.Err()
+}
+
+// getSLAState returns the oldest staging file not exported yet, the failed attempts since the last successful upload and the time of the last successful upload of the warehouse
+func (sh *slaHandleT) getSLAState(warehouse warehouseutils.Warehouse) (state slaStateT, err error) {
+	var (
+		lastSucceededID          sql.NullInt64
+		lastSucceededAt          sql.NullTime
+		lastExportedStagingFile  sql.NullInt64
+		oldestPendingStagingFile sql.NullTime
+	)
+	sqlStatement := fmt.Sprintf(`
+		SELECT
+		  id,
+		  updated_at,
+		  end_staging_file_id
+		FROM
+		  %s
+		WHERE
+		  source_id = $1
+		  AND destination_id = $2
+		  AND status = $3 %s
+		ORDER BY
+		  id DESC
+		LIMIT
+		  1;
+`,
+		warehouseutils.WarehouseUploadsTable,
+		routedUploadsSQL(warehouse),
+	)
+	err = sh.dbHandle.QueryRow(sqlStatement, warehouse.Source.ID, warehouse.Destination.ID, ExportedData).Scan(&lastSucceededID, &lastSucceededAt, &lastExportedStagingFile)
+	if err != nil && err != sql.ErrNoRows {
+		return state, fmt.Errorf("query: %s failed with error: %w", sqlStatement, err)
+	}
+	state.lastSucceededAt = lastSucceededAt.Time
+
+	sqlStatement = fmt.Sprintf(`
+		SELECT
+		  MIN(created_at)
+		FROM
+		  %s
+		WHERE
+		  id > $1
+		  AND source_id = $2
+		  AND destination_id = $3 %s;
+`,
+		warehouseutils.WarehouseStagingFilesTable,
+		routedNamespaceSQL(warehouse, "namespace"),
+	)
+	err = sh.dbHandle.QueryRow(sqlStatement, lastExportedStagingFile.Int64, warehouse.Source.ID, warehouse.Destination.ID).Scan(&oldestPendingStagingFile)
+	if err != nil {
+		return state, fmt.Errorf("query: %s failed with error: %w", sqlStatement, err)
+	}
+	state.oldestPendingAt = oldestPendingStagingFile.Time
+
+	// attempts are counted by state in the errors of the uploads
+	sqlStatement = fmt.Sprintf(`
+		SELECT
+		  error
+		FROM
+		  %s
+		WHERE
+		  source_id = $1
+		  AND destination_id = $2
+		  AND id > $3
+		  AND error IS NOT NULL %s;
+`,
+		warehouseutils.WarehouseUploadsTable,
+		routedUploadsSQL(warehouse),
+	)
+	rows, err := sh.dbHandle.Query(sqlStatement, warehouse.Source.ID, warehouse.Destination.ID, lastSucceededID.Int64)
+	if err != nil {
+		return state, fmt.Errorf("query: %s failed with error: %w", sqlStatement, err)
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		var uploadError string
+		if err = rows.Scan(&uploadError); err != nil {
+			return state, err
+		}
+		gjson.Parse(uploadError).ForEach(func(_, value gjson.Result) bool {
+			state.consecutiveFailures += value.Get("attempt").Int()
+			return true
+		})
+	}
+	return state, rows.Err()
+}
+
+// getOpenSLABreaches returns the ids of the open breaches of the warehouse by type, of its namespace if it is routed
+func (sh *slaHandleT) getOpenSLABreaches(warehouse warehouseutils.Warehouse) (map[string]int64, error) {
+	var namespaceSQL string
+	if warehouse.NamespaceRouting {
+		namespaceSQL = fmt.Sprintf(`AND namespace = %s`, misc.QuoteLiteral(warehouse.Namespace))
+	}
+	sqlStatement := fmt.Sprintf(`
+		SELECT
+		  id,
+		  breach_type
+		FROM
+		  %s
+		WHERE
+		  source_id = $1
+		  AND destination_id = $2
+		  AND resolved_at IS NULL %s;
+`,
+		warehouseutils.WarehouseSLABreachesTable,
+		namespaceSQL,
+	)
+	rows, err := sh.dbHandle.Query(sqlStatement, warehouse.Source.ID, warehouse.Destination.ID)
+	if err != nil {
+		return nil, fmt.Errorf("query: %s failed with error: %w", sqlStatement, err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	openBreaches := make(map[string]int64)
+	for rows.Next() {
+		var (
+			id         int64
+			breachType string
+		)
+		if err = rows.Scan(&id, &breachType); err != nil {
+			return nil, err
+		}
+		openBreaches[breachType] = id
+	}
+	return openBreaches, rows.Err()
+}
+
+// openSLABreach records the breach and alerts about it
+func (sh *slaHandleT) openSLABreach(warehouse warehouseutils.Warehouse, breachType, message string) error {
+	sqlStatement := fmt.Sprintf(`
+		INSERT INTO %s (
+		  workspace_id, source_id, destination_id,
+		  destination_type, namespace, breach_type,
+		  message, started_at
+		)
+		VALUES
+		  ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id;
+`,
+		warehouseutils.WarehouseSLABreachesTable,
+	)
+	var id int64
+	err := sh.dbHandle.QueryRow(sqlStatement, warehouse.WorkspaceID, warehouse.Source.ID, warehouse.Destination.ID, warehouse.Type, warehouse.Namespace, breachType, message, timeutil.Now()).Scan(&id)
+	if err != nil {
+		return fmt.Errorf("query: %s failed with error: %w", sqlStatement, err)
+	}
+	pkgLogger.Warnf("[WH]: SLA of %s breached: %s", warehouse.Identifier, message)
+
+	tags := map[string]string{
+		"workspaceId": warehouse.WorkspaceID,
+		"destType":    warehouse.Type,
+		"destID":      warehouse.Destination.ID,
+		"sourceID":    warehouse.Source.ID,
+		"breachType":  breachType,
+	}
+	stats.Default.NewTaggedStat("warehouse.sla.breaches", stats.CountType, tags).Count(1)
+
+	if sh.alerter == nil {
+		return nil
+	}
+	sh.alerter.Alert(fmt.Sprintf("Warehouse SLA breached for %s source %s (%s) to destination %s (%s): %s", warehouse.Type, warehouse.Source.Name, warehouse.Source.ID, warehouse.Destination.Name, warehouse.Destination.ID, message))
+	sqlStatement = fmt.Sprintf(`UPDATE %s SET alerted_at = $1 WHERE id = $2;`, warehouseutils.WarehouseSLABreachesTable)
+	if _, err = sh.dbHandle.Exec(sqlStatement, timeutil.Now(), id); err != nil {
+		return fmt.Errorf("query: %s failed with error: %w", sqlStatement, err)
+	}
+	return nil
+}
+
+func (sh *slaHandleT) resolveSLABreaches(ids []int64) error {
+	sqlStatement := fmt.Sprintf(`UPDATE %s SET resolved_at = $1 WHERE id = ANY($2);`, warehouseutils.WarehouseSLABreachesTable)
+	if _, err := sh.dbHandle.Exec(sqlStatement, timeutil.Now(), pq.Array(ids)); err != nil {
+		return fmt.Errorf("query: %s failed with error: %w", sqlStatement, err)
+	}
+	return nil
+}
+
+// checkSLA opens the breaches of the SLA of the warehouse not open yet, and resolves the open breaches which are over
+func (sh *slaHandleT) checkSLA(warehouse warehouseutils.Warehouse) error {
+	sla := getSLA(warehouse)
+	openBreaches, err := sh.getOpenSLABreaches(warehouse)
+	if err != nil {
+		return err
+	}
+	if !sla.isSet() && len(openBreaches) == 0 {
+		return nil
+	}
+
+	breaches := make(map[string]string)
+	if sla.isSet() {
+		state, err := sh.getSLAState(warehouse)
+		if err != nil {
+			return err
+		}
+		syncLocation, err := GetSyncLocation(warehouseutils.GetConfigValue(warehouseutils.SyncTimezone, warehouse))
+		if err != nil {
+			pkgLogger.Errorf("[WH]: Invalid sync timezone for %s, using UTC: %v", warehouse.Identifier, err)
+			syncLocation = time.UTC
+		}
+		breaches = slaBreaches(sla, state, timeutil.Now().In(syncLocation))
+	}
+
+	for breachType, message := range breaches {
+		if _, ok := openBreaches[breachType]; ok {
+			continue
+		}
+		if err = sh.openSLABreach(warehouse, breachType, message); err != nil {
+			return err
+		}
+	}
+	var resolved []int64
+	for breachType, id := range openBreaches {
+		if _, ok := breaches[breachType]; !ok {
+			resolved = append(resolved, id)
+		}
+	}
+	if len(resolved) == 0 {
+		return nil
+	}
+	pkgLogger.Infof("[WH]: Resolved %d SLA breaches of %s", len(resolved), warehouse.Identifier)
+	return sh.resolveSLABreaches(resolved)
+}
+
+// slaTargets returns the warehouses of the enabled sources and destinations
+func slaTargets() []warehouseutils.Warehouse {
+	connectionsMapLock.RLock()
+	defer connectionsMapLock.RUnlock()
+	var warehouses []warehouseutils.Warehouse
+	for _, srcMap := range connectionsMap {
+		for _, warehouse := range srcMap {
+			if warehouse.Source.Enabled && warehouse.Destination.Enabled {
+				warehouses = append(warehouses, warehouse)
+			}
+		}
+	}
+	return warehouses
+}
+
+func runSLAMonitor(ctx context.Context, dbHandle *sql.DB) {
+	sh := &slaHandleT{dbHandle: dbHandle}
+	alerter, err := alert.New()
+	if err != nil {
+		pkgLogger.Errorf("[WH]: Unable to initialize the alert manager, SLA breaches will not be alerted: %v", err)
+	} else {
+		sh.alerter = alerter
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			pkgLogger.Infof("context is cancelled, stopped monitoring SLAs")
+			return
+		case <-time.After(slaTickerTime):
+			if !enableSLAMonitoring {
+				continue
+			}
+			for _, target := range slaTargets() {
+				warehouses, err := sh.getSLAWarehouses(target)
+				if err != nil {
+					pkgLogger.Errorf("[WH]: Failed to get the namespaces to check the SLA of %s: %v", target.Identifier, err)
+					continue
+				}
+				for _, warehouse := range warehouses {
+					if err := sh.checkSLA(warehouse); err != nil {
+						pkgLogger.Errorf("[WH]: Failed to check SLA of %s: %v", warehouse.Identifier, err)
+					}
+				}
+			}
+		}
+	}
+}
+
+// SLABreachesReqT lists the SLA breaches of the sources of a workspace
+type SLABreachesReqT struct {
+	WorkspaceID     string
+	SourceID        string
+	DestinationID   string
+	IncludeResolved bool
+	Limit           int32
+	Offset          int32
+	API             UploadAPIT
+}
+
+func (breachesReq *SLABreachesReqT) validateReq() error {
+	if !breachesReq.API.enabled || breachesReq.API.log == nil || breachesReq.API.dbHandle == nil {
+		return errors.New("warehouse api's are not initialized")
+	}
+	if breachesReq.WorkspaceID == "" {
+		return errors.New("workspace_id is empty")
+	}
+	if breachesReq.Limit < 1 {
+		breachesReq.Limit = 10
+	}
+	if breachesReq.Offset < 0 {
+		breachesReq.Offset = 0
+	}
+	return nil
+}
+
+// GetSLABreaches returns the SLA breaches matching the request, the latest first
+func (breachesReq *SLABreachesReqT) GetSLABreaches(ctx context.Context) (*proto.WHSLABreachesResponse, error) {
+	if err := breachesReq.validateReq(); err != nil {
+		return &proto.WHSLABreachesResponse{}, err
+	}
+	sourceIDs := UploadsReqT{WorkspaceID: breachesReq.WorkspaceID}.authorizedSources()
+	if len(sourceIDs) == 0 {
+		return &proto.WHSLABreachesResponse{}, errors.New("unauthorized request")
+	}
+	if breachesReq.SourceID != "" && !misc.Contains(sourceIDs, breachesReq.SourceID) {
+		return &proto.WHSLABreachesResponse{}, errors.New("no such sourceID exists")
+	}
+
+	clauses := []FilterClause{{
+		Clause:    fmt.Sprintf(`source_id = ANY(%s)`, queryPlaceHolder),
+		ClauseArg: pq.Array(sourceIDs),
+	}}
+	if breachesReq.SourceID != "" {
+		clauses = append(clauses, FilterClause{Clause: fmt.Sprintf(`source_id = %s`, queryPlaceHolder), ClauseArg: breachesReq.SourceID})
+	}
+	if breachesReq.DestinationID != "" {
+		clauses = append(clauses, FilterClause{Clause: fmt.Sprintf(`destination_id = %s`, queryPlaceHolder), ClauseArg: breachesReq.DestinationID})
+	}
+
+	breaches, total, err := breachesReq.API.warehouseDBHandle.GetSLABreaches(ctx, breachesReq.IncludeResolved, breachesReq.Limit, breachesReq.Offset, clauses...)
+	if err != nil {
+		breachesReq.API.log.Errorf("WH: Error getting SLA breaches for workspace %s: %v", breachesReq.WorkspaceID, err)
+		return &proto.WHSLABreachesResponse{}, err
+	}
+	response := &proto.WHSLABreachesResponse{
+		Breaches: make([]*proto.WHSLABreach, 0, len(breaches)),
+		Pagination: &proto.Pagination{
+			Limit:  breachesReq.Limit,
+			Offset: breachesReq.Offset,
+			Total:  int32(total),
+		},
+	}
+	for _, breach := range breaches {
+		response.Breaches = append(response.Breaches, breach.proto())
+	}
+	return response, nil
+}
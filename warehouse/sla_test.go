@@ -0,0 +1,190 @@
+//go:build !warehouse_integration
+
+package warehouse
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/ory/dockertest/v3"
+
+	backendconfig "github.com/rudderlabs/rudder-server/config/backend-config"
+	"github.com/rudderlabs/rudder-server/testhelper"
+	"github.com/rudderlabs/rudder-server/testhelper/destination"
+	"github.com/rudderlabs/rudder-server/utils/logger"
+	warehouseutils "github.com/rudderlabs/rudder-server/warehouse/utils"
+)
+
+type slaAlerter struct {
+	alerts []string
+}
+
+func (a *slaAlerter) Alert(message string) {
+	a.alerts = append(a.alerts, message)
+}
+
+var _ = Describe("SLA", func() {
+	DescribeTable("SLA of the destination", func(config map[string]interface{}, expected SLAT) {
+		warehouse := warehouseutils.Warehouse{
+			Destination: backendconfig.DestinationT{Config: config},
+		}
+		Expect(getSLA(warehouse)).To(Equal(expected))
+		Expect(getSLA(warehouse).isSet()).To(Equal(expected != SLAT{}))
+	},
+		Entry("Not configured", map[string]interface{}{}, SLAT{}),
+		Entry("All limits", map[string]interface{}{
+			"slaMaxDataLag":             "120",
+			"slaMaxConsecutiveFailures": "3",
+			"slaSuccessBefore":          "09:00",
+		}, SLAT{MaxDataLag: 2 * time.Hour, MaxConsecutiveFailures: 3, SuccessBefore: "09:00"}),
+		Entry("Invalid limits are not set", map[string]interface{}{
+			"slaMaxDataLag":             "two hours",
+			"slaMaxConsecutiveFailures": "",
+		}, SLAT{}),
+	)
+
+	now := time.Date(2022, 10, 1, 10, 30, 0, 0, time.UTC)
+	DescribeTable("SLA breaches", func(sla SLAT, state slaStateT, expected []string) {
+		breaches := slaBreaches(sla, state, now)
+		breachTypes := make([]string, 0, len(breaches))
+		for breachType := range breaches {
+			breachTypes = append(breachTypes, breachType)
+		}
+		Expect(breachTypes).To(ConsistOf(expected))
+	},
+		Entry("No SLA", SLAT{}, slaStateT{oldestPendingAt: now.AddDate(0, 0, -1), consecutiveFailures: 10}, []string{}),
+		Entry("Within the data lag", SLAT{MaxDataLag: time.Hour}, slaStateT{oldestPendingAt: now.Add(-time.Hour)}, []string{}),
+		Entry("Data lag", SLAT{MaxDataLag: time.Hour}, slaStateT{oldestPendingAt: now.Add(-61 * time.Minute)}, []string{SLABreachDataLag}),
+		Entry("No pending staging files", SLAT{MaxDataLag: time.Hour}, slaStateT{}, []string{}),
+		Entry("Fewer failures", SLAT{MaxConsecutiveFailures: 3}, slaStateT{consecutiveFailures: 2}, []string{}),
+		Entry("Consecutive failures", SLAT{MaxConsecutiveFailures: 3}, slaStateT{consecutiveFailures: 3}, []string{SLABreachConsecutiveFailures}),
+		Entry("Succeeded before the deadline", SLAT{SuccessBefore: "09:00"}, slaStateT{lastSucceededAt: now.Add(-2 * time.Hour)}, []string{}),
+		Entry("Before the deadline", SLAT{SuccessBefore: "11:00"}, slaStateT{}, []string{}),
+		Entry("No success today", SLAT{SuccessBefore: "09:00"}, slaStateT{lastSucceededAt: now.AddDate(0, 0, -1)}, []string{SLABreachSuccessBefore}),
+		Entry("Every breach", SLAT{MaxDataLag: time.Hour, MaxConsecutiveFailures: 1, SuccessBefore: "10:30"}, slaStateT{oldestPendingAt: now.AddDate(0, 0, -1), consecutiveFailures: 1}, []string{SLABreachDataLag, SLABreachConsecutiveFailures, SLABreachSuccessBefore}),
+	)
+
+	Describe("SLA monitoring", Ordered, func() {
+		var (
+			pgResource *destination.PostgresResource
+			cleanup    = &testhelper.Cleanup{}
+			alerter    *slaAlerter
+			sh         *slaHandleT
+			warehouse  = warehouseutils.Warehouse{
+				WorkspaceID: "test-workspaceID",
+				Source:      backendconfig.SourceT{ID: "test-sourceID"},
+				Destination: backendconfig.DestinationT{
+					ID: "test-destinationID",
+					Config: map[string]interface{}{
+						"slaMaxDataLag":             "60",
+						"slaMaxConsecutiveFailures": "3",
+					},
+				},
+				Namespace: "test-namespace",
+				Type:      "POSTGRES",
+			}
+		)
+
+		openBreaches := func() []SLABreachT {
+			breaches, _, err := NewWarehouseDB(pgResource.DB).GetSLABreaches(context.Background(), false, 10, 0)
+			Expect(err).To(BeNil())
+			return breaches
+		}
+
+		BeforeAll(func() {
+			pool, err := dockertest.NewPool("")
+			Expect(err).To(BeNil())
+
+			pgResource = setupWarehouseJobs(pool, GinkgoT(), cleanup)
+
+			initWarehouse()
+
+			err = setupDB(context.TODO(), getConnectionString())
+			Expect(err).To(BeNil())
+
+			pkgLogger = logger.NOP
+			alerter = &slaAlerter{}
+			sh = &slaHandleT{dbHandle: pgResource.DB, alerter: alerter}
+
+			_, err = pgResource.DB.Exec(`
+				INSERT INTO wh_staging_files (id, location, source_id, destination_id, schema, created_at, updated_at)
+				VALUES
+				  (1, 'staging-1', 'test-sourceID', 'test-destinationID', '{}', now() - interval '3 hours', now()),
+				  (2, 'staging-2', 'test-sourceID', 'test-destinationID', '{}', now() - interval '2 hours', now());
+				INSERT INTO wh_uploads (id, source_id, namespace, destination_id, destination_type, start_staging_file_id, end_staging_file_id, status, schema, error, created_at, updated_at)
+				VALUES
+				  (1, 'test-sourceID', 'test-namespace', 'test-destinationID', 'POSTGRES', 1, 1, 'exported_data', '{}', '{}', now(), now()),
+				  (2, 'test-sourceID', 'test-namespace', 'test-destinationID', 'POSTGRES', 2, 2, 'exporting_data_failed', '{}', '{"exporting_data_failed": {"attempt": 2, "errors": ["timeout"]}, "generating_load_files_failed": {"attempt": 1, "errors": ["timeout"]}}', now(), now());
+			`)
+			Expect(err).To(BeNil())
+		})
+
+		AfterAll(func() {
+			cleanup.Run()
+		})
+
+		It("Should open and alert the breaches", func() {
+			Expect(sh.checkSLA(warehouse)).To(BeNil())
+
+			breaches := openBreaches()
+			Expect(breaches).To(HaveLen(2))
+			for _, breach := range breaches {
+				Expect(breach.BreachType).To(BeElementOf(SLABreachDataLag, SLABreachConsecutiveFailures))
+				Expect(breach.WorkspaceID).To(Equal("test-workspaceID"))
+				Expect(breach.AlertedAt).ToNot(BeZero())
+				Expect(breach.ResolvedAt).To(BeZero())
+			}
+			Expect(alerter.alerts).To(HaveLen(2))
+		})
+
+		It("Should not alert open breaches again", func() {
+			Expect(sh.checkSLA(warehouse)).To(BeNil())
+			Expect(openBreaches()).To(HaveLen(2))
+			Expect(alerter.alerts).To(HaveLen(2))
+		})
+
+		It("Should resolve the breaches once uploaded", func() {
+			_, err := pgResource.DB.Exec(`UPDATE wh_uploads SET status = 'exported_data' WHERE id = 2`)
+			Expect(err).To(BeNil())
+
+			Expect(sh.checkSLA(warehouse)).To(BeNil())
+			Expect(openBreaches()).To(BeEmpty())
+
+			breaches, total, err := NewWarehouseDB(pgResource.DB).GetSLABreaches(context.Background(), true, 10, 0)
+			Expect(err).To(BeNil())
+			Expect(total).To(Equal(int64(2)))
+			for _, breach := range breaches {
+				Expect(breach.ResolvedAt).ToNot(BeZero())
+			}
+		})
+
+		It("Should check the SLA of each routed namespace", func() {
+			_, err := pgResource.DB.Exec(`
+				INSERT INTO wh_staging_files (id, location, source_id, destination_id, namespace, schema, created_at, updated_at)
+				VALUES
+				  (3, 'staging-3', 'test-sourceID', 'test-destinationID', 'routed-namespace', '{}', now() - interval '2 hours', now());
+			`)
+			Expect(err).To(BeNil())
+
+			routed := warehouse
+			routed.Destination.Config = map[string]interface{}{
+				"slaMaxDataLag":                 "60",
+				"namespaceRoutingField":         "context.namespace",
+				"namespaceRoutingAllowedValues": []interface{}{"routed-namespace"},
+			}
+			warehouses, err := sh.getSLAWarehouses(routed)
+			Expect(err).To(BeNil())
+			Expect(warehouses).To(HaveLen(2))
+			for _, warehouse := range warehouses {
+				Expect(sh.checkSLA(warehouse)).To(BeNil())
+			}
+
+			breaches := openBreaches()
+			Expect(breaches).To(HaveLen(1), "the staging files of the default namespace are exported")
+			Expect(breaches[0].BreachType).To(Equal(SLABreachDataLag))
+			Expect(breaches[0].Namespace).To(Equal("routed-namespace"))
+		})
+	})
+})
@@ -47,6 +47,9 @@ type JobRunT struct {
 	tableEventCountMap   map[string]int
 	stagingFileReader    *gzip.Reader
 	whIdentifier         string
+	constraints          []ConstraintsI
+	// constraintViolations counts the violations by table, column and constraint
+	constraintViolations map[string]map[string]map[string]int
 }
 
 func (jobRun *JobRunT) setStagingFileReader() (reader *gzip.Reader, endOfFile bool) {
@@ -188,7 +191,8 @@ func (job *Payload) getDiscardsTable() string {
 func (jobRun *JobRunT) getLoadFilePath(tableName string) string {
 	job := jobRun.job
 	randomness := uuid.Must(uuid.NewV4()).String()
-	return strings.TrimSuffix(jobRun.stagingFilePath, "json.gz") + tableName + fmt.Sprintf(`.%s`, randomness) + fmt.Sprintf(`.%s`, warehouseutils.GetLoadFileFormat(job.DestinationType))
+	stagingFilePath := strings.TrimSuffix(strings.TrimSuffix(jobRun.stagingFilePath, "json.gz"), "parquet.tar")
+	return stagingFilePath + tableName + fmt.Sprintf(`.%s`, randomness) + fmt.Sprintf(`.%s`, warehouseutils.GetLoadFileFormat(job.DestinationType))
 }
 
 func (job *Payload) getColumnName(columnName string) string {
@@ -208,6 +212,7 @@ type loadFileUploadOutputT struct {
 	StagingFileID         int64
 	DestinationRevisionID string
 	UseRudderStorage      bool
+	ConstraintViolations  map[string]map[string]int `json:",omitempty"`
 }
 
 func (jobRun *JobRunT) uploadLoadFilesToObjectStorage() ([]loadFileUploadOutputT, error) {
@@ -260,6 +265,7 @@ func (jobRun *JobRunT) uploadLoadFilesToObjectStorage() ([]loadFileUploadOutputT
 						StagingFileID:         stagingFileId,
 						DestinationRevisionID: job.DestinationRevisionID,
 						UseRudderStorage:      job.UseRudderStorage,
+						ConstraintViolations:  jobRun.constraintViolations[tableName],
 					}
 				}
 			}
@@ -396,6 +402,11 @@ func processStagingFile(job Payload, workerIndex int) (loadFileUploadOutputs []l
 
 	pkgLogger.Debugf("[WH]: Starting processing staging file: %v at %s for %s", job.StagingFileID, job.StagingFileLocation, jobRun.whIdentifier)
 
+	jobRun.constraints, err = ConfiguredConstraints(job.DestinationConfig)
+	if err != nil {
+		return loadFileUploadOutputs, err
+	}
+
 	jobRun.setStagingFileDownloadPath(workerIndex)
 
 	// This creates the file, so on successful creation remove it
@@ -406,21 +417,10 @@ func processStagingFile(job Payload, workerIndex int) (loadFileUploadOutputs []l
 
 	sortedTableColumnMap := job.getSortedColumnMapForAllTables()
 
-	reader, endOfFile := jobRun.setStagingFileReader()
-	if endOfFile {
-		// If empty file, return nothing
-		return loadFileUploadOutputs, nil
-	}
-	scanner := bufio.NewScanner(reader)
-	// default scanner buffer maxCapacity is 64K
-	// set it to higher value to avoid read stop on read size error
-	maxCapacity := maxStagingFileReadBufferCapacityInK * 1024
-	buf := make([]byte, maxCapacity)
-	scanner.Buffer(buf, maxCapacity)
-
 	// read from staging file and write a separate load file for each table in warehouse
 	jobRun.outputFileWritersMap = make(map[string]warehouseutils.LoadFileWriterI)
 	jobRun.tableEventCountMap = make(map[string]int)
+	jobRun.constraintViolations = make(map[string]map[string]map[string]int)
 	jobRun.uuidTS = timeutil.Now()
 
 	// Initilize Discards Table
@@ -430,128 +430,185 @@ func processStagingFile(job Payload, workerIndex int) (loadFileUploadOutputs []l
 	timer := jobRun.timerStat("process_staging_file_time")
 	timer.Start()
 
-	lineBytesCounter := 0
-	var interfaceSliceSample []interface{}
-	for {
-		ok := scanner.Scan()
-		if !ok {
-			scanErr := scanner.Err()
-			if scanErr != nil {
-				pkgLogger.Errorf("WH: Error in scanner reading line from staging file: %v", scanErr)
-			}
-			break
-		}
-
-		lineBytes := scanner.Bytes()
-		lineBytesCounter += len(lineBytes)
-		var batchRouterEvent BatchRouterEventT
-		err := json.Unmarshal(lineBytes, &batchRouterEvent)
+	var bytesProcessed int
+	if job.StagingFileFormat == warehouseutils.StagingFileFormatParquet {
+		// columnar staging files are already split by table, their events are read without parsing them
+		fi, err := os.Stat(jobRun.stagingFilePath)
 		if err != nil {
-			pkgLogger.Errorf("[WH]: Failed to unmarshal JSON line to batchrouter event: %+v", batchRouterEvent)
-			continue
+			return loadFileUploadOutputs, err
 		}
-
-		tableName := batchRouterEvent.Metadata.Table
-		columnData := batchRouterEvent.Data
-
-		// Create separate load file for each table
-		writer, err := jobRun.GetWriter(tableName)
+		bytesProcessed = int(fi.Size())
+		err = readColumnarStagingFile(jobRun.stagingFilePath, func(batchRouterEvent *BatchRouterEventT) error {
+			return jobRun.processEvent(batchRouterEvent, sortedTableColumnMap)
+		})
 		if err != nil {
-			return nil, err
+			return loadFileUploadOutputs, err
 		}
-
-		eventLoader := warehouseutils.GetNewEventLoader(job.DestinationType, job.LoadFileType, writer)
-		for _, columnName := range sortedTableColumnMap[tableName] {
-			if eventLoader.IsLoadTimeColumn(columnName) {
-				timestampFormat := eventLoader.GetLoadTimeFomat(columnName)
-				eventLoader.AddColumn(job.getColumnName(columnName), job.UploadSchema[tableName][columnName], jobRun.uuidTS.Format(timestampFormat))
-				continue
-			}
-			columnInfo, ok := batchRouterEvent.GetColumnInfo(columnName)
+	} else {
+		reader, endOfFile := jobRun.setStagingFileReader()
+		if endOfFile {
+			// If empty file, return nothing
+			return loadFileUploadOutputs, nil
+		}
+		scanner := bufio.NewScanner(reader)
+		// default scanner buffer maxCapacity is 64K
+		// set it to higher value to avoid read stop on read size error
+		maxCapacity := maxStagingFileReadBufferCapacityInK * 1024
+		buf := make([]byte, maxCapacity)
+		scanner.Buffer(buf, maxCapacity)
+
+		for {
+			ok := scanner.Scan()
 			if !ok {
-				eventLoader.AddEmptyColumn(columnName)
+				scanErr := scanner.Err()
+				if scanErr != nil {
+					pkgLogger.Errorf("WH: Error in scanner reading line from staging file: %v", scanErr)
+				}
+				break
+			}
+
+			lineBytes := scanner.Bytes()
+			bytesProcessed += len(lineBytes)
+			var batchRouterEvent BatchRouterEventT
+			err := json.Unmarshal(lineBytes, &batchRouterEvent)
+			if err != nil {
+				pkgLogger.Errorf("[WH]: Failed to unmarshal JSON line to batchrouter event: %+v", batchRouterEvent)
 				continue
 			}
-			columnType := columnInfo.ColumnType
-			columnVal := columnInfo.ColumnVal
 
-			if columnType == "int" || columnType == "bigint" {
-				floatVal, ok := columnVal.(float64)
-				if !ok {
-					eventLoader.AddEmptyColumn(columnName)
-					continue
-				}
-				columnVal = int(floatVal)
+			if err = jobRun.processEvent(&batchRouterEvent, sortedTableColumnMap); err != nil {
+				return loadFileUploadOutputs, err
 			}
+		}
+	}
+	timer.End()
 
-			dataTypeInSchema, ok := job.UploadSchema[tableName][columnName]
-			violatedConstraints := ViolatedConstraints(job.DestinationType, &batchRouterEvent, columnName)
-			if ok && ((columnType != dataTypeInSchema) || (violatedConstraints.IsViolated)) {
-				newColumnVal, ok := HandleSchemaChange(dataTypeInSchema, columnType, columnVal)
-				if !ok || violatedConstraints.IsViolated {
-					if violatedConstraints.IsViolated {
-						eventLoader.AddColumn(columnName, job.UploadSchema[tableName][columnName], violatedConstraints.ViolatedIdentifier)
-					} else {
-						eventLoader.AddEmptyColumn(columnName)
-					}
+	pkgLogger.Debugf("[WH]: Process %v bytes from downloaded staging file: %s", bytesProcessed, job.StagingFileLocation)
+	jobRun.counterStat("bytes_processed_in_staging_file").Count(bytesProcessed)
+	for _, loadFile := range jobRun.outputFileWritersMap {
+		err = loadFile.Close()
+		if err != nil {
+			pkgLogger.Errorf("Error while closing load file %s : %v", loadFile.GetLoadFile().Name(), err)
+		}
+	}
+	loadFileUploadOutputs, err = jobRun.uploadLoadFilesToObjectStorage()
+	return loadFileUploadOutputs, err
+}
 
-					discardWriter, err := jobRun.GetWriter(discardsTable)
-					if err != nil {
-						return nil, err
-					}
-					// add discardWriter to outputFileWritersMap
-					jobRun.outputFileWritersMap[discardsTable] = discardWriter
+// processEvent writes the event to the load file of its table
+func (jobRun *JobRunT) processEvent(batchRouterEvent *BatchRouterEventT, sortedTableColumnMap map[string][]string) error {
+	job := jobRun.job
+	var interfaceSliceSample []interface{}
 
-					err = jobRun.handleDiscardTypes(tableName, columnName, columnVal, columnData, violatedConstraints, discardWriter)
+	tableName := batchRouterEvent.Metadata.Table
+	columnData := batchRouterEvent.Data
 
-					if err != nil {
-						pkgLogger.Errorf("[WH]: Failed to write to discards: %v", err)
+	// Create separate load file for each table
+	writer, err := jobRun.GetWriter(tableName)
+	if err != nil {
+		return err
+	}
+
+	eventLoader := warehouseutils.GetNewEventLoader(job.DestinationType, job.LoadFileType, writer)
+	for _, columnName := range sortedTableColumnMap[tableName] {
+		if eventLoader.IsLoadTimeColumn(columnName) {
+			timestampFormat := eventLoader.GetLoadTimeFomat(columnName)
+			eventLoader.AddColumn(job.getColumnName(columnName), job.UploadSchema[tableName][columnName], jobRun.uuidTS.Format(timestampFormat))
+			continue
+		}
+		columnInfo, ok := batchRouterEvent.GetColumnInfo(columnName)
+		if !ok {
+			// missing columns can still violate not null constraints
+			violatedConstraints := ViolatedConstraints(job.DestinationType, batchRouterEvent, columnName, jobRun.constraints...)
+			if violatedConstraints.IsViolated {
+				jobRun.recordConstraintViolation(tableName, columnName, violatedConstraints)
+				switch violatedConstraints.Policy {
+				case ConstraintPolicyFail:
+					return constraintViolationError(tableName, columnName, violatedConstraints)
+				case ConstraintPolicyDiscard:
+					if err = jobRun.discardColumn(tableName, columnName, "", columnData, violatedConstraints); err != nil {
+						return err
 					}
-					jobRun.tableEventCountMap[discardsTable]++
-					continue
 				}
-				if newColumnVal == nil {
-					eventLoader.AddEmptyColumn(columnName)
-					continue
-				}
-				columnVal = newColumnVal
 			}
+			eventLoader.AddEmptyColumn(columnName)
+			continue
+		}
+		columnType := columnInfo.ColumnType
+		columnVal := columnInfo.ColumnVal
 
-			// Special handling for JSON arrays
-			// TODO: Will this work for both BQ and RS?
-			if reflect.TypeOf(columnVal) == reflect.TypeOf(interfaceSliceSample) {
-				marshalledVal, err := json.Marshal(columnVal)
-				if err != nil {
-					pkgLogger.Errorf("[WH]: Error in marshalling []interface{} columnVal: %v", err)
+		if columnType == "int" || columnType == "bigint" {
+			floatVal, ok := columnVal.(float64)
+			if !ok {
+				eventLoader.AddEmptyColumn(columnName)
+				continue
+			}
+			columnVal = int(floatVal)
+		}
+
+		dataTypeInSchema, ok := job.UploadSchema[tableName][columnName]
+		violatedConstraints := ViolatedConstraints(job.DestinationType, batchRouterEvent, columnName, jobRun.constraints...)
+		if ok && violatedConstraints.IsViolated {
+			jobRun.recordConstraintViolation(tableName, columnName, violatedConstraints)
+			switch violatedConstraints.Policy {
+			case ConstraintPolicyFail:
+				return constraintViolationError(tableName, columnName, violatedConstraints)
+			case ConstraintPolicyNull:
+				eventLoader.AddEmptyColumn(columnName)
+				continue
+			case ConstraintPolicyTruncate:
+				columnVal = violatedConstraints.TruncatedValue
+			default:
+				if violatedConstraints.ViolatedIdentifier != "" {
+					eventLoader.AddColumn(columnName, job.UploadSchema[tableName][columnName], violatedConstraints.ViolatedIdentifier)
+				} else {
 					eventLoader.AddEmptyColumn(columnName)
-					continue
 				}
-				columnVal = string(marshalledVal)
+				if err = jobRun.discardColumn(tableName, columnName, columnVal, columnData, violatedConstraints); err != nil {
+					return err
+				}
+				continue
 			}
-
-			eventLoader.AddColumn(columnName, job.UploadSchema[tableName][columnName], columnVal)
+		}
+		if ok && columnType != dataTypeInSchema {
+			newColumnVal, ok := HandleSchemaChange(dataTypeInSchema, columnType, columnVal)
+			if !ok {
+				eventLoader.AddEmptyColumn(columnName)
+				if err = jobRun.discardColumn(tableName, columnName, columnVal, columnData, &ConstraintsViolationT{}); err != nil {
+					return err
+				}
+				continue
+			}
+			if newColumnVal == nil {
+				eventLoader.AddEmptyColumn(columnName)
+				continue
+			}
+			columnVal = newColumnVal
 		}
 
-		// Completed parsing all columns, write single event to the file
-		err = eventLoader.Write()
-		if err != nil {
-			pkgLogger.Errorf("[WH]: Failed to write event: %v", err)
-			return loadFileUploadOutputs, err
+		// Special handling for JSON arrays
+		// TODO: Will this work for both BQ and RS?
+		if reflect.TypeOf(columnVal) == reflect.TypeOf(interfaceSliceSample) {
+			marshalledVal, err := json.Marshal(columnVal)
+			if err != nil {
+				pkgLogger.Errorf("[WH]: Error in marshalling []interface{} columnVal: %v", err)
+				eventLoader.AddEmptyColumn(columnName)
+				continue
+			}
+			columnVal = string(marshalledVal)
 		}
-		jobRun.tableEventCountMap[tableName]++
+
+		eventLoader.AddColumn(columnName, job.UploadSchema[tableName][columnName], columnVal)
 	}
-	timer.End()
 
-	pkgLogger.Debugf("[WH]: Process %v bytes from downloaded staging file: %s", lineBytesCounter, job.StagingFileLocation)
-	jobRun.counterStat("bytes_processed_in_staging_file").Count(lineBytesCounter)
-	for _, loadFile := range jobRun.outputFileWritersMap {
-		err = loadFile.Close()
-		if err != nil {
-			pkgLogger.Errorf("Error while closing load file %s : %v", loadFile.GetLoadFile().Name(), err)
-		}
+	// Completed parsing all columns, write single event to the file
+	err = eventLoader.Write()
+	if err != nil {
+		pkgLogger.Errorf("[WH]: Failed to write event: %v", err)
+		return err
 	}
-	loadFileUploadOutputs, err = jobRun.uploadLoadFilesToObjectStorage()
-	return loadFileUploadOutputs, err
+	jobRun.tableEventCountMap[tableName]++
+	return nil
 }
 
 func processClaimedUploadJob(claimedJob pgnotifier.ClaimT, workerIndex int) {
@@ -610,26 +667,37 @@ func runAsyncJob(asyncjob jobs.AsyncJobPayloadT) (AsyncJobRunResult, error) {
 	if err != nil {
 		return AsyncJobRunResult{Id: asyncjob.Id, Result: false}, err
 	}
-	whasyncjob := &jobs.WhAsyncJob{}
+	uploader := &asyncJobUploader{WhAsyncJob: &jobs.WhAsyncJob{}, destType: destType}
 
-	var metadata warehouseutils.DeleteByMetaData
-	err = json.Unmarshal(asyncjob.MetaData, &metadata)
-	if err != nil {
-		return AsyncJobRunResult{Id: asyncjob.Id, Result: false}, err
-	}
-	whManager.Setup(warehouse, whasyncjob)
+	whManager.Setup(warehouse, uploader)
 	defer whManager.Cleanup()
-	tableNames := []string{asyncjob.TableName}
-	if asyncjob.AsyncJobType == "deletebyjobrunid" {
+	switch asyncjob.AsyncJobType {
+	case jobs.DeleteByJobRunID:
 		pkgLogger.Info("[WH-Jobs]: Running DeleteByJobRunID on slave worker")
 
+		var metadata warehouseutils.DeleteByMetaData
+		err = json.Unmarshal(asyncjob.MetaData, &metadata)
+		if err != nil {
+			return AsyncJobRunResult{Id: asyncjob.Id, Result: false}, err
+		}
 		params := warehouseutils.DeleteByParams{
 			SourceId:  asyncjob.SourceID,
 			TaskRunId: metadata.TaskRunId,
 			JobRunId:  metadata.JobRunId,
 			StartTime: metadata.StartTime,
 		}
-		err = whManager.DeleteBy(tableNames, params)
+		err = whManager.DeleteBy([]string{asyncjob.TableName}, params)
+	case jobs.BackfillColumn:
+		pkgLogger.Infof("[WH-Jobs]: Running BackfillColumn of job: %s on slave worker", asyncjob.Id)
+		err = backfillColumn(asyncjob, warehouse, whManager)
+	case jobs.IdentityResolution:
+		pkgLogger.Infof("[WH-Jobs]: Running IdentityResolution of job: %s on slave worker", asyncjob.Id)
+		err = resolveIdentitiesInRange(asyncjob, warehouse, whManager, uploader)
+	case jobs.RewriteTable:
+		pkgLogger.Infof("[WH-Jobs]: Running RewriteTable of job: %s on slave worker", asyncjob.Id)
+		err = rewriteTable(asyncjob, warehouse, whManager, uploader)
+	default:
+		err = fmt.Errorf("unsupported async job type: %s", asyncjob.AsyncJobType)
 	}
 	asyncJobRunResult := AsyncJobRunResult{
 		Result: err == nil,
@@ -676,10 +744,15 @@ func setupSlave(ctx context.Context) error {
 	g, ctx := errgroup.WithContext(ctx)
 
 	slaveID := uuid.Must(uuid.NewV4()).String()
-	jobNotificationChannel := notifier.Subscribe(ctx, slaveID, noOfSlaveWorkerRoutines)
+	// draining the slave stops the claims, closing the channel once the claimed jobs are handed to the workers
+	claimCtx, stopClaiming := context.WithCancel(ctx)
+	defer stopClaiming()
+	slaveDrain.start(stopClaiming, noOfSlaveWorkerRoutines)
+	jobNotificationChannel := notifier.Subscribe(claimCtx, slaveID, noOfSlaveWorkerRoutines)
 	for workerIdx := 0; workerIdx <= noOfSlaveWorkerRoutines-1; workerIdx++ {
 		idx := workerIdx
 		g.Go(misc.WithBugsnagForWarehouse(func() error {
+			defer slaveDrain.workerStopped()
 			// create tags and timers
 			workerIdleTimer := warehouseutils.NewTimerStat(STATS_WORKER_IDLE_TIME, warehouseutils.Tag{Name: TAG_WORKERID, Value: fmt.Sprintf("%d", idx)})
 			workerIdleTimeStart := time.Now()
@@ -687,11 +760,13 @@ func setupSlave(ctx context.Context) error {
 				workerIdleTimer.Since(workerIdleTimeStart)
 				pkgLogger.Infof("[WH]: Successfully claimed job:%v by slave worker-%v-%v & job type %s", claimedJob.ID, idx, slaveID, claimedJob.JobType)
 
+				slaveDrain.jobStarted()
 				if claimedJob.JobType == jobs.AsyncJobType {
 					processClaimedAsyncJob(claimedJob)
 				} else {
 					processClaimedUploadJob(claimedJob, idx)
 				}
+				slaveDrain.jobFinished()
 
 				pkgLogger.Infof("[WH]: Successfully processed job:%v by slave worker-%v-%v", claimedJob.ID, idx, slaveID)
 				workerIdleTimeStart = time.Now()
@@ -705,11 +780,43 @@ func setupSlave(ctx context.Context) error {
 	return g.Wait()
 }
 
+// discardColumn routes the value of a column which cannot be loaded to the discards table
+func (jobRun *JobRunT) discardColumn(tableName, columnName string, columnVal interface{}, columnData DataT, violatedConstraints *ConstraintsViolationT) error {
+	discardsTable := jobRun.job.getDiscardsTable()
+	discardWriter, err := jobRun.GetWriter(discardsTable)
+	if err != nil {
+		return err
+	}
+	// add discardWriter to outputFileWritersMap
+	jobRun.outputFileWritersMap[discardsTable] = discardWriter
+
+	err = jobRun.handleDiscardTypes(tableName, columnName, columnVal, columnData, violatedConstraints, discardWriter)
+	if err != nil {
+		pkgLogger.Errorf("[WH]: Failed to write to discards: %v", err)
+	}
+	jobRun.tableEventCountMap[discardsTable]++
+	return nil
+}
+
+func (jobRun *JobRunT) recordConstraintViolation(tableName, columnName string, violatedConstraints *ConstraintsViolationT) {
+	if _, ok := jobRun.constraintViolations[tableName]; !ok {
+		jobRun.constraintViolations[tableName] = make(map[string]map[string]int)
+	}
+	if _, ok := jobRun.constraintViolations[tableName][columnName]; !ok {
+		jobRun.constraintViolations[tableName][columnName] = make(map[string]int)
+	}
+	jobRun.constraintViolations[tableName][columnName][violatedConstraints.Constraint]++
+}
+
+func constraintViolationError(tableName, columnName string, violatedConstraints *ConstraintsViolationT) error {
+	return fmt.Errorf("column %s of table %s violates %s constraint", columnName, tableName, violatedConstraints.Constraint)
+}
+
 func (jobRun *JobRunT) handleDiscardTypes(tableName, columnName string, columnVal interface{}, columnData DataT, violatedConstraints *ConstraintsViolationT, discardWriter warehouseutils.LoadFileWriterI) error {
 	job := jobRun.job
 	rowID, hasID := columnData[job.getColumnName("id")]
 	receivedAt, hasReceivedAt := columnData[job.getColumnName("received_at")]
-	if violatedConstraints.IsViolated {
+	if violatedConstraints.ViolatedIdentifier != "" {
 		if !hasID {
 			rowID = violatedConstraints.ViolatedIdentifier
 			hasID = true
@@ -0,0 +1,181 @@
+package warehouse
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rudderlabs/rudder-server/services/pgnotifier"
+	warehouseutils "github.com/rudderlabs/rudder-server/warehouse/utils"
+)
+
+// slaveDrainT tracks the drain of a slave ahead of scaling it down. A draining slave stops claiming jobs
+// and is drained once its workers finished the jobs they claimed.
+type slaveDrainT struct {
+	lock          sync.Mutex
+	draining      bool
+	stopClaiming  context.CancelFunc
+	activeWorkers int
+	inFlightJobs  int
+}
+
+// SlaveDrainStatusT is the response of the drain endpoint of the slaves
+type SlaveDrainStatusT struct {
+	Draining     bool `json:"draining"`
+	Drained      bool `json:"drained"`
+	InFlightJobs int  `json:"inFlightJobs"`
+}
+
+var slaveDrain slaveDrainT
+
+// start registers the cancellation of the claims of the slave, cancelling them right away if the drain was already requested
+func (drain *slaveDrainT) start(stopClaiming context.CancelFunc, workers int) {
+	drain.lock.Lock()
+	defer drain.lock.Unlock()
+	drain.stopClaiming = stopClaiming
+	drain.activeWorkers = workers
+	if drain.draining {
+		stopClaiming()
+	}
+}
+
+func (drain *slaveDrainT) drain() {
+	drain.lock.Lock()
+	defer drain.lock.Unlock()
+	if drain.draining {
+		return
+	}
+	pkgLogger.Infof("[WH]: Draining slave, waiting for %d in-flight jobs", drain.inFlightJobs)
+	drain.draining = true
+	if drain.stopClaiming != nil {
+		drain.stopClaiming()
+	}
+}
+
+func (drain *slaveDrainT) jobStarted() {
+	drain.lock.Lock()
+	defer drain.lock.Unlock()
+	drain.inFlightJobs++
+}
+
+func (drain *slaveDrainT) jobFinished() {
+	drain.lock.Lock()
+	defer drain.lock.Unlock()
+	drain.inFlightJobs--
+}
+
+func (drain *slaveDrainT) workerStopped() {
+	drain.lock.Lock()
+	defer drain.lock.Unlock()
+	drain.activeWorkers--
+	if drain.draining && drain.activeWorkers == 0 {
+		pkgLogger.Infof("[WH]: Slave drained")
+	}
+}
+
+func (drain *slaveDrainT) status() SlaveDrainStatusT {
+	drain.lock.Lock()
+	defer drain.lock.Unlock()
+	return SlaveDrainStatusT{
+		Draining:     drain.draining,
+		Drained:      drain.draining && drain.activeWorkers == 0,
+		InFlightJobs: drain.inFlightJobs,
+	}
+}
+
+// drainSlaveHandler starts draining the slave on POST and returns the progress of the drain, to be polled until drained before stopping the slave
+func drainSlaveHandler(w http.ResponseWriter, r *http.Request) {
+	pkgLogger.LogRequest(r)
+
+	switch r.Method {
+	case http.MethodPost:
+		slaveDrain.drain()
+	case http.MethodGet:
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	statusJSON, err := json.Marshal(slaveDrain.status())
+	if err != nil {
+		http.Error(w, "can't marshall drain status", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(statusJSON)
+}
+
+// slaveBacklogT is the latest backlog of the queue of the slaves, as seen by the master
+type slaveBacklogT struct {
+	lock              sync.RWMutex
+	queueStats        pgnotifier.QueueStatsT
+	recommendedSlaves int
+	updatedAt         time.Time
+}
+
+var slaveBacklog slaveBacklogT
+
+// recommendedSlaves is the number of slaves needed to keep Warehouse.slaveAutoscaling.jobsPerWorker jobs of the backlog
+// for every worker of the slaves, bounded by Warehouse.slaveAutoscaling.minSlaves and Warehouse.slaveAutoscaling.maxSlaves
+func recommendedSlaves(queueStats pgnotifier.QueueStatsT, workersPerSlave int) int {
+	jobsPerSlave := int64(workersPerSlave * slaveAutoscalingJobsPerWorker)
+	if jobsPerSlave < 1 {
+		jobsPerSlave = 1
+	}
+	backlog := queueStats.WaitingJobs + queueStats.ExecutingJobs
+	slaves := int((backlog + jobsPerSlave - 1) / jobsPerSlave)
+	if slaves < slaveAutoscalingMinSlaves {
+		slaves = slaveAutoscalingMinSlaves
+	}
+	if slaveAutoscalingMaxSlaves > 0 && slaves > slaveAutoscalingMaxSlaves {
+		slaves = slaveAutoscalingMaxSlaves
+	}
+	return slaves
+}
+
+// monitorSlaveBacklog periodically exports the backlog of the queue of the slaves and the number of slaves recommended to run it, for autoscalers
+func monitorSlaveBacklog(ctx context.Context) {
+	moduleTag := warehouseutils.Tag{Name: "module", Value: "pgnotifier"}
+	waitingJobsStat := warehouseutils.NewGaugeStat("wh_notifier_waiting_jobs", moduleTag)
+	executingJobsStat := warehouseutils.NewGaugeStat("wh_notifier_executing_jobs", moduleTag)
+	oldestWaitingJobAgeStat := warehouseutils.NewGaugeStat("wh_notifier_oldest_waiting_job_age", moduleTag)
+	recommendedSlavesStat := warehouseutils.NewGaugeStat("wh_recommended_slaves", moduleTag)
+
+	for {
+		queueStats, err := notifier.GetQueueStats(ctx)
+		if err != nil {
+			pkgLogger.Errorf("[WH]: Failed to get the backlog of the slaves: %v", err)
+		} else {
+			recommended := recommendedSlaves(queueStats, noOfSlaveWorkerRoutines)
+			waitingJobsStat.Gauge(queueStats.WaitingJobs)
+			executingJobsStat.Gauge(queueStats.ExecutingJobs)
+			oldestWaitingJobAgeStat.Gauge(queueStats.OldestWaitingJobAge.Seconds())
+			recommendedSlavesStat.Gauge(recommended)
+
+			slaveBacklog.lock.Lock()
+			slaveBacklog.queueStats = queueStats
+			slaveBacklog.recommendedSlaves = recommended
+			slaveBacklog.updatedAt = time.Now()
+			slaveBacklog.lock.Unlock()
+		}
+
+		select {
+		case <-ctx.Done():
+			pkgLogger.Infof("context is cancelled, stopped monitoring the backlog of the slaves")
+			return
+		case <-time.After(slaveAutoscalingStatsInterval):
+		}
+	}
+}
+
+// getRecommendedSlaves returns the number of slaves recommended for the latest backlog, false if it is not known yet
+func getRecommendedSlaves() (pgnotifier.QueueStatsT, int, bool) {
+	slaveBacklog.lock.RLock()
+	defer slaveBacklog.lock.RUnlock()
+	if slaveBacklog.updatedAt.IsZero() {
+		return pgnotifier.QueueStatsT{}, 0, false
+	}
+	return slaveBacklog.queueStats, slaveBacklog.recommendedSlaves, true
+}
@@ -0,0 +1,87 @@
+//go:build !warehouse_integration
+
+package warehouse
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/rudderlabs/rudder-server/services/pgnotifier"
+)
+
+var _ = Describe("SlaveAutoscaling", func() {
+	BeforeEach(func() {
+		slaveDrain = slaveDrainT{}
+		slaveAutoscalingJobsPerWorker = 1
+		slaveAutoscalingMinSlaves = 1
+		slaveAutoscalingMaxSlaves = 0
+	})
+
+	DescribeTable("Recommended slaves", func(waiting, executing int64, jobsPerWorker, maxSlaves, expected int) {
+		slaveAutoscalingJobsPerWorker = jobsPerWorker
+		slaveAutoscalingMaxSlaves = maxSlaves
+		queueStats := pgnotifier.QueueStatsT{WaitingJobs: waiting, ExecutingJobs: executing, OldestWaitingJobAge: time.Minute}
+		Expect(recommendedSlaves(queueStats, 4)).To(Equal(expected))
+	},
+		Entry("Empty queue keeps the minimum slaves", int64(0), int64(0), 1, 0, 1),
+		Entry("Backlog fitting the workers of a slave", int64(1), int64(3), 1, 0, 1),
+		Entry("Backlog exceeding the workers of a slave", int64(9), int64(4), 1, 0, 4),
+		Entry("Several jobs per worker", int64(9), int64(4), 2, 0, 2),
+		Entry("Bounded by the maximum slaves", int64(90), int64(4), 1, 5, 5),
+	)
+
+	It("Should stop claiming and wait for the in-flight jobs when draining", func() {
+		claimCtx, stopClaiming := context.WithCancel(context.Background())
+		defer stopClaiming()
+		slaveDrain.start(stopClaiming, 2)
+		slaveDrain.jobStarted()
+		Expect(slaveDrain.status()).To(Equal(SlaveDrainStatusT{InFlightJobs: 1}))
+
+		slaveDrain.drain()
+		Expect(claimCtx.Err()).To(Equal(context.Canceled))
+		Expect(slaveDrain.status()).To(Equal(SlaveDrainStatusT{Draining: true, InFlightJobs: 1}))
+
+		slaveDrain.workerStopped()
+		slaveDrain.jobFinished()
+		Expect(slaveDrain.status()).To(Equal(SlaveDrainStatusT{Draining: true}), "drained once all the workers stopped")
+		slaveDrain.workerStopped()
+		Expect(slaveDrain.status()).To(Equal(SlaveDrainStatusT{Draining: true, Drained: true}))
+	})
+
+	It("Should not claim jobs when drained before starting", func() {
+		slaveDrain.drain()
+		claimCtx, stopClaiming := context.WithCancel(context.Background())
+		defer stopClaiming()
+		slaveDrain.start(stopClaiming, 1)
+		Expect(claimCtx.Err()).To(Equal(context.Canceled))
+	})
+
+	It("Should drain through the endpoint of the slave", func() {
+		request := func(method string) (*httptest.ResponseRecorder, SlaveDrainStatusT) {
+			w := httptest.NewRecorder()
+			drainSlaveHandler(w, httptest.NewRequest(method, "/v1/warehouse/slave/drain", http.NoBody))
+			var status SlaveDrainStatusT
+			if w.Code == http.StatusOK {
+				Expect(json.Unmarshal(w.Body.Bytes(), &status)).To(Succeed())
+			}
+			return w, status
+		}
+
+		w, status := request(http.MethodGet)
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(status.Draining).To(BeFalse(), "getting the status does not drain the slave")
+
+		w, status = request(http.MethodPost)
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(status).To(Equal(SlaveDrainStatusT{Draining: true, Drained: true}))
+
+		w, _ = request(http.MethodDelete)
+		Expect(w.Code).To(Equal(http.StatusMethodNotAllowed))
+	})
+})
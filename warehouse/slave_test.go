@@ -3,9 +3,18 @@
 package warehouse
 
 import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/require"
+
+	"github.com/rudderlabs/rudder-server/utils/logger"
+	"github.com/rudderlabs/rudder-server/utils/timeutil"
+	warehouseutils "github.com/rudderlabs/rudder-server/warehouse/utils"
 )
 
 func TestPickupStagingFileBucket(t *testing.T) {
@@ -45,3 +54,81 @@ func TestPickupStagingFileBucket(t *testing.T) {
 		require.Equal(t, got, input.expected)
 	}
 }
+
+func TestColumnarStagingFileLoadFiles(t *testing.T) {
+	warehouseutils.Init()
+	pkgLogger = logger.NOP
+
+	events := []string{
+		`{"metadata":{"table":"tracks","columns":{"id":"string","count":"int","props":"string","price":"float","received_at":"datetime","uuid_ts":"datetime"}},"data":{"id":"1","count":2,"props":[1,"b"],"price":1.5,"received_at":"2022-10-01T00:00:00.000Z"}}`,
+		`{"metadata":{"table":"pages","columns":{"id":"string","url":"string","uuid_ts":"datetime"}},"data":{"id":"2","url":"https://rudderstack.com"}}`,
+		`{"metadata":{"table":"tracks","columns":{"id":"string","count":"string","price":"int","received_at":"datetime","uuid_ts":"datetime"}},"data":{"id":"3","count":"two","price":3,"received_at":"2022-10-01T00:00:01.000Z"}}`,
+	}
+	job := Payload{
+		DestinationType: "POSTGRES",
+		LoadFileType:    warehouseutils.LOAD_FILE_TYPE_CSV,
+		UploadSchema: map[string]map[string]string{
+			"tracks": {"id": "string", "count": "int", "props": "string", "price": "float", "received_at": "datetime", "uuid_ts": "datetime"},
+			"pages":  {"id": "string", "url": "string", "uuid_ts": "datetime"},
+		},
+	}
+	uuidTS := timeutil.Now()
+
+	// loadFiles processes the events with fn and returns the content of the load file of each table
+	loadFiles := func(stagingFilePath string, read func(jobRun *JobRunT) error) map[string]string {
+		jobRun := &JobRunT{
+			job:                  job,
+			stagingFilePath:      stagingFilePath,
+			uuidTS:               uuidTS,
+			outputFileWritersMap: make(map[string]warehouseutils.LoadFileWriterI),
+			tableEventCountMap:   make(map[string]int),
+			constraintViolations: make(map[string]map[string]map[string]int),
+		}
+		require.NoError(t, read(jobRun))
+
+		contents := make(map[string]string)
+		for tableName, writer := range jobRun.outputFileWritersMap {
+			require.NoError(t, writer.Close())
+			f, err := os.Open(writer.GetLoadFile().Name())
+			require.NoError(t, err)
+			gzipReader, err := gzip.NewReader(f)
+			require.NoError(t, err)
+			content, err := io.ReadAll(gzipReader)
+			require.NoError(t, err)
+			_ = f.Close()
+			contents[tableName] = string(content)
+		}
+		return contents
+	}
+	sortedTableColumnMap := job.getSortedColumnMapForAllTables()
+
+	dir := t.TempDir()
+	fromJSON := loadFiles(filepath.Join(dir, "staging.json.gz"), func(jobRun *JobRunT) error {
+		for _, event := range events {
+			var batchRouterEvent BatchRouterEventT
+			if err := json.Unmarshal([]byte(event), &batchRouterEvent); err != nil {
+				return err
+			}
+			if err := jobRun.processEvent(&batchRouterEvent, sortedTableColumnMap); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	stagingFilePath := filepath.Join(dir, "staging.parquet.tar")
+	writer, err := warehouseutils.CreateColumnarStagingFile(stagingFilePath)
+	require.NoError(t, err)
+	for _, event := range events {
+		require.NoError(t, writer.WriteEvent([]byte(event)))
+	}
+	require.NoError(t, writer.Close())
+	fromColumnar := loadFiles(stagingFilePath, func(jobRun *JobRunT) error {
+		return readColumnarStagingFile(stagingFilePath, func(batchRouterEvent *BatchRouterEventT) error {
+			return jobRun.processEvent(batchRouterEvent, sortedTableColumnMap)
+		})
+	})
+
+	require.Len(t, fromJSON, 3) // tracks, pages and the discards of the string count of tracks
+	require.Equal(t, fromJSON, fromColumnar)
+}
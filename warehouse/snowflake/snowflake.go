@@ -99,16 +99,11 @@ var dataTypesMapToRudder = map[string]string{
 	"VARIANT":          "json",
 }
 
-var primaryKeyMap = map[string]string{
-	usersTable:      "ID",
-	identifiesTable: "ID",
-	discardsTable:   "ROW_ID",
-}
-
-var partitionKeyMap = map[string]string{
-	usersTable:      `"ID"`,
-	identifiesTable: `"ID"`,
-	discardsTable:   `"ROW_ID", "COLUMN_NAME", "TABLE_NAME"`,
+// primaryKeyMap are the columns deduplicating the rows of the tables in the merge load mode, defaulting to ID
+var primaryKeyMap = map[string][]string{
+	usersTable:      {"ID"},
+	identifiesTable: {"ID"},
+	discardsTable:   {"ROW_ID", "COLUMN_NAME", "TABLE_NAME"},
 }
 
 var (
@@ -250,6 +245,21 @@ func (sf *HandleT) DeleteBy(tableNames []string, params warehouseutils.DeleteByP
 	return nil
 }
 
+// PruneTable deletes the rows of the table older than the retention, one window of time at a time
+func (sf *HandleT) PruneTable(ctx context.Context, tableName string, params warehouseutils.RetentionParamsT) (warehouseutils.RetentionStatsT, error) {
+	pkgLogger.Infof("SF: Pruning rows with %s before %s in table %s for SF:%s", params.Column, params.Before, tableName, sf.Warehouse.Destination.ID)
+	table := fmt.Sprintf(`"%s"."%s"`, sf.Namespace, tableName)
+	batchedDelete := warehouseutils.BatchedDeleteT{
+		Db:          sf.Db,
+		OldestQuery: fmt.Sprintf(`SELECT MIN(%q) FROM %s`, params.Column, table),
+		DeleteQuery: fmt.Sprintf(`DELETE FROM %[1]s WHERE %[2]q >= ? AND %[2]q < ?`, table, params.Column),
+		TimeArg: func(t time.Time) interface{} {
+			return t.UTC().Format(time.RFC3339)
+		},
+	}
+	return batchedDelete.Run(ctx, params)
+}
+
 func (sf *HandleT) loadTable(tableName string, tableSchemaInUpload warehouseutils.TableSchemaT, dbHandle *sql.DB, skipClosingDBSession bool) (tableLoadResp tableLoadRespT, err error) {
 	pkgLogger.Infof("SF: Starting load for table:%s\n", tableName)
 
@@ -308,15 +318,22 @@ func (sf *HandleT) loadTable(tableName string, tableSchemaInUpload warehouseutil
 		return
 	}
 
-	primaryKey := "ID"
-	if column, ok := primaryKeyMap[tableName]; ok {
-		primaryKey = column
+	if sf.Warehouse.LoadMode(warehouseutils.LoadModeMerge) == warehouseutils.LoadModeAppend {
+		sqlStatement = fmt.Sprintf(`INSERT INTO %[3]s."%[1]s" (%[4]s) SELECT %[4]s FROM %[3]s."%[2]s"`, tableName, stagingTableName, schemaIdentifier, sortedColumnNames)
+		pkgLogger.Infof("SF: Appending records for table:%s using staging table: %s\n", tableName, sqlStatement)
+		if _, err = dbHandle.Exec(sqlStatement); err != nil {
+			pkgLogger.Errorf("SF: Error appending records from staging table: %v\n", err)
+			return
+		}
+		pkgLogger.Infof("SF: Complete load for table:%s\n", tableName)
+		return
 	}
 
-	partitionKey := `"ID"`
-	if column, ok := partitionKeyMap[tableName]; ok {
-		partitionKey = column
-	}
+	primaryKeys := sf.primaryKeys(tableName)
+	primaryJoinClause := warehouseutils.JoinWithFormatting(primaryKeys, func(_ int, key string) string {
+		return fmt.Sprintf(`original."%[1]s" = staging."%[1]s"`, key)
+	}, " AND ")
+	partitionKey := warehouseutils.DoubleQuoteAndJoinByComma(primaryKeys)
 
 	stagingColumnNames := warehouseutils.JoinWithFormatting(strKeys, func(_ int, name string) string {
 		return fmt.Sprintf(`staging."%s"`, name)
@@ -325,35 +342,30 @@ func (sf *HandleT) loadTable(tableName string, tableSchemaInUpload warehouseutil
 		return fmt.Sprintf(`original."%[1]s" = staging."%[1]s"`, name)
 	}, ",")
 
-	var additionalJoinClause string
-	if tableName == discardsTable {
-		additionalJoinClause = fmt.Sprintf(`AND original."%[1]s" = staging."%[1]s" AND original."%[2]s" = staging."%[2]s"`, "TABLE_NAME", "COLUMN_NAME")
-	}
-
 	keepLatestRecordOnDedup := sf.Uploader.ShouldOnDedupUseNewRecord()
 
 	if keepLatestRecordOnDedup {
-		sqlStatement = fmt.Sprintf(`MERGE INTO %[9]s."%[1]s" AS original
+		sqlStatement = fmt.Sprintf(`MERGE INTO %[8]s."%[1]s" AS original
 									USING (
 										SELECT * FROM (
-											SELECT *, row_number() OVER (PARTITION BY %[8]s ORDER BY RECEIVED_AT DESC) AS _rudder_staging_row_number FROM %[9]s."%[2]s"
+											SELECT *, row_number() OVER (PARTITION BY %[7]s ORDER BY RECEIVED_AT DESC) AS _rudder_staging_row_number FROM %[8]s."%[2]s"
 										) AS q WHERE _rudder_staging_row_number = 1
 									) AS staging
-									ON (original."%[3]s" = staging."%[3]s" %[7]s)
+									ON (%[3]s)
 									WHEN MATCHED THEN
 									UPDATE SET %[6]s
 									WHEN NOT MATCHED THEN
-									INSERT (%[4]s) VALUES (%[5]s)`, tableName, stagingTableName, primaryKey, sortedColumnNames, stagingColumnNames, columnsWithValues, additionalJoinClause, partitionKey, schemaIdentifier)
+									INSERT (%[4]s) VALUES (%[5]s)`, tableName, stagingTableName, primaryJoinClause, sortedColumnNames, stagingColumnNames, columnsWithValues, partitionKey, schemaIdentifier)
 	} else {
-		sqlStatement = fmt.Sprintf(`MERGE INTO %[8]s."%[1]s" AS original
+		sqlStatement = fmt.Sprintf(`MERGE INTO %[7]s."%[1]s" AS original
 										USING (
 											SELECT * FROM (
-												SELECT *, row_number() OVER (PARTITION BY %[7]s ORDER BY RECEIVED_AT DESC) AS _rudder_staging_row_number FROM %[8]s."%[2]s"
+												SELECT *, row_number() OVER (PARTITION BY %[6]s ORDER BY RECEIVED_AT DESC) AS _rudder_staging_row_number FROM %[7]s."%[2]s"
 											) AS q WHERE _rudder_staging_row_number = 1
 										) AS staging
-										ON (original."%[3]s" = staging."%[3]s" %[6]s)
+										ON (%[3]s)
 										WHEN NOT MATCHED THEN
-										INSERT (%[4]s) VALUES (%[5]s)`, tableName, stagingTableName, primaryKey, sortedColumnNames, stagingColumnNames, additionalJoinClause, partitionKey, schemaIdentifier)
+										INSERT (%[4]s) VALUES (%[5]s)`, tableName, stagingTableName, primaryJoinClause, sortedColumnNames, stagingColumnNames, partitionKey, schemaIdentifier)
 	}
 
 	pkgLogger.Infof("SF: Dedup records for table:%s using staging table: %s\n", tableName, sqlStatement)
@@ -367,6 +379,15 @@ func (sf *HandleT) loadTable(tableName string, tableSchemaInUpload warehouseutil
 	return
 }
 
+// primaryKeys returns the columns deduplicating the rows of tableName in the merge load mode
+func (sf *HandleT) primaryKeys(tableName string) []string {
+	keys, ok := primaryKeyMap[tableName]
+	if !ok {
+		keys = []string{"ID"}
+	}
+	return sf.Warehouse.MergePrimaryKeys(tableName, keys)
+}
+
 func (sf *HandleT) LoadIdentityMergeRulesTable() (err error) {
 	pkgLogger.Infof("SF: Starting load for table:%s\n", identityMergeRulesTable)
 
@@ -659,7 +680,35 @@ func (sf *HandleT) AddColumn(tableName, columnName, columnType string) (err erro
 	return err
 }
 
-func (*HandleT) AlterColumn(_, _, _ string) (err error) {
+// AlterColumn changes the type of the column by swapping it with a backfilled shadow column,
+// as snowflake does not support changing the type of columns other than increasing the length of varchars
+func (sf *HandleT) AlterColumn(tableName, columnName, columnType string) (response warehouseutils.AlterColumnResponseT, err error) {
+	// text is stored as varchar in snowflake same as string
+	dataType, ok := dataTypesMap[columnType]
+	if !ok {
+		return
+	}
+	shadowColumnName := columnName + warehouseutils.ToProviderCase(warehouseutils.SNOWFLAKE, warehouseutils.ShadowColumnSuffix)
+	backupColumnName := columnName + warehouseutils.ToProviderCase(warehouseutils.SNOWFLAKE, warehouseutils.BackupColumnSuffix)
+	queries := warehouseutils.ShadowColumnQueries(
+		fmt.Sprintf(`%s."%s"`, sf.schemaIdentifier(), tableName),
+		fmt.Sprintf(`"%s"`, columnName),
+		fmt.Sprintf(`"%s"`, shadowColumnName),
+		fmt.Sprintf(`"%s"`, backupColumnName),
+		dataType,
+		fmt.Sprintf(`CAST("%s" AS %s)`, columnName, dataType),
+		warehouseutils.GetShadowColumnState(sf.Uploader.GetTableSchemaInWarehouse(tableName), columnName, shadowColumnName, backupColumnName),
+	)
+	for _, sqlStatement := range queries {
+		pkgLogger.Infof("SF: Altering column in snowflake for %s:%s : %v", sf.Warehouse.Namespace, sf.Warehouse.Destination.ID, sqlStatement)
+		if _, err = sf.Db.Exec(sqlStatement); err != nil {
+			return
+		}
+	}
+	response = warehouseutils.AlterColumnResponseT{
+		Strategy: warehouseutils.AlterColumnShadowColumn,
+		Queries:  queries,
+	}
 	return
 }
 
@@ -825,6 +874,16 @@ func (sf *HandleT) Setup(warehouse warehouseutils.Warehouse, uploader warehouseu
 	return err
 }
 
+// SetupDryRun sets up the warehouse like Setup, running statements on db instead of connecting to snowflake
+func (sf *HandleT) SetupDryRun(warehouse warehouseutils.Warehouse, uploader warehouseutils.UploaderI, db *sql.DB) {
+	sf.Warehouse = warehouse
+	sf.Namespace = warehouse.Namespace
+	sf.CloudProvider = warehouseutils.SnowflakeCloudProvider(warehouse.Destination.Config)
+	sf.Uploader = uploader
+	sf.ObjectStorage = warehouseutils.ObjectStorageType(warehouseutils.SNOWFLAKE, warehouse.Destination.Config, sf.Uploader.UseRudderStorage())
+	sf.Db = db
+}
+
 func (sf *HandleT) TestConnection(warehouse warehouseutils.Warehouse) (err error) {
 	sf.Warehouse = warehouse
 	sf.Db, err = Connect(sf.getConnectionCredentials(OptionalCredsT{}))
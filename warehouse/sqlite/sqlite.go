@@ -0,0 +1,621 @@
+package sqlite
+
+import (
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rudderlabs/rudder-server/config"
+	"github.com/rudderlabs/rudder-server/services/filemanager"
+	"github.com/rudderlabs/rudder-server/utils/logger"
+	"github.com/rudderlabs/rudder-server/utils/misc"
+	"github.com/rudderlabs/rudder-server/warehouse/client"
+	warehouseutils "github.com/rudderlabs/rudder-server/warehouse/utils"
+
+	_ "modernc.org/sqlite"
+)
+
+var (
+	pkgLogger          logger.Logger
+	busyTimeout        time.Duration
+	enableDeleteByJobs bool
+	baseDirectory      string
+)
+
+const (
+	databaseDirectory = "databaseDirectory"
+)
+
+const (
+	provider              = warehouseutils.SQLITE
+	tableNameLimit        = 127
+	driverName            = "sqlite"
+	databaseFileExtension = ".db"
+)
+
+var rudderDataTypesMapToSQLite = map[string]string{
+	"int":      "INTEGER",
+	"float":    "REAL",
+	"string":   "TEXT",
+	"datetime": "DATETIME",
+	"boolean":  "BOOLEAN",
+	"json":     "JSON",
+}
+
+var sqliteDataTypesMapToRudder = map[string]string{
+	"INTEGER":   "int",
+	"INT":       "int",
+	"BIGINT":    "int",
+	"REAL":      "float",
+	"DOUBLE":    "float",
+	"NUMERIC":   "float",
+	"TEXT":      "string",
+	"VARCHAR":   "string",
+	"DATETIME":  "datetime",
+	"TIMESTAMP": "datetime",
+	"BOOLEAN":   "boolean",
+	"JSON":      "json",
+}
+
+// primaryKeyMap are the columns deduplicating the rows of the tables in the merge load mode, defaulting to id
+var primaryKeyMap = map[string][]string{
+	warehouseutils.UsersTable:      {"id"},
+	warehouseutils.IdentifiesTable: {"id"},
+	warehouseutils.DiscardsTable:   {"row_id", "column_name", "table_name"},
+}
+
+type HandleT struct {
+	Db             *sql.DB
+	Namespace      string
+	ObjectStorage  string
+	Warehouse      warehouseutils.Warehouse
+	Uploader       warehouseutils.UploaderI
+	ConnectTimeout time.Duration
+}
+
+// CredentialsT locates the database file of a namespace.
+// Every namespace is stored in its own database file inside Directory, since SQLite has no schemas.
+type CredentialsT struct {
+	Directory   string
+	Namespace   string
+	BusyTimeout time.Duration
+}
+
+// DatabasePath returns the path of the database file storing namespace
+func DatabasePath(directory, namespace string) string {
+	return filepath.Join(directory, namespace+databaseFileExtension)
+}
+
+// DatabaseDirectory returns the directory of the database files of a destination, confined to the base directory
+// configured on the server, since the directory configured in the destination is not trusted.
+// The destination is disabled unless the base directory is configured.
+func DatabaseDirectory(base, directory string) (string, error) {
+	if strings.TrimSpace(base) == "" {
+		return "", fmt.Errorf("sqlite destination is disabled : (Warehouse.sqlite.baseDirectory is not configured)")
+	}
+	if filepath.IsAbs(directory) {
+		return "", fmt.Errorf("sqlite database directory %q should be relative to the base directory", directory)
+	}
+	for _, element := range strings.FieldsFunc(directory, func(r rune) bool { return r == '/' || r == '\\' }) {
+		if element == ".." {
+			return "", fmt.Errorf("sqlite database directory %q should not leave the base directory", directory)
+		}
+	}
+	return filepath.Join(base, directory), nil
+}
+
+func Connect(cred CredentialsT) (*sql.DB, error) {
+	if strings.TrimSpace(cred.Directory) == "" {
+		return nil, fmt.Errorf("sqlite connection error : (database directory is not configured)")
+	}
+	if err := os.MkdirAll(cred.Directory, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("sqlite connection error : (%v)", err)
+	}
+	dsn := fmt.Sprintf("file:%s?_pragma=busy_timeout(%d)&_pragma=journal_mode(WAL)",
+		DatabasePath(cred.Directory, cred.Namespace),
+		cred.BusyTimeout/time.Millisecond,
+	)
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite connection error : (%v)", err)
+	}
+	// SQLite allows a single writer, sharing one connection avoids busy errors between the statements of a load
+	db.SetMaxOpenConns(1)
+	return db, nil
+}
+
+func Init() {
+	loadConfig()
+	pkgLogger = logger.NewLogger().Child("warehouse").Child("sqlite")
+}
+
+func loadConfig() {
+	config.RegisterDurationConfigVariable(30, &busyTimeout, true, time.Second, "Warehouse.sqlite.busyTimeout")
+	config.RegisterBoolConfigVariable(false, &enableDeleteByJobs, true, "Warehouse.sqlite.enableDeleteByJobs")
+	config.RegisterStringConfigVariable("", &baseDirectory, true, "Warehouse.sqlite.baseDirectory")
+}
+
+func (sl *HandleT) getConnectionCredentials() (CredentialsT, error) {
+	directory, err := DatabaseDirectory(baseDirectory, warehouseutils.GetConfigValue(databaseDirectory, sl.Warehouse))
+	if err != nil {
+		return CredentialsT{}, err
+	}
+	return CredentialsT{
+		Directory:   directory,
+		Namespace:   sl.Namespace,
+		BusyTimeout: busyTimeout,
+	}, nil
+}
+
+func ColumnsWithDataTypes(columns map[string]string) string {
+	var arr []string
+	for name, dataType := range columns {
+		arr = append(arr, fmt.Sprintf(`%q %s`, name, rudderDataTypesMapToSQLite[dataType]))
+	}
+	return strings.Join(arr, ",")
+}
+
+func (*HandleT) IsEmpty(_ warehouseutils.Warehouse) (empty bool, err error) {
+	return
+}
+
+func (sl *HandleT) DownloadLoadFiles(tableName string) ([]string, error) {
+	objects := sl.Uploader.GetLoadFilesMetadata(warehouseutils.GetLoadFilesOptionsT{Table: tableName})
+	storageProvider := warehouseutils.ObjectStorageType(sl.Warehouse.Destination.DestinationDefinition.Name, sl.Warehouse.Destination.Config, sl.Uploader.UseRudderStorage())
+	downloader, err := filemanager.DefaultFileManagerFactory.New(&filemanager.SettingsT{
+		Provider: storageProvider,
+		Config: misc.GetObjectStorageConfig(misc.ObjectStorageOptsT{
+			Provider:         storageProvider,
+			Config:           sl.Warehouse.Destination.Config,
+			UseRudderStorage: sl.Uploader.UseRudderStorage(),
+			WorkspaceID:      sl.Warehouse.Destination.WorkspaceID,
+		}),
+	})
+	if err != nil {
+		pkgLogger.Errorf("SL: Error in setting up a downloader for destionationID : %s Error : %v", sl.Warehouse.Destination.ID, err)
+		return nil, err
+	}
+	var fileNames []string
+	for _, object := range objects {
+		objectName, err := warehouseutils.GetObjectName(object.Location, sl.Warehouse.Destination.Config, sl.ObjectStorage)
+		if err != nil {
+			pkgLogger.Errorf("SL: Error in converting object location to object key for table:%s: %s,%v", tableName, object.Location, err)
+			return fileNames, err
+		}
+		tmpDirPath, err := misc.CreateTMPDIR()
+		if err != nil {
+			pkgLogger.Errorf("SL: Error in creating tmp directory for downloading load file for table:%s: %s, %v", tableName, object.Location, err)
+			return fileNames, err
+		}
+		objectPath := filepath.Join(tmpDirPath, misc.RudderWarehouseLoadUploadsTmp, fmt.Sprintf(`%s_%s_%d`, sl.Warehouse.Destination.DestinationDefinition.Name, sl.Warehouse.Destination.ID, time.Now().Unix()), objectName)
+		if err = os.MkdirAll(filepath.Dir(objectPath), os.ModePerm); err != nil {
+			pkgLogger.Errorf("SL: Error in making tmp directory for downloading load file for table:%s: %s, %v", tableName, object.Location, err)
+			return fileNames, err
+		}
+		objectFile, err := os.Create(objectPath)
+		if err != nil {
+			pkgLogger.Errorf("SL: Error in creating file in tmp directory for downloading load file for table:%s: %s, %v", tableName, object.Location, err)
+			return fileNames, err
+		}
+		fileNames = append(fileNames, objectFile.Name())
+		err = downloader.Download(context.TODO(), objectFile, objectName)
+		objectFile.Close()
+		if err != nil {
+			pkgLogger.Errorf("SL: Error in downloading file in tmp directory for downloading load file for table:%s: %s, %v", tableName, object.Location, err)
+			return fileNames, err
+		}
+	}
+	return fileNames, nil
+}
+
+func (sl *HandleT) loadTable(tableName string, tableSchemaInUpload warehouseutils.TableSchemaT) (err error) {
+	pkgLogger.Infof("SL: Starting load for table:%s", tableName)
+	fileNames, err := sl.DownloadLoadFiles(tableName)
+	defer misc.RemoveFilePaths(fileNames...)
+	if err != nil {
+		return
+	}
+	return sl.loadTableFromFiles(tableName, tableSchemaInUpload, fileNames)
+}
+
+// loadTableFromFiles copies the gzipped csv load files into a staging table and moves its rows into tableName.
+// In the merge load mode the rows sharing the primary keys of the staged rows are replaced by the staged row received last.
+func (sl *HandleT) loadTableFromFiles(tableName string, tableSchemaInUpload warehouseutils.TableSchemaT, fileNames []string) (err error) {
+	sortedColumnKeys := warehouseutils.SortColumnKeysFromColumnMap(tableSchemaInUpload)
+	quotedColumnNames := warehouseutils.DoubleQuoteAndJoinByComma(sortedColumnKeys)
+
+	txn, err := sl.Db.Begin()
+	if err != nil {
+		pkgLogger.Errorf("SL: Error while beginning a transaction in db for loading in table:%s: %v", tableName, err)
+		return
+	}
+	defer func() {
+		if err != nil {
+			if rollbackErr := txn.Rollback(); rollbackErr != nil {
+				pkgLogger.Errorf("SL: Error in rolling back transaction : %v", rollbackErr)
+			}
+		}
+	}()
+
+	// the staging table is temporary, so that it never outlives the connection
+	stagingTableName := warehouseutils.StagingTableName(provider, tableName, tableNameLimit)
+	sqlStatement := fmt.Sprintf(`CREATE TEMP TABLE %q AS SELECT %s FROM %q WHERE 0`, stagingTableName, quotedColumnNames, tableName)
+	pkgLogger.Debugf("SL: Creating staging table for table:%s at %s", tableName, sqlStatement)
+	if _, err = txn.Exec(sqlStatement); err != nil {
+		pkgLogger.Errorf("SL: Error creating staging table for table:%s: %v", tableName, err)
+		return
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(sortedColumnKeys)), ",")
+	stmt, err := txn.Prepare(fmt.Sprintf(`INSERT INTO %q (%s) VALUES (%s)`, stagingTableName, quotedColumnNames, placeholders))
+	if err != nil {
+		pkgLogger.Errorf("SL: Error while preparing statement for loading in staging table:%s: %v", stagingTableName, err)
+		return
+	}
+	defer stmt.Close()
+	for _, fileName := range fileNames {
+		if err = insertLoadFile(stmt, fileName, len(sortedColumnKeys)); err != nil {
+			pkgLogger.Errorf("SL: Error loading file %s into staging table:%s: %v", fileName, stagingTableName, err)
+			return
+		}
+	}
+
+	if sl.Warehouse.LoadMode(warehouseutils.LoadModeMerge) == warehouseutils.LoadModeAppend {
+		sqlStatement = fmt.Sprintf(`INSERT INTO %[1]q (%[2]s) SELECT %[2]s FROM %[3]q`, tableName, quotedColumnNames, stagingTableName)
+	} else {
+		primaryKeys := sl.primaryKeys(tableName)
+		joinClause := warehouseutils.JoinWithFormatting(primaryKeys, func(_ int, key string) string {
+			return fmt.Sprintf(`_source.%[2]q = %[1]q.%[2]q`, tableName, key)
+		}, " AND ")
+		sqlStatement = fmt.Sprintf(`DELETE FROM %[1]q WHERE EXISTS (SELECT 1 FROM %[2]q AS _source WHERE %[3]s)`, tableName, stagingTableName, joinClause)
+		pkgLogger.Infof("SL: Deduplicate records for table:%s using staging table: %s", tableName, sqlStatement)
+		if _, err = txn.Exec(sqlStatement); err != nil {
+			pkgLogger.Errorf("SL: Error deleting from original table for dedup: %v", err)
+			return
+		}
+		sqlStatement = fmt.Sprintf(`INSERT INTO %[1]q (%[2]s)
+									SELECT %[2]s FROM (
+										SELECT *, row_number() OVER (PARTITION BY %[4]s ORDER BY received_at DESC) AS _rudder_staging_row_number FROM %[3]q
+									) AS _ WHERE _rudder_staging_row_number = 1`, tableName, quotedColumnNames, stagingTableName, warehouseutils.DoubleQuoteAndJoinByComma(primaryKeys))
+	}
+	pkgLogger.Infof("SL: Inserting records for table:%s using staging table: %s", tableName, sqlStatement)
+	if _, err = txn.Exec(sqlStatement); err != nil {
+		pkgLogger.Errorf("SL: Error inserting into original table: %v", err)
+		return
+	}
+
+	if _, err = txn.Exec(fmt.Sprintf(`DROP TABLE %q`, stagingTableName)); err != nil {
+		pkgLogger.Errorf("SL: Error dropping staging table:%s: %v", stagingTableName, err)
+		return
+	}
+	if err = txn.Commit(); err != nil {
+		pkgLogger.Errorf("SL: Error while committing transaction for loading in table:%s: %v", tableName, err)
+		return
+	}
+	pkgLogger.Infof("SL: Complete load for table:%s", tableName)
+	return
+}
+
+// insertLoadFile inserts the rows of the gzipped csv load file with the prepared insert statement.
+func insertLoadFile(stmt *sql.Stmt, fileName string, columnCount int) error {
+	gzipFile, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer gzipFile.Close()
+	gzipReader, err := gzip.NewReader(gzipFile)
+	if err != nil {
+		return err
+	}
+	defer gzipReader.Close()
+
+	csvReader := csv.NewReader(gzipReader)
+	for {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(record) != columnCount {
+			return fmt.Errorf("load file CSV columns for a row mismatch: found %d, expected %d", len(record), columnCount)
+		}
+		recordInterface := make([]interface{}, len(record))
+		for i, value := range record {
+			if strings.TrimSpace(value) != "" {
+				recordInterface[i] = value
+			}
+		}
+		if _, err = stmt.Exec(recordInterface...); err != nil {
+			return err
+		}
+	}
+}
+
+// primaryKeys returns the columns deduplicating the rows of tableName in the merge load mode
+func (sl *HandleT) primaryKeys(tableName string) []string {
+	keys, ok := primaryKeyMap[tableName]
+	if !ok {
+		keys = []string{"id"}
+	}
+	return sl.Warehouse.MergePrimaryKeys(tableName, keys)
+}
+
+// Need to create a structure with delete parameters instead of simply adding a long list of params
+func (sl *HandleT) DeleteBy(tableNames []string, params warehouseutils.DeleteByParams) (err error) {
+	pkgLogger.Infof("SL: Cleaning up the following tables in sqlite for SL:%s : %+v", tableNames, params)
+	for _, tb := range tableNames {
+		sqlStatement := fmt.Sprintf(`DELETE FROM %q WHERE
+		context_sources_job_run_id <> ? AND
+		context_sources_task_run_id <> ? AND
+		context_source_id = ? AND
+		received_at < ?`,
+			tb,
+		)
+		pkgLogger.Infof("SL: Deleting rows in table in sqlite for SL:%s", sl.Warehouse.Destination.ID)
+		pkgLogger.Debugf("SL: Executing the sqlstatement  %v", sqlStatement)
+		if enableDeleteByJobs {
+			_, err = sl.Db.Exec(sqlStatement,
+				params.JobRunId,
+				params.TaskRunId,
+				params.SourceId,
+				params.StartTime)
+			if err != nil {
+				pkgLogger.Errorf("Error %s", err)
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// PruneTable deletes the rows of the table older than the retention, one window of time at a time
+func (sl *HandleT) PruneTable(ctx context.Context, tableName string, params warehouseutils.RetentionParamsT) (warehouseutils.RetentionStatsT, error) {
+	pkgLogger.Infof("SL: Pruning rows with %s before %s in table %s for SL:%s", params.Column, params.Before, tableName, sl.Warehouse.Destination.ID)
+	table := fmt.Sprintf(`%q`, tableName)
+	batchedDelete := warehouseutils.BatchedDeleteT{
+		Db:          sl.Db,
+		OldestQuery: fmt.Sprintf(`SELECT MIN(%q) FROM %s`, params.Column, table),
+		DeleteQuery: fmt.Sprintf(`DELETE FROM %[1]s WHERE %[2]q >= ? AND %[2]q < ?`, table, params.Column),
+		TimeArg: func(t time.Time) interface{} {
+			return t.UTC().Format(time.RFC3339)
+		},
+	}
+	return batchedDelete.Run(ctx, params)
+}
+
+// LoadUserTables loads the identifies and users tables. The users table keeps the latest row received for every user.
+func (sl *HandleT) LoadUserTables() (errorMap map[string]error) {
+	errorMap = map[string]error{warehouseutils.IdentifiesTable: nil}
+	pkgLogger.Infof("SL: Starting load for identifies and users tables")
+	err := sl.loadTable(warehouseutils.IdentifiesTable, sl.Uploader.GetTableSchemaInUpload(warehouseutils.IdentifiesTable))
+	if err != nil {
+		errorMap[warehouseutils.IdentifiesTable] = err
+		return
+	}
+
+	if len(sl.Uploader.GetTableSchemaInUpload(warehouseutils.UsersTable)) == 0 {
+		return
+	}
+	errorMap[warehouseutils.UsersTable] = nil
+	err = sl.loadTable(warehouseutils.UsersTable, sl.Uploader.GetTableSchemaInUpload(warehouseutils.UsersTable))
+	if err != nil {
+		errorMap[warehouseutils.UsersTable] = err
+	}
+	return
+}
+
+// CreateSchema creates the database file of the namespace, which is created on connecting
+func (sl *HandleT) CreateSchema() (err error) {
+	pkgLogger.Infof("SL: Creating database file for SL:%s : %s", sl.Warehouse.Destination.ID, DatabasePath(warehouseutils.GetConfigValue(databaseDirectory, sl.Warehouse), sl.Namespace))
+	_, err = sl.Db.Exec(`PRAGMA user_version`)
+	return
+}
+
+func (sl *HandleT) CreateTable(tableName string, columnMap map[string]string) (err error) {
+	sqlStatement := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %q ( %v )`, tableName, ColumnsWithDataTypes(columnMap))
+	pkgLogger.Infof("SL: Creating table in sqlite for SL:%s : %v", sl.Warehouse.Destination.ID, sqlStatement)
+	_, err = sl.Db.Exec(sqlStatement)
+	return
+}
+
+func (sl *HandleT) DropTable(tableName string) (err error) {
+	sqlStatement := fmt.Sprintf(`DROP TABLE %q`, tableName)
+	pkgLogger.Infof("SL: Dropping table in sqlite for SL:%s : %v", sl.Warehouse.Destination.ID, sqlStatement)
+	_, err = sl.Db.Exec(sqlStatement)
+	return
+}
+
+// AddColumn adds column:columnName with dataType columnType to the tableName.
+// SQLite does not support ADD COLUMN IF NOT EXISTS, so existing columns are skipped explicitly.
+func (sl *HandleT) AddColumn(tableName, columnName, columnType string) (err error) {
+	var exists bool
+	err = sl.Db.QueryRow(`SELECT EXISTS (SELECT 1 FROM pragma_table_info(?) WHERE name = ?)`, tableName, columnName).Scan(&exists)
+	if err != nil || exists {
+		return
+	}
+	sqlStatement := fmt.Sprintf(`ALTER TABLE %q ADD COLUMN %q %s`, tableName, columnName, rudderDataTypesMapToSQLite[columnType])
+	pkgLogger.Infof("SL: Adding column in sqlite for SL:%s : %v", sl.Warehouse.Destination.ID, sqlStatement)
+	_, err = sl.Db.Exec(sqlStatement)
+	return
+}
+
+// AlterColumn is a no-op since SQLite columns store values of any type regardless of their declared type
+func (*HandleT) AlterColumn(_, _, _ string) (warehouseutils.AlterColumnResponseT, error) {
+	return warehouseutils.AlterColumnResponseT{}, nil
+}
+
+func (sl *HandleT) TestConnection(warehouse warehouseutils.Warehouse) (err error) {
+	sl.Warehouse = warehouse
+	sl.Namespace = warehouse.Namespace
+	cred, err := sl.getConnectionCredentials()
+	if err != nil {
+		return
+	}
+	sl.Db, err = Connect(cred)
+	if err != nil {
+		return
+	}
+	defer sl.Db.Close()
+
+	ctx, cancel := context.WithTimeout(context.TODO(), sl.ConnectTimeout)
+	defer cancel()
+
+	err = sl.Db.PingContext(ctx)
+	if err == context.DeadlineExceeded {
+		return fmt.Errorf("connection testing timed out after %d sec", sl.ConnectTimeout/time.Second)
+	}
+	return err
+}
+
+func (sl *HandleT) Setup(warehouse warehouseutils.Warehouse, uploader warehouseutils.UploaderI) (err error) {
+	sl.Warehouse = warehouse
+	sl.Namespace = warehouse.Namespace
+	sl.Uploader = uploader
+	sl.ObjectStorage = warehouseutils.ObjectStorageType(warehouseutils.SQLITE, warehouse.Destination.Config, sl.Uploader.UseRudderStorage())
+
+	cred, err := sl.getConnectionCredentials()
+	if err != nil {
+		return err
+	}
+	sl.Db, err = Connect(cred)
+	return err
+}
+
+// SetupDryRun sets up the warehouse like Setup, running statements on db instead of connecting to the database file
+func (sl *HandleT) SetupDryRun(warehouse warehouseutils.Warehouse, uploader warehouseutils.UploaderI, db *sql.DB) {
+	sl.Warehouse = warehouse
+	sl.Namespace = warehouse.Namespace
+	sl.Uploader = uploader
+	sl.ObjectStorage = warehouseutils.ObjectStorageType(warehouseutils.SQLITE, warehouse.Destination.Config, sl.Uploader.UseRudderStorage())
+	sl.Db = db
+}
+
+// CrashRecover is a no-op since the staging tables are temporary and dropped along with the connection
+func (sl *HandleT) CrashRecover(warehouse warehouseutils.Warehouse) (err error) {
+	sl.Warehouse = warehouse
+	sl.Namespace = warehouse.Namespace
+	return
+}
+
+// FetchSchema queries the database file of the namespace and returns its schema
+func (sl *HandleT) FetchSchema(warehouse warehouseutils.Warehouse) (schema warehouseutils.SchemaT, err error) {
+	sl.Warehouse = warehouse
+	sl.Namespace = warehouse.Namespace
+	cred, err := sl.getConnectionCredentials()
+	if err != nil {
+		return
+	}
+	dbHandle, err := Connect(cred)
+	if err != nil {
+		return
+	}
+	defer dbHandle.Close()
+
+	schema = make(warehouseutils.SchemaT)
+	sqlStatement := `
+		SELECT
+		  m.name,
+		  p.name,
+		  p.type
+		FROM
+		  sqlite_master AS m
+		  JOIN pragma_table_info(m.name) AS p
+		WHERE
+		  m.type = 'table'
+		  AND m.name NOT LIKE 'sqlite_%'
+		  AND m.name NOT LIKE ?;
+		`
+	rows, err := dbHandle.Query(sqlStatement, fmt.Sprintf(`%s%%`, warehouseutils.StagingTablePrefix(provider)))
+	if err != nil {
+		pkgLogger.Errorf("SL: Error in fetching schema from sqlite destination:%v, query: %v", sl.Warehouse.Destination.ID, sqlStatement)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var tName, cName, cType string
+		if err = rows.Scan(&tName, &cName, &cType); err != nil {
+			pkgLogger.Errorf("SL: Error in processing fetched schema from sqlite destination:%v", sl.Warehouse.Destination.ID)
+			return
+		}
+		if _, ok := schema[tName]; !ok {
+			schema[tName] = make(map[string]string)
+		}
+		if datatype, ok := sqliteDataTypesMapToRudder[strings.ToUpper(cType)]; ok {
+			schema[tName][cName] = datatype
+		} else {
+			warehouseutils.WHCounterStat(warehouseutils.RUDDER_MISSING_DATATYPE, &sl.Warehouse, warehouseutils.Tag{Name: "datatype", Value: cType}).Count(1)
+		}
+	}
+	err = rows.Err()
+	return
+}
+
+func (sl *HandleT) LoadTable(tableName string) error {
+	return sl.loadTable(tableName, sl.Uploader.GetTableSchemaInUpload(tableName))
+}
+
+func (sl *HandleT) Cleanup() {
+	if sl.Db != nil {
+		sl.Db.Close()
+	}
+}
+
+func (*HandleT) LoadIdentityMergeRulesTable() (err error) {
+	return
+}
+
+func (*HandleT) LoadIdentityMappingsTable() (err error) {
+	return
+}
+
+func (*HandleT) DownloadIdentityRules(*misc.GZipWriter) (err error) {
+	return
+}
+
+func (sl *HandleT) GetTotalCountInTable(ctx context.Context, tableName string) (total int64, err error) {
+	sqlStatement := fmt.Sprintf(`SELECT count(*) FROM %q`, tableName)
+	err = sl.Db.QueryRowContext(ctx, sqlStatement).Scan(&total)
+	if err != nil {
+		pkgLogger.Errorf(`SL: Error getting total count in table %s:%s`, sl.Namespace, tableName)
+	}
+	return
+}
+
+func (sl *HandleT) Connect(warehouse warehouseutils.Warehouse) (client.Client, error) {
+	sl.Warehouse = warehouse
+	sl.Namespace = warehouse.Namespace
+	sl.ObjectStorage = warehouseutils.ObjectStorageType(
+		warehouseutils.SQLITE,
+		warehouse.Destination.Config,
+		misc.IsConfiguredToUseRudderObjectStorage(sl.Warehouse.Destination.Config),
+	)
+	cred, err := sl.getConnectionCredentials()
+	if err != nil {
+		return client.Client{}, err
+	}
+	dbHandle, err := Connect(cred)
+	if err != nil {
+		return client.Client{}, err
+	}
+
+	return client.Client{Type: client.SQLClient, SQL: dbHandle}, err
+}
+
+func (sl *HandleT) LoadTestTable(_, tableName string, payloadMap map[string]interface{}, _ string) (err error) {
+	sqlStatement := fmt.Sprintf(`INSERT INTO %q (%q, %q) VALUES (?, ?)`, tableName, "id", "val")
+	_, err = sl.Db.Exec(sqlStatement, payloadMap["id"], payloadMap["val"])
+	return
+}
+
+func (sl *HandleT) SetConnectionTimeout(timeout time.Duration) {
+	sl.ConnectTimeout = timeout
+}
@@ -0,0 +1,185 @@
+package sqlite
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rudderlabs/rudder-server/config"
+	backendconfig "github.com/rudderlabs/rudder-server/config/backend-config"
+	"github.com/rudderlabs/rudder-server/utils/logger"
+	warehouseutils "github.com/rudderlabs/rudder-server/warehouse/utils"
+)
+
+func TestMain(m *testing.M) {
+	config.Reset()
+	logger.Reset()
+	Init()
+	os.Exit(m.Run())
+}
+
+func setupHandle(t *testing.T, destinationConfig map[string]interface{}) *HandleT {
+	t.Helper()
+
+	baseDirectory = t.TempDir()
+	t.Cleanup(func() { baseDirectory = "" })
+	destinationConfig[databaseDirectory] = "analytics"
+	warehouse := warehouseutils.Warehouse{
+		Namespace: "sqlite_wh",
+		Type:      warehouseutils.SQLITE,
+		Destination: backendconfig.DestinationT{
+			ID:     "sqlite-destination",
+			Config: destinationConfig,
+		},
+	}
+	sl := &HandleT{
+		Warehouse: warehouse,
+		Namespace: warehouse.Namespace,
+	}
+	cred, err := sl.getConnectionCredentials()
+	require.NoError(t, err)
+	db, err := Connect(cred)
+	require.NoError(t, err)
+	sl.Db = db
+	t.Cleanup(sl.Cleanup)
+
+	require.NoError(t, sl.CreateSchema())
+	require.FileExists(t, DatabasePath(filepath.Join(baseDirectory, "analytics"), warehouse.Namespace))
+	return sl
+}
+
+func writeLoadFile(t *testing.T, records [][]string) string {
+	t.Helper()
+
+	fileName := filepath.Join(t.TempDir(), "load_file.csv.gz")
+	file, err := os.Create(fileName)
+	require.NoError(t, err)
+	gzipWriter := gzip.NewWriter(file)
+	require.NoError(t, csv.NewWriter(gzipWriter).WriteAll(records))
+	require.NoError(t, gzipWriter.Close())
+	require.NoError(t, file.Close())
+	return fileName
+}
+
+var tracksSchema = warehouseutils.TableSchemaT{
+	"id":          "string",
+	"event":       "string",
+	"received_at": "datetime",
+}
+
+func TestDatabaseDirectory(t *testing.T) {
+	base := t.TempDir()
+
+	_, err := DatabaseDirectory("", "analytics")
+	require.Error(t, err, "the destination should be disabled without a base directory")
+
+	for _, directory := range []string{"/var/lib/postgresql", "..", "../analytics", "analytics/../../etc", `analytics\..\..`} {
+		_, err = DatabaseDirectory(base, directory)
+		require.Error(t, err, directory)
+	}
+
+	for directory, expected := range map[string]string{
+		"":                  base,
+		"analytics":         filepath.Join(base, "analytics"),
+		"analytics/reports": filepath.Join(base, "analytics", "reports"),
+		"./analytics":       filepath.Join(base, "analytics"),
+	} {
+		actual, err := DatabaseDirectory(base, directory)
+		require.NoError(t, err, directory)
+		require.Equal(t, expected, actual, directory)
+	}
+}
+
+func TestSchemaLifecycle(t *testing.T) {
+	sl := setupHandle(t, map[string]interface{}{})
+
+	require.NoError(t, sl.CreateTable("tracks", tracksSchema))
+	require.NoError(t, sl.AddColumn("tracks", "revenue", "float"))
+	require.NoError(t, sl.AddColumn("tracks", "revenue", "float"))
+
+	schema, err := sl.FetchSchema(sl.Warehouse)
+	require.NoError(t, err)
+	require.Equal(t, warehouseutils.SchemaT{
+		"tracks": {
+			"id":          "string",
+			"event":       "string",
+			"received_at": "datetime",
+			"revenue":     "float",
+		},
+	}, schema)
+
+	require.NoError(t, sl.DropTable("tracks"))
+	schema, err = sl.FetchSchema(sl.Warehouse)
+	require.NoError(t, err)
+	require.Empty(t, schema)
+}
+
+func TestLoadTableFromFiles(t *testing.T) {
+	records := [][]string{
+		{"signed_up", "1", "2022-01-01T00:00:00Z"},
+		{"logged_in", "1", "2022-01-02T00:00:00Z"},
+		{"logged_in", "2", "2022-01-01T00:00:00Z"},
+	}
+
+	testCases := []struct {
+		name              string
+		destinationConfig map[string]interface{}
+		expectedEvents    map[string]string
+		expectedCount     int64
+	}{
+		{
+			name:              "merge load mode keeps the row received last",
+			destinationConfig: map[string]interface{}{},
+			expectedEvents:    map[string]string{"1": "logged_in", "2": "logged_in"},
+			expectedCount:     2,
+		},
+		{
+			name:              "append load mode keeps every row",
+			destinationConfig: map[string]interface{}{"loadMode": "append"},
+			expectedCount:     6,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			sl := setupHandle(t, tc.destinationConfig)
+			require.NoError(t, sl.CreateTable("tracks", tracksSchema))
+
+			// loading the same file twice must not duplicate the rows in the merge load mode
+			fileName := writeLoadFile(t, records)
+			require.NoError(t, sl.loadTableFromFiles("tracks", tracksSchema, []string{fileName}))
+			require.NoError(t, sl.loadTableFromFiles("tracks", tracksSchema, []string{fileName}))
+
+			count, err := sl.GetTotalCountInTable(context.Background(), "tracks")
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedCount, count)
+
+			for id, event := range tc.expectedEvents {
+				var got string
+				require.NoError(t, sl.Db.QueryRow(`SELECT event FROM tracks WHERE id = ?`, id).Scan(&got))
+				require.Equal(t, event, got)
+			}
+
+			schema, err := sl.FetchSchema(sl.Warehouse)
+			require.NoError(t, err)
+			require.Len(t, schema, 1, "staging tables must not be part of the schema")
+		})
+	}
+}
+
+func TestLoadTableFromFilesColumnMismatch(t *testing.T) {
+	sl := setupHandle(t, map[string]interface{}{})
+	require.NoError(t, sl.CreateTable("tracks", tracksSchema))
+
+	fileName := writeLoadFile(t, [][]string{{"signed_up", "1"}})
+	require.Error(t, sl.loadTableFromFiles("tracks", tracksSchema, []string{fileName}))
+
+	count, err := sl.GetTotalCountInTable(context.Background(), "tracks")
+	require.NoError(t, err)
+	require.Zero(t, count)
+}
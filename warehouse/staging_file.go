@@ -1,9 +1,18 @@
 package warehouse
 
 import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"os"
 	"time"
 
+	"github.com/rudderlabs/rudder-server/services/filemanager"
+	"github.com/rudderlabs/rudder-server/utils/misc"
 	warehouseutils "github.com/rudderlabs/rudder-server/warehouse/utils"
 )
 
@@ -25,7 +34,7 @@ func getFirstStagedEventAt(stagingFileID int64) (time.Time, error) {
 	return firstEventAt, err
 }
 
-func getTotalEventsStaged(startFileID, endFileID int64) (total int64, err error) {
+func getTotalEventsStaged(warehouse warehouseutils.Warehouse, startFileID, endFileID int64) (total int64, err error) {
 	sqlStatement := fmt.Sprintf(`
 		SELECT 
 		  sum(total_events) 
@@ -33,13 +42,116 @@ func getTotalEventsStaged(startFileID, endFileID int64) (total int64, err error)
 		  %[1]s 
 		WHERE 
 		  id >= %[2]v 
-		  AND id <= %[3]v;
+		  AND id <= %[3]v 
+		  %[4]s;
 `,
 		warehouseutils.WarehouseStagingFilesTable,
 		startFileID,
 		endFileID,
+		routedNamespaceSQL(warehouse, "namespace"),
 	)
 
 	err = dbHandle.QueryRow(sqlStatement).Scan(&total)
 	return total, err
 }
+
+var errStopReadingStagingFile = errors.New("stop reading staging file")
+
+// readColumnarStagingFile calls fn with each of the events of the columnar staging file, table by table
+func readColumnarStagingFile(filePath string, fn func(event *BatchRouterEventT) error) error {
+	stagingFileReader, err := warehouseutils.OpenColumnarStagingFile(filePath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = stagingFileReader.Close() }()
+
+	for {
+		tableReader, err := stagingFileReader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		err = tableReader.ReadRows(func(columns map[string]string, data map[string]interface{}) error {
+			return fn(&BatchRouterEventT{
+				Metadata: MetadataT{Table: tableReader.Table, Columns: columns},
+				Data:     data,
+			})
+		})
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// readStagingFile downloads the staging file and calls fn with each of its events, until fn returns false
+func readStagingFile(warehouse warehouseutils.Warehouse, stagingFile *StagingFileT, fn func(event *BatchRouterEventT) bool) error {
+	storageProvider := warehouseutils.ObjectStorageType(warehouse.Destination.DestinationDefinition.Name, warehouse.Destination.Config, stagingFile.UseRudderStorage)
+	downloader, err := filemanager.DefaultFileManagerFactory.New(&filemanager.SettingsT{
+		Provider: storageProvider,
+		Config: misc.GetObjectStorageConfig(misc.ObjectStorageOptsT{
+			Provider:                    storageProvider,
+			Config:                      warehouse.Destination.Config,
+			UseRudderStorage:            stagingFile.UseRudderStorage,
+			RudderStoragePrefixOverride: misc.GetRudderObjectStoragePrefix(),
+			WorkspaceID:                 warehouse.Destination.WorkspaceID,
+		}),
+	})
+	if err != nil {
+		return err
+	}
+
+	tmpDirPath, err := misc.CreateTMPDIR()
+	if err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(tmpDirPath, "staging-file.*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+	}()
+	if err = downloader.Download(context.TODO(), file, stagingFile.Location); err != nil {
+		return err
+	}
+	if stagingFile.StagingFileFormat == warehouseutils.StagingFileFormatParquet {
+		err = readColumnarStagingFile(file.Name(), func(event *BatchRouterEventT) error {
+			if !fn(event) {
+				return errStopReadingStagingFile
+			}
+			return nil
+		})
+		if errors.Is(err, errStopReadingStagingFile) {
+			return nil
+		}
+		return err
+	}
+	if _, err = file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = gzipReader.Close() }()
+
+	reader := bufio.NewReader(gzipReader)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			var event BatchRouterEventT
+			if jsonErr := json.Unmarshal(line, &event); jsonErr == nil && !fn(&event) {
+				return nil
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -118,7 +118,7 @@ func (job *UploadJobT) generateUploadSuccessMetrics() {
 	job.counterStat("total_rows_synced").Count(int(numUploadedEvents))
 
 	// Total staged events in the upload
-	numStagedEvents, err := getTotalEventsStaged(job.upload.StartStagingFileID, job.upload.EndStagingFileID)
+	numStagedEvents, err := getTotalEventsStaged(job.warehouse, job.upload.StartStagingFileID, job.upload.EndStagingFileID)
 	if err != nil {
 		pkgLogger.Errorf("[WH]: Failed to generate stage metrics: %s, Err: %v", job.warehouse.Identifier, err)
 		return
@@ -141,7 +141,7 @@ func (job *UploadJobT) generateUploadAbortedMetrics() {
 	job.counterStat("total_rows_synced").Count(int(numUploadedEvents))
 
 	// Total staged events in the upload
-	numStagedEvents, err := getTotalEventsStaged(job.upload.StartStagingFileID, job.upload.EndStagingFileID)
+	numStagedEvents, err := getTotalEventsStaged(job.warehouse, job.upload.StartStagingFileID, job.upload.EndStagingFileID)
 	if err != nil {
 		pkgLogger.Errorf("[WH]: Failed to generate stage metrics: %s, Err: %v", job.warehouse.Identifier, err)
 		return
@@ -0,0 +1,239 @@
+package warehouse
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/rudderlabs/rudder-server/utils/timeutil"
+	warehouseutils "github.com/rudderlabs/rudder-server/warehouse/utils"
+)
+
+var (
+	tableLoadSlotsLock sync.Mutex
+	tableLoadSlots     = map[string]*loadSlotsT{}
+)
+
+// loadSlotsT bounds the tables loaded in parallel. Its limit can change while slots are taken: loads holding a slot
+// keep it, while new loads wait until the slots in use drop below the new limit.
+type loadSlotsT struct {
+	mu    sync.Mutex
+	cond  *sync.Cond
+	limit int
+	inUse int
+}
+
+func newLoadSlots(limit int) *loadSlotsT {
+	slots := &loadSlotsT{limit: limit}
+	slots.cond = sync.NewCond(&slots.mu)
+	return slots
+}
+
+func (slots *loadSlotsT) setLimit(limit int) {
+	slots.mu.Lock()
+	defer slots.mu.Unlock()
+	slots.limit = limit
+	slots.cond.Broadcast()
+}
+
+func (slots *loadSlotsT) acquire() {
+	slots.mu.Lock()
+	defer slots.mu.Unlock()
+	for slots.inUse >= slots.limit {
+		slots.cond.Wait()
+	}
+	slots.inUse++
+}
+
+func (slots *loadSlotsT) release() {
+	slots.mu.Lock()
+	defer slots.mu.Unlock()
+	slots.inUse--
+	slots.cond.Broadcast()
+}
+
+// destinationLoadSlots returns the slots bounding the tables loaded in parallel in a destination, shared by all of its uploads
+func destinationLoadSlots(destinationID string, parallelLoads int) *loadSlotsT {
+	if parallelLoads < 1 {
+		parallelLoads = 1
+	}
+	tableLoadSlotsLock.Lock()
+	defer tableLoadSlotsLock.Unlock()
+	slots, ok := tableLoadSlots[destinationID]
+	if !ok {
+		slots = newLoadSlots(parallelLoads)
+		tableLoadSlots[destinationID] = slots
+		return slots
+	}
+	slots.setLimit(parallelLoads)
+	return slots
+}
+
+// TableRetriesPendingError is the outcome of exporting data when every table was exported or aborted, except the ones waiting for their own retries
+type TableRetriesPendingError struct {
+	tables        []string
+	nextRetryTime time.Time
+}
+
+func (e *TableRetriesPendingError) Error() string {
+	return fmt.Sprintf("tables %s failed to load and are retried independently, next at %s", strings.Join(e.tables, ", "), e.nextRetryTime.Format(time.RFC3339))
+}
+
+// TablesAbortedError is the outcome of exporting data when tables of the upload were aborted after exhausting their retries
+type TablesAbortedError struct {
+	tables []string
+}
+
+func (e *TablesAbortedError) Error() string {
+	return fmt.Sprintf("tables %s were aborted after failing to load %d times", strings.Join(e.tables, ", "), maxTableRetryAttempts)
+}
+
+// tablesAbortedError returns the tables of the upload which were aborted, nil if there are none
+func (job *UploadJobT) tablesAbortedError() (*TablesAbortedError, error) {
+	tableRetries, err := getTableUploadRetries(job.upload.ID)
+	if err != nil {
+		return nil, err
+	}
+	abortedErr := &TablesAbortedError{}
+	for tName, retry := range tableRetries {
+		if retry.status == TableUploadAborted {
+			abortedErr.tables = append(abortedErr.tables, tName)
+		}
+	}
+	if len(abortedErr.tables) == 0 {
+		return nil, nil
+	}
+	sort.Strings(abortedErr.tables)
+	return abortedErr, nil
+}
+
+// tableRetriesPendingError returns the tables of the upload waiting for their retries, nil if there are none
+func (job *UploadJobT) tableRetriesPendingError() *TableRetriesPendingError {
+	job.tableRetriesLock.Lock()
+	defer job.tableRetriesLock.Unlock()
+	if len(job.pendingTableRetries) == 0 {
+		return nil
+	}
+	retriesErr := &TableRetriesPendingError{}
+	for tName, nextRetryTime := range job.pendingTableRetries {
+		retriesErr.tables = append(retriesErr.tables, tName)
+		if retriesErr.nextRetryTime.IsZero() || nextRetryTime.Before(retriesErr.nextRetryTime) {
+			retriesErr.nextRetryTime = nextRetryTime
+		}
+	}
+	sort.Strings(retriesErr.tables)
+	return retriesErr
+}
+
+func (job *UploadJobT) addPendingTableRetry(tName string, nextRetryTime time.Time) {
+	job.tableRetriesLock.Lock()
+	defer job.tableRetriesLock.Unlock()
+	if job.pendingTableRetries == nil {
+		job.pendingTableRetries = make(map[string]time.Time)
+	}
+	job.pendingTableRetries[tName] = nextRetryTime
+}
+
+// retryTableLater schedules the retry of a table which failed to load, aborting the table once it was attempted maxTableRetryAttempts times.
+// Either way the failure of the table does not fail the other tables of the upload.
+func (job *UploadJobT) retryTableLater(tName string, loadErr error) (aborted bool, err error) {
+	tableUpload := NewTableUpload(job.upload.ID, tName)
+	attempt, nextRetryTime, err := tableUpload.scheduleRetry()
+	if err != nil {
+		return false, fmt.Errorf("scheduling retry of table %s failed with error: %v, after failing to load with error: %w", tName, err, loadErr)
+	}
+	if attempt >= int64(maxTableRetryAttempts) {
+		pkgLogger.Errorf("[WH]: Aborting table %s of upload %d after %d attempts: %v", tName, job.upload.ID, attempt, loadErr)
+		if err = tableUpload.setStatus(TableUploadAborted); err != nil {
+			return false, fmt.Errorf("aborting table %s failed with error: %v, after failing to load with error: %w", tName, err, loadErr)
+		}
+		job.counterStat("table_uploads_aborted", tag{name: "tableName", value: strings.ToLower(tName)}).Count(1)
+		return true, nil
+	}
+	pkgLogger.Infof("[WH]: Retrying table %s of upload %d at %s after attempt %d: %v", tName, job.upload.ID, nextRetryTime.Format(time.RFC3339), attempt, loadErr)
+	job.addPendingTableRetry(tName, nextRetryTime)
+	return false, nil
+}
+
+// setTableRetriesPending fails the upload until the next retry of its failed tables.
+// This does not count towards aborting the upload, since every table counts its own attempts.
+func (job *UploadJobT) setTableRetriesPending(state string, retriesErr *TableRetriesPendingError) error {
+	uploadErrors, err := extractAndUpdateUploadErrorsByState(job.upload.Error, state, retriesErr)
+	if err != nil {
+		return fmt.Errorf("unable to handle upload errors in job: %d by state: %s, err: %w", job.upload.ID, state, err)
+	}
+	serializedErr, _ := json.Marshal(&uploadErrors)
+
+	var metadata map[string]interface{}
+	if err := json.Unmarshal(job.upload.Metadata, &metadata); err != nil {
+		metadata = make(map[string]interface{})
+	}
+	metadata["nextRetryTime"] = retriesErr.nextRetryTime.Format(time.RFC3339)
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		metadataJSON = []byte("{}")
+	}
+
+	err = job.setUploadColumns(UploadColumnsOpts{Fields: []UploadColumnT{
+		{Column: "status", Value: state},
+		{Column: "metadata", Value: metadataJSON},
+		{Column: "error", Value: serializedErr},
+		{Column: "updated_at", Value: timeutil.Now()},
+	}})
+	if err != nil {
+		return fmt.Errorf("unable to change upload columns: %w", err)
+	}
+	job.upload.Status = state
+	job.upload.Error = serializedErr
+	job.upload.Metadata = metadataJSON
+	job.counterStat("table_retries_pending").Count(1)
+	return nil
+}
+
+// releaseLoadFiles deletes the load files of a table once exported or aborted, since the retries of the other tables of the upload do not need them.
+// The load files of a table waiting for its retry are kept.
+func (job *UploadJobT) releaseLoadFiles(tName string) {
+	sqlStatement := fmt.Sprintf(`
+		DELETE FROM
+		  %s
+		WHERE
+		  staging_file_id = ANY($1)
+		  AND table_name = $2 RETURNING location;
+`,
+		warehouseutils.WarehouseLoadFilesTable,
+	)
+	rows, err := job.dbHandle.Query(sqlStatement, pq.Array(job.stagingFileIDs), tName)
+	if err != nil {
+		pkgLogger.Errorf("[WH]: Failed to release load files of table %s for upload %d: %v", tName, job.upload.ID, err)
+		return
+	}
+	defer func() { _ = rows.Close() }()
+
+	var paths []string
+	for rows.Next() {
+		var location string
+		if err := rows.Scan(&location); err != nil {
+			pkgLogger.Errorf("[WH]: Failed to release load files of table %s for upload %d: %v", tName, job.upload.ID, err)
+			return
+		}
+		if u, err := url.Parse(location); err == nil && len(u.Path) > 1 {
+			paths = append(paths, u.Path[1:])
+		}
+	}
+	if err := rows.Err(); err != nil {
+		pkgLogger.Errorf("[WH]: Failed to release load files of table %s for upload %d: %v", tName, job.upload.ID, err)
+		return
+	}
+	// load files in the storage of the destination are left to its lifecycle rules, like the archiver does
+	if job.upload.UseRudderStorage && len(paths) > 0 {
+		if err := deleteFilesInStorage(paths); err != nil {
+			pkgLogger.Errorf("[WH]: Failed to delete load files of table %s for upload %d from rudder storage: %v", tName, job.upload.ID, err)
+		}
+	}
+}
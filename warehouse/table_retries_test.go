@@ -0,0 +1,61 @@
+//go:build !warehouse_integration
+
+package warehouse
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TableRetries", func() {
+	It("Should share the load slots of a destination between its uploads", func() {
+		slots := destinationLoadSlots("test-destinationID-slots", 2)
+		Expect(slots.limit).To(Equal(2))
+		Expect(destinationLoadSlots("test-destinationID-slots", 2)).To(BeIdenticalTo(slots))
+		Expect(destinationLoadSlots("test-other-destinationID-slots", 2)).NotTo(BeIdenticalTo(slots))
+
+		resized := destinationLoadSlots("test-destinationID-slots", 3)
+		Expect(resized).To(BeIdenticalTo(slots), "loads holding slots keep counting towards the limit")
+		Expect(resized.limit).To(Equal(3), "changes to the parallel loads of the destination take effect")
+	})
+
+	It("Should not exceed the parallel loads when the load slots are resized", func() {
+		slots := destinationLoadSlots("test-destinationID-resized-slots", 2)
+		slots.acquire()
+		slots.acquire()
+
+		destinationLoadSlots("test-destinationID-resized-slots", 1)
+		acquired := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			slots.acquire()
+			close(acquired)
+		}()
+
+		slots.release()
+		Consistently(acquired, 100*time.Millisecond).ShouldNot(BeClosed(), "a slot is still in use, which is the new limit")
+		slots.release()
+		Eventually(acquired).Should(BeClosed())
+
+		destinationLoadSlots("test-destinationID-resized-slots", 2)
+		slots.acquire()
+		Expect(slots.inUse).To(Equal(2))
+	})
+
+	It("Should wait for the earliest retry of the pending tables", func() {
+		job := &UploadJobT{}
+		Expect(job.tableRetriesPendingError()).To(BeNil())
+
+		now := time.Now()
+		job.addPendingTableRetry("tracks", now.Add(time.Hour))
+		job.addPendingTableRetry("pages", now.Add(time.Minute))
+
+		retriesErr := job.tableRetriesPendingError()
+		Expect(retriesErr).NotTo(BeNil())
+		Expect(retriesErr.tables).To(Equal([]string{"pages", "tracks"}))
+		Expect(retriesErr.nextRetryTime).To(Equal(now.Add(time.Minute)))
+		Expect(retriesErr.Error()).To(HavePrefix("tables pages, tracks failed to load and are retried independently"))
+	})
+})
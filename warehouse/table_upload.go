@@ -4,6 +4,9 @@ import (
 	"database/sql"
 	"fmt"
 	"strings"
+	"time"
+
+	"github.com/lib/pq"
 
 	"github.com/rudderlabs/rudder-server/utils/misc"
 	"github.com/rudderlabs/rudder-server/utils/timeutil"
@@ -20,6 +23,13 @@ type TableUploadT struct {
 	tableName string
 }
 
+// tableUploadRetryT is the retry state of a table upload which failed or was aborted
+type tableUploadRetryT struct {
+	status        string
+	attempt       int64
+	nextRetryTime time.Time
+}
+
 func NewTableUpload(uploadID int64, tableName string) *TableUploadT {
 	return &TableUploadT{uploadID: uploadID, tableName: tableName}
 }
@@ -257,3 +267,73 @@ func (tableUpload *TableUploadT) getNumEvents() (total int64, err error) {
 	err = dbHandle.QueryRow(sqlStatement).Scan(&total)
 	return total, err
 }
+
+// scheduleRetry counts a failed attempt of the table upload and sets when it is retried next, returning the attempts made so far.
+// The backoff mirrors DurationBeforeNextAttempt and is computed by the same statement incrementing the attempts,
+// so that concurrent failures of the table are counted correctly.
+func (tableUpload *TableUploadT) scheduleRetry() (attempt int64, nextRetryTime time.Time, err error) {
+	sqlStatement := fmt.Sprintf(`
+		UPDATE 
+		  %s 
+		SET 
+		  attempt = attempt + 1, 
+		  next_retry_time = $1 + LEAST(
+		    $2 * POWER(2, attempt), 
+		    $3
+		  ) * INTERVAL '1 second', 
+		  updated_at = $1 
+		WHERE 
+		  wh_upload_id = $4 
+		  AND table_name = $5 RETURNING attempt, 
+		  next_retry_time;
+`,
+		warehouseutils.WarehouseTableUploadsTable,
+	)
+	err = dbHandle.QueryRow(
+		sqlStatement,
+		timeutil.Now(),
+		minUploadBackoff.Seconds(),
+		maxUploadBackoff.Seconds(),
+		tableUpload.uploadID,
+		tableUpload.tableName,
+	).Scan(&attempt, &nextRetryTime)
+	return
+}
+
+// getTableUploadRetries returns the retry state of the table uploads of the upload which failed or were aborted
+func getTableUploadRetries(uploadID int64) (map[string]tableUploadRetryT, error) {
+	sqlStatement := fmt.Sprintf(`
+		SELECT 
+		  table_name, 
+		  status, 
+		  attempt, 
+		  next_retry_time 
+		FROM 
+		  %s 
+		WHERE 
+		  wh_upload_id = $1 
+		  AND status = ANY($2);
+`,
+		warehouseutils.WarehouseTableUploadsTable,
+	)
+	rows, err := dbHandle.Query(sqlStatement, uploadID, pq.Array([]string{TableUploadUpdatingSchemaFailed, TableUploadExportingFailed, TableUploadAborted}))
+	if err != nil {
+		return nil, fmt.Errorf("query: %s failed with Error : %w", sqlStatement, err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	tableRetries := make(map[string]tableUploadRetryT)
+	for rows.Next() {
+		var (
+			tableName     string
+			retry         tableUploadRetryT
+			nextRetryTime sql.NullTime
+		)
+		if err := rows.Scan(&tableName, &retry.status, &retry.attempt, &nextRetryTime); err != nil {
+			return nil, err
+		}
+		retry.nextRetryTime = nextRetryTime.Time
+		tableRetries[tableName] = retry
+	}
+	return tableRetries, rows.Err()
+}
@@ -6,6 +6,7 @@ import (
 	"context"
 	"errors"
 	"os"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -101,9 +102,56 @@ var _ = Describe("TableUpload", func() {
 				Expect(err).To(BeNil())
 			})
 
+			It("Scheduling retries", func() {
+				attempt, nextRetryTime, err := tu.scheduleRetry()
+				Expect(err).To(BeNil())
+				Expect(attempt).To(BeEquivalentTo(1))
+				Expect(nextRetryTime).To(BeTemporally(">", time.Now()))
+
+				tableRetries, err := getTableUploadRetries(uploadID)
+				Expect(err).To(BeNil())
+				Expect(tableRetries).To(HaveKey(tableName))
+				Expect(tableRetries[tableName].status).To(Equal(TableUploadExportingFailed))
+				Expect(tableRetries[tableName].attempt).To(BeEquivalentTo(1))
+				Expect(tableRetries[tableName].nextRetryTime).To(BeTemporally("~", nextRetryTime, time.Second))
+
+				attempt, nextRetryTime, err = tu.scheduleRetry()
+				Expect(err).To(BeNil())
+				Expect(attempt).To(BeEquivalentTo(2))
+				Expect(nextRetryTime).To(BeTemporally("~", time.Now().Add(DurationBeforeNextAttempt(2)), time.Minute))
+			})
+
+			It("Aborting tables after the maximum attempts", func() {
+				job := &UploadJobT{
+					upload:   &Upload{ID: uploadID},
+					dbHandle: pgResource.DB,
+				}
+				abortedErr, err := job.tablesAbortedError()
+				Expect(err).To(BeNil())
+				Expect(abortedErr).To(BeNil())
+
+				maxTableRetryAttempts = 3
+				aborted, err := job.retryTableLater(tableName, errors.New("test error"))
+				Expect(err).To(BeNil())
+				Expect(aborted).To(BeTrue(), "the third attempt reaches the maximum attempts")
+
+				tableRetries, err := getTableUploadRetries(uploadID)
+				Expect(err).To(BeNil())
+				Expect(tableRetries[tableName].status).To(Equal(TableUploadAborted))
+
+				abortedErr, err = job.tablesAbortedError()
+				Expect(err).To(BeNil())
+				Expect(abortedErr).NotTo(BeNil())
+				Expect(abortedErr.tables).To(Equal([]string{tableName}))
+			})
+
 			It("Setting status", func() {
 				err := tu.setStatus("exported_data")
 				Expect(err).To(BeNil())
+
+				tableRetries, err := getTableUploadRetries(uploadID)
+				Expect(err).To(BeNil())
+				Expect(tableRetries).NotTo(HaveKey(tableName), "exported tables are not retried")
 			})
 
 			Describe("Getting number of events", func() {
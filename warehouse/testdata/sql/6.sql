@@ -0,0 +1,21 @@
+BEGIN;
+INSERT INTO wh_staging_files (
+  id, location, schema, source_id, destination_id,
+  status, total_events, first_event_at,
+  last_event_at, created_at, updated_at,
+  metadata
+)
+VALUES
+  (
+    1, 'rudder/rudder-warehouse-staging-logs/2EUralUySYUs7hgsdU1lFXRSm/2022-09-20/1663650685.2EUralsdsDyZjOKU1lFXRSm.eeadsb4-a066-42f4-a90b-460161378e1b.json.gz',
+    '{"tracks": {"id": "string", "event": "string"}}', 'test-sourceID', 'test-destinationID',
+    'succeeded', 1, NOW(), NOW(), NOW(),
+    NOW(), '{}'
+  ),
+  (
+    2, 'rudder/rudder-warehouse-staging-logs/2EUralUySYUs7hgsdU1lFXRSm/2022-09-20/1663650686.2EUralsdsDyZjOKU1lFXRSm.eeadsb4-a066-42f4-a90b-460161378e1b.json.gz',
+    '{"tracks": {"id": "string", "event": "string", "revenue": "float"}}', 'test-sourceID', 'test-destinationID',
+    'succeeded', 1, NOW(), NOW(), NOW(),
+    NOW(), '{}'
+  );
+END;
@@ -22,6 +22,7 @@ import (
 	"github.com/rudderlabs/rudder-server/warehouse/validations"
 
 	"github.com/rudderlabs/rudder-server/utils/misc"
+	"github.com/rudderlabs/rudder-server/utils/timeutil"
 	warehouseutils "github.com/rudderlabs/rudder-server/warehouse/utils"
 
 	"github.com/cenkalti/backoff"
@@ -413,6 +414,27 @@ func VerifyEventsInWareHouse(t testing.TB, wareHouseTest *WareHouseTest, eventsM
 	t.Logf("Completed verifying events in warehouse")
 }
 
+// VerifyMergeLoadMode sends the same events three times for a new user and message id,
+// and verifies that the warehouse of the destination in merge load mode keeps a single row per event
+func VerifyMergeLoadMode(t testing.TB, wareHouseTest *WareHouseTest, destType string) {
+	t.Helper()
+
+	wareHouseTest.TimestampBeforeSendingEvents = timeutil.Now()
+	wareHouseTest.UserId = GetUserId(destType)
+	wareHouseTest.MessageId = uuid.Must(uuid.NewV4()).String()
+
+	sendEventsMap := SendEventsMap()
+	SendEvents(t, wareHouseTest, sendEventsMap)
+	SendEvents(t, wareHouseTest, sendEventsMap)
+	SendEvents(t, wareHouseTest, sendEventsMap)
+	SendIntegratedEvents(t, wareHouseTest, sendEventsMap)
+
+	VerifyEventsInStagingFiles(t, wareHouseTest, StagingFilesEventsMap())
+	VerifyEventsInLoadFiles(t, wareHouseTest, LoadFilesEventsMap())
+	VerifyEventsInTableUploads(t, wareHouseTest, TableUploadsEventsMap())
+	VerifyEventsInWareHouse(t, wareHouseTest, MergeEventsMap())
+}
+
 func VerifyingConfigurationTest(t *testing.T, destination backendconfig.DestinationT) {
 	t.Helper()
 	t.Logf("Started configuration tests for destination type: %s", destination.DestinationDefinition.Name)
@@ -505,6 +527,19 @@ func WarehouseEventsMap() EventsCountMap {
 	}
 }
 
+func MergeEventsMap() EventsCountMap {
+	return EventsCountMap{
+		"identifies":    1,
+		"users":         1,
+		"tracks":        1,
+		"product_track": 1,
+		"pages":         1,
+		"screens":       1,
+		"aliases":       1,
+		"groups":        1,
+	}
+}
+
 func WarehouseSourceEventsMap() EventsCountMap {
 	return EventsCountMap{
 		"google_sheet": 1,
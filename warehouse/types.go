@@ -33,6 +33,7 @@ type Payload struct {
 	Output                       []loadFileUploadOutputT
 	LoadFilePrefix               string // prefix for the load file name
 	LoadFileType                 string
+	StagingFileFormat            string
 }
 
 type ProcessStagingFilesJobT struct {
@@ -61,8 +62,10 @@ type StagingFileT struct {
 	CreatedAt             time.Time
 	FirstEventAt          time.Time
 	LastEventAt           time.Time
+	TotalEvents           int64
 	UseRudderStorage      bool
 	DestinationRevisionID string
+	StagingFileFormat     string
 	// cloud sources specific info
 	SourceBatchID   string
 	SourceTaskID    string
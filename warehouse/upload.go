@@ -65,6 +65,7 @@ const (
 	UserTableUploadExportingFailed     = "exporting_user_tables_failed"
 	IdentityTableUploadExportingFailed = "exporting_identities_failed"
 	TableUploadExported                = "exported_data"
+	TableUploadAborted                 = "aborted"
 )
 
 const (
@@ -126,13 +127,16 @@ type UploadJobT struct {
 	whManager            manager.ManagerI
 	stagingFiles         []*StagingFileT
 	stagingFileIDs       []int64
-	pgNotifier           *pgnotifier.PgNotifierT
+	pgNotifier           pgnotifier.NotifierI
 	schemaHandle         *SchemaHandleT
 	schemaLock           sync.Mutex
 	uploadLock           sync.Mutex
 	hasAllTablesSkipped  bool
 	tableUploadStatuses  []*TableUploadStatusT
 	destinationValidator validations.DestinationValidator
+	// pendingTableRetries are the tables which failed to load and are retried independently, with the time of their next retry
+	pendingTableRetries map[string]time.Time
+	tableRetriesLock    sync.Mutex
 }
 
 type UploadColumnT struct {
@@ -176,6 +180,7 @@ func setMaxParallelLoads() {
 		warehouseutils.SNOWFLAKE:  config.GetInt("Warehouse.snowflake.maxParallelLoads", 3),
 		warehouseutils.CLICKHOUSE: config.GetInt("Warehouse.clickhouse.maxParallelLoads", 3),
 		warehouseutils.DELTALAKE:  config.GetInt("Warehouse.deltalake.maxParallelLoads", 3),
+		warehouseutils.SQLITE:     config.GetInt("Warehouse.sqlite.maxParallelLoads", 1),
 	}
 	columnCountThresholds = map[string]int{
 		warehouseutils.AZURE_SYNAPSE: config.GetInt("Warehouse.azure_synapse.columnCountThreshold", 800),
@@ -185,6 +190,7 @@ func setMaxParallelLoads() {
 		warehouseutils.POSTGRES:      config.GetInt("Warehouse.postgres.columnCountThreshold", 1200),
 		warehouseutils.RS:            config.GetInt("Warehouse.redshift.columnCountThreshold", 1200),
 		warehouseutils.SNOWFLAKE:     config.GetInt("Warehouse.snowflake.columnCountThreshold", 1600),
+		warehouseutils.SQLITE:        config.GetInt("Warehouse.sqlite.columnCountThreshold", 1600),
 	}
 }
 
@@ -242,6 +248,11 @@ func (job *UploadJobT) generateUploadSchema(schemaHandle *SchemaHandleT) error {
 }
 
 func (job *UploadJobT) initTableUploads() error {
+	return createTableUploads(job.upload.ID, job.tablesToUpload())
+}
+
+// tablesToUpload returns the tables in the schema of the upload along with the identity tables loaded alongside them
+func (job *UploadJobT) tablesToUpload() []string {
 	schemaForUpload := job.upload.UploadSchema
 	destType := job.warehouse.Type
 	tables := make([]string, 0, len(schemaForUpload))
@@ -254,8 +265,7 @@ func (job *UploadJobT) initTableUploads() error {
 			}
 		}
 	}
-
-	return createTableUploads(job.upload.ID, tables)
+	return tables
 }
 
 func (job *UploadJobT) syncRemoteSchema() (schemaChanged bool, err error) {
@@ -295,13 +305,15 @@ func (job *UploadJobT) getTotalRowsInStagingFiles() int64 {
 		  ST.id >= %[2]v 
 		  AND ST.id <= %[3]v 
 		  AND ST.source_id = '%[4]s' 
-		  AND ST.destination_id = '%[5]s';
+		  AND ST.destination_id = '%[5]s' 
+		  %[6]s;
 	`,
 		warehouseutils.WarehouseStagingFilesTable,
 		job.upload.StartStagingFileID,
 		job.upload.EndStagingFileID,
 		job.warehouse.Source.ID,
 		job.warehouse.Destination.ID,
+		routedNamespaceSQL(job.warehouse, "ST.namespace"),
 	)
 	err := dbHandle.QueryRow(sqlStatement).Scan(&total)
 	if err != nil {
@@ -558,6 +570,20 @@ func (job *UploadJobT) run() (err error) {
 				err = misc.ConcatErrors(loadErrors)
 				break
 			}
+			if retriesErr := job.tableRetriesPendingError(); retriesErr != nil {
+				err = retriesErr
+				break
+			}
+			if enableTableRetries {
+				var abortedErr *TablesAbortedError
+				if abortedErr, err = job.tablesAbortedError(); err != nil {
+					break
+				}
+				if abortedErr != nil {
+					err = abortedErr
+					break
+				}
+			}
 			job.generateUploadSuccessMetrics()
 
 			newStatus = nextUploadState.completed
@@ -569,6 +595,21 @@ func (job *UploadJobT) run() (err error) {
 
 		if err != nil {
 			pkgLogger.Errorf("[WH] Upload: %d, TargetState: %s, NewState: %s, Error: %v", job.upload.ID, targetStatus, newStatus, err.Error())
+			var retriesErr *TableRetriesPendingError
+			if errors.As(err, &retriesErr) {
+				if setErr := job.setTableRetriesPending(newStatus, retriesErr); setErr != nil {
+					pkgLogger.Errorf("[WH] Upload: %d, failed to set table retries pending: %v", job.upload.ID, setErr)
+				}
+				break
+			}
+			// the tables which were loaded stay loaded, but the upload is not exported as a whole
+			var abortedErr *TablesAbortedError
+			if errors.As(err, &abortedErr) {
+				if _, setErr := job.setUploadError(err, Aborted); setErr == nil {
+					job.generateUploadAbortedMetrics()
+				}
+				break
+			}
 			state, err := job.setUploadError(err, newStatus)
 			if err == nil && state == Aborted {
 				job.generateUploadAbortedMetrics()
@@ -850,9 +891,16 @@ func (job *UploadJobT) updateTableSchema(tName string, tableSchemaDiff warehouse
 			return err
 		}
 		job.counterStat("tables_added").Increment()
+
+		changes := make([]SchemaChangeT, 0, len(tableSchemaDiff.ColumnMap))
+		for _, columnName := range warehouseutils.SortColumnKeysFromColumnMap(tableSchemaDiff.ColumnMap) {
+			changes = append(changes, job.schemaChange(tName, columnName, SchemaChangeCreateTable, "", tableSchemaDiff.ColumnMap[columnName]))
+		}
+		job.recordSchemaChanges(tName, changes)
 		return nil
 	}
 
+	var addedColumns []SchemaChangeT
 	for columnName, columnType := range tableSchemaDiff.ColumnMap {
 		err = job.whManager.AddColumn(tName, columnName, columnType)
 		if err != nil {
@@ -860,23 +908,116 @@ func (job *UploadJobT) updateTableSchema(tName string, tableSchemaDiff warehouse
 			break
 		}
 		job.counterStat("columns_added").Increment()
+		addedColumns = append(addedColumns, job.schemaChange(tName, columnName, SchemaChangeAddColumn, "", columnType))
 	}
+	job.recordSchemaChanges(tName, addedColumns)
 
 	if err != nil {
 		return err
 	}
 
 	for _, columnName := range tableSchemaDiff.StringColumnsToBeAlteredToText {
-		err = job.whManager.AlterColumn(tName, columnName, "text")
+		err = job.alterColumn(tName, columnName, "text")
 		if err != nil {
 			pkgLogger.Errorf("Altering column %s in table: %s.%s failed. Error: %v", columnName, job.warehouse.Namespace, tName, err)
 			break
 		}
 	}
 
+	if err != nil {
+		return err
+	}
+
+	for columnName, columnType := range tableSchemaDiff.AlteredColumnMap {
+		err = job.alterColumn(tName, columnName, columnType)
+		if err != nil {
+			pkgLogger.Errorf("Altering column %s in table: %s.%s to %s failed. Error: %v", columnName, job.warehouse.Namespace, tName, columnType, err)
+			break
+		}
+	}
+
 	return err
 }
 
+// ColumnTypeEvolutionT is the record of a change of the type of a column, kept in the metadata of the upload
+type ColumnTypeEvolutionT struct {
+	Table     string    `json:"table"`
+	Column    string    `json:"column"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Strategy  string    `json:"strategy"`
+	Queries   []string  `json:"queries,omitempty"`
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	AlteredAt time.Time `json:"altered_at"`
+}
+
+const (
+	ColumnTypeEvolutionsField = "columnTypeEvolutions"
+	ColumnTypeEvolutionFailed = "failed"
+	ColumnTypeEvolutionDone   = "succeeded"
+)
+
+// alterColumn changes the type of the column in the warehouse and records the change in the metadata of the upload
+func (job *UploadJobT) alterColumn(tName, columnName, columnType string) error {
+	evolution := ColumnTypeEvolutionT{
+		Table:     tName,
+		Column:    columnName,
+		From:      job.GetTableSchemaInWarehouse(tName)[columnName],
+		To:        columnType,
+		Status:    ColumnTypeEvolutionDone,
+		AlteredAt: timeutil.Now(),
+	}
+	response, err := job.whManager.AlterColumn(tName, columnName, columnType)
+	if err != nil {
+		evolution.Status = ColumnTypeEvolutionFailed
+		evolution.Error = err.Error()
+	} else if response.Strategy == "" {
+		// nothing had to be done in the warehouse
+		return nil
+	}
+	evolution.Strategy = response.Strategy
+	evolution.Queries = response.Queries
+
+	if recordErr := job.recordColumnTypeEvolution(evolution); recordErr != nil {
+		pkgLogger.Errorf("[WH]: Failed to record type change of column %s in table %s.%s for upload %d: %v", columnName, job.warehouse.Namespace, tName, job.upload.ID, recordErr)
+	}
+	if err != nil {
+		return err
+	}
+	job.counterStat("columns_altered", tag{name: "strategy", value: response.Strategy}).Increment()
+	job.recordSchemaChanges(tName, []SchemaChangeT{job.schemaChange(tName, columnName, SchemaChangeAlterColumn, evolution.From, columnType)})
+	return nil
+}
+
+// recordColumnTypeEvolution appends the evolution to the ones in the metadata of the upload.
+// Metadata is appended to in the database, as tables are loaded in parallel.
+func (job *UploadJobT) recordColumnTypeEvolution(evolution ColumnTypeEvolutionT) error {
+	evolutionJSON, err := json.Marshal([]ColumnTypeEvolutionT{evolution})
+	if err != nil {
+		return err
+	}
+	sqlStatement := fmt.Sprintf(`
+		UPDATE 
+		  %[1]s 
+		SET 
+		  metadata = jsonb_set(
+		    COALESCE(metadata, '{}' :: jsonb), 
+		    '{%[2]s}', 
+		    COALESCE(metadata -> '%[2]s', '[]' :: jsonb) || $1 :: jsonb
+		  ) 
+		WHERE 
+		  id = $2 RETURNING metadata;
+`,
+		warehouseutils.WarehouseUploadsTable,
+		ColumnTypeEvolutionsField,
+	)
+	job.uploadLock.Lock()
+	defer job.uploadLock.Unlock()
+	// keeping the metadata of the upload in sync, as it is written back on failures
+	return job.dbHandle.QueryRow(sqlStatement, string(evolutionJSON), job.upload.ID).Scan(&job.upload.Metadata)
+}
+
 // TableSkipError is a custom error type to capture if a table load is skipped because of a previously failed table load
 type TableSkipError struct {
 	tableName        string
@@ -903,8 +1044,16 @@ func (job *UploadJobT) loadAllTablesExcept(skipLoadForTables []string, loadFiles
 	var wg sync.WaitGroup
 	wg.Add(len(uploadSchema))
 
+	var tableRetries map[string]tableUploadRetryT
+	if enableTableRetries {
+		var err error
+		if tableRetries, err = getTableUploadRetries(job.upload.ID); err != nil {
+			return []error{err}
+		}
+	}
+
 	var alteredSchemaInAtleastOneTable bool
-	loadChan := make(chan struct{}, parallelLoads)
+	loadSlots := destinationLoadSlots(job.warehouse.Destination.ID, parallelLoads)
 	previouslyFailedTables, currentJobSucceededTables := job.getTablesToSkip()
 	for tableName := range uploadSchema {
 		if misc.Contains(skipLoadForTables, tableName) {
@@ -915,11 +1064,23 @@ func (job *UploadJobT) loadAllTablesExcept(skipLoadForTables []string, loadFiles
 			wg.Done()
 			continue
 		}
-		if prevJobStatus, ok := previouslyFailedTables[tableName]; ok {
+		// with table retries, tables which failed in earlier uploads are retried by those uploads and do not block this one
+		if prevJobStatus, ok := previouslyFailedTables[tableName]; ok && !enableTableRetries {
 			loadErrors = append(loadErrors, &TableSkipError{tableName: tableName, previousJobID: prevJobStatus.uploadID, previousJobError: prevJobStatus.error})
 			wg.Done()
 			continue
 		}
+		if retry, ok := tableRetries[tableName]; ok {
+			if retry.status == TableUploadAborted {
+				wg.Done()
+				continue
+			}
+			if retry.nextRetryTime.After(timeutil.Now()) {
+				job.addPendingTableRetry(tableName, retry.nextRetryTime)
+				wg.Done()
+				continue
+			}
+		}
 		hasLoadFiles := loadFilesTableMap[tableNameT(tableName)]
 		if !hasLoadFiles {
 			wg.Done()
@@ -930,12 +1091,22 @@ func (job *UploadJobT) loadAllTablesExcept(skipLoadForTables []string, loadFiles
 			continue
 		}
 		tName := tableName
-		loadChan <- struct{}{}
+		loadSlots.acquire()
 		rruntime.GoForWarehouse(func() {
 			alteredSchema, err := job.loadTable(tName)
 			if alteredSchema {
 				alteredSchemaInAtleastOneTable = true
 			}
+			if enableTableRetries {
+				// the load files are kept for the retries of the table, until it is loaded or aborted
+				release := err == nil
+				if err != nil {
+					release, err = job.retryTableLater(tName, err)
+				}
+				if release {
+					job.releaseLoadFiles(tName)
+				}
+			}
 
 			if err != nil {
 				loadErrorLock.Lock()
@@ -943,7 +1114,7 @@ func (job *UploadJobT) loadAllTablesExcept(skipLoadForTables []string, loadFiles
 				loadErrorLock.Unlock()
 			}
 			wg.Done()
-			<-loadChan
+			loadSlots.release()
 		})
 	}
 	wg.Wait()
@@ -1048,6 +1219,7 @@ func (job *UploadJobT) loadTable(tName string) (alteredSchema bool, err error) {
 	numEvents, queryErr := tableUpload.getNumEvents()
 	if queryErr == nil {
 		job.recordTableLoad(tName, numEvents)
+		job.recordTableUsage(tName, numEvents)
 	}
 
 	if columnThreshold, ok := columnCountThresholds[job.warehouse.Type]; ok {
@@ -1208,6 +1380,7 @@ func (job *UploadJobT) processLoadTableResponse(errorMap map[string]error) (erro
 				numEvents, queryErr := tableUpload.getNumEvents()
 				if queryErr == nil {
 					job.recordTableLoad(tName, numEvents)
+					job.recordTableUsage(tName, numEvents)
 				}
 			}
 		}
@@ -1857,6 +2030,7 @@ func (job *UploadJobT) createLoadFiles(generateAll bool) (startLoadFileID, endLo
 
 	var saveLoadFileErrs []error
 	var sampleError error
+	constraintViolations := &constraintViolationsT{}
 	for i := 0; i < len(toProcessStagingFiles); i += publishBatchSize {
 		j := i + publishBatchSize
 		if j > len(toProcessStagingFiles) {
@@ -1885,6 +2059,7 @@ func (job *UploadJobT) createLoadFiles(generateAll bool) (startLoadFileID, endLo
 				StagingUseRudderStorage:      stagingFile.UseRudderStorage,
 				DestinationRevisionID:        job.warehouse.Destination.RevisionID,
 				StagingDestinationRevisionID: stagingFile.DestinationRevisionID,
+				StagingFileFormat:            stagingFile.StagingFileFormat,
 			}
 			if revisionConfig, ok := destinationRevisionIDMap[stagingFile.DestinationRevisionID]; ok {
 				payload.StagingDestinationConfig = revisionConfig.Config
@@ -1910,7 +2085,7 @@ func (job *UploadJobT) createLoadFiles(generateAll bool) (startLoadFileID, endLo
 			Jobs:    messages,
 			JobType: "upload",
 		}
-		ch, err := job.pgNotifier.Publish(messagePayload, schema, job.upload.Priority)
+		ch, err := job.pgNotifier.Publish(context.TODO(), messagePayload, schema, job.upload.Priority)
 		if err != nil {
 			panic(err)
 		}
@@ -1945,6 +2120,7 @@ func (job *UploadJobT) createLoadFiles(generateAll bool) (startLoadFileID, endLo
 					continue
 				}
 				loadFiles = append(loadFiles, output...)
+				constraintViolations.add(output)
 				successfulStagingFileIDs = append(successfulStagingFileIDs, resp.JobID)
 			}
 			err = job.bulkInsertLoadFileRecords(loadFiles)
@@ -1958,6 +2134,10 @@ func (job *UploadJobT) createLoadFiles(generateAll bool) (startLoadFileID, endLo
 
 	wg.Wait()
 
+	if err = job.recordConstraintViolations(constraintViolations.counts, !generateAll); err != nil {
+		pkgLogger.Errorf(`[WH]: Failed to record constraint violations of upload %d: %v`, job.upload.ID, err)
+	}
+
 	if len(saveLoadFileErrs) > 0 {
 		err = misc.ConcatErrors(saveLoadFileErrs)
 		pkgLogger.Errorf(`[WH]: Encountered errors in creating load file records in wh_load_files: %v`, err)
@@ -2045,7 +2225,7 @@ func (job *UploadJobT) bulkInsertLoadFileRecords(loadFiles []loadFileUploadOutpu
 	defer stmt.Close()
 
 	for _, loadFile := range loadFiles {
-		metadata := fmt.Sprintf(`{"content_length": %d, "destination_revision_id": %q, "use_rudder_storage": %t}`, loadFile.ContentLength, loadFile.DestinationRevisionID, loadFile.UseRudderStorage)
+		metadata := fmt.Sprintf(`{"content_length": %d, "total_rows": %d, "destination_revision_id": %q, "use_rudder_storage": %t}`, loadFile.ContentLength, loadFile.TotalRows, loadFile.DestinationRevisionID, loadFile.UseRudderStorage)
 		_, err = stmt.Exec(loadFile.StagingFileID, loadFile.Location, job.upload.SourceID, job.upload.DestinationID, job.upload.DestinationType, loadFile.TableName, loadFile.TotalRows, timeutil.Now(), metadata)
 		if err != nil {
 			pkgLogger.Errorf(`[WH]: Error copying row in pq.CopyIn for loadFiles: %v Error: %v`, loadFile, err)
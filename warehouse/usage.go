@@ -0,0 +1,289 @@
+package warehouse
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	proto "github.com/rudderlabs/rudder-server/proto/warehouse"
+	"github.com/rudderlabs/rudder-server/utils/misc"
+	"github.com/rudderlabs/rudder-server/utils/timeutil"
+	warehouseutils "github.com/rudderlabs/rudder-server/warehouse/utils"
+)
+
+const usageDayLayout = "2006-01-02"
+
+// UsageT is what a source loaded into a table of a destination on a day, or over a period when summarized
+type UsageT struct {
+	WorkspaceID     string
+	SourceID        string
+	DestinationID   string
+	DestinationType string
+	Namespace       string
+	TableName       string
+	Day             time.Time
+	Uploads         int64
+	Rows            int64
+	Bytes           int64
+	LoadDuration    time.Duration
+}
+
+func (usage UsageT) proto() *proto.WHUsage {
+	p := &proto.WHUsage{
+		SourceId:        usage.SourceID,
+		DestinationId:   usage.DestinationID,
+		DestinationType: usage.DestinationType,
+		Namespace:       usage.Namespace,
+		TableName:       usage.TableName,
+		Uploads:         usage.Uploads,
+		Rows:            usage.Rows,
+		Bytes:           usage.Bytes,
+		LoadDurationMs:  usage.LoadDuration.Milliseconds(),
+	}
+	if !usage.Day.IsZero() {
+		p.Day = usage.Day.Format(usageDayLayout)
+	}
+	return p
+}
+
+// getTableLoadUsage returns the size of the load files of the table in the upload and how long the table took to load.
+// The load files are the latest ones of the staging files of the upload, like the ones GetLoadFilesMetadata loads.
+func (job *UploadJobT) getTableLoadUsage(tableName string) (bytes int64, loadDuration time.Duration, err error) {
+	sqlStatement := fmt.Sprintf(`
+		WITH row_numbered_load_files AS (
+		  SELECT
+			metadata,
+			row_number() OVER (
+			  PARTITION BY staging_file_id,
+			  table_name
+			  ORDER BY
+				id DESC
+			) AS row_number
+		  FROM
+			%[1]s
+		  WHERE
+			staging_file_id IN (%[2]v)
+			AND table_name = $1
+		)
+		SELECT
+		  COALESCE(SUM((metadata->>'content_length')::BIGINT), 0)
+		FROM
+		  row_numbered_load_files
+		WHERE
+		  row_number = 1;
+`,
+		warehouseutils.WarehouseLoadFilesTable,
+		misc.IntArrayToString(job.stagingFileIDs, ","),
+	)
+	err = job.dbHandle.QueryRow(sqlStatement, tableName).Scan(&bytes)
+	if err != nil {
+		return 0, 0, fmt.Errorf("query: %s failed with error: %w", sqlStatement, err)
+	}
+
+	// the table upload is executing from its last_exec_time until it is exported
+	var lastExecTime, exportedAt sql.NullTime
+	sqlStatement = fmt.Sprintf(`
+		SELECT
+		  last_exec_time,
+		  updated_at
+		FROM
+		  %s
+		WHERE
+		  wh_upload_id = $1
+		  AND table_name = $2;
+`,
+		warehouseutils.WarehouseTableUploadsTable,
+	)
+	err = job.dbHandle.QueryRow(sqlStatement, job.upload.ID, tableName).Scan(&lastExecTime, &exportedAt)
+	if err != nil {
+		return 0, 0, fmt.Errorf("query: %s failed with error: %w", sqlStatement, err)
+	}
+	if lastExecTime.Valid && exportedAt.Valid && exportedAt.Time.After(lastExecTime.Time) {
+		loadDuration = exportedAt.Time.Sub(lastExecTime.Time)
+	}
+	return bytes, loadDuration, nil
+}
+
+// recordTableUsage adds the rows, bytes and load duration of the exported table to the usage of the source for the day
+func (job *UploadJobT) recordTableUsage(tableName string, numEvents int64) {
+	if !enableUsageAccounting {
+		return
+	}
+	bytes, loadDuration, err := job.getTableLoadUsage(tableName)
+	if err != nil {
+		pkgLogger.Errorf("[WH]: Failed to get usage of table %s for %s: %v", tableName, job.warehouse.Identifier, err)
+		return
+	}
+
+	now := timeutil.Now()
+	sqlStatement := fmt.Sprintf(`
+		INSERT INTO %[1]s (
+		  workspace_id, source_id, destination_id,
+		  destination_type, namespace, table_name,
+		  day, uploads, rows, bytes, load_duration_ms,
+		  created_at, updated_at
+		)
+		VALUES
+		  ($1, $2, $3, $4, $5, $6, $7, 1, $8, $9, $10, $11, $11)
+		ON CONFLICT (source_id, destination_id, namespace, table_name, day) DO
+		UPDATE
+		SET
+		  uploads = %[1]s.uploads + 1,
+		  rows = %[1]s.rows + EXCLUDED.rows,
+		  bytes = %[1]s.bytes + EXCLUDED.bytes,
+		  load_duration_ms = %[1]s.load_duration_ms + EXCLUDED.load_duration_ms,
+		  updated_at = EXCLUDED.updated_at;
+`,
+		warehouseutils.WarehouseUsageTable,
+	)
+	_, err = job.dbHandle.Exec(sqlStatement,
+		job.upload.WorkspaceID,
+		job.upload.SourceID,
+		job.upload.DestinationID,
+		job.warehouse.Type,
+		job.upload.Namespace,
+		tableName,
+		now.Format(usageDayLayout),
+		numEvents,
+		bytes,
+		loadDuration.Milliseconds(),
+		now,
+	)
+	if err != nil {
+		pkgLogger.Errorf("[WH]: Failed to record usage of table %s for %s: %v", tableName, job.warehouse.Identifier, err)
+		return
+	}
+
+	tableTag := tag{name: "tableName", value: strings.ToLower(tableName)}
+	job.counterStat("usage_rows", tableTag).Count(int(numEvents))
+	job.counterStat("usage_bytes", tableTag).Count(int(bytes))
+	job.timerStat("usage_load_time", tableTag).SendTiming(loadDuration)
+}
+
+// UsageReqT lists the usage of the sources of a workspace between the days of StartTime, included, and EndTime, excluded
+type UsageReqT struct {
+	WorkspaceID   string
+	SourceID      string
+	DestinationID string
+	TableName     string
+	StartTime     time.Time
+	EndTime       time.Time
+	ByTable       bool
+	Limit         int32
+	Offset        int32
+	API           UploadAPIT
+}
+
+func (usageReq *UsageReqT) validateReq() error {
+	if !usageReq.API.enabled || usageReq.API.log == nil || usageReq.API.dbHandle == nil {
+		return errors.New("warehouse api's are not initialized")
+	}
+	if usageReq.WorkspaceID == "" {
+		return errors.New("workspace_id is empty")
+	}
+	if !usageReq.StartTime.IsZero() && !usageReq.EndTime.IsZero() && !usageReq.EndTime.After(usageReq.StartTime) {
+		return errors.New("end_time is not after start_time")
+	}
+	if usageReq.Limit < 1 {
+		usageReq.Limit = 10
+	}
+	if usageReq.Offset < 0 {
+		usageReq.Offset = 0
+	}
+	return nil
+}
+
+func (usageReq *UsageReqT) clausesQuery(sourceIDs []string) []FilterClause {
+	clauses := []FilterClause{{
+		Clause:    fmt.Sprintf(`source_id = ANY(%s)`, queryPlaceHolder),
+		ClauseArg: pq.Array(sourceIDs),
+	}}
+	optionalClauses := []struct {
+		clause string
+		value  interface{}
+		isSet  bool
+	}{
+		{clause: "source_id = %s", value: usageReq.SourceID, isSet: usageReq.SourceID != ""},
+		{clause: "destination_id = %s", value: usageReq.DestinationID, isSet: usageReq.DestinationID != ""},
+		{clause: "table_name = %s", value: usageReq.TableName, isSet: usageReq.TableName != ""},
+		{clause: "day >= %s", value: usageReq.StartTime.UTC().Format(usageDayLayout), isSet: !usageReq.StartTime.IsZero()},
+		{clause: "day < %s", value: usageReq.EndTime.UTC().Format(usageDayLayout), isSet: !usageReq.EndTime.IsZero()},
+	}
+	for _, clause := range optionalClauses {
+		if clause.isSet {
+			clauses = append(clauses, FilterClause{
+				Clause:    fmt.Sprintf(clause.clause, queryPlaceHolder),
+				ClauseArg: clause.value,
+			})
+		}
+	}
+	return clauses
+}
+
+// authorizedSources returns the sources of the workspace the usage can be listed for
+func (usageReq *UsageReqT) authorizedSources() ([]string, error) {
+	sourceIDs := UploadsReqT{WorkspaceID: usageReq.WorkspaceID}.authorizedSources()
+	if len(sourceIDs) == 0 {
+		return nil, errors.New("unauthorized request")
+	}
+	if usageReq.SourceID != "" && !misc.Contains(sourceIDs, usageReq.SourceID) {
+		return nil, errors.New("no such sourceID exists")
+	}
+	return sourceIDs, nil
+}
+
+func usageResponse(usages []UsageT, limit, offset int32, total int64) *proto.WHUsageResponse {
+	response := &proto.WHUsageResponse{
+		Usage: make([]*proto.WHUsage, 0, len(usages)),
+		Pagination: &proto.Pagination{
+			Limit:  limit,
+			Offset: offset,
+			Total:  int32(total),
+		},
+	}
+	for _, usage := range usages {
+		response.Usage = append(response.Usage, usage.proto())
+	}
+	return response
+}
+
+// GetUsage returns the daily usage matching the request, the latest day first
+func (usageReq *UsageReqT) GetUsage(ctx context.Context) (*proto.WHUsageResponse, error) {
+	if err := usageReq.validateReq(); err != nil {
+		return &proto.WHUsageResponse{}, err
+	}
+	sourceIDs, err := usageReq.authorizedSources()
+	if err != nil {
+		return &proto.WHUsageResponse{}, err
+	}
+
+	usages, total, err := usageReq.API.warehouseDBHandle.GetUsage(ctx, usageReq.Limit, usageReq.Offset, usageReq.clausesQuery(sourceIDs)...)
+	if err != nil {
+		usageReq.API.log.Errorf("WH: Error getting usage for workspace %s: %v", usageReq.WorkspaceID, err)
+		return &proto.WHUsageResponse{}, err
+	}
+	return usageResponse(usages, usageReq.Limit, usageReq.Offset, total), nil
+}
+
+// GetUsageSummary returns the usage matching the request summed by source and destination, and by table if ByTable is set
+func (usageReq *UsageReqT) GetUsageSummary(ctx context.Context) (*proto.WHUsageResponse, error) {
+	if err := usageReq.validateReq(); err != nil {
+		return &proto.WHUsageResponse{}, err
+	}
+	sourceIDs, err := usageReq.authorizedSources()
+	if err != nil {
+		return &proto.WHUsageResponse{}, err
+	}
+
+	usages, total, err := usageReq.API.warehouseDBHandle.GetUsageSummary(ctx, usageReq.ByTable, usageReq.Limit, usageReq.Offset, usageReq.clausesQuery(sourceIDs)...)
+	if err != nil {
+		usageReq.API.log.Errorf("WH: Error getting usage summary for workspace %s: %v", usageReq.WorkspaceID, err)
+		return &proto.WHUsageResponse{}, err
+	}
+	return usageResponse(usages, usageReq.Limit, usageReq.Offset, total), nil
+}
@@ -0,0 +1,192 @@
+//go:build !warehouse_integration
+
+package warehouse
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/ory/dockertest/v3"
+
+	proto "github.com/rudderlabs/rudder-server/proto/warehouse"
+	"github.com/rudderlabs/rudder-server/services/stats"
+	"github.com/rudderlabs/rudder-server/testhelper"
+	"github.com/rudderlabs/rudder-server/testhelper/destination"
+	"github.com/rudderlabs/rudder-server/utils/logger"
+	warehouseutils "github.com/rudderlabs/rudder-server/warehouse/utils"
+)
+
+var _ = Describe("Usage", func() {
+	api := UploadAPIT{enabled: true, log: logger.NOP, dbHandle: &sql.DB{}}
+	startTime := time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	DescribeTable("Validate request", func(usageReq UsageReqT, expectedErr string) {
+		Expect(usageReq.validateReq()).To(MatchError(expectedErr))
+	},
+		Entry("Not initialized", UsageReqT{}, "warehouse api's are not initialized"),
+		Entry("Missing workspace", UsageReqT{API: api}, "workspace_id is empty"),
+		Entry("Empty period", UsageReqT{
+			WorkspaceID: "test-workspaceID",
+			StartTime:   startTime,
+			EndTime:     startTime,
+			API:         api,
+		}, "end_time is not after start_time"),
+	)
+
+	It("Should filter the days of the period", func() {
+		usageReq := UsageReqT{
+			WorkspaceID: "test-workspaceID",
+			TableName:   "tracks",
+			StartTime:   startTime.Add(10 * time.Hour),
+			EndTime:     startTime.AddDate(0, 1, 0),
+		}
+		clausesQuery, clausesArgs := ClauseQueryArgs(usageReq.clausesQuery([]string{"test-sourceID"})...)
+		Expect(clausesQuery).To(Equal("source_id = ANY($1) AND table_name = $2 AND day >= $3 AND day < $4"))
+		Expect(clausesArgs[1:]).To(Equal([]interface{}{"tracks", "2022-10-01", "2022-11-01"}))
+	})
+
+	It("Should convert the usage", func() {
+		usage := UsageT{
+			SourceID:        "test-sourceID",
+			DestinationID:   "test-destinationID",
+			DestinationType: "POSTGRES",
+			TableName:       "tracks",
+			Day:             startTime,
+			Uploads:         2,
+			Rows:            100,
+			Bytes:           2048,
+			LoadDuration:    1500 * time.Millisecond,
+		}
+		Expect(usage.proto()).To(Equal(&proto.WHUsage{
+			SourceId:        "test-sourceID",
+			DestinationId:   "test-destinationID",
+			DestinationType: "POSTGRES",
+			TableName:       "tracks",
+			Day:             "2022-10-01",
+			Uploads:         2,
+			Rows:            100,
+			Bytes:           2048,
+			LoadDurationMs:  1500,
+		}))
+	})
+
+	Describe("Usage accounting", Ordered, func() {
+		var (
+			pgResource *destination.PostgresResource
+			cleanup    = &testhelper.Cleanup{}
+			g          = GinkgoT()
+		)
+
+		newJob := func(uploadID int64, stagingFileIDs ...int64) *UploadJobT {
+			return &UploadJobT{
+				upload: &Upload{
+					ID:            uploadID,
+					WorkspaceID:   "test-workspaceID",
+					Namespace:     "test-namespace",
+					SourceID:      "test-sourceID",
+					DestinationID: "test-destinationID",
+				},
+				stagingFileIDs: stagingFileIDs,
+				warehouse: warehouseutils.Warehouse{
+					Type: "POSTGRES",
+				},
+				dbHandle: pgResource.DB,
+			}
+		}
+
+		BeforeAll(func() {
+			pool, err := dockertest.NewPool("")
+			Expect(err).To(BeNil())
+
+			pgResource = setupWarehouseJobs(pool, g, cleanup)
+
+			initWarehouse()
+
+			err = setupDB(context.TODO(), getConnectionString())
+			Expect(err).To(BeNil())
+
+			pkgLogger = logger.NOP
+
+			_, err = pgResource.DB.Exec(`
+				INSERT INTO wh_load_files (id, staging_file_id, location, source_id, destination_id, destination_type, table_name, total_events, created_at, metadata)
+				VALUES
+				  (1, 1, 'load-1', 'test-sourceID', 'test-destinationID', 'POSTGRES', 'tracks', 10, now(), '{"content_length": 1000}'),
+				  (2, 1, 'load-2', 'test-sourceID', 'test-destinationID', 'POSTGRES', 'pages', 5, now(), '{"content_length": 300}'),
+				  (3, 2, 'load-3', 'test-sourceID', 'test-destinationID', 'POSTGRES', 'tracks', 20, now(), '{"content_length": 2000}'),
+				  (4, 3, 'load-4', 'other-sourceID', 'test-destinationID', 'POSTGRES', 'tracks', 40, now(), '{"content_length": 4000}'),
+				  (5, 2, 'load-5', 'test-sourceID', 'test-destinationID', 'POSTGRES', 'tracks', 20, now(), '{"content_length": 2500}');
+				INSERT INTO wh_table_uploads (wh_upload_id, table_name, status, error, total_events, last_exec_time, created_at, updated_at)
+				VALUES
+				  (1, 'tracks', 'exported_data', '{}', 10, now() - interval '2 seconds', now(), now()),
+				  (1, 'pages', 'exported_data', '{}', 5, now() - interval '1 second', now(), now()),
+				  (2, 'tracks', 'exported_data', '{}', 20, now() - interval '3 seconds', now(), now());
+			`)
+			Expect(err).To(BeNil())
+		})
+
+		AfterAll(func() {
+			cleanup.Run()
+		})
+
+		BeforeEach(func() {
+			defaultStats := stats.Default
+
+			DeferCleanup(func() {
+				stats.Default = defaultStats
+			})
+		})
+
+		It("Should record the usage of the exported tables", func() {
+			mockStats, mockMeasurement := getMockStats(g)
+			mockStats.EXPECT().NewTaggedStat(gomock.Any(), gomock.Any(), gomock.Any()).Times(9).Return(mockMeasurement)
+			mockMeasurement.EXPECT().Count(gomock.Any()).Times(6)
+			mockMeasurement.EXPECT().SendTiming(gomock.Any()).Times(3)
+			stats.Default = mockStats
+
+			newJob(1, 1).recordTableUsage("tracks", 10)
+			newJob(1, 1).recordTableUsage("pages", 5)
+			// the load files of staging file 2 got generated again
+			newJob(2, 2).recordTableUsage("tracks", 20)
+
+			usages, total, err := NewWarehouseDB(pgResource.DB).GetUsage(context.Background(), 10, 0)
+			Expect(err).To(BeNil())
+			Expect(total).To(Equal(int64(2)))
+
+			usageByTable := make(map[string]UsageT)
+			for _, usage := range usages {
+				Expect(usage.Day.Format(usageDayLayout)).To(Equal(time.Now().UTC().Format(usageDayLayout)))
+				Expect(usage.WorkspaceID).To(Equal("test-workspaceID"))
+				usageByTable[usage.TableName] = usage
+			}
+			Expect(usageByTable["tracks"].Uploads).To(Equal(int64(2)))
+			Expect(usageByTable["tracks"].Rows).To(Equal(int64(30)))
+			Expect(usageByTable["tracks"].Bytes).To(Equal(int64(3500)))
+			Expect(usageByTable["tracks"].LoadDuration).To(BeNumerically("~", 5*time.Second, 100*time.Millisecond))
+			Expect(usageByTable["pages"].Uploads).To(Equal(int64(1)))
+			Expect(usageByTable["pages"].Rows).To(Equal(int64(5)))
+			Expect(usageByTable["pages"].Bytes).To(Equal(int64(300)))
+		})
+
+		It("Should summarize the usage", func() {
+			db := NewWarehouseDB(pgResource.DB)
+
+			usages, total, err := db.GetUsageSummary(context.Background(), false, 10, 0)
+			Expect(err).To(BeNil())
+			Expect(total).To(Equal(int64(1)))
+			Expect(usages[0].TableName).To(BeEmpty())
+			Expect(usages[0].Uploads).To(Equal(int64(3)))
+			Expect(usages[0].Rows).To(Equal(int64(35)))
+			Expect(usages[0].Bytes).To(Equal(int64(3800)))
+
+			usages, total, err = db.GetUsageSummary(context.Background(), true, 10, 0)
+			Expect(err).To(BeNil())
+			Expect(total).To(Equal(int64(2)))
+			Expect(usages[0].TableName).To(Equal("tracks"))
+			Expect(usages[1].TableName).To(Equal("pages"))
+		})
+	})
+})
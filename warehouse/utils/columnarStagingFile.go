@@ -0,0 +1,374 @@
+package warehouseutils
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+	"github.com/xitongsys/parquet-go/source"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+const (
+	StagingFileFormatJSON    = "json"
+	StagingFileFormatParquet = "parquet"
+)
+
+// kinds of the values of the columns of columnar staging files, as decoded from the json events of the batch router
+const (
+	ColumnarValueKindNumber  = "number"
+	ColumnarValueKindBoolean = "boolean"
+	ColumnarValueKindString  = "string"
+	// json values are arrays, objects and nulls, kept json encoded
+	ColumnarValueKindJSON = "json"
+)
+
+const (
+	columnarStagingTableKey   = "rudder.table"
+	columnarStagingColumnsKey = "rudder.columns"
+	// columnarStagingRowGroupSize is the number of rows of a table buffered before they are flushed to the staging file as a row group,
+	// also the number of rows read at once
+	columnarStagingRowGroupSize = 10000
+)
+
+var columnarValueKindToParquetDataType = map[string]string{
+	ColumnarValueKindNumber:  PARQUET_DOUBLE,
+	ColumnarValueKindBoolean: PARQUET_BOOLEAN,
+	ColumnarValueKindString:  PARQUET_STRING,
+	ColumnarValueKindJSON:    PARQUET_STRING,
+}
+
+// ColumnarStagingColumnT is a column of a table of a columnar staging file.
+// A column of an event is stored in a different parquet column for each of its types and kinds of values,
+// so that the events can be restored as they were sent by the batch router.
+type ColumnarStagingColumnT struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Kind string `json:"kind"`
+}
+
+type columnarStagingEventT struct {
+	Metadata struct {
+		Table   string            `json:"table"`
+		Columns map[string]string `json:"columns"`
+	} `json:"metadata"`
+	Data map[string]interface{} `json:"data"`
+}
+
+type columnarStagingTableT struct {
+	columns     []ColumnarStagingColumnT
+	columnIndex map[ColumnarStagingColumnT]int
+	rows        [][]interface{}
+}
+
+// ColumnarStagingFileWriter writes the events of the batch router as a tar of parquet files of a single row group,
+// each holding up to columnarStagingRowGroupSize rows of a table along with the columns of those rows in its metadata.
+// The rows of a table are buffered until they fill a row group, so that the events of the batch are not all held in memory.
+type ColumnarStagingFileWriter struct {
+	file      *os.File
+	tarWriter *tar.Writer
+	tables    map[string]*columnarStagingTableT
+	// tableNames keeps the order tables are flushed in on close
+	tableNames []string
+	rowGroups  int
+}
+
+func CreateColumnarStagingFile(outputFilePath string) (*ColumnarStagingFileWriter, error) {
+	file, err := os.Create(outputFilePath)
+	if err != nil {
+		return nil, err
+	}
+	return &ColumnarStagingFileWriter{
+		file:      file,
+		tarWriter: tar.NewWriter(file),
+		tables:    make(map[string]*columnarStagingTableT),
+	}, nil
+}
+
+func columnarValue(val interface{}) (kind string, parquetVal interface{}, err error) {
+	switch v := val.(type) {
+	case float64:
+		return ColumnarValueKindNumber, v, nil
+	case bool:
+		return ColumnarValueKindBoolean, v, nil
+	case string:
+		return ColumnarValueKindString, v, nil
+	}
+	marshalledVal, err := json.Marshal(val)
+	if err != nil {
+		return "", nil, err
+	}
+	return ColumnarValueKindJSON, string(marshalledVal), nil
+}
+
+// WriteEvent buffers the batch router event, flushing the rows of its table once they fill a row group
+func (w *ColumnarStagingFileWriter) WriteEvent(payload []byte) error {
+	var event columnarStagingEventT
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return err
+	}
+
+	tableName := event.Metadata.Table
+	table, ok := w.tables[tableName]
+	if !ok {
+		table = &columnarStagingTableT{columnIndex: make(map[ColumnarStagingColumnT]int)}
+		w.tables[tableName] = table
+		w.tableNames = append(w.tableNames, tableName)
+	}
+
+	row := make([]interface{}, len(table.columns), len(table.columns)+len(event.Data))
+	for columnName, val := range event.Data {
+		kind, parquetVal, err := columnarValue(val)
+		if err != nil {
+			return err
+		}
+		column := ColumnarStagingColumnT{Name: columnName, Type: event.Metadata.Columns[columnName], Kind: kind}
+		index, ok := table.columnIndex[column]
+		if !ok {
+			index = len(table.columns)
+			table.columnIndex[column] = index
+			table.columns = append(table.columns, column)
+		}
+		for len(row) <= index {
+			row = append(row, nil)
+		}
+		row[index] = parquetVal
+	}
+	table.rows = append(table.rows, row)
+
+	if len(table.rows) < columnarStagingRowGroupSize {
+		return nil
+	}
+	return w.flush(tableName)
+}
+
+func (table *columnarStagingTableT) writeParquet(tableName string, w io.Writer) error {
+	pSchema := make([]string, len(table.columns))
+	for i, column := range table.columns {
+		pSchema[i] = fmt.Sprintf("name=c%d, %s", i, columnarValueKindToParquetDataType[column.Kind])
+	}
+	pw, err := writer.NewCSVWriterFromWriter(pSchema, w, parquetParallelWriters)
+	if err != nil {
+		return err
+	}
+	columnsJSON, err := json.Marshal(table.columns)
+	if err != nil {
+		return err
+	}
+	columnsStr := string(columnsJSON)
+	pw.Footer.KeyValueMetadata = append(pw.Footer.KeyValueMetadata,
+		&parquet.KeyValue{Key: columnarStagingTableKey, Value: &tableName},
+		&parquet.KeyValue{Key: columnarStagingColumnsKey, Value: &columnsStr},
+	)
+
+	for _, row := range table.rows {
+		for len(row) < len(table.columns) {
+			row = append(row, nil)
+		}
+		if err = pw.Write(row); err != nil {
+			return err
+		}
+	}
+	return pw.WriteStop()
+}
+
+// flush appends the buffered rows of the table to the staging file as a row group, starting the next row group of the table with no columns
+func (w *ColumnarStagingFileWriter) flush(tableName string) error {
+	table := w.tables[tableName]
+	if len(table.rows) == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	if err := table.writeParquet(tableName, &buf); err != nil {
+		return fmt.Errorf("writing table %s to columnar staging file: %w", tableName, err)
+	}
+	header := &tar.Header{
+		Name: fmt.Sprintf("%d.parquet", w.rowGroups),
+		Mode: 0o600,
+		Size: int64(buf.Len()),
+	}
+	if err := w.tarWriter.WriteHeader(header); err != nil {
+		return err
+	}
+	if _, err := w.tarWriter.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	w.rowGroups++
+	w.tables[tableName] = &columnarStagingTableT{columnIndex: make(map[ColumnarStagingColumnT]int)}
+	return nil
+}
+
+// Close flushes the rows of the tables left buffered to the staging file
+func (w *ColumnarStagingFileWriter) Close() error {
+	for _, tableName := range w.tableNames {
+		if err := w.flush(tableName); err != nil {
+			_ = w.file.Close()
+			return err
+		}
+	}
+	if err := w.tarWriter.Close(); err != nil {
+		_ = w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+func (w *ColumnarStagingFileWriter) GetFile() *os.File {
+	return w.file
+}
+
+// ColumnarStagingFileReader reads the row groups of a columnar staging file one after the other
+type ColumnarStagingFileReader struct {
+	file      *os.File
+	tarReader *tar.Reader
+}
+
+func OpenColumnarStagingFile(filePath string) (*ColumnarStagingFileReader, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return &ColumnarStagingFileReader{file: file, tarReader: tar.NewReader(file)}, nil
+}
+
+// columnarStagingPartT reads a parquet file of the tar of a columnar staging file in place, without moving the offset of the tar
+type columnarStagingPartT struct {
+	*io.SectionReader
+	file         *os.File
+	offset, size int64
+}
+
+func newColumnarStagingPart(file *os.File, offset, size int64) *columnarStagingPartT {
+	return &columnarStagingPartT{SectionReader: io.NewSectionReader(file, offset, size), file: file, offset: offset, size: size}
+}
+
+func (p *columnarStagingPartT) Open(string) (source.ParquetFile, error) {
+	return newColumnarStagingPart(p.file, p.offset, p.size), nil
+}
+
+func (*columnarStagingPartT) Create(string) (source.ParquetFile, error) {
+	return nil, errors.New("columnar staging file parts are read-only")
+}
+
+func (*columnarStagingPartT) Write([]byte) (int, error) {
+	return 0, errors.New("columnar staging file parts are read-only")
+}
+
+func (*columnarStagingPartT) Close() error {
+	return nil
+}
+
+// ColumnarStagingTableReader reads the rows of a row group of a table of a columnar staging file
+type ColumnarStagingTableReader struct {
+	Table   string
+	Columns []ColumnarStagingColumnT
+	NumRows int64
+	pr      *reader.ParquetReader
+}
+
+// Next returns the next row group of the staging file, io.EOF after the last one.
+// A table has a row group for every columnarStagingRowGroupSize rows, each with the columns of its rows.
+func (r *ColumnarStagingFileReader) Next() (*ColumnarStagingTableReader, error) {
+	header, err := r.tarReader.Next()
+	if err != nil {
+		return nil, err
+	}
+	// the tar reader reads no further than the header, so the file is at the start of the parquet file
+	offset, err := r.file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	pr, err := reader.NewParquetColumnReader(newColumnarStagingPart(r.file, offset, header.Size), parquetParallelWriters)
+	if err != nil {
+		return nil, err
+	}
+
+	tableReader := &ColumnarStagingTableReader{NumRows: pr.GetNumRows(), pr: pr}
+	var hasTable, hasColumns bool
+	for _, kv := range pr.Footer.GetKeyValueMetadata() {
+		switch kv.GetKey() {
+		case columnarStagingTableKey:
+			tableReader.Table, hasTable = kv.GetValue(), true
+		case columnarStagingColumnsKey:
+			if err = json.Unmarshal([]byte(kv.GetValue()), &tableReader.Columns); err != nil {
+				return nil, err
+			}
+			hasColumns = true
+		}
+	}
+	if !hasTable || !hasColumns {
+		return nil, errors.New("columnar staging file is missing the schema of its table")
+	}
+	return tableReader, nil
+}
+
+func (r *ColumnarStagingFileReader) Close() error {
+	return r.file.Close()
+}
+
+func columnarEventValue(kind string, val interface{}) (interface{}, error) {
+	switch kind {
+	case ColumnarValueKindNumber, ColumnarValueKindBoolean, ColumnarValueKindString:
+		return val, nil
+	case ColumnarValueKindJSON:
+		str, ok := val.(string)
+		if !ok {
+			return nil, fmt.Errorf("json value %v is not a string", val)
+		}
+		var eventVal interface{}
+		err := json.Unmarshal([]byte(str), &eventVal)
+		return eventVal, err
+	}
+	return nil, fmt.Errorf("unknown kind of value: %s", kind)
+}
+
+// ReadRows calls fn with the column types and the data of each of the rows of the table, as in the events of the batch router
+func (t *ColumnarStagingTableReader) ReadRows(fn func(columns map[string]string, data map[string]interface{}) error) error {
+	values := make([][]interface{}, len(t.Columns))
+	for read := int64(0); read < t.NumRows; {
+		batchSize := t.NumRows - read
+		if batchSize > columnarStagingRowGroupSize {
+			batchSize = columnarStagingRowGroupSize
+		}
+		for i := range t.Columns {
+			columnValues, _, _, err := t.pr.ReadColumnByIndex(int64(i), batchSize)
+			if err != nil {
+				return err
+			}
+			if int64(len(columnValues)) != batchSize {
+				return fmt.Errorf("read %d values of column %s of table %s, expected %d", len(columnValues), t.Columns[i].Name, t.Table, batchSize)
+			}
+			values[i] = columnValues
+		}
+
+		for row := int64(0); row < batchSize; row++ {
+			columns := make(map[string]string)
+			data := make(map[string]interface{})
+			for i, column := range t.Columns {
+				val := values[i][row]
+				if val == nil {
+					continue
+				}
+				eventVal, err := columnarEventValue(column.Kind, val)
+				if err != nil {
+					return err
+				}
+				data[column.Name] = eventVal
+				if column.Type != "" {
+					columns[column.Name] = column.Type
+				}
+			}
+			if err := fn(columns, data); err != nil {
+				return err
+			}
+		}
+		read += batchSize
+	}
+	return nil
+}
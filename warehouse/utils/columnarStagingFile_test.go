@@ -0,0 +1,122 @@
+package warehouseutils_test
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	. "github.com/rudderlabs/rudder-server/warehouse/utils"
+)
+
+type columnarStagingRow struct {
+	columns map[string]string
+	data    map[string]interface{}
+}
+
+func TestColumnarStagingFile(t *testing.T) {
+	events := []string{
+		`{"metadata":{"table":"tracks","columns":{"id":"string","count":"int","props":"json","received_at":"datetime"}},"data":{"id":"1","count":2,"props":{"a":[1,"b"]},"received_at":"2022-10-01T00:00:00.000Z"}}`,
+		`{"metadata":{"table":"pages","columns":{"id":"string","url":"string"}},"data":{"id":"2","url":"https://rudderstack.com"}}`,
+		`{"metadata":{"table":"tracks","columns":{"id":"string","count":"string","active":"boolean","empty":"string"}},"data":{"id":"3","count":"two","active":true,"empty":null,"untyped":1.5}}`,
+	}
+
+	filePath := filepath.Join(t.TempDir(), "staging.parquet.tar")
+	writer, err := CreateColumnarStagingFile(filePath)
+	require.NoError(t, err)
+	for _, event := range events {
+		require.NoError(t, writer.WriteEvent([]byte(event)))
+	}
+	require.Error(t, writer.WriteEvent([]byte(`not json`)))
+	require.NoError(t, writer.Close())
+
+	reader, err := OpenColumnarStagingFile(filePath)
+	require.NoError(t, err)
+	defer func() { _ = reader.Close() }()
+
+	rows := make(map[string][]columnarStagingRow)
+	var tables []string
+	for {
+		tableReader, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		tables = append(tables, tableReader.Table)
+		err = tableReader.ReadRows(func(columns map[string]string, data map[string]interface{}) error {
+			rows[tableReader.Table] = append(rows[tableReader.Table], columnarStagingRow{columns: columns, data: data})
+			return nil
+		})
+		require.NoError(t, err)
+	}
+
+	require.Equal(t, []string{"tracks", "pages"}, tables)
+	require.Equal(t, []columnarStagingRow{
+		{
+			columns: map[string]string{"id": "string", "count": "int", "props": "json", "received_at": "datetime"},
+			data:    map[string]interface{}{"id": "1", "count": float64(2), "props": map[string]interface{}{"a": []interface{}{float64(1), "b"}}, "received_at": "2022-10-01T00:00:00.000Z"},
+		},
+		{
+			columns: map[string]string{"id": "string", "count": "string", "active": "boolean", "empty": "string"},
+			data:    map[string]interface{}{"id": "3", "count": "two", "active": true, "empty": nil, "untyped": 1.5},
+		},
+	}, rows["tracks"])
+	require.Equal(t, []columnarStagingRow{
+		{
+			columns: map[string]string{"id": "string", "url": "string"},
+			data:    map[string]interface{}{"id": "2", "url": "https://rudderstack.com"},
+		},
+	}, rows["pages"])
+}
+
+func TestColumnarStagingFileReadsInRowGroups(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "staging.parquet.tar")
+	writer, err := CreateColumnarStagingFile(filePath)
+	require.NoError(t, err)
+	const (
+		totalEvents  = 25001
+		rowGroupSize = 10000
+	)
+	for i := 0; i < totalEvents; i++ {
+		event := fmt.Sprintf(`{"metadata":{"table":"tracks","columns":{"id":"int"}},"data":{"id":%d}}`, i)
+		if i%2 == 0 {
+			event = fmt.Sprintf(`{"metadata":{"table":"tracks","columns":{"id":"int","even":"boolean"}},"data":{"id":%d,"even":true}}`, i)
+		}
+		require.NoError(t, writer.WriteEvent([]byte(event)))
+		if i == rowGroupSize-1 {
+			info, err := os.Stat(filePath)
+			require.NoError(t, err)
+			require.NotZero(t, info.Size(), "rows of a full row group should be flushed before close")
+		}
+	}
+	require.NoError(t, writer.Close())
+
+	reader, err := OpenColumnarStagingFile(filePath)
+	require.NoError(t, err)
+	defer func() { _ = reader.Close() }()
+
+	var next int
+	var rowGroups []int64
+	for {
+		tableReader, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		require.Equal(t, "tracks", tableReader.Table)
+		rowGroups = append(rowGroups, tableReader.NumRows)
+		err = tableReader.ReadRows(func(columns map[string]string, data map[string]interface{}) error {
+			require.Equal(t, float64(next), data["id"])
+			_, even := data["even"]
+			require.Equal(t, next%2 == 0, even)
+			next++
+			return nil
+		})
+		require.NoError(t, err)
+	}
+	require.Equal(t, totalEvents, next)
+	require.Equal(t, []int64{rowGroupSize, rowGroupSize, 5001}, rowGroups)
+}
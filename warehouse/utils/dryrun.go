@@ -0,0 +1,145 @@
+package warehouseutils
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"sync"
+)
+
+// StatementRecorderT keeps the statements executed on a dry-run database, in the order they were executed
+type StatementRecorderT struct {
+	mu         sync.Mutex
+	statements []string
+}
+
+func (r *StatementRecorderT) record(query string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.statements = append(r.statements, query)
+}
+
+// Flush returns the statements recorded since the last flush
+func (r *StatementRecorderT) Flush() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	statements := r.statements
+	r.statements = nil
+	return statements
+}
+
+// NewDryRunDB returns a database which records the statements run on it instead of executing them.
+// Queries answer a single row with a single zero value, so that existence checks and counts see an empty warehouse.
+func NewDryRunDB() (*sql.DB, *StatementRecorderT) {
+	recorder := &StatementRecorderT{}
+	db := sql.OpenDB(&dryRunConnector{recorder: recorder})
+	db.SetMaxOpenConns(1)
+	return db, recorder
+}
+
+type dryRunConnector struct {
+	recorder *StatementRecorderT
+}
+
+func (c *dryRunConnector) Connect(context.Context) (driver.Conn, error) {
+	return &dryRunConn{recorder: c.recorder}, nil
+}
+
+func (c *dryRunConnector) Driver() driver.Driver {
+	return dryRunDriver{connector: c}
+}
+
+type dryRunDriver struct {
+	connector *dryRunConnector
+}
+
+func (d dryRunDriver) Open(string) (driver.Conn, error) {
+	return d.connector.Connect(context.Background())
+}
+
+type dryRunConn struct {
+	recorder *StatementRecorderT
+}
+
+func (c *dryRunConn) Prepare(query string) (driver.Stmt, error) {
+	c.recorder.record(query)
+	return dryRunStmt{}, nil
+}
+
+func (*dryRunConn) Close() error {
+	return nil
+}
+
+func (*dryRunConn) Begin() (driver.Tx, error) {
+	return dryRunTx{}, nil
+}
+
+// CheckNamedValue accepts arguments of any type, since they are never sent anywhere
+func (*dryRunConn) CheckNamedValue(*driver.NamedValue) error {
+	return nil
+}
+
+func (c *dryRunConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	c.recorder.record(query)
+	return driver.RowsAffected(0), nil
+}
+
+func (c *dryRunConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	c.recorder.record(query)
+	return &dryRunRows{}, nil
+}
+
+// dryRunStmt is a statement prepared on a dry-run database, which is recorded once when prepared however many times it is executed
+type dryRunStmt struct{}
+
+func (dryRunStmt) Close() error {
+	return nil
+}
+
+func (dryRunStmt) NumInput() int {
+	return -1
+}
+
+func (dryRunStmt) Exec([]driver.Value) (driver.Result, error) {
+	return driver.RowsAffected(0), nil
+}
+
+func (dryRunStmt) Query([]driver.Value) (driver.Rows, error) {
+	return &dryRunRows{}, nil
+}
+
+func (dryRunStmt) CheckNamedValue(*driver.NamedValue) error {
+	return nil
+}
+
+type dryRunTx struct{}
+
+func (dryRunTx) Commit() error {
+	return nil
+}
+
+func (dryRunTx) Rollback() error {
+	return nil
+}
+
+type dryRunRows struct {
+	done bool
+}
+
+func (*dryRunRows) Columns() []string {
+	return []string{"?column?"}
+}
+
+func (*dryRunRows) Close() error {
+	return nil
+}
+
+func (r *dryRunRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = int64(0)
+	return nil
+}
@@ -0,0 +1,55 @@
+package warehouseutils_test
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/rudderlabs/rudder-server/warehouse/utils"
+)
+
+func TestDryRunDB(t *testing.T) {
+	db, recorder := NewDryRunDB()
+	defer func() { _ = db.Close() }()
+
+	var exists bool
+	err := db.QueryRow(`SELECT EXISTS (SELECT 1 FROM pg_catalog.pg_namespace WHERE nspname = 'namespace')`).Scan(&exists)
+	require.NoError(t, err)
+	require.False(t, exists, "existence checks see an empty warehouse")
+
+	var count int64
+	err = db.QueryRow(`SELECT count(*) FROM "namespace"."tracks"`).Scan(&count)
+	require.NoError(t, err)
+	require.Zero(t, count)
+
+	_, err = db.Exec(`CREATE SCHEMA IF NOT EXISTS "namespace"`)
+	require.NoError(t, err)
+
+	txn, err := db.Begin()
+	require.NoError(t, err)
+	stmt, err := txn.Prepare(`COPY "namespace"."tracks" ("id") FROM STDIN`)
+	require.NoError(t, err)
+	_, err = stmt.Exec("1")
+	require.NoError(t, err)
+	_, err = stmt.Exec()
+	require.NoError(t, err)
+	require.NoError(t, stmt.Close())
+	_, err = txn.Exec(`DELETE FROM "namespace"."tracks" WHERE "id" = ANY($1)`, pq.Array([]string{"1"}))
+	require.NoError(t, err)
+	require.NoError(t, txn.Commit())
+
+	require.Equal(t, []string{
+		`SELECT EXISTS (SELECT 1 FROM pg_catalog.pg_namespace WHERE nspname = 'namespace')`,
+		`SELECT count(*) FROM "namespace"."tracks"`,
+		`CREATE SCHEMA IF NOT EXISTS "namespace"`,
+		`COPY "namespace"."tracks" ("id") FROM STDIN`,
+		`DELETE FROM "namespace"."tracks" WHERE "id" = ANY($1)`,
+	}, recorder.Flush())
+	require.Empty(t, recorder.Flush(), "flushing clears the recorded statements")
+
+	_, err = db.Exec(`ALTER TABLE "namespace"."tracks" ADD COLUMN "name" text`, sql.Named("unused", 1))
+	require.NoError(t, err)
+	require.Equal(t, []string{`ALTER TABLE "namespace"."tracks" ADD COLUMN "name" text`}, recorder.Flush())
+}
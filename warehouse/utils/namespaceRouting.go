@@ -0,0 +1,98 @@
+package warehouseutils
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tidwall/gjson"
+
+	backendconfig "github.com/rudderlabs/rudder-server/config/backend-config"
+)
+
+// routedNamespaceMaxLength is the length of the namespace columns of the warehouse tables
+const routedNamespaceMaxLength = 64
+
+// NamespaceRoutingT routes the events of a source into several namespaces by the value of an event field, e.g.
+// {"namespaceRoutingField": "context.tenant", "namespaceRoutingRules": [{"value": "acme", "namespace": "acme_prod"}]}.
+// Values can also be routed to the namespace of the same name by allowing them, e.g.
+// {"namespaceRoutingAllowedValues": ["acme", "globex"]}. Since the field is set by the clients sending the events,
+// only the configured values are routed: events without the field, or with any other value, stay in the default
+// namespace of the destination.
+type NamespaceRoutingT struct {
+	Field   string
+	Rules   map[string]string
+	Allowed map[string]struct{}
+}
+
+// GetNamespaceRouting returns the namespace routing configured for the destination, if any.
+// Routing is disabled unless rules or allowed values are configured along with the field.
+func GetNamespaceRouting(destination backendconfig.DestinationT) (routing NamespaceRoutingT, ok bool) {
+	field, _ := destination.Config[NamespaceRoutingField].(string)
+	routing.Field = strings.TrimSpace(field)
+	if routing.Field == "" {
+		return NamespaceRoutingT{}, false
+	}
+
+	rules, _ := destination.Config[NamespaceRoutingRules].([]interface{})
+	for _, ruleI := range rules {
+		rule, ok := ruleI.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		value := fmt.Sprint(rule["value"])
+		namespace, _ := rule["namespace"].(string)
+		if rule["value"] == nil || strings.TrimSpace(namespace) == "" {
+			continue
+		}
+		if routing.Rules == nil {
+			routing.Rules = make(map[string]string)
+		}
+		routing.Rules[value] = namespace
+	}
+
+	allowed, _ := destination.Config[NamespaceRoutingAllowed].([]interface{})
+	for _, valueI := range allowed {
+		if valueI == nil {
+			continue
+		}
+		value := fmt.Sprint(valueI)
+		if strings.TrimSpace(value) == "" {
+			continue
+		}
+		if routing.Allowed == nil {
+			routing.Allowed = make(map[string]struct{})
+		}
+		routing.Allowed[value] = struct{}{}
+	}
+
+	if routing.Rules == nil && routing.Allowed == nil {
+		return NamespaceRoutingT{}, false
+	}
+	return routing, true
+}
+
+// RouteEvent returns the namespace the transformed event is routed to, empty for the default namespace.
+// The field is looked up in the columns of the event, where nested properties are flattened, e.g. context.tenant into context_tenant.
+func (routing NamespaceRoutingT) RouteEvent(provider string, payload []byte) string {
+	column := strings.ReplaceAll(routing.Field, ".", "_")
+	value := gjson.GetBytes(payload, "data."+ToProviderCase(provider, column))
+	if !value.Exists() {
+		value = gjson.GetBytes(payload, "data."+column)
+	}
+	if !value.Exists() || value.Type == gjson.Null || value.String() == "" {
+		return ""
+	}
+
+	namespace, ok := routing.Rules[value.String()]
+	if !ok {
+		if _, ok = routing.Allowed[value.String()]; !ok {
+			return ""
+		}
+		namespace = value.String()
+	}
+	namespace = ToSafeNamespace(provider, namespace)
+	if len(namespace) > routedNamespaceMaxLength {
+		namespace = namespace[:routedNamespaceMaxLength]
+	}
+	return ToProviderCase(provider, namespace)
+}
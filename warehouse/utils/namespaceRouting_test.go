@@ -0,0 +1,127 @@
+package warehouseutils_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	backendconfig "github.com/rudderlabs/rudder-server/config/backend-config"
+	. "github.com/rudderlabs/rudder-server/warehouse/utils"
+)
+
+func TestGetNamespaceRouting(t *testing.T) {
+	_, ok := GetNamespaceRouting(backendconfig.DestinationT{Config: map[string]interface{}{}})
+	require.False(t, ok)
+
+	_, ok = GetNamespaceRouting(backendconfig.DestinationT{Config: map[string]interface{}{"namespaceRoutingField": " "}})
+	require.False(t, ok)
+
+	_, ok = GetNamespaceRouting(backendconfig.DestinationT{Config: map[string]interface{}{"namespaceRoutingField": "context.tenant"}})
+	require.False(t, ok, "values of the field should not be routed unless configured")
+
+	routing, ok := GetNamespaceRouting(backendconfig.DestinationT{Config: map[string]interface{}{
+		"namespaceRoutingField":         "context.tenant",
+		"namespaceRoutingAllowedValues": []interface{}{"acme", 42.0, " ", nil},
+	}})
+	require.True(t, ok)
+	require.Equal(t, NamespaceRoutingT{
+		Field:   "context.tenant",
+		Allowed: map[string]struct{}{"acme": {}, "42": {}},
+	}, routing)
+
+	routing, ok = GetNamespaceRouting(backendconfig.DestinationT{Config: map[string]interface{}{
+		"namespaceRoutingField": "context.tenant",
+		"namespaceRoutingRules": []interface{}{
+			map[string]interface{}{"value": "acme", "namespace": "acme_prod"},
+			map[string]interface{}{"value": 42.0, "namespace": "answer"},
+			map[string]interface{}{"value": "invalid", "namespace": ""},
+			map[string]interface{}{"namespace": "invalid"},
+			"invalid",
+		},
+	}})
+	require.True(t, ok)
+	require.Equal(t, NamespaceRoutingT{
+		Field: "context.tenant",
+		Rules: map[string]string{"acme": "acme_prod", "42": "answer"},
+	}, routing)
+}
+
+func TestNamespaceRoutingRouteEvent(t *testing.T) {
+	testCases := []struct {
+		name     string
+		routing  NamespaceRoutingT
+		provider string
+		payload  string
+		expected string
+	}{
+		{
+			name:     "allowed value of the field",
+			routing:  NamespaceRoutingT{Field: "context.tenant", Allowed: map[string]struct{}{"Acme Inc": {}}},
+			provider: POSTGRES,
+			payload:  `{"metadata":{"table":"tracks"},"data":{"context_tenant":"Acme Inc"}}`,
+			expected: "acme_inc",
+		},
+		{
+			name:     "value of the field not allowed",
+			routing:  NamespaceRoutingT{Field: "context.tenant", Allowed: map[string]struct{}{"Acme Inc": {}}},
+			provider: POSTGRES,
+			payload:  `{"metadata":{"table":"tracks"},"data":{"context_tenant":"evil"}}`,
+			expected: "",
+		},
+		{
+			name:     "value of the field without rules",
+			routing:  NamespaceRoutingT{Field: "context.tenant"},
+			provider: POSTGRES,
+			payload:  `{"metadata":{"table":"tracks"},"data":{"context_tenant":"acme"}}`,
+			expected: "",
+		},
+		{
+			name:     "provider case",
+			routing:  NamespaceRoutingT{Field: "context.tenant", Allowed: map[string]struct{}{"acme": {}}},
+			provider: SNOWFLAKE,
+			payload:  `{"metadata":{"table":"TRACKS"},"data":{"CONTEXT_TENANT":"acme"}}`,
+			expected: "ACME",
+		},
+		{
+			name:     "long value",
+			routing:  NamespaceRoutingT{Field: "tenant", Allowed: map[string]struct{}{strings.Repeat("a", 100): {}}},
+			provider: POSTGRES,
+			payload:  `{"metadata":{"table":"tracks"},"data":{"tenant":"` + strings.Repeat("a", 100) + `"}}`,
+			expected: strings.Repeat("a", 64),
+		},
+		{
+			name:     "missing field",
+			routing:  NamespaceRoutingT{Field: "context.tenant"},
+			provider: POSTGRES,
+			payload:  `{"metadata":{"table":"users"},"data":{"id":"1"}}`,
+			expected: "",
+		},
+		{
+			name:     "null field",
+			routing:  NamespaceRoutingT{Field: "context.tenant"},
+			provider: POSTGRES,
+			payload:  `{"metadata":{"table":"tracks"},"data":{"context_tenant":null}}`,
+			expected: "",
+		},
+		{
+			name:     "matching rule",
+			routing:  NamespaceRoutingT{Field: "tenant", Rules: map[string]string{"acme": "acme_prod", "42": "answer"}},
+			provider: POSTGRES,
+			payload:  `{"metadata":{"table":"tracks"},"data":{"tenant":42}}`,
+			expected: "answer",
+		},
+		{
+			name:     "no matching rule",
+			routing:  NamespaceRoutingT{Field: "tenant", Rules: map[string]string{"acme": "acme_prod"}},
+			provider: POSTGRES,
+			payload:  `{"metadata":{"table":"tracks"},"data":{"tenant":"globex"}}`,
+			expected: "",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, tc.routing.RouteEvent(tc.provider, []byte(tc.payload)))
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package warehouseutils
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+const (
+	RetentionMethodPartitionDrop = "partition_drop"
+	RetentionMethodBatchedDelete = "batched_delete"
+	RetentionMethodDeleteVacuum  = "delete_vacuum"
+)
+
+// RetentionParamsT describes the rows of a table to prune, i.e. the ones with Column older than Before
+type RetentionParamsT struct {
+	Column      string
+	Before      time.Time
+	BatchWindow time.Duration
+}
+
+// RetentionStatsT is the outcome of pruning a table
+type RetentionStatsT struct {
+	Method            string
+	RowsDeleted       int64
+	PartitionsDropped int64
+}
+
+// BatchedDeleteT deletes the rows of a table older than a time, one window of time at a time starting from the oldest row,
+// so that no single statement has to delete the whole history of the table.
+type BatchedDeleteT struct {
+	Db *sql.DB
+	// OldestQuery selects the oldest value of the retention column in the table
+	OldestQuery string
+	// DeleteQuery deletes the rows with the retention column in [from, to), taking both as arguments in the placeholders of the warehouse
+	DeleteQuery string
+	// TimeArg converts the bounds of a window to query arguments, for drivers which cannot compare the stored values with time.Time
+	TimeArg func(time.Time) interface{}
+}
+
+func (bd *BatchedDeleteT) Run(ctx context.Context, params RetentionParamsT) (stats RetentionStatsT, err error) {
+	stats.Method = RetentionMethodBatchedDelete
+
+	var oldestValue interface{}
+	if err = bd.Db.QueryRowContext(ctx, bd.OldestQuery).Scan(&oldestValue); err != nil {
+		return stats, fmt.Errorf("fetching oldest %s: %w", params.Column, err)
+	}
+	oldest, ok, err := retentionTime(oldestValue)
+	if err != nil {
+		return stats, fmt.Errorf("fetching oldest %s: %w", params.Column, err)
+	}
+	if !ok || !oldest.Before(params.Before) {
+		return stats, nil
+	}
+
+	window := params.BatchWindow
+	if window <= 0 {
+		window = 24 * time.Hour
+	}
+	timeArg := bd.TimeArg
+	if timeArg == nil {
+		timeArg = func(t time.Time) interface{} { return t }
+	}
+	for from := oldest.Truncate(window); from.Before(params.Before); from = from.Add(window) {
+		to := from.Add(window)
+		if to.After(params.Before) {
+			to = params.Before
+		}
+		result, err := bd.Db.ExecContext(ctx, bd.DeleteQuery, timeArg(from), timeArg(to))
+		if err != nil {
+			return stats, fmt.Errorf("deleting rows with %s in [%s, %s): %w", params.Column, from.Format(time.RFC3339), to.Format(time.RFC3339), err)
+		}
+		if rowsDeleted, err := result.RowsAffected(); err == nil {
+			stats.RowsDeleted += rowsDeleted
+		}
+	}
+	return stats, nil
+}
+
+// retentionTime converts the oldest value of a retention column to a time, since drivers scan timestamps without type information as text
+func retentionTime(value interface{}) (time.Time, bool, error) {
+	switch v := value.(type) {
+	case nil:
+		return time.Time{}, false, nil
+	case time.Time:
+		return v.UTC(), true, nil
+	case []byte:
+		return retentionTime(string(v))
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999-07:00", "2006-01-02 15:04:05.999999999", "2006-01-02"} {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t.UTC(), true, nil
+			}
+		}
+		return time.Time{}, false, fmt.Errorf("unsupported time format: %s", v)
+	}
+	return time.Time{}, false, fmt.Errorf("unsupported time type: %T", value)
+}
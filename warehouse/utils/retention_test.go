@@ -0,0 +1,71 @@
+package warehouseutils_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+
+	. "github.com/rudderlabs/rudder-server/warehouse/utils"
+)
+
+func TestBatchedDelete(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	_, err = db.Exec(`CREATE TABLE "pages" ("id" TEXT, "received_at" DATETIME)`)
+	require.NoError(t, err)
+
+	batchedDelete := BatchedDeleteT{
+		Db:          db,
+		OldestQuery: `SELECT MIN("received_at") FROM "pages"`,
+		DeleteQuery: `DELETE FROM "pages" WHERE "received_at" >= ? AND "received_at" < ?`,
+		TimeArg: func(t time.Time) interface{} {
+			return t.UTC().Format(time.RFC3339)
+		},
+	}
+	params := RetentionParamsT{
+		Column:      "received_at",
+		Before:      time.Date(2022, 10, 10, 12, 0, 0, 0, time.UTC),
+		BatchWindow: 24 * time.Hour,
+	}
+
+	stats, err := batchedDelete.Run(context.Background(), params)
+	require.NoError(t, err)
+	require.Equal(t, RetentionStatsT{Method: RetentionMethodBatchedDelete}, stats, "empty tables have nothing to delete")
+
+	for id, receivedAt := range map[string]string{
+		"1": "2022-09-01T10:00:00.000Z",
+		"2": "2022-10-09T23:59:59.000Z",
+		"3": "2022-10-10T11:00:00.000Z",
+		"4": "2022-10-10T12:30:00.000Z",
+		"5": "2022-10-11T00:00:00.000Z",
+	} {
+		_, err = db.Exec(`INSERT INTO "pages" VALUES (?, ?)`, id, receivedAt)
+		require.NoError(t, err)
+	}
+
+	stats, err = batchedDelete.Run(context.Background(), params)
+	require.NoError(t, err)
+	require.Equal(t, RetentionStatsT{Method: RetentionMethodBatchedDelete, RowsDeleted: 3}, stats)
+
+	var remaining []string
+	rows, err := db.Query(`SELECT "id" FROM "pages" ORDER BY "id"`)
+	require.NoError(t, err)
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		var id string
+		require.NoError(t, rows.Scan(&id))
+		remaining = append(remaining, id)
+	}
+	require.NoError(t, rows.Err())
+	require.Equal(t, []string{"4", "5"}, remaining)
+
+	stats, err = batchedDelete.Run(context.Background(), params)
+	require.NoError(t, err)
+	require.Zero(t, stats.RowsDeleted, "pruning again must not delete newer rows")
+}
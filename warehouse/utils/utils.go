@@ -5,6 +5,7 @@ import (
 	"context"
 	"crypto/sha512"
 	"database/sql"
+	"encoding/csv"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -46,6 +47,7 @@ const (
 	S3_DATALAKE    = "S3_DATALAKE"
 	GCS_DATALAKE   = "GCS_DATALAKE"
 	AZURE_DATALAKE = "AZURE_DATALAKE"
+	SQLITE         = "SQLITE"
 )
 
 const (
@@ -58,12 +60,18 @@ const (
 
 // warehouse table names
 const (
-	WarehouseStagingFilesTable = "wh_staging_files"
-	WarehouseLoadFilesTable    = "wh_load_files"
-	WarehouseUploadsTable      = "wh_uploads"
-	WarehouseTableUploadsTable = "wh_table_uploads"
-	WarehouseSchemasTable      = "wh_schemas"
-	WarehouseAsyncJobTable     = "wh_async_jobs"
+	WarehouseStagingFilesTable        = "wh_staging_files"
+	WarehouseLoadFilesTable           = "wh_load_files"
+	WarehouseUploadsTable             = "wh_uploads"
+	WarehouseTableUploadsTable        = "wh_table_uploads"
+	WarehouseSchemasTable             = "wh_schemas"
+	WarehouseAsyncJobTable            = "wh_async_jobs"
+	WarehouseSchemaHistoryTable       = "wh_schema_history"
+	WarehouseRetentionRunsTable       = "wh_retention_runs"
+	WarehouseReverseETLRunsTable      = "wh_reverse_etl_runs"
+	WarehouseReverseETLSnapshotsTable = "wh_reverse_etl_snapshots"
+	WarehouseSLABreachesTable         = "wh_sla_breaches"
+	WarehouseUsageTable               = "wh_usage"
 )
 
 const (
@@ -72,9 +80,27 @@ const (
 	IdentityMappingsTable   = "rudder_identity_mappings"
 	SyncFrequency           = "syncFrequency"
 	SyncStartAt             = "syncStartAt"
+	SyncCron                = "syncCron"
+	SyncTimezone            = "syncTimezone"
+	SyncMaxPendingEvents    = "syncMaxPendingEvents"
+	SyncMaxStagingFileAge   = "syncMaxStagingFileAge"
+	SLAMaxDataLag           = "slaMaxDataLag"
+	SLAMaxConsecutiveFails  = "slaMaxConsecutiveFailures"
+	SLASuccessBefore        = "slaSuccessBefore"
 	ExcludeWindow           = "excludeWindow"
 	ExcludeWindowStartTime  = "excludeWindowStartTime"
 	ExcludeWindowEndTime    = "excludeWindowEndTime"
+	LoadModeConfig          = "loadMode"
+	MergePrimaryKeysConfig  = "mergePrimaryKeys"
+	NamespaceRoutingField   = "namespaceRoutingField"
+	NamespaceRoutingRules   = "namespaceRoutingRules"
+	NamespaceRoutingAllowed = "namespaceRoutingAllowedValues"
+)
+
+// load modes of the warehouse destinations
+const (
+	LoadModeAppend = "append"
+	LoadModeMerge  = "merge"
 )
 
 const (
@@ -130,8 +156,10 @@ const (
 
 var (
 	IdentityEnabledWarehouses []string
-	enableIDResolution        bool
-	AWSCredsExpiryInS         int64
+	// ColumnTypeEvolutionWarehouses are the warehouses in which the type of columns can be widened
+	ColumnTypeEvolutionWarehouses []string
+	enableIDResolution            bool
+	AWSCredsExpiryInS             int64
 )
 
 var WHDestNameMap = map[string]string{
@@ -146,6 +174,7 @@ var WHDestNameMap = map[string]string{
 	GCS_DATALAKE:   "gcs_datalake",
 	AZURE_DATALAKE: "azure_datalake",
 	AZURE_SYNAPSE:  "azure_synapse",
+	SQLITE:         "sqlite",
 }
 
 var ObjectStorageMap = map[string]string{
@@ -197,9 +226,10 @@ func Init() {
 }
 
 func loadConfig() {
-	IdentityEnabledWarehouses = []string{SNOWFLAKE, BQ}
+	IdentityEnabledWarehouses = []string{SNOWFLAKE, BQ, POSTGRES, CLICKHOUSE, MSSQL, AZURE_SYNAPSE}
+	ColumnTypeEvolutionWarehouses = []string{RS, BQ, SNOWFLAKE, POSTGRES, CLICKHOUSE, MSSQL, AZURE_SYNAPSE}
 	TimeWindowDestinations = []string{S3_DATALAKE, GCS_DATALAKE, AZURE_DATALAKE}
-	WarehouseDestinations = []string{RS, BQ, SNOWFLAKE, POSTGRES, CLICKHOUSE, MSSQL, AZURE_SYNAPSE, S3_DATALAKE, GCS_DATALAKE, AZURE_DATALAKE, DELTALAKE}
+	WarehouseDestinations = []string{RS, BQ, SNOWFLAKE, POSTGRES, CLICKHOUSE, MSSQL, AZURE_SYNAPSE, S3_DATALAKE, GCS_DATALAKE, AZURE_DATALAKE, DELTALAKE, SQLITE}
 	config.RegisterBoolConfigVariable(false, &enableIDResolution, false, "Warehouse.enableIDResolution")
 	config.RegisterInt64ConfigVariable(3600, &AWSCredsExpiryInS, true, 1, "Warehouse.awsCredsExpiryInS")
 	config.RegisterIntConfigVariable(10240, &maxStagingFileReadBufferCapacityInK, false, 1, "Warehouse.maxStagingFileReadBufferCapacityInK")
@@ -214,6 +244,10 @@ type Warehouse struct {
 	Namespace   string
 	Type        string
 	Identifier  string
+	// NamespaceRouting is set when the events of the source are routed into several namespaces by event field,
+	// the warehouse then only loads the staging files routed to RoutedNamespace, empty for the default namespace
+	NamespaceRouting bool
+	RoutedNamespace  string
 }
 
 type DeleteByMetaData struct {
@@ -239,6 +273,36 @@ func (w *Warehouse) GetBoolDestinationConfig(key string) bool {
 	return false
 }
 
+// LoadMode returns the load mode configured for the destination, falling back to defaultMode if it is missing or invalid.
+func (w *Warehouse) LoadMode(defaultMode string) string {
+	switch mode := strings.ToLower(strings.TrimSpace(GetConfigValue(LoadModeConfig, *w))); mode {
+	case LoadModeAppend, LoadModeMerge:
+		return mode
+	}
+	return defaultMode
+}
+
+// MergePrimaryKeys returns the columns deduplicating the rows of tableName in the merge load mode.
+// Comma separated columns configured for the table in mergePrimaryKeys of the destination take precedence over defaultKeys.
+func (w *Warehouse) MergePrimaryKeys(tableName string, defaultKeys []string) []string {
+	for table, value := range GetConfigValueAsMap(MergePrimaryKeysConfig, w.Destination.Config) {
+		columns, ok := value.(string)
+		if !ok || !strings.EqualFold(table, tableName) {
+			continue
+		}
+		var keys []string
+		for _, column := range strings.Split(columns, ",") {
+			if column = strings.TrimSpace(column); column != "" {
+				keys = append(keys, ToProviderCase(w.Type, column))
+			}
+		}
+		if len(keys) > 0 {
+			return keys
+		}
+	}
+	return defaultKeys
+}
+
 type DestinationT struct {
 	Source      backendconfig.SourceT
 	Destination backendconfig.DestinationT
@@ -264,6 +328,9 @@ type StagingFile struct {
 	TotalEvents           int
 	UseRudderStorage      bool
 	DestinationRevisionID string
+	StagingFileFormat     string
+	// Namespace the events of the staging file were routed to, empty for the default namespace of the destination
+	Namespace string
 	// cloud sources specific info
 	SourceBatchID   string
 	SourceTaskID    string
@@ -311,6 +378,74 @@ type TableSchemaDiffT struct {
 	ColumnMap                      map[string]string
 	UpdatedSchema                  map[string]string
 	StringColumnsToBeAlteredToText []string
+	// AlteredColumnMap contains the columns whose type has to be widened along with their new type
+	AlteredColumnMap map[string]string
+}
+
+// Strategies used to change the type of columns
+const (
+	// AlterColumnInPlace changes the type of the column with a single ALTER
+	AlterColumnInPlace = "in_place"
+	// AlterColumnShadowColumn backfills a new column of the new type and swaps it with the column,
+	// for warehouses not supporting the change of the type in place
+	AlterColumnShadowColumn = "shadow_column"
+)
+
+// AlterColumnResponseT describes how the type of a column has been changed by AlterColumn.
+// Strategy is empty if nothing had to be done, e.g. if the types are the same in the warehouse.
+type AlterColumnResponseT struct {
+	Strategy string
+	Queries  []string
+}
+
+const (
+	// ShadowColumnSuffix is the suffix of the columns backfilled with the values of a column whose type is being changed
+	ShadowColumnSuffix = "_rudder_shadow"
+	// BackupColumnSuffix is the suffix the column whose type is being changed is renamed to until its shadow column replaces it
+	BackupColumnSuffix = "_rudder_backup"
+)
+
+// ShadowColumnStateT tells which of a column, its shadow column and its backup column are in the warehouse.
+// Since the queries of a swap are not run in a transaction by every warehouse, a swap interrupted by a crash
+// leaves a shadow or a backup column behind, which has to be recovered before retrying the swap.
+type ShadowColumnStateT struct {
+	Column, ShadowColumn, BackupColumn bool
+}
+
+// GetShadowColumnState returns the state of the swap of column with shadowColumn in the schema of the table in the warehouse
+func GetShadowColumnState(schema TableSchemaT, column, shadowColumn, backupColumn string) ShadowColumnStateT {
+	_, hasColumn := schema[column]
+	_, hasShadowColumn := schema[shadowColumn]
+	_, hasBackupColumn := schema[backupColumn]
+	return ShadowColumnStateT{Column: hasColumn, ShadowColumn: hasShadowColumn, BackupColumn: hasBackupColumn}
+}
+
+// ShadowColumnQueries returns the queries changing the type of column in table by adding a shadow column of the
+// new type, backfilling it with castExpression and swapping it with column. The column is renamed to backupColumn
+// rather than dropped before the shadow column takes its name, so that its values are never lost, and the backup is
+// dropped only once the swap is done. Table and columns are expected to be quoted already.
+//
+// The leftovers of a previously interrupted swap are recovered first according to state: a backup column is renamed
+// back to column if the shadow column never replaced it, then the shadow column and a stale backup column are dropped.
+func ShadowColumnQueries(table, column, shadowColumn, backupColumn, columnType, castExpression string, state ShadowColumnStateT) (queries []string) {
+	if state.BackupColumn && !state.Column {
+		// interrupted after the column was renamed to the backup and before the shadow column was renamed
+		queries = append(queries, fmt.Sprintf(`ALTER TABLE %s RENAME COLUMN %s TO %s`, table, backupColumn, column))
+	} else if state.BackupColumn {
+		// interrupted after the shadow column was renamed, the column has every value already
+		queries = append(queries, fmt.Sprintf(`ALTER TABLE %s DROP COLUMN %s`, table, backupColumn))
+	}
+	if state.ShadowColumn {
+		queries = append(queries, fmt.Sprintf(`ALTER TABLE %s DROP COLUMN %s`, table, shadowColumn))
+	}
+	return append(queries,
+		fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, shadowColumn, columnType),
+		// WHERE clause is mandatory in BigQuery
+		fmt.Sprintf(`UPDATE %s SET %s = %s WHERE TRUE`, table, shadowColumn, castExpression),
+		fmt.Sprintf(`ALTER TABLE %s RENAME COLUMN %s TO %s`, table, column, backupColumn),
+		fmt.Sprintf(`ALTER TABLE %s RENAME COLUMN %s TO %s`, table, shadowColumn, column),
+		fmt.Sprintf(`ALTER TABLE %s DROP COLUMN %s`, table, backupColumn),
+	)
 }
 
 type QueryResult struct {
@@ -334,6 +469,20 @@ type TriggerUploadRequestT struct {
 	DestinationID string `json:"destination_id"`
 }
 
+type DryRunRequestT struct {
+	WorkspaceID   string `json:"workspace_id"`
+	SourceID      string `json:"source_id"`
+	DestinationID string `json:"destination_id"`
+}
+
+type QueryRequestT struct {
+	WorkspaceID   string `json:"workspace_id"`
+	SourceID      string `json:"source_id"`
+	DestinationID string `json:"destination_id"`
+	SQLStatement  string `json:"sql_statement"`
+	Limit         int32  `json:"limit"`
+}
+
 type LoadFileWriterI interface {
 	WriteGZ(s string) error
 	Write(p []byte) (int, error)
@@ -735,6 +884,73 @@ func IdentityMappingsUniqueMappingConstraintName(warehouse Warehouse) string {
 	return fmt.Sprintf(`unique_merge_property_%s_%s`, warehouse.Namespace, warehouse.Destination.ID)
 }
 
+// IdentityRulesTables are the event tables from which the distinct anonymous_id, user_id
+// combinations are downloaded while populating historic identities.
+var IdentityRulesTables = []string{"tracks", "pages", "screens", "identifies", "aliases"}
+
+// IdentityRulesSelectFields returns the fields selecting anonymous_id and user_id from a table having tableSchema.
+// Missing columns are selected as NULL. ok is false when the table has neither of the columns.
+func IdentityRulesSelectFields(tableSchema TableSchemaT) (fields string, ok bool) {
+	_, hasAnonymousID := tableSchema["anonymous_id"]
+	_, hasUserID := tableSchema["user_id"]
+	switch {
+	case hasAnonymousID && hasUserID:
+		return `"anonymous_id", "user_id"`, true
+	case hasAnonymousID:
+		return `"anonymous_id", NULL AS "user_id"`, true
+	case hasUserID:
+		return `NULL AS "anonymous_id", "user_id"`, true
+	}
+	return "", false
+}
+
+// WriteIdentityRules writes the anonymous_id, user_id combinations scanned from rows as identity merge rules to gzWriter.
+// Rows having neither of the ids are skipped.
+func WriteIdentityRules(rows *sql.Rows, gzWriter *misc.GZipWriter) error {
+	defer rows.Close()
+	for rows.Next() {
+		var anonymousID, userID sql.NullString
+		if err := rows.Scan(&anonymousID, &userID); err != nil {
+			return err
+		}
+		row, ok := IdentityMergeRule(anonymousID, userID)
+		if !ok {
+			continue
+		}
+		if err := gzWriter.WriteGZ(row); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// IdentityMergeRule returns the csv row of the merge rule between anonymousID and userID.
+// Empty ids are treated as missing, as warehouses like clickhouse store them for non-nullable columns.
+func IdentityMergeRule(anonymousID, userID sql.NullString) (string, bool) {
+	hasAnonymousID := anonymousID.Valid && anonymousID.String != ""
+	hasUserID := userID.Valid && userID.String != ""
+	if !hasAnonymousID && !hasUserID {
+		return "", false
+	}
+
+	var csvRow []string
+	// avoid setting null merge_property_1 to avoid not null constraint in local postgres
+	if hasAnonymousID {
+		csvRow = []string{"anonymous_id", anonymousID.String, "user_id", ""}
+		if hasUserID {
+			csvRow[3] = userID.String
+		}
+	} else {
+		csvRow = []string{"user_id", userID.String, "anonymous_id", ""}
+	}
+
+	var buff bytes.Buffer
+	csvWriter := csv.NewWriter(&buff)
+	_ = csvWriter.Write(csvRow)
+	csvWriter.Flush()
+	return buff.String(), true
+}
+
 func GetWarehouseIdentifier(destType, sourceID, destinationID string) string {
 	return fmt.Sprintf("%s:%s:%s", destType, sourceID, destinationID)
 }
@@ -834,6 +1050,16 @@ func NewCounterStat(name string, extraTags ...Tag) stats.Measurement {
 	return stats.Default.NewTaggedStat(name, stats.CountType, tags)
 }
 
+func NewGaugeStat(name string, extraTags ...Tag) stats.Measurement {
+	tags := map[string]string{
+		"module": "warehouse",
+	}
+	for _, extraTag := range extraTags {
+		tags[extraTag.Name] = extraTag.Value
+	}
+	return stats.Default.NewTaggedStat(name, stats.GaugeType, tags)
+}
+
 func WHCounterStat(name string, warehouse *Warehouse, extraTags ...Tag) stats.Measurement {
 	tags := map[string]string{
 		"module":      WAREHOUSE,
@@ -1398,6 +1398,187 @@ var _ = Describe("Utils", func() {
 	})
 })
 
+func TestShadowColumnQueries(t *testing.T) {
+	swap := []string{
+		`ALTER TABLE "ns"."t" ADD COLUMN "c_rudder_shadow" varchar(512)`,
+		`UPDATE "ns"."t" SET "c_rudder_shadow" = "c"::varchar(512) WHERE TRUE`,
+		`ALTER TABLE "ns"."t" RENAME COLUMN "c" TO "c_rudder_backup"`,
+		`ALTER TABLE "ns"."t" RENAME COLUMN "c_rudder_shadow" TO "c"`,
+		`ALTER TABLE "ns"."t" DROP COLUMN "c_rudder_backup"`,
+	}
+	queries := func(state ShadowColumnStateT) []string {
+		return ShadowColumnQueries(`"ns"."t"`, `"c"`, `"c_rudder_shadow"`, `"c_rudder_backup"`, "varchar(512)", `"c"::varchar(512)`, state)
+	}
+
+	require.Equal(t, swap, queries(ShadowColumnStateT{Column: true}))
+
+	// interrupted before the column was renamed
+	require.Equal(t, append([]string{
+		`ALTER TABLE "ns"."t" DROP COLUMN "c_rudder_shadow"`,
+	}, swap...), queries(ShadowColumnStateT{Column: true, ShadowColumn: true}))
+
+	// interrupted after the column was renamed, the backup is the only copy of the values
+	require.Equal(t, append([]string{
+		`ALTER TABLE "ns"."t" RENAME COLUMN "c_rudder_backup" TO "c"`,
+		`ALTER TABLE "ns"."t" DROP COLUMN "c_rudder_shadow"`,
+	}, swap...), queries(ShadowColumnStateT{ShadowColumn: true, BackupColumn: true}))
+
+	// interrupted after the shadow column was renamed
+	require.Equal(t, append([]string{
+		`ALTER TABLE "ns"."t" DROP COLUMN "c_rudder_backup"`,
+	}, swap...), queries(ShadowColumnStateT{Column: true, BackupColumn: true}))
+}
+
+func TestGetShadowColumnState(t *testing.T) {
+	schema := TableSchemaT{"c": "int", "c_rudder_backup": "int", "d_rudder_shadow": "string"}
+	require.Equal(t, ShadowColumnStateT{Column: true, BackupColumn: true}, GetShadowColumnState(schema, "c", "c_rudder_shadow", "c_rudder_backup"))
+	require.Equal(t, ShadowColumnStateT{ShadowColumn: true}, GetShadowColumnState(schema, "d", "d_rudder_shadow", "d_rudder_backup"))
+}
+
+func TestIdentityRulesSelectFields(t *testing.T) {
+	fields, ok := IdentityRulesSelectFields(TableSchemaT{"anonymous_id": "string", "user_id": "string", "event": "string"})
+	require.True(t, ok)
+	require.Equal(t, `"anonymous_id", "user_id"`, fields)
+
+	fields, ok = IdentityRulesSelectFields(TableSchemaT{"anonymous_id": "string"})
+	require.True(t, ok)
+	require.Equal(t, `"anonymous_id", NULL AS "user_id"`, fields)
+
+	fields, ok = IdentityRulesSelectFields(TableSchemaT{"user_id": "string"})
+	require.True(t, ok)
+	require.Equal(t, `NULL AS "anonymous_id", "user_id"`, fields)
+
+	_, ok = IdentityRulesSelectFields(TableSchemaT{"event": "string"})
+	require.False(t, ok)
+}
+
+func TestIdentityMergeRule(t *testing.T) {
+	inputs := []struct {
+		anonymousID sql.NullString
+		userID      sql.NullString
+		row         string
+		ok          bool
+	}{
+		{
+			anonymousID: sql.NullString{String: "a1", Valid: true},
+			userID:      sql.NullString{String: "u1", Valid: true},
+			row:         "anonymous_id,a1,user_id,u1\n",
+			ok:          true,
+		},
+		{
+			anonymousID: sql.NullString{String: "a1", Valid: true},
+			row:         "anonymous_id,a1,user_id,\n",
+			ok:          true,
+		},
+		{
+			anonymousID: sql.NullString{Valid: true},
+			userID:      sql.NullString{String: "u1", Valid: true},
+			row:         "user_id,u1,anonymous_id,\n",
+			ok:          true,
+		},
+		{
+			anonymousID: sql.NullString{Valid: true},
+			userID:      sql.NullString{Valid: true},
+		},
+		{},
+	}
+	for _, input := range inputs {
+		row, ok := IdentityMergeRule(input.anonymousID, input.userID)
+		require.Equal(t, input.ok, ok)
+		require.Equal(t, input.row, row)
+	}
+}
+
+func TestWarehouse_LoadMode(t *testing.T) {
+	inputs := []struct {
+		config   map[string]interface{}
+		expected string
+	}{
+		{
+			expected: LoadModeAppend,
+		},
+		{
+			config:   map[string]interface{}{"loadMode": "merge"},
+			expected: LoadModeMerge,
+		},
+		{
+			config:   map[string]interface{}{"loadMode": " MERGE "},
+			expected: LoadModeMerge,
+		},
+		{
+			config:   map[string]interface{}{"loadMode": "append"},
+			expected: LoadModeAppend,
+		},
+		{
+			config:   map[string]interface{}{"loadMode": "upsert"},
+			expected: LoadModeAppend,
+		},
+	}
+	for _, input := range inputs {
+		warehouse := Warehouse{
+			Destination: backendconfig.DestinationT{
+				Config: input.config,
+			},
+		}
+		require.Equal(t, input.expected, warehouse.LoadMode(LoadModeAppend))
+	}
+}
+
+func TestWarehouse_MergePrimaryKeys(t *testing.T) {
+	inputs := []struct {
+		destType  string
+		config    map[string]interface{}
+		tableName string
+		expected  []string
+	}{
+		{
+			destType:  "POSTGRES",
+			tableName: "tracks",
+			expected:  []string{"id"},
+		},
+		{
+			destType: "POSTGRES",
+			config: map[string]interface{}{
+				"mergePrimaryKeys": map[string]interface{}{
+					"tracks": "user_id, event ,",
+				},
+			},
+			tableName: "tracks",
+			expected:  []string{"user_id", "event"},
+		},
+		{
+			destType: "POSTGRES",
+			config: map[string]interface{}{
+				"mergePrimaryKeys": map[string]interface{}{
+					"pages":  "user_id",
+					"tracks": " , ",
+				},
+			},
+			tableName: "tracks",
+			expected:  []string{"id"},
+		},
+		{
+			destType: "SNOWFLAKE",
+			config: map[string]interface{}{
+				"mergePrimaryKeys": map[string]interface{}{
+					"tracks": "user_id,event",
+				},
+			},
+			tableName: "TRACKS",
+			expected:  []string{"USER_ID", "EVENT"},
+		},
+	}
+	for _, input := range inputs {
+		warehouse := Warehouse{
+			Type: input.destType,
+			Destination: backendconfig.DestinationT{
+				Config: input.config,
+			},
+		}
+		require.Equal(t, input.expected, warehouse.MergePrimaryKeys(input.tableName, []string{"id"}))
+	}
+}
+
 func TestMain(m *testing.M) {
 	config.Reset()
 	logger.Reset()
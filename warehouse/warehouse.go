@@ -49,48 +49,80 @@ import (
 )
 
 var (
-	application                         app.App
-	webPort                             int
-	dbHandle                            *sql.DB
-	notifier                            pgnotifier.PgNotifierT
-	noOfSlaveWorkerRoutines             int
-	uploadFreqInS                       int64
-	stagingFilesSchemaPaginationSize    int
-	mainLoopSleep                       time.Duration
-	stagingFilesBatchSize               int
-	crashRecoverWarehouses              []string
-	inRecoveryMap                       map[string]bool
-	lastProcessedMarkerMap              map[string]int64
-	lastProcessedMarkerMapLock          sync.RWMutex
-	warehouseMode                       string
-	warehouseSyncPreFetchCount          int
-	warehouseSyncFreqIgnore             bool
-	minRetryAttempts                    int
-	retryTimeWindow                     time.Duration
-	maxStagingFileReadBufferCapacityInK int
-	connectionsMap                      map[string]map[string]warehouseutils.Warehouse // destID -> sourceID -> warehouse map
-	connectionsMapLock                  sync.RWMutex
-	triggerUploadsMap                   map[string]bool // `whType:sourceID:destinationID` -> boolean value representing if an upload was triggered or not
-	triggerUploadsMapLock               sync.RWMutex
-	sourceIDsByWorkspace                map[string][]string // workspaceID -> []sourceIDs
-	sourceIDsByWorkspaceLock            sync.RWMutex
-	longRunningUploadStatThresholdInMin time.Duration
-	pkgLogger                           logger.Logger
-	numLoadFileUploadWorkers            int
-	slaveUploadTimeout                  time.Duration
-	tableCountQueryTimeout              time.Duration
-	runningMode                         string
-	uploadStatusTrackFrequency          time.Duration
-	uploadAllocatorSleep                time.Duration
-	waitForConfig                       time.Duration
-	waitForWorkerSleep                  time.Duration
-	uploadBufferTimeInMin               int
-	ShouldForceSetLowerVersion          bool
-	skipDeepEqualSchemas                bool
-	maxParallelJobCreation              int
-	enableJitterForSyncs                bool
-	configBackendURL                    string
-	asyncWh                             *jobs.AsyncJobWhT
+	application                             app.App
+	webPort                                 int
+	dbHandle                                *sql.DB
+	notifier                                pgnotifier.NotifierI
+	noOfSlaveWorkerRoutines                 int
+	uploadFreqInS                           int64
+	stagingFilesSchemaPaginationSize        int
+	mainLoopSleep                           time.Duration
+	stagingFilesBatchSize                   int
+	crashRecoverWarehouses                  []string
+	inRecoveryMap                           map[string]bool
+	lastProcessedMarkerMap                  map[string]int64
+	lastProcessedMarkerMapLock              sync.RWMutex
+	warehouseMode                           string
+	warehouseSyncPreFetchCount              int
+	warehouseSyncFreqIgnore                 bool
+	minRetryAttempts                        int
+	retryTimeWindow                         time.Duration
+	maxStagingFileReadBufferCapacityInK     int
+	connectionsMap                          map[string]map[string]warehouseutils.Warehouse // destID -> sourceID -> warehouse map
+	connectionsMapLock                      sync.RWMutex
+	triggerUploadsMap                       map[string]bool // `whType:sourceID:destinationID` -> boolean value representing if an upload was triggered or not
+	triggerUploadsMapLock                   sync.RWMutex
+	sourceIDsByWorkspace                    map[string][]string // workspaceID -> []sourceIDs
+	sourceIDsByWorkspaceLock                sync.RWMutex
+	longRunningUploadStatThresholdInMin     time.Duration
+	pkgLogger                               logger.Logger
+	numLoadFileUploadWorkers                int
+	slaveUploadTimeout                      time.Duration
+	slaveAutoscalingStatsInterval           time.Duration
+	slaveAutoscalingJobsPerWorker           int
+	slaveAutoscalingMinSlaves               int
+	slaveAutoscalingMaxSlaves               int
+	enableReverseETL                        bool
+	reverseETLTickerTime                    time.Duration
+	reverseETLDefaultSyncFrequency          time.Duration
+	reverseETLQueryTimeout                  time.Duration
+	reverseETLMaxRows                       int
+	reverseETLMaxEventsPerBatch             int
+	reverseETLGatewayURL                    string
+	reverseETLGatewayTimeout                time.Duration
+	enableSLAMonitoring                     bool
+	slaTickerTime                           time.Duration
+	enableUsageAccounting                   bool
+	backfillBatchSize                       int
+	queryGatewayTimeout                     time.Duration
+	queryGatewayMaxRows                     int
+	tableCountQueryTimeout                  time.Duration
+	runningMode                             string
+	uploadStatusTrackFrequency              time.Duration
+	uploadAllocatorSleep                    time.Duration
+	waitForConfig                           time.Duration
+	waitForWorkerSleep                      time.Duration
+	uploadBufferTimeInMin                   int
+	ShouldForceSetLowerVersion              bool
+	skipDeepEqualSchemas                    bool
+	enableColumnTypeEvolution               bool
+	enableSchemaHistory                     bool
+	captureSchemaChangeEventSamples         bool
+	maxStagingFilesToSampleForSchemaChanges int
+	schemaHistoryEventSampleRetention       time.Duration
+	schemaHistoryRetentionTickerTime        time.Duration
+	enableRetention                         bool
+	retentionTickerTime                     time.Duration
+	retentionRunInterval                    time.Duration
+	retentionRunTimeout                     time.Duration
+	retentionDeleteBatchWindow              time.Duration
+	maxStagingFilesToCountForDryRun         int
+	enableTableRetries                      bool
+	maxTableRetryAttempts                   int
+	maxParallelJobCreation                  int
+	enableJitterForSyncs                    bool
+	configBackendURL                        string
+	asyncWh                                 *jobs.AsyncJobWhT
 )
 
 var (
@@ -122,7 +154,7 @@ type HandleT struct {
 	warehouses                        []warehouseutils.Warehouse
 	dbHandle                          *sql.DB
 	warehouseDBHandle                 *DB
-	notifier                          pgnotifier.PgNotifierT
+	notifier                          pgnotifier.NotifierI
 	isEnabled                         bool
 	configSubscriberLock              sync.RWMutex
 	workerChannelMap                  map[string]chan *UploadJobT
@@ -181,6 +213,10 @@ func loadConfig() {
 	config.RegisterIntConfigVariable(10240, &maxStagingFileReadBufferCapacityInK, true, 1, "Warehouse.maxStagingFileReadBufferCapacityInK")
 	config.RegisterDurationConfigVariable(120, &longRunningUploadStatThresholdInMin, true, time.Minute, []string{"Warehouse.longRunningUploadStatThreshold", "Warehouse.longRunningUploadStatThresholdInMin"}...)
 	config.RegisterDurationConfigVariable(10, &slaveUploadTimeout, true, time.Minute, []string{"Warehouse.slaveUploadTimeout", "Warehouse.slaveUploadTimeoutInMin"}...)
+	config.RegisterDurationConfigVariable(30, &slaveAutoscalingStatsInterval, true, time.Second, "Warehouse.slaveAutoscaling.statsInterval")
+	config.RegisterIntConfigVariable(1, &slaveAutoscalingJobsPerWorker, true, 1, "Warehouse.slaveAutoscaling.jobsPerWorker")
+	config.RegisterIntConfigVariable(1, &slaveAutoscalingMinSlaves, true, 1, "Warehouse.slaveAutoscaling.minSlaves")
+	config.RegisterIntConfigVariable(0, &slaveAutoscalingMaxSlaves, true, 1, "Warehouse.slaveAutoscaling.maxSlaves")
 	config.RegisterIntConfigVariable(8, &numLoadFileUploadWorkers, true, 1, "Warehouse.numLoadFileUploadWorkers")
 	runningMode = config.GetString("Warehouse.runningMode", "")
 	config.RegisterDurationConfigVariable(30, &uploadStatusTrackFrequency, false, time.Minute, []string{"Warehouse.uploadStatusTrackFrequency", "Warehouse.uploadStatusTrackFrequencyInMin"}...)
@@ -190,6 +226,34 @@ func loadConfig() {
 	config.RegisterDurationConfigVariable(5, &waitForWorkerSleep, false, time.Second, []string{"Warehouse.waitForWorkerSleep", "Warehouse.waitForWorkerSleepInS"}...)
 	config.RegisterBoolConfigVariable(true, &ShouldForceSetLowerVersion, false, "SQLMigrator.forceSetLowerVersion")
 	config.RegisterBoolConfigVariable(false, &skipDeepEqualSchemas, true, "Warehouse.skipDeepEqualSchemas")
+	config.RegisterBoolConfigVariable(false, &enableColumnTypeEvolution, true, "Warehouse.enableColumnTypeEvolution")
+	config.RegisterBoolConfigVariable(true, &enableSchemaHistory, true, "Warehouse.schemaHistory.enabled")
+	config.RegisterBoolConfigVariable(false, &captureSchemaChangeEventSamples, true, "Warehouse.schemaHistory.captureEventSamples")
+	config.RegisterIntConfigVariable(3, &maxStagingFilesToSampleForSchemaChanges, true, 1, "Warehouse.schemaHistory.maxStagingFilesToSample")
+	config.RegisterDurationConfigVariable(168, &schemaHistoryEventSampleRetention, true, time.Hour, "Warehouse.schemaHistory.eventSampleRetention")
+	config.RegisterDurationConfigVariable(60, &schemaHistoryRetentionTickerTime, true, time.Minute, "Warehouse.schemaHistory.retentionTickerTime")
+	config.RegisterBoolConfigVariable(true, &enableRetention, true, "Warehouse.retention.enabled")
+	config.RegisterDurationConfigVariable(60, &retentionTickerTime, true, time.Minute, "Warehouse.retention.tickerTime")
+	config.RegisterDurationConfigVariable(24, &retentionRunInterval, true, time.Hour, "Warehouse.retention.runInterval")
+	config.RegisterDurationConfigVariable(6, &retentionRunTimeout, true, time.Hour, "Warehouse.retention.runTimeout")
+	config.RegisterDurationConfigVariable(24, &retentionDeleteBatchWindow, true, time.Hour, "Warehouse.retention.deleteBatchWindow")
+	config.RegisterIntConfigVariable(10, &maxStagingFilesToCountForDryRun, true, 1, "Warehouse.dryRun.maxStagingFilesToCount")
+	config.RegisterBoolConfigVariable(false, &enableTableRetries, true, "Warehouse.tableRetries.enabled")
+	config.RegisterIntConfigVariable(3, &maxTableRetryAttempts, true, 1, "Warehouse.tableRetries.maxAttempts")
+	config.RegisterBoolConfigVariable(false, &enableReverseETL, true, "Warehouse.reverseETL.enabled")
+	config.RegisterDurationConfigVariable(1, &reverseETLTickerTime, true, time.Minute, "Warehouse.reverseETL.tickerTime")
+	config.RegisterDurationConfigVariable(60, &reverseETLDefaultSyncFrequency, true, time.Minute, "Warehouse.reverseETL.defaultSyncFrequency")
+	config.RegisterDurationConfigVariable(30, &reverseETLQueryTimeout, true, time.Minute, "Warehouse.reverseETL.queryTimeout")
+	config.RegisterIntConfigVariable(100000, &reverseETLMaxRows, true, 1, "Warehouse.reverseETL.maxRows")
+	config.RegisterIntConfigVariable(100, &reverseETLMaxEventsPerBatch, true, 1, "Warehouse.reverseETL.maxEventsPerBatch")
+	config.RegisterStringConfigVariable("http://localhost:8080", &reverseETLGatewayURL, true, "Warehouse.reverseETL.gatewayURL")
+	config.RegisterDurationConfigVariable(30, &reverseETLGatewayTimeout, true, time.Second, "Warehouse.reverseETL.gatewayTimeout")
+	config.RegisterBoolConfigVariable(true, &enableSLAMonitoring, true, "Warehouse.sla.enabled")
+	config.RegisterDurationConfigVariable(5, &slaTickerTime, true, time.Minute, "Warehouse.sla.tickerTime")
+	config.RegisterBoolConfigVariable(true, &enableUsageAccounting, true, "Warehouse.usage.enabled")
+	config.RegisterIntConfigVariable(500, &backfillBatchSize, true, 1, "Warehouse.jobs.backfillBatchSize")
+	config.RegisterDurationConfigVariable(60, &queryGatewayTimeout, true, time.Second, "Warehouse.query.timeout")
+	config.RegisterIntConfigVariable(1000, &queryGatewayMaxRows, true, 1, "Warehouse.query.maxRows")
 	config.RegisterIntConfigVariable(8, &maxParallelJobCreation, true, 1, "Warehouse.maxParallelJobCreation")
 	config.RegisterBoolConfigVariable(false, &enableJitterForSyncs, true, "Warehouse.enableJitterForSyncs")
 	config.RegisterDurationConfigVariable(30, &tableCountQueryTimeout, true, time.Second, []string{"Warehouse.tableCountQueryTimeout", "Warehouse.tableCountQueryTimeoutInS"}...)
@@ -391,7 +455,8 @@ func (wh *HandleT) getStagingFiles(warehouse warehouseutils.Warehouse, startID,
 		  metadata ->> 'time_window_day', 
 		  metadata ->> 'time_window_hour', 
 		  metadata ->> 'use_rudder_storage', 
-		  metadata ->> 'destination_revision_id' 
+		  metadata ->> 'destination_revision_id', 
+		  metadata ->> 'staging_file_format' 
 		FROM 
 		  %[1]s ST
 		WHERE 
@@ -399,6 +464,7 @@ func (wh *HandleT) getStagingFiles(warehouse warehouseutils.Warehouse, startID,
 		  AND ST.id <= %[3]v 
 		  AND ST.source_id = '%[4]s' 
 		  AND ST.destination_id = '%[5]s' 
+		  %[6]s 
 		ORDER BY 
 		  id ASC;
 `,
@@ -408,6 +474,7 @@ func (wh *HandleT) getStagingFiles(warehouse warehouseutils.Warehouse, startID,
 		endID,
 		warehouse.Source.ID,
 		warehouse.Destination.ID,
+		routedNamespaceSQL(warehouse, "ST.namespace"),
 	)
 	rows, err := wh.dbHandle.Query(sqlStatement)
 	if err != nil && err != sql.ErrNoRows {
@@ -419,7 +486,7 @@ func (wh *HandleT) getStagingFiles(warehouse warehouseutils.Warehouse, startID,
 	for rows.Next() {
 		var jsonUpload StagingFileT
 		var timeWindowYear, timeWindowMonth, timeWindowDay, timeWindowHour sql.NullInt64
-		var destinationRevisionID sql.NullString
+		var destinationRevisionID, stagingFileFormat sql.NullString
 		var UseRudderStorage sql.NullBool
 		err := rows.Scan(
 			&jsonUpload.ID,
@@ -431,6 +498,7 @@ func (wh *HandleT) getStagingFiles(warehouse warehouseutils.Warehouse, startID,
 			&timeWindowHour,
 			&UseRudderStorage,
 			&destinationRevisionID,
+			&stagingFileFormat,
 		)
 		if err != nil {
 			panic(fmt.Errorf("Failed to scan result from query: %s\nwith Error : %w", sqlStatement, err))
@@ -438,6 +506,7 @@ func (wh *HandleT) getStagingFiles(warehouse warehouseutils.Warehouse, startID,
 		jsonUpload.TimeWindow = time.Date(int(timeWindowYear.Int64), time.Month(timeWindowMonth.Int64), int(timeWindowDay.Int64), int(timeWindowHour.Int64), 0, 0, 0, time.UTC)
 		jsonUpload.UseRudderStorage = UseRudderStorage.Bool
 		jsonUpload.DestinationRevisionID = destinationRevisionID.String
+		jsonUpload.StagingFileFormat = stagingFileFormat.String
 		stagingFilesList = append(stagingFilesList, &jsonUpload)
 	}
 
@@ -463,6 +532,28 @@ func (wh *HandleT) getPendingStagingFiles(warehouse warehouseutils.Warehouse) ([
 		warehouse.Source.ID,
 		warehouse.Destination.ID,
 	)
+	if warehouse.NamespaceRouting {
+		// the uploads of a routed namespace are the ones whose last staging file was routed to it
+		sqlStatement = fmt.Sprintf(`
+		SELECT 
+		  COALESCE(MAX(UT.end_staging_file_id), 0) 
+		FROM 
+		  %[1]s UT 
+		  JOIN %[2]s ST ON ST.id = UT.end_staging_file_id 
+		WHERE 
+		  UT.destination_type = '%[3]s' 
+		  AND UT.source_id = '%[4]s' 
+		  AND UT.destination_id = '%[5]s' 
+		  %[6]s;
+`,
+			warehouseutils.WarehouseUploadsTable,
+			warehouseutils.WarehouseStagingFilesTable,
+			warehouse.Type,
+			warehouse.Source.ID,
+			warehouse.Destination.ID,
+			routedNamespaceSQL(warehouse, "ST.namespace"),
+		)
+	}
 
 	err := wh.dbHandle.QueryRow(sqlStatement).Scan(&lastStagingFileID)
 	if err != nil && err != sql.ErrNoRows {
@@ -486,13 +577,16 @@ func (wh *HandleT) getPendingStagingFiles(warehouse warehouseutils.Warehouse) ([
 		  metadata ->> 'time_window_month', 
 		  metadata ->> 'time_window_day', 
 		  metadata ->> 'time_window_hour', 
-		  metadata ->> 'destination_revision_id' 
+		  metadata ->> 'destination_revision_id', 
+		  metadata ->> 'staging_file_format', 
+		  total_events 
 		FROM 
 		  %[1]s ST
 		WHERE 
 		  ST.id > %[2]v 
 		  AND ST.source_id = '%[3]s' 
 		  AND ST.destination_id = '%[4]s' 
+		  %[5]s 
 		ORDER BY 
 		  id ASC;
 `,
@@ -500,6 +594,7 @@ func (wh *HandleT) getPendingStagingFiles(warehouse warehouseutils.Warehouse) ([
 		lastStagingFileID,
 		warehouse.Source.ID,
 		warehouse.Destination.ID,
+		routedNamespaceSQL(warehouse, "ST.namespace"),
 	)
 	rows, err := wh.dbHandle.Query(sqlStatement)
 	if err != nil && err != sql.ErrNoRows {
@@ -509,9 +604,10 @@ func (wh *HandleT) getPendingStagingFiles(warehouse warehouseutils.Warehouse) ([
 
 	var stagingFilesList []*StagingFileT
 	var firstEventAt, lastEventAt sql.NullTime
-	var sourceBatchID, sourceTaskID, sourceTaskRunID, sourceJobID, sourceJobRunID, destinationRevisionID sql.NullString
+	var sourceBatchID, sourceTaskID, sourceTaskRunID, sourceJobID, sourceJobRunID, destinationRevisionID, stagingFileFormat sql.NullString
 	var timeWindowYear, timeWindowMonth, timeWindowDay, timeWindowHour sql.NullInt64
 	var UseRudderStorage sql.NullBool
+	var totalEvents sql.NullInt64
 	for rows.Next() {
 		var jsonUpload StagingFileT
 		err := rows.Scan(
@@ -531,15 +627,19 @@ func (wh *HandleT) getPendingStagingFiles(warehouse warehouseutils.Warehouse) ([
 			&timeWindowDay,
 			&timeWindowHour,
 			&destinationRevisionID,
+			&stagingFileFormat,
+			&totalEvents,
 		)
 		if err != nil {
 			panic(fmt.Errorf("Failed to scan result from query: %s\nwith Error : %w", sqlStatement, err))
 		}
 		jsonUpload.FirstEventAt = firstEventAt.Time
+		jsonUpload.TotalEvents = totalEvents.Int64
 		jsonUpload.LastEventAt = lastEventAt.Time
 		jsonUpload.TimeWindow = time.Date(int(timeWindowYear.Int64), time.Month(timeWindowMonth.Int64), int(timeWindowDay.Int64), int(timeWindowHour.Int64), 0, 0, 0, time.UTC)
 		jsonUpload.UseRudderStorage = UseRudderStorage.Bool
 		jsonUpload.DestinationRevisionID = destinationRevisionID.String
+		jsonUpload.StagingFileFormat = stagingFileFormat.String
 		// add cloud sources metadata
 		jsonUpload.SourceBatchID = sourceBatchID.String
 		jsonUpload.SourceTaskID = sourceTaskID.String
@@ -607,6 +707,9 @@ func (wh *HandleT) initUpload(warehouse warehouseutils.Warehouse, jsonUploadsLis
 	if priority != 0 {
 		metadataMap["priority"] = priority
 	}
+	if warehouse.NamespaceRouting {
+		metadataMap[routedNamespaceKey] = warehouse.RoutedNamespace
+	}
 	metadata, err := json.Marshal(metadataMap)
 	if err != nil {
 		panic(err)
@@ -700,14 +803,18 @@ func (wh *HandleT) createUploadJobsFromStagingFiles(warehouse warehouseutils.War
 	// Process staging files in batches of stagingFilesBatchSize
 	// E.g. If there are 1000 pending staging files and stagingFilesBatchSize is 100,
 	// Then we create 10 new entries in wh_uploads table each with 100 staging files
+	// Uploads are also capped at syncMaxPendingEvents events, if set, so that the uploads triggered by it do not grow unbounded
 	var stagingFilesInUpload []*StagingFileT
 	var counter int
+	var eventsInUpload int64
 	uploadTriggered := isUploadTriggered(warehouse)
+	maxEventsInUpload := getConfigValueAsInt(warehouseutils.SyncMaxPendingEvents, warehouse)
 
 	initUpload := func() {
 		wh.initUpload(warehouse, stagingFilesInUpload, uploadTriggered, priority, uploadStartAfter)
 		stagingFilesInUpload = []*StagingFileT{}
 		counter = 0
+		eventsInUpload = 0
 	}
 	for idx, sFile := range stagingFilesList {
 		if idx > 0 && counter > 0 && sFile.UseRudderStorage != stagingFilesList[idx-1].UseRudderStorage {
@@ -716,13 +823,15 @@ func (wh *HandleT) createUploadJobsFromStagingFiles(warehouse warehouseutils.War
 
 		stagingFilesInUpload = append(stagingFilesInUpload, sFile)
 		counter++
-		if counter == stagingFilesBatchSize || idx == len(stagingFilesList)-1 {
+		eventsInUpload += sFile.TotalEvents
+		if counter == stagingFilesBatchSize || (maxEventsInUpload > 0 && eventsInUpload >= maxEventsInUpload) || idx == len(stagingFilesList)-1 {
 			initUpload()
 		}
 	}
 
 	// reset upload trigger if the upload was triggered
-	if uploadTriggered {
+	// the uploads of all the routed namespaces of the warehouse are created before it is reset
+	if uploadTriggered && !warehouse.NamespaceRouting {
 		clearTriggeredUpload(warehouse)
 	}
 }
@@ -735,11 +844,19 @@ func getUploadStartAfterTime() time.Time {
 }
 
 func (wh *HandleT) getLatestUploadStatus(warehouse *warehouseutils.Warehouse) (int64, string, int) {
+	var filterClauses []FilterClause
+	if warehouse.NamespaceRouting {
+		filterClauses = append(filterClauses, FilterClause{
+			Clause:    fmt.Sprintf(`metadata ->> '%s' = %s`, routedNamespaceKey, queryPlaceHolder),
+			ClauseArg: warehouse.RoutedNamespace,
+		})
+	}
 	uploadID, status, priority, err := wh.warehouseDBHandle.GetLatestUploadStatus(
 		context.TODO(),
 		warehouse.Type,
 		warehouse.Source.ID,
-		warehouse.Destination.ID)
+		warehouse.Destination.ID,
+		filterClauses...)
 	if err != nil {
 		pkgLogger.Errorf(`Error getting latest upload status for warehouse: %v`, err)
 	}
@@ -788,6 +905,42 @@ func (wh *HandleT) createJobs(warehouse warehouseutils.Warehouse) (err error) {
 		return nil
 	}
 
+	warehouses := []warehouseutils.Warehouse{warehouse}
+	_, namespaceRouting := warehouseutils.GetNamespaceRouting(warehouse.Destination)
+	if namespaceRouting {
+		// staging files routed to each namespace are uploaded separately
+		routedNamespaces, err := wh.getPendingRoutedNamespaces(warehouse)
+		if err != nil {
+			pkgLogger.Errorf("[WH]: Failed to get pending routed namespaces: %s with error %v", warehouse.Identifier, err)
+			return err
+		}
+		warehouses = warehouses[:0]
+		for _, routedNamespace := range routedNamespaces {
+			warehouses = append(warehouses, routedWarehouse(warehouse, routedNamespace))
+		}
+	}
+
+	var createdUploads bool
+	uploadStartAfter := getUploadStartAfterTime()
+	for _, warehouse := range warehouses {
+		created, err := wh.createNamespaceJobs(warehouse, whManager, uploadStartAfter)
+		if err != nil {
+			return err
+		}
+		createdUploads = createdUploads || created
+	}
+	if createdUploads {
+		if namespaceRouting && isUploadTriggered(warehouse) {
+			clearTriggeredUpload(warehouse)
+		}
+		setLastProcessedMarker(warehouse, uploadStartAfter)
+	}
+
+	return nil
+}
+
+// createNamespaceJobs creates the uploads of the pending staging files of the namespace of the warehouse
+func (wh *HandleT) createNamespaceJobs(warehouse warehouseutils.Warehouse, whManager manager.ManagerI, uploadStartAfter time.Time) (created bool, err error) {
 	wh.areBeingEnqueuedLock.Lock()
 
 	priority := 0
@@ -811,13 +964,13 @@ func (wh *HandleT) createJobs(warehouse warehouseutils.Warehouse) (err error) {
 	stagingFilesList, err := wh.getPendingStagingFiles(warehouse)
 	if err != nil {
 		pkgLogger.Errorf("[WH]: Failed to get pending staging files: %s with error %v", warehouse.Identifier, err)
-		return err
+		return false, err
 	}
 	stagingFilesFetchStat.End()
 
 	if len(stagingFilesList) == 0 {
-		pkgLogger.Debugf("[WH]: Found no pending staging files for %s", warehouse.Identifier)
-		return nil
+		pkgLogger.Debugf("[WH]: Found no pending staging files for %s in namespace %s", warehouse.Identifier, warehouse.Namespace)
+		return false, nil
 	}
 
 	uploadJobCreationStat := stats.Default.NewTaggedStat("wh_scheduler.create_upload_jobs", stats.TimerType, stats.Tags{
@@ -827,13 +980,11 @@ func (wh *HandleT) createJobs(warehouse warehouseutils.Warehouse) (err error) {
 	})
 	uploadJobCreationStat.Start()
 
-	uploadStartAfter := getUploadStartAfterTime()
 	wh.createUploadJobsFromStagingFiles(warehouse, whManager, stagingFilesList, priority, uploadStartAfter)
-	setLastProcessedMarker(warehouse, uploadStartAfter)
 
 	uploadJobCreationStat.End()
 
-	return nil
+	return true, nil
 }
 
 func (wh *HandleT) mainLoop(ctx context.Context) {
@@ -1048,6 +1199,11 @@ func (wh *HandleT) getUploadsToProcess(availableWorkers int, skipIdentifiers []s
 		upload.SourceType = warehouse.Source.SourceDefinition.Name
 		upload.SourceCategory = warehouse.Source.SourceDefinition.Category
 
+		if routedNamespace, ok := getRoutedNamespace(upload.Metadata); ok {
+			warehouse = routedWarehouse(warehouse, routedNamespace)
+			warehouse.Namespace = upload.Namespace
+		}
+
 		stagingFilesList, err := wh.getStagingFiles(warehouse, upload.StartStagingFileID, upload.EndStagingFileID)
 		if err != nil {
 			return nil, err
@@ -1069,7 +1225,7 @@ func (wh *HandleT) getUploadsToProcess(availableWorkers int, skipIdentifiers []s
 			warehouse:            warehouse,
 			whManager:            whManager,
 			dbHandle:             wh.dbHandle,
-			pgNotifier:           &wh.notifier,
+			pgNotifier:           wh.notifier,
 			destinationValidator: validations.NewDestinationValidator(),
 		}
 
@@ -1565,6 +1721,7 @@ func processHandler(w http.ResponseWriter, r *http.Request) {
 		"time_window_day":         stagingFile.TimeWindow.Day(),
 		"time_window_hour":        stagingFile.TimeWindow.Hour(),
 		"destination_revision_id": stagingFile.DestinationRevisionID,
+		"staging_file_format":     stagingFile.StagingFileFormat,
 	}
 	metadata, err := json.Marshal(metadataMap)
 	if err != nil {
@@ -1585,10 +1742,11 @@ func processHandler(w http.ResponseWriter, r *http.Request) {
 			last_event_at,
 			created_at,
 			updated_at,
-			metadata
+			metadata,
+			namespace
 		)
 		VALUES
-		 ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`, warehouseutils.WarehouseStagingFilesTable)
+		 ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`, warehouseutils.WarehouseStagingFilesTable)
 	stmt, err := dbHandle.Prepare(sqlStatement)
 	if err != nil {
 		panic(err)
@@ -1610,6 +1768,7 @@ func processHandler(w http.ResponseWriter, r *http.Request) {
 		now,
 		now,
 		metadata,
+		stagingFile.Namespace,
 	)
 	if err != nil {
 		panic(err)
@@ -1890,6 +2049,103 @@ func triggerUploadHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
+func dryRunHandler(w http.ResponseWriter, r *http.Request) {
+	pkgLogger.LogRequest(r)
+
+	if r.Method != "POST" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	// read body
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		pkgLogger.Errorf("[WH]: Error reading body: %v", err)
+		http.Error(w, "can't read body", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	// unmarshall body
+	var dryRunReq warehouseutils.DryRunRequestT
+	err = json.Unmarshal(body, &dryRunReq)
+	if err != nil {
+		pkgLogger.Errorf("[WH]: Error unmarshalling body: %v", err)
+		http.Error(w, "can't unmarshall body", http.StatusBadRequest)
+		return
+	}
+
+	plan, err := (&DryRunReqT{
+		WorkspaceID:   dryRunReq.WorkspaceID,
+		SourceID:      dryRunReq.SourceID,
+		DestinationID: dryRunReq.DestinationID,
+		API:           UploadAPI,
+	}).DryRunUpload(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	planJSON, err := json.Marshal(plan)
+	if err != nil {
+		http.Error(w, "can't marshall plan", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(planJSON)
+}
+
+// queryHandler runs a read-only query on the warehouse of a connection, streaming the result
+// as csv if the format query param or the Accept header asks for it and as json otherwise
+func queryHandler(w http.ResponseWriter, r *http.Request) {
+	pkgLogger.LogRequest(r)
+
+	if r.Method != "POST" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	// read body
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		pkgLogger.Errorf("[WH]: Error reading body: %v", err)
+		http.Error(w, "can't read body", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	// unmarshall body
+	var queryReq warehouseutils.QueryRequestT
+	err = json.Unmarshal(body, &queryReq)
+	if err != nil {
+		pkgLogger.Errorf("[WH]: Error unmarshalling body: %v", err)
+		http.Error(w, "can't unmarshall body", http.StatusBadRequest)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" && strings.Contains(r.Header.Get("Accept"), "text/csv") {
+		format = QueryFormatCSV
+	}
+	if format != "" && format != QueryFormatJSON && format != QueryFormatCSV {
+		http.Error(w, fmt.Sprintf("unsupported format: %s", format), http.StatusBadRequest)
+		return
+	}
+
+	queryWriter := newQueryHTTPWriter(w, format)
+	err = (&QueryReqT{
+		WorkspaceID:   queryReq.WorkspaceID,
+		SourceID:      queryReq.SourceID,
+		DestinationID: queryReq.DestinationID,
+		SQLStatement:  queryReq.SQLStatement,
+		Limit:         queryReq.Limit,
+		API:           UploadAPI,
+	}).Query(r.Context(), queryWriter)
+	if err != nil {
+		queryWriter.Fail(err)
+	}
+}
+
 func TriggerUploadHandler(sourceID, destID string) error {
 	// return error if source id and dest id is empty
 	if sourceID == "" && destID == "" {
@@ -1963,28 +2219,36 @@ func clearTriggeredUpload(wh warehouseutils.Warehouse) {
 	triggerUploadsMapLock.Unlock()
 }
 
-func healthHandler(w http.ResponseWriter, _ *http.Request) {
+func healthHandler(w http.ResponseWriter, r *http.Request) {
 	dbService := ""
 	pgNotifierService := ""
 	if runningMode != DegradedMode {
-		if !CheckPGHealth(notifier.GetDBHandle()) {
+		if notifier == nil || !notifier.CheckHealth(r.Context()) {
 			http.Error(w, "Cannot connect to pgNotifierService", http.StatusInternalServerError)
 			return
 		}
 		pgNotifierService = "UP"
 	}
 
+	// the backlog of the slaves, along with the number of slaves recommended to run it, once known by the master
+	waitingJobs, oldestWaitingJobAge, recommendedSlavesCount := "", "", ""
 	if isMaster() {
 		if !CheckPGHealth(dbHandle) {
 			http.Error(w, "Cannot connect to dbService", http.StatusInternalServerError)
 			return
 		}
 		dbService = "UP"
+
+		if queueStats, recommended, ok := getRecommendedSlaves(); ok {
+			waitingJobs = strconv.FormatInt(queueStats.WaitingJobs, 10)
+			oldestWaitingJobAge = strconv.Itoa(int(queueStats.OldestWaitingJobAge / time.Second))
+			recommendedSlavesCount = strconv.Itoa(recommended)
+		}
 	}
 
 	healthVal := fmt.Sprintf(
-		`{"server":"UP","db":%q,"pgNotifier":%q,"acceptingEvents":"TRUE","warehouseMode":%q,"goroutines":"%d"}`,
-		dbService, pgNotifierService, strings.ToUpper(warehouseMode), runtime.NumGoroutine(),
+		`{"server":"UP","db":%q,"pgNotifier":%q,"acceptingEvents":"TRUE","warehouseMode":%q,"goroutines":"%d","waitingJobs":%q,"oldestWaitingJobAgeInS":%q,"recommendedSlaves":%q}`,
+		dbService, pgNotifierService, strings.ToUpper(warehouseMode), runtime.NumGoroutine(), waitingJobs, oldestWaitingJobAge, recommendedSlavesCount,
 	)
 	w.Write([]byte(healthVal))
 }
@@ -2014,17 +2278,28 @@ func startWebHandler(ctx context.Context) error {
 			mux.HandleFunc("/v1/warehouse/pending-events", pendingEventsHandler)
 			// triggers uploads for a source
 			mux.HandleFunc("/v1/warehouse/trigger-upload", triggerUploadHandler)
+			// previews the next upload of a connection without running it
+			mux.HandleFunc("/v1/warehouse/dry-run", dryRunHandler)
+			mux.HandleFunc("/v1/warehouse/query", queryHandler)
 			mux.HandleFunc("/databricksVersion", databricksVersionHandler)
 			mux.HandleFunc("/v1/setConfig", setConfigHandler)
 
 			// Warehouse Async Job end-points
 			mux.HandleFunc("/v1/warehouse/jobs", asyncWh.AddWarehouseJobHandler)
 			mux.HandleFunc("/v1/warehouse/jobs/status", asyncWh.StatusWarehouseJobHandler)
+			// backfill, identity resolution and rewrite jobs
+			mux.HandleFunc("/v1/warehouse/async-jobs", asyncWh.AddAsyncJobHandler)
+			mux.HandleFunc("/v1/warehouse/async-jobs/status", asyncWh.AsyncJobStatusHandler)
+			mux.HandleFunc("/v1/warehouse/async-jobs/cancel", asyncWh.CancelAsyncJobHandler)
 
 			pkgLogger.Infof("WH: Starting warehouse master service in %d", webPort)
 		} else {
 			pkgLogger.Infof("WH: Starting warehouse slave service in %d", webPort)
 		}
+		if isSlave() {
+			// stops claiming jobs ahead of scaling down the slave
+			mux.HandleFunc("/v1/warehouse/slave/drain", drainSlaveHandler)
+		}
 	}
 
 	srv := &http.Server{
@@ -2131,7 +2406,7 @@ func Start(ctx context.Context, app app.App) error {
 	}
 	var err error
 	workspaceIdentifier := fmt.Sprintf(`%s::%s`, config.GetKubeNamespace(), misc.GetMD5Hash(config.GetWorkspaceToken()))
-	notifier, err = pgnotifier.New(workspaceIdentifier, psqlInfo)
+	notifier, err = pgnotifier.NewNotifier(workspaceIdentifier, psqlInfo)
 	if err != nil {
 		panic(err)
 	}
@@ -2192,13 +2467,33 @@ func Start(ctx context.Context, app app.App) error {
 			runArchiver(ctx, dbHandle)
 			return nil
 		}))
+		g.Go(misc.WithBugsnagForWarehouse(func() error {
+			runRetention(ctx, dbHandle)
+			return nil
+		}))
+		g.Go(misc.WithBugsnagForWarehouse(func() error {
+			monitorSlaveBacklog(ctx)
+			return nil
+		}))
+		g.Go(misc.WithBugsnagForWarehouse(func() error {
+			runReverseETL(ctx, dbHandle)
+			return nil
+		}))
+		g.Go(misc.WithBugsnagForWarehouse(func() error {
+			runSLAMonitor(ctx, dbHandle)
+			return nil
+		}))
+		g.Go(misc.WithBugsnagForWarehouse(func() error {
+			runSchemaHistoryRetention(ctx, dbHandle)
+			return nil
+		}))
 
 		err := InitWarehouseAPI(dbHandle, pkgLogger.Child("upload_api"))
 		if err != nil {
 			pkgLogger.Errorf("WH: Failed to start warehouse api: %v", err)
 			return err
 		}
-		asyncWh = jobs.InitWarehouseJobsAPI(ctx, dbHandle, &notifier)
+		asyncWh = jobs.InitWarehouseJobsAPI(ctx, dbHandle, notifier)
 
 		g.Go(misc.WithBugsnagForWarehouse(func() error {
 			return asyncWh.InitAsyncJobRunner()
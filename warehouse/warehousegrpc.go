@@ -177,3 +177,96 @@ func (*warehouseGRPC) CountWHUploadsToRetry(ctx context.Context, req *proto.Retr
 	}
 	return
 }
+
+func (*warehouseGRPC) GetWHSchemaHistory(ctx context.Context, request *proto.WHSchemaHistoryRequest) (*proto.WHSchemaHistoryResponse, error) {
+	historyReq := SchemaHistoryReqT{
+		WorkspaceID:   request.WorkspaceId,
+		SourceID:      request.SourceId,
+		DestinationID: request.DestinationId,
+		Namespace:     request.Namespace,
+		TableName:     request.TableName,
+		ColumnName:    request.ColumnName,
+		UploadID:      request.UploadId,
+		Limit:         request.Limit,
+		Offset:        request.Offset,
+		API:           UploadAPI,
+	}
+	return historyReq.GetSchemaHistory(ctx)
+}
+
+func (*warehouseGRPC) GetWHColumnLineage(ctx context.Context, request *proto.WHColumnLineageRequest) (*proto.WHColumnLineageResponse, error) {
+	historyReq := SchemaHistoryReqT{
+		WorkspaceID:   request.WorkspaceId,
+		DestinationID: request.DestinationId,
+		Namespace:     request.Namespace,
+		TableName:     request.TableName,
+		ColumnName:    request.ColumnName,
+		API:           UploadAPI,
+	}
+	return historyReq.GetColumnLineage(ctx)
+}
+
+func (*warehouseGRPC) GetWHSLABreaches(ctx context.Context, request *proto.WHSLABreachesRequest) (*proto.WHSLABreachesResponse, error) {
+	breachesReq := SLABreachesReqT{
+		WorkspaceID:     request.WorkspaceId,
+		SourceID:        request.SourceId,
+		DestinationID:   request.DestinationId,
+		IncludeResolved: request.IncludeResolved,
+		Limit:           request.Limit,
+		Offset:          request.Offset,
+		API:             UploadAPI,
+	}
+	return breachesReq.GetSLABreaches(ctx)
+}
+
+func usageReqFromProto(request *proto.WHUsageRequest) UsageReqT {
+	usageReq := UsageReqT{
+		WorkspaceID:   request.WorkspaceId,
+		SourceID:      request.SourceId,
+		DestinationID: request.DestinationId,
+		TableName:     request.TableName,
+		ByTable:       request.ByTable,
+		Limit:         request.Limit,
+		Offset:        request.Offset,
+		API:           UploadAPI,
+	}
+	if request.StartTime != nil {
+		usageReq.StartTime = request.StartTime.AsTime()
+	}
+	if request.EndTime != nil {
+		usageReq.EndTime = request.EndTime.AsTime()
+	}
+	return usageReq
+}
+
+func (*warehouseGRPC) GetWHUsage(ctx context.Context, request *proto.WHUsageRequest) (*proto.WHUsageResponse, error) {
+	usageReq := usageReqFromProto(request)
+	return usageReq.GetUsage(ctx)
+}
+
+func (*warehouseGRPC) GetWHUsageSummary(ctx context.Context, request *proto.WHUsageRequest) (*proto.WHUsageResponse, error) {
+	usageReq := usageReqFromProto(request)
+	return usageReq.GetUsageSummary(ctx)
+}
+
+func (*warehouseGRPC) DryRunWHUpload(ctx context.Context, request *proto.WHDryRunRequest) (*proto.WHDryRunResponse, error) {
+	dryRunReq := DryRunReqT{
+		WorkspaceID:   request.WorkspaceId,
+		SourceID:      request.SourceId,
+		DestinationID: request.DestinationId,
+		API:           UploadAPI,
+	}
+	return dryRunReq.DryRunUpload(ctx)
+}
+
+func (*warehouseGRPC) QueryWH(request *proto.WHQueryRequest, stream proto.Warehouse_QueryWHServer) error {
+	queryReq := QueryReqT{
+		WorkspaceID:   request.WorkspaceId,
+		SourceID:      request.SourceId,
+		DestinationID: request.DestinationId,
+		SQLStatement:  request.SqlStatement,
+		Limit:         request.Limit,
+		API:           UploadAPI,
+	}
+	return queryReq.Query(stream.Context(), &queryStreamWriterT{stream: stream})
+}